@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"library-management/library"
+)
+
+// maxBatchBody caps the size of a batch request; books may carry their
+// text.
+const maxBatchBody = 64 << 20
+
+// batchRequest is the body of a batch endpoint: the items to apply, in
+// order.
+type batchRequest[T any] struct {
+	Items []T `json:"items"`
+}
+
+// batchResponse reports each item's outcome, in request order, with counts
+// by status.
+type batchResponse struct {
+	Results []library.BatchResult `json:"results"`
+	Counts  map[string]int        `json:"counts"`
+}
+
+// authenticateStaff returns the member ID of a staff member signed in to the
+// request, writing the error response if there is none.
+func (s *Server) authenticateStaff(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	memberID, err := s.authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="library"`)
+		writeError(w, http.StatusUnauthorized, err)
+		return 0, false
+	}
+	m, err := s.mgr.GetMemberCtx(r.Context(), memberID)
+	if err != nil || !m.IsAdmin {
+		writeError(w, http.StatusForbidden, fmt.Errorf("staff privileges required"))
+		return 0, false
+	}
+	return memberID, true
+}
+
+// handleBatch decodes a batch of T from a staff member's request and writes
+// the results of apply, which is given the request's context and the staff
+// member's ID.
+func handleBatch[T any](s *Server, apply func(ctx context.Context, items []T, staffID int64) ([]library.BatchResult, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		staffID, ok := s.authenticateStaff(w, r)
+		if !ok {
+			return
+		}
+		var req batchRequest[T]
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBody))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid batch: %w", err))
+			return
+		}
+		results, err := apply(r.Context(), req.Items, staffID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		counts := make(map[string]int)
+		for _, res := range results {
+			counts[res.Status]++
+		}
+		writeJSON(w, http.StatusOK, batchResponse{Results: results, Counts: counts})
+	}
+}
+
+func (s *Server) handleBatchBooks() http.HandlerFunc {
+	return handleBatch(s, s.mgr.AddBooksCtx)
+}
+
+func (s *Server) handleBatchMembers() http.HandlerFunc {
+	return handleBatch(s, func(ctx context.Context, members []library.BatchMember, _ int64) ([]library.BatchResult, error) {
+		return s.mgr.UpsertMembersCtx(ctx, members)
+	})
+}
+
+func (s *Server) handleBatchCirculation() http.HandlerFunc {
+	return handleBatch(s, func(ctx context.Context, events []library.CirculationEvent, _ int64) ([]library.BatchResult, error) {
+		return s.mgr.ApplyCirculationCtx(ctx, events)
+	})
+}
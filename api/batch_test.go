@@ -0,0 +1,110 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"library-management/library"
+)
+
+func postBatch(t *testing.T, url string, memberID int64, password, body string) (int, batchResponse) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewBufferString(body))
+	req.SetBasicAuth(fmt.Sprint(memberID), password)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	var out batchResponse
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	return resp.StatusCode, out
+}
+
+func TestBatchSync(t *testing.T) {
+	mgr, srv := newTestServer(t)
+	staff, _ := mgr.AddMember("Staff", "password1")
+	mgr.SetMemberAdmin(staff, true)
+	alice, _ := mgr.AddMember("Alice", "password1")
+	existing, _ := mgr.AddBook("Emma", "Austen")
+
+	books := `{"items": [
+		{"key": "b1", "title": "Dune", "author": "Herbert", "isbn": "978-0-441-17271-9"},
+		{"key": "b2", "title": "emma", "author": "AUSTEN"},
+		{"key": "b3", "title": "", "author": "Nobody"}
+	]}`
+	if code, _ := postBatch(t, srv.URL+"/batch/books", alice, "password1", books); code != http.StatusForbidden {
+		t.Fatalf("member without staff rights: status %d", code)
+	}
+	code, out := postBatch(t, srv.URL+"/batch/books", staff, "password1", books)
+	if code != http.StatusOK || len(out.Results) != 3 {
+		t.Fatalf("status %d, results %+v", code, out.Results)
+	}
+	dune := out.Results[0].ID
+	if out.Results[0].Status != library.BatchCreated || out.Results[1].Status != library.BatchDuplicate ||
+		out.Results[1].ID != existing || out.Results[2].Status != library.BatchFailed || out.Results[2].Index != 2 {
+		t.Fatalf("results = %+v", out.Results)
+	}
+	if b, err := mgr.GetBook(dune); err != nil || b.ISBN != "9780441172719" {
+		t.Fatalf("added book = %+v, %v", b, err)
+	}
+
+	// Sent again after a timeout: nothing is added twice
+	_, out = postBatch(t, srv.URL+"/batch/books", staff, "password1", books)
+	if !out.Results[0].Replayed || out.Results[0].ID != dune || out.Results[0].Status != library.BatchCreated {
+		t.Fatalf("retried results = %+v", out.Results)
+	}
+	if n, _ := mgr.CountBooks(); n != 2 {
+		t.Fatalf("%d books after the retry, want 2", n)
+	}
+
+	members := fmt.Sprintf(`{"items": [
+		{"key": "m1", "name": "Bob", "tier": "senior"},
+		{"name": "Alice", "expiry_date": "2030-01-31"},
+		{"id": %d, "name": "Alice Smith"},
+		{"name": "Carol", "tier": "platinum"}
+	]}`, alice)
+	_, out = postBatch(t, srv.URL+"/batch/members", staff, "password1", members)
+	if out.Counts[library.BatchCreated] != 1 || out.Counts[library.BatchUpdated] != 2 || out.Counts[library.BatchFailed] != 1 {
+		t.Fatalf("member results = %+v", out.Results)
+	}
+	bob := out.Results[0].ID
+	if m, _ := mgr.GetMember(alice); m.Name != "Alice Smith" || m.ExpiryTime.Year() != 2030 {
+		t.Fatalf("Alice = %+v", m)
+	}
+
+	events := fmt.Sprintf(`{"items": [
+		{"key": "c1", "type": "checkout", "book_id": %[1]d, "member_id": %[2]d},
+		{"key": "c2", "type": "checkout", "book_id": %[1]d, "member_id": %[3]d},
+		{"key": "c3", "type": "return", "book_id": %[1]d, "member_id": %[3]d},
+		{"key": "c4", "type": "return", "book_id": %[1]d, "member_id": %[2]d},
+		{"type": "renew", "book_id": %[1]d}
+	]}`, dune, alice, bob)
+	_, out = postBatch(t, srv.URL+"/batch/circulation", staff, "password1", events)
+	statuses := ""
+	for _, r := range out.Results {
+		statuses += r.Status + " "
+	}
+	if statuses != "applied failed failed applied failed " || out.Results[0].ID == 0 || out.Results[3].ID != out.Results[0].ID {
+		t.Fatalf("circulation results = %+v", out.Results)
+	}
+	// The failed checkout can be retried under its key; the applied one isn't repeated
+	_, out = postBatch(t, srv.URL+"/batch/circulation", staff, "password1", events)
+	if !out.Results[0].Replayed || out.Results[1].Status != library.BatchApplied || out.Results[1].Replayed {
+		t.Fatalf("retried circulation = %+v", out.Results)
+	}
+
+	if code, _ := postBatch(t, srv.URL+"/batch/books", staff, "password1", `{"items": []}`); code != http.StatusBadRequest {
+		t.Fatalf("empty batch: status %d", code)
+	}
+	if code, _ := postBatch(t, srv.URL+"/batch/books", staff, "password1", `{"items": [{"titel": "Typo"}]}`); code != http.StatusBadRequest {
+		t.Fatalf("unknown field: status %d", code)
+	}
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"library-management/library"
+)
+
+var catalogTemplates = template.Must(template.New("").Parse(`
+{{define "index"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Library catalog</title>
+</head>
+<body>
+<h1>Library catalog</h1>
+<ul>
+{{range .}}<li><a href="catalog/{{.ID}}">{{.Title}}</a> by {{.Author}}</li>
+{{end}}</ul>
+</body>
+</html>
+{{end}}
+
+{{define "book"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} by {{.Author}}</title>
+<meta name="description" content="{{.Title}} by {{.Author}}: {{.Status}}">
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>by {{.Author}}</p>
+<dl>
+<dt>Type</dt><dd>{{.ItemType}}</dd>
+<dt>Availability</dt><dd>{{.Status}}</dd>
+</dl>
+<p><a href="../catalog">Back to the catalog</a></p>
+</body>
+</html>
+{{end}}
+`))
+
+// catalogPage is the data behind a book's public page.
+type catalogPage struct {
+	*library.Book
+	Status string
+}
+
+func availability(b *library.Book) string {
+	switch {
+	case b.NonCirculating:
+		return "Reference only: use it in the library"
+	case b.Available:
+		return "Available"
+	default:
+		return "On loan"
+	}
+}
+
+func (s *Server) handleCatalogIndex(w http.ResponseWriter, r *http.Request) {
+	books, err := s.mgr.GetAllBookSummariesCtx(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var modified time.Time
+	for _, b := range books {
+		if b.UpdatedTime.After(modified) {
+			modified = b.UpdatedTime
+		}
+	}
+	s.renderHTML(w, r, modified, "index", books)
+}
+
+func (s *Server) handleCatalogPage(w http.ResponseWriter, r *http.Request) {
+	bookID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	b, err := s.mgr.GetBookCtx(r.Context(), bookID)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	s.renderHTML(w, r, b.UpdatedTime, "book", catalogPage{Book: b, Status: availability(b)})
+}
+
+func (s *Server) renderHTML(w http.ResponseWriter, r *http.Request, modified time.Time, name string, data any) {
+	var buf bytes.Buffer
+	if err := catalogTemplates.ExecuteTemplate(&buf, name, data); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	serveCacheable(w, r, modified, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// sitemap follows https://www.sitemaps.org/protocol.html.
+type sitemap struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+func (s *Server) handleSitemap(w http.ResponseWriter, r *http.Request) {
+	books, err := s.mgr.GetAllBookSummariesCtx(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	base := s.baseURL(r)
+	var modified time.Time
+	sm := sitemap{URLs: make([]sitemapURL, 0, len(books))}
+	for _, b := range books {
+		sm.URLs = append(sm.URLs, sitemapURL{
+			Loc:     fmt.Sprintf("%s/catalog/%d", base, b.ID),
+			LastMod: b.UpdatedTime.UTC().Format(time.RFC3339),
+		})
+		if b.UpdatedTime.After(modified) {
+			modified = b.UpdatedTime
+		}
+	}
+	body, err := xml.MarshalIndent(sm, "", "  ")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	serveCacheable(w, r, modified, "application/xml", append([]byte(xml.Header), body...))
+}
+
+// baseURL is the absolute URL this library is served from, as seen by the
+// client. Behind a TLS-terminating proxy, X-Forwarded-Proto gives the scheme.
+func (s *Server) baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if p := r.Header.Get("X-Forwarded-Proto"); p == "http" || p == "https" {
+		scheme = p
+	}
+	base := scheme + "://" + r.Host
+	if s.tenant != "" {
+		base += "/" + s.tenant
+	}
+	return base
+}
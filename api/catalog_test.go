@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCatalogPagesAndSitemap(t *testing.T) {
+	mgr, srv := newTestServer(t)
+	bookID, _ := mgr.AddBook("Dune <Deluxe>", "Frank Herbert")
+	refID, _ := mgr.AddBook("Atlas", "Cartographer")
+	mgr.SetNonCirculating(refID, true)
+
+	resp := get(t, fmt.Sprintf("%s/catalog/%d", srv.URL, bookID), 0, "")
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Fatalf("book page: status %d, type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	page := string(body)
+	if !strings.Contains(page, "<h1>Dune &lt;Deluxe&gt;</h1>") || !strings.Contains(page, "Available") {
+		t.Fatalf("unexpected book page:\n%s", page)
+	}
+	if resp.Header.Get("ETag") == "" {
+		t.Fatalf("book page should be cacheable")
+	}
+
+	resp = get(t, fmt.Sprintf("%s/catalog/%d", srv.URL, refID), 0, "")
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "Reference only") {
+		t.Fatalf("reference item should say so:\n%s", body)
+	}
+
+	if resp := get(t, srv.URL+"/catalog/999", 0, ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown book: status %d", resp.StatusCode)
+	}
+
+	resp = get(t, srv.URL+"/sitemap.xml", 0, "")
+	defer resp.Body.Close()
+	var sm struct {
+		URLs []struct {
+			Loc     string `xml:"loc"`
+			LastMod string `xml:"lastmod"`
+		} `xml:"url"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&sm); err != nil {
+		t.Fatalf("decode sitemap: %v", err)
+	}
+	want := fmt.Sprintf("%s/catalog/%d", srv.URL, bookID)
+	if len(sm.URLs) != 2 || sm.URLs[0].Loc != want || sm.URLs[0].LastMod == "" {
+		t.Fatalf("unexpected sitemap: %+v", sm.URLs)
+	}
+}
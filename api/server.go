@@ -0,0 +1,276 @@
+// Package api serves the library over HTTP for web and mobile clients.
+//
+// Members authenticate with HTTP Basic auth, using their member ID as the
+// user name, or with a bearer token from POST /tokens. Catalog resources are
+// public.
+//
+// A Tenants router hosts several independent libraries, each in its own
+// database, under /{library}/. Tokens issued by one library are rejected by
+// every other.
+//
+// GET /capabilities lists which optional subsystems (fines, email,
+// full-text search, server mode) the library has, and GET /version which
+// build is serving it and the schema version of its database.
+//
+// The public catalog is also served as plain HTML pages under /catalog,
+// listed in /sitemap.xml, so search engines can index a library's holdings.
+//
+// Staff sync external systems through POST /batch/books, /batch/members and
+// /batch/circulation, which apply up to library.MaxBatchItems items each and
+// report every item's outcome. Items with a "key" are applied only once, so
+// a batch that failed partway can simply be sent again.
+//
+// Responses carry an ETag and Last-Modified header, and conditional requests
+// (If-None-Match, If-Modified-Since) are answered with 304 Not Modified when
+// the client's copy is current.
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"library-management/library"
+)
+
+// Server routes API requests to a LibraryManager.
+type Server struct {
+	mgr *library.LibraryManager
+	mux *http.ServeMux
+	// tenant names the library when the server is one of several; its
+	// tokens are scoped to it. Empty for a single-library server.
+	tenant string
+}
+
+// NewServer creates a Server backed by mgr.
+func NewServer(mgr *library.LibraryManager) *Server {
+	return newServer(mgr, "")
+}
+
+func newServer(mgr *library.LibraryManager, tenant string) *Server {
+	s := &Server{mgr: mgr, mux: http.NewServeMux(), tenant: tenant}
+	s.mux.HandleFunc("POST /tokens", s.handleIssueToken)
+	s.mux.HandleFunc("GET /books", s.handleListBooks)
+	s.mux.HandleFunc("GET /books/{id}", s.handleGetBook)
+	s.mux.HandleFunc("GET /books/{id}/pages/{n}", s.handleGetPage)
+	s.mux.HandleFunc("GET /catalog", s.handleCatalogIndex)
+	s.mux.HandleFunc("GET /catalog/{id}", s.handleCatalogPage)
+	s.mux.HandleFunc("GET /sitemap.xml", s.handleSitemap)
+	s.mux.HandleFunc("GET /capabilities", s.handleCapabilities)
+	s.mux.HandleFunc("GET /version", s.handleVersion)
+	s.mux.HandleFunc("POST /batch/books", s.handleBatchBooks())
+	s.mux.HandleFunc("POST /batch/members", s.handleBatchMembers())
+	s.mux.HandleFunc("POST /batch/circulation", s.handleBatchCirculation())
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// catalogRecord is the public view of a book, without its content.
+type catalogRecord struct {
+	ID             int64     `json:"id"`
+	Title          string    `json:"title"`
+	Author         string    `json:"author"`
+	ItemType       string    `json:"item_type"`
+	Available      bool      `json:"available"`
+	NonCirculating bool      `json:"non_circulating"`
+	UpdatedTime    time.Time `json:"updated_time"`
+}
+
+func newCatalogRecord(b *library.Book) catalogRecord {
+	return catalogRecord{
+		ID:             b.ID,
+		Title:          b.Title,
+		Author:         b.Author,
+		ItemType:       b.ItemType,
+		Available:      b.Available,
+		NonCirculating: b.NonCirculating,
+		UpdatedTime:    b.UpdatedTime,
+	}
+}
+
+// authenticate returns the member ID from the request's bearer token or
+// Basic credentials.
+func (s *Server) authenticate(r *http.Request) (int64, error) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return s.authenticateToken(r.Context(), token)
+	}
+	return s.authenticateBasic(r)
+}
+
+func (s *Server) authenticateToken(ctx context.Context, token string) (int64, error) {
+	if s.tenant != "" {
+		if scope, _, _ := strings.Cut(token, "."); scope != s.tenant {
+			return 0, fmt.Errorf("token was not issued by this library")
+		}
+	}
+	return s.mgr.AuthenticateTokenCtx(ctx, token)
+}
+
+func (s *Server) authenticateBasic(r *http.Request) (int64, error) {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return 0, fmt.Errorf("authentication required")
+	}
+	memberID, err := strconv.ParseInt(user, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid member ID: %s", user)
+	}
+	if err := s.mgr.AuthenticateMemberCtx(r.Context(), memberID, password); err != nil {
+		return 0, err
+	}
+	return memberID, nil
+}
+
+// handleIssueToken exchanges a member's Basic credentials for a bearer token.
+func (s *Server) handleIssueToken(w http.ResponseWriter, r *http.Request) {
+	memberID, err := s.authenticateBasic(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="library"`)
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	token, err := s.mgr.IssueTokenCtx(r.Context(), memberID, s.tenant)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"token": token})
+}
+
+func (s *Server) handleListBooks(w http.ResponseWriter, r *http.Request) {
+	books, err := s.mgr.GetAllBookSummariesCtx(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	records := make([]catalogRecord, 0, len(books))
+	var modified time.Time
+	for _, b := range books {
+		records = append(records, newCatalogRecord(b))
+		if b.UpdatedTime.After(modified) {
+			modified = b.UpdatedTime
+		}
+	}
+	writeCacheable(w, r, modified, records)
+}
+
+func (s *Server) handleGetBook(w http.ResponseWriter, r *http.Request) {
+	bookID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid book ID: %s", r.PathValue("id")))
+		return
+	}
+	b, err := s.mgr.GetBookCtx(r.Context(), bookID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("book not found"))
+		return
+	}
+	writeCacheable(w, r, b.UpdatedTime, newCatalogRecord(b))
+}
+
+// handleGetPage serves one page of a book the member is allowed to read.
+func (s *Server) handleGetPage(w http.ResponseWriter, r *http.Request) {
+	memberID, err := s.authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="library"`)
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	bookID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid book ID: %s", r.PathValue("id")))
+		return
+	}
+	n, err := strconv.Atoi(r.PathValue("n"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid page number: %s", r.PathValue("n")))
+		return
+	}
+
+	modified, err := s.mgr.GetBookUpdatedTimeCtx(r.Context(), bookID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("book not found"))
+		return
+	}
+
+	page, err := s.mgr.GetPageCtx(r.Context(), bookID, memberID, n)
+	switch {
+	case errors.Is(err, library.ErrPageOutOfRange):
+		writeError(w, http.StatusNotFound, err)
+	case err != nil:
+		// Loan and authorization failures
+		writeError(w, http.StatusForbidden, err)
+	default:
+		w.Header().Set("Cache-Control", "private, no-cache")
+		writeCacheable(w, r, modified, page)
+	}
+}
+
+// handleCapabilities lists the library's optional subsystems, so clients
+// can hide what isn't available.
+func (s *Server) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	caps, err := s.mgr.CapabilitiesCtx(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, caps)
+}
+
+// handleVersion reports the build serving the library and its database's
+// schema version.
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	v, err := s.mgr.VersionInfoCtx(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// writeCacheable writes v as JSON with a content-hash ETag and the given
+// Last-Modified time, letting http.ServeContent answer conditional requests.
+func writeCacheable(w http.ResponseWriter, r *http.Request, modified time.Time, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	serveCacheable(w, r, modified, "application/json", body)
+}
+
+// serveCacheable writes body with a content-hash ETag and answers
+// conditional requests.
+func serveCacheable(w http.ResponseWriter, r *http.Request, modified time.Time, contentType string, body []byte) {
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Content-Type", contentType)
+	http.ServeContent(w, r, "", modified, bytes.NewReader(body))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	// Whatever the handler made of it, a request that ran out of time
+	// found the library too busy
+	if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"library-management/library"
+)
+
+func newTestServer(t *testing.T) (*library.LibraryManager, *httptest.Server) {
+	t.Helper()
+	mgr, err := library.NewLibraryManager(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	t.Cleanup(func() { mgr.Close() })
+	srv := httptest.NewServer(NewServer(mgr))
+	t.Cleanup(srv.Close)
+	return mgr, srv
+}
+
+func get(t *testing.T, url string, memberID int64, password string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if memberID > 0 {
+		req.SetBasicAuth(fmt.Sprint(memberID), password)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	return resp
+}
+
+func TestGetPage(t *testing.T) {
+	mgr, srv := newTestServer(t)
+
+	content := "CHAPTER I\n" + strings.Repeat("a", library.PageSize) + "\nCHAPTER II\nThe end."
+	bookID, _ := mgr.AddBook("Paged Book", "Author")
+	if err := mgr.UpdateBookContent(bookID, content); err != nil {
+		t.Fatalf("update content: %v", err)
+	}
+	alice, _ := mgr.AddMember("Alice", "password1")
+	bob, _ := mgr.AddMember("Bob", "password2")
+	if err := mgr.CheckoutBook(bookID, alice); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+
+	url := fmt.Sprintf("%s/books/%d/pages/2", srv.URL, bookID)
+
+	if resp := get(t, url, 0, ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("missing credentials: status %d", resp.StatusCode)
+	}
+	if resp := get(t, url, alice, "wrong"); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("bad password: status %d", resp.StatusCode)
+	}
+	if resp := get(t, url, bob, "password2"); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("reader without the loan: status %d", resp.StatusCode)
+	}
+
+	resp := get(t, url, alice, "password1")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("borrower: status %d", resp.StatusCode)
+	}
+	var page library.Page
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if page.Number != 2 || page.TotalPages != 2 || page.Chapter != "CHAPTER II" || !strings.HasSuffix(page.Text, "The end.") {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+
+	out := fmt.Sprintf("%s/books/%d/pages/3", srv.URL, bookID)
+	if resp := get(t, out, alice, "password1"); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("page past the end: status %d", resp.StatusCode)
+	}
+}
+
+func TestConditionalCatalogRequests(t *testing.T) {
+	mgr, srv := newTestServer(t)
+	bookID, _ := mgr.AddBook("Cacheable", "Author")
+	alice, _ := mgr.AddMember("Alice", "password1")
+	url := fmt.Sprintf("%s/books/%d", srv.URL, bookID)
+
+	resp := get(t, url, 0, "")
+	resp.Body.Close()
+	etag, modified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || etag == "" || modified == "" {
+		t.Fatalf("status %d, ETag %q, Last-Modified %q", resp.StatusCode, etag, modified)
+	}
+
+	conditional := func(header, value string) int {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set(header, value)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := conditional("If-None-Match", etag); code != http.StatusNotModified {
+		t.Fatalf("matching ETag: status %d", code)
+	}
+	if code := conditional("If-Modified-Since", modified); code != http.StatusNotModified {
+		t.Fatalf("unmodified since: status %d", code)
+	}
+
+	// Checking the book out changes the record
+	if err := mgr.CheckoutBook(bookID, alice); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	if code := conditional("If-None-Match", etag); code != http.StatusOK {
+		t.Fatalf("stale ETag: status %d", code)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	_, srv := newTestServer(t)
+	resp := get(t, srv.URL+"/version", 0, "")
+	defer resp.Body.Close()
+	var v library.VersionInfo
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %v", resp.StatusCode, err)
+	}
+	if v.Version != library.Version || v.SQLiteVersion == "" || !v.FTS5 || v.SchemaVersion == 0 || v.SchemaVersion != v.LatestSchema {
+		t.Fatalf("version = %+v", v)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	mgr, srv := newTestServer(t)
+	mgr.SetCapability(library.CapServer, true, "test")
+
+	resp := get(t, srv.URL+"/capabilities", 0, "")
+	defer resp.Body.Close()
+	var caps []library.Capability
+	if err := json.NewDecoder(resp.Body).Decode(&caps); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %v", resp.StatusCode, err)
+	}
+	enabled := map[string]bool{}
+	for _, c := range caps {
+		enabled[c.Name] = c.Enabled
+	}
+	want := map[string]bool{library.CapFines: true, library.CapEmail: false, library.CapFullTextSearch: true, library.CapServer: true}
+	if fmt.Sprint(enabled) != fmt.Sprint(want) {
+		t.Fatalf("capabilities = %v, want %v", enabled, want)
+	}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"library-management/library"
+)
+
+// tenantName restricts library names to what is safe in a URL path segment
+// and a file name.
+var tenantName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Tenants serves several libraries from one process. Each library has its
+// own LibraryManager, and so its own database file; requests for
+// /{library}/... are routed to that library's Server with the prefix removed.
+type Tenants struct {
+	servers map[string]*Server
+}
+
+// NewTenants creates a router for the given libraries, keyed by name.
+func NewTenants(libraries map[string]*library.LibraryManager) (*Tenants, error) {
+	t := &Tenants{servers: make(map[string]*Server, len(libraries))}
+	for name, mgr := range libraries {
+		if !ValidTenantName(name) {
+			return nil, fmt.Errorf("invalid library name %q", name)
+		}
+		t.servers[name] = newServer(mgr, name)
+	}
+	return t, nil
+}
+
+// ValidTenantName reports whether name can identify a hosted library.
+func ValidTenantName(name string) bool {
+	return tenantName.MatchString(name)
+}
+
+func (t *Tenants) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	srv, ok := t.servers[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("library not found"))
+		return
+	}
+	http.StripPrefix("/"+name, srv).ServeHTTP(w, r)
+}
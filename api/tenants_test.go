@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"library-management/library"
+)
+
+func newTenantServer(t *testing.T, names ...string) (map[string]*library.LibraryManager, *httptest.Server) {
+	t.Helper()
+	dir := t.TempDir()
+	libraries := make(map[string]*library.LibraryManager)
+	for _, name := range names {
+		mgr, err := library.NewLibraryManager(filepath.Join(dir, name+".db"))
+		if err != nil {
+			t.Fatalf("new manager: %v", err)
+		}
+		t.Cleanup(func() { mgr.Close() })
+		libraries[name] = mgr
+	}
+	tenants, err := NewTenants(libraries)
+	if err != nil {
+		t.Fatalf("new tenants: %v", err)
+	}
+	srv := httptest.NewServer(tenants)
+	t.Cleanup(srv.Close)
+	return libraries, srv
+}
+
+func issueToken(t *testing.T, url string, memberID int64, password string) string {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, nil)
+	req.SetBasicAuth(fmt.Sprint(memberID), password)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("issue token: status %d", resp.StatusCode)
+	}
+	var body struct{ Token string }
+	json.NewDecoder(resp.Body).Decode(&body)
+	return body.Token
+}
+
+func getWithToken(t *testing.T, url, token string) int {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestTenantIsolation(t *testing.T) {
+	libs, srv := newTenantServer(t, "north", "south")
+	north, south := libs["north"], libs["south"]
+
+	// Same IDs exist in both libraries but belong to different people
+	northBook, _ := north.AddBook("Northern Lights", "Author")
+	north.UpdateBookContent(northBook, "Aurora.")
+	southBook, _ := south.AddBook("Southern Cross", "Author")
+	south.UpdateBookContent(southBook, "Stars.")
+	alice, _ := north.AddMember("Alice", "north-pass")
+	bob, _ := south.AddMember("Bob", "south-pass")
+	if alice != bob {
+		t.Fatalf("expected colliding member IDs, got %d and %d", alice, bob)
+	}
+	north.CheckoutBook(northBook, alice)
+	south.CheckoutBook(southBook, bob)
+
+	var books []struct{ Title string }
+	resp := get(t, srv.URL+"/north/books", 0, "")
+	json.NewDecoder(resp.Body).Decode(&books)
+	resp.Body.Close()
+	if len(books) != 1 || books[0].Title != "Northern Lights" {
+		t.Fatalf("north catalog leaked other libraries' books: %+v", books)
+	}
+
+	token := issueToken(t, srv.URL+"/north/tokens", alice, "north-pass")
+	northPage := fmt.Sprintf("%s/north/books/%d/pages/1", srv.URL, northBook)
+	southPage := fmt.Sprintf("%s/south/books/%d/pages/1", srv.URL, southBook)
+	if code := getWithToken(t, northPage, token); code != http.StatusOK {
+		t.Fatalf("token in its own library: status %d", code)
+	}
+	if code := getWithToken(t, southPage, token); code != http.StatusUnauthorized {
+		t.Fatalf("token in another library: status %d", code)
+	}
+
+	// A password is only valid in the library that holds the account
+	if resp := get(t, southPage, alice, "north-pass"); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("north credentials in south: status %d", resp.StatusCode)
+	}
+	if resp := get(t, southPage, bob, "south-pass"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("south member in south: status %d", resp.StatusCode)
+	}
+
+	if resp := get(t, srv.URL+"/west/books", 0, ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown library: status %d", resp.StatusCode)
+	}
+}
+
+func TestNewTenantsRejectsBadNames(t *testing.T) {
+	if _, err := NewTenants(map[string]*library.LibraryManager{"../etc": nil}); err == nil {
+		t.Fatalf("expected invalid library name to be rejected")
+	}
+}
@@ -28,102 +28,46 @@ func main() {
 	}
 	defer manager.Close()
 
-	// Book metadata mapping (filename -> [title, author])
-	bookMetadata := map[string][2]string{
-		"1984.txt":                            {"1984", "George Orwell"},
-		"animal_farm.txt":                     {"Animal Farm", "George Orwell"},
-		"anne_frank.txt":                      {"The Diary of a Young Girl", "Anne Frank"},
-		"art_of_war.txt":                      {"The Art of War", "Sun Tzu"},
-		"fellowship_of_the_ring.txt":          {"The Fellowship of the Ring", "J.R.R. Tolkien"},
-		"harry_potter_chamber_of_secrets.txt": {"Harry Potter and the Chamber of Secrets", "J.K. Rowling"},
-		"harry_potter_deathly_hallows.txt":    {"Harry Potter and the Deathly Hallows", "J.K. Rowling"},
-		"harry_potter_half_blood_prince.txt":  {"Harry Potter and the Half-Blood Prince", "J.K. Rowling"},
-		"harry_potter_order_pheonix.txt":      {"Harry Potter and the Order of the Phoenix", "J.K. Rowling"},
-		"harry_potter_prisoner_azkaban.txt":   {"Harry Potter and the Prisoner of Azkaban", "J.K. Rowling"},
-		"harry_potter_scorcerers_stone.txt":   {"Harry Potter and the Philosopher's Stone", "J.K. Rowling"},
-		"return_of_the_king.txt":              {"The Return of the King", "J.R.R. Tolkien"},
-		"romeo_and_juliet.txt":                {"Romeo and Juliet", "William Shakespeare"},
-		"the_two_towers.txt":                  {"The Two Towers", "J.R.R. Tolkien"},
-		"three_little_pigs.txt":               {"The Three Little Pigs", "Traditional"},
-		"three_musketeers.txt":                {"The Three Musketeers", "Alexandre Dumas"},
+	// The catalog lists each book's text file, title and author
+	catalogPath := filepath.Join("texts", "catalog.csv")
+	if len(os.Args) > 1 {
+		catalogPath = os.Args[1]
 	}
+	fmt.Printf("Importing books listed in %s...\n", catalogPath)
 
-	// Import books from the texts directory
-	booksDir := "texts"
-	fmt.Printf("Importing books from %s directory...\n", booksDir)
-
-	files, err := os.ReadDir(booksDir)
+	f, err := os.Open(catalogPath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading books directory: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error reading catalog: %v\n", err)
 		os.Exit(1)
 	}
-
-	successCount := 0
-	errorCount := 0
-
-	for _, file := range files {
-		if file.IsDir() || !strings.HasSuffix(file.Name(), ".txt") {
-			continue
-		}
-
-		filename := file.Name()
-		metadata, exists := bookMetadata[filename]
-		if !exists {
-			fmt.Printf("Warning: No metadata found for %s, skipping\n", filename)
-			continue
-		}
-
-		title := metadata[0]
-		author := metadata[1]
-		filePath := filepath.Join(booksDir, filename)
-
-		fmt.Printf("Importing: %s by %s... ", title, author)
-
-		// Check if file exists and is readable
-		if _, err := os.Stat(filePath); err != nil {
-			fmt.Printf("ERROR - File not accessible: %v\n", err)
-			errorCount++
-			continue
-		}
-
-		// Add book to database
-		bookID, err := manager.AddBookFromFile(title, author, filePath)
-		if err != nil {
-			fmt.Printf("ERROR - %v\n", err)
-			errorCount++
-			continue
-		}
-
-		fmt.Printf("SUCCESS (ID: %d)\n", bookID)
-		successCount++
+	report, err := manager.ImportBooksCSV(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error importing catalog: %v\n", err)
+		os.Exit(1)
 	}
 
-	fmt.Printf("\nImport complete!\n")
-	fmt.Printf("Successfully imported: %d books\n", successCount)
-	fmt.Printf("Errors: %d\n", errorCount)
+	fmt.Printf("\nImport complete: %s\n", report)
+	for _, issue := range report.Duplicates {
+		fmt.Printf("  line %d: skipped, %s\n", issue.Line, issue.Reason)
+	}
+	for _, issue := range report.Rejected {
+		fmt.Printf("  line %d: ERROR - %s\n", issue.Line, issue.Reason)
+	}
+	successCount := len(report.Added)
 
 	// Display summary of imported books
 	if successCount > 0 {
 		fmt.Println("\nImported books:")
-		books, err := manager.GetAllBooks()
+		books, err := manager.GetAllBookSummaries()
 		if err != nil {
 			fmt.Printf("Error retrieving books: %v\n", err)
 		} else {
 			fmt.Printf("%-3s %-50s %-30s\n", "ID", "Title", "Author")
 			fmt.Println(strings.Repeat("-", 85))
 			for _, book := range books {
-				fmt.Printf("%-3d %-50s %-30s\n", book.ID, truncateString(book.Title, 50), truncateString(book.Author, 30))
+				fmt.Printf("%-3d %-50s %-30s\n", book.ID, library.Truncate(book.Title, 50), library.Truncate(book.Author, 30))
 			}
 		}
 	}
 }
-
-func truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	if maxLen <= 3 {
-		return s[:maxLen]
-	}
-	return s[:maxLen-3] + "..."
-}
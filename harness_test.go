@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"library-management/library"
+)
+
+// expectTimeout is how long expect waits for output before failing.
+const expectTimeout = 5 * time.Second
+
+// cliSession is the interactive shell running in-process over a fresh
+// library, driven expect-style: send types a line, expect waits for the
+// shell to print something. Passwords are typed like any other answer.
+//
+// The shell prints to os.Stdout, which a session takes over while it runs,
+// so sessions must not run in parallel.
+type cliSession struct {
+	t   *testing.T
+	mgr *library.LibraryManager
+
+	lines chan string // typed lines not yet read
+
+	mu   sync.Mutex
+	out  bytes.Buffer
+	seen int // output already matched by expect
+
+	done chan struct{} // closed when the shell exits
+}
+
+// startCLI starts the shell over an empty library.
+func startCLI(t *testing.T) *cliSession {
+	t.Helper()
+	return startCLIOver(t, newTestLibrary(t))
+}
+
+// newTestLibrary opens an empty library in a temporary directory.
+func newTestLibrary(t *testing.T) *library.LibraryManager {
+	t.Helper()
+	mgr, err := library.NewLibraryManager(filepath.Join(t.TempDir(), "library.db"))
+	if err != nil {
+		t.Fatalf("new library: %v", err)
+	}
+	t.Cleanup(func() { mgr.Close() })
+	// Hashing at full cost would make every sign-in take a good part of
+	// expectTimeout under the race detector
+	mgr.SetBcryptCost(bcrypt.MinCost)
+	return mgr
+}
+
+// startCLIOver starts the shell over mgr, with the shell's package state
+// (login session, output format, recent IDs) reset and times shown in UTC.
+func startCLIOver(t *testing.T, mgr *library.LibraryManager) *cliSession {
+	t.Helper()
+	session, greeted, lastPrompt = nil, map[int64]bool{}, ""
+	outputFormat, listedBooks, recentIDs = "table", nil, map[string][]int64{}
+	zone := displayZone
+	displayZone = time.UTC
+
+	s := &cliSession{t: t, mgr: mgr, lines: make(chan string, 100), done: make(chan struct{})}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, w
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		io.Copy(s, r)
+	}()
+
+	scanner := bufio.NewScanner(lineReader(s.lines))
+	scanner.Split(scanInput)
+	secret := readSecret
+	readSecret = func() ([]byte, error) {
+		if !scanner.Scan() {
+			return nil, io.EOF
+		}
+		return []byte(scanner.Text()), nil
+	}
+
+	go func() {
+		defer close(s.done)
+		runPrompt(scanner, mgr, library.NewJobRunner(nil))
+	}()
+
+	t.Cleanup(func() {
+		close(s.lines)
+		select {
+		case <-s.done:
+		case <-time.After(expectTimeout):
+			t.Errorf("shell still running at the end of the test")
+		}
+		w.Close()
+		<-copied
+		os.Stdout, os.Stderr = stdout, stderr
+		readSecret = secret
+		displayZone = zone
+	})
+	return s
+}
+
+func (s *cliSession) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.out.Write(p)
+}
+
+// send types line at the shell.
+func (s *cliSession) send(line string) {
+	s.lines <- line
+}
+
+// expect waits for the shell to print want, after whatever earlier expects
+// matched, and fails the test if it doesn't within expectTimeout.
+func (s *cliSession) expect(want string) {
+	s.t.Helper()
+	deadline := time.Now().Add(expectTimeout)
+	for {
+		s.mu.Lock()
+		unseen := s.out.String()[s.seen:]
+		if i := strings.Index(unseen, want); i >= 0 {
+			s.seen += i + len(want)
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+		if time.Now().After(deadline) {
+			s.t.Fatalf("timed out waiting for %q; the shell printed:\n%s", want, unseen)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// output waits for the next command prompt and returns what the shell
+// printed before it, since the last expect. The prompt is left for run.
+func (s *cliSession) output() string {
+	s.t.Helper()
+	deadline := time.Now().Add(expectTimeout)
+	for {
+		s.mu.Lock()
+		unseen := s.out.String()[s.seen:]
+		if i := strings.Index(unseen, commandPrompt); i >= 0 {
+			s.seen += i
+			s.mu.Unlock()
+			return unseen[:i]
+		}
+		s.mu.Unlock()
+		if time.Now().After(deadline) {
+			s.t.Fatalf("timed out waiting for the command prompt; the shell printed:\n%s", unseen)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// run sends a command and answers its questions in turn, waiting for each
+// question before answering, then waits for the next command prompt.
+// Questions and answers alternate: run("login", "Member ID: ", "1", ...).
+func (s *cliSession) run(command string, dialog ...string) {
+	s.t.Helper()
+	s.expect(commandPrompt)
+	s.send(command)
+	for i := 0; i+1 < len(dialog); i += 2 {
+		s.expect(dialog[i])
+		s.send(dialog[i+1])
+	}
+}
+
+// lineReader hands the scanner one typed line per Read, blocking until a
+// line is sent, and reports the end of input once lines is closed.
+type lineReader chan string
+
+func (lr lineReader) Read(p []byte) (int, error) {
+	line, ok := <-lr
+	if !ok {
+		return 0, io.EOF
+	}
+	return copy(p, line+"\n"), nil
+}
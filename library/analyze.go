@@ -0,0 +1,268 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// WordCount is how often a word occurs in a book.
+type WordCount struct {
+	Word  string `json:"word"`
+	Count int    `json:"count"`
+}
+
+// BookAnalysis is the vocabulary of a book's text. Words are lower-cased
+// runs of letters and digits, with apostrophes inside words kept.
+type BookAnalysis struct {
+	BookID      int64       `json:"book_id"`
+	Title       string      `json:"title"`
+	Pages       int         `json:"pages"`
+	TotalWords  int         `json:"total_words"`
+	UniqueWords int         `json:"unique_words"`
+	Top         []WordCount `json:"top"` // most frequent, leaving out common English words
+}
+
+// ConcordanceLine is one occurrence of a word with its surroundings.
+type ConcordanceLine struct {
+	Page    int    `json:"page"` // reader page (1-based) the word starts on
+	Context string `json:"context"`
+}
+
+// concordanceWidth is how much text either side of a word a concordance
+// line shows, in bytes.
+const concordanceWidth = 40
+
+// stopWords are left out of BookAnalysis.Top; they top every book's list.
+var stopWords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`a about after all also an and any are as at be been but by can could did do does
+		for from had has have he her him his how i if in into is it its just me more my no not now of on one or our
+		out she so some than that the their them then there these they this those to up us very was we were what
+		when which who will with would you your`) {
+		stopWords[w] = true
+	}
+}
+
+// AnalyzeBook counts the words of bookID's text and lists the top most
+// frequent ones.
+func (d *Database) AnalyzeBook(bookID int64, top int) (*BookAnalysis, error) {
+	a := &BookAnalysis{BookID: bookID}
+	if err := d.db.QueryRow(`SELECT title FROM books WHERE id=?`, bookID).Scan(&a.Title); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("book not found")
+		}
+		return nil, err
+	}
+	counts := map[string]int{}
+	pages, err := d.scanBookWords(bookID, func(w bookWord) {
+		counts[w.word]++
+		a.TotalWords++
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.Pages, a.UniqueWords = pages, len(counts)
+	for w, n := range counts {
+		if !stopWords[w] {
+			a.Top = append(a.Top, WordCount{Word: w, Count: n})
+		}
+	}
+	sort.Slice(a.Top, func(i, j int) bool {
+		if a.Top[i].Count != a.Top[j].Count {
+			return a.Top[i].Count > a.Top[j].Count
+		}
+		return a.Top[i].Word < a.Top[j].Word
+	})
+	if len(a.Top) > top {
+		a.Top = a.Top[:top]
+	}
+	return a, nil
+}
+
+// Concordance lists every occurrence of word (matched as a whole word,
+// ignoring case) in bookID with the page it is on and the text around it.
+func (d *Database) Concordance(bookID int64, word string) ([]*ConcordanceLine, error) {
+	word = strings.ToLower(strings.TrimSpace(word))
+	if word == "" || strings.IndexFunc(word, func(r rune) bool { return !isWordRune(r) }) >= 0 {
+		return nil, fmt.Errorf("give a single word to look up")
+	}
+	var exists int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM books WHERE id=?`, bookID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		return nil, fmt.Errorf("book not found")
+	}
+	var lines []*ConcordanceLine
+	_, err := d.scanBookWords(bookID, func(w bookWord) {
+		if w.word == word {
+			lines = append(lines, &ConcordanceLine{Page: w.page, Context: w.context()})
+		}
+	})
+	return lines, err
+}
+
+// bookWord is a word found by scanBookWords. before and after are the text
+// around it, for concordance lines; they are only valid during the visit.
+type bookWord struct {
+	word          string
+	page          int
+	before, after string
+	raw           string
+}
+
+func (w bookWord) context() string {
+	before, after := w.before, w.after
+	// Cutting at a byte count can split a character; drop its pieces
+	if len(before) > concordanceWidth {
+		before = "…" + strings.ToValidUTF8(before[len(before)-concordanceWidth:], "")
+	}
+	if len(after) > concordanceWidth {
+		after = strings.ToValidUTF8(after[:concordanceWidth], "") + "…"
+	}
+	flat := strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ")
+	return flat.Replace(before) + "[" + w.raw + "]" + flat.Replace(after)
+}
+
+func isWordRune(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+// scanBookWords reads bookID's text one reader page (PageSize bytes) at a
+// time and calls visit for each word, so memory use is bounded by a page
+// rather than the book. A word running over a page boundary counts on the
+// page it starts on. It returns the number of pages.
+func (d *Database) scanBookWords(bookID int64, visit func(bookWord)) (int, error) {
+	r, err := d.openBookContent(bookID)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	buf := make([]byte, PageSize)
+	var (
+		pending     string // a word, or a rune, cut off by the end of the last page
+		pendingPage int
+		tail        string // text before pending, for context
+		page        int
+	)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return page, err
+		}
+		eof := err != nil
+		if n > 0 {
+			page++
+		}
+		text := pending + string(buf[:n])
+		pageOf := func(start int) int {
+			if start < len(pending) {
+				return pendingPage
+			}
+			return page
+		}
+
+		cut := len(text) // where this pass stops; the rest carries over
+		for i := 0; i < len(text); {
+			if !eof && !utf8.FullRuneInString(text[i:]) {
+				cut = i
+				break
+			}
+			c, size := utf8.DecodeRuneInString(text[i:])
+			if !isWordRune(c) {
+				i += size
+				continue
+			}
+			start := i
+			end := wordEnd(text, i)
+			// wordEnd looks one rune past an apostrophe, and a rune cut
+			// off by the page end reads as a non-letter; a word ending
+			// that close to the end may continue on the next page
+			if !eof && end+2*utf8.UTFMax > len(text) {
+				cut = start
+				break
+			}
+			visit(bookWord{
+				word:   strings.ToLower(text[start:end]),
+				raw:    text[start:end],
+				page:   pageOf(start),
+				before: tail + text[:start],
+				after:  text[end:],
+			})
+			i = end
+		}
+
+		if eof {
+			return page, nil
+		}
+		if cut < len(text) {
+			pendingPage = pageOf(cut)
+		}
+		tail = lastBytes(tail+text[:cut], concordanceWidth+utf8.UTFMax)
+		pending = text[cut:]
+	}
+}
+
+// wordEnd returns where the word starting at i in text ends. Apostrophes
+// between letters stay in the word ("don't", "o’clock").
+func wordEnd(text string, i int) int {
+	for i < len(text) {
+		c, size := utf8.DecodeRuneInString(text[i:])
+		if isWordRune(c) {
+			i += size
+			continue
+		}
+		if c == '\'' || c == '’' {
+			next, _ := utf8.DecodeRuneInString(text[i+size:])
+			if i+size < len(text) && isWordRune(next) {
+				i += size
+				continue
+			}
+		}
+		break
+	}
+	return i
+}
+
+func lastBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[len(s)-n:]
+}
+
+// openBookContent returns a reader over bookID's text, streaming it from
+// the content store when it is kept there.
+func (d *Database) openBookContent(bookID int64) (io.ReadCloser, error) {
+	var content string
+	var ref sql.NullString
+	err := d.db.QueryRow(`SELECT `+contentColumns+` FROM books b WHERE b.id=?`, bookID).Scan(&content, &ref)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("book not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ref.Valid {
+		return io.NopCloser(strings.NewReader(content)), nil
+	}
+	if d.contentStore == nil {
+		return nil, fmt.Errorf("book content is in a content store, but none is configured")
+	}
+	return d.contentStore.Get(ref.String)
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) AnalyzeBook(bookID int64, top int) (*BookAnalysis, error) {
+	return lm.db.AnalyzeBook(bookID, top)
+}
+
+func (lm *LibraryManager) Concordance(bookID int64, word string) ([]*ConcordanceLine, error) {
+	return lm.db.Concordance(bookID, word)
+}
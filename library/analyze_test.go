@@ -0,0 +1,83 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeBook(t *testing.T) {
+	db := tempDB(t)
+	// Two pages; the run of x straddles the boundary, so the later words are on page 2
+	filler := strings.Repeat("x", PageSize-3)
+	text := "The Ring was forged. " + filler + " ring of power. Don't touch the ring’s edge; the RING calls."
+	id, _ := db.AddBook("Rings", "Tolkien", text)
+
+	a, err := db.AnalyzeBook(id, 3)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if a.Pages != 2 || a.TotalWords != 16 || a.UniqueWords != 12 {
+		t.Fatalf("unexpected analysis: %+v", a)
+	}
+	if len(a.Top) != 3 || a.Top[0] != (WordCount{"ring", 3}) {
+		t.Fatalf("unexpected top words: %+v", a.Top)
+	}
+
+	lines, err := db.Concordance(id, "Ring")
+	if err != nil || len(lines) != 3 {
+		t.Fatalf("concordance: %v %+v", err, lines)
+	}
+	if lines[0].Page != 1 || lines[0].Context != "The [Ring] was forged. "+filler[:concordanceWidth-len(" was forged. ")]+"…" {
+		t.Fatalf("unexpected first line: %+v", lines[0])
+	}
+	if lines[1].Page != 2 || !strings.HasSuffix(lines[1].Context, "[ring] of power. Don't touch the ring’s edge…") {
+		t.Fatalf("unexpected second line: %+v", lines[1])
+	}
+	if lines[2].Page != 2 || !strings.Contains(lines[2].Context, "the [RING] calls.") {
+		t.Fatalf("unexpected third line: %+v", lines[2])
+	}
+
+	if _, err := db.Concordance(id, "two words"); err == nil {
+		t.Fatalf("expected a phrase to be refused")
+	}
+}
+
+func TestScanBookWordsAcrossPages(t *testing.T) {
+	db := tempDB(t)
+	// The page boundary falls inside the é of "café"
+	text := strings.Repeat("a ", (PageSize-4)/2) + "café" + " é" + strings.Repeat(" z", 10)
+	id, _ := db.AddBook("Split", "Tester", text)
+
+	var words []bookWord
+	pages, err := db.scanBookWords(id, func(w bookWord) { words = append(words, w) })
+	if err != nil || pages != 2 {
+		t.Fatalf("scan: %d pages, %v", pages, err)
+	}
+	var cafe, accent *bookWord
+	for i := range words {
+		switch words[i].word {
+		case "café":
+			cafe = &words[i]
+		case "é":
+			accent = &words[i]
+		}
+	}
+	if cafe == nil || cafe.page != 1 || accent == nil || accent.page != 2 {
+		t.Fatalf("split words mishandled: %+v %+v", cafe, accent)
+	}
+}
+
+func TestScanBookWordsApostropheAtPageEnd(t *testing.T) {
+	db := tempDB(t)
+	// The apostrophe ends page 1 and the "t" starts page 2
+	text := strings.Repeat("a ", (PageSize-6)/2) + "don’t stop"
+	id, _ := db.AddBook("Split", "Tester", text)
+
+	a, err := db.AnalyzeBook(id, 5)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if len(a.Top) != 2 || a.Top[0].Word != "don’t" || a.Top[1].Word != "stop" {
+		t.Fatalf("apostrophe word split across pages: %+v", a.Top)
+	}
+}
@@ -0,0 +1,118 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Annotation kinds.
+const (
+	AnnotationNote      = "note"
+	AnnotationHighlight = "highlight"
+)
+
+// Annotation is a member's private note on, or highlighted passage of, a
+// page of a book.
+type Annotation struct {
+	ID          int64     `json:"id"`
+	MemberID    int64     `json:"member_id"`
+	BookID      int64     `json:"book_id"`
+	BookTitle   string    `json:"book_title"`
+	Page        int       `json:"page"` // 1-based, as in the reader
+	Kind        string    `json:"kind"`
+	Text        string    `json:"text"`
+	Snippet     string    `json:"snippet,omitempty"` // search matches only; matched terms in [brackets]
+	CreatedTime time.Time `json:"created_time"`
+}
+
+// AddAnnotation saves a note or highlight by memberID on page (1-based) of
+// bookID.
+func (d *Database) AddAnnotation(memberID, bookID int64, page int, kind, text string) (int64, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, fmt.Errorf("%s cannot be empty", kind)
+	}
+	if kind != AnnotationNote && kind != AnnotationHighlight {
+		return 0, fmt.Errorf("unknown annotation kind %q", kind)
+	}
+	v, err := d.ValidateReadBookAccess(bookID, memberID)
+	if err != nil {
+		return 0, err
+	}
+	if !v.BookExists {
+		return 0, fmt.Errorf("book not found")
+	}
+	if !v.MemberExists {
+		return 0, fmt.Errorf("member not found")
+	}
+	if totalPages := (v.BookContentLength + PageSize - 1) / PageSize; page < 1 || page > totalPages {
+		return 0, ErrPageOutOfRange
+	}
+	res, err := d.db.Exec(`INSERT INTO annotations(member_id, book_id, page, kind, text) VALUES(?,?,?,?,?)`,
+		memberID, bookID, page, kind, text)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+const annotationColumns = `a.id, a.member_id, a.book_id, b.title, a.page, a.kind, a.text, a.created_time`
+
+// GetAnnotations lists memberID's annotations on bookID in page order.
+func (d *Database) GetAnnotations(memberID, bookID int64) ([]*Annotation, error) {
+	rows, err := d.db.Query(`SELECT `+annotationColumns+`, ''
+                             FROM annotations a JOIN books b ON b.id = a.book_id
+                             WHERE a.member_id=? AND a.book_id=? ORDER BY a.page, a.id`, memberID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	return scanAnnotations(rows)
+}
+
+// SearchAnnotations runs a search query (see ParseSearchQuery) over
+// memberID's own notes and highlights in every book, best matches first.
+func (d *Database) SearchAnnotations(memberID int64, q string) ([]*Annotation, error) {
+	match, err := ParseSearchQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.db.Query(`SELECT `+annotationColumns+`, snippet(annotations_fts, 0, '[', ']', '…', 12)
+                             FROM annotations_fts
+                             JOIN annotations a ON a.id = annotations_fts.rowid
+                             JOIN books b ON b.id = a.book_id
+                             WHERE annotations_fts MATCH ? AND a.member_id = ?
+                             ORDER BY rank`, match, memberID)
+	if err != nil {
+		return nil, err
+	}
+	return scanAnnotations(rows)
+}
+
+func scanAnnotations(rows *sql.Rows) ([]*Annotation, error) {
+	defer rows.Close()
+	var out []*Annotation
+	for rows.Next() {
+		var a Annotation
+		if err := rows.Scan(&a.ID, &a.MemberID, &a.BookID, &a.BookTitle, &a.Page, &a.Kind, &a.Text, &a.CreatedTime, &a.Snippet); err != nil {
+			return nil, err
+		}
+		out = append(out, &a)
+	}
+	return out, rows.Err()
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) AddAnnotation(memberID, bookID int64, page int, kind, text string) (int64, error) {
+	return lm.db.AddAnnotation(memberID, bookID, page, kind, text)
+}
+
+func (lm *LibraryManager) GetAnnotations(memberID, bookID int64) ([]*Annotation, error) {
+	return lm.db.GetAnnotations(memberID, bookID)
+}
+
+func (lm *LibraryManager) SearchAnnotations(memberID int64, q string) ([]*Annotation, error) {
+	return lm.db.SearchAnnotations(memberID, q)
+}
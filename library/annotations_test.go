@@ -0,0 +1,49 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSearchAnnotations(t *testing.T) {
+	db := tempDB(t)
+	long := strings.Repeat("Call me Ishmael. ", 200)
+	moby, _ := db.AddBook("Moby Dick", "Herman Melville", long)
+	emma, _ := db.AddBook("Emma", "Jane Austen", "Emma Woodhouse, handsome, clever, and rich.")
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+
+	db.AddAnnotation(alice, moby, 2, AnnotationNote, "The whale as an obsession")
+	db.AddAnnotation(alice, emma, 1, AnnotationHighlight, "handsome, clever, and rich")
+	db.AddAnnotation(bob, moby, 1, AnnotationNote, "whale again")
+	if _, err := db.AddAnnotation(alice, moby, 99, AnnotationNote, "too far"); err != ErrPageOutOfRange {
+		t.Fatalf("expected page out of range, got %v", err)
+	}
+	if _, err := db.AddAnnotation(alice, moby, 1, AnnotationNote, "  "); err == nil {
+		t.Fatalf("expected empty note to be refused")
+	}
+
+	found, err := db.SearchAnnotations(alice, "whale")
+	if err != nil || len(found) != 1 {
+		t.Fatalf("search: %v %+v", err, found)
+	}
+	if a := found[0]; a.BookTitle != "Moby Dick" || a.Page != 2 || a.Kind != AnnotationNote || !strings.Contains(a.Snippet, "[whale]") {
+		t.Fatalf("unexpected match: %+v", a)
+	}
+	if found, _ := db.SearchAnnotations(alice, `"clever and rich"`); len(found) != 1 || found[0].BookID != emma {
+		t.Fatalf("phrase search: %+v", found)
+	}
+	if _, err := db.SearchAnnotations(alice, "whale OR"); err == nil {
+		t.Fatalf("expected syntax error")
+	}
+
+	// Erasing a member takes their annotations, and the index entries, with them
+	if _, err := db.ForgetMember(bob, 0); err != nil {
+		t.Fatalf("forget: %v", err)
+	}
+	var n int
+	db.db.QueryRow(`SELECT COUNT(*) FROM annotations_fts WHERE annotations_fts MATCH 'again'`).Scan(&n)
+	if n != 0 {
+		t.Fatalf("index still has the erased member's note")
+	}
+}
@@ -0,0 +1,228 @@
+package library
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Audit log actions.
+const (
+	AuditForcePasswordReset = "force_password_reset"
+	AuditForgetMember       = "forget_member"
+	AuditDelegatePickup     = "delegate_pickup"
+	AuditDelegateReturn     = "delegate_return"
+	AuditDeactivateMember   = "deactivate_member"
+	AuditReactivateMember   = "reactivate_member"
+	AuditDeleteMember       = "delete_member"
+	AuditFulfillHold        = "fulfill_hold"
+	AuditAddBook            = "add_book"
+	AuditCheckout           = "checkout"
+	AuditReturn             = "return"
+	AuditPasswordReset      = "password_reset"
+)
+
+// AuditEntry is one row of the audit log. ActorID is 0 for actions taken by
+// the system, or at the desk without signing in, rather than by a member.
+// MemberID and BookID name the member and book the action was about, 0 when
+// there is none.
+//
+// Entries form a hash chain: Hash covers the entry's fields and PrevHash,
+// the hash of the entry before it, so altering, removing or reordering any
+// entry breaks every link after it. The table itself rejects updates and
+// deletes.
+type AuditEntry struct {
+	ID          int64     `json:"id"`
+	ActorID     int64     `json:"actor_id"`
+	MemberID    int64     `json:"member_id"`
+	BookID      int64     `json:"book_id"`
+	Action      string    `json:"action"`
+	Detail      string    `json:"detail"`
+	CreatedTime time.Time `json:"created_time"`
+	PrevHash    string    `json:"prev_hash"`
+	Hash        string    `json:"hash"`
+}
+
+// auditHash chains one entry onto prevHash. created is the timestamp exactly
+// as stored.
+func auditHash(prevHash string, actorID, memberID, bookID int64, action, detail, created string) string {
+	// JSON keeps the fields unambiguous whatever they contain. Entries
+	// without a member or book hash as they did before those were recorded,
+	// so older chains still verify.
+	fields := []interface{}{prevHash, actorID, action, detail, created}
+	if memberID != 0 || bookID != 0 {
+		fields = append(fields, memberID, bookID)
+	}
+	b, _ := json.Marshal(fields)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// recordAudit appends an entry to the audit log within tx, so it is written
+// if and only if the audited change is. Writers are serialized by SQLite, so
+// the entry read as the chain's tail is still the tail when this one lands.
+func recordAudit(tx *sql.Tx, actorID int64, action, detail string) error {
+	return recordAuditOf(tx, actorID, action, 0, 0, detail)
+}
+
+// recordAuditOf is recordAudit for an action about memberID and bookID, so
+// the entry turns up when the log is filtered by either.
+func recordAuditOf(tx *sql.Tx, actorID int64, action string, memberID, bookID int64, detail string) error {
+	var prev string
+	err := tx.QueryRow(`SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&prev)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	created := time.Now().UTC().Format(sqlTimeLayout)
+	_, err = tx.Exec(`INSERT INTO audit_log(actor_id, member_id, book_id, action, detail, created_time, prev_hash, hash)
+                      VALUES(NULLIF(?, 0),NULLIF(?, 0),NULLIF(?, 0),?,?,?,?,?)`,
+		actorID, memberID, bookID, action, detail, created, prev, auditHash(prev, actorID, memberID, bookID, action, detail, created))
+	return err
+}
+
+// chainAuditLog fills in the hashes of entries written before the log was
+// chained.
+func chainAuditLog(db *sql.DB) error {
+	rows, err := db.Query(`SELECT id, COALESCE(actor_id, 0), action, detail, CAST(created_time AS TEXT) FROM audit_log ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	type link struct {
+		id         int64
+		prev, hash string
+	}
+	var links []link
+	prev := ""
+	for rows.Next() {
+		var id, actor int64
+		var action, detail, created string
+		if err := rows.Scan(&id, &actor, &action, &detail, &created); err != nil {
+			rows.Close()
+			return err
+		}
+		h := auditHash(prev, actor, 0, 0, action, detail, created)
+		links = append(links, link{id, prev, h})
+		prev = h
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, l := range links {
+		if _, err := db.Exec(`UPDATE audit_log SET prev_hash=?, hash=? WHERE id=?`, l.prev, l.hash, l.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyAuditLog walks the hash chain from the first entry and returns how
+// many entries it checked. It fails at the first entry whose contents no
+// longer match its hash or whose link to the previous entry is broken.
+// Truncating the newest entries leaves a valid chain, so compare the count
+// with an earlier run (or a backup) to catch that.
+func (d *Database) VerifyAuditLog() (int, error) {
+	rows, err := d.db.Query(`SELECT id, COALESCE(actor_id, 0), COALESCE(member_id, 0), COALESCE(book_id, 0),
+                                    action, detail, CAST(created_time AS TEXT), prev_hash, hash
+                             FROM audit_log ORDER BY id`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	n := 0
+	prev := ""
+	for rows.Next() {
+		var id, actor, member, book int64
+		var action, detail, created, prevHash, hash string
+		if err := rows.Scan(&id, &actor, &member, &book, &action, &detail, &created, &prevHash, &hash); err != nil {
+			return n, err
+		}
+		if prevHash != prev {
+			return n, fmt.Errorf("audit entry %d does not follow the entry before it: entries were removed or reordered", id)
+		}
+		if auditHash(prev, actor, member, book, action, detail, created) != hash {
+			return n, fmt.Errorf("audit entry %d has been altered", id)
+		}
+		prev = hash
+		n++
+	}
+	return n, rows.Err()
+}
+
+// AuditFilter narrows a search of the audit log. Zero fields don't filter.
+type AuditFilter struct {
+	MemberID int64     // entries by or about this member
+	BookID   int64     // entries about this book
+	Since    time.Time // entries at or after this time
+	Until    time.Time // entries before this time
+	Limit    int       // at most this many, the newest; 0 for all
+}
+
+// GetAuditLog returns the most recent audit entries, newest first.
+func (d *Database) GetAuditLog(limit int) ([]*AuditEntry, error) {
+	return d.SearchAuditLog(AuditFilter{Limit: limit})
+}
+
+// SearchAuditLog returns the audit entries matching f, newest first.
+func (d *Database) SearchAuditLog(f AuditFilter) ([]*AuditEntry, error) {
+	var where []string
+	var args []interface{}
+	if f.MemberID != 0 {
+		where = append(where, "(actor_id=? OR member_id=?)")
+		args = append(args, f.MemberID, f.MemberID)
+	}
+	if f.BookID != 0 {
+		where = append(where, "book_id=?")
+		args = append(args, f.BookID)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "created_time >= ?")
+		args = append(args, f.Since.UTC().Format(sqlTimeLayout))
+	}
+	if !f.Until.IsZero() {
+		where = append(where, "created_time < ?")
+		args = append(args, f.Until.UTC().Format(sqlTimeLayout))
+	}
+	query := `SELECT id, COALESCE(actor_id, 0), COALESCE(member_id, 0), COALESCE(book_id, 0),
+                     action, detail, created_time, prev_hash, hash
+              FROM audit_log`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []*AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.MemberID, &e.BookID, &e.Action, &e.Detail, &e.CreatedTime,
+			&e.PrevHash, &e.Hash); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &e)
+	}
+	return entries, rows.Err()
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) GetAuditLog(limit int) ([]*AuditEntry, error) {
+	return lm.db.GetAuditLog(limit)
+}
+
+func (lm *LibraryManager) SearchAuditLog(f AuditFilter) ([]*AuditEntry, error) {
+	return lm.db.SearchAuditLog(f)
+}
+
+func (lm *LibraryManager) VerifyAuditLog() (int, error) { return lm.db.VerifyAuditLog() }
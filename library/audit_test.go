@@ -0,0 +1,189 @@
+package library
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLogChain(t *testing.T) {
+	db := tempDB(t)
+	staff, _ := db.AddMember("Staff", "password1")
+	for i := 0; i < 3; i++ {
+		if _, err := db.ForcePasswordReset(staff, nil); err != nil {
+			t.Fatalf("force reset: %v", err)
+		}
+	}
+	if n, err := db.VerifyAuditLog(); err != nil || n != 3 {
+		t.Fatalf("verify: n=%d err=%v", n, err)
+	}
+
+	if _, err := db.db.Exec(`UPDATE audit_log SET detail='nothing happened' WHERE id=2`); err == nil {
+		t.Fatalf("expected the audit log to reject updates")
+	}
+	if _, err := db.db.Exec(`DELETE FROM audit_log WHERE id=2`); err == nil {
+		t.Fatalf("expected the audit log to reject deletes")
+	}
+
+	// Someone with the database file can drop the guards, but not forge the chain
+	db.db.Exec(`DROP TRIGGER audit_log_no_update`)
+	db.db.Exec(`UPDATE audit_log SET detail='nothing happened' WHERE id=2`)
+	if _, err := db.VerifyAuditLog(); err == nil || !strings.Contains(err.Error(), "entry 2 has been altered") {
+		t.Fatalf("expected tampering to be detected, got %v", err)
+	}
+}
+
+func TestAuditLogDetectsRemovedEntry(t *testing.T) {
+	db := tempDB(t)
+	staff, _ := db.AddMember("Staff", "password1")
+	for i := 0; i < 3; i++ {
+		db.ForcePasswordReset(staff, nil)
+	}
+	db.db.Exec(`DROP TRIGGER audit_log_no_delete`)
+	db.db.Exec(`DELETE FROM audit_log WHERE id=2`)
+	if _, err := db.VerifyAuditLog(); err == nil || !strings.Contains(err.Error(), "entry 3") {
+		t.Fatalf("expected the gap to be detected, got %v", err)
+	}
+}
+
+func TestSearchAuditLog(t *testing.T) {
+	db := tempDB(t)
+	staff, _ := db.AddMember("Staff", "password1")
+	alice, _ := db.AddMember("Alice", "password1")
+	dune, _ := db.AddBook("Dune", "Herbert", "content")
+	emma, _ := db.AddBook("Emma", "Austen", "content")
+	if err := db.CheckoutBook(dune, alice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ReturnBook(dune); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.ResetMemberPasswordAs(alice, "password2", staff); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CheckoutBook(emma, staff); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := db.VerifyAuditLog(); err != nil || n != 6 {
+		t.Fatalf("verify: n=%d err=%v", n, err)
+	}
+
+	actions := func(entries []*AuditEntry) string {
+		var s []string
+		for _, e := range entries {
+			s = append(s, e.Action)
+		}
+		return strings.Join(s, ",")
+	}
+	entries, err := db.SearchAuditLog(AuditFilter{MemberID: alice})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := actions(entries); got != "password_reset,return,checkout" || entries[0].ActorID != staff {
+		t.Fatalf("Alice's entries = %s", got)
+	}
+	// Staff show up for what they did as well as what was done to them
+	entries, _ = db.SearchAuditLog(AuditFilter{MemberID: staff})
+	if got := actions(entries); got != "checkout,password_reset" {
+		t.Fatalf("staff entries = %s", got)
+	}
+	entries, _ = db.SearchAuditLog(AuditFilter{BookID: dune})
+	if got := actions(entries); got != "return,checkout,add_book" {
+		t.Fatalf("Dune's entries = %s", got)
+	}
+	entries, _ = db.SearchAuditLog(AuditFilter{BookID: dune, MemberID: alice, Limit: 1})
+	if got := actions(entries); got != "return" {
+		t.Fatalf("limited entries = %s", got)
+	}
+
+	now := time.Now()
+	if entries, _ = db.SearchAuditLog(AuditFilter{Since: now.Add(-time.Hour), Until: now.Add(time.Hour)}); len(entries) != 6 {
+		t.Fatalf("entries within the hour = %d", len(entries))
+	}
+	if entries, _ = db.SearchAuditLog(AuditFilter{Until: now.Add(-time.Hour)}); len(entries) != 0 {
+		t.Fatalf("entries before the hour = %d", len(entries))
+	}
+}
+
+// Reserving a book that is on the shelf checks it out, and is audited as a
+// checkout like one made at the desk.
+func TestReserveAvailableBookIsAudited(t *testing.T) {
+	db := tempDB(t)
+	alice, _ := db.AddMember("Alice", "password1")
+	dune, _ := db.AddBook("Dune", "Herbert", "content")
+	if err := db.ReserveBook(dune, alice); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := db.SearchAuditLog(AuditFilter{BookID: dune})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Action != AuditCheckout || entries[0].ActorID != alice {
+		t.Fatalf("Dune's entries = %+v", entries)
+	}
+	if _, err := db.VerifyAuditLog(); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+}
+
+// Books and loans that arrive other than through AddBook and CheckoutBook,
+// as equipment or by import, are audited all the same.
+func TestItemsAndImportsAreAudited(t *testing.T) {
+	actions := func(db *Database, bookID int64) []string {
+		t.Helper()
+		entries, err := db.SearchAuditLog(AuditFilter{BookID: bookID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Action)
+		}
+		return got
+	}
+
+	db := tempDB(t)
+	laptop, err := db.AddItem("Chromebook #3", "Acme", "laptop", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := actions(db, laptop); !slices.Equal(got, []string{AuditAddBook}) {
+		t.Fatalf("laptop's entries = %v", got)
+	}
+
+	var data LibraryData
+	if err := json.Unmarshal([]byte(legacyJSON), &data); err != nil {
+		t.Fatal(err)
+	}
+	db = tempDB(t)
+	if _, err := db.ImportLegacyData(&data); err != nil {
+		t.Fatal(err)
+	}
+	if got := actions(db, 1); !slices.Equal(got, []string{AuditCheckout, AuditAddBook}) {
+		t.Fatalf("Dune's entries = %v", got)
+	}
+	if got := actions(db, 4); !slices.Equal(got, []string{AuditAddBook}) {
+		t.Fatalf("Ulysses' entries = %v", got)
+	}
+	if _, err := db.VerifyAuditLog(); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	dune, _ := db.AddBook("Dune", "Frank Herbert", "")
+	carol, err := db.AddMember("Carol", "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := &CirculationState{Format: circulationFormat, Checkouts: []*ExportedCheckout{{
+		BookTitle: "Dune", BookAuthor: "Frank Herbert", MemberID: carol, MemberName: "Carol",
+		Status: LoanActive, CheckoutTime: time.Now(),
+	}}}
+	if err := db.ImportCirculation(state); err != nil {
+		t.Fatal(err)
+	}
+	if got := actions(db, dune); !slices.Equal(got, []string{AuditCheckout, AuditAddBook}) {
+		t.Fatalf("imported loan's entries = %v", got)
+	}
+}
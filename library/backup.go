@@ -0,0 +1,301 @@
+package library
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Snapshot files are named library-<local time>.db, each with a
+// sha256sum-compatible <name>.sha256 beside it.
+const (
+	snapshotPrefix     = "library-"
+	snapshotTimeLayout = "20060102-150405"
+	snapshotExt        = ".db"
+	checksumExt        = ".sha256"
+)
+
+// BackupSchedule configures the nightly snapshot job.
+type BackupSchedule struct {
+	Dir        string // where snapshots are written
+	Hour       int    // local hour of day after which the nightly snapshot is taken
+	KeepDaily  int    // newest snapshot of each of the last KeepDaily days
+	KeepWeekly int    // newest snapshot of each of the last KeepWeekly ISO weeks
+}
+
+// Snapshot is a backup file found in a backup directory.
+type Snapshot struct {
+	Path string
+	Time time.Time
+}
+
+// BackupVerification reports the checks VerifyBackup ran on a snapshot.
+type BackupVerification struct {
+	Checksum string
+	Books    int
+	Members  int
+}
+
+// SetBackupSchedule turns on nightly snapshots, or turns them off when s
+// is nil.
+func (d *Database) SetBackupSchedule(s *BackupSchedule) error {
+	if s == nil {
+		_, err := d.db.Exec(`DELETE FROM backup_schedule`)
+		return err
+	}
+	if s.Dir == "" {
+		return fmt.Errorf("backup directory is required")
+	}
+	if s.Hour < 0 || s.Hour > 23 {
+		return fmt.Errorf("backup hour must be between 0 and 23")
+	}
+	if s.KeepDaily < 1 || s.KeepWeekly < 0 {
+		return fmt.Errorf("keep at least one daily snapshot")
+	}
+	_, err := d.db.Exec(`INSERT OR REPLACE INTO backup_schedule(id, dir, hour, keep_daily, keep_weekly) VALUES(1,?,?,?,?)`,
+		s.Dir, s.Hour, s.KeepDaily, s.KeepWeekly)
+	return err
+}
+
+// GetBackupSchedule returns the snapshot schedule, or nil if backups are off.
+func (d *Database) GetBackupSchedule() (*BackupSchedule, error) {
+	var s BackupSchedule
+	err := d.db.QueryRow(`SELECT dir, hour, keep_daily, keep_weekly FROM backup_schedule WHERE id=1`).
+		Scan(&s.Dir, &s.Hour, &s.KeepDaily, &s.KeepWeekly)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// CreateSnapshot writes a consistent copy of the database into dir, named
+// for now, along with its SHA-256 checksum file.
+func (d *Database) CreateSnapshot(dir string, now time.Time) (*Snapshot, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create backup dir: %w", err)
+	}
+	name := snapshotPrefix + now.Format(snapshotTimeLayout) + snapshotExt
+	path := filepath.Join(dir, name)
+	partial := path + ".partial"
+	os.Remove(partial)
+
+	if _, err := d.db.Exec(`VACUUM INTO ?`, partial); err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+	sum, err := fileChecksum(partial)
+	if err != nil {
+		os.Remove(partial)
+		return nil, err
+	}
+	if err := os.WriteFile(path+checksumExt, []byte(sum+"  "+name+"\n"), 0o644); err != nil {
+		os.Remove(partial)
+		return nil, err
+	}
+	if err := os.Rename(partial, path); err != nil {
+		return nil, err
+	}
+	return &Snapshot{Path: path, Time: now}, nil
+}
+
+// ListSnapshots returns the snapshots in dir, newest first.
+func ListSnapshots(dir string) ([]*Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var snaps []*Snapshot
+	for _, e := range entries {
+		stamp, ok := strings.CutPrefix(e.Name(), snapshotPrefix)
+		if !ok || !strings.HasSuffix(stamp, snapshotExt) {
+			continue
+		}
+		t, err := time.ParseInLocation(snapshotTimeLayout, strings.TrimSuffix(stamp, snapshotExt), time.Local)
+		if err != nil {
+			continue
+		}
+		snaps = append(snaps, &Snapshot{Path: filepath.Join(dir, e.Name()), Time: t})
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Time.After(snaps[j].Time) })
+	return snaps, nil
+}
+
+// PruneSnapshots deletes the snapshots in dir that fall outside the
+// retention policy: the newest snapshot of each of the last keepDaily days
+// and of each of the last keepWeekly ISO weeks are kept. It returns the
+// paths it removed.
+func PruneSnapshots(dir string, keepDaily, keepWeekly int) ([]string, error) {
+	snaps, err := ListSnapshots(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	var removed []string
+	for _, s := range snaps {
+		keep := false
+		if day := s.Time.Format("2006-01-02"); !days[day] && len(days) < keepDaily {
+			days[day] = true
+			keep = true
+		}
+		year, week := s.Time.ISOWeek()
+		if w := fmt.Sprintf("%d-W%02d", year, week); !weeks[w] && len(weeks) < keepWeekly {
+			weeks[w] = true
+			keep = true
+		}
+		if keep {
+			continue
+		}
+		if err := os.Remove(s.Path); err != nil {
+			return removed, err
+		}
+		os.Remove(s.Path + checksumExt)
+		removed = append(removed, s.Path)
+	}
+	return removed, nil
+}
+
+// VerifyBackup checks a snapshot against its checksum file, restores it
+// into a temporary database (bringing it up to the current schema) and runs
+// SQLite's integrity, foreign key and full-text index checks on the result.
+func VerifyBackup(path string) (*BackupVerification, error) {
+	raw, err := os.ReadFile(path + checksumExt)
+	if err != nil {
+		return nil, fmt.Errorf("read checksum: %w", err)
+	}
+	want, _, _ := strings.Cut(strings.TrimSpace(string(raw)), " ")
+	got, err := fileChecksum(path)
+	if err != nil {
+		return nil, err
+	}
+	if got != want {
+		return nil, fmt.Errorf("checksum mismatch: file is %s, expected %s", got, want)
+	}
+
+	tmp, err := os.MkdirTemp("", "library-verify-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	restored := filepath.Join(tmp, "restore.db")
+	if err := copyFile(path, restored); err != nil {
+		return nil, fmt.Errorf("restore: %w", err)
+	}
+	d, err := NewDatabase(restored)
+	if err != nil {
+		return nil, fmt.Errorf("restore: %w", err)
+	}
+	defer d.Close()
+
+	var result string
+	if err := d.db.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		return nil, err
+	}
+	if result != "ok" {
+		return nil, fmt.Errorf("integrity check failed: %s", result)
+	}
+	rows, err := d.db.Query(`PRAGMA foreign_key_check`)
+	if err != nil {
+		return nil, err
+	}
+	broken := rows.Next()
+	rows.Close()
+	if broken {
+		return nil, fmt.Errorf("foreign key check failed")
+	}
+	if _, err := d.db.Exec(`INSERT INTO books_fts(books_fts) VALUES('integrity-check')`); err != nil {
+		return nil, fmt.Errorf("search index check failed: %w", err)
+	}
+
+	v := &BackupVerification{Checksum: got}
+	if err := d.db.QueryRow(`SELECT (SELECT COUNT(*) FROM books), (SELECT COUNT(*) FROM members)`).Scan(&v.Books, &v.Members); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) SetBackupSchedule(s *BackupSchedule) error {
+	return lm.db.SetBackupSchedule(s)
+}
+
+func (lm *LibraryManager) GetBackupSchedule() (*BackupSchedule, error) {
+	return lm.db.GetBackupSchedule()
+}
+
+func (lm *LibraryManager) CreateSnapshot(dir string, now time.Time) (*Snapshot, error) {
+	return lm.db.CreateSnapshot(dir, now)
+}
+
+// BackupJob takes the nightly snapshot and prunes old ones according to the
+// stored BackupSchedule. It does nothing while backups are off, before the
+// scheduled hour, or once today's snapshot exists.
+func (lm *LibraryManager) BackupJob(interval time.Duration) *Job {
+	return &Job{
+		Name:     "nightly-backup",
+		Interval: interval,
+		Run: func() error {
+			s, err := lm.GetBackupSchedule()
+			if err != nil || s == nil {
+				return err
+			}
+			now := time.Now()
+			if now.Hour() < s.Hour {
+				return nil
+			}
+			snaps, err := ListSnapshots(s.Dir)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if len(snaps) > 0 && snaps[0].Time.Format("2006-01-02") == now.Format("2006-01-02") {
+				return nil
+			}
+			if _, err := lm.CreateSnapshot(s.Dir, now); err != nil {
+				return err
+			}
+			_, err = PruneSnapshots(s.Dir, s.KeepDaily, s.KeepWeekly)
+			return err
+		},
+	}
+}
@@ -0,0 +1,109 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotAndVerify(t *testing.T) {
+	db := tempDB(t)
+	db.AddBook("Dune", "Frank Herbert", "Arrakis")
+	db.AddMember("Alice", "password")
+	dir := t.TempDir()
+
+	snap, err := db.CreateSnapshot(dir, time.Now())
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	v, err := VerifyBackup(snap.Path)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if v.Books != 1 || v.Members != 1 {
+		t.Fatalf("restored snapshot has %d books and %d members", v.Books, v.Members)
+	}
+	sumFile, _ := os.ReadFile(snap.Path + checksumExt)
+	if !strings.HasPrefix(string(sumFile), v.Checksum+"  library-") {
+		t.Fatalf("checksum file should be sha256sum compatible: %q", sumFile)
+	}
+
+	// Any change to the file is caught before it is restored
+	f, _ := os.OpenFile(snap.Path, os.O_WRONLY|os.O_APPEND, 0)
+	f.Write([]byte{0})
+	f.Close()
+	if _, err := VerifyBackup(snap.Path); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	// Two snapshots a day for 60 days, ending on a Sunday
+	end := time.Date(2026, 3, 1, 23, 0, 0, 0, time.Local)
+	for day := 0; day < 60; day++ {
+		for _, hour := range []int{2, 14} {
+			ts := end.AddDate(0, 0, -day).Add(time.Duration(hour-23) * time.Hour)
+			name := snapshotPrefix + ts.Format(snapshotTimeLayout) + snapshotExt
+			os.WriteFile(filepath.Join(dir, name), nil, 0o644)
+			os.WriteFile(filepath.Join(dir, name+checksumExt), nil, 0o644)
+		}
+	}
+
+	if _, err := PruneSnapshots(dir, 7, 4); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	snaps, _ := ListSnapshots(dir)
+	// 7 daily (covering this week and last Sunday's week) plus 3 older weeks
+	if len(snaps) != 10 {
+		t.Fatalf("expected 10 snapshots to survive, got %d", len(snaps))
+	}
+	for i, s := range snaps[:7] {
+		if want := end.AddDate(0, 0, -i).Format("2006-01-02"); s.Time.Format("2006-01-02") != want || s.Time.Hour() != 14 {
+			t.Fatalf("daily snapshot %d is %v, want the latest of %s", i, s.Time, want)
+		}
+	}
+	for _, s := range snaps[7:] {
+		if s.Time.Weekday() != time.Sunday {
+			t.Fatalf("weekly snapshot %v should be the last of its week", s.Time)
+		}
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 2*len(snaps) {
+		t.Fatalf("checksum files should be pruned with their snapshots, %d files left", len(files))
+	}
+}
+
+func TestBackupJob(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	job := lm.BackupJob(time.Hour)
+	if err := job.Run(); err != nil {
+		t.Fatalf("job without a schedule: %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "backups")
+	if err := lm.SetBackupSchedule(&BackupSchedule{Dir: dir, Hour: 0, KeepDaily: 7, KeepWeekly: 4}); err != nil {
+		t.Fatalf("set schedule: %v", err)
+	}
+	if s, _ := lm.GetBackupSchedule(); s == nil || s.Dir != dir {
+		t.Fatalf("schedule not stored: %+v", s)
+	}
+	for i := 0; i < 2; i++ {
+		if err := job.Run(); err != nil {
+			t.Fatalf("job: %v", err)
+		}
+	}
+	if snaps, _ := ListSnapshots(dir); len(snaps) != 1 {
+		t.Fatalf("expected one snapshot per night, got %d", len(snaps))
+	}
+
+	if err := lm.SetBackupSchedule(&BackupSchedule{Dir: dir, Hour: 24, KeepDaily: 7}); err == nil {
+		t.Fatalf("expected invalid hour to be rejected")
+	}
+	lm.SetBackupSchedule(nil)
+	if s, _ := lm.GetBackupSchedule(); s != nil {
+		t.Fatalf("schedule should be cleared")
+	}
+}
@@ -0,0 +1,308 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxBatchItems caps the items in one batch, so a single request can't
+// hold the database for long.
+const MaxBatchItems = 1000
+
+// Batch item outcomes.
+const (
+	BatchCreated   = "created"
+	BatchUpdated   = "updated"
+	BatchApplied   = "applied"   // a circulation event took effect
+	BatchDuplicate = "duplicate" // already in the catalog; ID is the existing record
+	BatchFailed    = "failed"
+)
+
+// BatchResult is the outcome of one item of a batch. Each item is applied in
+// its own transaction, so one that fails leaves the others in place.
+//
+// An item with a Key is applied once: the key is stored with the outcome,
+// and sending the item again, as a client does when it retries a batch that
+// timed out, returns that outcome with Replayed set instead of applying it a
+// second time. Failed items don't store their key and can be retried. Keys
+// are scoped to the kind of batch.
+type BatchResult struct {
+	Index    int    `json:"index"`
+	Key      string `json:"key,omitempty"`
+	Status   string `json:"status"`
+	ID       int64  `json:"id,omitempty"`
+	Replayed bool   `json:"replayed,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// BatchBook is a book to add in a batch.
+type BatchBook struct {
+	Key     string `json:"key,omitempty"`
+	Title   string `json:"title"`
+	Author  string `json:"author"`
+	Content string `json:"content,omitempty"`
+	BookMetadata
+}
+
+// BatchMember is a member to add or update in a batch: the member with ID
+// if given, otherwise the one with Name, who is added if there is none.
+// Empty fields are left as they are, or take their defaults for a new
+// member. New members have no password until staff set one.
+type BatchMember struct {
+	Key        string `json:"key,omitempty"`
+	ID         int64  `json:"id,omitempty"`
+	Name       string `json:"name"`
+	Tier       string `json:"tier,omitempty"`
+	ExpiryDate string `json:"expiry_date,omitempty"` // YYYY-MM-DD
+}
+
+// Circulation event types.
+const (
+	CirculationCheckout = "checkout"
+	CirculationReturn   = "return"
+)
+
+// CirculationEvent is a checkout or return recorded elsewhere, such as a
+// self-service kiosk, to replay here. A return's MemberID, if given, must be
+// the borrower.
+type CirculationEvent struct {
+	Key      string `json:"key,omitempty"`
+	Type     string `json:"type"`
+	BookID   int64  `json:"book_id"`
+	MemberID int64  `json:"member_id,omitempty"`
+}
+
+// applyBatchItem applies one item of a batch of kind in a transaction of its
+// own, unless key was applied before. apply returns the outcome and the ID
+// of the record concerned.
+func (d *Database) applyBatchItem(kind, key string, apply func(tx *sql.Tx) (string, int64, error)) BatchResult {
+	r := BatchResult{Key: key}
+	err := d.inTx(func(tx *sql.Tx) error {
+		r.Replayed = false
+		if key != "" {
+			var id sql.NullInt64
+			err := tx.QueryRow(`SELECT status, record_id FROM batch_keys WHERE kind=? AND key=?`, kind, key).Scan(&r.Status, &id)
+			if err == nil {
+				r.ID, r.Replayed = id.Int64, true
+				return nil
+			}
+			if err != sql.ErrNoRows {
+				return err
+			}
+		}
+		status, id, err := apply(tx)
+		if err != nil {
+			return err
+		}
+		r.Status, r.ID = status, id
+		if key == "" {
+			return nil
+		}
+		_, err = tx.Exec(`INSERT INTO batch_keys(kind, key, status, record_id) VALUES(?,?,?,NULLIF(?, 0))`, kind, key, status, id)
+		return err
+	})
+	if err != nil {
+		return BatchResult{Key: key, Status: BatchFailed, Error: err.Error()}
+	}
+	return r
+}
+
+// checkBatchSize rejects an empty or oversized batch.
+func checkBatchSize(n int) error {
+	if n == 0 {
+		return fmt.Errorf("the batch is empty")
+	}
+	if n > MaxBatchItems {
+		return fmt.Errorf("a batch holds at most %d items, not %d", MaxBatchItems, n)
+	}
+	return nil
+}
+
+// AddBooks adds a batch of books for staffID, who is audit-logged as adding
+// them. A book whose title and author match one in the catalog is not added
+// again; its result is BatchDuplicate with the existing book's ID.
+func (d *Database) AddBooks(books []BatchBook, staffID int64) ([]BatchResult, error) {
+	if err := checkBatchSize(len(books)); err != nil {
+		return nil, err
+	}
+	results := make([]BatchResult, len(books))
+	for i, b := range books {
+		title, author := strings.TrimSpace(b.Title), strings.TrimSpace(b.Author)
+		meta, err := b.BookMetadata.normalize()
+		switch {
+		case title == "" || author == "":
+			err = fmt.Errorf("title and author are required")
+		case err == nil:
+			results[i] = d.applyBatchItem("books", b.Key, func(tx *sql.Tx) (string, int64, error) {
+				var existing int64
+				err := tx.QueryRow(`SELECT id FROM books WHERE lower(trim(title))=lower(?) AND lower(trim(author))=lower(?)
+				                    ORDER BY id LIMIT 1`, title, author).Scan(&existing)
+				if err == nil {
+					return BatchDuplicate, existing, nil
+				}
+				if err != sql.ErrNoRows {
+					return "", 0, err
+				}
+				id, err := d.addBook(tx, title, author, b.Content, staffID)
+				if err != nil {
+					return "", 0, err
+				}
+				_, err = tx.Exec(`UPDATE books SET isbn=?, publisher=?, publication_year=?, language=?, page_count=? WHERE id=?`,
+					meta.ISBN, meta.Publisher, meta.PublicationYear, meta.Language, meta.PageCount, id)
+				return BatchCreated, id, err
+			})
+			if r := results[i]; r.Status == BatchCreated && !r.Replayed {
+				if err := d.storeNewContent(r.ID, b.Content); err != nil {
+					results[i].Error = fmt.Sprintf("book added, but its content was not stored: %v", err)
+				}
+			}
+		}
+		if err != nil {
+			results[i] = BatchResult{Key: b.Key, Status: BatchFailed, Error: err.Error()}
+		}
+		results[i].Index = i
+	}
+	return results, nil
+}
+
+// UpsertMembers adds or updates a batch of members.
+func (d *Database) UpsertMembers(members []BatchMember) ([]BatchResult, error) {
+	if err := checkBatchSize(len(members)); err != nil {
+		return nil, err
+	}
+	results := make([]BatchResult, len(members))
+	for i, m := range members {
+		name := strings.TrimSpace(m.Name)
+		var expiry *time.Time
+		var err error
+		if m.Tier != "" {
+			_, err = d.GetTierPolicy(m.Tier)
+		}
+		if v := m.ExpiryDate; v != "" && err == nil {
+			t, perr := time.ParseInLocation("2006-01-02", v, d.zone())
+			if perr != nil {
+				err = fmt.Errorf("expiry_date must be YYYY-MM-DD, not %q", v)
+			}
+			expiry = &t
+		}
+		if name == "" && m.ID == 0 && err == nil {
+			err = fmt.Errorf("member name cannot be empty")
+		}
+		if err != nil {
+			results[i] = BatchResult{Key: m.Key, Status: BatchFailed, Error: err.Error(), Index: i}
+			continue
+		}
+
+		results[i] = d.applyBatchItem("members", m.Key, func(tx *sql.Tx) (string, int64, error) {
+			id := m.ID
+			if id == 0 {
+				err := tx.QueryRow(`SELECT id FROM members WHERE name=? AND placeholder=0`, name).Scan(&id)
+				if err == sql.ErrNoRows {
+					return d.insertBatchMember(tx, name, m.Tier, expiry)
+				}
+				if err != nil {
+					return "", 0, err
+				}
+			}
+			res, err := tx.Exec(`UPDATE members SET name=COALESCE(NULLIF(?, ''), name), tier=COALESCE(NULLIF(?, ''), tier),
+			                                        expiry_time=COALESCE(?, expiry_time)
+			                     WHERE id=? AND placeholder=0`, name, m.Tier, sqlTime(expiry), id)
+			if err != nil {
+				if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+					return "", 0, fmt.Errorf("member with name '%s' already exists", name)
+				}
+				return "", 0, err
+			}
+			if n, _ := res.RowsAffected(); n == 0 {
+				return "", 0, fmt.Errorf("member with ID %d not found", id)
+			}
+			return BatchUpdated, id, nil
+		})
+		results[i].Index = i
+	}
+	return results, nil
+}
+
+// insertBatchMember adds a member without a password within tx, as
+// ImportMembersCSV does.
+func (d *Database) insertBatchMember(tx *sql.Tx, name, tier string, expiry *time.Time) (string, int64, error) {
+	if tier == "" {
+		tier = TierAdult
+	}
+	if expiry == nil {
+		t := time.Now().AddDate(0, 0, DefaultMembershipDays)
+		expiry = &t
+	}
+	res, err := tx.Exec(`INSERT INTO members(name, expiry_time, tier) VALUES(?,?,?)`, name, sqlTime(expiry), tier)
+	if err != nil {
+		return "", 0, err
+	}
+	id, err := res.LastInsertId()
+	return BatchCreated, id, err
+}
+
+// ApplyCirculation replays a batch of checkouts and returns in order, with
+// the same checks as at the desk. Each result's ID is the loan's checkout.
+func (d *Database) ApplyCirculation(events []CirculationEvent) ([]BatchResult, error) {
+	if err := checkBatchSize(len(events)); err != nil {
+		return nil, err
+	}
+	results := make([]BatchResult, len(events))
+	for i, e := range events {
+		var apply func(tx *sql.Tx) (string, int64, error)
+		switch e.Type {
+		case CirculationCheckout:
+			apply = func(tx *sql.Tx) (string, int64, error) {
+				if err := d.checkBlockingAlerts(tx, e.MemberID); err != nil {
+					return "", 0, err
+				}
+				if err := d.checkoutBook(tx, e.BookID, e.MemberID); err != nil {
+					return "", 0, err
+				}
+				var checkoutID int64
+				err := tx.QueryRow(`SELECT id FROM checkouts WHERE book_id=? AND member_id=? AND return_time IS NULL`,
+					e.BookID, e.MemberID).Scan(&checkoutID)
+				return BatchApplied, checkoutID, err
+			}
+		case CirculationReturn:
+			apply = func(tx *sql.Tx) (string, int64, error) {
+				var checkoutID, borrowerID int64
+				err := tx.QueryRow(`SELECT id, member_id FROM checkouts WHERE book_id=? AND status<>? AND return_time IS NULL`,
+					e.BookID, LoanAwaitingPickup).Scan(&checkoutID, &borrowerID)
+				if err != nil && err != sql.ErrNoRows {
+					return "", 0, err
+				}
+				if err == nil && e.MemberID != 0 && e.MemberID != borrowerID {
+					return "", 0, fmt.Errorf("book %d is not on loan to member %d", e.BookID, e.MemberID)
+				}
+				if _, _, err := d.returnItemTx(tx, e.BookID, nil, e.MemberID); err != nil {
+					return "", 0, err
+				}
+				return BatchApplied, checkoutID, nil
+			}
+		default:
+			results[i] = BatchResult{Key: e.Key, Status: BatchFailed, Index: i,
+				Error: fmt.Sprintf("unknown event type %q (expected %s or %s)", e.Type, CirculationCheckout, CirculationReturn)}
+			continue
+		}
+		results[i] = d.applyBatchItem("circulation", e.Key, apply)
+		results[i].Index = i
+	}
+	return results, nil
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) AddBooks(books []BatchBook, staffID int64) ([]BatchResult, error) {
+	return lm.db.AddBooks(books, staffID)
+}
+
+func (lm *LibraryManager) UpsertMembers(members []BatchMember) ([]BatchResult, error) {
+	return lm.db.UpsertMembers(members)
+}
+
+func (lm *LibraryManager) ApplyCirculation(events []CirculationEvent) ([]BatchResult, error) {
+	return lm.db.ApplyCirculation(events)
+}
@@ -0,0 +1,38 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+// Checkouts replayed from a batch meet the same checks as at the desk,
+// blocking alerts included.
+func TestApplyCirculationBlockedMember(t *testing.T) {
+	mgr := newManager(t)
+	staff, _ := mgr.AddMember("Librarian", "password")
+	mgr.SetMemberAdmin(staff, true)
+	alice, _ := mgr.AddMember("Alice", "password")
+	dune, _ := mgr.AddBook("Dune", "Herbert")
+	alertID, _ := mgr.AddMemberAlert(alice, staff, "Verify new address", true)
+
+	results, err := mgr.ApplyCirculation([]CirculationEvent{
+		{Key: "c1", Type: CirculationCheckout, BookID: dune, MemberID: alice},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Status != BatchFailed || !strings.Contains(results[0].Error, "blocked") {
+		t.Fatalf("results = %+v", results)
+	}
+	if b, _ := mgr.GetBook(dune); !b.Available {
+		t.Fatalf("a blocked member was lent the book")
+	}
+
+	mgr.ClearAlert(alertID, staff)
+	results, _ = mgr.ApplyCirculation([]CirculationEvent{
+		{Key: "c2", Type: CirculationCheckout, BookID: dune, MemberID: alice},
+	})
+	if results[0].Status != BatchApplied {
+		t.Fatalf("results after clearing the alert = %+v", results)
+	}
+}
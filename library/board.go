@@ -0,0 +1,39 @@
+package library
+
+import "time"
+
+// BoardStats is the desk's at-a-glance summary of circulation.
+type BoardStats struct {
+	TotalBooks     int
+	AvailableBooks int
+	CheckoutsToday int // loans started since local midnight
+	HoldsReady     int // fulfilled holds waiting in the pickup locker
+	Overdue        int
+	UpdatedTime    time.Time
+}
+
+// GetBoardStats counts the figures shown on the availability board as of now.
+func (d *Database) GetBoardStats(now time.Time) (*BoardStats, error) {
+	y, m, day := now.Date()
+	midnight := time.Date(y, m, day, 0, 0, 0, 0, now.Location())
+	s := BoardStats{UpdatedTime: now}
+	err := d.db.QueryRow(`SELECT
+	        (SELECT COUNT(*) FROM books),
+	        (SELECT COUNT(*) FROM books WHERE available=1),
+	        (SELECT COUNT(*) FROM checkouts WHERE checkout_time >= ?),
+	        (SELECT COUNT(*) FROM checkouts WHERE return_time IS NULL AND status=?),
+	        (SELECT COUNT(*) FROM checkouts WHERE return_time IS NULL AND status=? AND due_time <= ?)`,
+		sqlTime(&midnight), LoanAwaitingPickup, LoanActive, sqlTime(&now)).
+		Scan(&s.TotalBooks, &s.AvailableBooks, &s.CheckoutsToday, &s.HoldsReady, &s.Overdue)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ------------------ Manager helpers ------------------
+
+// GetBoardStats returns the availability board figures as of now.
+func (lm *LibraryManager) GetBoardStats(now time.Time) (*BoardStats, error) {
+	return lm.db.GetBoardStats(now)
+}
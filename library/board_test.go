@@ -0,0 +1,37 @@
+package library
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetBoardStats(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	b1, _ := db.AddBook("One", "Author", "")
+	b2, _ := db.AddBook("Two", "Author", "")
+	b3, _ := db.AddBook("Three", "Author", "")
+	db.AddBook("Four", "Author", "")
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+
+	db.CheckoutBook(b1, alice)
+	db.CheckoutBook(b2, alice)
+	db.db.Exec(`UPDATE checkouts SET due_time=datetime('now', '-1 day') WHERE book_id=?`, b2)
+
+	// Bob's hold waits in the locker once Alice returns the third book
+	db.SetLockerPickup(true)
+	db.CheckoutBook(b3, alice)
+	lm.ReserveBook(b3, bob)
+	if _, err := lm.ReturnBook(b3, alice); err != nil {
+		t.Fatalf("return: %v", err)
+	}
+
+	s, err := db.GetBoardStats(time.Now())
+	if err != nil {
+		t.Fatalf("board stats: %v", err)
+	}
+	if s.TotalBooks != 4 || s.AvailableBooks != 1 || s.CheckoutsToday != 4 || s.HoldsReady != 1 || s.Overdue != 1 {
+		t.Fatalf("unexpected board: %+v", s)
+	}
+}
@@ -0,0 +1,100 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Bookmark is a page of a book a member has marked to come back to.
+type Bookmark struct {
+	ID          int64     `json:"id"`
+	MemberID    int64     `json:"member_id"`
+	BookID      int64     `json:"book_id"`
+	Page        int       `json:"page"`            // 1-based, as in the reader
+	Label       string    `json:"label,omitempty"` // the member's own words, if any
+	CreatedTime time.Time `json:"created_time"`
+}
+
+// SaveReadingProgress records page (1-based) as where memberID is in bookID.
+func (d *Database) SaveReadingProgress(memberID, bookID int64, page int) error {
+	_, err := d.db.Exec(`INSERT INTO reading_progress(member_id, book_id, page, updated_time) VALUES(?,?,?,?)
+	                     ON CONFLICT(member_id, book_id) DO UPDATE SET page=excluded.page, updated_time=excluded.updated_time`,
+		memberID, bookID, page, d.sqlNow())
+	return err
+}
+
+// GetReadingProgress returns the page (1-based) where memberID left off in
+// bookID, or 0 if they haven't read it.
+func (d *Database) GetReadingProgress(memberID, bookID int64) (int, error) {
+	var page int
+	err := d.db.QueryRow(`SELECT page FROM reading_progress WHERE member_id=? AND book_id=?`, memberID, bookID).Scan(&page)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return page, err
+}
+
+// AddBookmark bookmarks page (1-based) of bookID for memberID, with an
+// optional label. Bookmarking a page again replaces its label.
+func (d *Database) AddBookmark(memberID, bookID int64, page int, label string) (int64, error) {
+	v, err := d.ValidateReadBookAccess(bookID, memberID)
+	if err != nil {
+		return 0, err
+	}
+	if !v.BookExists {
+		return 0, fmt.Errorf("book not found")
+	}
+	if !v.MemberExists {
+		return 0, fmt.Errorf("member not found")
+	}
+	if totalPages := (v.BookContentLength + PageSize - 1) / PageSize; page < 1 || page > totalPages {
+		return 0, ErrPageOutOfRange
+	}
+	var id int64
+	err = d.db.QueryRow(`INSERT INTO bookmarks(member_id, book_id, page, label) VALUES(?,?,?,?)
+	                     ON CONFLICT(member_id, book_id, page) DO UPDATE SET label=excluded.label
+	                     RETURNING id`, memberID, bookID, page, strings.TrimSpace(label)).Scan(&id)
+	return id, err
+}
+
+// GetBookmarks lists memberID's bookmarks in bookID in page order.
+func (d *Database) GetBookmarks(memberID, bookID int64) ([]*Bookmark, error) {
+	rows, err := d.db.Query(`SELECT id, member_id, book_id, page, label, created_time FROM bookmarks
+	                         WHERE member_id=? AND book_id=? ORDER BY page`, memberID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*Bookmark
+	for rows.Next() {
+		var b Bookmark
+		if err := rows.Scan(&b.ID, &b.MemberID, &b.BookID, &b.Page, &b.Label, &b.CreatedTime); err != nil {
+			return nil, err
+		}
+		out = append(out, &b)
+	}
+	return out, rows.Err()
+}
+
+// DeleteBookmark removes memberID's bookmark on page of bookID, reporting
+// whether there was one.
+func (d *Database) DeleteBookmark(memberID, bookID int64, page int) (bool, error) {
+	res, err := d.db.Exec(`DELETE FROM bookmarks WHERE member_id=? AND book_id=? AND page=?`, memberID, bookID, page)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) GetReadingProgress(memberID, bookID int64) (int, error) {
+	return lm.db.GetReadingProgress(memberID, bookID)
+}
+
+func (lm *LibraryManager) GetBookmarks(memberID, bookID int64) ([]*Bookmark, error) {
+	return lm.db.GetBookmarks(memberID, bookID)
+}
@@ -0,0 +1,55 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadingProgress(t *testing.T) {
+	db := tempDB(t)
+	bookID, _ := db.AddBook("Long Book", "Author", strings.Repeat("x", 3*PageSize))
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+
+	if page, err := db.GetReadingProgress(alice, bookID); err != nil || page != 0 {
+		t.Fatalf("expected no progress yet, got %d %v", page, err)
+	}
+	db.SaveReadingProgress(alice, bookID, 2)
+	db.SaveReadingProgress(alice, bookID, 3)
+	if page, _ := db.GetReadingProgress(alice, bookID); page != 3 {
+		t.Fatalf("expected to resume on page 3, got %d", page)
+	}
+	if page, _ := db.GetReadingProgress(bob, bookID); page != 0 {
+		t.Fatalf("progress leaked to another member: %d", page)
+	}
+}
+
+func TestBookmarks(t *testing.T) {
+	db := tempDB(t)
+	bookID, _ := db.AddBook("Long Book", "Author", strings.Repeat("x", 3*PageSize))
+	alice, _ := db.AddMember("Alice", "password")
+
+	if _, err := db.AddBookmark(alice, bookID, 4, ""); err != ErrPageOutOfRange {
+		t.Fatalf("expected a page past the end to be refused, got %v", err)
+	}
+	db.AddBookmark(alice, bookID, 3, "the duel")
+	db.AddBookmark(alice, bookID, 1, "")
+	if _, err := db.AddBookmark(alice, bookID, 3, "  the real duel "); err != nil {
+		t.Fatalf("re-bookmark: %v", err)
+	}
+
+	marks, err := db.GetBookmarks(alice, bookID)
+	if err != nil || len(marks) != 2 || marks[0].Page != 1 || marks[1].Page != 3 || marks[1].Label != "the real duel" {
+		t.Fatalf("expected bookmarks on pages 1 and 3, relabelled, got %v %+v", err, marks)
+	}
+
+	if removed, _ := db.DeleteBookmark(alice, bookID, 1); !removed {
+		t.Fatalf("expected the bookmark on page 1 removed")
+	}
+	if removed, _ := db.DeleteBookmark(alice, bookID, 2); removed {
+		t.Fatalf("page 2 was never bookmarked")
+	}
+	if marks, _ := db.GetBookmarks(alice, bookID); len(marks) != 1 {
+		t.Fatalf("expected one bookmark left, got %+v", marks)
+	}
+}
@@ -0,0 +1,141 @@
+package library
+
+import (
+	"container/list"
+	"database/sql"
+	"strings"
+	"sync"
+)
+
+// lruCache is a fixed-size, concurrency-safe least-recently-used cache.
+type lruCache[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // front is most recently used
+	items    map[K]*list.Element
+}
+
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+func newLRUCache[K comparable, V any](capacity int) *lruCache[K, V] {
+	return &lruCache[K, V]{capacity: capacity, order: list.New(), items: make(map[K]*list.Element)}
+}
+
+func (c *lruCache[K, V]) get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.order.MoveToFront(el)
+		return el.Value.(*lruEntry[K, V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+func (c *lruCache[K, V]) add(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruEntry[K, V]).value = value
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
+
+// removeFunc drops every entry whose key matches.
+func (c *lruCache[K, V]) removeFunc(match func(K) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, el := range c.items {
+		if match(key) {
+			c.order.Remove(el)
+			delete(c.items, key)
+		}
+	}
+}
+
+// bookMeta is the content-derived information about a book that only
+// changes when its content does.
+type bookMeta struct {
+	title      string
+	author     string
+	length     int
+	hasContent bool
+	chapters   []chapterMark
+}
+
+// chapterMark is a chapter heading and its byte offset in the content.
+type chapterMark struct {
+	offset  int
+	heading string
+}
+
+type chunkKey struct {
+	bookID         int64
+	offset, length int
+	page           int // for GetBookPage, with no offset or length
+}
+
+// EnableCache keeps up to size recently read page chunks, and the metadata
+// of as many books, in memory. Cached entries for a book are dropped
+// whenever its content is updated.
+func (d *Database) EnableCache(size int) {
+	d.metaCache = newLRUCache[int64, *bookMeta](size)
+	d.chunkCache = newLRUCache[chunkKey, string](size)
+}
+
+// invalidateBook drops cached data for bookID.
+func (d *Database) invalidateBook(bookID int64) {
+	if d.metaCache == nil {
+		return
+	}
+	d.metaCache.removeFunc(func(id int64) bool { return id == bookID })
+	d.chunkCache.removeFunc(func(k chunkKey) bool { return k.bookID == bookID })
+}
+
+// getBookMeta returns bookID's content-derived metadata, from the cache
+// when enabled. It returns sql.ErrNoRows for unknown books.
+func (d *Database) getBookMeta(bookID int64) (*bookMeta, error) {
+	if d.metaCache != nil {
+		if m, ok := d.metaCache.get(bookID); ok {
+			return m, nil
+		}
+	}
+
+	var content string
+	var ref sql.NullString
+	m := &bookMeta{}
+	if err := d.db.QueryRow(`SELECT b.title, b.author, `+contentColumns+` FROM books b WHERE b.id=?`, bookID).
+		Scan(&m.title, &m.author, &content, &ref); err != nil {
+		return nil, err
+	}
+	content, err := d.resolveContent(content, ref)
+	if err != nil {
+		return nil, err
+	}
+	m.length = len(content)
+	m.hasContent = len(strings.TrimSpace(content)) > 0
+	for _, loc := range chapterPattern.FindAllStringIndex(content, -1) {
+		m.chapters = append(m.chapters, chapterMark{offset: loc[0], heading: strings.TrimSpace(content[loc[0]:loc[1]])})
+	}
+
+	if d.metaCache != nil {
+		d.metaCache.add(bookID, m)
+	}
+	return m, nil
+}
+
+// ------------------ Manager helpers ------------------
+
+// EnableCache turns on the in-process page and metadata cache; useful in
+// server mode where popular titles are read page by page.
+func (lm *LibraryManager) EnableCache(size int) { lm.db.EnableCache(size) }
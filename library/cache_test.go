@@ -0,0 +1,53 @@
+package library
+
+import "testing"
+
+func TestLRUCacheEviction(t *testing.T) {
+	c := newLRUCache[int, string](2)
+	c.add(1, "one")
+	c.add(2, "two")
+	c.get(1) // 2 is now least recently used
+	c.add(3, "three")
+
+	if _, ok := c.get(2); ok {
+		t.Fatalf("least recently used entry should be evicted")
+	}
+	if v, ok := c.get(1); !ok || v != "one" {
+		t.Fatalf("recently used entry should survive, got %q", v)
+	}
+	c.removeFunc(func(k int) bool { return k == 3 })
+	if _, ok := c.get(3); ok {
+		t.Fatalf("removed entry should be gone")
+	}
+}
+
+func TestContentCacheInvalidation(t *testing.T) {
+	db := tempDB(t)
+	db.EnableCache(16)
+	bookID, _ := db.AddBook("Cached", "Author", "first edition")
+	memberID, _ := db.AddMember("Reader", "password")
+
+	if chunk, _ := db.GetBookContentChunk(bookID, 0, 5); chunk != "first" {
+		t.Fatalf("chunk = %q", chunk)
+	}
+
+	// Writes that bypass UpdateBookContent are not seen while cached
+	db.db.Exec(`UPDATE book_contents SET content='changed' WHERE book_id=?`, bookID)
+	if chunk, _ := db.GetBookContentChunk(bookID, 0, 5); chunk != "first" {
+		t.Fatalf("expected cached chunk, got %q", chunk)
+	}
+
+	if err := db.UpdateBookContent(bookID, "second edition, revised"); err != nil {
+		t.Fatalf("update content: %v", err)
+	}
+	if chunk, _ := db.GetBookContentChunk(bookID, 0, 6); chunk != "second" {
+		t.Fatalf("chunk after update = %q", chunk)
+	}
+	v, err := db.ValidateReadBookAccess(bookID, memberID)
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if v.BookContentLength != len("second edition, revised") {
+		t.Fatalf("cached length not invalidated: %d", v.BookContentLength)
+	}
+}
@@ -0,0 +1,126 @@
+package library
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Capability names. Fines and full-text search are worked out from the
+// database; the rest depend on how the library is run and are registered
+// with SetCapability.
+const (
+	CapFines          = "fines"
+	CapEmail          = "email"
+	CapFullTextSearch = "full_text_search"
+	CapServer         = "server"
+)
+
+// Capability says whether an optional subsystem is available, so clients
+// can leave out what would only fail.
+type Capability struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// capabilityRegistry holds the capabilities registered from outside the
+// database.
+type capabilityRegistry struct {
+	mu   sync.Mutex
+	caps map[string]Capability
+}
+
+// SetCapability records whether the subsystem name is available. It
+// overrides anything worked out from the database.
+func (d *Database) SetCapability(name string, enabled bool, detail string) {
+	r := d.capabilities
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.caps == nil {
+		r.caps = make(map[string]Capability)
+	}
+	r.caps[name] = Capability{Name: name, Enabled: enabled, Detail: detail}
+}
+
+// Capabilities lists every known capability by name. Email and server mode
+// are off unless registered.
+func (d *Database) Capabilities() ([]Capability, error) {
+	fines, err := d.finesCapability()
+	if err != nil {
+		return nil, err
+	}
+	caps := map[string]Capability{
+		CapFines:          fines,
+		CapFullTextSearch: d.fullTextSearchCapability(),
+		CapEmail:          {Name: CapEmail, Detail: "no mail server configured"},
+		CapServer:         {Name: CapServer},
+	}
+
+	d.capabilities.mu.Lock()
+	for name, c := range d.capabilities.caps {
+		caps[name] = c
+	}
+	d.capabilities.mu.Unlock()
+
+	list := make([]Capability, 0, len(caps))
+	for _, c := range caps {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Capability returns the named capability.
+func (d *Database) Capability(name string) (Capability, error) {
+	caps, err := d.Capabilities()
+	if err != nil {
+		return Capability{}, err
+	}
+	for _, c := range caps {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return Capability{}, fmt.Errorf("unknown capability %q", name)
+}
+
+// finesCapability reports fines as enabled when any membership tier or
+// item type charges for overdue loans.
+func (d *Database) finesCapability() (Capability, error) {
+	var maxRate int64
+	err := d.db.QueryRow(`SELECT MAX(COALESCE((SELECT MAX(fine_cents_per_day) FROM membership_tiers), 0),
+	                                 COALESCE((SELECT MAX(fine_cents_per_day) FROM item_types), 0))`).Scan(&maxRate)
+	if err != nil {
+		return Capability{}, fmt.Errorf("check fine rates: %w", err)
+	}
+	if maxRate <= 0 {
+		return Capability{Name: CapFines, Detail: "no tier or item type charges fines"}, nil
+	}
+	return Capability{Name: CapFines, Enabled: true, Detail: fmt.Sprintf("up to %d cents per day", maxRate)}, nil
+}
+
+// fullTextSearchCapability reports whether the FTS5 index can be queried;
+// without it, search falls back to matching titles and authors.
+func (d *Database) fullTextSearchCapability() Capability {
+	rows, err := d.db.Query(`SELECT rowid FROM books_fts LIMIT 0`)
+	if err != nil {
+		return Capability{Name: CapFullTextSearch, Detail: "searching titles and authors only"}
+	}
+	rows.Close()
+	return Capability{Name: CapFullTextSearch, Enabled: true}
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) SetCapability(name string, enabled bool, detail string) {
+	lm.db.SetCapability(name, enabled, detail)
+}
+
+func (lm *LibraryManager) Capabilities() ([]Capability, error) {
+	return lm.db.Capabilities()
+}
+
+func (lm *LibraryManager) Capability(name string) (Capability, error) {
+	return lm.db.Capability(name)
+}
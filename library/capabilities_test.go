@@ -0,0 +1,30 @@
+package library
+
+import "testing"
+
+func TestCapabilities(t *testing.T) {
+	db := tempDB(t)
+
+	c, err := db.Capability(CapFines)
+	if err != nil || !c.Enabled {
+		t.Fatalf("fines with the default tiers: %+v %v", c, err)
+	}
+	if c, _ := db.Capability(CapEmail); c.Enabled {
+		t.Fatalf("email enabled without a mail server")
+	}
+
+	// A library that charges nothing has no fines to show
+	db.db.Exec(`UPDATE membership_tiers SET fine_cents_per_day = 0`)
+	db.db.Exec(`UPDATE item_types SET fine_cents_per_day = NULL`)
+	if c, _ := db.Capability(CapFines); c.Enabled {
+		t.Fatalf("fines enabled with no fine rates: %+v", c)
+	}
+
+	db.SetCapability(CapEmail, true, "via mail.example.org:25")
+	if c, _ := db.Capability(CapEmail); !c.Enabled || c.Detail != "via mail.example.org:25" {
+		t.Fatalf("registered email: %+v", c)
+	}
+	if _, err := db.Capability("teleport"); err == nil {
+		t.Fatalf("expected an error for an unknown capability")
+	}
+}
@@ -0,0 +1,339 @@
+package library
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A catalog can be cleaned up in a spreadsheet: ExportEditableBooks writes
+// the fields staff may change, DiffEditableBooks compares the edited file
+// with the catalog for a preview, and ApplyBookEdits makes the changes.
+
+// editableBookColumns are the columns of an editable export. id identifies
+// the book and is not editable; tags are separated by semicolons.
+var editableBookColumns = []string{"id", "title", "author", "tags",
+	"isbn", "publisher", "publication_year", "language", "page_count"}
+
+// editableBook is the part of a book an editable export can change.
+type editableBook struct {
+	title, author string
+	meta          BookMetadata
+	tags          []string // normalized and sorted
+}
+
+// fields returns b's columns, as written in an editable export.
+func (b *editableBook) fields() map[string]string {
+	return map[string]string{
+		"title": b.title, "author": b.author, "tags": strings.Join(b.tags, "; "),
+		"isbn": b.meta.ISBN, "publisher": b.meta.Publisher, "publication_year": optionalInt(b.meta.PublicationYear),
+		"language": b.meta.Language, "page_count": optionalInt(b.meta.PageCount),
+	}
+}
+
+func (b *editableBook) equal(o *editableBook) bool {
+	return b.title == o.title && b.author == o.author && b.meta == o.meta && slices.Equal(b.tags, o.tags)
+}
+
+// BookEdit is the change an edited export makes to one book.
+type BookEdit struct {
+	BookID  int64
+	Line    int // of the edited file
+	Title   string
+	Changes []FieldChange
+
+	before, after *editableBook
+}
+
+// FieldChange is one column of a BookEdit, as text.
+type FieldChange struct {
+	Field    string
+	Old, New string
+}
+
+// CatalogEdits is the difference between an edited export and the catalog.
+type CatalogEdits struct {
+	Edits     []*BookEdit
+	Unchanged int            // rows that match the catalog
+	Rejected  []*ImportIssue // rows that can't be applied
+}
+
+// editableBooks loads the editable fields of the books with the given IDs,
+// or of every book when ids is nil.
+func (d *Database) editableBooks(ids []int64) (map[int64]*editableBook, error) {
+	query := `SELECT b.id, b.title, b.author, b.isbn, b.publisher, b.publication_year, b.language, b.page_count,
+	                 COALESCE((SELECT group_concat(t.name, char(31)) FROM book_tags bt JOIN tags t ON t.id = bt.tag_id
+	                           WHERE bt.book_id = b.id), '')
+	          FROM books b`
+	var args []any
+	if ids != nil {
+		query += ` WHERE b.id IN (SELECT value FROM json_each(?))`
+		list := make([]string, len(ids))
+		for i, id := range ids {
+			list[i] = strconv.FormatInt(id, 10)
+		}
+		args = append(args, "["+strings.Join(list, ",")+"]")
+	}
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	books := map[int64]*editableBook{}
+	for rows.Next() {
+		var id int64
+		var b editableBook
+		var tags string
+		if err := rows.Scan(&id, &b.title, &b.author, &b.meta.ISBN, &b.meta.Publisher, &b.meta.PublicationYear,
+			&b.meta.Language, &b.meta.PageCount, &tags); err != nil {
+			return nil, err
+		}
+		if tags != "" {
+			b.tags = strings.Split(tags, "\x1f")
+			slices.Sort(b.tags)
+		}
+		books[id] = &b
+	}
+	return books, rows.Err()
+}
+
+// ExportEditableBooks writes the catalog to w as CSV with only the columns
+// DiffEditableBooks reads back: the ID, title, author, tags and
+// publication details.
+func (d *Database) ExportEditableBooks(w io.Writer) error {
+	books, err := d.editableBooks(nil)
+	if err != nil {
+		return err
+	}
+	ids := make([]int64, 0, len(books))
+	for id := range books {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	table := [][]string{editableBookColumns}
+	for _, id := range ids {
+		fields := books[id].fields()
+		row := []string{strconv.FormatInt(id, 10)}
+		for _, c := range editableBookColumns[1:] {
+			row = append(row, fields[c])
+		}
+		table = append(table, row)
+	}
+	return writeExportCSV(w, table)
+}
+
+// DiffEditableBooks reads an edited export from r and returns how it would
+// change the catalog, without changing anything. The id column is
+// required and the others optional; a column left out of the file, and a
+// book left out of it, stay as they are. Rows for unknown books, repeated
+// IDs and invalid values are rejected.
+func (d *Database) DiffEditableBooks(r io.Reader) (*CatalogEdits, error) {
+	t, err := newCSVTable(r, editableBookColumns[:1], editableBookColumns[1:])
+	if err != nil {
+		return nil, err
+	}
+	current, err := d.editableBooks(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	edits := &CatalogEdits{}
+	seen := map[int64]int{}
+	report, err := readRows(t, func(line int) (int64, error) {
+		id, err := strconv.ParseInt(t.get("id"), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("id must be a book ID, not %q", t.get("id"))
+		}
+		before, ok := current[id]
+		if !ok {
+			return 0, fmt.Errorf("book %d not found", id)
+		}
+		if first, ok := seen[id]; ok {
+			return 0, fmt.Errorf("book %d is also on line %d", id, first)
+		}
+		seen[id] = line
+
+		after, err := editedBook(t, before)
+		if err != nil {
+			return 0, err
+		}
+		if after.equal(before) {
+			edits.Unchanged++
+			return id, nil
+		}
+		edits.Edits = append(edits.Edits, &BookEdit{BookID: id, Line: line, Title: before.title,
+			Changes: fieldChanges(before, after), before: before, after: after})
+		return id, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	edits.Rejected = report.Rejected
+	return edits, nil
+}
+
+// editedBook returns before with the columns of t's current row applied,
+// validated and normalized as the catalog stores them.
+func editedBook(t *csvTable, before *editableBook) (*editableBook, error) {
+	after := *before
+	has := func(column string) bool { _, ok := t.columns[column]; return ok }
+	if has("title") {
+		after.title = t.get("title")
+	}
+	if has("author") {
+		after.author = t.get("author")
+	}
+	if after.title == "" || after.author == "" {
+		return nil, fmt.Errorf("title and author are required")
+	}
+	if has("isbn") {
+		after.meta.ISBN = t.get("isbn")
+	}
+	if has("publisher") {
+		after.meta.Publisher = t.get("publisher")
+	}
+	if has("language") {
+		after.meta.Language = t.get("language")
+	}
+	for _, f := range []struct {
+		column string
+		v      *int
+	}{{"publication_year", &after.meta.PublicationYear}, {"page_count", &after.meta.PageCount}} {
+		if !has(f.column) {
+			continue
+		}
+		*f.v = 0
+		if v := t.get(f.column); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be a number, not %q", f.column, v)
+			}
+			*f.v = n
+		}
+	}
+	meta, err := after.meta.normalize()
+	if err != nil {
+		return nil, err
+	}
+	after.meta = meta
+
+	if has("tags") {
+		after.tags = nil
+		for _, tag := range strings.Split(t.get("tags"), ";") {
+			if strings.TrimSpace(tag) == "" {
+				continue
+			}
+			name, err := normalizeTag(tag)
+			if err != nil {
+				return nil, err
+			}
+			if !slices.Contains(after.tags, name) {
+				after.tags = append(after.tags, name)
+			}
+		}
+		slices.Sort(after.tags)
+	}
+	return &after, nil
+}
+
+// fieldChanges lists the columns that differ between before and after.
+func fieldChanges(before, after *editableBook) []FieldChange {
+	old, edited := before.fields(), after.fields()
+	var changes []FieldChange
+	for _, c := range editableBookColumns[1:] {
+		if c == "tags" {
+			continue
+		}
+		if old[c] != edited[c] {
+			changes = append(changes, FieldChange{Field: c, Old: old[c], New: edited[c]})
+		}
+	}
+	// Tags read better as what is added and removed
+	var tagChanges []string
+	for _, tag := range after.tags {
+		if !slices.Contains(before.tags, tag) {
+			tagChanges = append(tagChanges, "+"+tag)
+		}
+	}
+	for _, tag := range before.tags {
+		if !slices.Contains(after.tags, tag) {
+			tagChanges = append(tagChanges, "-"+tag)
+		}
+	}
+	if len(tagChanges) > 0 {
+		changes = append(changes, FieldChange{Field: "tags", Old: old["tags"], New: strings.Join(tagChanges, ", ")})
+	}
+	return changes
+}
+
+// ApplyBookEdits makes the edits DiffEditableBooks found. A book changed
+// by someone else since the preview is skipped, so their change isn't
+// overwritten; the skipped books are returned, by the edited file's line.
+func (d *Database) ApplyBookEdits(edits []*BookEdit) ([]*ImportIssue, error) {
+	ids := make([]int64, len(edits))
+	for i, e := range edits {
+		ids[i] = e.BookID
+	}
+	current, err := d.editableBooks(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var skipped []*ImportIssue
+	for _, e := range edits {
+		now, ok := current[e.BookID]
+		switch {
+		case !ok:
+			skipped = append(skipped, &ImportIssue{Line: e.Line, Reason: fmt.Sprintf("book %d has been deleted", e.BookID)})
+			continue
+		case !now.equal(e.before):
+			skipped = append(skipped, &ImportIssue{Line: e.Line, Reason: fmt.Sprintf("book %d has changed since the preview", e.BookID)})
+			continue
+		}
+		if err := d.applyBookEdit(e); err != nil {
+			return skipped, fmt.Errorf("book %d: %w", e.BookID, err)
+		}
+	}
+	return skipped, nil
+}
+
+func (d *Database) applyBookEdit(e *BookEdit) error {
+	before, after := e.before, e.after
+	if before.title != after.title || before.author != after.author || before.meta != after.meta {
+		if err := d.UpdateBookMetadata(e.BookID, after.title, after.author, after.meta); err != nil {
+			return err
+		}
+	}
+	for _, tag := range after.tags {
+		if !slices.Contains(before.tags, tag) {
+			if err := d.TagBook(e.BookID, tag); err != nil {
+				return err
+			}
+		}
+	}
+	for _, tag := range before.tags {
+		if !slices.Contains(after.tags, tag) {
+			if err := d.UntagBook(e.BookID, tag); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) ExportEditableBooks(w io.Writer) error {
+	return lm.db.ExportEditableBooks(w)
+}
+
+func (lm *LibraryManager) DiffEditableBooks(r io.Reader) (*CatalogEdits, error) {
+	return lm.db.DiffEditableBooks(r)
+}
+
+func (lm *LibraryManager) ApplyBookEdits(edits []*BookEdit) ([]*ImportIssue, error) {
+	return lm.db.ApplyBookEdits(edits)
+}
@@ -0,0 +1,70 @@
+package library
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestEditableBooksRoundTrip(t *testing.T) {
+	db := tempDB(t)
+	dune, _ := db.AddBook("Dune", "Frank Herbert", "")
+	emma, _ := db.AddBook("emma", "J. Austen", "")
+	db.AddBook("Untouched", "Someone", "")
+	db.TagBook(dune, "scifi")
+	db.TagBook(dune, "classic")
+
+	var buf bytes.Buffer
+	if err := db.ExportEditableBooks(&buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	table, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(table) != 4 || table[1][3] != "classic; scifi" {
+		t.Fatalf("unexpected export: %v %q", err, table)
+	}
+
+	// Fix Emma, retag Dune, and make two mistakes
+	table[1][3] = "Science Fiction; classic"
+	table[2][1], table[2][2], table[2][6] = "Emma", "Jane Austen", "1815"
+	table = append(table, []string{"99", "Ghost", "Nobody", "", "", "", "", "", ""},
+		[]string{"1", "Dune", "Frank Herbert", "", "", "", "", "", ""})
+	buf.Reset()
+	csv.NewWriter(&buf).WriteAll(table)
+
+	diff, err := db.DiffEditableBooks(&buf)
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if len(diff.Edits) != 2 || diff.Unchanged != 1 || len(diff.Rejected) != 2 {
+		t.Fatalf("expected 2 edits, 1 unchanged and 2 rejected, got %+v", diff)
+	}
+	if !strings.Contains(diff.Rejected[1].Reason, "also on line 2") {
+		t.Errorf("expected the repeated ID rejected, got %q", diff.Rejected[1].Reason)
+	}
+	if c := diff.Edits[0].Changes; len(c) != 1 || c[0].Field != "tags" || c[0].New != "+science fiction, -scifi" {
+		t.Errorf("unexpected tag changes: %+v", c)
+	}
+	if c := diff.Edits[1].Changes; len(c) != 3 || c[0].Field != "title" || c[0].Old != "emma" || c[2].Field != "publication_year" {
+		t.Errorf("unexpected Emma changes: %+v", c)
+	}
+	if book, _ := db.GetBook(emma); book.Title != "emma" {
+		t.Fatalf("a diff must not change the catalog")
+	}
+
+	skipped, err := db.ApplyBookEdits(diff.Edits)
+	if err != nil || len(skipped) != 0 {
+		t.Fatalf("apply: %v %+v", err, skipped)
+	}
+	if book, _ := db.GetBook(emma); book.Title != "Emma" || book.Author != "Jane Austen" || book.PublicationYear != 1815 {
+		t.Fatalf("Emma not corrected: %+v", book)
+	}
+	if tags, _ := db.GetBookTags(dune); strings.Join(tags, ",") != "classic,science fiction" {
+		t.Fatalf("Dune not retagged: %v", tags)
+	}
+
+	// Applying the same preview again finds the books changed since
+	if skipped, _ := db.ApplyBookEdits(diff.Edits); len(skipped) != 2 || !strings.Contains(skipped[0].Reason, "changed since the preview") {
+		t.Fatalf("expected stale edits skipped, got %+v", skipped)
+	}
+}
@@ -0,0 +1,264 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// circulationFormat versions the CirculationState layout.
+const circulationFormat = 1
+
+// CirculationState is the live circulation of a library (open loans,
+// waiting holds and outstanding charges) without its catalog or content.
+// Books are identified by title and author and members by ID and name, so
+// the state can be loaded into a library whose books were re-imported from
+// files and therefore have new IDs.
+type CirculationState struct {
+	Format       int                 `json:"format"`
+	ExportedTime time.Time           `json:"exported_time"`
+	Checkouts    []*ExportedCheckout `json:"checkouts"`
+	Holds        []*ExportedHold     `json:"holds"`
+	Fines        []*ExportedFine     `json:"fines"`
+}
+
+// ExportedCheckout is an open loan.
+type ExportedCheckout struct {
+	BookTitle     string     `json:"book_title"`
+	BookAuthor    string     `json:"book_author"`
+	MemberID      int64      `json:"member_id"`
+	MemberName    string     `json:"member_name"`
+	Status        string     `json:"status"`
+	CheckoutTime  time.Time  `json:"checkout_time"`
+	DueTime       *time.Time `json:"due_time,omitempty"`
+	ClaimTime     *time.Time `json:"claim_time,omitempty"`
+	PickupCode    string     `json:"pickup_code,omitempty"`
+	DepositCents  int64      `json:"deposit_cents,omitempty"`
+	DepositStatus string     `json:"deposit_status,omitempty"`
+}
+
+// ExportedHold is a reservation still waiting in a book's queue.
+type ExportedHold struct {
+	BookTitle       string    `json:"book_title"`
+	BookAuthor      string    `json:"book_author"`
+	MemberID        int64     `json:"member_id"`
+	MemberName      string    `json:"member_name"`
+	ReservationTime time.Time `json:"reservation_time"`
+}
+
+// ExportedFine is an unreversed charge. Checkout is the 1-based position in
+// CirculationState.Checkouts of the loan it was charged for, or 0.
+type ExportedFine struct {
+	MemberID    int64     `json:"member_id"`
+	MemberName  string    `json:"member_name"`
+	Checkout    int       `json:"checkout,omitempty"`
+	Kind        string    `json:"kind"`
+	AmountCents int64     `json:"amount_cents"`
+	Description string    `json:"description"`
+	CreatedTime time.Time `json:"created_time"`
+}
+
+// ExportCirculation captures the library's live circulation state.
+func (d *Database) ExportCirculation() (*CirculationState, error) {
+	state := &CirculationState{Format: circulationFormat, ExportedTime: d.now().UTC()}
+
+	rows, err := d.db.Query(`SELECT c.id, b.title, b.author, m.id, m.name, c.status, c.checkout_time, c.due_time, c.claim_time,
+                                    COALESCE(c.pickup_code, ''), c.deposit_cents, COALESCE(c.deposit_status, '')
+                             FROM checkouts c
+                             JOIN books b ON c.book_id = b.id
+                             JOIN members m ON c.member_id = m.id
+                             WHERE c.return_time IS NULL
+                             ORDER BY c.id`)
+	if err != nil {
+		return nil, err
+	}
+	position := make(map[int64]int)
+	for rows.Next() {
+		var id int64
+		var c ExportedCheckout
+		var due, claim sql.NullTime
+		if err := rows.Scan(&id, &c.BookTitle, &c.BookAuthor, &c.MemberID, &c.MemberName, &c.Status, &c.CheckoutTime,
+			&due, &claim, &c.PickupCode, &c.DepositCents, &c.DepositStatus); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if due.Valid {
+			c.DueTime = &due.Time
+		}
+		if claim.Valid {
+			c.ClaimTime = &claim.Time
+		}
+		state.Checkouts = append(state.Checkouts, &c)
+		position[id] = len(state.Checkouts)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = d.db.Query(`SELECT b.title, b.author, m.id, m.name, r.reservation_time
+                            FROM reservations r
+                            JOIN books b ON r.book_id = b.id
+                            JOIN members m ON r.member_id = m.id
+                            WHERE r.fulfilled_time IS NULL
+                            ORDER BY r.book_id, r.reservation_time, r.id`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var h ExportedHold
+		if err := rows.Scan(&h.BookTitle, &h.BookAuthor, &h.MemberID, &h.MemberName, &h.ReservationTime); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		state.Holds = append(state.Holds, &h)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = d.db.Query(`SELECT m.id, m.name, COALESCE(f.checkout_id, 0), f.kind, f.amount_cents, f.description, f.created_time
+                            FROM fines f JOIN members m ON f.member_id = m.id
+                            WHERE f.reversed_time IS NULL
+                            ORDER BY f.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f ExportedFine
+		var checkoutID int64
+		if err := rows.Scan(&f.MemberID, &f.MemberName, &checkoutID, &f.Kind, &f.AmountCents, &f.Description, &f.CreatedTime); err != nil {
+			return nil, err
+		}
+		f.Checkout = position[checkoutID]
+		state.Fines = append(state.Fines, &f)
+	}
+	return state, rows.Err()
+}
+
+// ImportCirculation loads state exported from another library. Every book
+// and member must already exist here; if any cannot be matched, or a book
+// is not available to take the loan, nothing is imported.
+func (d *Database) ImportCirculation(state *CirculationState) error {
+	if state.Format != circulationFormat {
+		return fmt.Errorf("unsupported circulation export format %d", state.Format)
+	}
+
+	return d.inTx(func(tx *sql.Tx) error {
+		for _, m := range memberRefs(state) {
+			var name string
+			err := tx.QueryRow(`SELECT name FROM members WHERE id=?`, m.id).Scan(&name)
+			if err == sql.ErrNoRows || (err == nil && name != m.name) {
+				return fmt.Errorf("member %d (%s) not found", m.id, m.name)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		checkoutIDs := make([]int64, len(state.Checkouts))
+		for i, c := range state.Checkouts {
+			var bookID int64
+			err := tx.QueryRow(`SELECT id FROM books WHERE title=? AND author=? AND available=1 ORDER BY id LIMIT 1`,
+				c.BookTitle, c.BookAuthor).Scan(&bookID)
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("no available copy of '%s' by %s for member %d", c.BookTitle, c.BookAuthor, c.MemberID)
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`UPDATE books SET available=0, borrower_id=? WHERE id=?`, c.MemberID, bookID); err != nil {
+				return err
+			}
+			var pickupCode, depositStatus interface{}
+			if c.PickupCode != "" {
+				pickupCode = c.PickupCode
+			}
+			if c.DepositStatus != "" {
+				depositStatus = c.DepositStatus
+			}
+			res, err := tx.Exec(`INSERT INTO checkouts(book_id, member_id, status, checkout_time, due_time, claim_time,
+                                                       pickup_code, deposit_cents, deposit_status)
+                                 VALUES(?,?,?,?,?,?,?,?,?)`,
+				bookID, c.MemberID, c.Status, sqlTime(&c.CheckoutTime), sqlTime(c.DueTime), sqlTime(c.ClaimTime),
+				pickupCode, c.DepositCents, depositStatus)
+			if err != nil {
+				return fmt.Errorf("import loan of '%s': %w", c.BookTitle, err)
+			}
+			if checkoutIDs[i], err = res.LastInsertId(); err != nil {
+				return err
+			}
+			detail := fmt.Sprintf("imported checkout of book %d from another library", bookID)
+			if err := recordAuditOf(tx, 0, AuditCheckout, c.MemberID, bookID, detail); err != nil {
+				return err
+			}
+		}
+
+		for _, h := range state.Holds {
+			var bookID int64
+			err := tx.QueryRow(`SELECT id FROM books WHERE title=? AND author=? ORDER BY id LIMIT 1`,
+				h.BookTitle, h.BookAuthor).Scan(&bookID)
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("book '%s' by %s not found", h.BookTitle, h.BookAuthor)
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT INTO reservations(book_id, member_id, reservation_time) VALUES(?,?,?)`,
+				bookID, h.MemberID, sqlTime(&h.ReservationTime)); err != nil {
+				return err
+			}
+		}
+
+		for _, f := range state.Fines {
+			var checkoutID interface{}
+			if f.Checkout > 0 && f.Checkout <= len(checkoutIDs) {
+				checkoutID = checkoutIDs[f.Checkout-1]
+			}
+			if _, err := tx.Exec(`INSERT INTO fines(member_id, checkout_id, kind, amount_cents, description, created_time) VALUES(?,?,?,?,?,?)`,
+				f.MemberID, checkoutID, f.Kind, f.AmountCents, f.Description, sqlTime(&f.CreatedTime)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type memberRef struct {
+	id   int64
+	name string
+}
+
+// memberRefs lists the distinct members referenced by state.
+func memberRefs(state *CirculationState) []memberRef {
+	seen := make(map[memberRef]bool)
+	var refs []memberRef
+	add := func(id int64, name string) {
+		if r := (memberRef{id, name}); !seen[r] {
+			seen[r] = true
+			refs = append(refs, r)
+		}
+	}
+	for _, c := range state.Checkouts {
+		add(c.MemberID, c.MemberName)
+	}
+	for _, h := range state.Holds {
+		add(h.MemberID, h.MemberName)
+	}
+	for _, f := range state.Fines {
+		add(f.MemberID, f.MemberName)
+	}
+	return refs
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) ExportCirculation() (*CirculationState, error) {
+	return lm.db.ExportCirculation()
+}
+
+func (lm *LibraryManager) ImportCirculation(state *CirculationState) error {
+	return lm.db.ImportCirculation(state)
+}
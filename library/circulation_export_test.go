@@ -0,0 +1,96 @@
+package library
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestCirculationExportImport(t *testing.T) {
+	src := tempDB(t)
+	lm := &LibraryManager{db: src}
+	dune, _ := src.AddBook("Dune", "Frank Herbert", "content")
+	emma, _ := src.AddBook("Emma", "Jane Austen", "content")
+	alice, _ := src.AddMember("Alice", "password")
+	bob, _ := src.AddMember("Bob", "password")
+
+	src.CheckoutBook(dune, alice)
+	src.db.Exec(`UPDATE checkouts SET due_time = datetime('now', '-3 days')`)
+	src.ReserveBook(dune, bob)
+	src.CheckoutBook(emma, bob)
+	if err := src.MarkLost(emma); err != nil {
+		t.Fatalf("mark lost: %v", err)
+	}
+	// A returned loan is history, not live state
+	src.db.Exec(`INSERT INTO checkouts(book_id, member_id, return_time) VALUES(?, ?, CURRENT_TIMESTAMP)`, emma, alice)
+
+	state, err := lm.ExportCirculation()
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if len(state.Checkouts) != 2 || len(state.Holds) != 1 || len(state.Fines) != 1 {
+		t.Fatalf("unexpected export: %d checkouts, %d holds, %d fines", len(state.Checkouts), len(state.Holds), len(state.Fines))
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	// The destination re-imported its books, in a different order
+	dst := tempDB(t)
+	dstEmma, _ := dst.AddBook("Emma", "Jane Austen", "content")
+	dstDune, _ := dst.AddBook("Dune", "Frank Herbert", "content")
+	dst.AddMember("Alice", "password")
+	dst.AddMember("Bob", "password")
+
+	var loaded CirculationState
+	json.Unmarshal(data, &loaded)
+	if err := dst.ImportCirculation(&loaded); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+
+	loans, _ := dst.GetOpenLoans(alice)
+	if len(loans) != 1 || loans[0].BookID != dstDune || !loans[0].DueTime.Before(time.Now().Add(-48*time.Hour)) {
+		t.Fatalf("Alice's overdue loan did not carry over: %+v", loans)
+	}
+	loans, _ = dst.GetOpenLoans(bob)
+	if len(loans) != 1 || loans[0].BookID != dstEmma || loans[0].Status != LoanLost {
+		t.Fatalf("Bob's lost loan did not carry over: %+v", loans)
+	}
+	fines, _ := dst.GetMemberFines(bob)
+	if len(fines) != 1 || fines[0].Kind != FineReplacement || fines[0].CheckoutID != loans[0].CheckoutID {
+		t.Fatalf("replacement charge did not carry over: %+v", fines)
+	}
+	queue, _ := dst.GetReservations(dstDune)
+	if len(queue) != 1 || queue[0].ID != bob {
+		t.Fatalf("hold did not carry over: %+v", queue)
+	}
+}
+
+func TestImportCirculationIsAllOrNothing(t *testing.T) {
+	dst := tempDB(t)
+	dst.AddBook("Dune", "Frank Herbert", "content")
+	alice, _ := dst.AddMember("Alice", "password")
+
+	state := &CirculationState{
+		Format: circulationFormat,
+		Checkouts: []*ExportedCheckout{
+			{BookTitle: "Dune", BookAuthor: "Frank Herbert", MemberID: alice, MemberName: "Alice", Status: LoanActive},
+			{BookTitle: "Missing", BookAuthor: "Nobody", MemberID: alice, MemberName: "Alice", Status: LoanActive},
+		},
+	}
+	if err := dst.ImportCirculation(state); err == nil {
+		t.Fatalf("expected unmatched book to fail the import")
+	}
+	var open int
+	dst.db.QueryRow(`SELECT COUNT(*) FROM checkouts`).Scan(&open)
+	if open != 0 {
+		t.Fatalf("partial import left %d checkouts", open)
+	}
+
+	state.Checkouts = state.Checkouts[:1]
+	state.Checkouts[0].MemberName = "Mallory"
+	if err := dst.ImportCirculation(state); err == nil {
+		t.Fatalf("expected member name mismatch to fail the import")
+	}
+}
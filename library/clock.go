@@ -0,0 +1,73 @@
+package library
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the library what time it is. Due dates, hold expiry, fines,
+// membership checks and reminders all read it, so tests can stand in a
+// FixedClock instead of waiting for real time to pass.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the wall clock, used unless SetClock says otherwise.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// FixedClock is a Clock that only moves when told to.
+type FixedClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFixedClock returns a FixedClock stopped at now.
+func NewFixedClock(now time.Time) *FixedClock { return &FixedClock{now: now} }
+
+func (c *FixedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set moves the clock to now.
+func (c *FixedClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock forward by d.
+func (c *FixedClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// SetClock sets the clock the library reads the time from; nil restores the
+// wall clock.
+func (d *Database) SetClock(c Clock) { d.clock = c }
+
+// now returns the time on the library's clock.
+func (d *Database) now() time.Time {
+	if d.clock == nil {
+		return systemClock{}.Now()
+	}
+	return d.clock.Now()
+}
+
+// sqlNow returns the time on the library's clock formatted for storage, for
+// queries that would otherwise use CURRENT_TIMESTAMP or datetime('now').
+func (d *Database) sqlNow() string {
+	return d.now().UTC().Format(sqlTimeLayout)
+}
+
+// ------------------ Manager helpers ------------------
+
+// SetClock sets the clock the library reads the time from.
+func (lm *LibraryManager) SetClock(c Clock) { lm.db.SetClock(c) }
+
+// Now returns the time on the library's clock.
+func (lm *LibraryManager) Now() time.Time { return lm.db.now() }
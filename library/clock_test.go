@@ -0,0 +1,89 @@
+package library
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCirculationFollowsClock(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	start := time.Date(2030, 3, 1, 9, 0, 0, 0, time.UTC)
+	clock := NewFixedClock(start)
+	db.SetClock(clock)
+
+	bookID, _ := db.AddBook("Book", "Author", "content")
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+
+	if err := db.CheckoutBook(bookID, alice); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	loans, _ := db.GetOpenLoans(alice)
+	if len(loans) != 1 || !loans[0].CheckoutTime.Equal(start) || !loans[0].DueTime.Equal(start.AddDate(0, 0, defaultLoanDays)) {
+		t.Fatalf("loan not dated by the clock: %+v", loans)
+	}
+
+	// Nothing is overdue until the clock passes the due date
+	n := &recordingNotifier{}
+	if sent, _ := lm.SendOverdueNotices(n); sent != 0 {
+		t.Fatalf("overdue before the due date")
+	}
+	clock.Advance((defaultLoanDays + 3) * 24 * time.Hour)
+	if sent, err := lm.SendOverdueNotices(n); err != nil || sent != 1 {
+		t.Fatalf("overdue notices: %d %v", sent, err)
+	}
+	if fine := loans[0].AccruedFineCents(lm.Now()); fine != 3*loans[0].FineCentsPerDay {
+		t.Fatalf("fine after three days late = %d", fine)
+	}
+
+	// The hold placed on return lapses when the clock passes its window
+	db.ReserveBook(bookID, bob)
+	returned := clock.Now()
+	db.ReturnBook(bookID)
+	holds, _ := db.GetHolds()
+	if len(holds) != 1 || !holds[0].HoldExpiryTime.Equal(returned.AddDate(0, 0, defaultHoldPickupDays)) {
+		t.Fatalf("hold not dated by the clock: %+v", holds)
+	}
+	if expired, _ := db.ExpireHolds(); expired != 0 {
+		t.Fatalf("hold expired early")
+	}
+	clock.Advance(defaultHoldPickupDays * 24 * time.Hour)
+	if expired, err := db.ExpireHolds(); err != nil || expired != 1 {
+		t.Fatalf("expire holds: %d %v", expired, err)
+	}
+
+	// Reports can look back to a date before the later activity
+	asOf := start.Add(time.Hour)
+	stats, err := db.GetUsageStats(start.AddDate(0, 0, -30), asOf, 10)
+	if err != nil || stats.Checkouts != 1 {
+		t.Fatalf("usage as of the first day: %+v %v", stats, err)
+	}
+	if stats, _ := db.GetUsageStats(start.AddDate(0, 0, -30), start.Add(-time.Second), 10); stats.Checkouts != 0 {
+		t.Fatalf("usage before the first checkout: %+v", stats)
+	}
+}
+
+func TestMembershipExpiryFollowsClock(t *testing.T) {
+	db := tempDB(t)
+	clock := NewFixedClock(time.Now())
+	db.SetClock(clock)
+
+	bookID, _ := db.AddBook("Book", "Author", "content")
+	alice, _ := db.AddMember("Alice", "password")
+
+	clock.Advance(2 * DefaultMembershipDays * 24 * time.Hour)
+	if err := db.CheckoutBook(bookID, alice); err == nil {
+		t.Fatalf("expired member checked out a book")
+	}
+	expiry, err := db.RenewMembership(alice, 30)
+	if err != nil {
+		t.Fatalf("renew: %v", err)
+	}
+	if want := clock.Now().AddDate(0, 0, 30); expiry.Sub(want).Abs() > time.Second {
+		t.Fatalf("renewed until %v, want %v", expiry, want)
+	}
+	if err := db.CheckoutBook(bookID, alice); err != nil {
+		t.Fatalf("checkout after renewal: %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Collection is a curated reading list.
+type Collection struct {
+	ID          int64             `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Items       []*CollectionItem `json:"items"`
+}
+
+// CollectionItem is one book on a reading list, with the curator's blurb.
+type CollectionItem struct {
+	BookID int64  `json:"book_id"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	Blurb  string `json:"blurb"`
+}
+
+// CreateCollection starts an empty reading list.
+func (d *Database) CreateCollection(name, description string) (int64, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, fmt.Errorf("collection name cannot be empty")
+	}
+	res, err := d.db.Exec(`INSERT INTO collections(name, description) VALUES(?,?)`, name, strings.TrimSpace(description))
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return 0, fmt.Errorf("a collection named %q already exists", name)
+		}
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// AddToCollection appends bookID to the end of the named collection, or
+// updates its blurb if it is already there.
+func (d *Database) AddToCollection(name string, bookID int64, blurb string) error {
+	return d.inTx(func(tx *sql.Tx) error {
+		var collectionID int64
+		err := tx.QueryRow(`SELECT id FROM collections WHERE name=?`, name).Scan(&collectionID)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("collection %q not found", name)
+		}
+		if err != nil {
+			return err
+		}
+		var exists int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM books WHERE id=?`, bookID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists == 0 {
+			return fmt.Errorf("book not found")
+		}
+		_, err = tx.Exec(`INSERT INTO collection_items(collection_id, book_id, position, blurb)
+                          VALUES(?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM collection_items WHERE collection_id=?), ?)
+                          ON CONFLICT(collection_id, book_id) DO UPDATE SET blurb=excluded.blurb`,
+			collectionID, bookID, collectionID, strings.TrimSpace(blurb))
+		return err
+	})
+}
+
+// RemoveFromCollection takes bookID off the named collection.
+func (d *Database) RemoveFromCollection(name string, bookID int64) error {
+	res, err := d.db.Exec(`DELETE FROM collection_items
+                           WHERE book_id=? AND collection_id=(SELECT id FROM collections WHERE name=?)`, bookID, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("book %d is not in collection %q", bookID, name)
+	}
+	return nil
+}
+
+// GetCollection returns the named collection with its books in order.
+func (d *Database) GetCollection(name string) (*Collection, error) {
+	var c Collection
+	err := d.db.QueryRow(`SELECT id, name, description FROM collections WHERE name=?`, name).
+		Scan(&c.ID, &c.Name, &c.Description)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("collection %q not found", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.db.Query(`SELECT b.id, b.title, b.author, i.blurb
+                             FROM collection_items i JOIN books b ON i.book_id = b.id
+                             WHERE i.collection_id=? ORDER BY i.position`, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var it CollectionItem
+		if err := rows.Scan(&it.BookID, &it.Title, &it.Author, &it.Blurb); err != nil {
+			return nil, err
+		}
+		c.Items = append(c.Items, &it)
+	}
+	return &c, rows.Err()
+}
+
+// GetCollections lists every collection without its items.
+func (d *Database) GetCollections() ([]*Collection, error) {
+	rows, err := d.db.Query(`SELECT id, name, description FROM collections ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cs []*Collection
+	for rows.Next() {
+		var c Collection
+		if err := rows.Scan(&c.ID, &c.Name, &c.Description); err != nil {
+			return nil, err
+		}
+		cs = append(cs, &c)
+	}
+	return cs, rows.Err()
+}
+
+// WriteCollectionPDF writes a printable handout of the named collection to
+// w: a title, the description, then each book with its author, catalog
+// number (the book ID; the catalog keeps no shelf call numbers) and blurb.
+func (d *Database) WriteCollectionPDF(name string, w io.Writer, now time.Time) error {
+	c, err := d.GetCollection(name)
+	if err != nil {
+		return err
+	}
+	doc := newPDFDoc()
+	doc.paragraph(pdfBold, 20, 0, c.Name)
+	if c.Description != "" {
+		doc.space(4)
+		doc.paragraph(pdfRegular, 11, 0, c.Description)
+	}
+	doc.space(14)
+	for i, it := range c.Items {
+		doc.keepTogether(11*1.3*3 + 10)
+		doc.paragraph(pdfBold, 12, 0, fmt.Sprintf("%d. %s", i+1, it.Title))
+		doc.paragraph(pdfRegular, 10, 14, fmt.Sprintf("%s  ·  Catalog no. %d", it.Author, it.BookID))
+		if it.Blurb != "" {
+			doc.paragraph(pdfItalic, 10, 14, it.Blurb)
+		}
+		doc.space(10)
+	}
+	if len(c.Items) == 0 {
+		doc.paragraph(pdfItalic, 11, 0, "This list is empty.")
+	}
+	doc.setFooter(fmt.Sprintf("%s · printed %s", c.Name, now.Format("January 2, 2006")))
+	_, err = doc.WriteTo(w)
+	return err
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) CreateCollection(name, description string) (int64, error) {
+	return lm.db.CreateCollection(name, description)
+}
+
+func (lm *LibraryManager) AddToCollection(name string, bookID int64, blurb string) error {
+	return lm.db.AddToCollection(name, bookID, blurb)
+}
+
+func (lm *LibraryManager) RemoveFromCollection(name string, bookID int64) error {
+	return lm.db.RemoveFromCollection(name, bookID)
+}
+
+func (lm *LibraryManager) GetCollection(name string) (*Collection, error) {
+	return lm.db.GetCollection(name)
+}
+
+func (lm *LibraryManager) GetCollections() ([]*Collection, error) { return lm.db.GetCollections() }
+
+// WriteCollectionPDF writes a printable handout of a collection; see
+// Database.WriteCollectionPDF.
+func (lm *LibraryManager) WriteCollectionPDF(name string, w io.Writer) error {
+	return lm.db.WriteCollectionPDF(name, w, lm.db.localTime(lm.db.now()))
+}
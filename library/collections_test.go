@@ -0,0 +1,71 @@
+package library
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectionPDF(t *testing.T) {
+	db := tempDB(t)
+	dune, _ := db.AddBook("Dune", "Frank Herbert", "")
+	hobbit, _ := db.AddBook("The Hobbit (Illustrated)", "J.R.R. Tolkien", "")
+
+	if _, err := db.CreateCollection("Summer Reads", "Staff picks for the holidays"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := db.CreateCollection("Summer Reads", ""); err == nil {
+		t.Fatalf("expected duplicate name to be refused")
+	}
+	db.AddToCollection("Summer Reads", hobbit, "There and back again")
+	db.AddToCollection("Summer Reads", dune, "Desert politics")
+	db.AddToCollection("Summer Reads", hobbit, "A hobbit’s tale")
+	if err := db.AddToCollection("Summer Reads", 999, ""); err == nil {
+		t.Fatalf("expected unknown book to be refused")
+	}
+
+	c, err := db.GetCollection("Summer Reads")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if len(c.Items) != 2 || c.Items[0].BookID != hobbit || c.Items[0].Blurb != "A hobbit’s tale" {
+		t.Fatalf("unexpected items: %+v", c.Items)
+	}
+
+	var buf bytes.Buffer
+	if err := db.WriteCollectionPDF("Summer Reads", &buf, time.Now()); err != nil {
+		t.Fatalf("pdf: %v", err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "%PDF-1.4") || !strings.HasSuffix(out, "%%EOF\n") {
+		t.Fatalf("not a PDF")
+	}
+	for _, want := range []string{"(1. The Hobbit \\(Illustrated\\))", "(2. Dune)", "A hobbit\\222s tale"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("PDF missing %q", want)
+		}
+	}
+	// startxref must point at the cross-reference table
+	i := strings.LastIndex(out, "startxref\n")
+	off, err := strconv.Atoi(strings.Fields(out[i+len("startxref\n"):])[0])
+	if err != nil || !strings.HasPrefix(out[off:], "xref\n") {
+		t.Fatalf("bad startxref offset %d", off)
+	}
+}
+
+func TestPDFPageBreaks(t *testing.T) {
+	doc := newPDFDoc()
+	for i := 0; i < 200; i++ {
+		doc.paragraph(pdfRegular, 11, 0, "A line of text long enough to matter when it wraps across the page width of a letter sheet.")
+	}
+	if len(doc.pages) < 3 {
+		t.Fatalf("expected several pages, got %d", len(doc.pages))
+	}
+	var buf bytes.Buffer
+	doc.WriteTo(&buf)
+	if !strings.Contains(buf.String(), "/Count "+strconv.Itoa(len(doc.pages))) {
+		t.Fatalf("page count missing from page tree")
+	}
+}
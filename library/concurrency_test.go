@@ -0,0 +1,249 @@
+package library
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fileDB opens a database file; unlike tempDB's in-memory database, every
+// connection of the pool sees the same data, so it can be used from several
+// goroutines at once.
+func fileDB(t *testing.T, path string) *Database {
+	t.Helper()
+	db, err := NewDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetBcryptCost(bcrypt.MinCost)
+	return db
+}
+
+// checkCirculation fails t if a book's availability disagrees with its open
+// loans, or the audit chain is broken.
+func checkCirculation(t *testing.T, db *Database) {
+	t.Helper()
+	var bad int
+	err := db.db.QueryRow(`SELECT COUNT(*) FROM books b
+	                       WHERE (SELECT COUNT(*) FROM checkouts c WHERE c.book_id = b.id AND c.return_time IS NULL) > 1
+	                          OR b.available <> NOT EXISTS (SELECT 1 FROM checkouts c WHERE c.book_id = b.id AND c.return_time IS NULL)
+	                          OR (b.available = 0 AND b.borrower_id IS NOT
+	                              (SELECT member_id FROM checkouts c WHERE c.book_id = b.id AND c.return_time IS NULL))`).Scan(&bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bad > 0 {
+		t.Errorf("%d books disagree with their loans", bad)
+	}
+	if _, err := db.VerifyAuditLog(); err != nil {
+		t.Errorf("audit log: %v", err)
+	}
+}
+
+func TestJournalModeWAL(t *testing.T) {
+	db := fileDB(t, filepath.Join(t.TempDir(), "wal.db"))
+	var mode string
+	if err := db.db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil || mode != "wal" {
+		t.Fatalf("journal mode %q, %v", mode, err)
+	}
+}
+
+func TestConcurrentCirculation(t *testing.T) {
+	db := fileDB(t, filepath.Join(t.TempDir(), "stress.db"))
+	var books, members []int64
+	for i := 0; i < 8; i++ {
+		id, _ := db.AddBook(fmt.Sprintf("Book %d", i), "Author", "content")
+		books = append(books, id)
+	}
+	for i := 0; i < 12; i++ {
+		id, _ := db.AddMember(fmt.Sprintf("Member %d", i), "password")
+		members = append(members, id)
+	}
+
+	// Desks check out, reserve, cancel and return at random; refusals such
+	// as "book is not available" are expected, lock errors are not
+	var wg sync.WaitGroup
+	errs := make(chan error, 16*150)
+	for w := 0; w < 16; w++ {
+		wg.Add(1)
+		go func(seed uint64) {
+			defer wg.Done()
+			r := rand.New(rand.NewPCG(seed, seed))
+			for i := 0; i < 150; i++ {
+				book, member := books[r.IntN(len(books))], members[r.IntN(len(members))]
+				var err error
+				switch r.IntN(4) {
+				case 0:
+					err = db.CheckoutBook(book, member)
+				case 1:
+					err = db.ReserveBook(book, member)
+				case 2:
+					err = db.CancelReservation(book, member)
+				case 3:
+					_, err = db.ReturnBook(book)
+				}
+				if isBusy(err) {
+					errs <- err
+				}
+			}
+		}(uint64(w))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("lock error: %v", err)
+	}
+	checkCirculation(t, db)
+}
+
+// Two desks, each with its own connection pool as separate processes would
+// have, race for the same copy: exactly one gets it.
+func TestConcurrentCheckoutAcrossConnections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "desks.db")
+	a := fileDB(t, path)
+	b := fileDB(t, path)
+	var members []int64
+	for i := 0; i < 10; i++ {
+		id, _ := a.AddMember(fmt.Sprintf("Member %d", i), "password")
+		members = append(members, id)
+	}
+
+	for round := 0; round < 10; round++ {
+		book, _ := a.AddBook(fmt.Sprintf("Round %d", round), "Author", "content")
+		var wg sync.WaitGroup
+		results := make(chan error, len(members))
+		for i, m := range members {
+			desk := a
+			if i%2 == 1 {
+				desk = b
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results <- desk.CheckoutBook(book, m)
+			}()
+		}
+		wg.Wait()
+		close(results)
+		won := 0
+		for err := range results {
+			switch {
+			case err == nil:
+				won++
+			case isBusy(err):
+				t.Errorf("round %d: lock error: %v", round, err)
+			}
+		}
+		if won != 1 {
+			t.Fatalf("round %d: %d checkouts of one copy succeeded", round, won)
+		}
+	}
+	checkCirculation(t, a)
+}
+
+// tickingClock moves on a second each time it is read. Reads made inside
+// write transactions happen one transaction at a time, so the timestamps
+// they store put the transactions in order.
+type tickingClock struct {
+	ticks atomic.Int64
+}
+
+func (c *tickingClock) Now() time.Time {
+	return time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC).Add(time.Duration(c.ticks.Add(1)) * time.Second)
+}
+
+// Hundreds of checkouts, returns and reservations of a few books at once
+// leave circulation as consistent as if they had been made one by one: no
+// book has two borrowers, loans of a book never overlap, and holds go down
+// each queue in the order it was joined.
+func TestCirculationInvariantsUnderLoad(t *testing.T) {
+	db := fileDB(t, filepath.Join(t.TempDir(), "load.db"))
+	db.SetClock(&tickingClock{})
+	lm := &LibraryManager{db: db}
+	var books, members []int64
+	for i := 0; i < 5; i++ {
+		id, _ := lm.AddBook(fmt.Sprintf("Book %d", i), "Author")
+		books = append(books, id)
+	}
+	for i := 0; i < 20; i++ {
+		id, _ := lm.AddMember(fmt.Sprintf("Member %d", i), "password")
+		members = append(members, id)
+	}
+
+	var wg sync.WaitGroup
+	var checkouts, returns, holds atomic.Int32
+	errs := make(chan error, 24*200)
+	for w := 0; w < 24; w++ {
+		wg.Add(1)
+		go func(seed uint64) {
+			defer wg.Done()
+			r := rand.New(rand.NewPCG(seed, 7))
+			for i := 0; i < 200; i++ {
+				book, member := books[r.IntN(len(books))], members[r.IntN(len(members))]
+				var err error
+				switch r.IntN(5) {
+				case 0, 1:
+					if err = lm.CheckoutBook(book, member); err == nil {
+						checkouts.Add(1)
+					}
+				case 2:
+					err = lm.ReserveBook(book, member)
+				case 3:
+					err = lm.CancelReservation(book, member)
+				case 4:
+					// Whoever has the book brings it back
+					var b *Book
+					if b, err = lm.GetBook(book); err == nil && !b.Available {
+						var heldFor int64
+						if heldFor, err = lm.ReturnBook(book, b.BorrowerID); err == nil {
+							returns.Add(1)
+							if heldFor != 0 {
+								holds.Add(1)
+							}
+						}
+					}
+				}
+				if isBusy(err) {
+					errs <- err
+				}
+			}
+		}(uint64(w))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("lock error: %v", err)
+	}
+	if checkouts.Load() < 50 || returns.Load() < 50 || holds.Load() == 0 {
+		t.Fatalf("too little circulation to tell: %d checkouts, %d returns, %d holds", checkouts.Load(), returns.Load(), holds.Load())
+	}
+
+	checkCirculation(t, db)
+	for _, c := range []struct{ what, query string }{
+		{"loans of a book that overlap", `
+			SELECT COUNT(*) FROM checkouts a JOIN checkouts b ON a.book_id = b.book_id AND a.id < b.id
+			WHERE a.return_time IS NULL OR a.return_time > b.checkout_time`},
+		{"holds given out of queue order", `
+			SELECT COUNT(*) FROM reservations a JOIN reservations b ON a.book_id = b.book_id
+			WHERE (a.reservation_time, a.id) < (b.reservation_time, b.id) AND b.fulfilled_time IS NOT NULL
+			  AND (a.fulfilled_time IS NULL OR a.fulfilled_time > b.fulfilled_time)`},
+		{"members waiting twice for a book", `
+			SELECT COUNT(*) FROM (SELECT 1 FROM reservations WHERE fulfilled_time IS NULL
+			                      GROUP BY book_id, member_id HAVING COUNT(*) > 1)`},
+	} {
+		var n int
+		if err := db.db.QueryRow(c.query).Scan(&n); err != nil {
+			t.Fatalf("%s: %v", c.what, err)
+		}
+		if n > 0 {
+			t.Errorf("%d %s", n, c.what)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// DefaultInlineContentMax is the largest text kept inside the database when
+// a content store is configured.
+const DefaultInlineContentMax = 64 << 10
+
+// SetContentStore moves book texts longer than inlineMax bytes into store
+// as they are written, leaving a reference in the database. Replicas that
+// share the store (a mounted volume or bucket) share the texts.
+func (d *Database) SetContentStore(store ObjectStore, inlineMax int) {
+	d.contentStore = store
+	d.inlineContentMax = inlineMax
+}
+
+// contentColumns selects a book's inline text and its content reference,
+// from books aliased as b, for resolveContent.
+const contentColumns = `COALESCE((SELECT content FROM book_contents WHERE book_id = b.id), ''), b.content_ref`
+
+// setInlineContent makes content bookID's text kept in the database.
+func setInlineContent(tx *sql.Tx, bookID int64, content string) error {
+	_, err := tx.Exec(`INSERT INTO book_contents(book_id, content) VALUES(?,?)
+	                   ON CONFLICT(book_id) DO UPDATE SET content = excluded.content`, bookID, content)
+	return err
+}
+
+// resolveContent returns a book's text given its inline text and
+// books.content_ref value, as selected by contentColumns.
+func (d *Database) resolveContent(content string, ref sql.NullString) (string, error) {
+	if !ref.Valid {
+		return content, nil
+	}
+	if d.contentStore == nil {
+		return "", fmt.Errorf("book content is in a content store, but none is configured")
+	}
+	r, err := d.contentStore.Get(ref.String)
+	if err != nil {
+		return "", fmt.Errorf("read book content: %w", err)
+	}
+	defer r.Close()
+	var sb strings.Builder
+	if _, err := io.Copy(&sb, r); err != nil {
+		return "", fmt.Errorf("read book content: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// UpdateBookContent replaces bookID's text, storing it in the content store
+// if one is configured and the text is too long to keep inline, and
+// refreshes its similarity signature.
+func (d *Database) UpdateBookContent(bookID int64, content string) error {
+	defer d.invalidateBook(bookID)
+
+	var oldRef sql.NullString
+	err := d.db.QueryRow(`SELECT content_ref FROM books WHERE id=?`, bookID).Scan(&oldRef)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if d.contentStore == nil || len(content) <= d.inlineContentMax {
+		err := d.inTx(func(tx *sql.Tx) error {
+			if err := setInlineContent(tx, bookID, content); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`UPDATE books SET content_ref=NULL WHERE id=?`, bookID)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		// A new name per version, so the old text stays valid until the
+		// database points at the new one
+		ref := fmt.Sprintf("books/%d/content-%d.txt", bookID, time.Now().UnixNano())
+		if err := d.contentStore.Put(ref, strings.NewReader(content)); err != nil {
+			return fmt.Errorf("store book content: %w", err)
+		}
+		err := d.inTx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(`UPDATE books SET content_ref=? WHERE id=?`, ref, bookID); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`DELETE FROM book_contents WHERE book_id=?`, bookID); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`DELETE FROM books_fts WHERE rowid=?`, bookID); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`INSERT INTO books_fts(rowid, title, author, content)
+                               SELECT id, title, author, ? FROM books WHERE id=?`, content, bookID)
+			return err
+		})
+		if err != nil {
+			d.contentStore.Delete(ref)
+			return err
+		}
+	}
+
+	if oldRef.Valid && d.contentStore != nil {
+		d.contentStore.Delete(oldRef.String)
+	}
+	return d.updateSignature(bookID)
+}
+
+// OffloadContent moves existing inline texts longer than the inline limit
+// into the content store and returns how many were moved. Run database
+// maintenance afterwards to hand the freed space back.
+func (d *Database) OffloadContent() (int, error) {
+	if d.contentStore == nil {
+		return 0, fmt.Errorf("no content store configured")
+	}
+	rows, err := d.db.Query(`SELECT c.book_id FROM book_contents c JOIN books b ON b.id = c.book_id
+	                         WHERE b.content_ref IS NULL AND length(CAST(c.content AS BLOB)) > ?`, d.inlineContentMax)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for i, id := range ids {
+		var content string
+		if err := d.db.QueryRow(`SELECT content FROM book_contents WHERE book_id=?`, id).Scan(&content); err != nil {
+			return i, err
+		}
+		if err := d.UpdateBookContent(id, content); err != nil {
+			return i, fmt.Errorf("book %d: %w", id, err)
+		}
+	}
+	return len(ids), nil
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) SetContentStore(store ObjectStore, inlineMax int) {
+	lm.db.SetContentStore(store, inlineMax)
+}
+
+func (lm *LibraryManager) OffloadContent() (int, error) { return lm.db.OffloadContent() }
@@ -0,0 +1,120 @@
+package library
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExternalContent(t *testing.T) {
+	db := tempDB(t)
+	bookID, _ := db.AddBook("Inline First", "Author", strings.Repeat("small words ", 20))
+
+	store, _ := NewDirStore(filepath.Join(t.TempDir(), "content"))
+	db.SetContentStore(store, 1000)
+
+	long := "CHAPTER I\n" + strings.Repeat("zebra ", 400)
+	bigID, err := db.AddBook("Big Book", "Author", long)
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	var inline string
+	db.db.QueryRow(`SELECT content FROM book_contents WHERE book_id=?`, bigID).Scan(&inline)
+	if inline != "" {
+		t.Fatalf("long text should not be stored inline")
+	}
+	if names, _ := store.List("books/"); len(names) != 1 {
+		t.Fatalf("expected one stored text, got %v", names)
+	}
+
+	chunk, err := db.GetBookContentChunk(bigID, 0, 15)
+	if err != nil || chunk != "CHAPTER I\nzebra" {
+		t.Fatalf("chunk %q, err %v", chunk, err)
+	}
+	if v, _ := db.ValidateReadBookAccess(bigID, 0); v.BookContentLength != len(long) {
+		t.Fatalf("content length %d, want %d", v.BookContentLength, len(long))
+	}
+	if books, _ := db.SearchBooks("zebra"); len(books) != 1 || books[0].ID != bigID {
+		t.Fatalf("external text should be searchable: %+v", books)
+	}
+
+	// Replacing the text swaps the stored object and the index
+	db.UpdateBookContent(bigID, strings.Repeat("yak ", 400))
+	if names, _ := store.List("books/"); len(names) != 1 {
+		t.Fatalf("old text should be removed, got %v", names)
+	}
+	if books, _ := db.SearchBooks("zebra"); len(books) != 0 {
+		t.Fatalf("stale text still indexed")
+	}
+	if books, _ := db.SearchBooks("yak"); len(books) != 1 {
+		t.Fatalf("new text not indexed")
+	}
+
+	// Short texts come back inline
+	db.UpdateBookContent(bigID, "tiny")
+	if names, _ := store.List("books/"); len(names) != 0 {
+		t.Fatalf("stored text should be removed, got %v", names)
+	}
+	if chunk, _ := db.GetBookContentChunk(bigID, 0, 10); chunk != "tiny" {
+		t.Fatalf("inline chunk %q", chunk)
+	}
+
+	// Existing inline texts can be moved out afterwards
+	db.SetContentStore(store, 100)
+	if n, err := db.OffloadContent(); err != nil || n != 1 {
+		t.Fatalf("offload moved %d, err %v", n, err)
+	}
+	if books, _ := db.SearchBooks("small"); len(books) != 1 || books[0].ID != bookID {
+		t.Fatalf("offloaded text should stay searchable: %+v", books)
+	}
+}
+
+// A library from before book_contents keeps its texts, searchable, once
+// they have moved out of the books table.
+func TestMigrateContentsOutOfBooks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for v, migrate := range migrations[:42] {
+		if err := migrate(raw); err != nil {
+			t.Fatalf("migration %d: %v", v+1, err)
+		}
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE schema_version (version INTEGER)`,
+		`INSERT INTO schema_version(version) VALUES(42)`,
+		`INSERT INTO books(title, author, content) VALUES('Dune', 'Frank Herbert', 'The spice must flow.'), ('Blank', 'Nobody', '')`,
+	} {
+		if _, err := raw.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	raw.Close()
+
+	db := fileDB(t, path)
+	if chunk, err := db.GetBookContentChunk(1, 4, 5); err != nil || chunk != "spice" {
+		t.Fatalf("chunk %q, %v", chunk, err)
+	}
+	if books, _ := db.SearchBooks("spice"); len(books) != 1 || books[0].ID != 1 {
+		t.Fatalf("search after the move = %+v", books)
+	}
+	var rows int
+	db.db.QueryRow(`SELECT COUNT(*) FROM book_contents`).Scan(&rows)
+	if rows != 1 {
+		t.Fatalf("%d rows in book_contents; only Dune has a text", rows)
+	}
+	if _, err := db.db.Exec(`SELECT content FROM books`); err == nil {
+		t.Fatalf("expected books to have no content column")
+	}
+
+	// Texts written since are indexed by the new triggers
+	if err := db.UpdateBookContent(2, "A page left blank no longer."); err != nil {
+		t.Fatal(err)
+	}
+	if books, _ := db.SearchBooks("blank"); len(books) != 1 || books[0].ID != 2 {
+		t.Fatalf("search after update = %+v", books)
+	}
+}
@@ -0,0 +1,206 @@
+package library
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// conn is the connection pool with the context queries are made under.
+// Every query a Database makes goes through it, so binding a Database to a
+// context (WithContext) reaches all of its methods at once.
+type conn struct {
+	*sql.DB
+	ctx context.Context
+}
+
+func (c conn) Query(query string, args ...any) (*sql.Rows, error) {
+	return c.DB.QueryContext(c.ctx, query, args...)
+}
+
+func (c conn) QueryRow(query string, args ...any) *sql.Row {
+	return c.DB.QueryRowContext(c.ctx, query, args...)
+}
+
+func (c conn) Exec(query string, args ...any) (sql.Result, error) {
+	return c.DB.ExecContext(c.ctx, query, args...)
+}
+
+// Begin starts a transaction that is rolled back if the context is done
+// before it commits.
+func (c conn) Begin() (*sql.Tx, error) {
+	return c.DB.BeginTx(c.ctx, nil)
+}
+
+// WithContext returns a view of d whose methods run under ctx: once ctx is
+// cancelled or its deadline passes, queries in progress are interrupted,
+// open transactions roll back, and waits for other writers give up, with
+// the method returning ctx's error.
+//
+// The view shares d's connections, caches, subscribers and settings as they
+// are when it is made. It is meant for the length of one operation, such
+// as an HTTP request; configure and Close d itself. The methods of d run
+// under context.Background.
+func (d *Database) WithContext(ctx context.Context) *Database {
+	view := *d
+	view.db.ctx = ctx
+	return &view
+}
+
+// Context returns the context d's methods run under.
+func (d *Database) Context() context.Context { return d.db.ctx }
+
+// The methods below take a context for the calls a server makes most;
+// d.WithContext(ctx).Method(...) does the same for any other method.
+
+func (d *Database) GetBookCtx(ctx context.Context, id int64) (*Book, error) {
+	return d.WithContext(ctx).GetBook(id)
+}
+
+func (d *Database) GetAllBookSummariesCtx(ctx context.Context) ([]*Book, error) {
+	return d.WithContext(ctx).GetAllBookSummaries()
+}
+
+func (d *Database) SearchBookSummariesCtx(ctx context.Context, q string) ([]*Book, error) {
+	return d.WithContext(ctx).SearchBookSummaries(q)
+}
+
+func (d *Database) GetBookUpdatedTimeCtx(ctx context.Context, bookID int64) (time.Time, error) {
+	return d.WithContext(ctx).GetBookUpdatedTime(bookID)
+}
+
+func (d *Database) CheckoutBookCtx(ctx context.Context, bookID, memberID int64) error {
+	return d.WithContext(ctx).CheckoutBook(bookID, memberID)
+}
+
+func (d *Database) ReturnBookCtx(ctx context.Context, bookID int64) (int64, error) {
+	return d.WithContext(ctx).ReturnBook(bookID)
+}
+
+func (d *Database) ReserveBookCtx(ctx context.Context, bookID, memberID int64) error {
+	return d.WithContext(ctx).ReserveBook(bookID, memberID)
+}
+
+func (d *Database) CancelReservationCtx(ctx context.Context, bookID, memberID int64) error {
+	return d.WithContext(ctx).CancelReservation(bookID, memberID)
+}
+
+func (d *Database) GetMemberCtx(ctx context.Context, id int64) (*Member, error) {
+	return d.WithContext(ctx).GetMember(id)
+}
+
+func (d *Database) AuthenticateMemberCtx(ctx context.Context, memberID int64, password string) error {
+	return d.WithContext(ctx).AuthenticateMember(memberID, password)
+}
+
+func (d *Database) IssueTokenCtx(ctx context.Context, memberID int64, scope string) (string, error) {
+	return d.WithContext(ctx).IssueToken(memberID, scope)
+}
+
+func (d *Database) AuthenticateTokenCtx(ctx context.Context, token string) (int64, error) {
+	return d.WithContext(ctx).AuthenticateToken(token)
+}
+
+func (d *Database) CapabilitiesCtx(ctx context.Context) ([]Capability, error) {
+	return d.WithContext(ctx).Capabilities()
+}
+
+func (d *Database) VersionInfoCtx(ctx context.Context) (*VersionInfo, error) {
+	return d.WithContext(ctx).VersionInfo()
+}
+
+func (d *Database) AddBooksCtx(ctx context.Context, books []BatchBook, staffID int64) ([]BatchResult, error) {
+	return d.WithContext(ctx).AddBooks(books, staffID)
+}
+
+func (d *Database) UpsertMembersCtx(ctx context.Context, members []BatchMember) ([]BatchResult, error) {
+	return d.WithContext(ctx).UpsertMembers(members)
+}
+
+func (d *Database) ApplyCirculationCtx(ctx context.Context, events []CirculationEvent) ([]BatchResult, error) {
+	return d.WithContext(ctx).ApplyCirculation(events)
+}
+
+// ------------------ Manager helpers ------------------
+
+// WithContext returns a view of lm whose methods run under ctx; see
+// Database.WithContext.
+func (lm *LibraryManager) WithContext(ctx context.Context) *LibraryManager {
+	view := *lm
+	view.db = lm.db.WithContext(ctx)
+	return &view
+}
+
+func (lm *LibraryManager) Context() context.Context { return lm.db.Context() }
+
+func (lm *LibraryManager) GetBookCtx(ctx context.Context, id int64) (*Book, error) {
+	return lm.WithContext(ctx).GetBook(id)
+}
+
+func (lm *LibraryManager) GetAllBookSummariesCtx(ctx context.Context) ([]*Book, error) {
+	return lm.WithContext(ctx).GetAllBookSummaries()
+}
+
+func (lm *LibraryManager) SearchBookSummariesCtx(ctx context.Context, q string) ([]*Book, error) {
+	return lm.WithContext(ctx).SearchBookSummaries(q)
+}
+
+func (lm *LibraryManager) GetPageCtx(ctx context.Context, bookID, memberID int64, n int) (*Page, error) {
+	return lm.WithContext(ctx).GetPage(bookID, memberID, n)
+}
+
+func (lm *LibraryManager) GetBookUpdatedTimeCtx(ctx context.Context, bookID int64) (time.Time, error) {
+	return lm.WithContext(ctx).GetBookUpdatedTime(bookID)
+}
+
+func (lm *LibraryManager) CheckoutBookCtx(ctx context.Context, bookID, memberID int64) error {
+	return lm.WithContext(ctx).CheckoutBook(bookID, memberID)
+}
+
+func (lm *LibraryManager) ReturnBookCtx(ctx context.Context, bookID, memberID int64) (int64, error) {
+	return lm.WithContext(ctx).ReturnBook(bookID, memberID)
+}
+
+func (lm *LibraryManager) ReserveBookCtx(ctx context.Context, bookID, memberID int64) error {
+	return lm.WithContext(ctx).ReserveBook(bookID, memberID)
+}
+
+func (lm *LibraryManager) CancelReservationCtx(ctx context.Context, bookID, memberID int64) error {
+	return lm.WithContext(ctx).CancelReservation(bookID, memberID)
+}
+
+func (lm *LibraryManager) GetMemberCtx(ctx context.Context, id int64) (*Member, error) {
+	return lm.WithContext(ctx).GetMember(id)
+}
+
+func (lm *LibraryManager) AuthenticateMemberCtx(ctx context.Context, memberID int64, password string) error {
+	return lm.WithContext(ctx).AuthenticateMember(memberID, password)
+}
+
+func (lm *LibraryManager) IssueTokenCtx(ctx context.Context, memberID int64, scope string) (string, error) {
+	return lm.WithContext(ctx).IssueToken(memberID, scope)
+}
+
+func (lm *LibraryManager) AuthenticateTokenCtx(ctx context.Context, token string) (int64, error) {
+	return lm.WithContext(ctx).AuthenticateToken(token)
+}
+
+func (lm *LibraryManager) CapabilitiesCtx(ctx context.Context) ([]Capability, error) {
+	return lm.WithContext(ctx).Capabilities()
+}
+
+func (lm *LibraryManager) VersionInfoCtx(ctx context.Context) (*VersionInfo, error) {
+	return lm.WithContext(ctx).VersionInfo()
+}
+
+func (lm *LibraryManager) AddBooksCtx(ctx context.Context, books []BatchBook, staffID int64) ([]BatchResult, error) {
+	return lm.WithContext(ctx).AddBooks(books, staffID)
+}
+
+func (lm *LibraryManager) UpsertMembersCtx(ctx context.Context, members []BatchMember) ([]BatchResult, error) {
+	return lm.WithContext(ctx).UpsertMembers(members)
+}
+
+func (lm *LibraryManager) ApplyCirculationCtx(ctx context.Context, events []CirculationEvent) ([]BatchResult, error) {
+	return lm.WithContext(ctx).ApplyCirculation(events)
+}
@@ -0,0 +1,71 @@
+package library
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestContextCancellation(t *testing.T) {
+	db := tempDB(t)
+	bookID, _ := db.AddBook("Dune", "Herbert", "content")
+	alice, _ := db.AddMember("Alice", "password1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := db.GetBookCtx(ctx, bookID); !errors.Is(err, context.Canceled) {
+		t.Fatalf("read with a cancelled context: %v", err)
+	}
+	if err := db.CheckoutBookCtx(ctx, bookID, alice); !errors.Is(err, context.Canceled) {
+		t.Fatalf("checkout with a cancelled context: %v", err)
+	}
+
+	// Cancelled partway, a transaction's writes are rolled back
+	ctx, cancel = context.WithCancel(context.Background())
+	err := db.WithContext(ctx).inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`UPDATE books SET title = 'Changed' WHERE id=?`, bookID); err != nil {
+			return err
+		}
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) && !errors.Is(err, sql.ErrTxDone) {
+		t.Fatalf("transaction cancelled before commit: %v", err)
+	}
+	if b, _ := db.GetBook(bookID); b.Title != "Dune" || !b.Available {
+		t.Fatalf("book after cancelled writes = %+v", b)
+	}
+
+	// The original is untouched by its views' contexts
+	if err := db.CheckoutBook(bookID, alice); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+}
+
+func TestContextDeadlineWaitingForWriter(t *testing.T) {
+	db := tempDB(t)
+	bookID, _ := db.AddBook("Dune", "Herbert", "content")
+	alice, _ := db.AddMember("Alice", "password1")
+
+	var events int
+	db.OnCheckout(func(Event) { events++ })
+
+	// Another write transaction holds the lock past the deadline
+	db.writeLock <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := db.CheckoutBookCtx(ctx, bookID, alice); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("checkout behind a busy writer: %v", err)
+	}
+	<-db.writeLock
+
+	// A view shares the original's subscribers
+	if err := db.CheckoutBookCtx(context.Background(), bookID, alice); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	if events != 1 {
+		t.Fatalf("%d checkout events, want 1", events)
+	}
+}
@@ -0,0 +1,124 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Each book record is one copy on the shelf; copies of a title are records
+// that share its title, author and details. FindTitleDuplicates lets the
+// desk notice a title it already has before typing it in again, and
+// AddCopy adds a copy without retyping anything.
+
+// titleMatchLimit caps the near-identical titles FindTitleDuplicates returns.
+const titleMatchLimit = 5
+
+// titleKey reduces a title to the words that identify it: lower case,
+// without punctuation or a leading article, so "The Hobbit" and "hobbit"
+// compare equal.
+func titleKey(title string) string {
+	var words []string
+	for _, w := range splitWords(title) {
+		words = append(words, w.folded)
+	}
+	if len(words) > 1 && (words[0] == "the" || words[0] == "a" || words[0] == "an") {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// FindTitleDuplicates returns the books whose titles are the same as title
+// or nearly so, closest first and without their content. A title of eight
+// letters or more may differ by a typo for each eight.
+func (d *Database) FindTitleDuplicates(title string) ([]*Book, error) {
+	key := titleKey(title)
+	if key == "" {
+		return nil, nil
+	}
+	allowed := len([]rune(key)) / 8
+
+	rows, err := d.db.Query(`SELECT id, title, author FROM books ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	type match struct {
+		book     *Book
+		distance int
+	}
+	var matches []match
+	for rows.Next() {
+		var b Book
+		if err := rows.Scan(&b.ID, &b.Title, &b.Author); err != nil {
+			return nil, err
+		}
+		other := titleKey(b.Title)
+		// Lengths further apart than the allowance can't be close enough
+		if diff := len([]rune(other)) - len([]rune(key)); diff > allowed || -diff > allowed {
+			continue
+		}
+		if dist := editDistance(key, other); dist <= allowed {
+			matches = append(matches, match{&b, dist})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].distance < matches[j].distance })
+	var books []*Book
+	for _, m := range matches {
+		if len(books) == titleMatchLimit {
+			break
+		}
+		books = append(books, m.book)
+	}
+	return books, nil
+}
+
+// AddCopy adds another copy of bookID: a new record with its title,
+// author, text, publication details, item type, lending rules, replacement
+// cost and tags. Digital licenses belong to the title and are not copied.
+func (d *Database) AddCopy(bookID int64) (int64, error) {
+	book, err := d.GetBook(bookID)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("book not found")
+	}
+	if err != nil {
+		return 0, err
+	}
+	// The copy gets its own text, even from the content store, so changing
+	// one copy's never touches the other's
+	var ref sql.NullString
+	if err := d.db.QueryRow(`SELECT content_ref FROM books WHERE id=?`, bookID).Scan(&ref); err != nil {
+		return 0, err
+	}
+	content, err := d.resolveContent(book.Content, ref)
+	if err != nil {
+		return 0, err
+	}
+	id, err := d.AddBookWithMetadata(book.Title, book.Author, content, book.BookMetadata)
+	if err != nil {
+		return 0, err
+	}
+	_, err = d.db.Exec(`UPDATE books SET (item_type, non_circulating, replacement_cost_cents) =
+	                      (SELECT item_type, non_circulating, replacement_cost_cents FROM books WHERE id=?)
+	                    WHERE id=?`, bookID, id)
+	if err != nil {
+		return id, err
+	}
+	_, err = d.db.Exec(`INSERT INTO book_tags(book_id, tag_id) SELECT ?, tag_id FROM book_tags WHERE book_id=?`, id, bookID)
+	return id, err
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) FindTitleDuplicates(title string) ([]*Book, error) {
+	return lm.db.FindTitleDuplicates(title)
+}
+
+func (lm *LibraryManager) AddCopy(bookID int64) (int64, error) {
+	return lm.db.AddCopy(bookID)
+}
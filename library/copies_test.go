@@ -0,0 +1,62 @@
+package library
+
+import "testing"
+
+func TestFindTitleDuplicates(t *testing.T) {
+	db := tempDB(t)
+	orwell, _ := db.AddBook("1984", "George Orwell", "")
+	hobbit, _ := db.AddBook("The Hobbit", "J.R.R. Tolkien", "")
+	karamazov, _ := db.AddBook("The Brothers Karamazov", "Fyodor Dostoevsky", "")
+	db.AddBook("1985", "Anthony Burgess", "")
+
+	for _, tc := range []struct {
+		title string
+		want  []int64
+	}{
+		{"1984", []int64{orwell}},
+		{"  hobbit!", []int64{hobbit}},
+		{"The Brothers Karamazof", []int64{karamazov}},
+		{"1986", nil},
+		{"Hobbits", nil},
+		{"", nil},
+	} {
+		dups, err := db.FindTitleDuplicates(tc.title)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.title, err)
+		}
+		var got []int64
+		for _, b := range dups {
+			got = append(got, b.ID)
+		}
+		if len(got) != len(tc.want) || len(got) > 0 && got[0] != tc.want[0] {
+			t.Errorf("%q: got %v, want %v", tc.title, got, tc.want)
+		}
+	}
+}
+
+func TestAddCopy(t *testing.T) {
+	db := tempDB(t)
+	original, _ := db.AddBookWithMetadata("1984", "George Orwell", "War is peace.", BookMetadata{ISBN: "9780451524935", PublicationYear: 1949})
+	db.TagBook(original, "dystopia")
+	db.SetReplacementCost(original, 1500)
+
+	copyID, err := db.AddCopy(original)
+	if err != nil || copyID == original {
+		t.Fatalf("add copy: %d %v", copyID, err)
+	}
+	book, _ := db.GetBook(copyID)
+	if book.Title != "1984" || book.Content != "War is peace." || book.ISBN != "9780451524935" || book.PublicationYear != 1949 || !book.Available {
+		t.Fatalf("copy differs from the original: %+v", book)
+	}
+	if tags, _ := db.GetBookTags(copyID); len(tags) != 1 || tags[0] != "dystopia" {
+		t.Fatalf("expected the tags copied, got %v", tags)
+	}
+	var cost int64
+	db.db.QueryRow(`SELECT replacement_cost_cents FROM books WHERE id=?`, copyID).Scan(&cost)
+	if cost != 1500 {
+		t.Fatalf("expected the replacement cost copied, got %d", cost)
+	}
+	if _, err := db.AddCopy(999); err == nil {
+		t.Fatalf("expected copying an unknown book to fail")
+	}
+}
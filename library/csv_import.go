@@ -0,0 +1,278 @@
+package library
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImportReport summarizes a CSV import. Rows are validated one at a time:
+// good rows are added, and the rest are listed with their line number and
+// the reason they were left out.
+type ImportReport struct {
+	Added      []int64        // IDs of the new records, in file order
+	Duplicates []*ImportIssue // rows matching an existing record or an earlier row
+	Rejected   []*ImportIssue // rows that failed validation
+}
+
+// ImportIssue is a row an import left out.
+type ImportIssue struct {
+	Line   int
+	Reason string
+}
+
+func (r *ImportReport) String() string {
+	return fmt.Sprintf("%d added, %s skipped, %d rejected",
+		len(r.Added), plural(len(r.Duplicates), "duplicate"), len(r.Rejected))
+}
+
+// csvTable reads a CSV file with a header row naming its columns, in any
+// order and case. required columns must be present, and every column must be
+// one of required or optional.
+type csvTable struct {
+	r       *csv.Reader
+	columns map[string]int
+	row     []string
+}
+
+func newCSVTable(r io.Reader, required, optional []string) (*csvTable, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("empty file: expected a header row (%s)", strings.Join(required, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, c := range append(append([]string{}, required...), optional...) {
+		known[c] = true
+	}
+	t := &csvTable{r: cr, columns: map[string]int{}}
+	for i, h := range header {
+		name := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if !known[name] {
+			return nil, fmt.Errorf("unknown column %q (expected %s)", h, strings.Join(append(required, optional...), ", "))
+		}
+		t.columns[name] = i
+	}
+	for _, c := range required {
+		if _, ok := t.columns[c]; !ok {
+			return nil, fmt.Errorf("missing required column %q", c)
+		}
+	}
+	return t, nil
+}
+
+// next reads the following row and reports its line number; it returns
+// io.EOF at the end.
+func (t *csvTable) next() (int, error) {
+	row, err := t.r.Read()
+	if err != nil {
+		return 0, err
+	}
+	t.row = row
+	line, _ := t.r.FieldPos(0)
+	return line, nil
+}
+
+// get returns the named column of the current row, trimmed, or "".
+func (t *csvTable) get(column string) string {
+	i, ok := t.columns[column]
+	if !ok || i >= len(t.row) {
+		return ""
+	}
+	return strings.TrimSpace(t.row[i])
+}
+
+// readRows calls add for each row of t, sorting failures into the report.
+// add returns a duplicateRow error for a duplicate and any other error for
+// an invalid row. A malformed CSV line is rejected and reading goes on;
+// other read errors end the import.
+func readRows(t *csvTable, add func(line int) (int64, error)) (*ImportReport, error) {
+	report := &ImportReport{}
+	for {
+		line, err := t.next()
+		if err == io.EOF {
+			return report, nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			report.Rejected = append(report.Rejected, &ImportIssue{Line: parseErr.Line, Reason: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+		id, err := add(line)
+		var dup duplicateRow
+		switch {
+		case errors.As(err, &dup):
+			report.Duplicates = append(report.Duplicates, &ImportIssue{Line: line, Reason: dup.Error()})
+		case err != nil:
+			report.Rejected = append(report.Rejected, &ImportIssue{Line: line, Reason: err.Error()})
+		default:
+			report.Added = append(report.Added, id)
+		}
+	}
+}
+
+// duplicateRow explains why an import row was taken for a duplicate.
+type duplicateRow string
+
+func (e duplicateRow) Error() string { return string(e) }
+
+// ImportBooksCSV adds the books listed in r. The header names the columns:
+// title and author are required; item_type (default "book"),
+// non_circulating (true/false), file (a text file with the book's content)
+// and the publication details isbn, publisher, publication_year, language
+// and page_count are optional. Relative file paths are taken from the current
+// directory. A book whose title and author match one already in the
+// catalog, or an earlier row, is skipped as a duplicate.
+func (d *Database) ImportBooksCSV(r io.Reader) (*ImportReport, error) {
+	t, err := newCSVTable(r, []string{"title", "author"}, []string{"item_type", "non_circulating", "file",
+		"isbn", "publisher", "publication_year", "language", "page_count"})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	rows, err := d.db.Query(`SELECT title, author FROM books`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var title, author string
+		if err := rows.Scan(&title, &author); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		seen[bookKey(title, author)] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return readRows(t, func(line int) (int64, error) {
+		title, author := t.get("title"), t.get("author")
+		if title == "" || author == "" {
+			return 0, fmt.Errorf("title and author are required")
+		}
+		if seen[bookKey(title, author)] {
+			return 0, duplicateRow(fmt.Sprintf("%q by %s is already in the catalog", title, author))
+		}
+		itemType := t.get("item_type")
+		if itemType == "" {
+			itemType = "book"
+		}
+		if _, err := d.GetItemType(itemType); err != nil {
+			return 0, err
+		}
+		nonCirculating := false
+		if v := t.get("non_circulating"); v != "" {
+			if nonCirculating, err = strconv.ParseBool(v); err != nil {
+				return 0, fmt.Errorf("non_circulating must be true or false, not %q", v)
+			}
+		}
+		meta := BookMetadata{ISBN: t.get("isbn"), Publisher: t.get("publisher"), Language: t.get("language")}
+		for _, f := range []struct {
+			column string
+			v      *int
+		}{{"publication_year", &meta.PublicationYear}, {"page_count", &meta.PageCount}} {
+			if v := t.get(f.column); v != "" {
+				if *f.v, err = strconv.Atoi(v); err != nil {
+					return 0, fmt.Errorf("%s must be a number, not %q", f.column, v)
+				}
+			}
+		}
+		if meta, err = meta.normalize(); err != nil {
+			return 0, err
+		}
+		content := ""
+		if path := t.get("file"); path != "" {
+			data, err := os.ReadFile(filepath.Clean(path))
+			if err != nil {
+				return 0, fmt.Errorf("read content: %w", err)
+			}
+			content = string(data)
+		}
+
+		id, err := d.AddBookWithMetadata(title, author, content, meta)
+		if err != nil {
+			return 0, err
+		}
+		if itemType != "book" || nonCirculating {
+			if _, err := d.db.Exec(`UPDATE books SET item_type=?, non_circulating=? WHERE id=?`, itemType, nonCirculating, id); err != nil {
+				return id, err
+			}
+		}
+		seen[bookKey(title, author)] = true
+		return id, nil
+	})
+}
+
+func bookKey(title, author string) string {
+	return strings.ToLower(strings.TrimSpace(title)) + "\x00" + strings.ToLower(strings.TrimSpace(author))
+}
+
+// ImportMembersCSV adds the members listed in r. The header names the
+// columns: name is required; tier (default adult) and expiry_date
+// (YYYY-MM-DD; default a standard term from today) are optional. Members are
+// imported without a password, so staff set one with reset password before
+// they can sign in. Names are unique, so a name already in use is skipped as
+// a duplicate.
+func (d *Database) ImportMembersCSV(r io.Reader) (*ImportReport, error) {
+	t, err := newCSVTable(r, []string{"name"}, []string{"tier", "expiry_date"})
+	if err != nil {
+		return nil, err
+	}
+	return readRows(t, func(line int) (int64, error) {
+		name := t.get("name")
+		if name == "" {
+			return 0, fmt.Errorf("member name cannot be empty")
+		}
+		var exists int
+		if err := d.db.QueryRow(`SELECT COUNT(*) FROM members WHERE name=?`, name).Scan(&exists); err != nil {
+			return 0, err
+		}
+		if exists > 0 {
+			return 0, duplicateRow(fmt.Sprintf("a member named %q already exists", name))
+		}
+		tier := t.get("tier")
+		if tier == "" {
+			tier = TierAdult
+		}
+		if _, err := d.GetTierPolicy(tier); err != nil {
+			return 0, err
+		}
+		expiry := time.Now().AddDate(0, 0, DefaultMembershipDays)
+		if v := t.get("expiry_date"); v != "" {
+			if expiry, err = time.ParseInLocation("2006-01-02", v, d.zone()); err != nil {
+				return 0, fmt.Errorf("expiry_date must be YYYY-MM-DD, not %q", v)
+			}
+		}
+		res, err := d.db.Exec(`INSERT INTO members(name, expiry_time, tier) VALUES(?,?,?)`, name, sqlTime(&expiry), tier)
+		if err != nil {
+			return 0, err
+		}
+		return res.LastInsertId()
+	})
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) ImportBooksCSV(r io.Reader) (*ImportReport, error) {
+	return lm.db.ImportBooksCSV(r)
+}
+
+func (lm *LibraryManager) ImportMembersCSV(r io.Reader) (*ImportReport, error) {
+	return lm.db.ImportMembersCSV(r)
+}
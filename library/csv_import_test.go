@@ -0,0 +1,67 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImportBooksCSV(t *testing.T) {
+	db := tempDB(t)
+	db.AddBook("Dune", "Frank Herbert", "")
+	text := filepath.Join(t.TempDir(), "emma.txt")
+	os.WriteFile(text, []byte("It is a truth universally acknowledged"), 0o644)
+
+	csvData := "Title,Author,File,non_circulating\n" +
+		"Emma,Jane Austen," + text + ",\n" +
+		"dune , frank herbert,,\n" +
+		",Nobody,,\n" +
+		"Atlas,Rand McNally,,true\n" +
+		"Ghost,Someone,/no/such/file.txt,\n" +
+		"EMMA,jane austen,,\n"
+	report, err := db.ImportBooksCSV(strings.NewReader(csvData))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(report.Added) != 2 || len(report.Duplicates) != 2 || len(report.Rejected) != 2 {
+		t.Fatalf("unexpected report: %s %+v %+v", report, report.Duplicates, report.Rejected)
+	}
+	if report.Duplicates[0].Line != 3 || report.Rejected[0].Line != 4 || report.Duplicates[1].Line != 7 {
+		t.Fatalf("wrong line numbers: %+v %+v", report.Duplicates, report.Rejected)
+	}
+
+	emma, _ := db.GetBook(report.Added[0])
+	atlas, _ := db.GetBook(report.Added[1])
+	if emma.Content != "It is a truth universally acknowledged" || !atlas.NonCirculating {
+		t.Fatalf("unexpected books: %+v %+v", emma, atlas)
+	}
+
+	if _, err := db.ImportBooksCSV(strings.NewReader("title,isbn\nX,1\n")); err == nil {
+		t.Fatalf("expected unknown column to be refused")
+	}
+	if _, err := db.ImportBooksCSV(strings.NewReader("title\nX\n")); err == nil {
+		t.Fatalf("expected missing author column to be refused")
+	}
+}
+
+func TestImportMembersCSV(t *testing.T) {
+	db := tempDB(t)
+	db.AddMember("Alice", "password")
+
+	report, err := db.ImportMembersCSV(strings.NewReader(
+		"name,tier,expiry_date\nBob,child,2030-01-31\nAlice,,\nCarol,pirate,\nDan,,31/01/2030\nErin,,\n"))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(report.Added) != 2 || len(report.Duplicates) != 1 || len(report.Rejected) != 2 {
+		t.Fatalf("unexpected report: %s", report)
+	}
+	bob, err := db.GetMember(report.Added[0])
+	if err != nil || bob.Tier != TierChild || bob.ExpiryTime == nil || bob.ExpiryTime.Format("2006-01-02") != "2030-01-31" {
+		t.Fatalf("unexpected member: %+v %v", bob, err)
+	}
+	if err := db.AuthenticateMember(bob.ID, ""); err == nil {
+		t.Fatalf("imported member should not be able to sign in before a password is set")
+	}
+}
@@ -2,12 +2,16 @@ package library
 
 import (
 	"bufio"
+	"context"
 	"database/sql"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	_ "github.com/mattn/go-sqlite3"
 	"golang.org/x/crypto/bcrypt"
@@ -15,10 +19,68 @@ import (
 
 // Database provides high-level helpers around a SQLite connection.
 type Database struct {
-	db *sql.DB
+	// db runs queries under the context the Database is bound to; see
+	// WithContext.
+	db conn
 
 	addBookStmt   *sql.Stmt
 	addMemberStmt *sql.Stmt
+
+	// lockerPickup puts held books in the pickup lockers, opened with a
+	// one-time code.
+	lockerPickup bool
+
+	// digitalLoanDays overrides defaultDigitalLoanDays when positive.
+	digitalLoanDays int
+
+	// holdPickupDays overrides defaultHoldPickupDays when positive.
+	holdPickupDays int
+
+	// loanDays overrides defaultLoanDays when positive.
+	loanDays int
+
+	// fineCentsPerDay is the overdue fine when the borrower's tier is
+	// unknown; it starts at defaultFineCentsPerDay.
+	fineCentsPerDay int64
+
+	// passwordCost overrides bcryptCost when positive.
+	passwordCost int
+
+	// holdPolicy is what a returned book someone is waiting for does; ""
+	// means HoldPolicyShelf.
+	holdPolicy string
+
+	// location is the zone dates in notices are written in; nil means the
+	// machine's local zone.
+	location *time.Location
+
+	// clock tells the time for circulation; nil means the wall clock.
+	clock Clock
+
+	// events delivers circulation events to subscribers.
+	events *eventBus
+
+	// capabilities holds the optional subsystems registered by the caller.
+	capabilities *capabilityRegistry
+
+	// Optional caches of book metadata and content chunks; nil when disabled.
+	metaCache  *lruCache[int64, *bookMeta]
+	chunkCache *lruCache[chunkKey, string]
+	// retry governs re-running write transactions that hit SQLITE_BUSY.
+	retry RetryPolicy
+	// writeLock, holding a token while a write transaction runs, serializes
+	// this process's writers, which SQLite would otherwise make wait on each
+	// other's locks; other processes are waited out through busy_timeout and
+	// retry. A channel rather than a mutex so that waiting for it can be
+	// cancelled.
+	writeLock chan struct{}
+
+	path       string      // database file, for the WAL archiver
+	walArchive *walArchive // nil unless WAL archiving is enabled
+
+	// Optional home for long book texts; see SetContentStore.
+	contentStore     ObjectStore
+	inlineContentMax int
 }
 
 // NewDatabase opens (or creates) the SQLite database at dbPath, applies schema
@@ -31,8 +93,11 @@ func NewDatabase(dbPath string) (*Database, error) {
 		}
 	}
 
-	// Enable busy_timeout and foreign keys.
-	dsn := fmt.Sprintf("file:%s?_busy_timeout=5000&_foreign_keys=1", dbPath)
+	// Enable busy_timeout and foreign keys. In WAL mode readers don't block
+	// the writer or each other, and write transactions take the write lock
+	// when they begin, so a busy writer is waited for instead of failing
+	// when a reading transaction tries to upgrade.
+	dsn := fmt.Sprintf("file:%s?_busy_timeout=5000&_foreign_keys=1&_journal_mode=WAL&_synchronous=NORMAL&_txlock=immediate", dbPath)
 	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("open sqlite: %w", err)
@@ -43,7 +108,15 @@ func NewDatabase(dbPath string) (*Database, error) {
 		return nil, err
 	}
 
-	database := &Database{db: db}
+	database := &Database{
+		db:              conn{DB: db, ctx: context.Background()},
+		events:          &eventBus{},
+		capabilities:    &capabilityRegistry{},
+		writeLock:       make(chan struct{}, 1),
+		retry:           DefaultRetryPolicy,
+		fineCentsPerDay: defaultFineCentsPerDay,
+		path:            dbPath,
+	}
 	if err := database.prepareStatements(); err != nil {
 		db.Close()
 		return nil, err
@@ -59,6 +132,10 @@ func (d *Database) Close() error {
 	if d.addMemberStmt != nil {
 		d.addMemberStmt.Close()
 	}
+	// Closing checkpoints and removes the WAL, so archive it first
+	if d.walArchive != nil {
+		d.ArchiveWAL(time.Now())
+	}
 	return d.db.Close()
 }
 
@@ -66,7 +143,55 @@ func (d *Database) Close() error {
 // Schema migration with proper password support
 // ---------------------------------------------------------------------------
 
-const schemaVersion = 3
+// migrations lists every schema migration in order; migrations[i] upgrades the
+// schema from version i to version i+1.
+var migrations = []func(*sql.DB) error{
+	applyMigration1,
+	applyMigration2,
+	applyMigration3,
+	applyMigration4,
+	applyMigration5,
+	applyMigration6,
+	applyMigration7,
+	applyMigration8,
+	applyMigration9,
+	applyMigration10,
+	applyMigration11,
+	applyMigration12,
+	applyMigration13,
+	applyMigration14,
+	applyMigration15,
+	applyMigration16,
+	applyMigration17,
+	applyMigration18,
+	applyMigration19,
+	applyMigration20,
+	applyMigration21,
+	applyMigration22,
+	applyMigration23,
+	applyMigration24,
+	applyMigration25,
+	applyMigration26,
+	applyMigration27,
+	applyMigration28,
+	applyMigration29,
+	applyMigration30,
+	applyMigration31,
+	applyMigration32,
+	applyMigration33,
+	applyMigration34,
+	applyMigration35,
+	applyMigration36,
+	applyMigration37,
+	applyMigration38,
+	applyMigration39,
+	applyMigration40,
+	applyMigration41,
+	applyMigration42,
+	applyMigration43,
+}
+
+var schemaVersion = len(migrations)
 
 func applyMigrations(db *sql.DB) error {
 	// Create schema_version table if it doesn't exist
@@ -84,18 +209,8 @@ func applyMigrations(db *sql.DB) error {
 	}
 
 	// Apply migrations in sequence
-	if currentVersion < 1 {
-		if err := applyMigration1(db); err != nil {
-			return err
-		}
-	}
-	if currentVersion < 2 {
-		if err := applyMigration2(db); err != nil {
-			return err
-		}
-	}
-	if currentVersion < 3 {
-		if err := applyMigration3(db); err != nil {
+	for v := currentVersion; v < len(migrations); v++ {
+		if err := migrations[v](db); err != nil {
 			return err
 		}
 	}
@@ -200,13 +315,861 @@ func applyMigration3(db *sql.DB) error {
 	return nil
 }
 
+func applyMigration4(db *sql.DB) error {
+	// Track due dates on checkouts and remember which reminders were sent
+	dueSchema := `
+		ALTER TABLE checkouts ADD COLUMN due_time DATETIME;
+
+		-- Backfill open loans with the default loan period
+		UPDATE checkouts SET due_time = datetime(checkout_time, '+14 days') WHERE due_time IS NULL;
+
+		CREATE TABLE IF NOT EXISTS reminders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			checkout_id INTEGER NOT NULL,
+			kind TEXT NOT NULL,
+			sent_time DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (checkout_id, kind),
+			FOREIGN KEY (checkout_id) REFERENCES checkouts(id)
+		);
+	`
+	if _, err := db.Exec(dueSchema); err != nil {
+		return fmt.Errorf("apply migration 4: %w", err)
+	}
+	return nil
+}
+
+func applyMigration5(db *sql.DB) error {
+	// In-app notification inbox
+	inboxSchema := `
+		CREATE TABLE IF NOT EXISTS notifications (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			member_id INTEGER NOT NULL,
+			kind TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			body TEXT NOT NULL DEFAULT '',
+			created_time DATETIME DEFAULT CURRENT_TIMESTAMP,
+			read_time DATETIME,
+			FOREIGN KEY (member_id) REFERENCES members(id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications(member_id, read_time);
+	`
+	if _, err := db.Exec(inboxSchema); err != nil {
+		return fmt.Errorf("apply migration 5: %w", err)
+	}
+	return nil
+}
+
+func applyMigration6(db *sql.DB) error {
+	// Staff accounts are members with the admin flag set
+	adminSchema := `
+		ALTER TABLE members ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT 0;
+	`
+	if _, err := db.Exec(adminSchema); err != nil {
+		return fmt.Errorf("apply migration 6: %w", err)
+	}
+	return nil
+}
+
+func applyMigration7(db *sql.DB) error {
+	// Loan status for disputes: active, claims_returned, lost, returned
+	statusSchema := `
+		ALTER TABLE checkouts ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+		ALTER TABLE checkouts ADD COLUMN claim_time DATETIME;
+
+		UPDATE checkouts SET status = 'returned' WHERE return_time IS NOT NULL;
+	`
+	if _, err := db.Exec(statusSchema); err != nil {
+		return fmt.Errorf("apply migration 7: %w", err)
+	}
+	return nil
+}
+
+func applyMigration8(db *sql.DB) error {
+	// Fines ledger and per-title replacement prices
+	finesSchema := `
+		ALTER TABLE books ADD COLUMN replacement_cost_cents INTEGER;
+
+		CREATE TABLE IF NOT EXISTS fines (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			member_id INTEGER NOT NULL,
+			checkout_id INTEGER,
+			kind TEXT NOT NULL,
+			amount_cents INTEGER NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			created_time DATETIME DEFAULT CURRENT_TIMESTAMP,
+			reversed_time DATETIME,
+			FOREIGN KEY (member_id) REFERENCES members(id),
+			FOREIGN KEY (checkout_id) REFERENCES checkouts(id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_fines_member ON fines(member_id);
+	`
+	if _, err := db.Exec(finesSchema); err != nil {
+		return fmt.Errorf("apply migration 8: %w", err)
+	}
+	return nil
+}
+
+func applyMigration9(db *sql.DB) error {
+	// Staff-only notes attached to member records
+	notesSchema := `
+		CREATE TABLE IF NOT EXISTS member_notes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			member_id INTEGER NOT NULL,
+			author_id INTEGER NOT NULL,
+			note TEXT NOT NULL,
+			created_time DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (member_id) REFERENCES members(id),
+			FOREIGN KEY (author_id) REFERENCES members(id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_member_notes_member ON member_notes(member_id);
+	`
+	if _, err := db.Exec(notesSchema); err != nil {
+		return fmt.Errorf("apply migration 9: %w", err)
+	}
+	return nil
+}
+
+func applyMigration10(db *sql.DB) error {
+	// Account alerts shown to staff at checkout
+	alertsSchema := `
+		CREATE TABLE IF NOT EXISTS member_alerts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			member_id INTEGER NOT NULL,
+			author_id INTEGER NOT NULL,
+			message TEXT NOT NULL,
+			blocking BOOLEAN NOT NULL DEFAULT 0,
+			created_time DATETIME DEFAULT CURRENT_TIMESTAMP,
+			cleared_time DATETIME,
+			cleared_by INTEGER,
+			FOREIGN KEY (member_id) REFERENCES members(id),
+			FOREIGN KEY (author_id) REFERENCES members(id),
+			FOREIGN KEY (cleared_by) REFERENCES members(id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_member_alerts_member ON member_alerts(member_id, cleared_time);
+	`
+	if _, err := db.Exec(alertsSchema); err != nil {
+		return fmt.Errorf("apply migration 10: %w", err)
+	}
+	return nil
+}
+
+func applyMigration11(db *sql.DB) error {
+	// Membership expiry; existing members get a full term from today
+	expirySchema := `
+		ALTER TABLE members ADD COLUMN expiry_time DATETIME;
+		ALTER TABLE members ADD COLUMN expiry_notified BOOLEAN NOT NULL DEFAULT 0;
+
+		UPDATE members SET expiry_time = datetime('now', '+365 days') WHERE expiry_time IS NULL;
+	`
+	if _, err := db.Exec(expirySchema); err != nil {
+		return fmt.Errorf("apply migration 11: %w", err)
+	}
+	return nil
+}
+
+func applyMigration12(db *sql.DB) error {
+	// Membership tiers with their own circulation policies
+	tierSchema := `
+		CREATE TABLE IF NOT EXISTS membership_tiers (
+			name TEXT PRIMARY KEY,
+			loan_limit INTEGER NOT NULL,
+			loan_days INTEGER NOT NULL,
+			fine_cents_per_day INTEGER NOT NULL
+		);
+
+		INSERT OR IGNORE INTO membership_tiers(name, loan_limit, loan_days, fine_cents_per_day) VALUES
+			('adult', 10, 14, 25),
+			('child', 5, 21, 0),
+			('senior', 10, 21, 10),
+			('staff', 25, 28, 0);
+
+		ALTER TABLE members ADD COLUMN tier TEXT NOT NULL DEFAULT 'adult' REFERENCES membership_tiers(name);
+	`
+	if _, err := db.Exec(tierSchema); err != nil {
+		return fmt.Errorf("apply migration 12: %w", err)
+	}
+	return nil
+}
+
+func applyMigration13(db *sql.DB) error {
+	// Privacy-flagged member profile data, kept out of the members table so
+	// it is never loaded with ordinary member lookups
+	profileSchema := `
+		CREATE TABLE IF NOT EXISTS member_profiles (
+			member_id INTEGER PRIMARY KEY,
+			address TEXT NOT NULL DEFAULT '',
+			zip TEXT NOT NULL DEFAULT '',
+			age_group TEXT NOT NULL DEFAULT '',
+			updated_time DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (member_id) REFERENCES members(id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_member_profiles_zip ON member_profiles(zip);
+	`
+	if _, err := db.Exec(profileSchema); err != nil {
+		return fmt.Errorf("apply migration 13: %w", err)
+	}
+	return nil
+}
+
+func applyMigration14(db *sql.DB) error {
+	// One-time locker pickup codes for holds awaiting collection
+	pickupSchema := `
+		ALTER TABLE checkouts ADD COLUMN pickup_code TEXT;
+
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_checkouts_pickup_code ON checkouts(pickup_code);
+	`
+	if _, err := db.Exec(pickupSchema); err != nil {
+		return fmt.Errorf("apply migration 14: %w", err)
+	}
+	return nil
+}
+
+func applyMigration15(db *sql.DB) error {
+	// Reference (non-circulating) items and their in-library consultations
+	inLibrarySchema := `
+		ALTER TABLE books ADD COLUMN non_circulating BOOLEAN NOT NULL DEFAULT 0;
+
+		CREATE TABLE IF NOT EXISTS in_library_uses (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			book_id INTEGER NOT NULL,
+			use_time DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (book_id) REFERENCES books(id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_in_library_uses_time ON in_library_uses(use_time);
+	`
+	if _, err := db.Exec(inLibrarySchema); err != nil {
+		return fmt.Errorf("apply migration 15: %w", err)
+	}
+	return nil
+}
+
+func applyMigration16(db *sql.DB) error {
+	// Non-book item types with their own loan rules, per-item accessory
+	// checklists, and deposits held against each loan
+	itemSchema := `
+		CREATE TABLE IF NOT EXISTS item_types (
+			name TEXT PRIMARY KEY,
+			loan_days INTEGER,
+			fine_cents_per_day INTEGER,
+			deposit_cents INTEGER NOT NULL DEFAULT 0
+		);
+
+		INSERT OR IGNORE INTO item_types(name, loan_days, fine_cents_per_day, deposit_cents) VALUES
+			('book', NULL, NULL, 0),
+			('laptop', 7, 500, 10000),
+			('hotspot', 14, 100, 2500),
+			('board_game', 21, NULL, 0);
+
+		ALTER TABLE books ADD COLUMN item_type TEXT NOT NULL DEFAULT 'book' REFERENCES item_types(name);
+
+		CREATE TABLE IF NOT EXISTS item_accessories (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			book_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			FOREIGN KEY (book_id) REFERENCES books(id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_item_accessories_book ON item_accessories(book_id);
+
+		ALTER TABLE checkouts ADD COLUMN deposit_cents INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE checkouts ADD COLUMN deposit_status TEXT;
+		ALTER TABLE checkouts ADD COLUMN missing_accessories TEXT NOT NULL DEFAULT '';
+	`
+	if _, err := db.Exec(itemSchema); err != nil {
+		return fmt.Errorf("apply migration 16: %w", err)
+	}
+	return nil
+}
+
+func applyMigration17(db *sql.DB) error {
+	// Digital lending: licensed concurrent reads, separate from the physical copy
+	digitalSchema := `
+		ALTER TABLE books ADD COLUMN digital_licenses INTEGER NOT NULL DEFAULT 0;
+
+		CREATE TABLE IF NOT EXISTS digital_loans (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			book_id INTEGER NOT NULL,
+			member_id INTEGER NOT NULL,
+			start_time DATETIME DEFAULT CURRENT_TIMESTAMP,
+			expiry_time DATETIME NOT NULL,
+			return_time DATETIME,
+			FOREIGN KEY (book_id) REFERENCES books(id),
+			FOREIGN KEY (member_id) REFERENCES members(id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_digital_loans_book ON digital_loans(book_id, expiry_time);
+	`
+	if _, err := db.Exec(digitalSchema); err != nil {
+		return fmt.Errorf("apply migration 17: %w", err)
+	}
+	return nil
+}
+
+func applyMigration18(db *sql.DB) error {
+	// Remember which automatically returned digital loans members were told about
+	digitalReturnSchema := `
+		ALTER TABLE digital_loans ADD COLUMN return_notified BOOLEAN NOT NULL DEFAULT 0;
+	`
+	if _, err := db.Exec(digitalReturnSchema); err != nil {
+		return fmt.Errorf("apply migration 18: %w", err)
+	}
+	return nil
+}
+
+func applyMigration19(db *sql.DB) error {
+	// Last-modified time of each catalog record for HTTP conditional requests.
+	// SQLite can't add a column with a CURRENT_TIMESTAMP default, so triggers
+	// stamp new and changed rows instead.
+	updatedSchema := `
+		ALTER TABLE books ADD COLUMN updated_time DATETIME;
+
+		UPDATE books SET updated_time = CURRENT_TIMESTAMP;
+
+		CREATE TRIGGER IF NOT EXISTS books_touch_insert AFTER INSERT ON books BEGIN
+			UPDATE books SET updated_time = CURRENT_TIMESTAMP WHERE id = new.id;
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS books_touch_update AFTER UPDATE ON books
+		WHEN new.updated_time IS old.updated_time BEGIN
+			UPDATE books SET updated_time = CURRENT_TIMESTAMP WHERE id = new.id;
+		END;
+	`
+	if _, err := db.Exec(updatedSchema); err != nil {
+		return fmt.Errorf("apply migration 19: %w", err)
+	}
+	return nil
+}
+
+func applyMigration20(db *sql.DB) error {
+	// Indexes for the circulation queries, which otherwise scan whole tables
+	indexSchema := `
+		CREATE INDEX IF NOT EXISTS idx_checkouts_book_open ON checkouts(book_id, return_time);
+		CREATE INDEX IF NOT EXISTS idx_checkouts_member_open ON checkouts(member_id, return_time);
+		CREATE INDEX IF NOT EXISTS idx_reservations_queue ON reservations(book_id, fulfilled_time, reservation_time);
+		CREATE INDEX IF NOT EXISTS idx_books_available ON books(available);
+		CREATE INDEX IF NOT EXISTS idx_members_name ON members(name);
+	`
+	if _, err := db.Exec(indexSchema); err != nil {
+		return fmt.Errorf("apply migration 20: %w", err)
+	}
+	return nil
+}
+
+func applyMigration21(db *sql.DB) error {
+	// Switch the file to incremental auto-vacuum so maintenance can hand
+	// freed pages back to the filesystem; the mode only takes effect after
+	// one full VACUUM.
+	vacuumSchema := `
+		PRAGMA auto_vacuum = INCREMENTAL;
+		VACUUM;
+	`
+	if _, err := db.Exec(vacuumSchema); err != nil {
+		return fmt.Errorf("apply migration 21: %w", err)
+	}
+	return nil
+}
+
+func applyMigration22(db *sql.DB) error {
+	// API tokens, stored as SHA-256 hashes of the issued secret
+	tokenSchema := `
+		CREATE TABLE IF NOT EXISTS api_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			member_id INTEGER NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			created_time DATETIME DEFAULT CURRENT_TIMESTAMP,
+			revoked_time DATETIME,
+			FOREIGN KEY(member_id) REFERENCES members(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS idx_api_tokens_member ON api_tokens(member_id);
+	`
+	if _, err := db.Exec(tokenSchema); err != nil {
+		return fmt.Errorf("apply migration 22: %w", err)
+	}
+	return nil
+}
+
+func applyMigration23(db *sql.DB) error {
+	// Nightly snapshot configuration; no row means backups are off
+	backupSchema := `
+		CREATE TABLE IF NOT EXISTS backup_schedule (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			dir TEXT NOT NULL,
+			hour INTEGER NOT NULL CHECK (hour BETWEEN 0 AND 23),
+			keep_daily INTEGER NOT NULL,
+			keep_weekly INTEGER NOT NULL
+		);
+	`
+	if _, err := db.Exec(backupSchema); err != nil {
+		return fmt.Errorf("apply migration 23: %w", err)
+	}
+	return nil
+}
+
+func applyMigration24(db *sql.DB) error {
+	// Book text may live in a content store, referenced by content_ref. The
+	// search index becomes contentless (keyed by book ID) so it no longer
+	// keeps a second copy of every text; books with external content are
+	// indexed by the application instead of the triggers.
+	contentSchema := `
+		ALTER TABLE books ADD COLUMN content_ref TEXT;
+
+		DROP TRIGGER IF EXISTS books_fts_insert;
+		DROP TRIGGER IF EXISTS books_fts_update;
+		DROP TRIGGER IF EXISTS books_fts_delete;
+		DROP TABLE IF EXISTS books_fts;
+
+		CREATE VIRTUAL TABLE books_fts USING fts5(
+			title, author, content, content='', contentless_delete=1
+		);
+		INSERT INTO books_fts(rowid, title, author, content)
+		SELECT id, title, author, content FROM books;
+
+		CREATE TRIGGER books_fts_insert AFTER INSERT ON books WHEN new.content_ref IS NULL BEGIN
+			INSERT INTO books_fts(rowid, title, author, content) VALUES (new.id, new.title, new.author, new.content);
+		END;
+
+		CREATE TRIGGER books_fts_update AFTER UPDATE OF title, author, content, content_ref ON books
+		WHEN new.content_ref IS NULL BEGIN
+			UPDATE books_fts SET title = new.title, author = new.author, content = new.content WHERE rowid = new.id;
+		END;
+
+		CREATE TRIGGER books_fts_delete AFTER DELETE ON books BEGIN
+			DELETE FROM books_fts WHERE rowid = old.id;
+		END;
+	`
+	if _, err := db.Exec(contentSchema); err != nil {
+		return fmt.Errorf("apply migration 24: %w", err)
+	}
+	return nil
+}
+
+// applyMigration25 records when members last signed in, lets staff force a
+// password change, and starts the audit log.
+func applyMigration25(db *sql.DB) error {
+	securitySchema := `
+		ALTER TABLE members ADD COLUMN last_login_time DATETIME;
+		ALTER TABLE members ADD COLUMN must_change_password BOOLEAN NOT NULL DEFAULT 0;
+
+		-- No foreign key on actor_id: entries outlive the accounts they name
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			actor_id INTEGER,
+			action TEXT NOT NULL,
+			detail TEXT NOT NULL DEFAULT '',
+			created_time DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+	`
+	if _, err := db.Exec(securitySchema); err != nil {
+		return fmt.Errorf("apply migration 25: %w", err)
+	}
+	return nil
+}
+
+// applyMigration26 hash-chains the audit log and makes it append-only.
+// Entries written so far are chained in order before the guards go on.
+func applyMigration26(db *sql.DB) error {
+	chainSchema := `
+		ALTER TABLE audit_log ADD COLUMN prev_hash TEXT NOT NULL DEFAULT '';
+		ALTER TABLE audit_log ADD COLUMN hash TEXT NOT NULL DEFAULT '';
+	`
+	if _, err := db.Exec(chainSchema); err != nil {
+		return fmt.Errorf("apply migration 26: %w", err)
+	}
+	if err := chainAuditLog(db); err != nil {
+		return fmt.Errorf("apply migration 26: %w", err)
+	}
+	guardSchema := `
+		CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log BEGIN
+			SELECT RAISE(ABORT, 'audit log is append-only');
+		END;
+		CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log BEGIN
+			SELECT RAISE(ABORT, 'audit log is append-only');
+		END;
+	`
+	if _, err := db.Exec(guardSchema); err != nil {
+		return fmt.Errorf("apply migration 26: %w", err)
+	}
+	return nil
+}
+
+func applyMigration27(db *sql.DB) error {
+	// Erasure of members on request: the shared placeholder that keeps their
+	// anonymized circulation history, and a certificate for every erasure
+	forgetSchema := `
+		ALTER TABLE members ADD COLUMN placeholder BOOLEAN NOT NULL DEFAULT 0;
+
+		CREATE TABLE IF NOT EXISTS erasure_certificates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			member_id INTEGER NOT NULL,
+			actor_id INTEGER,
+			summary TEXT NOT NULL,
+			created_time DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+	`
+	if _, err := db.Exec(forgetSchema); err != nil {
+		return fmt.Errorf("apply migration 27: %w", err)
+	}
+	return nil
+}
+
+func applyMigration28(db *sql.DB) error {
+	// Family delegation: members a borrower allows to collect holds and
+	// return books for them, and who actually did so on each loan
+	delegateSchema := `
+		CREATE TABLE IF NOT EXISTS delegates (
+			owner_id INTEGER NOT NULL,
+			delegate_id INTEGER NOT NULL,
+			created_time DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (owner_id, delegate_id),
+			FOREIGN KEY (owner_id) REFERENCES members(id),
+			FOREIGN KEY (delegate_id) REFERENCES members(id)
+		);
+
+		ALTER TABLE checkouts ADD COLUMN picked_up_by INTEGER REFERENCES members(id);
+		ALTER TABLE checkouts ADD COLUMN returned_by INTEGER REFERENCES members(id);
+	`
+	if _, err := db.Exec(delegateSchema); err != nil {
+		return fmt.Errorf("apply migration 28: %w", err)
+	}
+	return nil
+}
+
+func applyMigration29(db *sql.DB) error {
+	// Curated collections (reading lists) of books in a chosen order, each
+	// with an optional blurb
+	collectionSchema := `
+		CREATE TABLE IF NOT EXISTS collections (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			description TEXT NOT NULL DEFAULT '',
+			created_time DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS collection_items (
+			collection_id INTEGER NOT NULL,
+			book_id INTEGER NOT NULL,
+			position INTEGER NOT NULL,
+			blurb TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (collection_id, book_id),
+			FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE,
+			FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
+		);
+	`
+	if _, err := db.Exec(collectionSchema); err != nil {
+		return fmt.Errorf("apply migration 29: %w", err)
+	}
+	return nil
+}
+
+func applyMigration30(db *sql.DB) error {
+	// Members' notes and highlights on book pages, with a full-text index of
+	// their text kept in step by triggers
+	annotationSchema := `
+		CREATE TABLE IF NOT EXISTS annotations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			member_id INTEGER NOT NULL,
+			book_id INTEGER NOT NULL,
+			page INTEGER NOT NULL,
+			kind TEXT NOT NULL CHECK (kind IN ('note', 'highlight')),
+			text TEXT NOT NULL,
+			created_time DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (member_id) REFERENCES members(id) ON DELETE CASCADE,
+			FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_annotations_member_book ON annotations(member_id, book_id, page);
+
+		CREATE VIRTUAL TABLE IF NOT EXISTS annotations_fts USING fts5(
+			text, content='annotations', content_rowid='id'
+		);
+
+		CREATE TRIGGER IF NOT EXISTS annotations_fts_insert AFTER INSERT ON annotations BEGIN
+			INSERT INTO annotations_fts(rowid, text) VALUES (new.id, new.text);
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS annotations_fts_delete AFTER DELETE ON annotations BEGIN
+			INSERT INTO annotations_fts(annotations_fts, rowid, text) VALUES ('delete', old.id, old.text);
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS annotations_fts_update AFTER UPDATE OF text ON annotations BEGIN
+			INSERT INTO annotations_fts(annotations_fts, rowid, text) VALUES ('delete', old.id, old.text);
+			INSERT INTO annotations_fts(rowid, text) VALUES (new.id, new.text);
+		END;
+	`
+	if _, err := db.Exec(annotationSchema); err != nil {
+		return fmt.Errorf("apply migration 30: %w", err)
+	}
+	return nil
+}
+
+func applyMigration31(db *sql.DB) error {
+	// Publication metadata on books; ISBNs are stored normalized (digits and
+	// a final X, no hyphens) so lookups match however they were typed
+	metadataSchema := `
+		ALTER TABLE books ADD COLUMN isbn TEXT NOT NULL DEFAULT '';
+		ALTER TABLE books ADD COLUMN publisher TEXT NOT NULL DEFAULT '';
+		ALTER TABLE books ADD COLUMN publication_year INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE books ADD COLUMN language TEXT NOT NULL DEFAULT '';
+		ALTER TABLE books ADD COLUMN page_count INTEGER NOT NULL DEFAULT 0;
+
+		CREATE INDEX IF NOT EXISTS idx_books_isbn ON books(isbn) WHERE isbn != '';
+	`
+	if _, err := db.Exec(metadataSchema); err != nil {
+		return fmt.Errorf("apply migration 31: %w", err)
+	}
+	return nil
+}
+
+func applyMigration32(db *sql.DB) error {
+	// A MinHash signature of each book's vocabulary, written with its text,
+	// for finding books with similar content
+	signatureSchema := `
+		CREATE TABLE IF NOT EXISTS book_signatures (
+			book_id INTEGER PRIMARY KEY,
+			minhash BLOB NOT NULL,
+			FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
+		);
+	`
+	if _, err := db.Exec(signatureSchema); err != nil {
+		return fmt.Errorf("apply migration 32: %w", err)
+	}
+	return nil
+}
+
+func applyMigration33(db *sql.DB) error {
+	// The search index's terms, per column, for spelling suggestions
+	vocabSchema := `
+		CREATE VIRTUAL TABLE IF NOT EXISTS books_fts_vocab USING fts5vocab(books_fts, 'col');
+	`
+	if _, err := db.Exec(vocabSchema); err != nil {
+		return fmt.Errorf("apply migration 33: %w", err)
+	}
+	return nil
+}
+
+func applyMigration34(db *sql.DB) error {
+	// Fulfilled reservations are held for a pickup window; holds already
+	// waiting get a full window from now
+	holdSchema := fmt.Sprintf(`
+		ALTER TABLE checkouts ADD COLUMN hold_expiry_time DATETIME;
+
+		UPDATE checkouts SET hold_expiry_time = datetime('now', '+%d days')
+		WHERE status = 'awaiting_pickup' AND return_time IS NULL;
+	`, defaultHoldPickupDays)
+	if _, err := db.Exec(holdSchema); err != nil {
+		return fmt.Errorf("apply migration 34: %w", err)
+	}
+	return nil
+}
+
+func applyMigration35(db *sql.DB) error {
+	// Optional email address for reminders, with a switch per kind of
+	// reminder; members start opted in to all of them
+	emailSchema := `
+		ALTER TABLE members ADD COLUMN email TEXT;
+		ALTER TABLE members ADD COLUMN email_due_soon INTEGER NOT NULL DEFAULT 1;
+		ALTER TABLE members ADD COLUMN email_overdue INTEGER NOT NULL DEFAULT 1;
+		ALTER TABLE members ADD COLUMN email_hold_ready INTEGER NOT NULL DEFAULT 1;
+	`
+	if _, err := db.Exec(emailSchema); err != nil {
+		return fmt.Errorf("apply migration 35: %w", err)
+	}
+	return nil
+}
+
+func applyMigration36(db *sql.DB) error {
+	// Subject tags and genres, many to many with books; names are stored
+	// lower-case so "Fantasy" and "fantasy" are one tag
+	tagSchema := `
+		CREATE TABLE IF NOT EXISTS tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE
+		);
+
+		CREATE TABLE IF NOT EXISTS book_tags (
+			book_id INTEGER NOT NULL,
+			tag_id INTEGER NOT NULL,
+			PRIMARY KEY (book_id, tag_id),
+			FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE,
+			FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS idx_book_tags_tag ON book_tags(tag_id);
+	`
+	if _, err := db.Exec(tagSchema); err != nil {
+		return fmt.Errorf("apply migration 36: %w", err)
+	}
+	return nil
+}
+
+func applyMigration37(db *sql.DB) error {
+	// Departed members are deactivated: they keep their history but can no
+	// longer sign in, borrow or reserve
+	activeSchema := `
+		ALTER TABLE members ADD COLUMN active BOOLEAN NOT NULL DEFAULT 1;
+	`
+	if _, err := db.Exec(activeSchema); err != nil {
+		return fmt.Errorf("apply migration 37: %w", err)
+	}
+	return nil
+}
+
+func applyMigration38(db *sql.DB) error {
+	// A hold waiting on the shelf is not a loan yet: it has no due date
+	// until it is collected, when the loan period starts
+	holdSchema := `
+		UPDATE checkouts SET due_time = NULL
+		WHERE status = 'awaiting_pickup' AND return_time IS NULL;
+	`
+	if _, err := db.Exec(holdSchema); err != nil {
+		return fmt.Errorf("apply migration 38: %w", err)
+	}
+	return nil
+}
+
+func applyMigration39(db *sql.DB) error {
+	// Where each member left off in each book, so the reader resumes there,
+	// and the pages they have bookmarked
+	readingSchema := `
+		CREATE TABLE IF NOT EXISTS reading_progress (
+			member_id INTEGER NOT NULL,
+			book_id INTEGER NOT NULL,
+			page INTEGER NOT NULL,
+			updated_time DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (member_id, book_id),
+			FOREIGN KEY (member_id) REFERENCES members(id) ON DELETE CASCADE,
+			FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS bookmarks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			member_id INTEGER NOT NULL,
+			book_id INTEGER NOT NULL,
+			page INTEGER NOT NULL,
+			label TEXT NOT NULL DEFAULT '',
+			created_time DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (member_id, book_id, page),
+			FOREIGN KEY (member_id) REFERENCES members(id) ON DELETE CASCADE,
+			FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
+		);
+	`
+	if _, err := db.Exec(readingSchema); err != nil {
+		return fmt.Errorf("apply migration 39: %w", err)
+	}
+	return nil
+}
+
+func applyMigration40(db *sql.DB) error {
+	// Whether moving up a reservation queue is emailed too, like the
+	// other reminders
+	if _, err := db.Exec(`ALTER TABLE members ADD COLUMN email_queue_moved INTEGER NOT NULL DEFAULT 1`); err != nil {
+		return fmt.Errorf("apply migration 40: %w", err)
+	}
+	return nil
+}
+
+// applyMigration41 records which member and book an audit entry is about,
+// so the log can be filtered by either.
+func applyMigration41(db *sql.DB) error {
+	auditSchema := `
+		ALTER TABLE audit_log ADD COLUMN member_id INTEGER;
+		ALTER TABLE audit_log ADD COLUMN book_id INTEGER;
+		CREATE INDEX IF NOT EXISTS idx_audit_log_member ON audit_log(member_id);
+		CREATE INDEX IF NOT EXISTS idx_audit_log_book ON audit_log(book_id);
+	`
+	if _, err := db.Exec(auditSchema); err != nil {
+		return fmt.Errorf("apply migration 41: %w", err)
+	}
+	return nil
+}
+
+// applyMigration42 remembers the idempotency keys of batch items already
+// applied, with their outcome.
+func applyMigration42(db *sql.DB) error {
+	batchSchema := `
+		CREATE TABLE IF NOT EXISTS batch_keys (
+			kind TEXT NOT NULL,
+			key TEXT NOT NULL,
+			status TEXT NOT NULL,
+			record_id INTEGER,
+			created_time DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY(kind, key)
+		);
+	`
+	if _, err := db.Exec(batchSchema); err != nil {
+		return fmt.Errorf("apply migration 42: %w", err)
+	}
+	return nil
+}
+
+// applyMigration43 moves book texts out of the books row into
+// book_contents, so that catalog queries never read them. The search
+// index follows the text into its new table.
+func applyMigration43(db *sql.DB) error {
+	contentsSchema := `
+		CREATE TABLE IF NOT EXISTS book_contents (
+			book_id INTEGER PRIMARY KEY REFERENCES books(id) ON DELETE CASCADE,
+			content TEXT NOT NULL DEFAULT ''
+		);
+		INSERT INTO book_contents(book_id, content) SELECT id, content FROM books WHERE content != '';
+
+		DROP TRIGGER IF EXISTS books_fts_insert;
+		DROP TRIGGER IF EXISTS books_fts_update;
+		ALTER TABLE books DROP COLUMN content;
+
+		CREATE TRIGGER books_fts_insert AFTER INSERT ON books WHEN new.content_ref IS NULL BEGIN
+			INSERT INTO books_fts(rowid, title, author, content) VALUES (new.id, new.title, new.author, '');
+		END;
+
+		CREATE TRIGGER books_fts_update AFTER UPDATE OF title, author, content_ref ON books
+		WHEN new.content_ref IS NULL BEGIN
+			UPDATE books_fts SET title = new.title, author = new.author,
+			       content = COALESCE((SELECT content FROM book_contents WHERE book_id = new.id), '')
+			WHERE rowid = new.id;
+		END;
+
+		-- The index is contentless, so every column is written on update
+		CREATE TRIGGER book_contents_fts_insert AFTER INSERT ON book_contents
+		WHEN EXISTS (SELECT 1 FROM books WHERE id = new.book_id AND content_ref IS NULL) BEGIN
+			UPDATE books_fts SET title = (SELECT title FROM books WHERE id = new.book_id),
+			       author = (SELECT author FROM books WHERE id = new.book_id), content = new.content
+			WHERE rowid = new.book_id;
+			UPDATE books SET updated_time = CURRENT_TIMESTAMP WHERE id = new.book_id;
+		END;
+
+		CREATE TRIGGER book_contents_fts_update AFTER UPDATE OF content ON book_contents
+		WHEN EXISTS (SELECT 1 FROM books WHERE id = new.book_id AND content_ref IS NULL) BEGIN
+			UPDATE books_fts SET title = (SELECT title FROM books WHERE id = new.book_id),
+			       author = (SELECT author FROM books WHERE id = new.book_id), content = new.content
+			WHERE rowid = new.book_id;
+			UPDATE books SET updated_time = CURRENT_TIMESTAMP WHERE id = new.book_id;
+		END;
+
+		CREATE TRIGGER book_contents_fts_delete AFTER DELETE ON book_contents
+		WHEN EXISTS (SELECT 1 FROM books WHERE id = old.book_id AND content_ref IS NULL) BEGIN
+			UPDATE books_fts SET title = (SELECT title FROM books WHERE id = old.book_id),
+			       author = (SELECT author FROM books WHERE id = old.book_id), content = ''
+			WHERE rowid = old.book_id;
+		END;
+	`
+	if _, err := db.Exec(contentsSchema); err != nil {
+		return fmt.Errorf("apply migration 43: %w", err)
+	}
+	return nil
+}
+
 func (d *Database) prepareStatements() error {
 	var err error
-	d.addBookStmt, err = d.db.Prepare(`INSERT INTO books(title, author, content) VALUES(?,?,?)`)
+	d.addBookStmt, err = d.db.Prepare(`INSERT INTO books(title, author) VALUES(?,?)`)
 	if err != nil {
 		return fmt.Errorf("prepare addBookStmt: %w", err)
 	}
-	d.addMemberStmt, err = d.db.Prepare(`INSERT INTO members(name, password_hash) VALUES(?,?)`)
+	d.addMemberStmt, err = d.db.Prepare(`INSERT INTO members(name, password_hash, expiry_time, tier) VALUES(?,?,datetime(?, ?),?)`)
 	if err != nil {
 		return fmt.Errorf("prepare addMemberStmt: %w", err)
 	}
@@ -223,6 +1186,16 @@ const (
 	minPasswordLength = 1  // Minimum length (can't be empty)
 )
 
+// SetBcryptCost sets the bcrypt cost of new password hashes; zero restores
+// the default. Existing hashes keep the cost they were made with.
+func (d *Database) SetBcryptCost(cost int) error {
+	if cost != 0 && (cost < bcrypt.MinCost || cost > bcrypt.MaxCost) {
+		return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	d.passwordCost = cost
+	return nil
+}
+
 // HashPassword securely hashes a password using bcrypt with proper validation
 func (d *Database) HashPassword(password string) (string, error) {
 	// Validate password length and content
@@ -238,7 +1211,11 @@ func (d *Database) HashPassword(password string) (string, error) {
 		return "", fmt.Errorf("password too long (maximum %d characters)", maxPasswordLength)
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	cost := bcryptCost
+	if d.passwordCost > 0 {
+		cost = d.passwordCost
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	if err != nil {
 		return "", fmt.Errorf("failed to hash password: %w", err)
 	}
@@ -255,9 +1232,10 @@ func (d *Database) CheckPassword(password, hash string) bool {
 func (d *Database) AuthenticateMember(memberID int64, password string) error {
 	var storedHash sql.NullString
 	var memberName string
+	var active bool
 
-	err := d.db.QueryRow(`SELECT name, password_hash FROM members WHERE id = ?`, memberID).
-		Scan(&memberName, &storedHash)
+	err := d.db.QueryRow(`SELECT name, password_hash, active FROM members WHERE id = ?`, memberID).
+		Scan(&memberName, &storedHash, &active)
 
 	if err == sql.ErrNoRows {
 		// Generic error message - don't reveal if member exists
@@ -277,55 +1255,103 @@ func (d *Database) AuthenticateMember(memberID int64, password string) error {
 		// Generic error message - don't reveal which part failed
 		return fmt.Errorf("authentication failed: invalid member ID or password")
 	}
+	if !active {
+		return fmt.Errorf("member account %d is deactivated", memberID)
+	}
 
-	return nil
+	return d.recordLogin(memberID)
 }
 
 // ResetMemberPassword securely updates a member's password with proper validation
 func (d *Database) ResetMemberPassword(memberID int64, newPassword string) error {
+	return d.ResetMemberPasswordAs(memberID, newPassword, 0)
+}
+
+// ResetMemberPasswordAs is ResetMemberPassword done by actorID, who is
+// audit-logged as resetting it; 0 for the system.
+func (d *Database) ResetMemberPasswordAs(memberID int64, newPassword string, actorID int64) error {
 	// Validate new password
 	newHash, err := d.HashPassword(newPassword)
 	if err != nil {
 		return fmt.Errorf("invalid password: %w", err)
 	}
 
-	// Check if member exists
-	var memberName string
-	err = d.db.QueryRow(`SELECT name FROM members WHERE id = ?`, memberID).Scan(&memberName)
+	return d.inTx(func(tx *sql.Tx) error {
+		// Check if member exists
+		var memberName string
+		err := tx.QueryRow(`SELECT name FROM members WHERE id = ?`, memberID).Scan(&memberName)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("member with ID %d not found", memberID)
+		}
+		if err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+
+		// Update password
+		result, err := tx.Exec(`UPDATE members SET password_hash = ?, must_change_password = 0 WHERE id = ?`, newHash, memberID)
+		if err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to verify password update: %w", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("member with ID %d not found", memberID)
+		}
+
+		detail := fmt.Sprintf("password reset for member %d", memberID)
+		if actorID == memberID {
+			detail = fmt.Sprintf("member %d changed their password", memberID)
+		}
+		return recordAuditOf(tx, actorID, AuditPasswordReset, memberID, 0, detail)
+	})
+}
+
+// ChangeMemberPassword is the member's own password change: unlike
+// ResetMemberPassword it needs their current password, and the new one must
+// differ from it. It also satisfies a password change required by staff.
+func (d *Database) ChangeMemberPassword(memberID int64, currentPassword, newPassword string) error {
+	var storedHash sql.NullString
+	var active bool
+	err := d.db.QueryRow(`SELECT password_hash, active FROM members WHERE id = ?`, memberID).Scan(&storedHash, &active)
 	if err == sql.ErrNoRows {
-		return fmt.Errorf("member with ID %d not found", memberID)
+		return fmt.Errorf("authentication failed: invalid member ID or password")
 	}
 	if err != nil {
-		return fmt.Errorf("database error: %w", err)
+		return fmt.Errorf("database error during authentication: %w", err)
 	}
-
-	// Update password
-	result, err := d.db.Exec(`UPDATE members SET password_hash = ? WHERE id = ?`, newHash, memberID)
-	if err != nil {
-		return fmt.Errorf("failed to update password: %w", err)
+	if !storedHash.Valid || !d.CheckPassword(currentPassword, storedHash.String) {
+		return fmt.Errorf("authentication failed: invalid member ID or password")
 	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to verify password update: %w", err)
+	if !active {
+		return fmt.Errorf("member account %d is deactivated", memberID)
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("member with ID %d not found", memberID)
+	if newPassword == currentPassword {
+		return fmt.Errorf("the new password must differ from the current one")
 	}
-
-	return nil
+	return d.ResetMemberPasswordAs(memberID, newPassword, memberID)
 }
 
 // ---------------------------------------------------------------------------
 // Member Management with Authentication
 // ---------------------------------------------------------------------------
 
-// AddMember creates a new member with proper password validation
+// AddMember creates a new adult member with proper password validation
 func (d *Database) AddMember(name, password string) (int64, error) {
+	return d.AddMemberWithTier(name, password, TierAdult)
+}
+
+// AddMemberWithTier creates a new member in the given membership tier.
+func (d *Database) AddMemberWithTier(name, password, tier string) (int64, error) {
 	// Validate inputs
 	if strings.TrimSpace(name) == "" {
 		return 0, fmt.Errorf("member name cannot be empty")
 	}
+	if _, err := d.GetTierPolicy(tier); err != nil {
+		return 0, err
+	}
 
 	// Hash password with validation
 	hashedPassword, err := d.HashPassword(password)
@@ -334,15 +1360,35 @@ func (d *Database) AddMember(name, password string) (int64, error) {
 	}
 
 	// Insert member
-	res, err := d.addMemberStmt.Exec(name, hashedPassword)
+	res, err := d.addMemberStmt.ExecContext(d.db.ctx, name, hashedPassword, d.sqlNow(), fmt.Sprintf("+%d days", DefaultMembershipDays), tier)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return 0, fmt.Errorf("member with name '%s' already exists", name)
+		}
+		return 0, fmt.Errorf("failed to add member: %w", err)
+	}
+
+	return res.LastInsertId()
+}
+
+// UpdateMemberName renames a member, e.g. after marriage or to fix a typo.
+// Names are unique.
+func (d *Database) UpdateMemberName(memberID int64, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("member name cannot be empty")
+	}
+	res, err := d.db.Exec(`UPDATE members SET name=? WHERE id=? AND placeholder=0`, name, memberID)
 	if err != nil {
 		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-			return 0, fmt.Errorf("member with name '%s' already exists", name)
+			return fmt.Errorf("member with name '%s' already exists", name)
 		}
-		return 0, fmt.Errorf("failed to add member: %w", err)
+		return fmt.Errorf("failed to rename member: %w", err)
 	}
-
-	return res.LastInsertId()
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("member with ID %d not found", memberID)
+	}
+	return nil
 }
 
 // ---------------------------------------------------------------------------
@@ -351,11 +1397,44 @@ func (d *Database) AddMember(name, password string) (int64, error) {
 
 // AddBook inserts a book when you already have the full content in memory.
 func (d *Database) AddBook(title, author, content string) (int64, error) {
-	res, err := d.addBookStmt.Exec(title, author, content)
+	id, err := inTxResult(d, func(tx *sql.Tx) (int64, error) {
+		return d.addBook(tx, title, author, content, 0)
+	})
 	if err != nil {
 		return 0, err
 	}
-	return res.LastInsertId()
+	return id, d.storeNewContent(id, content)
+}
+
+// addBook inserts a book within tx, audit-logging actorID as adding it. Only
+// content kept inline is written; storeNewContent finishes the job once tx
+// has committed.
+func (d *Database) addBook(tx *sql.Tx, title, author, content string, actorID int64) (int64, error) {
+	res, err := tx.Stmt(d.addBookStmt).Exec(title, author)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if content != "" && (d.contentStore == nil || len(content) <= d.inlineContentMax) {
+		if err := setInlineContent(tx, id, content); err != nil {
+			return 0, err
+		}
+	}
+	detail := fmt.Sprintf("added book %d '%s' by %s", id, title, author)
+	return id, recordAuditOf(tx, actorID, AuditAddBook, 0, id, detail)
+}
+
+// storeNewContent puts the content of a book just added by addBook in the
+// content store when it is too large to keep inline, and computes its
+// similarity signature.
+func (d *Database) storeNewContent(bookID int64, content string) error {
+	if d.contentStore != nil && len(content) > d.inlineContentMax {
+		return d.UpdateBookContent(bookID, content)
+	}
+	return d.updateSignature(bookID)
 }
 
 // AddBookFromReader streams the content from r and avoids holding more than
@@ -370,89 +1449,231 @@ func (d *Database) AddBookFromReader(title, author string, r io.Reader) (int64,
 }
 
 func (d *Database) GetBook(id int64) (*Book, error) {
-	var b Book
-	err := d.db.QueryRow(`SELECT id,title,author,content,available,COALESCE(borrower_id,0) FROM books WHERE id=?`, id).
-		Scan(&b.ID, &b.Title, &b.Author, &b.Content, &b.Available, &b.BorrowerID)
+	return scanBook(d.db.QueryRow(`SELECT `+bookColumns+` FROM books b WHERE b.id=?`, id))
+}
+
+func (d *Database) GetAllBooks() ([]*Book, error) {
+	return d.getAllBooks(bookColumns)
+}
+
+// GetAllBookSummaries is GetAllBooks with every Content left empty, for
+// callers that only show the catalog.
+func (d *Database) GetAllBookSummaries() ([]*Book, error) {
+	return d.getAllBooks(bookListColumns)
+}
+
+func (d *Database) getAllBooks(columns string) ([]*Book, error) {
+	rows, err := d.db.Query(`SELECT ` + columns + ` FROM books b ORDER BY b.id`)
 	if err != nil {
 		return nil, err
 	}
-	return &b, nil
+	return scanBooks(rows)
 }
 
-func (d *Database) GetAllBooks() ([]*Book, error) {
-	rows, err := d.db.Query(`SELECT id,title,author,content,available,COALESCE(borrower_id,0) FROM books ORDER BY id`)
+// GetBooksPage returns up to limit books in ID order, skipping the first
+// offset. Their Content is left empty, so listing a large catalog doesn't
+// load every text.
+func (d *Database) GetBooksPage(offset, limit int) ([]*Book, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("invalid page: offset %d, limit %d", offset, limit)
+	}
+	rows, err := d.db.Query(`SELECT `+bookListColumns+` FROM books b ORDER BY b.id LIMIT ? OFFSET ?`, limit, offset)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return scanBooks(rows)
+}
 
-	var books []*Book
-	for rows.Next() {
-		var b Book
-		if err := rows.Scan(&b.ID, &b.Title, &b.Author, &b.Content, &b.Available, &b.BorrowerID); err != nil {
+// CountBooks returns the number of books in the catalog.
+func (d *Database) CountBooks() (int, error) {
+	var n int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM books`).Scan(&n)
+	return n, err
+}
+
+// SearchBooks runs a book search query (see ParseBookQuery) over titles,
+// authors and content, best matches first. A query that is an ISBN finds the
+// books with that ISBN. A malformed query returns a *SearchSyntaxError.
+func (d *Database) SearchBooks(q string) ([]*Book, error) {
+	return resultBooks(d.searchBooks(q, bookColumns))
+}
+
+// SearchBookSummaries is SearchBooks with every Content left empty.
+func (d *Database) SearchBookSummaries(q string) ([]*Book, error) {
+	return resultBooks(d.searchBooks(q, bookListColumns))
+}
+
+// SearchBookResults is SearchBookSummaries with each book's relevance and
+// excerpts showing the matched terms in its title and text.
+func (d *Database) SearchBookResults(q string) ([]*SearchResult, error) {
+	return d.searchBooks(q, bookListColumns)
+}
+
+func (d *Database) searchBooks(q, columns string) ([]*SearchResult, error) {
+	if books, err := d.findBooksByISBN(q, columns); err != nil || len(books) > 0 {
+		return bookResults(books, err)
+	}
+
+	bq, err := ParseBookQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	if bq.Match == "" {
+		// Only filters, or nothing at all: every book that passes them
+		rows, err := d.db.Query(`SELECT `+columns+`
+                                 FROM books b
+                                 WHERE ?1 IS NULL OR b.available = ?1
+                                 ORDER BY b.id`, bq.Available)
+		if err != nil {
 			return nil, err
 		}
-		books = append(books, &b)
+		return bookResults(scanBooks(rows))
 	}
-	return books, rows.Err()
-}
 
-func (d *Database) SearchBooks(q string) ([]*Book, error) {
-	// Use FTS5 for search
-	query := `SELECT b.id, b.title, b.author, b.content, b.available, COALESCE(b.borrower_id,0)
+	// Use FTS5 for search; rank is bm25, lower for better matches
+	query := `SELECT ` + columns + `, -fts.rank, ` + contentColumns + `
               FROM books_fts fts
-              JOIN books b ON fts.content_id = b.id
-              WHERE books_fts MATCH ?
+              JOIN books b ON fts.rowid = b.id
+              WHERE books_fts MATCH ?1 AND (?2 IS NULL OR b.available = ?2)
               ORDER BY rank`
 
-	rows, err := d.db.Query(query, q)
+	rows, err := d.db.Query(query, bq.Match, bq.Available)
 	if err != nil {
 		// If FTS fails, fall back to LIKE search
-		fallbackQuery := `SELECT id,title,author,content,available,COALESCE(borrower_id,0) 
-                          FROM books 
-                          WHERE title LIKE ? OR author LIKE ? 
-                          ORDER BY id`
+		fallbackQuery := `SELECT ` + columns + `
+                          FROM books b
+                          WHERE (b.title LIKE ?1 OR b.author LIKE ?1) AND (?2 IS NULL OR b.available = ?2)
+                          ORDER BY b.id`
 		likePattern := "%" + q + "%"
-		rows, err = d.db.Query(fallbackQuery, likePattern, likePattern)
+		rows, err := d.db.Query(fallbackQuery, likePattern, bq.Available)
 		if err != nil {
 			return nil, err
 		}
+		return bookResults(scanBooks(rows))
 	}
 	defer rows.Close()
-
-	var books []*Book
+	var results []*SearchResult
 	for rows.Next() {
-		var b Book
-		if err := rows.Scan(&b.ID, &b.Title, &b.Author, &b.Content, &b.Available, &b.BorrowerID); err != nil {
+		r := &SearchResult{Book: &Book{}}
+		var content string
+		var ref sql.NullString
+		if err := rows.Scan(append(bookFields(r.Book), &r.Score, &content, &ref)...); err != nil {
 			return nil, err
 		}
-		books = append(books, &b)
+		r.TitleHighlight = highlightTitle(r.Book.Title, bq.terms)
+		if len(results) < searchExcerptResults {
+			// A text the content store can't supply just has no excerpt
+			if text, err := d.resolveContent(content, ref); err == nil {
+				r.Snippet = searchExcerpt(text, bq.terms)
+			}
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// bookResults wraps books found without ranking as search results.
+func bookResults(books []*Book, err error) ([]*SearchResult, error) {
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*SearchResult, len(books))
+	for i, b := range books {
+		results[i] = &SearchResult{Book: b}
+	}
+	return results, nil
+}
+
+// resultBooks returns the books of search results.
+func resultBooks(results []*SearchResult, err error) ([]*Book, error) {
+	if err != nil {
+		return nil, err
+	}
+	var books []*Book
+	for _, r := range results {
+		books = append(books, r.Book)
 	}
-	return books, rows.Err()
+	return books, nil
 }
 
 // ---------------------------------------------------------------------------
 // Circulation with Authorization Checks
 // ---------------------------------------------------------------------------
 
-// CheckoutBook performs a book checkout with proper validation
-func (d *Database) CheckoutBook(bookID, memberID int64) error {
-	tx, err := d.db.Begin()
+// defaultLoanDays is the loan period used when a member's tier is unknown.
+const defaultLoanDays = 14
+
+// SetDefaultLoanDays sets the loan period used when a member's tier is
+// unknown; zero or less restores the default.
+func (d *Database) SetDefaultLoanDays(days int) { d.loanDays = days }
+
+func (d *Database) fallbackLoanDays() int {
+	if d.loanDays > 0 {
+		return d.loanDays
+	}
+	return defaultLoanDays
+}
+
+// insertCheckout records a new loan inside tx. The due date comes from the
+// item type's loan period if it has one, otherwise from the borrower's
+// membership tier; any deposit the item type requires is marked held. It
+// returns the new checkout's ID.
+func (d *Database) insertCheckout(tx *sql.Tx, bookID, memberID int64) (int64, error) {
+	days, err := loanDays(tx, bookID, memberID, d.fallbackLoanDays())
 	if err != nil {
-		return err
+		return 0, err
+	}
+	now := d.sqlNow()
+	res, err := tx.Exec(`INSERT INTO checkouts(book_id, member_id, checkout_time, due_time, deposit_cents, deposit_status)
+                       SELECT b.id, ?, ?, datetime(?, ?),
+                              COALESCE(it.deposit_cents, 0),
+                              CASE WHEN it.deposit_cents > 0 THEN ? END
+                       FROM books b LEFT JOIN item_types it ON it.name = b.item_type
+                       WHERE b.id = ?`,
+		memberID, now, now, fmt.Sprintf("+%d days", days), DepositHeld, bookID)
+	if err != nil {
+		return 0, err
 	}
-	defer tx.Rollback()
+	return res.LastInsertId()
+}
+
+// loanDays returns the loan period in days for memberID borrowing bookID:
+// the item type's if it has one, otherwise the member's tier's, otherwise
+// fallback.
+func loanDays(tx *sql.Tx, bookID, memberID int64, fallback int) (int, error) {
+	var days int
+	err := tx.QueryRow(`SELECT COALESCE((SELECT it.loan_days FROM books b JOIN item_types it ON it.name = b.item_type WHERE b.id = ?),
+	                                    (SELECT t.loan_days FROM members m JOIN membership_tiers t ON m.tier = t.name WHERE m.id = ?),
+	                                    ?)`, bookID, memberID, fallback).Scan(&days)
+	return days, err
+}
+
+// CheckoutBook performs a book checkout with proper validation
+func (d *Database) CheckoutBook(bookID, memberID int64) error {
+	return d.inTx(func(tx *sql.Tx) error {
+		return d.checkoutBook(tx, bookID, memberID)
+	})
+}
 
+// checkoutBook is CheckoutBook within tx.
+func (d *Database) checkoutBook(tx *sql.Tx, bookID, memberID int64) error {
 	// Check if book exists and is available
-	var available bool
-	err = tx.QueryRow(`SELECT available FROM books WHERE id=?`, bookID).Scan(&available)
+	var available, nonCirculating bool
+	err := tx.QueryRow(`SELECT available, non_circulating FROM books WHERE id=?`, bookID).Scan(&available, &nonCirculating)
 	if err == sql.ErrNoRows {
 		return fmt.Errorf("book not found")
 	}
 	if err != nil {
 		return err
 	}
+	if nonCirculating {
+		return fmt.Errorf("book is for in-library use only")
+	}
 	if !available {
+		// Unless it is on hold for this member, who is collecting it
+		if collected, err := d.collectOwnHold(tx, bookID, memberID); collected || err != nil {
+			return err
+		}
 		return fmt.Errorf("book is not available")
 	}
 
@@ -465,6 +1686,12 @@ func (d *Database) CheckoutBook(bookID, memberID int64) error {
 	if err != nil {
 		return err
 	}
+	if err := d.checkMembershipActive(tx, memberID); err != nil {
+		return err
+	}
+	if err := checkLoanLimit(tx, memberID); err != nil {
+		return err
+	}
 
 	// Update book as checked out
 	if _, err := tx.Exec(`UPDATE books SET available=0, borrower_id=? WHERE id=?`, memberID, bookID); err != nil {
@@ -472,55 +1699,157 @@ func (d *Database) CheckoutBook(bookID, memberID int64) error {
 	}
 
 	// Record checkout
-	if _, err := tx.Exec(`INSERT INTO checkouts(book_id, member_id) VALUES(?,?)`, bookID, memberID); err != nil {
+	checkoutID, err := d.insertCheckout(tx, bookID, memberID)
+	if err != nil {
+		return err
+	}
+	detail := fmt.Sprintf("checked out book %d", bookID)
+	if err := recordAuditOf(tx, memberID, AuditCheckout, memberID, bookID, detail); err != nil {
 		return err
 	}
+	return d.queueLoanEvent(tx, EventCheckout, checkoutID)
+}
+
+// CheckoutBooks lends a cart of books to memberID in one transaction: either
+// every book is checked out or, if any cannot be, none is. The loan limit
+// applies to the cart as a whole. It returns the new loans in cart order.
+func (d *Database) CheckoutBooks(bookIDs []int64, memberID int64) ([]*Loan, error) {
+	if len(bookIDs) == 0 {
+		return nil, fmt.Errorf("the cart is empty")
+	}
+	seen := make(map[int64]bool, len(bookIDs))
+	for _, id := range bookIDs {
+		if seen[id] {
+			return nil, fmt.Errorf("book %d is in the cart twice", id)
+		}
+		seen[id] = true
+	}
+
+	err := d.inTx(func(tx *sql.Tx) error {
+		// Holds waiting for the member already count against their limit
+		var held int
+		for _, id := range bookIDs {
+			var n int
+			if err := tx.QueryRow(`SELECT COUNT(*) FROM checkouts WHERE book_id=? AND member_id=? AND status=? AND return_time IS NULL`,
+				id, memberID, LoanAwaitingPickup).Scan(&n); err != nil {
+				return err
+			}
+			held += n
+		}
+		if err := checkLoanLimitFor(tx, memberID, len(bookIDs)-held); err != nil {
+			return err
+		}
+		for _, id := range bookIDs {
+			if err := d.checkoutBook(tx, id, memberID); err != nil {
+				return fmt.Errorf("book %d: %w", id, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	return tx.Commit()
+	open, err := d.GetOpenLoans(memberID)
+	if err != nil {
+		return nil, err
+	}
+	byBook := make(map[int64]*Loan, len(open))
+	for _, l := range open {
+		byBook[l.BookID] = l
+	}
+	loans := make([]*Loan, 0, len(bookIDs))
+	for _, id := range bookIDs {
+		if l := byBook[id]; l != nil {
+			loans = append(loans, l)
+		}
+	}
+	return loans, nil
 }
 
 // ReserveBook implements proper reservation logic with fix for the "already borrowed" bug
 func (d *Database) ReserveBook(bookID, memberID int64) error {
-	tx, err := d.db.Begin()
-	if err != nil {
-		return err
+	return d.inTx(func(tx *sql.Tx) error {
+		return d.reserveBook(tx, bookID, memberID)
+	})
+}
+
+// ReserveBookFor reserves bookID for each of memberIDs, joining the queue in
+// the order given, e.g. a class reading the same title. If the book is on the
+// shelf the first member checks it out. Either every member is reserved or,
+// if any cannot be, none is.
+func (d *Database) ReserveBookFor(bookID int64, memberIDs []int64) error {
+	seen := make(map[int64]bool, len(memberIDs))
+	for _, id := range memberIDs {
+		if seen[id] {
+			return fmt.Errorf("member %d is listed twice", id)
+		}
+		seen[id] = true
 	}
-	defer tx.Rollback()
+	return d.inTx(func(tx *sql.Tx) error {
+		for _, id := range memberIDs {
+			if err := d.reserveBook(tx, bookID, id); err != nil {
+				return fmt.Errorf("member %d: %w", id, err)
+			}
+		}
+		return nil
+	})
+}
 
+// reserveBook is ReserveBook within tx.
+func (d *Database) reserveBook(tx *sql.Tx, bookID, memberID int64) error {
 	// Check if book exists
-	var available bool
+	var available, nonCirculating bool
 	var borrowerID sql.NullInt64
-	err = tx.QueryRow(`SELECT available, borrower_id FROM books WHERE id=?`, bookID).Scan(&available, &borrowerID)
+	err := tx.QueryRow(`SELECT available, borrower_id, non_circulating FROM books WHERE id=?`, bookID).Scan(&available, &borrowerID, &nonCirculating)
 	if err == sql.ErrNoRows {
 		return fmt.Errorf("book not found")
 	}
 	if err != nil {
 		return err
 	}
+	if nonCirculating {
+		return fmt.Errorf("book is for in-library use only")
+	}
 
 	// Verify member exists
 	var memberName string
-	err = tx.QueryRow(`SELECT name FROM members WHERE id=?`, memberID).Scan(&memberName)
+	var active bool
+	err = tx.QueryRow(`SELECT name, active FROM members WHERE id=?`, memberID).Scan(&memberName, &active)
 	if err == sql.ErrNoRows {
 		return fmt.Errorf("member not found")
 	}
 	if err != nil {
 		return err
 	}
+	if !active {
+		return fmt.Errorf("member account is deactivated")
+	}
 
 	// If book is available, check it out immediately instead of reserving
 	if available {
+		if err := d.checkMembershipActive(tx, memberID); err != nil {
+			return err
+		}
+		if err := checkLoanLimit(tx, memberID); err != nil {
+			return err
+		}
+
 		// Update book as checked out
 		if _, err := tx.Exec(`UPDATE books SET available=0, borrower_id=? WHERE id=?`, memberID, bookID); err != nil {
 			return err
 		}
 
 		// Record checkout
-		if _, err := tx.Exec(`INSERT INTO checkouts(book_id, member_id) VALUES(?,?)`, bookID, memberID); err != nil {
+		checkoutID, err := d.insertCheckout(tx, bookID, memberID)
+		if err != nil {
 			return err
 		}
-
-		return tx.Commit()
+		detail := fmt.Sprintf("checked out book %d", bookID)
+		if err := recordAuditOf(tx, memberID, AuditCheckout, memberID, bookID, detail); err != nil {
+			return err
+		}
+		return d.queueLoanEvent(tx, EventCheckout, checkoutID)
 	}
 
 	// CRITICAL FIX: Check if member is the current borrower
@@ -539,71 +1868,109 @@ func (d *Database) ReserveBook(bookID, memberID int64) error {
 	}
 
 	// Create reservation
-	if _, err := tx.Exec(`INSERT INTO reservations(book_id, member_id) VALUES(?,?)`, bookID, memberID); err != nil {
+	if _, err := tx.Exec(`INSERT INTO reservations(book_id, member_id, reservation_time) VALUES(?,?,?)`, bookID, memberID, d.sqlNow()); err != nil {
 		return err
 	}
 
-	return tx.Commit()
+	return nil
 }
 
-// ReturnBook marks a book as returned and assigns it to the next person in the reservation queue.
-// Returns the member ID who returned the book.
+// ReturnBook marks a book as returned and holds it for the next person in the
+// reservation queue. Returns the member ID who returned the book.
 func (d *Database) ReturnBook(bookID int64) (int64, error) {
-	tx, err := d.db.Begin()
-	if err != nil {
-		return 0, err
-	}
-	defer tx.Rollback()
+	return d.ReturnItem(bookID, nil)
+}
+
+// ReturnItem is ReturnBook with the result of the accessory checklist: any
+// deposit held on the loan is refunded when nothing is missing and forfeited
+// otherwise.
+func (d *Database) ReturnItem(bookID int64, missingAccessories []string) (int64, error) {
+	return d.returnItem(bookID, missingAccessories, 0)
+}
+
+// returnItem is ReturnItem recording actingID, when non-zero, as the member
+// who brought the item back: the borrower or one of their delegates.
+func (d *Database) returnItem(bookID int64, missingAccessories []string, actingID int64) (int64, error) {
+	return inTxResult(d, func(tx *sql.Tx) (int64, error) {
+		borrowerID, _, err := d.returnItemTx(tx, bookID, missingAccessories, actingID)
+		return borrowerID, err
+	})
+}
 
+// returnItemTx is returnItem within tx. It returns the borrower and the
+// member the item is now held for, or 0.
+func (d *Database) returnItemTx(tx *sql.Tx, bookID int64, missingAccessories []string, actingID int64) (int64, int64, error) {
 	// Get current borrower
 	var borrowerID int64
 	var available bool
-	err = tx.QueryRow(`SELECT borrower_id, available FROM books WHERE id=?`, bookID).Scan(&borrowerID, &available)
+	err := tx.QueryRow(`SELECT borrower_id, available FROM books WHERE id=?`, bookID).Scan(&borrowerID, &available)
 	if err == sql.ErrNoRows {
-		return 0, fmt.Errorf("book not found")
+		return 0, 0, fmt.Errorf("book not found")
 	}
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 	if available {
-		return 0, fmt.Errorf("book is not checked out")
+		return 0, 0, fmt.Errorf("book is not checked out")
 	}
-
-	// Mark current checkout as returned
-	if _, err := tx.Exec(`UPDATE checkouts SET return_time=CURRENT_TIMESTAMP WHERE book_id=? AND member_id=? AND return_time IS NULL`, bookID, borrowerID); err != nil {
-		return 0, err
+	// A hold has not left the library; it lapses or is collected instead
+	var onHoldShelf bool
+	if err := tx.QueryRow(`SELECT COUNT(*) > 0 FROM checkouts WHERE book_id=? AND status=? AND return_time IS NULL`,
+		bookID, LoanAwaitingPickup).Scan(&onHoldShelf); err != nil {
+		return 0, 0, err
 	}
-
-	// Check for reservations
-	var nextMemberID sql.NullInt64
-	err = tx.QueryRow(`SELECT member_id FROM reservations WHERE book_id=? AND fulfilled_time IS NULL ORDER BY reservation_time LIMIT 1`, bookID).Scan(&nextMemberID)
-	if err != nil && err != sql.ErrNoRows {
-		return 0, err
+	if onHoldShelf {
+		return 0, 0, fmt.Errorf("book is on the hold shelf awaiting pickup, not checked out")
 	}
 
-	if nextMemberID.Valid {
-		// Assign to next member in queue
-		if _, err := tx.Exec(`UPDATE books SET borrower_id=? WHERE id=?`, nextMemberID.Int64, bookID); err != nil {
-			return 0, err
-		}
+	// A lost copy turned up: reverse its replacement charge
+	if _, err := tx.Exec(`UPDATE fines SET reversed_time=?
+                          WHERE kind=? AND reversed_time IS NULL AND checkout_id IN
+                              (SELECT id FROM checkouts WHERE book_id=? AND return_time IS NULL AND status=?)`,
+		d.sqlNow(), FineReplacement, bookID, LoanLost); err != nil {
+		return 0, 0, err
+	}
 
-		// Mark reservation as fulfilled
-		if _, err := tx.Exec(`UPDATE reservations SET fulfilled_time=CURRENT_TIMESTAMP WHERE book_id=? AND member_id=?`, bookID, nextMemberID.Int64); err != nil {
-			return 0, err
-		}
+	// Settle the deposit against the accessory checklist
+	depositStatus := DepositRefunded
+	if len(missingAccessories) > 0 {
+		depositStatus = DepositForfeited
+	}
+	if _, err := tx.Exec(`UPDATE checkouts
+                          SET missing_accessories=?,
+                              deposit_status=CASE WHEN deposit_status=? THEN ? ELSE deposit_status END
+                          WHERE book_id=? AND return_time IS NULL`,
+		strings.Join(missingAccessories, ", "), DepositHeld, depositStatus, bookID); err != nil {
+		return 0, 0, err
+	}
 
-		// Create new checkout record
-		if _, err := tx.Exec(`INSERT INTO checkouts(book_id, member_id) VALUES(?,?)`, bookID, nextMemberID.Int64); err != nil {
-			return 0, err
-		}
-	} else {
-		// No one waiting, make available
-		if _, err := tx.Exec(`UPDATE books SET available=1, borrower_id=NULL WHERE id=?`, bookID); err != nil {
-			return 0, err
-		}
+	// Mark current checkout as returned
+	var checkoutID int64
+	if err := tx.QueryRow(`SELECT id FROM checkouts WHERE book_id=? AND member_id=? AND return_time IS NULL`,
+		bookID, borrowerID).Scan(&checkoutID); err != nil {
+		return 0, 0, err
+	}
+	if _, err := tx.Exec(`UPDATE checkouts SET return_time=?, status='returned', returned_by=NULLIF(?, 0)
+                          WHERE id=?`, d.sqlNow(), actingID, checkoutID); err != nil {
+		return 0, 0, err
+	}
+	if err := d.queueLoanEvent(tx, EventReturn, checkoutID); err != nil {
+		return 0, 0, err
+	}
+	action, detail := AuditReturn, fmt.Sprintf("returned book %d", bookID)
+	if actingID > 0 && actingID != borrowerID {
+		action, detail = AuditDelegateReturn, fmt.Sprintf("returned book %d for member %d", bookID, borrowerID)
+	}
+	if err := recordAuditOf(tx, actingID, action, borrowerID, bookID, detail); err != nil {
+		return 0, 0, err
 	}
 
-	return borrowerID, tx.Commit()
+	// Hold it for the next member in the queue, if any
+	holdFor, err := d.holdForNextReservation(tx, bookID)
+	if err != nil {
+		return 0, 0, err
+	}
+	return borrowerID, holdFor, nil
 }
 
 // VerifyReturnAuthorization checks if a member can return a specific book
@@ -622,26 +1989,34 @@ func (d *Database) VerifyReturnAuthorization(bookID, memberID int64) error {
 		return fmt.Errorf("book is not currently checked out")
 	}
 
-	if !borrowerID.Valid || borrowerID.Int64 != memberID {
+	if !borrowerID.Valid {
 		return fmt.Errorf("you can only return books that you have checked out")
 	}
+	if borrowerID.Int64 != memberID {
+		ok, err := d.IsDelegate(borrowerID.Int64, memberID)
+		if err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		if !ok {
+			return fmt.Errorf("you can only return books that you have checked out")
+		}
+	}
 
 	return nil
 }
 
-func (d *Database) UpdateBookContent(bookID int64, content string) error {
-	_, err := d.db.Exec(`UPDATE books SET content=? WHERE id=?`, content, bookID)
-	return err
-}
-
 func (d *Database) GetMember(id int64) (*Member, error) {
 	var m Member
 	var passwordHash sql.NullString
-	err := d.db.QueryRow(`SELECT id,name,password_hash FROM members WHERE id=?`, id).
-		Scan(&m.ID, &m.Name, &passwordHash)
+	var expiry sql.NullTime
+	err := d.db.QueryRow(`SELECT id,name,password_hash,is_admin,expiry_time,tier,active FROM members WHERE id=?`, id).
+		Scan(&m.ID, &m.Name, &passwordHash, &m.IsAdmin, &expiry, &m.Tier, &m.Active)
 	if err != nil {
 		return nil, err
 	}
+	if expiry.Valid {
+		m.ExpiryTime = &expiry.Time
+	}
 
 	// Only set password hash if it exists (backwards compatibility)
 	if passwordHash.Valid {
@@ -652,19 +2027,48 @@ func (d *Database) GetMember(id int64) (*Member, error) {
 }
 
 func (d *Database) GetAllMembers() ([]*Member, error) {
-	rows, err := d.db.Query(`SELECT id,name,password_hash FROM members ORDER BY id`)
+	rows, err := d.db.Query(`SELECT id,name,password_hash,is_admin,expiry_time,tier,active FROM members WHERE placeholder=0 ORDER BY id`)
 	if err != nil {
 		return nil, err
 	}
+	return scanMembers(rows)
+}
+
+// GetMembersPage returns up to limit members in ID order, skipping the
+// first offset.
+func (d *Database) GetMembersPage(offset, limit int) ([]*Member, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("invalid page: offset %d, limit %d", offset, limit)
+	}
+	rows, err := d.db.Query(`SELECT id,name,password_hash,is_admin,expiry_time,tier,active FROM members WHERE placeholder=0
+                             ORDER BY id LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanMembers(rows)
+}
+
+// CountMembers returns the number of members, as listed by GetAllMembers.
+func (d *Database) CountMembers() (int, error) {
+	var n int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM members WHERE placeholder=0`).Scan(&n)
+	return n, err
+}
+
+func scanMembers(rows *sql.Rows) ([]*Member, error) {
 	defer rows.Close()
 
 	var members []*Member
 	for rows.Next() {
 		var m Member
 		var passwordHash sql.NullString
-		if err := rows.Scan(&m.ID, &m.Name, &passwordHash); err != nil {
+		var expiry sql.NullTime
+		if err := rows.Scan(&m.ID, &m.Name, &passwordHash, &m.IsAdmin, &expiry, &m.Tier, &m.Active); err != nil {
 			return nil, err
 		}
+		if expiry.Valid {
+			m.ExpiryTime = &expiry.Time
+		}
 
 		// Only set password hash if it exists (backwards compatibility)
 		if passwordHash.Valid {
@@ -676,12 +2080,47 @@ func (d *Database) GetAllMembers() ([]*Member, error) {
 	return members, rows.Err()
 }
 
+// ReservationCount summarises one book's reservation queue.
+type ReservationCount struct {
+	BookID          int64
+	QueueLength     int
+	FirstMemberID   int64
+	FirstMemberName string
+}
+
+// GetReservationCounts returns the queue length and first member in line for
+// every book with active reservations, keyed by book ID, in a single query.
+func (d *Database) GetReservationCounts() (map[int64]*ReservationCount, error) {
+	// SQLite takes the bare member columns from the row that supplies MIN(r.id),
+	// i.e. the oldest reservation in each group
+	rows, err := d.db.Query(`SELECT r.book_id, COUNT(*), r.member_id, m.name, MIN(r.id)
+                             FROM reservations r
+                             JOIN members m ON r.member_id = m.id
+                             WHERE r.fulfilled_time IS NULL
+                             GROUP BY r.book_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]*ReservationCount)
+	for rows.Next() {
+		var c ReservationCount
+		var firstID int64
+		if err := rows.Scan(&c.BookID, &c.QueueLength, &c.FirstMemberID, &c.FirstMemberName, &firstID); err != nil {
+			return nil, err
+		}
+		counts[c.BookID] = &c
+	}
+	return counts, rows.Err()
+}
+
 func (d *Database) GetReservations(bookID int64) ([]*Member, error) {
 	query := `SELECT m.id, m.name, COALESCE(m.password_hash, '') as password_hash
               FROM reservations r
               JOIN members m ON r.member_id = m.id
               WHERE r.book_id = ? AND r.fulfilled_time IS NULL
-              ORDER BY r.reservation_time`
+              ORDER BY r.reservation_time, r.id`
 
 	rows, err := d.db.Query(query, bookID)
 	if err != nil {
@@ -703,7 +2142,17 @@ func (d *Database) GetReservations(bookID int64) ([]*Member, error) {
 }
 
 func (d *Database) GetMemberReservations(memberID int64) ([]*Book, error) {
-	query := `SELECT b.id, b.title, b.author, b.content, b.available, COALESCE(b.borrower_id,0)
+	return d.getMemberReservations(memberID, bookColumns)
+}
+
+// GetMemberReservationSummaries is GetMemberReservations with every Content
+// left empty.
+func (d *Database) GetMemberReservationSummaries(memberID int64) ([]*Book, error) {
+	return d.getMemberReservations(memberID, bookListColumns)
+}
+
+func (d *Database) getMemberReservations(memberID int64, columns string) ([]*Book, error) {
+	query := `SELECT ` + columns + `
               FROM reservations r
               JOIN books b ON r.book_id = b.id
               WHERE r.member_id = ? AND r.fulfilled_time IS NULL
@@ -713,34 +2162,32 @@ func (d *Database) GetMemberReservations(memberID int64) ([]*Book, error) {
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var books []*Book
-	for rows.Next() {
-		var b Book
-		if err := rows.Scan(&b.ID, &b.Title, &b.Author, &b.Content, &b.Available, &b.BorrowerID); err != nil {
-			return nil, err
-		}
-		books = append(books, &b)
-	}
-	return books, rows.Err()
+	return scanBooks(rows)
 }
 
+// CancelReservation withdraws memberID's reservation of bookID; the members
+// behind them in the queue move up.
 func (d *Database) CancelReservation(bookID, memberID int64) error {
-	result, err := d.db.Exec(`DELETE FROM reservations WHERE book_id=? AND member_id=? AND fulfilled_time IS NULL`, bookID, memberID)
-	if err != nil {
-		return err
-	}
+	return d.inTx(func(tx *sql.Tx) error {
+		queueMoved, err := d.watchQueues(tx, bookID)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec(`DELETE FROM reservations WHERE book_id=? AND member_id=? AND fulfilled_time IS NULL`, bookID, memberID)
+		if err != nil {
+			return err
+		}
 
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rows == 0 {
-		return fmt.Errorf("no active reservation found for this book and member")
-	}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return fmt.Errorf("no active reservation found for this book and member")
+		}
 
-	return nil
+		return queueMoved()
+	})
 }
 
 // ---------------------------------------------------------------------------
@@ -757,6 +2204,7 @@ type ReadBookValidation struct {
 	HasContent        bool
 	MemberExists      bool
 	MemberName        string
+	DigitalLicenses   int  // Concurrent digital reads allowed; 0 means print only
 	CanAutoCheckout   bool // Book is available for checkout
 	CanRead           bool // Member can read (owns book or can auto-checkout with content)
 }
@@ -765,12 +2213,14 @@ type ReadBookValidation struct {
 func (d *Database) ValidateReadBookAccess(bookID, memberID int64) (*ReadBookValidation, error) {
 	v := &ReadBookValidation{}
 
-	// Check book exists and get details
-	var title, author, content string
+	// Check book exists and get details; the content-derived part may be cached
 	var available bool
 	var borrowerID sql.NullInt64
-	err := d.db.QueryRow(`SELECT title, author, content, available, borrower_id FROM books WHERE id=?`, bookID).
-		Scan(&title, &author, &content, &available, &borrowerID)
+	meta, err := d.getBookMeta(bookID)
+	if err == nil {
+		err = d.db.QueryRow(`SELECT available, borrower_id, digital_licenses FROM books WHERE id=?`, bookID).
+			Scan(&available, &borrowerID, &v.DigitalLicenses)
+	}
 
 	if err == sql.ErrNoRows {
 		v.BookExists = false
@@ -779,14 +2229,14 @@ func (d *Database) ValidateReadBookAccess(bookID, memberID int64) (*ReadBookVali
 		return nil, err
 	} else {
 		v.BookExists = true
-		v.BookTitle = title
-		v.BookAuthor = author
+		v.BookTitle = meta.title
+		v.BookAuthor = meta.author
 		v.BookAvailable = available
 		if borrowerID.Valid {
 			v.BookBorrowerID = borrowerID.Int64
 		}
-		v.BookContentLength = len(content)
-		v.HasContent = len(strings.TrimSpace(content)) > 0
+		v.BookContentLength = meta.length
+		v.HasContent = meta.hasContent
 	}
 
 	// Check member exists
@@ -812,20 +2262,89 @@ func (d *Database) ValidateReadBookAccess(bookID, memberID int64) (*ReadBookVali
 }
 
 func (d *Database) GetBookContentChunk(bookID int64, offset, length int) (string, error) {
-	var content string
-	err := d.db.QueryRow(`SELECT content FROM books WHERE id=?`, bookID).Scan(&content)
+	if offset < 0 || length < 0 {
+		return "", fmt.Errorf("invalid content range: %d bytes from %d", length, offset)
+	}
+	if d.chunkCache == nil {
+		return d.loadBookContentChunk(bookID, offset, length)
+	}
+	key := chunkKey{bookID: bookID, offset: offset, length: length}
+	if chunk, ok := d.chunkCache.get(key); ok {
+		return chunk, nil
+	}
+	chunk, err := d.loadBookContentChunk(bookID, offset, length)
+	if err != nil {
+		return "", err
+	}
+	d.chunkCache.add(key, chunk)
+	return chunk, nil
+}
+
+// loadBookContentChunk reads one chunk of a book's text, as contentChunk
+// would cut it.
+func (d *Database) loadBookContentChunk(bookID int64, offset, length int) (string, error) {
+	window, err := d.loadContentBytes(bookID, offset, min(length, math.MaxInt32)+utf8.UTFMax-1)
+	if err != nil {
+		return "", err
+	}
+	return windowChunk(window, offset == 0, length), nil
+}
+
+// loadContentBytes returns length bytes of a book's text from offset, or as
+// many as there are, without regard to where characters begin and end. An
+// inline text is cut in SQL, so only those bytes are read into memory; a
+// text in the content store is fetched whole.
+func (d *Database) loadContentBytes(bookID int64, offset, length int) (string, error) {
+	// SQLite texts are shorter than 2GB, and substr counts from 1
+	var window []byte
+	var ref sql.NullString
+	err := d.db.QueryRow(`SELECT substr(CAST(c.content AS BLOB), ?, ?), b.content_ref
+	                      FROM books b LEFT JOIN book_contents c ON c.book_id = b.id
+	                      WHERE b.id=?`,
+		min(offset, math.MaxInt32)+1, min(length, math.MaxInt32), bookID).Scan(&window, &ref)
 	if err != nil {
 		return "", err
 	}
+	if !ref.Valid {
+		return string(window), nil
+	}
 
+	content, err := d.resolveContent("", ref)
+	if err != nil {
+		return "", err
+	}
 	if offset >= len(content) {
 		return "", nil
 	}
+	return content[offset:min(offset+length, len(content))], nil
+}
 
-	end := offset + length
-	if end > len(content) {
-		end = len(content)
+// contentChunk returns the length bytes of content starting at offset,
+// with each end moved forward to a whole character when it falls inside a
+// multi-byte one. Consecutive chunks therefore split no character, and
+// together they make up the whole content.
+func contentChunk(content string, offset, length int) string {
+	if offset >= len(content) {
+		return ""
 	}
+	window := content[offset:]
+	if length < len(window)-(utf8.UTFMax-1) {
+		window = window[:length+utf8.UTFMax-1]
+	}
+	return windowChunk(window, offset == 0, length)
+}
 
-	return content[offset:end], nil
+// windowChunk cuts the chunk contentChunk would from window, the content
+// from the chunk's offset on. window must run utf8.UTFMax-1 bytes past the
+// chunk, or to the end of the content; atStart says the offset is 0, whose
+// byte is never skipped.
+func windowChunk(window string, atStart bool, length int) string {
+	start := 0
+	if !atStart {
+		for n := 1; n < utf8.UTFMax && start < len(window) && !utf8.RuneStart(window[start]); n++ {
+			start++
+		}
+	}
+	end := max(runeStartAt(window, min(length, len(window))), start)
+	return window[start:end]
 }
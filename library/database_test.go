@@ -1,11 +1,12 @@
 package library
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 )
 
-func tempDB(t *testing.T) *Database {
+func tempDB(t testing.TB) *Database {
 	db, err := NewDatabase(":memory:")
 	if err != nil {
 		t.Fatal(err)
@@ -139,6 +140,54 @@ func TestPasswordReset(t *testing.T) {
 	}
 }
 
+func TestChangeMemberPassword(t *testing.T) {
+	db := tempDB(t)
+	memberID, _ := db.AddMember("Carol", "originalPassword")
+
+	if err := db.ChangeMemberPassword(memberID, "wrongPassword", "newPassword123"); err == nil {
+		t.Fatalf("changing the password should need the current one")
+	}
+	if err := db.ChangeMemberPassword(memberID, "originalPassword", "originalPassword"); err == nil {
+		t.Fatalf("the new password should have to differ")
+	}
+	if err := db.ChangeMemberPassword(99999, "originalPassword", "newPassword123"); err == nil {
+		t.Fatalf("changing the password of a missing member should fail")
+	}
+
+	db.db.Exec(`UPDATE members SET must_change_password=1 WHERE id=?`, memberID)
+	if err := db.ChangeMemberPassword(memberID, "originalPassword", "newPassword123"); err != nil {
+		t.Fatalf("change password: %v", err)
+	}
+	if err := db.AuthenticateMember(memberID, "originalPassword"); err == nil {
+		t.Fatalf("old password should not work after a change")
+	}
+	if err := db.AuthenticateMember(memberID, "newPassword123"); err != nil {
+		t.Fatalf("new password should work and clear a required change: %v", err)
+	}
+}
+
+func TestUpdateMemberName(t *testing.T) {
+	db := tempDB(t)
+	alice, _ := db.AddMember("Alice Smith", "password")
+	db.AddMember("Bob", "password")
+
+	if err := db.UpdateMemberName(alice, "  Alice Jones "); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if m, _ := db.GetMember(alice); m.Name != "Alice Jones" {
+		t.Fatalf("name = %q", m.Name)
+	}
+	if err := db.UpdateMemberName(alice, "Bob"); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected a duplicate name error, got %v", err)
+	}
+	if err := db.UpdateMemberName(alice, " "); err == nil {
+		t.Fatalf("expected an error for an empty name")
+	}
+	if err := db.UpdateMemberName(99999, "Nobody"); err == nil {
+		t.Fatalf("expected an error for a missing member")
+	}
+}
+
 func TestPasswordHashSecurity(t *testing.T) {
 	db := tempDB(t)
 
@@ -617,14 +666,20 @@ func TestCompleteReservationWorkflow(t *testing.T) {
 		t.Fatalf("queue should now have only Diana")
 	}
 
-	// Step 6: Bob returns book -> automatically assigned to Diana (Charlie was skipped)
+	// Step 6: Bob collects and returns book -> automatically assigned to Diana (Charlie was skipped)
+	if _, err := db.PickUpHold(bookID, bob); err != nil {
+		t.Fatalf("Bob's pickup failed: %v", err)
+	}
 	_, _ = db.ReturnBook(bookID)
 	book, _ = db.GetBook(bookID)
 	if book.Available || book.BorrowerID != diana {
 		t.Fatalf("book should be automatically assigned to Diana")
 	}
 
-	// Step 7: Diana returns book -> no more reservations, book becomes available
+	// Step 7: Diana collects and returns book -> no more reservations, book becomes available
+	if _, err := db.PickUpHold(bookID, diana); err != nil {
+		t.Fatalf("Diana's pickup failed: %v", err)
+	}
 	_, _ = db.ReturnBook(bookID)
 	book, _ = db.GetBook(bookID)
 	if !book.Available {
@@ -698,3 +753,168 @@ func TestAuthenticationEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestGetReservationCounts(t *testing.T) {
+	db := tempDB(t)
+	popular, _ := db.AddBook("Popular", "Author", "content")
+	quiet, _ := db.AddBook("Quiet", "Author", "content")
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+	carol, _ := db.AddMember("Carol", "password")
+
+	db.CheckoutBook(popular, alice)
+	db.ReserveBook(popular, bob)
+	db.ReserveBook(popular, carol)
+
+	counts, err := db.GetReservationCounts()
+	if err != nil {
+		t.Fatalf("GetReservationCounts: %v", err)
+	}
+	if len(counts) != 1 {
+		t.Fatalf("want 1 book with reservations, got %d", len(counts))
+	}
+	c := counts[popular]
+	if c == nil || c.QueueLength != 2 || c.FirstMemberID != bob || c.FirstMemberName != "Bob" {
+		t.Fatalf("unexpected queue summary: %+v", c)
+	}
+	if _, ok := counts[quiet]; ok {
+		t.Fatalf("book without reservations should not be listed")
+	}
+
+	// Fulfilled reservations drop out of the queue
+	db.ReturnBook(popular)
+	counts, _ = db.GetReservationCounts()
+	if c := counts[popular]; c == nil || c.QueueLength != 1 || c.FirstMemberID != carol {
+		t.Fatalf("unexpected queue after fulfilment: %+v", c)
+	}
+}
+
+func TestReserveBookFor(t *testing.T) {
+	db := tempDB(t)
+	bookID, _ := db.AddBook("Class Set", "Author", "content")
+	var class []int64
+	for _, name := range []string{"Dana", "Eli", "Fay", "Gus"} {
+		id, _ := db.AddMember(name, "password")
+		class = append(class, id)
+	}
+
+	// Listed order wins over ID order; the first takes the shelf copy
+	order := []int64{class[2], class[0], class[3]}
+	if err := db.ReserveBookFor(bookID, order); err != nil {
+		t.Fatalf("bulk reserve: %v", err)
+	}
+	if book, _ := db.GetBook(bookID); book.BorrowerID != class[2] {
+		t.Fatalf("first member should have checked the book out, borrower %d", book.BorrowerID)
+	}
+	queue, _ := db.GetReservations(bookID)
+	if len(queue) != 2 || queue[0].ID != class[0] || queue[1].ID != class[3] {
+		t.Fatalf("unexpected queue: %+v", queue)
+	}
+
+	// One bad entry rejects the whole list
+	if err := db.ReserveBookFor(bookID, []int64{class[1], class[0]}); err == nil {
+		t.Fatalf("expected the duplicate reservation to fail the batch")
+	}
+	if queue, _ := db.GetReservations(bookID); len(queue) != 2 {
+		t.Fatalf("a failed batch must not reserve anyone: %+v", queue)
+	}
+	if err := db.ReserveBookFor(bookID, []int64{class[1], class[1]}); err == nil {
+		t.Fatalf("expected a repeated member to be rejected")
+	}
+}
+
+func TestBooksAndMembersPages(t *testing.T) {
+	db := tempDB(t)
+	for i := 1; i <= 5; i++ {
+		db.AddBook(fmt.Sprintf("Book %d", i), "Author", "some content")
+		db.AddMember(fmt.Sprintf("Member %d", i), "password")
+	}
+
+	if n, err := db.CountBooks(); err != nil || n != 5 {
+		t.Fatalf("count books: %d %v", n, err)
+	}
+	page, err := db.GetBooksPage(2, 2)
+	if err != nil || len(page) != 2 || page[0].Title != "Book 3" || page[1].Title != "Book 4" {
+		t.Fatalf("second page: %+v %v", page, err)
+	}
+	if page[0].Content != "" {
+		t.Fatalf("page loaded the book's content")
+	}
+	if last, _ := db.GetBooksPage(4, 2); len(last) != 1 {
+		t.Fatalf("last page has %d books", len(last))
+	}
+	if _, err := db.GetBooksPage(0, 0); err == nil {
+		t.Fatalf("expected an error for an empty page")
+	}
+
+	if n, _ := db.CountMembers(); n != 5 {
+		t.Fatalf("count members = %d", n)
+	}
+	members, err := db.GetMembersPage(3, 10)
+	if err != nil || len(members) != 2 || members[0].Name != "Member 4" {
+		t.Fatalf("members page: %+v %v", members, err)
+	}
+}
+
+func TestBookSummariesLeaveOutContent(t *testing.T) {
+	db := tempDB(t)
+	bookID, _ := db.AddBook("Summary Book", "Author", "the whole text of the book")
+	memberID, _ := db.AddMember("Holder", "password")
+	otherID, _ := db.AddMember("Borrower", "password")
+	if err := db.CheckoutBook(bookID, otherID); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	if err := db.ReserveBook(bookID, memberID); err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+
+	all, err := db.GetAllBookSummaries()
+	if err != nil || len(all) != 1 || all[0].Title != "Summary Book" || all[0].Content != "" {
+		t.Fatalf("all summaries: %+v %v", all, err)
+	}
+	found, err := db.SearchBookSummaries("whole")
+	if err != nil || len(found) != 1 || found[0].ID != bookID || found[0].Content != "" {
+		t.Fatalf("search summaries: %+v %v", found, err)
+	}
+	held, err := db.GetMemberReservationSummaries(memberID)
+	if err != nil || len(held) != 1 || held[0].ID != bookID || held[0].Content != "" {
+		t.Fatalf("reservation summaries: %+v %v", held, err)
+	}
+	if full, _ := db.SearchBooks("whole"); len(full) != 1 || full[0].Content == "" {
+		t.Fatalf("SearchBooks should still load the content")
+	}
+}
+
+func TestCheckoutBooksCart(t *testing.T) {
+	db := tempDB(t)
+	child, _ := db.AddMemberWithTier("Kid", "password", TierChild)
+	other, _ := db.AddMember("Other", "password")
+	policy, _ := db.GetTierPolicy(TierChild)
+	var books []int64
+	for i := 0; i <= policy.LoanLimit; i++ {
+		id, _ := db.AddBook(fmt.Sprintf("Book %d", i), "Author", "content")
+		books = append(books, id)
+	}
+
+	if _, err := db.CheckoutBooks(books, child); err == nil || !strings.Contains(err.Error(), "loan limit") {
+		t.Fatalf("expected the cart to pass the loan limit, got %v", err)
+	}
+	db.CheckoutBook(books[1], other)
+	if _, err := db.CheckoutBooks([]int64{books[0], books[1]}, child); err == nil {
+		t.Fatalf("expected an error for a book on loan")
+	}
+	if loans, _ := db.GetOpenLoans(child); len(loans) != 0 {
+		t.Fatalf("a failed cart should lend nothing, got %d loan(s)", len(loans))
+	}
+	if _, err := db.CheckoutBooks([]int64{books[0], books[0]}, child); err == nil {
+		t.Fatalf("expected an error for a book listed twice")
+	}
+
+	loans, err := db.CheckoutBooks([]int64{books[2], books[0]}, child)
+	if err != nil {
+		t.Fatalf("checkout cart: %v", err)
+	}
+	if len(loans) != 2 || loans[0].BookID != books[2] || loans[1].BookID != books[0] {
+		t.Fatalf("unexpected loans: %+v", loans)
+	}
+}
@@ -0,0 +1,121 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// AddDelegate lets delegateID collect ownerID's holds and return their books,
+// e.g. a parent for a child.
+func (d *Database) AddDelegate(ownerID, delegateID int64) error {
+	if ownerID == delegateID {
+		return fmt.Errorf("a member cannot be their own delegate")
+	}
+	for _, id := range []int64{ownerID, delegateID} {
+		if _, err := d.GetMember(id); err != nil {
+			return fmt.Errorf("member with ID %d not found", id)
+		}
+	}
+	_, err := d.db.Exec(`INSERT OR IGNORE INTO delegates(owner_id, delegate_id) VALUES(?,?)`, ownerID, delegateID)
+	return err
+}
+
+// RemoveDelegate withdraws a delegation made with AddDelegate.
+func (d *Database) RemoveDelegate(ownerID, delegateID int64) error {
+	res, err := d.db.Exec(`DELETE FROM delegates WHERE owner_id=? AND delegate_id=?`, ownerID, delegateID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("member %d is not a delegate of member %d", delegateID, ownerID)
+	}
+	return nil
+}
+
+// GetDelegates lists the members ownerID has authorized to act for them.
+func (d *Database) GetDelegates(ownerID int64) ([]*Member, error) {
+	rows, err := d.db.Query(`SELECT m.id, m.name FROM delegates g JOIN members m ON g.delegate_id = m.id
+                             WHERE g.owner_id=? ORDER BY m.name`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var members []*Member
+	for rows.Next() {
+		var m Member
+		if err := rows.Scan(&m.ID, &m.Name); err != nil {
+			return nil, err
+		}
+		members = append(members, &m)
+	}
+	return members, rows.Err()
+}
+
+// IsDelegate reports whether delegateID may act for ownerID.
+func (d *Database) IsDelegate(ownerID, delegateID int64) (bool, error) {
+	var n int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM delegates WHERE owner_id=? AND delegate_id=?`, ownerID, delegateID).Scan(&n)
+	return n > 0, err
+}
+
+// PickUpHold collects a hold waiting for pickup on bookID on behalf of its
+// owner. actingID must be the owner or one of their delegates; it is recorded
+// on the loan, which starts now. The checkout is audit-logged, and so is a
+// delegated pickup.
+func (d *Database) PickUpHold(bookID, actingID int64) (*Loan, error) {
+	return inTxResult(d, func(tx *sql.Tx) (*Loan, error) {
+		var checkoutID, ownerID int64
+		err := tx.QueryRow(`SELECT id, member_id FROM checkouts WHERE book_id=? AND status=? AND return_time IS NULL`,
+			bookID, LoanAwaitingPickup).Scan(&checkoutID, &ownerID)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no hold is waiting for pickup on book %d", bookID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if actingID != ownerID {
+			var n int
+			err := tx.QueryRow(`SELECT COUNT(*) FROM delegates WHERE owner_id=? AND delegate_id=?`, ownerID, actingID).Scan(&n)
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				// Don't reveal whose hold it is
+				return nil, fmt.Errorf("this hold is not yours to collect")
+			}
+		}
+		loan, err := d.collectPickup(tx, checkoutID, actingID)
+		if err != nil {
+			return nil, err
+		}
+		detail := fmt.Sprintf("collected book %d from the hold shelf", bookID)
+		if err := recordAuditOf(tx, actingID, AuditCheckout, ownerID, bookID, detail); err != nil {
+			return nil, err
+		}
+		if actingID != ownerID {
+			detail := fmt.Sprintf("collected book %d for member %d", bookID, ownerID)
+			if err := recordAuditOf(tx, actingID, AuditDelegatePickup, ownerID, bookID, detail); err != nil {
+				return nil, err
+			}
+		}
+		return loan, nil
+	})
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) AddDelegate(ownerID, delegateID int64) error {
+	return lm.db.AddDelegate(ownerID, delegateID)
+}
+
+func (lm *LibraryManager) RemoveDelegate(ownerID, delegateID int64) error {
+	return lm.db.RemoveDelegate(ownerID, delegateID)
+}
+
+func (lm *LibraryManager) GetDelegates(ownerID int64) ([]*Member, error) {
+	return lm.db.GetDelegates(ownerID)
+}
+
+func (lm *LibraryManager) PickUpHold(bookID, actingID int64) (*Loan, error) {
+	return lm.db.PickUpHold(bookID, actingID)
+}
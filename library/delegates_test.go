@@ -0,0 +1,86 @@
+package library
+
+import "testing"
+
+// A member collecting their own hold is audited as a checkout, like any
+// other way a hold is collected.
+func TestOwnPickupIsAudited(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+	bookID, _ := db.AddBook("Dune", "Herbert", "")
+
+	db.CheckoutBook(bookID, alice)
+	lm.ReserveBook(bookID, bob)
+	lm.ReturnBook(bookID, alice)
+	if _, err := db.PickUpHold(bookID, bob); err != nil {
+		t.Fatalf("pickup: %v", err)
+	}
+	entries, _ := db.SearchAuditLog(AuditFilter{MemberID: bob})
+	if len(entries) != 1 || entries[0].Action != AuditCheckout || entries[0].ActorID != bob || entries[0].BookID != bookID {
+		t.Fatalf("Bob's entries = %+v", entries)
+	}
+}
+
+func TestDelegatedPickupAndReturn(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	parent, _ := db.AddMember("Parent", "password")
+	child, _ := db.AddMember("Child", "password")
+	other, _ := db.AddMember("Other", "password")
+	bookID, _ := db.AddBook("Picture Book", "Author", "")
+
+	if err := db.AddDelegate(child, parent); err != nil {
+		t.Fatalf("add delegate: %v", err)
+	}
+	if err := db.AddDelegate(child, child); err == nil {
+		t.Fatalf("a member should not be their own delegate")
+	}
+
+	// The child's hold waits in the locker
+	db.SetLockerPickup(true)
+	db.CheckoutBook(bookID, other)
+	lm.ReserveBook(bookID, child)
+	lm.ReturnBook(bookID, other)
+
+	if _, err := db.PickUpHold(bookID, other); err == nil {
+		t.Fatalf("a stranger must not collect the hold")
+	}
+	loan, err := db.PickUpHold(bookID, parent)
+	if err != nil {
+		t.Fatalf("delegate pickup: %v", err)
+	}
+	if loan.MemberID != child || loan.PickedUpBy != parent || loan.Status != LoanActive {
+		t.Fatalf("unexpected loan: %+v", loan)
+	}
+
+	if _, err := lm.ReturnBook(bookID, other); err == nil {
+		t.Fatalf("a stranger must not return the book")
+	}
+	if _, err := lm.ReturnBook(bookID, parent); err != nil {
+		t.Fatalf("delegate return: %v", err)
+	}
+	var returnedBy int64
+	db.db.QueryRow(`SELECT returned_by FROM checkouts WHERE id=?`, loan.CheckoutID).Scan(&returnedBy)
+	if returnedBy != parent {
+		t.Fatalf("returned_by = %d, want the parent %d", returnedBy, parent)
+	}
+
+	// After the book was added, lent to and returned by the other member
+	entries, _ := db.GetAuditLog(10)
+	if len(entries) != 6 || entries[0].Action != AuditDelegateReturn || entries[1].Action != AuditDelegatePickup ||
+		entries[0].ActorID != parent || entries[0].MemberID != child || entries[1].BookID != bookID ||
+		entries[2].Action != AuditCheckout || entries[2].ActorID != parent || entries[2].MemberID != child {
+		t.Fatalf("unexpected audit log: %+v", entries)
+	}
+
+	// Withdrawn delegation no longer counts
+	if err := db.RemoveDelegate(child, parent); err != nil {
+		t.Fatalf("remove delegate: %v", err)
+	}
+	db.CheckoutBook(bookID, child)
+	if _, err := lm.ReturnBook(bookID, parent); err == nil {
+		t.Fatalf("expected the return to be refused after the delegation was withdrawn")
+	}
+}
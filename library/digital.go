@@ -0,0 +1,254 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// defaultDigitalLoanDays is how long a digital loan holds one of a title's
+// licenses unless configured otherwise.
+const defaultDigitalLoanDays = 14
+
+// NoticeDigitalReturned tells a member their digital loan ended on its own.
+const NoticeDigitalReturned = "digital_returned"
+
+// DigitalLoan is a member's time-limited e-reading license for a book.
+type DigitalLoan struct {
+	ID         int64     `json:"id"`
+	BookID     int64     `json:"book_id"`
+	BookTitle  string    `json:"book_title"`
+	MemberID   int64     `json:"member_id"`
+	StartTime  time.Time `json:"start_time"`
+	ExpiryTime time.Time `json:"expiry_time"`
+}
+
+// SetDigitalLoanDays sets the length of new digital loans; zero or less
+// restores the default.
+func (d *Database) SetDigitalLoanDays(days int) { d.digitalLoanDays = days }
+
+// expireDigitalLoans returns every digital loan whose time is up. Expiry is
+// applied lazily whenever digital loans are accessed and by the scheduled
+// job, so members never have to return e-content by hand.
+func (d *Database) expireDigitalLoans(ex interface {
+	Exec(string, ...any) (sql.Result, error)
+}) error {
+	_, err := ex.Exec(`UPDATE digital_loans SET return_time = expiry_time
+                       WHERE return_time IS NULL AND expiry_time <= ?`, d.sqlNow())
+	return err
+}
+
+// SetDigitalLicenses sets how many members may read bookID digitally at the
+// same time. Zero turns digital lending off for the title.
+func (d *Database) SetDigitalLicenses(bookID int64, licenses int) error {
+	if licenses < 0 {
+		return fmt.Errorf("number of licenses cannot be negative")
+	}
+	res, err := d.db.Exec(`UPDATE books SET digital_licenses=? WHERE id=?`, licenses, bookID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("book not found")
+	}
+	return nil
+}
+
+// AcquireDigitalLoan returns memberID's current digital loan for bookID, or
+// takes a free license for them. A license frees up again once its loan
+// expires. Like a checkout it needs an active, unexpired membership.
+func (d *Database) AcquireDigitalLoan(bookID, memberID int64) (*DigitalLoan, error) {
+	return inTxResult(d, func(tx *sql.Tx) (*DigitalLoan, error) {
+		if err := d.expireDigitalLoans(tx); err != nil {
+			return nil, err
+		}
+		if err := d.checkMembershipActive(tx, memberID); err != nil {
+			return nil, err
+		}
+
+		var licenses int
+		err := tx.QueryRow(`SELECT digital_licenses FROM books WHERE id=?`, bookID).Scan(&licenses)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("book not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if licenses == 0 {
+			return nil, fmt.Errorf("book is not available for digital lending")
+		}
+
+		const activeLoans = `SELECT dl.id, dl.book_id, b.title, dl.member_id, dl.start_time, dl.expiry_time
+	                         FROM digital_loans dl
+	                         JOIN books b ON dl.book_id = b.id
+	                         WHERE dl.book_id = ? AND dl.return_time IS NULL`
+
+		var l DigitalLoan
+		err = tx.QueryRow(activeLoans+` AND dl.member_id = ?`, bookID, memberID).
+			Scan(&l.ID, &l.BookID, &l.BookTitle, &l.MemberID, &l.StartTime, &l.ExpiryTime)
+		if err == nil {
+			return &l, nil
+		}
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+
+		var inUse int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM (`+activeLoans+`)`, bookID).Scan(&inUse); err != nil {
+			return nil, err
+		}
+		if inUse >= licenses {
+			return nil, fmt.Errorf("all %d digital copies are in use, please try again later", licenses)
+		}
+
+		days := defaultDigitalLoanDays
+		if d.digitalLoanDays > 0 {
+			days = d.digitalLoanDays
+		}
+		now := d.sqlNow()
+		res, err := tx.Exec(`INSERT INTO digital_loans(book_id, member_id, start_time, expiry_time)
+	                         VALUES(?, ?, ?, datetime(?, ?))`,
+			bookID, memberID, now, now, fmt.Sprintf("+%d days", days))
+		if err != nil {
+			return nil, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		err = tx.QueryRow(`SELECT dl.id, dl.book_id, b.title, dl.member_id, dl.start_time, dl.expiry_time
+	                       FROM digital_loans dl JOIN books b ON dl.book_id = b.id
+	                       WHERE dl.id = ?`, id).
+			Scan(&l.ID, &l.BookID, &l.BookTitle, &l.MemberID, &l.StartTime, &l.ExpiryTime)
+		if err != nil {
+			return nil, err
+		}
+		return &l, nil
+	})
+}
+
+// GetDigitalLoans lists memberID's unexpired digital loans.
+func (d *Database) GetDigitalLoans(memberID int64) ([]*DigitalLoan, error) {
+	if err := d.expireDigitalLoans(d.db); err != nil {
+		return nil, err
+	}
+	rows, err := d.db.Query(`SELECT dl.id, dl.book_id, b.title, dl.member_id, dl.start_time, dl.expiry_time
+                             FROM digital_loans dl
+                             JOIN books b ON dl.book_id = b.id
+                             WHERE dl.member_id = ? AND dl.return_time IS NULL
+                             ORDER BY dl.expiry_time`, memberID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var loans []*DigitalLoan
+	for rows.Next() {
+		var l DigitalLoan
+		if err := rows.Scan(&l.ID, &l.BookID, &l.BookTitle, &l.MemberID, &l.StartTime, &l.ExpiryTime); err != nil {
+			return nil, err
+		}
+		loans = append(loans, &l)
+	}
+	return loans, rows.Err()
+}
+
+// ReturnDigitalLoan ends memberID's digital loan of bookID early, freeing
+// the license for the next reader.
+func (d *Database) ReturnDigitalLoan(bookID, memberID int64) error {
+	if err := d.expireDigitalLoans(d.db); err != nil {
+		return err
+	}
+	res, err := d.db.Exec(`UPDATE digital_loans SET return_time = ?, return_notified = 1
+                           WHERE book_id = ? AND member_id = ? AND return_time IS NULL`, d.sqlNow(), bookID, memberID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("no active digital loan found for this book and member")
+	}
+	return nil
+}
+
+// SendDigitalReturnNotices expires finished digital loans and tells each
+// member that their loan has been returned automatically.
+func (lm *LibraryManager) SendDigitalReturnNotices(n Notifier) (int, error) {
+	if err := lm.db.expireDigitalLoans(lm.db.db); err != nil {
+		return 0, err
+	}
+
+	rows, err := lm.db.db.Query(`SELECT dl.id, dl.member_id, m.name, b.title
+                                 FROM digital_loans dl
+                                 JOIN members m ON dl.member_id = m.id
+                                 JOIN books b ON dl.book_id = b.id
+                                 WHERE dl.return_time IS NOT NULL AND dl.return_notified = 0
+                                 ORDER BY dl.id`)
+	if err != nil {
+		return 0, err
+	}
+	type returned struct {
+		id, memberID int64
+		name, title  string
+	}
+	var due []returned
+	for rows.Next() {
+		var r returned
+		if err := rows.Scan(&r.id, &r.memberID, &r.name, &r.title); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, r := range due {
+		notice := Notice{
+			MemberID:   r.memberID,
+			MemberName: r.name,
+			Kind:       NoticeDigitalReturned,
+			Subject:    fmt.Sprintf("Your digital loan of '%s' has ended", r.title),
+			Body:       fmt.Sprintf("Your digital loan of '%s' expired and was returned automatically. Read it again to borrow it for another period if a copy is free.", r.title),
+		}
+		if err := n.Notify(notice); err != nil {
+			return sent, fmt.Errorf("notify member %d: %w", r.memberID, err)
+		}
+		if _, err := lm.db.db.Exec(`UPDATE digital_loans SET return_notified = 1 WHERE id=?`, r.id); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// DigitalReturnJob wraps SendDigitalReturnNotices as a Job for the JobRunner.
+func (lm *LibraryManager) DigitalReturnJob(n Notifier, interval time.Duration) *Job {
+	return &Job{
+		Name:     "digital-returns",
+		Interval: interval,
+		Run: func() error {
+			_, err := lm.SendDigitalReturnNotices(n)
+			return err
+		},
+	}
+}
+
+// ------------------ Manager helpers ------------------
+
+// SetDigitalLoanDays sets the length of new digital loans.
+func (lm *LibraryManager) SetDigitalLoanDays(days int) { lm.db.SetDigitalLoanDays(days) }
+
+func (lm *LibraryManager) ReturnDigitalLoan(bookID, memberID int64) error {
+	return lm.db.ReturnDigitalLoan(bookID, memberID)
+}
+
+func (lm *LibraryManager) SetDigitalLicenses(bookID int64, licenses int) error {
+	return lm.db.SetDigitalLicenses(bookID, licenses)
+}
+
+func (lm *LibraryManager) GetDigitalLoans(memberID int64) ([]*DigitalLoan, error) {
+	return lm.db.GetDigitalLoans(memberID)
+}
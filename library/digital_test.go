@@ -0,0 +1,94 @@
+package library
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDigitalLoanLicenses(t *testing.T) {
+	db := tempDB(t)
+	bookID, _ := db.AddBook("E-Book", "Author", "content")
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+	carol, _ := db.AddMember("Carol", "password")
+
+	if _, err := db.AcquireDigitalLoan(bookID, alice); err == nil {
+		t.Fatalf("unlicensed book should not lend digitally")
+	}
+	if err := db.SetDigitalLicenses(bookID, 2); err != nil {
+		t.Fatalf("set licenses: %v", err)
+	}
+
+	first, err := db.AcquireDigitalLoan(bookID, alice)
+	if err != nil {
+		t.Fatalf("alice acquire: %v", err)
+	}
+	if again, err := db.AcquireDigitalLoan(bookID, alice); err != nil || again.ID != first.ID {
+		t.Fatalf("re-reading should reuse the loan: %+v, err=%v", again, err)
+	}
+	if _, err := db.AcquireDigitalLoan(bookID, bob); err != nil {
+		t.Fatalf("bob acquire: %v", err)
+	}
+	if _, err := db.AcquireDigitalLoan(bookID, carol); err == nil {
+		t.Fatalf("third reader should wait for a free license")
+	}
+
+	// The physical copy is unaffected
+	if b, _ := db.GetBook(bookID); !b.Available {
+		t.Fatalf("digital loans must not take the physical copy")
+	}
+
+	// An expired loan frees its license
+	db.db.Exec(`UPDATE digital_loans SET expiry_time = datetime('now', '-1 minute') WHERE id = ?`, first.ID)
+	if _, err := db.AcquireDigitalLoan(bookID, carol); err != nil {
+		t.Fatalf("carol should get the expired license: %v", err)
+	}
+	if loans, _ := db.GetDigitalLoans(alice); len(loans) != 0 {
+		t.Fatalf("alice's expired loan should not be listed, got %d", len(loans))
+	}
+}
+
+func TestDigitalLoanAutoReturn(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	db.SetDigitalLoanDays(3)
+	bookID, _ := db.AddBook("E-Book", "Author", "content")
+	db.SetDigitalLicenses(bookID, 1)
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+
+	loan, err := db.AcquireDigitalLoan(bookID, alice)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if got := loan.ExpiryTime.Sub(loan.StartTime); got != 3*24*time.Hour {
+		t.Fatalf("digital loan period = %v, want 3 days", got)
+	}
+
+	// Early return frees the license without a notice
+	if err := db.ReturnDigitalLoan(bookID, alice); err != nil {
+		t.Fatalf("early return: %v", err)
+	}
+	if err := db.ReturnDigitalLoan(bookID, alice); err == nil {
+		t.Fatalf("returning twice should fail")
+	}
+	if _, err := db.AcquireDigitalLoan(bookID, bob); err != nil {
+		t.Fatalf("bob acquire: %v", err)
+	}
+
+	db.db.Exec(`UPDATE digital_loans SET expiry_time = datetime('now', '-1 minute') WHERE member_id = ?`, bob)
+	rec := &recordingNotifier{}
+	sent, err := lm.SendDigitalReturnNotices(rec)
+	if err != nil || sent != 1 {
+		t.Fatalf("sent=%d err=%v", sent, err)
+	}
+	if rec.notices[0].MemberID != bob || rec.notices[0].Kind != NoticeDigitalReturned {
+		t.Fatalf("unexpected notice: %+v", rec.notices[0])
+	}
+	if sent, _ := lm.SendDigitalReturnNotices(rec); sent != 0 {
+		t.Fatalf("auto-return notice should only be sent once, sent %d", sent)
+	}
+	if _, err := db.AcquireDigitalLoan(bookID, alice); err != nil {
+		t.Fatalf("license should be free after auto-return: %v", err)
+	}
+}
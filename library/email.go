@@ -0,0 +1,254 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// reminders.kind values for emails, kept apart from the in-app notices so
+// each channel is sent once per loan.
+const (
+	EmailDueSoon   = "email_due_soon"
+	EmailOverdue   = "email_overdue"
+	EmailHoldReady = "email_hold_ready"
+)
+
+// EmailPreferences is where a member wants reminders emailed and which
+// ones. An empty Email turns email off.
+type EmailPreferences struct {
+	Email     string `json:"email,omitempty"`
+	DueSoon   bool   `json:"due_soon"`
+	Overdue   bool   `json:"overdue"`
+	HoldReady bool   `json:"hold_ready"`
+	// QueueMoved: moving up a reservation queue; see QueueNotifier
+	QueueMoved bool `json:"queue_moved"`
+}
+
+// GetEmailPreferences returns memberID's email settings.
+func (d *Database) GetEmailPreferences(memberID int64) (*EmailPreferences, error) {
+	var p EmailPreferences
+	var email sql.NullString
+	err := d.db.QueryRow(`SELECT email, email_due_soon, email_overdue, email_hold_ready, email_queue_moved FROM members WHERE id=?`, memberID).
+		Scan(&email, &p.DueSoon, &p.Overdue, &p.HoldReady, &p.QueueMoved)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("member with ID %d not found", memberID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.Email = email.String
+	return &p, nil
+}
+
+// SetEmailPreferences replaces memberID's email settings. The address must
+// be a plain address such as name@example.org.
+func (d *Database) SetEmailPreferences(memberID int64, p EmailPreferences) error {
+	p.Email = strings.TrimSpace(p.Email)
+	if p.Email != "" {
+		addr, err := mail.ParseAddress(p.Email)
+		if err != nil || addr.Address != p.Email {
+			return fmt.Errorf("invalid email address %q", p.Email)
+		}
+	}
+	res, err := d.db.Exec(`UPDATE members SET email=NULLIF(?, ''), email_due_soon=?, email_overdue=?, email_hold_ready=?, email_queue_moved=?
+	                       WHERE id=?`,
+		p.Email, p.DueSoon, p.Overdue, p.HoldReady, p.QueueMoved, memberID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("member with ID %d not found", memberID)
+	}
+	return nil
+}
+
+// SMTPConfig says how to reach the mail server.
+type SMTPConfig struct {
+	Addr     string // host:port
+	From     string // sender address
+	Username string // optional; PLAIN auth is used when set
+	Password string
+}
+
+// SMTPNotifier emails notices to members' addresses on file.
+type SMTPNotifier struct {
+	db  *Database
+	cfg SMTPConfig
+	// send delivers a message; smtp.SendMail unless a test replaces it.
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTPNotifier returns a Notifier that emails notices through cfg.
+func (lm *LibraryManager) NewSMTPNotifier(cfg SMTPConfig) (*SMTPNotifier, error) {
+	if cfg.Addr == "" {
+		return nil, fmt.Errorf("SMTP server address is required")
+	}
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return nil, fmt.Errorf("invalid sender address %q", cfg.From)
+	}
+	return &SMTPNotifier{db: lm.db, cfg: cfg, send: smtp.SendMail}, nil
+}
+
+// Notify implements Notifier. It fails for members without an address.
+func (sn *SMTPNotifier) Notify(n Notice) error {
+	var email sql.NullString
+	if err := sn.db.db.QueryRow(`SELECT email FROM members WHERE id=?`, n.MemberID).Scan(&email); err != nil {
+		return err
+	}
+	if email.String == "" {
+		return fmt.Errorf("member %d has no email address", n.MemberID)
+	}
+
+	var auth smtp.Auth
+	if sn.cfg.Username != "" {
+		host, _, _ := strings.Cut(sn.cfg.Addr, ":")
+		auth = smtp.PlainAuth("", sn.cfg.Username, sn.cfg.Password, host)
+	}
+	from, _ := mail.ParseAddress(sn.cfg.From)
+	to := mail.Address{Name: n.MemberName, Address: email.String}
+	// Book titles end up in the subject; keep them on one header line
+	subject := strings.Join(strings.Fields(n.Subject), " ")
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to.String())
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", sn.db.now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(n.Body, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	return sn.send(sn.cfg.Addr, auth, from.Address, []string{email.String}, []byte(msg.String()))
+}
+
+// emailLoan is an open checkout a member has asked to be emailed about.
+type emailLoan struct {
+	checkoutID int64
+	title      string
+	memberID   int64
+	memberName string
+	due        sql.NullTime // not set for holds awaiting pickup
+	holdExpiry sql.NullTime
+}
+
+// loansToEmail lists the open checkouts matching cond whose borrowers have
+// an address and the preference column pref switched on, and which have
+// had no reminder of kind yet.
+func (d *Database) loansToEmail(kind, pref, cond string, args ...any) ([]*emailLoan, error) {
+	rows, err := d.db.Query(`SELECT c.id, b.title, m.id, m.name, c.due_time, c.hold_expiry_time
+	                         FROM checkouts c
+	                         JOIN books b ON c.book_id = b.id
+	                         JOIN members m ON c.member_id = m.id
+	                         WHERE c.return_time IS NULL AND m.email IS NOT NULL AND m.`+pref+` = 1 AND `+cond+`
+	                           AND NOT EXISTS (SELECT 1 FROM reminders r WHERE r.checkout_id = c.id AND r.kind = ?)
+	                         ORDER BY c.id`, append(args, kind)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var loans []*emailLoan
+	for rows.Next() {
+		var l emailLoan
+		if err := rows.Scan(&l.checkoutID, &l.title, &l.memberID, &l.memberName, &l.due, &l.holdExpiry); err != nil {
+			return nil, err
+		}
+		loans = append(loans, &l)
+	}
+	return loans, rows.Err()
+}
+
+// SendEmailReminders emails members who asked for them about loans falling
+// due within lead, overdue loans and holds ready for pickup, once per loan
+// and kind. It returns the number of emails sent.
+func (lm *LibraryManager) SendEmailReminders(n Notifier, lead time.Duration) (int, error) {
+	d := lm.db
+	now := d.sqlNow()
+	date := func(t time.Time) string { return d.localTime(t).Format("2006-01-02 15:04 MST") }
+
+	batches := []struct {
+		kind, notice, pref, cond string
+		args                     []any
+		render                   func(*emailLoan) (string, string)
+	}{
+		{EmailDueSoon, ReminderDueSoon, "email_due_soon",
+			`c.status = 'active' AND c.due_time > ? AND c.due_time <= datetime(?, ?)`,
+			[]any{now, now, fmt.Sprintf("+%d seconds", int64(lead.Seconds()))},
+			func(l *emailLoan) (string, string) {
+				return fmt.Sprintf("'%s' is due soon", l.title),
+					fmt.Sprintf("Please return or renew '%s' by %s.", l.title, date(l.due.Time))
+			}},
+		{EmailOverdue, NoticeOverdue, "email_overdue",
+			`c.status = 'active' AND c.due_time <= ?`,
+			[]any{now},
+			func(l *emailLoan) (string, string) {
+				return fmt.Sprintf("'%s' is overdue", l.title),
+					fmt.Sprintf("'%s' was due on %s. Please return it as soon as possible.", l.title, date(l.due.Time))
+			}},
+		{EmailHoldReady, NoticeHoldReady, "email_hold_ready",
+			`c.status = 'awaiting_pickup' AND c.hold_expiry_time > ?`,
+			[]any{now},
+			func(l *emailLoan) (string, string) {
+				return fmt.Sprintf("'%s' is ready for you", l.title),
+					fmt.Sprintf("Your reservation for '%s' is on hold for you until %s. Check it out by then, or it passes to the next member waiting.",
+						l.title, date(l.holdExpiry.Time))
+			}},
+	}
+
+	sent := 0
+	for _, b := range batches {
+		loans, err := d.loansToEmail(b.kind, b.pref, b.cond, b.args...)
+		if err != nil {
+			return sent, fmt.Errorf("find %s emails: %w", b.notice, err)
+		}
+		for _, l := range loans {
+			claimed, err := d.ClaimReminder(l.checkoutID, b.kind)
+			if err != nil {
+				return sent, err
+			}
+			if !claimed {
+				continue
+			}
+			subject, body := b.render(l)
+			notice := Notice{MemberID: l.memberID, MemberName: l.memberName, Kind: b.notice, Subject: subject, Body: body}
+			if err := n.Notify(notice); err != nil {
+				// Let the next run try again instead of silently dropping it.
+				if relErr := d.ReleaseReminder(l.checkoutID, b.kind); relErr != nil {
+					return sent, relErr
+				}
+				return sent, fmt.Errorf("email member %d: %w", l.memberID, err)
+			}
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// EmailReminderJob wraps SendEmailReminders as a Job for the JobRunner.
+func (lm *LibraryManager) EmailReminderJob(n Notifier, lead, interval time.Duration) *Job {
+	return &Job{
+		Name:     "email-reminders",
+		Interval: interval,
+		Run: func() error {
+			_, err := lm.SendEmailReminders(n, lead)
+			return err
+		},
+	}
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) GetEmailPreferences(memberID int64) (*EmailPreferences, error) {
+	return lm.db.GetEmailPreferences(memberID)
+}
+
+func (lm *LibraryManager) SetEmailPreferences(memberID int64, p EmailPreferences) error {
+	return lm.db.SetEmailPreferences(memberID, p)
+}
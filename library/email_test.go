@@ -0,0 +1,114 @@
+package library
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sentMail struct {
+	to  []string
+	msg string
+}
+
+func testSMTPNotifier(t *testing.T, lm *LibraryManager, outbox *[]sentMail) *SMTPNotifier {
+	t.Helper()
+	sn, err := lm.NewSMTPNotifier(SMTPConfig{Addr: "mail.example.org:25", From: "desk@library.example"})
+	if err != nil {
+		t.Fatalf("new notifier: %v", err)
+	}
+	sn.send = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		if outbox == nil {
+			return fmt.Errorf("connection refused")
+		}
+		*outbox = append(*outbox, sentMail{to, string(msg)})
+		return nil
+	}
+	return sn
+}
+
+func TestEmailPreferences(t *testing.T) {
+	db := tempDB(t)
+	alice, _ := db.AddMember("Alice", "password")
+
+	p, err := db.GetEmailPreferences(alice)
+	if err != nil || p.Email != "" || !p.DueSoon || !p.Overdue || !p.HoldReady {
+		t.Fatalf("defaults: %+v %v", p, err)
+	}
+	for _, bad := range []string{"alice", "Alice <alice@example.org>", "alice@example.org\r\nBcc: x@example.org"} {
+		if err := db.SetEmailPreferences(alice, EmailPreferences{Email: bad}); err == nil {
+			t.Errorf("accepted %q", bad)
+		}
+	}
+	if err := db.SetEmailPreferences(alice, EmailPreferences{Email: " alice@example.org ", Overdue: true}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	p, _ = db.GetEmailPreferences(alice)
+	if *p != (EmailPreferences{Email: "alice@example.org", Overdue: true}) {
+		t.Fatalf("saved %+v", p)
+	}
+	if err := db.SetEmailPreferences(999, EmailPreferences{}); err == nil {
+		t.Fatalf("expected an error for a missing member")
+	}
+}
+
+func TestSendEmailReminders(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	clock := NewFixedClock(time.Date(2030, 1, 10, 12, 0, 0, 0, time.UTC))
+	db.SetClock(clock)
+	db.SetTimezone(time.UTC)
+
+	soon, _ := db.AddBook("Dune", "Herbert", "content")
+	late, _ := db.AddBook("Emma", "Austen", "content")
+	held, _ := db.AddBook("Ulysses", "Joyce", "content")
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+	carol, _ := db.AddMember("Carol", "password")
+	db.SetEmailPreferences(alice, EmailPreferences{Email: "alice@example.org", DueSoon: true, Overdue: true, HoldReady: true})
+	// Bob only wants to hear about holds; Carol has no address
+	db.SetEmailPreferences(bob, EmailPreferences{Email: "bob@example.org", HoldReady: true})
+
+	db.CheckoutBook(soon, alice)
+	db.CheckoutBook(late, bob)
+	db.CheckoutBook(held, carol)
+	db.ReserveBook(held, alice)
+	db.db.Exec(`UPDATE checkouts SET due_time='2030-01-11 09:00:00' WHERE book_id=?`, soon)
+	db.db.Exec(`UPDATE checkouts SET due_time='2030-01-05 09:00:00' WHERE book_id IN (?, ?)`, late, held)
+	db.ReturnBook(held)
+
+	var outbox []sentMail
+	sn := testSMTPNotifier(t, lm, &outbox)
+	sent, err := lm.SendEmailReminders(sn, 48*time.Hour)
+	if err != nil || sent != 2 || len(outbox) != 2 {
+		t.Fatalf("sent=%d err=%v outbox=%+v", sent, err, outbox)
+	}
+	if outbox[0].to[0] != "alice@example.org" || !strings.Contains(outbox[0].msg, "Subject: 'Dune' is due soon\r\n") ||
+		!strings.Contains(outbox[0].msg, "by 2030-01-11 09:00 UTC.") || !strings.Contains(outbox[0].msg, `To: "Alice" <alice@example.org>`) {
+		t.Fatalf("due soon email: %q", outbox[0].msg)
+	}
+	if !strings.Contains(outbox[1].msg, "Subject: 'Ulysses' is ready for you\r\n") {
+		t.Fatalf("hold email: %q", outbox[1].msg)
+	}
+
+	// Each email goes once, whatever the in-app notices did
+	if sent, _ := lm.SendEmailReminders(sn, 48*time.Hour); sent != 0 {
+		t.Fatalf("emailed twice")
+	}
+	lm.SendDueReminders(lm.NewInboxNotifier(), 48*time.Hour)
+	if n, _ := db.CountUnreadNotifications(alice); n == 0 {
+		t.Fatalf("in-app reminder suppressed by the email")
+	}
+
+	// Failed deliveries are retried on the next run
+	clock.Advance(24 * time.Hour)
+	if _, err := lm.SendEmailReminders(testSMTPNotifier(t, lm, nil), 48*time.Hour); err == nil {
+		t.Fatalf("expected a delivery error")
+	}
+	outbox = nil
+	if sent, err := lm.SendEmailReminders(sn, 48*time.Hour); err != nil || sent != 1 || !strings.Contains(outbox[0].msg, "'Dune' is overdue") {
+		t.Fatalf("overdue email not retried: %d %v %+v", sent, err, outbox)
+	}
+}
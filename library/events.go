@@ -0,0 +1,170 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Event kinds published to subscribers.
+const (
+	EventCheckout  = "checkout"   // a loan started, including a hold being collected
+	EventReturn    = "return"     // a loan ended with the item back in the library
+	EventHoldReady = "hold_ready" // a returned item was put on hold for a member
+	EventOverdue   = "overdue"    // a loan was first found overdue
+	// EventQueueMoved: members moved up reservation queues because a
+	// reservation ahead of theirs was cancelled or fulfilled
+	EventQueueMoved = "queue_moved"
+)
+
+// Event is something that happened to a loan, or for EventQueueMoved, to
+// reservation queues.
+type Event struct {
+	Kind  string
+	Time  time.Time   // by the library's clock
+	Loan  *Loan       // as it stands after the event; nil for EventQueueMoved
+	Moves []QueueMove // EventQueueMoved only
+}
+
+// EventHandler is called with each event it subscribed to, on the goroutine
+// that caused it and after the change is committed. Handlers should return
+// quickly; hand slow work such as sending mail to another goroutine.
+type EventHandler func(Event)
+
+// eventBus holds the subscribers for each event kind, and the events raised
+// by each open transaction until it commits.
+type eventBus struct {
+	mu       sync.Mutex
+	handlers map[string][]EventHandler
+	pending  map[*sql.Tx][]Event
+}
+
+func (b *eventBus) subscribe(kind string, h EventHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.handlers == nil {
+		b.handlers = make(map[string][]EventHandler)
+	}
+	b.handlers[kind] = append(b.handlers[kind], h)
+}
+
+// queue holds e until tx commits.
+func (b *eventBus) queue(tx *sql.Tx, e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending == nil {
+		b.pending = make(map[*sql.Tx][]Event)
+	}
+	b.pending[tx] = append(b.pending[tx], e)
+}
+
+// take removes and returns the events queued for tx.
+func (b *eventBus) take(tx *sql.Tx) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	events := b.pending[tx]
+	delete(b.pending, tx)
+	return events
+}
+
+// publish calls the subscribers of each event in turn.
+func (b *eventBus) publish(events ...Event) {
+	for _, e := range events {
+		b.mu.Lock()
+		handlers := b.handlers[e.Kind]
+		b.mu.Unlock()
+		for _, h := range handlers {
+			h(e)
+		}
+	}
+}
+
+// OnCheckout subscribes h to loans starting.
+func (d *Database) OnCheckout(h EventHandler) { d.events.subscribe(EventCheckout, h) }
+
+// OnReturn subscribes h to items coming back.
+func (d *Database) OnReturn(h EventHandler) { d.events.subscribe(EventReturn, h) }
+
+// OnHoldReady subscribes h to returned items going on hold for the next
+// member waiting.
+func (d *Database) OnHoldReady(h EventHandler) { d.events.subscribe(EventHoldReady, h) }
+
+// OnOverdue subscribes h to loans falling overdue. The event is raised once
+// per loan, when the overdue notices job first finds it.
+func (d *Database) OnOverdue(h EventHandler) { d.events.subscribe(EventOverdue, h) }
+
+// OnQueueMoved subscribes h to members moving up reservation queues. One
+// event carries every move a change caused.
+func (d *Database) OnQueueMoved(h EventHandler) { d.events.subscribe(EventQueueMoved, h) }
+
+// queueLoanEvent raises an event of kind for checkoutID within tx, to be
+// published once tx commits.
+func (d *Database) queueLoanEvent(tx *sql.Tx, kind string, checkoutID int64) error {
+	loan, err := d.getLoan(tx, checkoutID)
+	if err != nil {
+		return err
+	}
+	d.events.queue(tx, Event{Kind: kind, Time: d.now(), Loan: loan})
+	return nil
+}
+
+// publishLoanEvent raises an event of kind for checkoutID outside any
+// transaction, publishing it straight away.
+func (d *Database) publishLoanEvent(kind string, checkoutID int64) error {
+	loan, err := d.getLoan(d.db, checkoutID)
+	if err != nil {
+		return err
+	}
+	d.events.publish(Event{Kind: kind, Time: d.now(), Loan: loan})
+	return nil
+}
+
+// EventLogger writes each event as a single timestamped line to W.
+type EventLogger struct {
+	mu sync.Mutex
+	W  io.Writer
+}
+
+// NewEventLogger returns an EventLogger that writes to w.
+func NewEventLogger(w io.Writer) *EventLogger {
+	return &EventLogger{W: w}
+}
+
+// Handle is an EventHandler.
+func (el *EventLogger) Handle(e Event) {
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	if e.Loan == nil {
+		for _, m := range e.Moves {
+			fmt.Fprintf(el.W, "%s [%s] book %d: member %d from %d to %d\n",
+				e.Time.UTC().Format(time.RFC3339), e.Kind, m.BookID, m.MemberID, m.From, m.To)
+		}
+		return
+	}
+	fmt.Fprintf(el.W, "%s [%s] '%s' (book %d) for %s (ID: %d)\n",
+		e.Time.UTC().Format(time.RFC3339), e.Kind, e.Loan.BookTitle, e.Loan.BookID, e.Loan.MemberName, e.Loan.MemberID)
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) OnCheckout(h EventHandler) { lm.db.OnCheckout(h) }
+
+func (lm *LibraryManager) OnReturn(h EventHandler) { lm.db.OnReturn(h) }
+
+func (lm *LibraryManager) OnHoldReady(h EventHandler) { lm.db.OnHoldReady(h) }
+
+func (lm *LibraryManager) OnOverdue(h EventHandler) { lm.db.OnOverdue(h) }
+
+func (lm *LibraryManager) OnQueueMoved(h EventHandler) { lm.db.OnQueueMoved(h) }
+
+// LogEvents writes every event to w as it happens.
+func (lm *LibraryManager) LogEvents(w io.Writer) {
+	h := NewEventLogger(w).Handle
+	lm.OnCheckout(h)
+	lm.OnReturn(h)
+	lm.OnHoldReady(h)
+	lm.OnOverdue(h)
+	lm.OnQueueMoved(h)
+}
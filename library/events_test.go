@@ -0,0 +1,63 @@
+package library
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCirculationEvents(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	clock := NewFixedClock(time.Now())
+	db.SetClock(clock)
+
+	var got []string
+	record := func(e Event) {
+		got = append(got, e.Kind+":"+e.Loan.MemberName)
+	}
+	lm.OnCheckout(record)
+	lm.OnReturn(record)
+	lm.OnHoldReady(record)
+	lm.OnOverdue(record)
+
+	bookID, _ := db.AddBook("Book", "Author", "content")
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+
+	db.CheckoutBook(bookID, alice)
+	// Failed operations raise nothing
+	if err := db.CheckoutBook(bookID, bob); err == nil {
+		t.Fatalf("checked out a book on loan")
+	}
+	db.ReserveBook(bookID, bob)
+	db.ReturnBook(bookID)
+	db.CheckoutBook(bookID, bob)
+
+	clock.Advance((defaultLoanDays + 1) * 24 * time.Hour)
+	lm.SendOverdueNotices(&recordingNotifier{})
+	lm.SendOverdueNotices(&recordingNotifier{})
+
+	want := []string{"checkout:Alice", "return:Alice", "hold_ready:Bob", "checkout:Bob", "overdue:Bob"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("events = %q, want %q", got, want)
+	}
+}
+
+func TestEventLogger(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	db.SetClock(NewFixedClock(time.Date(2030, 5, 1, 12, 0, 0, 0, time.UTC)))
+	var buf bytes.Buffer
+	lm.LogEvents(&buf)
+
+	bookID, _ := db.AddBook("Dune", "Herbert", "content")
+	alice, _ := db.AddMember("Alice", "password")
+	db.CheckoutBook(bookID, alice)
+
+	want := "2030-05-01T12:00:00Z [checkout] 'Dune' (book 1) for Alice (ID: 1)\n"
+	if buf.String() != want {
+		t.Fatalf("log = %q, want %q", buf.String(), want)
+	}
+}
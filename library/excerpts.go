@@ -0,0 +1,168 @@
+package library
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Search results show where they matched. The search index is contentless,
+// so FTS5's highlight() and snippet() have no text to work from; instead the
+// terms of the query are found again in the title and text, split into
+// words much as the index's tokenizer splits them.
+
+const (
+	// searchExcerptWords is how many words of text an excerpt shows.
+	searchExcerptWords = 16
+	// searchExcerptResults caps the results given excerpts, as each needs
+	// the book's whole text.
+	searchExcerptResults = 20
+)
+
+// textWord is a word of a text, at bytes [start, end), folded to lower case.
+type textWord struct {
+	start, end int
+	folded     string
+}
+
+func splitWords(s string) []textWord {
+	var words []textWord
+	start := -1
+	for i, r := range s {
+		inWord := unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r)
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			words = append(words, textWord{start, i, strings.ToLower(s[start:i])})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, textWord{start, len(s), strings.ToLower(s[start:])})
+	}
+	return words
+}
+
+// matchSpans returns the runs of words, as [first, end) indexes into words,
+// where terms that may match field occur, in order and without overlaps.
+func matchSpans(words []textWord, terms []searchTerm, field string) [][2]int {
+	var spans [][2]int
+	for _, t := range terms {
+		if t.field != "" && t.field != field {
+			continue
+		}
+		want := splitWords(t.text)
+		if len(want) == 0 {
+			continue
+		}
+		for i := 0; i+len(want) <= len(words); i++ {
+			if termAt(words[i:], want, t.prefix) {
+				spans = append(spans, [2]int{i, i + len(want)})
+			}
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+
+	var merged [][2]int
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s[0] < merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], s[1])
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// termAt reports whether words starts with the words of a term; with
+// prefix set, its last word need only begin the text's word.
+func termAt(words, want []textWord, prefix bool) bool {
+	for k, w := range want {
+		if k == len(want)-1 && prefix {
+			if !strings.HasPrefix(words[k].folded, w.folded) {
+				return false
+			}
+		} else if words[k].folded != w.folded {
+			return false
+		}
+	}
+	return true
+}
+
+// markSpans returns s from word from up to word to, with the spans inside
+// that range in [brackets].
+func markSpans(s string, words []textWord, spans [][2]int, from, to int) string {
+	var b strings.Builder
+	next := 0
+	for next < len(spans) && spans[next][0] < from {
+		next++
+	}
+	open := false
+	for i := from; i < to; i++ {
+		if i > from {
+			b.WriteString(s[words[i-1].end:words[i].start])
+		}
+		if next < len(spans) && spans[next][0] == i && spans[next][1] <= to {
+			b.WriteByte('[')
+			open = true
+		}
+		b.WriteString(s[words[i].start:words[i].end])
+		if open && spans[next][1] == i+1 {
+			b.WriteByte(']')
+			open = false
+			next++
+		}
+	}
+	return b.String()
+}
+
+// highlightTitle returns title with the terms it matches in [brackets].
+func highlightTitle(title string, terms []searchTerm) string {
+	words := splitWords(title)
+	spans := matchSpans(words, terms, "title")
+	if len(spans) == 0 {
+		return title
+	}
+	// Keep any text before the first word and after the last
+	return title[:words[0].start] + markSpans(title, words, spans, 0, len(words)) + title[words[len(words)-1].end:]
+}
+
+// searchExcerpt returns searchExcerptWords words of text around where the
+// most terms match, in [brackets], or its opening words if none do.
+func searchExcerpt(text string, terms []searchTerm) string {
+	words := splitWords(text)
+	if len(words) == 0 {
+		return ""
+	}
+	spans := matchSpans(words, terms, "content")
+
+	from := 0
+	if len(spans) > 0 {
+		best, bestCount := 0, 0
+		for i, s := range spans {
+			count := 0
+			for _, t := range spans[i:] {
+				if t[1] > s[0]+searchExcerptWords {
+					break
+				}
+				count++
+			}
+			if count > bestCount {
+				best, bestCount = s[0], count
+			}
+		}
+		// A couple of words of lead-in
+		from = max(0, best-2)
+	}
+	to := min(len(words), from+searchExcerptWords)
+
+	excerpt := markSpans(text, words, spans, from, to)
+	if from > 0 {
+		excerpt = "…" + excerpt
+	}
+	if to < len(words) {
+		excerpt += "…"
+	}
+	return excerpt
+}
@@ -0,0 +1,53 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSearchExcerpt(t *testing.T) {
+	bq, err := ParseBookQuery(`"great machines" melan* -worms title:arrakis`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	text := "Prologue. The spice must flow. Arrakis is a desert planet where the spice melange is harvested by great machines while worms roam beneath the dunes of the deep desert, far from any city."
+
+	got := searchExcerpt(text, bq.terms)
+	want := "…the spice [melange] is harvested by [great machines] while worms roam beneath the dunes of the…"
+	if got != want {
+		t.Errorf("excerpt:\n got %q\nwant %q", got, want)
+	}
+	if got := searchExcerpt("Just a short text.", bq.terms); got != "Just a short text" {
+		t.Errorf("excerpt without matches = %q", got)
+	}
+	if got := highlightTitle("Arrakis: A History", bq.terms); got != "[Arrakis]: A History" {
+		t.Errorf("title = %q", got)
+	}
+}
+
+func TestSearchBookResults(t *testing.T) {
+	db := tempDB(t)
+	dune, _ := db.AddBook("Dune", "Herbert", "The spice must flow across the desert of Arrakis.")
+	db.AddBook("Spice Trade", "Historian", "Pepper and cloves crossed the ocean.")
+
+	results, err := db.SearchBookResults("spice")
+	if err != nil || len(results) != 2 {
+		t.Fatalf("search: %d results, %v", len(results), err)
+	}
+	for _, r := range results {
+		if r.Score <= 0 || r.Book.Content != "" {
+			t.Errorf("expected a positive score and no content, got %+v", r)
+		}
+		if r.Book.ID == dune && !strings.Contains(r.Snippet, "[spice]") {
+			t.Errorf("expected the match marked in the excerpt, got %q", r.Snippet)
+		}
+		if r.Book.ID != dune && r.TitleHighlight != "[Spice] Trade" {
+			t.Errorf("expected the match marked in the title, got %q", r.TitleHighlight)
+		}
+	}
+
+	results, err = db.SearchBookResults("available:yes")
+	if err != nil || len(results) != 2 || results[0].Score != 0 || results[0].Snippet != "" {
+		t.Fatalf("filter-only search should list unranked books: %v %+v", err, results)
+	}
+}
@@ -0,0 +1,36 @@
+package library
+
+import "strings"
+
+// ExplainQueryPlan returns SQLite's query plan for query, one line per plan
+// step (e.g. "SEARCH checkouts USING INDEX idx_checkouts_book_open
+// (book_id=? AND return_time=?)"). It is meant for checking that
+// circulation queries use an index rather than scanning a table.
+func (d *Database) ExplainQueryPlan(query string, args ...any) ([]string, error) {
+	rows, err := d.db.Query(`EXPLAIN QUERY PLAN `+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			return nil, err
+		}
+		plan = append(plan, detail)
+	}
+	return plan, rows.Err()
+}
+
+// planScans reports whether a query plan contains a full scan of table.
+func planScans(plan []string, table string) bool {
+	for _, step := range plan {
+		if step == "SCAN "+table || strings.HasPrefix(step, "SCAN "+table+" ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package library
+
+import "testing"
+
+func TestCirculationQueriesUseIndexes(t *testing.T) {
+	db := tempDB(t)
+
+	queries := []struct {
+		name  string
+		table string
+		query string
+		args  []any
+	}{
+		{"open checkout for book", "checkouts",
+			`SELECT id FROM checkouts WHERE book_id=? AND return_time IS NULL`, []any{1}},
+		{"open checkouts for member", "checkouts",
+			`SELECT COUNT(*) FROM checkouts WHERE member_id=? AND return_time IS NULL`, []any{1}},
+		{"reservation queue", "reservations",
+			`SELECT member_id FROM reservations WHERE book_id=? AND fulfilled_time IS NULL ORDER BY reservation_time, id LIMIT 1`, []any{1}},
+		{"available books", "books",
+			`SELECT id FROM books WHERE available=1`, nil},
+		{"member by name", "members",
+			`SELECT id FROM members WHERE name=?`, []any{"Alice"}},
+	}
+
+	for _, q := range queries {
+		t.Run(q.name, func(t *testing.T) {
+			plan, err := db.ExplainQueryPlan(q.query, q.args...)
+			if err != nil {
+				t.Fatalf("explain: %v", err)
+			}
+			if planScans(plan, q.table) {
+				t.Fatalf("query scans %s: %v", q.table, plan)
+			}
+		})
+	}
+}
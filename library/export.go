@@ -0,0 +1,173 @@
+package library
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Formats accepted by ExportBooks and ExportMembers.
+const (
+	ExportCSV  = "csv"
+	ExportJSON = "json"
+)
+
+// BookRecord is a catalog entry as exported, without the book's text.
+type BookRecord struct {
+	ID             int64     `json:"id"`
+	Title          string    `json:"title"`
+	Author         string    `json:"author"`
+	ItemType       string    `json:"item_type"`
+	Available      bool      `json:"available"`
+	BorrowerID     int64     `json:"borrower_id,omitempty"`
+	NonCirculating bool      `json:"non_circulating"`
+	UpdatedTime    time.Time `json:"updated_time"`
+
+	BookMetadata
+}
+
+// MemberRecord is a member account as exported, without credentials.
+type MemberRecord struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	IsAdmin    bool       `json:"is_admin"`
+	Tier       string     `json:"tier"`
+	ExpiryTime *time.Time `json:"expiry_time,omitempty"`
+}
+
+// ExportBooks writes the whole catalog to w as CSV (with a header row) or
+// as a JSON array, for spreadsheets and other systems.
+func (d *Database) ExportBooks(w io.Writer, format string) error {
+	if err := checkExportFormat(format); err != nil {
+		return err
+	}
+	rows, err := d.db.Query(`SELECT id, title, author, item_type, available, COALESCE(borrower_id, 0), non_circulating, updated_time,
+                                    isbn, publisher, publication_year, language, page_count
+                             FROM books ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var books []*BookRecord
+	for rows.Next() {
+		var b BookRecord
+		if err := rows.Scan(&b.ID, &b.Title, &b.Author, &b.ItemType, &b.Available, &b.BorrowerID, &b.NonCirculating, &b.UpdatedTime,
+			&b.ISBN, &b.Publisher, &b.PublicationYear, &b.Language, &b.PageCount); err != nil {
+			return err
+		}
+		books = append(books, &b)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if format == ExportJSON {
+		return writeExportJSON(w, books)
+	}
+	table := [][]string{{"id", "title", "author", "item_type", "available", "borrower_id", "non_circulating", "updated_time",
+		"isbn", "publisher", "publication_year", "language", "page_count"}}
+	for _, b := range books {
+		borrower := ""
+		if b.BorrowerID != 0 {
+			borrower = strconv.FormatInt(b.BorrowerID, 10)
+		}
+		table = append(table, []string{strconv.FormatInt(b.ID, 10), b.Title, b.Author, b.ItemType,
+			strconv.FormatBool(b.Available), borrower, strconv.FormatBool(b.NonCirculating), csvTime(&b.UpdatedTime),
+			b.ISBN, b.Publisher, optionalInt(b.PublicationYear), b.Language, optionalInt(b.PageCount)})
+	}
+	return writeExportCSV(w, table)
+}
+
+// ExportMembers writes every member account to w as CSV or JSON. Password
+// hashes are never exported.
+func (d *Database) ExportMembers(w io.Writer, format string) error {
+	if err := checkExportFormat(format); err != nil {
+		return err
+	}
+	rows, err := d.db.Query(`SELECT id, name, is_admin, tier, expiry_time FROM members WHERE placeholder=0 ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var members []*MemberRecord
+	for rows.Next() {
+		var m MemberRecord
+		var expiry sql.NullTime
+		if err := rows.Scan(&m.ID, &m.Name, &m.IsAdmin, &m.Tier, &expiry); err != nil {
+			return err
+		}
+		if expiry.Valid {
+			m.ExpiryTime = &expiry.Time
+		}
+		members = append(members, &m)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if format == ExportJSON {
+		return writeExportJSON(w, members)
+	}
+	table := [][]string{{"id", "name", "is_admin", "tier", "expiry_time"}}
+	for _, m := range members {
+		table = append(table, []string{strconv.FormatInt(m.ID, 10), m.Name,
+			strconv.FormatBool(m.IsAdmin), m.Tier, csvTime(m.ExpiryTime)})
+	}
+	return writeExportCSV(w, table)
+}
+
+func checkExportFormat(format string) error {
+	if format != ExportCSV && format != ExportJSON {
+		return fmt.Errorf("unknown export format %q (use %s or %s)", format, ExportCSV, ExportJSON)
+	}
+	return nil
+}
+
+// optionalInt formats n, or "" for zero (unknown).
+func optionalInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// csvTime formats t as RFC 3339 in UTC, or "" for no time.
+func csvTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func writeExportCSV(w io.Writer, table [][]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(table); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// writeExportJSON writes records as an indented array; an empty table is
+// [] rather than null.
+func writeExportJSON[T any](w io.Writer, records []T) error {
+	if records == nil {
+		records = []T{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) ExportBooks(w io.Writer, format string) error {
+	return lm.db.ExportBooks(w, format)
+}
+
+func (lm *LibraryManager) ExportMembers(w io.Writer, format string) error {
+	return lm.db.ExportMembers(w, format)
+}
@@ -0,0 +1,54 @@
+package library
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExportBooksAndMembers(t *testing.T) {
+	db := tempDB(t)
+	dune, _ := db.AddBook("Dune", "Frank Herbert", "secret text")
+	db.AddBook("Emma, Volume 1", "Jane \"J\" Austen", "")
+	alice, _ := db.AddMember("Alice", "password")
+	db.CheckoutBook(dune, alice)
+
+	var buf bytes.Buffer
+	if err := db.ExportBooks(&buf, ExportCSV); err != nil {
+		t.Fatalf("export csv: %v", err)
+	}
+	if strings.Contains(buf.String(), "secret text") {
+		t.Fatalf("book content should not be exported")
+	}
+	table, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(table) != 3 || table[0][1] != "title" || table[2][1] != "Emma, Volume 1" || table[2][2] != "Jane \"J\" Austen" {
+		t.Fatalf("unexpected csv: %q", table)
+	}
+	if table[1][4] != "false" || table[1][5] != "1" {
+		t.Fatalf("expected Dune on loan to member 1: %q", table[1])
+	}
+
+	buf.Reset()
+	if err := db.ExportMembers(&buf, ExportJSON); err != nil {
+		t.Fatalf("export json: %v", err)
+	}
+	if strings.Contains(buf.String(), "password") || strings.Contains(buf.String(), "$2a$") {
+		t.Fatalf("credentials leaked: %s", buf.String())
+	}
+	var members []MemberRecord
+	if err := json.Unmarshal(buf.Bytes(), &members); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(members) != 1 || members[0].Name != "Alice" {
+		t.Fatalf("unexpected members: %+v", members)
+	}
+
+	if err := db.ExportBooks(&buf, "xml"); err == nil {
+		t.Fatalf("expected unknown format to be refused")
+	}
+}
@@ -0,0 +1,151 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fine kinds stored in the fines ledger.
+const (
+	FineReplacement = "replacement"
+)
+
+// defaultReplacementCents is billed for a lost copy when the title has no
+// replacement price of its own.
+const defaultReplacementCents = 2500
+
+// Fine is a single charge on a member's account.
+type Fine struct {
+	ID           int64      `json:"id"`
+	MemberID     int64      `json:"member_id"`
+	CheckoutID   int64      `json:"checkout_id,omitempty"`
+	Kind         string     `json:"kind"`
+	AmountCents  int64      `json:"amount_cents"`
+	Description  string     `json:"description"`
+	CreatedTime  time.Time  `json:"created_time"`
+	ReversedTime *time.Time `json:"reversed_time,omitempty"`
+}
+
+// SetReplacementCost sets the price billed when a copy of bookID is lost.
+// A negative cents value clears the price so the default applies.
+func (d *Database) SetReplacementCost(bookID int64, cents int64) error {
+	var value interface{}
+	if cents >= 0 {
+		value = cents
+	}
+	res, err := d.db.Exec(`UPDATE books SET replacement_cost_cents=? WHERE id=?`, value, bookID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("book not found")
+	}
+	return nil
+}
+
+// GetReplacementCost returns the price billed when a copy of bookID is lost.
+func (d *Database) GetReplacementCost(bookID int64) (int64, error) {
+	var cost sql.NullInt64
+	err := d.db.QueryRow(`SELECT replacement_cost_cents FROM books WHERE id=?`, bookID).Scan(&cost)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("book not found")
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !cost.Valid {
+		return defaultReplacementCents, nil
+	}
+	return cost.Int64, nil
+}
+
+// GetMemberFines lists every ledger entry for memberID, newest first.
+func (d *Database) GetMemberFines(memberID int64) ([]*Fine, error) {
+	rows, err := d.db.Query(`SELECT id, member_id, COALESCE(checkout_id, 0), kind, amount_cents, description, created_time, reversed_time
+                             FROM fines WHERE member_id=? ORDER BY created_time DESC, id DESC`, memberID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fines []*Fine
+	for rows.Next() {
+		var f Fine
+		var reversed sql.NullTime
+		if err := rows.Scan(&f.ID, &f.MemberID, &f.CheckoutID, &f.Kind, &f.AmountCents, &f.Description, &f.CreatedTime, &reversed); err != nil {
+			return nil, err
+		}
+		if reversed.Valid {
+			f.ReversedTime = &reversed.Time
+		}
+		fines = append(fines, &f)
+	}
+	return fines, rows.Err()
+}
+
+// GetFineBalance returns the total of memberID's unreversed charges.
+func (d *Database) GetFineBalance(memberID int64) (int64, error) {
+	var total int64
+	err := d.db.QueryRow(`SELECT COALESCE(SUM(amount_cents), 0) FROM fines WHERE member_id=? AND reversed_time IS NULL`, memberID).Scan(&total)
+	return total, err
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) SetReplacementCost(bookID int64, cents int64) error {
+	return lm.db.SetReplacementCost(bookID, cents)
+}
+
+func (lm *LibraryManager) GetReplacementCost(bookID int64) (int64, error) {
+	return lm.db.GetReplacementCost(bookID)
+}
+
+func (lm *LibraryManager) GetMemberFines(memberID int64) ([]*Fine, error) {
+	return lm.db.GetMemberFines(memberID)
+}
+
+func (lm *LibraryManager) GetFineBalance(memberID int64) (int64, error) {
+	return lm.db.GetFineBalance(memberID)
+}
+
+// FormatCents renders an amount of money stored in cents, e.g. "$1.50".
+func FormatCents(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s$%d.%02d", sign, cents/100, cents%100)
+}
+
+// ParseCents parses a non-negative amount such as "12", "12.5" or "$12.50"
+// into cents.
+func ParseCents(s string) (int64, error) {
+	amount := strings.TrimPrefix(strings.TrimSpace(s), "$")
+	whole, frac, _ := strings.Cut(amount, ".")
+	if len(frac) > 2 {
+		return 0, fmt.Errorf("invalid amount: %s", s)
+	}
+	dollars, err := strconv.ParseUint(whole, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %s", s)
+	}
+	var cents uint64
+	if frac != "" {
+		cents, err = strconv.ParseUint(frac, 10, 8)
+		if err != nil {
+			return 0, fmt.Errorf("invalid amount: %s", s)
+		}
+		if len(frac) == 1 {
+			cents *= 10
+		}
+	}
+	return int64(dollars*100 + cents), nil
+}
@@ -0,0 +1,65 @@
+package library
+
+import "testing"
+
+func TestLostItemReplacementCharge(t *testing.T) {
+	mgr := newManager(t)
+	priced, _ := mgr.AddBook("Priced Book", "Author")
+	unpriced, _ := mgr.AddBook("Unpriced Book", "Author")
+	alice, _ := mgr.AddMember("Alice", "password")
+
+	if err := mgr.SetReplacementCost(priced, 1850); err != nil {
+		t.Fatalf("set price: %v", err)
+	}
+	mgr.CheckoutBook(priced, alice)
+	mgr.CheckoutBook(unpriced, alice)
+
+	mgr.MarkClaimsReturned(priced)
+	if _, err := mgr.ResolveClaim(priced, false); err != nil {
+		t.Fatalf("mark lost: %v", err)
+	}
+	mgr.MarkClaimsReturned(unpriced)
+	mgr.ResolveClaim(unpriced, false)
+
+	balance, _ := mgr.GetFineBalance(alice)
+	if balance != 1850+defaultReplacementCents {
+		t.Fatalf("balance = %d, want %d", balance, 1850+defaultReplacementCents)
+	}
+
+	// The priced copy turns up at the desk: its charge is reversed
+	if _, err := mgr.CheckInBook(priced); err != nil {
+		t.Fatalf("check in lost copy: %v", err)
+	}
+	balance, _ = mgr.GetFineBalance(alice)
+	if balance != defaultReplacementCents {
+		t.Fatalf("balance after check-in = %d, want %d", balance, defaultReplacementCents)
+	}
+
+	fines, _ := mgr.GetMemberFines(alice)
+	reversed := 0
+	for _, f := range fines {
+		if f.ReversedTime != nil {
+			reversed++
+		}
+	}
+	if len(fines) != 2 || reversed != 1 {
+		t.Fatalf("want 2 ledger entries with 1 reversed, got %d/%d", len(fines), reversed)
+	}
+}
+
+func TestParseCents(t *testing.T) {
+	tests := map[string]int64{"12": 1200, "12.5": 1250, "$0.99": 99, "3.05": 305}
+	for in, want := range tests {
+		if got, err := ParseCents(in); err != nil || got != want {
+			t.Errorf("ParseCents(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "abc", "1.234", "-5", "12abc"} {
+		if _, err := ParseCents(bad); err == nil {
+			t.Errorf("ParseCents(%q) should fail", bad)
+		}
+	}
+	if FormatCents(1250) != "$12.50" {
+		t.Errorf("FormatCents(1250) = %s", FormatCents(1250))
+	}
+}
@@ -0,0 +1,80 @@
+package library_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"library-management/library"
+	"library-management/librarytest"
+)
+
+func TestLoadFixtures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "library.db")
+	lm, fx, err := librarytest.LoadFixtures(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lm.Close()
+	if _, _, err := librarytest.LoadFixtures(path); err == nil {
+		t.Fatalf("expected loading fixtures twice to be refused")
+	}
+
+	if !lm.Now().Equal(librarytest.Epoch.AddDate(0, 0, 15)) {
+		t.Fatalf("clock stopped at %v", lm.Now())
+	}
+	if err := lm.AuthenticateAdmin(fx.Staff, librarytest.FixturePassword); err != nil {
+		t.Fatalf("staff sign-in: %v", err)
+	}
+	emma, err := lm.GetBook(fx.Emma)
+	if err != nil || emma.Available || emma.BorrowerID != fx.Carol {
+		t.Fatalf("Emma = %+v, %v; want it held for Carol", emma, err)
+	}
+	if s, _ := lm.GetAccountSummary(fx.Alice, 7*24*time.Hour); s.Overdue != 1 {
+		t.Fatalf("Alice's account = %+v; want Dune overdue", s)
+	}
+}
+
+// Two loads give byte-for-byte the same exports, whenever they run.
+func TestGoldenExports(t *testing.T) {
+	lm, fx := librarytest.NewFixtureLibrary(t)
+
+	for _, format := range []string{library.ExportCSV, library.ExportJSON} {
+		var books, members bytes.Buffer
+		if err := lm.ExportBooks(&books, format); err != nil {
+			t.Fatal(err)
+		}
+		librarytest.CheckGolden(t, "books."+format, books.Bytes())
+		if err := lm.ExportMembers(&members, format); err != nil {
+			t.Fatal(err)
+		}
+		librarytest.CheckGolden(t, "members."+format, members.Bytes())
+	}
+
+	state, err := lm.ExportCirculation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	circulation, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	librarytest.CheckGolden(t, "circulation.json", append(circulation, '\n'))
+
+	var summaries bytes.Buffer
+	for _, id := range []int64{fx.Staff, fx.Alice, fx.Bob, fx.Carol} {
+		s, err := lm.GetAccountSummary(id, 7*24*time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		line := s.String()
+		if line == "" {
+			line = "nothing to report"
+		}
+		fmt.Fprintf(&summaries, "%d: %s\n", id, line)
+	}
+	librarytest.CheckGolden(t, "summaries.txt", summaries.Bytes())
+}
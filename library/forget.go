@@ -0,0 +1,222 @@
+package library
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// placeholderName is the name of the member that anonymized records of
+// erased members are reassigned to.
+const placeholderName = "Forgotten member"
+
+// ErasureCertificate records that a member's personal data was erased. It
+// holds no personal data itself, only counts of what was removed.
+type ErasureCertificate struct {
+	ID          int64          `json:"id"`
+	MemberID    int64          `json:"member_id"` // the erased account's former ID
+	ActorID     int64          `json:"actor_id"`
+	Deleted     map[string]int `json:"deleted"`
+	Anonymized  map[string]int `json:"anonymized"`
+	CreatedTime time.Time      `json:"created_time"`
+}
+
+// ForgetMember erases a member on request, in one transaction, under this
+// policy:
+//
+//   - Refused while the member has books on loan (including holds waiting for
+//     pickup and disputed loans) or owes money, so neither is lost.
+//   - Deleted: the account itself, holds, inbox notifications, profile, API
+//     tokens, digital loans, delegations either way, their notes,
+//     highlights, bookmarks and reading progress in books, and staff notes
+//     and alerts about the member.
+//   - Anonymized: past checkouts (including those collected or returned for
+//     someone else), their reminders and charges, and notes and alerts the
+//     member wrote or cleared as staff, are reassigned to a shared
+//     "Forgotten member" placeholder. Circulation statistics stay correct but
+//     no longer lead back to anyone.
+//   - Kept: audit log entries. The log is append-only and hash-chained, so
+//     entries the member took part in keep their former member ID; with the
+//     account gone it no longer resolves to a name.
+//
+// The library stores no reviews, so there are none to erase. An erasure
+// certificate and an audit entry record the erasure.
+func (d *Database) ForgetMember(memberID, actorID int64) (*ErasureCertificate, error) {
+	return inTxResult(d, func(tx *sql.Tx) (*ErasureCertificate, error) {
+		var placeholder bool
+		err := tx.QueryRow(`SELECT placeholder FROM members WHERE id=?`, memberID).Scan(&placeholder)
+		if err == sql.ErrNoRows || placeholder {
+			return nil, fmt.Errorf("member with ID %d not found", memberID)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var onLoan int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM checkouts WHERE member_id=? AND return_time IS NULL`, memberID).Scan(&onLoan); err != nil {
+			return nil, err
+		}
+		if onLoan > 0 {
+			return nil, fmt.Errorf("member still has %d item(s) on loan; check them in first", onLoan)
+		}
+		var balance int64
+		err = tx.QueryRow(`SELECT COALESCE(SUM(amount_cents), 0) FROM fines WHERE member_id=? AND reversed_time IS NULL`, memberID).Scan(&balance)
+		if err != nil {
+			return nil, err
+		}
+		if balance > 0 {
+			return nil, fmt.Errorf("member owes %s; settle or waive it first", FormatCents(balance))
+		}
+
+		placeholderID, err := ensurePlaceholder(tx)
+		if err != nil {
+			return nil, err
+		}
+
+		cert := &ErasureCertificate{MemberID: memberID, ActorID: actorID,
+			Deleted: map[string]int{}, Anonymized: map[string]int{}}
+		run := func(counts map[string]int, key, query string, args ...interface{}) error {
+			res, err := tx.Exec(query, args...)
+			if err != nil {
+				return fmt.Errorf("erase %s: %w", key, err)
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			counts[key] += int(n)
+			return nil
+		}
+		steps := []struct {
+			counts map[string]int
+			key    string
+			query  string
+			args   []interface{}
+		}{
+			{cert.Anonymized, "checkouts", `UPDATE checkouts SET member_id=? WHERE member_id=?`, []interface{}{placeholderID, memberID}},
+			{cert.Anonymized, "checkouts_handled", `UPDATE checkouts SET picked_up_by=? WHERE picked_up_by=?`, []interface{}{placeholderID, memberID}},
+			{cert.Anonymized, "checkouts_handled", `UPDATE checkouts SET returned_by=? WHERE returned_by=?`, []interface{}{placeholderID, memberID}},
+			{cert.Anonymized, "fines", `UPDATE fines SET member_id=? WHERE member_id=?`, []interface{}{placeholderID, memberID}},
+			{cert.Anonymized, "member_notes", `UPDATE member_notes SET author_id=? WHERE author_id=?`, []interface{}{placeholderID, memberID}},
+			{cert.Anonymized, "member_alerts", `UPDATE member_alerts SET author_id=? WHERE author_id=?`, []interface{}{placeholderID, memberID}},
+			{cert.Anonymized, "member_alerts", `UPDATE member_alerts SET cleared_by=? WHERE cleared_by=?`, []interface{}{placeholderID, memberID}},
+			{cert.Deleted, "account", `DELETE FROM members WHERE id=?`, []interface{}{memberID}},
+		}
+		for _, r := range memberOwnedRows {
+			if err := run(cert.Deleted, r.key, r.query, memberID); err != nil {
+				return nil, err
+			}
+		}
+		for _, s := range steps {
+			if err := run(s.counts, s.key, s.query, s.args...); err != nil {
+				return nil, err
+			}
+		}
+
+		summary, err := json.Marshal(map[string]map[string]int{"deleted": cert.Deleted, "anonymized": cert.Anonymized})
+		if err != nil {
+			return nil, err
+		}
+		cert.CreatedTime = time.Now().UTC().Truncate(time.Second)
+		res, err := tx.Exec(`INSERT INTO erasure_certificates(member_id, actor_id, summary, created_time) VALUES(?,?,?,?)`,
+			memberID, actorID, string(summary), sqlTime(&cert.CreatedTime))
+		if err != nil {
+			return nil, err
+		}
+		if cert.ID, err = res.LastInsertId(); err != nil {
+			return nil, err
+		}
+		detail := fmt.Sprintf("member %d erased, certificate %d", memberID, cert.ID)
+		if err := recordAuditOf(tx, actorID, AuditForgetMember, memberID, 0, detail); err != nil {
+			return nil, err
+		}
+		return cert, nil
+	})
+}
+
+// memberOwnedRows delete the records that belong to a member alone and go
+// with their account, keyed as in an erasure certificate. Each query takes
+// the member ID as its only parameter.
+var memberOwnedRows = []struct{ key, query string }{
+	{"reservations", `DELETE FROM reservations WHERE member_id=?1`},
+	{"notifications", `DELETE FROM notifications WHERE member_id=?1`},
+	{"profile", `DELETE FROM member_profiles WHERE member_id=?1`},
+	{"api_tokens", `DELETE FROM api_tokens WHERE member_id=?1`},
+	{"digital_loans", `DELETE FROM digital_loans WHERE member_id=?1`},
+	{"member_notes", `DELETE FROM member_notes WHERE member_id=?1`},
+	{"member_alerts", `DELETE FROM member_alerts WHERE member_id=?1`},
+	{"annotations", `DELETE FROM annotations WHERE member_id=?1`},
+	{"bookmarks", `DELETE FROM bookmarks WHERE member_id=?1`},
+	{"reading_progress", `DELETE FROM reading_progress WHERE member_id=?1`},
+	{"delegations", `DELETE FROM delegates WHERE owner_id=?1 OR delegate_id=?1`},
+}
+
+// ensurePlaceholder returns the ID of the shared placeholder member, creating
+// it the first time a member is erased. It has no password, so it can never
+// sign in.
+func ensurePlaceholder(tx *sql.Tx) (int64, error) {
+	var id int64
+	err := tx.QueryRow(`SELECT id FROM members WHERE placeholder=1`).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+	// Names are unique; a real member could already be called this
+	name := placeholderName
+	for i := 2; ; i++ {
+		var taken int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM members WHERE name=?`, name).Scan(&taken); err != nil {
+			return 0, err
+		}
+		if taken == 0 {
+			break
+		}
+		name = fmt.Sprintf("%s %d", placeholderName, i)
+	}
+	res, err := tx.Exec(`INSERT INTO members(name, placeholder) VALUES(?, 1)`, name)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// GetErasureCertificates lists every erasure, oldest first.
+func (d *Database) GetErasureCertificates() ([]*ErasureCertificate, error) {
+	rows, err := d.db.Query(`SELECT id, member_id, COALESCE(actor_id, 0), summary, created_time
+                             FROM erasure_certificates ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var certs []*ErasureCertificate
+	for rows.Next() {
+		var c ErasureCertificate
+		var summary string
+		if err := rows.Scan(&c.ID, &c.MemberID, &c.ActorID, &summary, &c.CreatedTime); err != nil {
+			return nil, err
+		}
+		var counts struct {
+			Deleted    map[string]int `json:"deleted"`
+			Anonymized map[string]int `json:"anonymized"`
+		}
+		if err := json.Unmarshal([]byte(summary), &counts); err != nil {
+			return nil, fmt.Errorf("certificate %d: %w", c.ID, err)
+		}
+		c.Deleted, c.Anonymized = counts.Deleted, counts.Anonymized
+		certs = append(certs, &c)
+	}
+	return certs, rows.Err()
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) ForgetMember(memberID, actorID int64) (*ErasureCertificate, error) {
+	return lm.db.ForgetMember(memberID, actorID)
+}
+
+func (lm *LibraryManager) GetErasureCertificates() ([]*ErasureCertificate, error) {
+	return lm.db.GetErasureCertificates()
+}
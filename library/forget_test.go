@@ -0,0 +1,87 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestForgetMember(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	staff, _ := db.AddMember("Staff", "password1")
+	db.SetMemberAdmin(staff, true)
+	alice, _ := db.AddMember("Alice", "password1")
+	bob, _ := db.AddMember("Bob", "password1")
+	b1, _ := db.AddBook("Read Before", "Author", "")
+	b2, _ := db.AddBook("Wanted", "Author", "")
+
+	db.CheckoutBook(b1, alice)
+	db.CheckoutBook(b2, bob)
+	lm.ReserveBook(b2, alice)
+	db.AddMemberNote(alice, staff, "Prefers large print")
+	db.AddMemberAlert(alice, staff, "Address unconfirmed", false)
+	db.AddMemberNote(bob, alice, "Note written by Alice as staff")
+	db.SetMemberProfile(alice, MemberProfile{Address: "1 Main St", Zip: "12345", AgeGroup: "18-64"})
+	db.IssueToken(alice, "")
+
+	if _, err := db.ForgetMember(alice, staff); err == nil || !strings.Contains(err.Error(), "on loan") {
+		t.Fatalf("expected open loans to block erasure, got %v", err)
+	}
+	if _, err := lm.ReturnBook(b1, alice); err != nil {
+		t.Fatalf("return: %v", err)
+	}
+
+	cert, err := db.ForgetMember(alice, staff)
+	if err != nil {
+		t.Fatalf("forget: %v", err)
+	}
+	if cert.Deleted["account"] != 1 || cert.Deleted["reservations"] != 1 || cert.Deleted["profile"] != 1 ||
+		cert.Anonymized["checkouts"] != 1 || cert.Anonymized["member_notes"] != 1 {
+		t.Fatalf("unexpected certificate: %+v", cert)
+	}
+
+	if _, err := db.GetMember(alice); err == nil {
+		t.Fatalf("Alice's account should be gone")
+	}
+	for _, q := range []string{
+		`SELECT COUNT(*) FROM checkouts WHERE member_id=?`,
+		`SELECT COUNT(*) FROM reservations WHERE member_id=?`,
+		`SELECT COUNT(*) FROM member_notes WHERE member_id=? OR author_id=?`,
+		`SELECT COUNT(*) FROM member_profiles WHERE member_id=?`,
+		`SELECT COUNT(*) FROM api_tokens WHERE member_id=?`,
+	} {
+		var n int
+		db.db.QueryRow(q, alice, alice).Scan(&n)
+		if n != 0 {
+			t.Errorf("%s: %d rows still reference Alice", q, n)
+		}
+	}
+	var total int
+	db.db.QueryRow(`SELECT COUNT(*) FROM checkouts`).Scan(&total)
+	if total != 2 {
+		t.Fatalf("circulation history should be kept, got %d checkouts", total)
+	}
+
+	members, _ := db.GetAllMembers()
+	if len(members) != 2 {
+		t.Fatalf("the placeholder should not be listed: %+v", members)
+	}
+	certs, _ := db.GetErasureCertificates()
+	if len(certs) != 1 || certs[0].MemberID != alice || certs[0].Deleted["account"] != 1 {
+		t.Fatalf("unexpected certificates: %+v", certs)
+	}
+	if entries, _ := db.GetAuditLog(1); len(entries) != 1 || entries[0].Action != AuditForgetMember {
+		t.Fatalf("expected an audit entry, got %+v", entries)
+	}
+
+	// A second erasure reuses the placeholder
+	lm.ReturnBook(b2, bob)
+	if _, err := db.ForgetMember(bob, staff); err != nil {
+		t.Fatalf("forget Bob: %v", err)
+	}
+	var placeholders int
+	db.db.QueryRow(`SELECT COUNT(*) FROM members WHERE placeholder=1`).Scan(&placeholders)
+	if placeholders != 1 {
+		t.Fatalf("expected one placeholder, got %d", placeholders)
+	}
+}
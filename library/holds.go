@@ -0,0 +1,325 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// defaultHoldPickupDays is how long a returned book waits on hold for the
+// next member in its reservation queue before passing further down it.
+const defaultHoldPickupDays = 7
+
+// Hold policies say what happens to a returned book that members have
+// reserved. Libraries run holds differently, so it is a setting.
+const (
+	// HoldPolicyShelf holds the book for the next member in the queue for a
+	// pickup window; their loan starts when they collect it. The default.
+	HoldPolicyShelf = "shelf"
+	// HoldPolicyImmediate checks the book out to the next member at once,
+	// for libraries that deliver or bag reserved books.
+	HoldPolicyImmediate = "immediate"
+	// HoldPolicyNotify puts the book back on the shelf and tells the next
+	// member it is in; whoever borrows it first gets it.
+	HoldPolicyNotify = "notify"
+)
+
+// HoldPolicies lists the hold policies.
+var HoldPolicies = []string{HoldPolicyShelf, HoldPolicyImmediate, HoldPolicyNotify}
+
+// SetHoldPickupDays sets how long new holds wait for pickup; zero or less
+// restores the default.
+func (d *Database) SetHoldPickupDays(days int) { d.holdPickupDays = days }
+
+// SetHoldPolicy sets what happens to returned books that members are
+// waiting for, from HoldPolicies; "" restores the default.
+func (d *Database) SetHoldPolicy(policy string) error {
+	if policy != "" && !slices.Contains(HoldPolicies, policy) {
+		return fmt.Errorf("unknown hold policy %q (use %s)", policy, strings.Join(HoldPolicies, ", "))
+	}
+	d.holdPolicy = policy
+	return nil
+}
+
+// holdForNextReservation passes bookID, just returned or released from a
+// lapsed hold, to the next member in its reservation queue within tx, as
+// the hold policy says, and tells them. With nobody waiting the book goes
+// back on the shelf. It returns the member the book is held or checked out
+// for, or 0.
+func (d *Database) holdForNextReservation(tx *sql.Tx, bookID int64) (int64, error) {
+	var nextMemberID int64
+	err := tx.QueryRow(`SELECT member_id FROM reservations WHERE book_id=? AND fulfilled_time IS NULL
+	                    ORDER BY reservation_time, id LIMIT 1`, bookID).Scan(&nextMemberID)
+	if err == sql.ErrNoRows {
+		_, err := tx.Exec(`UPDATE books SET available=1, borrower_id=NULL WHERE id=?`, bookID)
+		return 0, err
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	now := d.sqlNow()
+	queueMoved, err := d.watchQueues(tx, bookID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE reservations SET fulfilled_time=?
+	                      WHERE book_id=? AND member_id=? AND fulfilled_time IS NULL`, now, bookID, nextMemberID); err != nil {
+		return 0, err
+	}
+	if err := queueMoved(); err != nil {
+		return 0, err
+	}
+	switch d.holdPolicy {
+	case HoldPolicyImmediate:
+		return nextMemberID, d.checkoutToNextMember(tx, bookID, nextMemberID)
+	case HoldPolicyNotify:
+		return 0, d.notifyNextMember(tx, bookID, nextMemberID)
+	}
+
+	if _, err := tx.Exec(`UPDATE books SET available=0, borrower_id=? WHERE id=?`, nextMemberID, bookID); err != nil {
+		return 0, err
+	}
+
+	// The hold is a checkout waiting for pickup, with no due date; its loan
+	// period starts when it is collected
+	checkoutID, err := d.insertCheckout(tx, bookID, nextMemberID)
+	if err != nil {
+		return 0, err
+	}
+	days := defaultHoldPickupDays
+	if d.holdPickupDays > 0 {
+		days = d.holdPickupDays
+	}
+	if _, err := tx.Exec(`UPDATE checkouts SET status=?, due_time=NULL, hold_expiry_time=datetime(?, ?)
+	                      WHERE id=?`,
+		LoanAwaitingPickup, now, fmt.Sprintf("+%d days", days), checkoutID); err != nil {
+		return 0, err
+	}
+
+	var title string
+	var expiry time.Time
+	if err := tx.QueryRow(`SELECT b.title, c.hold_expiry_time FROM books b JOIN checkouts c ON c.book_id = b.id
+	                       WHERE b.id=? AND c.return_time IS NULL`, bookID).Scan(&title, &expiry); err != nil {
+		return 0, err
+	}
+	body := fmt.Sprintf("Your reservation for '%s' is on hold for you until %s. Check it out by then, or it passes to the next member waiting.",
+		title, d.localTime(expiry).Format("2006-01-02"))
+	if d.lockerPickup {
+		code, err := assignPickupCode(tx, bookID)
+		if err != nil {
+			return 0, err
+		}
+		body = fmt.Sprintf("Your reservation for '%s' is waiting in the pickup locker until %s. Your one-time pickup code is %s.",
+			title, d.localTime(expiry).Format("2006-01-02"), code)
+	}
+	if err := insertNotification(tx, nextMemberID, NoticeHoldReady,
+		fmt.Sprintf("'%s' is ready for you", title), body); err != nil {
+		return 0, err
+	}
+	if err := d.queueLoanEvent(tx, EventHoldReady, checkoutID); err != nil {
+		return 0, err
+	}
+	return nextMemberID, nil
+}
+
+// checkoutToNextMember lends bookID to memberID, whose reservation it
+// fulfils, within tx and tells them it is theirs.
+func (d *Database) checkoutToNextMember(tx *sql.Tx, bookID, memberID int64) error {
+	if _, err := tx.Exec(`UPDATE books SET available=0, borrower_id=? WHERE id=?`, memberID, bookID); err != nil {
+		return err
+	}
+	checkoutID, err := d.insertCheckout(tx, bookID, memberID)
+	if err != nil {
+		return err
+	}
+	loan, err := d.getLoan(tx, checkoutID)
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("Your reservation for '%s' has been checked out to you, due %s.",
+		loan.BookTitle, d.localTime(loan.DueTime).Format("2006-01-02"))
+	if err := insertNotification(tx, memberID, NoticeHoldReady,
+		fmt.Sprintf("'%s' is checked out to you", loan.BookTitle), body); err != nil {
+		return err
+	}
+	detail := fmt.Sprintf("checked out book %d to member %d, next in the reservation queue", bookID, memberID)
+	if err := recordAuditOf(tx, 0, AuditCheckout, memberID, bookID, detail); err != nil {
+		return err
+	}
+	return d.queueLoanEvent(tx, EventCheckout, checkoutID)
+}
+
+// notifyNextMember puts bookID back on the shelf within tx and tells
+// memberID, whose reservation it fulfils, that it is in.
+func (d *Database) notifyNextMember(tx *sql.Tx, bookID, memberID int64) error {
+	if _, err := tx.Exec(`UPDATE books SET available=1, borrower_id=NULL WHERE id=?`, bookID); err != nil {
+		return err
+	}
+	var title string
+	if err := tx.QueryRow(`SELECT title FROM books WHERE id=?`, bookID).Scan(&title); err != nil {
+		return err
+	}
+	body := fmt.Sprintf("'%s', which you reserved, is back on the shelf. It is not held for you: borrow it soon, before someone else does.", title)
+	return insertNotification(tx, memberID, NoticeHoldReady, fmt.Sprintf("'%s' is back in", title), body)
+}
+
+// collectOwnHold starts memberID's loan of bookID if the book is on hold
+// for them, within tx. It reports whether there was such a hold.
+func (d *Database) collectOwnHold(tx *sql.Tx, bookID, memberID int64) (bool, error) {
+	var checkoutID int64
+	err := tx.QueryRow(`SELECT id FROM checkouts WHERE book_id=? AND member_id=? AND status=? AND return_time IS NULL`,
+		bookID, memberID, LoanAwaitingPickup).Scan(&checkoutID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, err := d.collectPickup(tx, checkoutID, memberID); err != nil {
+		return false, err
+	}
+	detail := fmt.Sprintf("collected book %d from the hold shelf", bookID)
+	return true, recordAuditOf(tx, memberID, AuditCheckout, memberID, bookID, detail)
+}
+
+// FulfillHold starts the loan of a hold on bookID when memberID, the
+// member it is held for, collects it at the desk. The loan period runs
+// from now, as with every pickup, and staffID is audit-logged as handing
+// it over.
+func (d *Database) FulfillHold(bookID, memberID, staffID int64) (*Loan, error) {
+	return inTxResult(d, func(tx *sql.Tx) (*Loan, error) {
+		var checkoutID, holderID int64
+		err := tx.QueryRow(`SELECT id, member_id FROM checkouts WHERE book_id=? AND status=? AND return_time IS NULL`,
+			bookID, LoanAwaitingPickup).Scan(&checkoutID, &holderID)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no hold is waiting for pickup on book %d", bookID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if holderID != memberID {
+			return nil, fmt.Errorf("book %d is on hold for member %d, not member %d", bookID, holderID, memberID)
+		}
+		detail := fmt.Sprintf("handed over book %d to member %d", bookID, memberID)
+		if err := recordAuditOf(tx, staffID, AuditFulfillHold, memberID, bookID, detail); err != nil {
+			return nil, err
+		}
+		return d.collectPickup(tx, checkoutID, memberID)
+	})
+}
+
+// GetHolds lists the holds waiting for pickup, soonest to lapse first.
+func (d *Database) GetHolds() ([]*Loan, error) {
+	rows, err := d.db.Query(`SELECT `+d.loanColumns()+`
+                             FROM checkouts c
+                             JOIN books b ON c.book_id = b.id
+                             JOIN members m ON c.member_id = m.id
+                             WHERE c.status = ? AND c.return_time IS NULL
+                             ORDER BY c.hold_expiry_time, c.id`, LoanAwaitingPickup)
+	if err != nil {
+		return nil, err
+	}
+	return scanLoans(rows)
+}
+
+// ExpireHolds ends the holds that were not collected within their pickup
+// window. Each member is told their hold lapsed, and the book is held for
+// the next member in the queue or goes back on the shelf. It returns the
+// number of holds expired.
+func (d *Database) ExpireHolds() (int, error) {
+	rows, err := d.db.Query(`SELECT id, book_id FROM checkouts
+	                         WHERE status=? AND return_time IS NULL AND hold_expiry_time <= ?
+	                         ORDER BY hold_expiry_time, id`, LoanAwaitingPickup, d.sqlNow())
+	if err != nil {
+		return 0, err
+	}
+	type lapsed struct{ checkoutID, bookID int64 }
+	var holds []lapsed
+	for rows.Next() {
+		var h lapsed
+		if err := rows.Scan(&h.checkoutID, &h.bookID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		holds = append(holds, h)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, h := range holds {
+		released := false
+		err := d.inTx(func(tx *sql.Tx) error {
+			// Skip holds collected since the query above
+			res, err := tx.Exec(`UPDATE checkouts SET status=?, return_time=?, pickup_code=NULL
+			                     WHERE id=? AND status=? AND return_time IS NULL`, LoanHoldExpired, d.sqlNow(), h.checkoutID, LoanAwaitingPickup)
+			if err != nil {
+				return err
+			}
+			if n, _ := res.RowsAffected(); n == 0 {
+				return nil
+			}
+
+			var memberID int64
+			var title string
+			if err := tx.QueryRow(`SELECT c.member_id, b.title FROM checkouts c JOIN books b ON c.book_id = b.id
+			                       WHERE c.id=?`, h.checkoutID).Scan(&memberID, &title); err != nil {
+				return err
+			}
+			if err := insertNotification(tx, memberID, NoticeHoldExpired,
+				fmt.Sprintf("Your hold on '%s' has expired", title),
+				fmt.Sprintf("'%s' was not collected in time and has been released. Reserve it again if you still want it.", title)); err != nil {
+				return err
+			}
+
+			if _, err := d.holdForNextReservation(tx, h.bookID); err != nil {
+				return err
+			}
+			released = true
+			return nil
+		})
+		if err != nil {
+			return expired, fmt.Errorf("expire hold on book %d: %w", h.bookID, err)
+		}
+		if released {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// HoldExpiryJob wraps ExpireHolds as a Job for the JobRunner.
+func (lm *LibraryManager) HoldExpiryJob(interval time.Duration) *Job {
+	return &Job{
+		Name:     "hold-expiry",
+		Interval: interval,
+		Run: func() error {
+			_, err := lm.ExpireHolds()
+			return err
+		},
+	}
+}
+
+// ------------------ Manager helpers ------------------
+
+// SetHoldPickupDays sets how long new holds wait for pickup.
+func (lm *LibraryManager) SetHoldPickupDays(days int) { lm.db.SetHoldPickupDays(days) }
+
+func (lm *LibraryManager) SetHoldPolicy(policy string) error { return lm.db.SetHoldPolicy(policy) }
+
+func (lm *LibraryManager) GetHolds() ([]*Loan, error) {
+	return lm.db.GetHolds()
+}
+
+func (lm *LibraryManager) ExpireHolds() (int, error) {
+	return lm.db.ExpireHolds()
+}
+
+func (lm *LibraryManager) FulfillHold(bookID, memberID, staffID int64) (*Loan, error) {
+	return lm.db.FulfillHold(bookID, memberID, staffID)
+}
@@ -0,0 +1,220 @@
+package library
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReturnPlacesHold(t *testing.T) {
+	db := tempDB(t)
+	db.SetHoldPickupDays(3)
+	bookID, _ := db.AddBook("Popular Book", "Author", "")
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+
+	db.CheckoutBook(bookID, alice)
+	db.ReserveBook(bookID, bob)
+	if _, err := db.ReturnBook(bookID); err != nil {
+		t.Fatalf("return: %v", err)
+	}
+
+	holds, err := db.GetHolds()
+	if err != nil || len(holds) != 1 || holds[0].MemberID != bob || holds[0].HoldExpiryTime == nil {
+		t.Fatalf("expected a hold for Bob: %v %+v", err, holds)
+	}
+	if window := time.Until(*holds[0].HoldExpiryTime); window < 71*time.Hour || window > 73*time.Hour {
+		t.Fatalf("hold should last 3 days, lasts %v", window)
+	}
+	notes, _ := db.ReadNotifications(bob)
+	if len(notes) != 1 || notes[0].Kind != NoticeHoldReady || !strings.Contains(notes[0].Body, "on hold for you until") {
+		t.Fatalf("expected a hold-ready notice, got %+v", notes)
+	}
+
+	// Only Bob can collect it, by checking it out
+	if err := db.CheckoutBook(bookID, alice); err == nil {
+		t.Fatalf("a held book must not be checked out by someone else")
+	}
+	if err := db.CheckoutBook(bookID, bob); err != nil {
+		t.Fatalf("collect hold: %v", err)
+	}
+	loans, _ := db.GetOpenLoans(bob)
+	if len(loans) != 1 || loans[0].Status != LoanActive || loans[0].HoldExpiryTime != nil {
+		t.Fatalf("hold should have become a loan: %+v", loans)
+	}
+	if holds, _ := db.GetHolds(); len(holds) != 0 {
+		t.Fatalf("collected hold still listed: %+v", holds)
+	}
+}
+
+func TestHoldIsNotALoanUntilCollected(t *testing.T) {
+	db := tempDB(t)
+	bookID, _ := db.AddBook("Popular Book", "Author", "")
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+
+	db.CheckoutBook(bookID, alice)
+	db.ReserveBook(bookID, bob)
+	db.ReturnBook(bookID)
+
+	loans, _ := db.GetOpenLoans(bob)
+	if len(loans) != 1 || loans[0].Status != LoanAwaitingPickup || !loans[0].DueTime.IsZero() {
+		t.Fatalf("expected a hold without a due date, got %+v", loans)
+	}
+	if fine := loans[0].AccruedFineCents(time.Now().AddDate(0, 1, 0)); fine != 0 {
+		t.Fatalf("a hold accrued a fine of %d", fine)
+	}
+	if _, err := db.ReturnBook(bookID); err == nil || !strings.Contains(err.Error(), "hold shelf") {
+		t.Fatalf("expected a hold not to be returnable, got %v", err)
+	}
+
+	loan, err := db.PickUpHold(bookID, bob)
+	if err != nil {
+		t.Fatalf("pickup: %v", err)
+	}
+	if period := loan.DueTime.Sub(loan.CheckoutTime); period != defaultLoanDays*24*time.Hour {
+		t.Fatalf("loan period should start at pickup, got %v", period)
+	}
+	if _, err := db.ReturnBook(bookID); err != nil {
+		t.Fatalf("return after pickup: %v", err)
+	}
+}
+
+func TestExpireHolds(t *testing.T) {
+	db := tempDB(t)
+	bookID, _ := db.AddBook("Popular Book", "Author", "")
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+	carol, _ := db.AddMember("Carol", "password")
+
+	db.CheckoutBook(bookID, alice)
+	db.ReserveBook(bookID, bob)
+	db.ReserveBook(bookID, carol)
+	db.ReturnBook(bookID)
+	db.ReadNotifications(bob)
+
+	if n, err := db.ExpireHolds(); err != nil || n != 0 {
+		t.Fatalf("nothing should expire yet: %d %v", n, err)
+	}
+
+	lapse := func() {
+		t.Helper()
+		if _, err := db.db.Exec(`UPDATE checkouts SET hold_expiry_time=datetime('now', '-1 hour') WHERE status=?`, LoanAwaitingPickup); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Bob's hold lapses and passes to Carol
+	lapse()
+	if n, err := db.ExpireHolds(); err != nil || n != 1 {
+		t.Fatalf("expire: %d %v", n, err)
+	}
+	if loans, _ := db.GetOpenLoans(bob); len(loans) != 0 {
+		t.Fatalf("Bob should no longer hold the book: %+v", loans)
+	}
+	notes, _ := db.ReadNotifications(bob)
+	if len(notes) != 1 || notes[0].Kind != NoticeHoldExpired {
+		t.Fatalf("expected a hold-expired notice, got %+v", notes)
+	}
+	history, _ := db.GetMemberHistory(bob)
+	if len(history) != 1 || history[0].Status != LoanHoldExpired || history[0].ReturnTime == nil {
+		t.Fatalf("lapsed hold not closed: %+v", history)
+	}
+	holds, _ := db.GetHolds()
+	if len(holds) != 1 || holds[0].MemberID != carol {
+		t.Fatalf("hold should pass to Carol: %+v", holds)
+	}
+	if n, _ := db.CountUnreadNotifications(carol); n != 1 {
+		t.Fatalf("Carol should be told her hold is ready, has %d notices", n)
+	}
+
+	// With nobody left waiting, the book goes back on the shelf
+	lapse()
+	if n, err := db.ExpireHolds(); err != nil || n != 1 {
+		t.Fatalf("expire: %d %v", n, err)
+	}
+	book, _ := db.GetBook(bookID)
+	if !book.Available || book.BorrowerID != 0 {
+		t.Fatalf("book should be back on the shelf: %+v", book)
+	}
+}
+
+func TestFulfillHold(t *testing.T) {
+	db := tempDB(t)
+	staff, _ := db.AddMember("Staff", "password")
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+	bookID, _ := db.AddBook("Popular Book", "Author", "")
+
+	if _, err := db.FulfillHold(bookID, bob, staff); err == nil {
+		t.Fatalf("expected an error with no hold waiting")
+	}
+	db.CheckoutBook(bookID, alice)
+	db.ReserveBook(bookID, bob)
+	db.ReturnBook(bookID)
+
+	if _, err := db.FulfillHold(bookID, alice, staff); err == nil || !strings.Contains(err.Error(), "on hold for member") {
+		t.Fatalf("expected the hold to be refused to someone else, got %v", err)
+	}
+	loan, err := db.FulfillHold(bookID, bob, staff)
+	if err != nil {
+		t.Fatalf("fulfill: %v", err)
+	}
+	if loan.MemberID != bob || loan.Status != LoanActive || loan.HoldExpiryTime != nil {
+		t.Fatalf("expected an active loan for Bob, got %+v", loan)
+	}
+	if holds, _ := db.GetHolds(); len(holds) != 0 {
+		t.Fatalf("the hold shelf should be empty, has %d", len(holds))
+	}
+	entries, _ := db.GetAuditLog(1)
+	if len(entries) != 1 || entries[0].Action != AuditFulfillHold || entries[0].ActorID != staff {
+		t.Fatalf("expected the handover to be audited, got %+v", entries)
+	}
+}
+
+func TestHoldPolicies(t *testing.T) {
+	db := tempDB(t)
+	if err := db.SetHoldPolicy("lottery"); err == nil {
+		t.Fatalf("expected an unknown policy to be refused")
+	}
+	bookID, _ := db.AddBook("Popular Book", "Author", "")
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+	carol, _ := db.AddMember("Carol", "password")
+
+	db.SetHoldPolicy(HoldPolicyImmediate)
+	db.CheckoutBook(bookID, alice)
+	db.ReserveBook(bookID, bob)
+	db.ReserveBook(bookID, carol)
+	if _, err := db.ReturnBook(bookID); err != nil {
+		t.Fatalf("return: %v", err)
+	}
+	loans, _ := db.GetOpenLoans(bob)
+	if len(loans) != 1 || loans[0].Status != LoanActive || loans[0].DueTime.IsZero() {
+		t.Fatalf("expected the book checked out to Bob, got %+v", loans)
+	}
+	if holds, _ := db.GetHolds(); len(holds) != 0 {
+		t.Fatalf("immediate policy left a hold: %+v", holds)
+	}
+	notes, _ := db.ReadNotifications(bob)
+	if len(notes) != 1 || !strings.Contains(notes[0].Body, "checked out to you") {
+		t.Fatalf("expected Bob told of the loan, got %+v", notes)
+	}
+
+	db.SetHoldPolicy(HoldPolicyNotify)
+	if _, err := db.ReturnBook(bookID); err != nil {
+		t.Fatalf("return: %v", err)
+	}
+	book, _ := db.GetBook(bookID)
+	if !book.Available {
+		t.Fatalf("notify policy should put the book back on the shelf")
+	}
+	notes, _ = db.ReadNotifications(carol)
+	if len(notes) != 1 || !strings.Contains(notes[0].Body, "back on the shelf") {
+		t.Fatalf("expected Carol told the book is in, got %+v", notes)
+	}
+	// Not held for her: anyone may borrow it
+	if err := db.CheckoutBook(bookID, alice); err != nil {
+		t.Fatalf("checkout after notify: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// UsageStats summarises circulation and in-library use over a period.
+// In-library consultations are counted separately from checkouts because
+// reference usage never shows up in loan records.
+type UsageStats struct {
+	Checkouts     int64
+	InLibraryUses int64
+	TopInLibrary  []*ItemUsage // most consulted items, busiest first
+}
+
+// ItemUsage is the number of in-library consultations of one item.
+type ItemUsage struct {
+	BookID int64
+	Title  string
+	Uses   int64
+}
+
+// SetNonCirculating marks bookID as a reference item that can be consulted
+// in the library but not checked out or reserved.
+func (d *Database) SetNonCirculating(bookID int64, nonCirculating bool) error {
+	res, err := d.db.Exec(`UPDATE books SET non_circulating=? WHERE id=?`, nonCirculating, bookID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("book not found")
+	}
+	return nil
+}
+
+// RecordInLibraryUse logs one consultation of bookID without a checkout.
+func (d *Database) RecordInLibraryUse(bookID int64) error {
+	var exists int
+	err := d.db.QueryRow(`SELECT 1 FROM books WHERE id=?`, bookID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("book not found")
+	}
+	if err != nil {
+		return err
+	}
+	_, err = d.db.Exec(`INSERT INTO in_library_uses(book_id, use_time) VALUES(?, ?)`, bookID, d.sqlNow())
+	return err
+}
+
+// GetUsageStats counts checkouts and in-library uses from since up to and
+// including until, listing up to top of the most consulted items.
+func (d *Database) GetUsageStats(since, until time.Time, top int) (*UsageStats, error) {
+	from, to := sqlTime(&since), sqlTime(&until)
+	var stats UsageStats
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM checkouts WHERE checkout_time >= ? AND checkout_time <= ?`, from, to).Scan(&stats.Checkouts); err != nil {
+		return nil, err
+	}
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM in_library_uses WHERE use_time >= ? AND use_time <= ?`, from, to).Scan(&stats.InLibraryUses); err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.Query(`SELECT u.book_id, b.title, COUNT(*) AS uses
+                             FROM in_library_uses u
+                             JOIN books b ON u.book_id = b.id
+                             WHERE u.use_time >= ? AND u.use_time <= ?
+                             GROUP BY u.book_id
+                             ORDER BY uses DESC, u.book_id
+                             LIMIT ?`, from, to, top)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u ItemUsage
+		if err := rows.Scan(&u.BookID, &u.Title, &u.Uses); err != nil {
+			return nil, err
+		}
+		stats.TopInLibrary = append(stats.TopInLibrary, &u)
+	}
+	return &stats, rows.Err()
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) SetNonCirculating(bookID int64, nonCirculating bool) error {
+	return lm.db.SetNonCirculating(bookID, nonCirculating)
+}
+
+func (lm *LibraryManager) RecordInLibraryUse(bookID int64) error {
+	return lm.db.RecordInLibraryUse(bookID)
+}
+
+func (lm *LibraryManager) GetUsageStats(since, until time.Time, top int) (*UsageStats, error) {
+	return lm.db.GetUsageStats(since, until, top)
+}
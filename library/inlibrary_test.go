@@ -0,0 +1,48 @@
+package library
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInLibraryUse(t *testing.T) {
+	db := tempDB(t)
+	atlas, _ := db.AddBook("World Atlas", "Cartographer", "maps")
+	novel, _ := db.AddBook("Novel", "Author", "story")
+	alice, _ := db.AddMember("Alice", "password")
+
+	if err := db.SetNonCirculating(atlas, true); err != nil {
+		t.Fatalf("set non-circulating: %v", err)
+	}
+	if err := db.CheckoutBook(atlas, alice); err == nil {
+		t.Fatalf("reference item should not be checked out")
+	}
+	if err := db.ReserveBook(atlas, alice); err == nil {
+		t.Fatalf("reference item should not be reserved")
+	}
+	if b, _ := db.GetBook(atlas); !b.NonCirculating || !b.Available {
+		t.Fatalf("atlas should be an available reference item: %+v", b)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := db.RecordInLibraryUse(atlas); err != nil {
+			t.Fatalf("record use: %v", err)
+		}
+	}
+	db.RecordInLibraryUse(novel)
+	if err := db.RecordInLibraryUse(999); err == nil {
+		t.Fatalf("unknown book should be rejected")
+	}
+	db.CheckoutBook(novel, alice)
+
+	stats, err := db.GetUsageStats(time.Now().Add(-time.Hour), time.Now().Add(time.Minute), 10)
+	if err != nil {
+		t.Fatalf("usage stats: %v", err)
+	}
+	if stats.Checkouts != 1 || stats.InLibraryUses != 4 {
+		t.Fatalf("checkouts=%d in-library=%d", stats.Checkouts, stats.InLibraryUses)
+	}
+	if len(stats.TopInLibrary) != 2 || stats.TopInLibrary[0].BookID != atlas || stats.TopInLibrary[0].Uses != 3 {
+		t.Fatalf("unexpected top items: %+v", stats.TopInLibrary)
+	}
+}
@@ -0,0 +1,133 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ItemBook is the item type of ordinary catalogue books.
+const ItemBook = "book"
+
+// Deposit states stored in checkouts.deposit_status.
+const (
+	DepositHeld      = "held"
+	DepositRefunded  = "refunded"
+	DepositForfeited = "forfeited"
+)
+
+// ItemType holds the circulation rules for a kind of item. Zero LoanDays or
+// FineCentsPerDay means the borrower's membership tier decides.
+type ItemType struct {
+	Name            string `json:"name"`
+	LoanDays        int    `json:"loan_days,omitempty"`
+	FineCentsPerDay int64  `json:"fine_cents_per_day,omitempty"`
+	DepositCents    int64  `json:"deposit_cents"`
+}
+
+const itemTypeColumns = `name, COALESCE(loan_days, 0), COALESCE(fine_cents_per_day, 0), deposit_cents`
+
+// GetItemType returns the rules for the named item type.
+func (d *Database) GetItemType(name string) (*ItemType, error) {
+	var it ItemType
+	err := d.db.QueryRow(`SELECT `+itemTypeColumns+` FROM item_types WHERE name=?`, name).
+		Scan(&it.Name, &it.LoanDays, &it.FineCentsPerDay, &it.DepositCents)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("unknown item type %q", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &it, nil
+}
+
+// GetItemTypes lists every item type.
+func (d *Database) GetItemTypes() ([]*ItemType, error) {
+	rows, err := d.db.Query(`SELECT ` + itemTypeColumns + ` FROM item_types ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var types []*ItemType
+	for rows.Next() {
+		var it ItemType
+		if err := rows.Scan(&it.Name, &it.LoanDays, &it.FineCentsPerDay, &it.DepositCents); err != nil {
+			return nil, err
+		}
+		types = append(types, &it)
+	}
+	return types, rows.Err()
+}
+
+// AddItem catalogues a non-book item such as a laptop or board game along
+// with the accessories that must come back with it.
+func (d *Database) AddItem(title, maker, itemType string, accessories []string) (int64, error) {
+	if _, err := d.GetItemType(itemType); err != nil {
+		return 0, err
+	}
+
+	return inTxResult(d, func(tx *sql.Tx) (int64, error) {
+		id, err := d.addBook(tx, title, maker, "", 0)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`UPDATE books SET item_type=? WHERE id=?`, itemType, id); err != nil {
+			return 0, err
+		}
+		for _, a := range accessories {
+			if a = strings.TrimSpace(a); a == "" {
+				continue
+			}
+			if _, err := tx.Exec(`INSERT INTO item_accessories(book_id, name) VALUES(?,?)`, id, a); err != nil {
+				return 0, err
+			}
+		}
+		return id, nil
+	})
+}
+
+// GetAccessories lists the accessories that belong with bookID.
+func (d *Database) GetAccessories(bookID int64) ([]string, error) {
+	rows, err := d.db.Query(`SELECT name FROM item_accessories WHERE book_id=? ORDER BY id`, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accessories []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		accessories = append(accessories, name)
+	}
+	return accessories, rows.Err()
+}
+
+// requireNoAccessories rejects self-service returns of items whose
+// accessory checklist has to be verified by staff.
+func (d *Database) requireNoAccessories(bookID int64) error {
+	accessories, err := d.GetAccessories(bookID)
+	if err != nil {
+		return err
+	}
+	if len(accessories) > 0 {
+		return fmt.Errorf("item %d has accessories and must be checked in at the desk", bookID)
+	}
+	return nil
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) AddItem(title, maker, itemType string, accessories []string) (int64, error) {
+	return lm.db.AddItem(title, maker, itemType, accessories)
+}
+
+func (lm *LibraryManager) GetItemType(name string) (*ItemType, error) { return lm.db.GetItemType(name) }
+func (lm *LibraryManager) GetItemTypes() ([]*ItemType, error)         { return lm.db.GetItemTypes() }
+
+func (lm *LibraryManager) GetAccessories(bookID int64) ([]string, error) {
+	return lm.db.GetAccessories(bookID)
+}
@@ -0,0 +1,52 @@
+package library
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEquipmentLending(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	laptop, err := db.AddItem("Chromebook #3", "Acme", "laptop", []string{"charger", "sleeve"})
+	if err != nil {
+		t.Fatalf("add item: %v", err)
+	}
+	if _, err := db.AddItem("Widget", "Acme", "spaceship", nil); err == nil {
+		t.Fatalf("unknown item type should be rejected")
+	}
+	alice, _ := db.AddMember("Alice", "password")
+
+	if err := lm.CheckoutBook(laptop, alice); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	loans, _ := db.GetOpenLoans(alice)
+	if len(loans) != 1 {
+		t.Fatalf("expected one open loan, got %d", len(loans))
+	}
+	loan := loans[0]
+	if got := loan.DueTime.Sub(loan.CheckoutTime); got != 7*24*time.Hour {
+		t.Fatalf("laptop loan period = %v, want 7 days", got)
+	}
+	if loan.FineCentsPerDay != 500 || loan.DepositCents != 10000 || loan.DepositStatus != DepositHeld {
+		t.Fatalf("unexpected laptop loan rules: %+v", loan)
+	}
+
+	// Equipment with accessories can't be self-returned
+	if _, _, err := lm.ReturnBookWithDetails(laptop, alice); err == nil {
+		t.Fatalf("self-return of equipment with accessories should be refused")
+	}
+
+	res, err := lm.CheckInItem(laptop, []string{"charger"})
+	if err != nil {
+		t.Fatalf("check in: %v", err)
+	}
+	if res.DepositStatus != DepositForfeited || res.DepositCents != 10000 {
+		t.Fatalf("deposit should be forfeited with a missing charger: %+v", res)
+	}
+
+	lm.CheckoutBook(laptop, alice)
+	if res, err = lm.CheckInItem(laptop, nil); err != nil || res.DepositStatus != DepositRefunded {
+		t.Fatalf("deposit should be refunded when complete: %+v, err=%v", res, err)
+	}
+}
@@ -0,0 +1,107 @@
+package library
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Job is a named task that the JobRunner executes every Interval.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func() error
+}
+
+// JobResult describes the outcome of a single job execution.
+type JobResult struct {
+	Name     string
+	Started  time.Time
+	Duration time.Duration
+	Err      error
+}
+
+// JobRunner runs registered jobs on their intervals. Jobs never overlap:
+// a runner executes at most one job at a time.
+type JobRunner struct {
+	mu      sync.Mutex
+	jobs    []*Job
+	lastRun map[string]time.Time
+	onDone  func(JobResult)
+}
+
+// NewJobRunner creates an empty runner. onDone, if non-nil, is called after
+// every job execution (useful for logging failures).
+func NewJobRunner(onDone func(JobResult)) *JobRunner {
+	return &JobRunner{lastRun: make(map[string]time.Time), onDone: onDone}
+}
+
+// Add registers a job. Jobs with duplicate names are rejected.
+func (r *JobRunner) Add(job *Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if job.Interval <= 0 {
+		return fmt.Errorf("job %q: interval must be positive", job.Name)
+	}
+	for _, existing := range r.jobs {
+		if existing.Name == job.Name {
+			return fmt.Errorf("job %q already registered", job.Name)
+		}
+	}
+	r.jobs = append(r.jobs, job)
+	return nil
+}
+
+// RunDue executes every job whose interval has elapsed since its last run.
+func (r *JobRunner) RunDue(now time.Time) []JobResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var results []JobResult
+	for _, job := range r.jobs {
+		if last, ok := r.lastRun[job.Name]; ok && now.Sub(last) < job.Interval {
+			continue
+		}
+		results = append(results, r.run(job, now))
+	}
+	return results
+}
+
+// RunAll executes every job immediately, regardless of schedule.
+func (r *JobRunner) RunAll() []JobResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var results []JobResult
+	for _, job := range r.jobs {
+		results = append(results, r.run(job, time.Now()))
+	}
+	return results
+}
+
+// run must be called with r.mu held.
+func (r *JobRunner) run(job *Job, now time.Time) JobResult {
+	start := time.Now()
+	err := job.Run()
+	r.lastRun[job.Name] = now
+	res := JobResult{Name: job.Name, Started: start, Duration: time.Since(start), Err: err}
+	if r.onDone != nil {
+		r.onDone(res)
+	}
+	return res
+}
+
+// Start runs due jobs every tick until stop is closed.
+func (r *JobRunner) Start(tick time.Duration, stop <-chan struct{}) {
+	go func() {
+		t := time.NewTicker(tick)
+		defer t.Stop()
+		r.RunDue(time.Now())
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-t.C:
+				r.RunDue(now)
+			}
+		}
+	}()
+}
@@ -0,0 +1,165 @@
+package library
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// LegacyImport counts what ImportLegacyData brought over.
+type LegacyImport struct {
+	Books     int
+	Members   int
+	Checkouts int
+}
+
+// ImportLegacyData loads the JSON state written by the old file-based
+// version of the library. Books and members keep their IDs, so the
+// database must not already use them. Legacy members had no passwords;
+// they are imported without one and staff must reset it before they can
+// sign in. Checked-out books start a fresh loan period.
+func (d *Database) ImportLegacyData(data *LibraryData) (*LegacyImport, error) {
+	loans, err := legacyLoans(data)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &LegacyImport{}
+	err = d.inTx(func(tx *sql.Tx) error {
+		*report = LegacyImport{}
+		for _, key := range sortedKeys(data.Members) {
+			m := data.Members[key]
+			if _, err := tx.Exec(`INSERT INTO members(id, name) VALUES(?,?)`, m.ID, m.Name); err != nil {
+				return fmt.Errorf("import member %d (%s): %w", m.ID, m.Name, err)
+			}
+			report.Members++
+		}
+		for _, key := range sortedKeys(data.Books) {
+			b := data.Books[key]
+			if _, err := tx.Exec(`INSERT INTO books(id, title, author) VALUES(?,?,?)`, b.ID, b.Title, b.Author); err != nil {
+				return fmt.Errorf("import book %d (%s): %w", b.ID, b.Title, err)
+			}
+			if b.Content != "" {
+				if err := setInlineContent(tx, b.ID, b.Content); err != nil {
+					return fmt.Errorf("import book %d (%s): %w", b.ID, b.Title, err)
+				}
+			}
+			detail := fmt.Sprintf("imported book %d '%s' by %s from legacy data", b.ID, b.Title, b.Author)
+			if err := recordAuditOf(tx, 0, AuditAddBook, 0, b.ID, detail); err != nil {
+				return err
+			}
+			report.Books++
+		}
+
+		for _, l := range loans {
+			if _, err := tx.Exec(`UPDATE books SET available=0, borrower_id=? WHERE id=?`, l.memberID, l.bookID); err != nil {
+				return err
+			}
+			if _, err := d.insertCheckout(tx, l.bookID, l.memberID); err != nil {
+				return fmt.Errorf("import checkout of book %d: %w", l.bookID, err)
+			}
+			detail := fmt.Sprintf("imported checkout of book %d from legacy data", l.bookID)
+			if err := recordAuditOf(tx, 0, AuditCheckout, l.memberID, l.bookID, detail); err != nil {
+				return err
+			}
+			report.Checkouts++
+		}
+
+		// Don't hand out IDs the old version had already used
+		for table, next := range map[string]int{"books": data.NextBookID, "members": data.NextMemberID} {
+			if _, err := tx.Exec(`UPDATE sqlite_sequence SET seq = MAX(seq, ?) WHERE name = ?`, next-1, table); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+type legacyLoan struct {
+	bookID, memberID int64
+}
+
+// legacyLoans reconciles the checked-out map with each book's own borrower
+// field, which old versions did not always keep in step.
+func legacyLoans(data *LibraryData) ([]legacyLoan, error) {
+	borrower := make(map[int64]int64)
+	assign := func(bookID, memberID int64) error {
+		if data.Books[strconv.FormatInt(bookID, 10)] == nil {
+			return fmt.Errorf("checked-out book %d is not in the catalog", bookID)
+		}
+		if data.Members[strconv.FormatInt(memberID, 10)] == nil {
+			return fmt.Errorf("book %d is checked out to unknown member %d", bookID, memberID)
+		}
+		if prev, ok := borrower[bookID]; ok && prev != memberID {
+			return fmt.Errorf("book %d is checked out to both member %d and member %d", bookID, prev, memberID)
+		}
+		borrower[bookID] = memberID
+		return nil
+	}
+
+	for memberKey, bookKeys := range data.CheckedOutBooks {
+		memberID, err := strconv.ParseInt(memberKey, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid member ID %q in checked-out books", memberKey)
+		}
+		for _, bookKey := range bookKeys {
+			bookID, err := strconv.ParseInt(bookKey, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid book ID %q in checked-out books", bookKey)
+			}
+			if err := assign(bookID, memberID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, b := range data.Books {
+		if !b.Available && b.BorrowerID != 0 {
+			if err := assign(b.ID, b.BorrowerID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	loans := make([]legacyLoan, 0, len(borrower))
+	for bookID, memberID := range borrower {
+		loans = append(loans, legacyLoan{bookID, memberID})
+	}
+	sort.Slice(loans, func(i, j int) bool { return loans[i].bookID < loans[j].bookID })
+	return loans, nil
+}
+
+// sortedKeys returns m's keys in numeric order so imports are deterministic.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, _ := strconv.ParseInt(keys[i], 10, 64)
+		b, _ := strconv.ParseInt(keys[j], 10, 64)
+		return a < b
+	})
+	return keys
+}
+
+// ------------------ Manager helpers ------------------
+
+// LoadData imports a legacy JSON data file; see Database.ImportLegacyData.
+func (lm *LibraryManager) LoadData(path string) (*LegacyImport, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var data LibraryData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return lm.db.ImportLegacyData(&data)
+}
@@ -0,0 +1,82 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const legacyJSON = `{
+  "books": {
+    "1": {"id": 1, "title": "Dune", "author": "Frank Herbert", "content": "Arrakis", "available": false, "borrower_id": 2},
+    "2": {"id": 2, "title": "Emma", "author": "Jane Austen", "content": "Highbury", "available": false},
+    "4": {"id": 4, "title": "Ulysses", "author": "James Joyce", "content": "Dublin", "available": true}
+  },
+  "members": {
+    "1": {"id": 1, "name": "Alice"},
+    "2": {"id": 2, "name": "Bob"}
+  },
+  "next_book_id": 6,
+  "next_member_id": 3,
+  "checked_out_books": {"1": ["2"], "2": ["1"]}
+}`
+
+func TestLoadLegacyData(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	path := filepath.Join(t.TempDir(), "library.json")
+	os.WriteFile(path, []byte(legacyJSON), 0o644)
+
+	report, err := lm.LoadData(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if report.Books != 3 || report.Members != 2 || report.Checkouts != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	dune, _ := db.GetBook(1)
+	if dune.Available || dune.BorrowerID != 2 {
+		t.Fatalf("Dune should be on loan to Bob: %+v", dune)
+	}
+	if loans, _ := db.GetOpenLoans(1); len(loans) != 1 || loans[0].BookID != 2 {
+		t.Fatalf("Alice should have Emma on loan: %+v", loans)
+	}
+	if books, _ := db.SearchBooks("Dublin"); len(books) != 1 || books[0].ID != 4 {
+		t.Fatalf("imported content should be searchable: %+v", books)
+	}
+
+	// Legacy members have to be given a password first
+	if err := db.AuthenticateMember(1, ""); err == nil || !strings.Contains(err.Error(), "password") {
+		t.Fatalf("expected password setup error, got %v", err)
+	}
+
+	// New records continue after the old version's counters
+	if id, _ := db.AddBook("New", "Author", ""); id != 6 {
+		t.Fatalf("expected next book ID 6, got %d", id)
+	}
+	if id, _ := db.AddMember("Carol", "password"); id != 3 {
+		t.Fatalf("expected next member ID 3, got %d", id)
+	}
+
+	// Importing again would reuse the IDs
+	if _, err := lm.LoadData(path); err == nil {
+		t.Fatalf("expected second import to fail")
+	}
+}
+
+func TestLegacyLoansConflict(t *testing.T) {
+	db := tempDB(t)
+	data := &LibraryData{
+		Books:           map[string]*Book{"1": {ID: 1, Title: "Dune", Available: false, BorrowerID: 2}},
+		Members:         map[string]*Member{"1": {ID: 1, Name: "Alice"}, "2": {ID: 2, Name: "Bob"}},
+		CheckedOutBooks: map[string][]string{"1": {"1"}},
+	}
+	if _, err := db.ImportLegacyData(data); err == nil {
+		t.Fatalf("expected conflicting borrowers to be rejected")
+	}
+	if books, _ := db.GetAllBooks(); len(books) != 0 {
+		t.Fatalf("nothing should be imported on error")
+	}
+}
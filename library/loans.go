@@ -0,0 +1,286 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// defaultFineCentsPerDay is the overdue fine per started day when the
+// borrower's tier is unknown.
+const defaultFineCentsPerDay = 25
+
+// SetDefaultFineRate sets the overdue fine per started day when the
+// borrower's tier is unknown; a negative rate restores the default.
+func (d *Database) SetDefaultFineRate(cents int64) {
+	if cents < 0 {
+		cents = defaultFineCentsPerDay
+	}
+	d.fineCentsPerDay = cents
+}
+
+// AccruedFineCents returns the overdue fine accrued on the loan as of now.
+// Accrual stops when the loan is returned or a claims-returned dispute is
+// opened, so patrons aren't charged while staff investigate.
+func (l *Loan) AccruedFineCents(now time.Time) int64 {
+	end := now
+	if l.ReturnTime != nil {
+		end = *l.ReturnTime
+	}
+	if l.ClaimTime != nil && l.ClaimTime.Before(end) {
+		end = *l.ClaimTime
+	}
+	if l.DueTime.IsZero() {
+		// A hold never collected was never a loan
+		return 0
+	}
+	overdue := end.Sub(l.DueTime)
+	if overdue <= 0 {
+		return 0
+	}
+	days := int64((overdue + 24*time.Hour - 1) / (24 * time.Hour))
+	return days * l.FineCentsPerDay
+}
+
+// loanColumns are the columns scanLoans reads, with d's default fine rate.
+func (d *Database) loanColumns() string {
+	return fmt.Sprintf(`c.id, c.book_id, b.title, c.member_id, m.name, c.checkout_time, c.due_time, c.return_time, c.status, c.claim_time,
+	COALESCE((SELECT it.fine_cents_per_day FROM item_types it WHERE it.name = b.item_type),
+	         (SELECT t.fine_cents_per_day FROM membership_tiers t WHERE t.name = m.tier), %d),
+	c.deposit_cents, COALESCE(c.deposit_status, ''), COALESCE(c.picked_up_by, 0), COALESCE(c.returned_by, 0),
+	c.hold_expiry_time`, d.fineCentsPerDay)
+}
+
+// getLoan reads checkout checkoutID through q, a database or transaction.
+func (d *Database) getLoan(q interface {
+	Query(string, ...any) (*sql.Rows, error)
+}, checkoutID int64) (*Loan, error) {
+	rows, err := q.Query(`SELECT `+d.loanColumns()+`
+	                      FROM checkouts c
+	                      JOIN books b ON c.book_id = b.id
+	                      JOIN members m ON c.member_id = m.id
+	                      WHERE c.id = ?`, checkoutID)
+	if err != nil {
+		return nil, err
+	}
+	loans, err := scanLoans(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(loans) == 0 {
+		return nil, fmt.Errorf("checkout %d not found", checkoutID)
+	}
+	return loans[0], nil
+}
+
+func scanLoans(rows *sql.Rows) ([]*Loan, error) {
+	defer rows.Close()
+	var loans []*Loan
+	for rows.Next() {
+		var l Loan
+		var due, returned, claimed, holdExpiry sql.NullTime
+		if err := rows.Scan(&l.CheckoutID, &l.BookID, &l.BookTitle, &l.MemberID, &l.MemberName,
+			&l.CheckoutTime, &due, &returned, &l.Status, &claimed, &l.FineCentsPerDay,
+			&l.DepositCents, &l.DepositStatus, &l.PickedUpBy, &l.ReturnedBy, &holdExpiry); err != nil {
+			return nil, err
+		}
+		l.DueTime = due.Time
+		if returned.Valid {
+			l.ReturnTime = &returned.Time
+		}
+		if claimed.Valid {
+			l.ClaimTime = &claimed.Time
+		}
+		if holdExpiry.Valid {
+			l.HoldExpiryTime = &holdExpiry.Time
+		}
+		loans = append(loans, &l)
+	}
+	return loans, rows.Err()
+}
+
+// GetOpenLoans lists memberID's loans that have not been returned yet,
+// including disputed and lost ones.
+func (d *Database) GetOpenLoans(memberID int64) ([]*Loan, error) {
+	rows, err := d.db.Query(`SELECT `+d.loanColumns()+`
+                             FROM checkouts c
+                             JOIN books b ON c.book_id = b.id
+                             JOIN members m ON c.member_id = m.id
+                             WHERE c.member_id = ? AND c.return_time IS NULL
+                             ORDER BY c.due_time`, memberID)
+	if err != nil {
+		return nil, err
+	}
+	return scanLoans(rows)
+}
+
+// GetLoansByStatus lists open loans currently in the given status.
+func (d *Database) GetLoansByStatus(status string) ([]*Loan, error) {
+	rows, err := d.db.Query(`SELECT `+d.loanColumns()+`
+                             FROM checkouts c
+                             JOIN books b ON c.book_id = b.id
+                             JOIN members m ON c.member_id = m.id
+                             WHERE c.status = ? AND c.return_time IS NULL
+                             ORDER BY c.claim_time, c.id`, status)
+	if err != nil {
+		return nil, err
+	}
+	return scanLoans(rows)
+}
+
+// getOpenLoanForBook returns the open checkout for bookID.
+func (d *Database) getOpenLoanForBook(bookID int64) (*Loan, error) {
+	rows, err := d.db.Query(`SELECT `+d.loanColumns()+`
+                             FROM checkouts c
+                             JOIN books b ON c.book_id = b.id
+                             JOIN members m ON c.member_id = m.id
+                             WHERE c.book_id = ? AND c.return_time IS NULL
+                             ORDER BY c.id DESC LIMIT 1`, bookID)
+	if err != nil {
+		return nil, err
+	}
+	loans, err := scanLoans(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(loans) == 0 {
+		return nil, fmt.Errorf("book %d has no open checkout", bookID)
+	}
+	return loans[0], nil
+}
+
+// GetMemberHistory lists every checkout memberID has made, returned or not,
+// newest first.
+func (d *Database) GetMemberHistory(memberID int64) ([]*Loan, error) {
+	rows, err := d.db.Query(`SELECT `+d.loanColumns()+`
+                             FROM checkouts c
+                             JOIN books b ON c.book_id = b.id
+                             JOIN members m ON c.member_id = m.id
+                             WHERE c.member_id = ?
+                             ORDER BY c.checkout_time DESC, c.id DESC`, memberID)
+	if err != nil {
+		return nil, err
+	}
+	return scanLoans(rows)
+}
+
+// GetBookHistory lists every checkout of bookID, returned or not, newest
+// first.
+func (d *Database) GetBookHistory(bookID int64) ([]*Loan, error) {
+	rows, err := d.db.Query(`SELECT `+d.loanColumns()+`
+                             FROM checkouts c
+                             JOIN books b ON c.book_id = b.id
+                             JOIN members m ON c.member_id = m.id
+                             WHERE c.book_id = ?
+                             ORDER BY c.checkout_time DESC, c.id DESC`, bookID)
+	if err != nil {
+		return nil, err
+	}
+	return scanLoans(rows)
+}
+
+// MarkClaimsReturned opens a dispute on bookID's active loan: the patron says
+// it was returned, so fine accrual stops and the copy is flagged for a shelf
+// search.
+func (d *Database) MarkClaimsReturned(bookID int64) error {
+	res, err := d.db.Exec(`UPDATE checkouts SET status=?, claim_time=?
+                           WHERE book_id=? AND return_time IS NULL AND status=?`,
+		LoanClaimsReturned, d.sqlNow(), bookID, LoanActive)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("book %d has no active checkout to dispute", bookID)
+	}
+	return nil
+}
+
+// MarkLost flags bookID's open loan as lost and bills the borrower for a
+// replacement copy. The checkout stays open so a later check-in can still
+// close it and reverse the charge.
+func (d *Database) MarkLost(bookID int64) error {
+	return d.inTx(func(tx *sql.Tx) error {
+		var checkoutID, memberID int64
+		var title string
+		var cost sql.NullInt64
+		err := tx.QueryRow(`SELECT c.id, c.member_id, b.title, b.replacement_cost_cents
+	                       FROM checkouts c JOIN books b ON c.book_id = b.id
+	                       WHERE c.book_id=? AND c.return_time IS NULL AND c.status IN (?, ?)`,
+			bookID, LoanActive, LoanClaimsReturned).Scan(&checkoutID, &memberID, &title, &cost)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("book %d has no open checkout to mark lost", bookID)
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`UPDATE checkouts SET status=? WHERE id=?`, LoanLost, checkoutID); err != nil {
+			return err
+		}
+
+		amount := int64(defaultReplacementCents)
+		if cost.Valid {
+			amount = cost.Int64
+		}
+		if _, err := tx.Exec(`INSERT INTO fines(member_id, checkout_id, kind, amount_cents, description, created_time) VALUES(?,?,?,?,?,?)`,
+			memberID, checkoutID, FineReplacement, amount, fmt.Sprintf("Replacement for lost copy of '%s'", title), d.sqlNow()); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) SetDefaultLoanDays(days int) { lm.db.SetDefaultLoanDays(days) }
+
+func (lm *LibraryManager) SetDefaultFineRate(cents int64) { lm.db.SetDefaultFineRate(cents) }
+
+func (lm *LibraryManager) GetOpenLoans(memberID int64) ([]*Loan, error) {
+	return lm.db.GetOpenLoans(memberID)
+}
+
+func (lm *LibraryManager) GetMemberHistory(memberID int64) ([]*Loan, error) {
+	return lm.db.GetMemberHistory(memberID)
+}
+
+func (lm *LibraryManager) GetBookHistory(bookID int64) ([]*Loan, error) {
+	return lm.db.GetBookHistory(bookID)
+}
+
+// GetShelfSearchList returns copies under a claims-returned dispute that staff
+// should look for on the shelves.
+func (lm *LibraryManager) GetShelfSearchList() ([]*Loan, error) {
+	return lm.db.GetLoansByStatus(LoanClaimsReturned)
+}
+
+func (lm *LibraryManager) MarkClaimsReturned(bookID int64) error {
+	return lm.db.MarkClaimsReturned(bookID)
+}
+
+// MarkLost declares bookID's open loan lost and bills the borrower for a
+// replacement; the charge is reversed if the copy is later checked in.
+func (lm *LibraryManager) MarkLost(bookID int64) error {
+	return lm.db.MarkLost(bookID)
+}
+
+// ResolveClaim closes a claims-returned dispute after investigation. When the
+// copy was found it is checked in normally; otherwise the loan becomes lost.
+func (lm *LibraryManager) ResolveClaim(bookID int64, found bool) (*CheckInResult, error) {
+	loan, err := lm.db.getOpenLoanForBook(bookID)
+	if err != nil {
+		return nil, err
+	}
+	if loan.Status != LoanClaimsReturned {
+		return nil, fmt.Errorf("book %d is not under a claims-returned dispute", bookID)
+	}
+	if found {
+		return lm.CheckInBook(bookID)
+	}
+	return nil, lm.db.MarkLost(bookID)
+}
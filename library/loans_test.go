@@ -0,0 +1,158 @@
+package library
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAccruedFineCents(t *testing.T) {
+	due := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	claim := due.Add(36 * time.Hour)
+
+	tests := []struct {
+		name string
+		loan Loan
+		now  time.Time
+		want int64
+	}{
+		{"not yet due", Loan{DueTime: due, FineCentsPerDay: 25}, due.Add(-time.Hour), 0},
+		{"one partial day", Loan{DueTime: due, FineCentsPerDay: 25}, due.Add(time.Hour), 25},
+		{"three days", Loan{DueTime: due, FineCentsPerDay: 25}, due.Add(72 * time.Hour), 75},
+		{"claim suspends accrual", Loan{DueTime: due, FineCentsPerDay: 25, Status: LoanClaimsReturned, ClaimTime: &claim}, due.Add(240 * time.Hour), 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.loan.AccruedFineCents(tt.now); got != tt.want {
+				t.Errorf("AccruedFineCents = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaimsReturnedWorkflow(t *testing.T) {
+	mgr := newManager(t)
+	bookID, _ := mgr.AddBook("Disputed Book", "Author")
+	lostID, _ := mgr.AddBook("Lost Book", "Author")
+	alice, _ := mgr.AddMember("Alice", "password")
+	mgr.CheckoutBook(bookID, alice)
+	mgr.CheckoutBook(lostID, alice)
+
+	if err := mgr.MarkClaimsReturned(bookID); err != nil {
+		t.Fatalf("mark claims returned: %v", err)
+	}
+	if err := mgr.MarkClaimsReturned(bookID); err == nil {
+		t.Fatalf("a loan can only be disputed once")
+	}
+	if err := mgr.MarkClaimsReturned(lostID); err != nil {
+		t.Fatalf("mark claims returned: %v", err)
+	}
+
+	search, err := mgr.GetShelfSearchList()
+	if err != nil || len(search) != 2 {
+		t.Fatalf("want 2 copies flagged for shelf search, got %d (err=%v)", len(search), err)
+	}
+	if search[0].ClaimTime == nil {
+		t.Fatalf("claim time should be recorded")
+	}
+
+	// Found on the shelf: resolves to returned
+	if _, err := mgr.ResolveClaim(bookID, true); err != nil {
+		t.Fatalf("resolve found: %v", err)
+	}
+	if b, _ := mgr.GetBook(bookID); !b.Available {
+		t.Fatalf("found copy should be available again")
+	}
+
+	// Not found: resolves to lost, loan stays open
+	if _, err := mgr.ResolveClaim(lostID, false); err != nil {
+		t.Fatalf("resolve lost: %v", err)
+	}
+	loans, _ := mgr.GetOpenLoans(alice)
+	if len(loans) != 1 || loans[0].Status != LoanLost {
+		t.Fatalf("expected one lost loan, got %+v", loans)
+	}
+	if _, err := mgr.ResolveClaim(lostID, true); err == nil {
+		t.Fatalf("resolving a loan without a dispute should fail")
+	}
+}
+
+func TestCheckoutHistory(t *testing.T) {
+	mgr := newManager(t)
+	first, _ := mgr.AddBook("First Book", "Author")
+	second, _ := mgr.AddBook("Second Book", "Author")
+	alice, _ := mgr.AddMember("Alice", "password")
+	bob, _ := mgr.AddMember("Bob", "password")
+
+	mgr.CheckoutBook(first, alice)
+	mgr.ReturnBook(first, alice)
+	mgr.CheckoutBook(first, bob)
+	mgr.CheckoutBook(second, alice)
+
+	history, err := mgr.GetMemberHistory(alice)
+	if err != nil {
+		t.Fatalf("member history: %v", err)
+	}
+	if len(history) != 2 || history[0].BookID != second || history[1].BookID != first {
+		t.Fatalf("unexpected member history: %+v", history)
+	}
+	if history[0].ReturnTime != nil || history[1].ReturnTime == nil {
+		t.Fatalf("return times not reported: %+v %+v", history[0], history[1])
+	}
+
+	history, err = mgr.GetBookHistory(first)
+	if err != nil {
+		t.Fatalf("book history: %v", err)
+	}
+	if len(history) != 2 || history[0].MemberID != bob || history[1].MemberID != alice || history[1].MemberName != "Alice" {
+		t.Fatalf("unexpected book history: %+v", history)
+	}
+
+	if history, err := mgr.GetBookHistory(999); err != nil || len(history) != 0 {
+		t.Fatalf("expected no history for an unknown book: %v %+v", err, history)
+	}
+}
+
+func TestDefaultLoanPolicy(t *testing.T) {
+	db := tempDB(t)
+	db.SetDefaultLoanDays(3)
+	db.SetDefaultFineRate(10)
+	if err := db.SetBcryptCost(bcrypt.MinCost); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetBcryptCost(bcrypt.MaxCost + 1); err == nil {
+		t.Error("expected an out-of-range bcrypt cost to be rejected")
+	}
+	bookID, _ := db.AddBook("Dune", "Herbert", "")
+	memberID, _ := db.AddMember("Alice", "password")
+	if hash, _ := db.HashPassword("password"); !strings.HasPrefix(hash, fmt.Sprintf("$2a$%02d$", bcrypt.MinCost)) {
+		t.Errorf("hash %q not made with the configured cost", hash)
+	}
+
+	// The defaults are for members whose tier has gone, which only edits
+	// outside the application can cause
+	if _, err := db.db.Exec(fmt.Sprintf(`PRAGMA foreign_keys=OFF; UPDATE members SET tier='gone' WHERE id=%d; PRAGMA foreign_keys=ON`, memberID)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CheckoutBook(bookID, memberID); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	loans, err := db.GetOpenLoans(memberID)
+	if err != nil || len(loans) != 1 {
+		t.Fatalf("loans: %v %v", loans, err)
+	}
+	if period := loans[0].DueTime.Sub(loans[0].CheckoutTime); period != 3*24*time.Hour {
+		t.Errorf("loan period = %v, want 3 days", period)
+	}
+	if loans[0].FineCentsPerDay != 10 {
+		t.Errorf("fine rate = %d, want 10", loans[0].FineCentsPerDay)
+	}
+
+	db.SetDefaultFineRate(-1)
+	if loans, _ := db.GetOpenLoans(memberID); loans[0].FineCentsPerDay != defaultFineCentsPerDay {
+		t.Errorf("fine rate = %d after restoring the default", loans[0].FineCentsPerDay)
+	}
+}
@@ -0,0 +1,101 @@
+package library
+
+import (
+	"fmt"
+	"time"
+)
+
+// ftsMergePages bounds how much FTS index work one maintenance run does.
+const ftsMergePages = 500
+
+// MaintenanceReport summarises one run of Maintain.
+type MaintenanceReport struct {
+	PagesBefore int64
+	PagesAfter  int64
+	// WAL frames written back to the database; -1 when the database is not
+	// in WAL mode.
+	CheckpointedFrames int
+	Duration           time.Duration
+}
+
+// Maintain refreshes the query planner's statistics, returns free pages to
+// the filesystem, merges the full-text index's segments and checkpoints the
+// write-ahead log. Each step is cheap enough to run while the desk is open,
+// but the scheduled job keeps it to idle hours.
+func (d *Database) Maintain() (*MaintenanceReport, error) {
+	start := time.Now()
+	report := &MaintenanceReport{}
+	if err := d.db.QueryRow(`PRAGMA page_count`).Scan(&report.PagesBefore); err != nil {
+		return nil, err
+	}
+
+	if _, err := d.db.Exec(`ANALYZE`); err != nil {
+		return nil, fmt.Errorf("analyze: %w", err)
+	}
+	if _, err := d.db.Exec(`INSERT INTO books_fts(books_fts, rank) VALUES('merge', ?)`, ftsMergePages); err != nil {
+		return nil, fmt.Errorf("optimize search index: %w", err)
+	}
+	if err := d.incrementalVacuum(); err != nil {
+		return nil, fmt.Errorf("incremental vacuum: %w", err)
+	}
+
+	var busy, logFrames int
+	if err := d.db.QueryRow(`PRAGMA wal_checkpoint(TRUNCATE)`).Scan(&busy, &logFrames, &report.CheckpointedFrames); err != nil {
+		return nil, fmt.Errorf("checkpoint: %w", err)
+	}
+
+	if err := d.db.QueryRow(`PRAGMA page_count`).Scan(&report.PagesAfter); err != nil {
+		return nil, err
+	}
+	report.Duration = time.Since(start)
+	return report, nil
+}
+
+// incrementalVacuum releases every free page. SQLite frees one page per step
+// of the pragma, so its (empty) result set has to be drained.
+func (d *Database) incrementalVacuum() error {
+	rows, err := d.db.Query(`PRAGMA incremental_vacuum`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) Maintain() (*MaintenanceReport, error) {
+	return lm.db.Maintain()
+}
+
+// MaintenanceJob runs Maintain at most once a day, on the first check that
+// falls between idleStart and idleEnd (hours of the day, local time; the
+// window may wrap past midnight).
+func (lm *LibraryManager) MaintenanceJob(idleStart, idleEnd int, interval time.Duration) *Job {
+	var lastDay string
+	return &Job{
+		Name:     "db-maintenance",
+		Interval: interval,
+		Run: func() error {
+			now := time.Now()
+			if !inHourWindow(now.Hour(), idleStart, idleEnd) || now.Format("2006-01-02") == lastDay {
+				return nil
+			}
+			if _, err := lm.Maintain(); err != nil {
+				return err
+			}
+			lastDay = now.Format("2006-01-02")
+			return nil
+		},
+	}
+}
+
+// inHourWindow reports whether hour lies in [start, end), wrapping at midnight.
+func inHourWindow(hour, start, end int) bool {
+	if start <= end {
+		return hour >= start && hour < end
+	}
+	return hour >= start || hour < end
+}
@@ -0,0 +1,62 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMaintain(t *testing.T) {
+	db := tempDB(t)
+	for i := 0; i < 50; i++ {
+		id, _ := db.AddBook("Churn", "Author", strings.Repeat("lorem ipsum ", 2000))
+		db.UpdateBookContent(id, "short")
+		db.db.Exec(`DELETE FROM books WHERE id=?`, id)
+	}
+
+	var mode int
+	db.db.QueryRow(`PRAGMA auto_vacuum`).Scan(&mode)
+	if mode != 2 {
+		t.Fatalf("expected incremental auto_vacuum, got mode %d", mode)
+	}
+
+	var free int64
+	db.db.QueryRow(`PRAGMA freelist_count`).Scan(&free)
+	if free == 0 {
+		t.Fatalf("expected deleted content to leave free pages")
+	}
+
+	report, err := db.Maintain()
+	if err != nil {
+		t.Fatalf("maintain: %v", err)
+	}
+	if report.PagesAfter >= report.PagesBefore {
+		t.Fatalf("expected free pages to be released, before=%d after=%d", report.PagesBefore, report.PagesAfter)
+	}
+	db.db.QueryRow(`PRAGMA freelist_count`).Scan(&free)
+	if free != 0 {
+		t.Fatalf("expected empty freelist after maintenance, got %d", free)
+	}
+	var stats int
+	db.db.QueryRow(`SELECT COUNT(*) FROM sqlite_stat1`).Scan(&stats)
+	if stats == 0 {
+		t.Fatalf("ANALYZE should populate sqlite_stat1")
+	}
+}
+
+func TestInHourWindow(t *testing.T) {
+	cases := []struct {
+		hour, start, end int
+		want             bool
+	}{
+		{3, 2, 5, true},
+		{5, 2, 5, false},
+		{23, 22, 4, true},
+		{1, 22, 4, true},
+		{12, 22, 4, false},
+	}
+	for _, c := range cases {
+		if got := inHourWindow(c.hour, c.start, c.end); got != c.want {
+			t.Errorf("inHourWindow(%d, %d, %d) = %v, want %v", c.hour, c.start, c.end, got, c.want)
+		}
+	}
+}
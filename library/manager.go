@@ -6,14 +6,24 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
 // LibraryManager is a thin façade over the Database, keeping CLI code simple.
 type LibraryManager struct {
 	db *Database
+
+	// input is where the book reader reads keystrokes from; nil means
+	// standard input. See SetInput.
+	input *bufio.Scanner
 }
 
+// SetInput makes the book reader take its commands from sc instead of
+// standard input, so it shares a prompt's scanner (and whatever that has
+// already buffered) rather than reading past it.
+func (lm *LibraryManager) SetInput(sc *bufio.Scanner) { lm.input = sc }
+
 // NewLibraryManager opens (or creates) the SQLite database at dbPath.
 func NewLibraryManager(dbPath string) (*LibraryManager, error) {
 	db, err := NewDatabase(dbPath)
@@ -49,6 +59,16 @@ func (lm *LibraryManager) UpdateBookContent(id int64, content string) error {
 func (lm *LibraryManager) GetBook(id int64) (*Book, error) { return lm.db.GetBook(id) }
 func (lm *LibraryManager) GetAllBooks() ([]*Book, error)   { return lm.db.GetAllBooks() }
 
+func (lm *LibraryManager) GetAllBookSummaries() ([]*Book, error) {
+	return lm.db.GetAllBookSummaries()
+}
+
+func (lm *LibraryManager) GetBooksPage(offset, limit int) ([]*Book, error) {
+	return lm.db.GetBooksPage(offset, limit)
+}
+
+func (lm *LibraryManager) CountBooks() (int, error) { return lm.db.CountBooks() }
+
 // ------------------ Member helpers with Authentication ------------------
 
 // AddMember creates a new member with password validation
@@ -59,6 +79,12 @@ func (lm *LibraryManager) AddMember(name, password string) (int64, error) {
 func (lm *LibraryManager) GetMember(id int64) (*Member, error) { return lm.db.GetMember(id) }
 func (lm *LibraryManager) GetAllMembers() ([]*Member, error)   { return lm.db.GetAllMembers() }
 
+func (lm *LibraryManager) GetMembersPage(offset, limit int) ([]*Member, error) {
+	return lm.db.GetMembersPage(offset, limit)
+}
+
+func (lm *LibraryManager) CountMembers() (int, error) { return lm.db.CountMembers() }
+
 // AuthenticateMember verifies member credentials
 func (lm *LibraryManager) AuthenticateMember(memberID int64, password string) error {
 	return lm.db.AuthenticateMember(memberID, password)
@@ -69,20 +95,62 @@ func (lm *LibraryManager) ResetMemberPassword(memberID int64, newPassword string
 	return lm.db.ResetMemberPassword(memberID, newPassword)
 }
 
+func (lm *LibraryManager) ResetMemberPasswordAs(memberID int64, newPassword string, actorID int64) error {
+	return lm.db.ResetMemberPasswordAs(memberID, newPassword, actorID)
+}
+
+func (lm *LibraryManager) ChangeMemberPassword(memberID int64, currentPassword, newPassword string) error {
+	return lm.db.ChangeMemberPassword(memberID, currentPassword, newPassword)
+}
+
+func (lm *LibraryManager) UpdateMemberName(memberID int64, name string) error {
+	return lm.db.UpdateMemberName(memberID, name)
+}
+
+func (lm *LibraryManager) SetBcryptCost(cost int) error { return lm.db.SetBcryptCost(cost) }
+
 // ------------------ Reservation helpers ------------------
 
+// ReserveBook queues a reservation, or checks the book out immediately when it
+// is available, in which case blocking account alerts apply.
 func (lm *LibraryManager) ReserveBook(bookID, memberID int64) error {
+	if book, err := lm.db.GetBook(bookID); err == nil && book.Available {
+		if err := lm.db.checkBlockingAlerts(lm.db.db, memberID); err != nil {
+			return err
+		}
+	}
 	return lm.db.ReserveBook(bookID, memberID)
 }
 
+// ReserveBookFor reserves a book for several members at once, in order; see
+// Database.ReserveBookFor.
+func (lm *LibraryManager) ReserveBookFor(bookID int64, memberIDs []int64) error {
+	if book, err := lm.db.GetBook(bookID); err == nil && book.Available && len(memberIDs) > 0 {
+		if err := lm.db.checkBlockingAlerts(lm.db.db, memberIDs[0]); err != nil {
+			return err
+		}
+	}
+	return lm.db.ReserveBookFor(bookID, memberIDs)
+}
+
 func (lm *LibraryManager) GetReservations(bookID int64) ([]*Member, error) {
 	return lm.db.GetReservations(bookID)
 }
 
+// GetReservationCounts summarises every reservation queue in one query; use
+// it instead of calling GetReservations per book when listing many books.
+func (lm *LibraryManager) GetReservationCounts() (map[int64]*ReservationCount, error) {
+	return lm.db.GetReservationCounts()
+}
+
 func (lm *LibraryManager) GetMemberReservations(memberID int64) ([]*Book, error) {
 	return lm.db.GetMemberReservations(memberID)
 }
 
+func (lm *LibraryManager) GetMemberReservationSummaries(memberID int64) ([]*Book, error) {
+	return lm.db.GetMemberReservationSummaries(memberID)
+}
+
 func (lm *LibraryManager) CancelReservation(bookID, memberID int64) error {
 	return lm.db.CancelReservation(bookID, memberID)
 }
@@ -93,21 +161,43 @@ func (lm *LibraryManager) SearchBooks(q string) ([]*Book, error) {
 	return lm.db.SearchBooks(q)
 }
 
+func (lm *LibraryManager) SearchBookSummaries(q string) ([]*Book, error) {
+	return lm.db.SearchBookSummaries(q)
+}
+
+func (lm *LibraryManager) SearchBookResults(q string) ([]*SearchResult, error) {
+	return lm.db.SearchBookResults(q)
+}
+
 // ------------------ Circulation with Authorization ------------------
 
-// CheckoutBook performs a book checkout
+// CheckoutBook performs a book checkout. Members with an uncleared blocking
+// alert cannot check out until staff clear it.
 func (lm *LibraryManager) CheckoutBook(bookID, memberID int64) error {
+	if err := lm.db.checkBlockingAlerts(lm.db.db, memberID); err != nil {
+		return err
+	}
 	return lm.db.CheckoutBook(bookID, memberID)
 }
 
+func (lm *LibraryManager) CheckoutBooks(bookIDs []int64, memberID int64) ([]*Loan, error) {
+	if err := lm.db.checkBlockingAlerts(lm.db.db, memberID); err != nil {
+		return nil, err
+	}
+	return lm.db.CheckoutBooks(bookIDs, memberID)
+}
+
 // ReturnBook returns the book and yields the member who had it with authorization check
 func (lm *LibraryManager) ReturnBook(bookID, memberID int64) (int64, error) {
 	// First verify the member is authorized to return this book
 	if err := lm.db.VerifyReturnAuthorization(bookID, memberID); err != nil {
 		return 0, err
 	}
+	if err := lm.db.requireNoAccessories(bookID); err != nil {
+		return 0, err
+	}
 
-	return lm.db.ReturnBook(bookID)
+	return lm.db.returnItem(bookID, nil, memberID)
 }
 
 // ReturnBookWithDetails returns the book and provides detailed information about what happened
@@ -116,7 +206,18 @@ func (lm *LibraryManager) ReturnBookWithDetails(bookID, memberID int64) (returne
 	if err := lm.db.VerifyReturnAuthorization(bookID, memberID); err != nil {
 		return 0, 0, err
 	}
+	if err := lm.db.requireNoAccessories(bookID); err != nil {
+		return 0, 0, err
+	}
+
+	return lm.returnWithDetails(bookID, nil, memberID)
+}
 
+// returnWithDetails performs the return without any authorization check and
+// reports who returned the book and who, if anyone, it is now held for.
+// missingAccessories is the result of the desk's accessory checklist, and
+// actingID the member handing the book in (0 at the desk).
+func (lm *LibraryManager) returnWithDetails(bookID int64, missingAccessories []string, actingID int64) (returnedByMemberID int64, assignedToMemberID int64, err error) {
 	// First get the current borrower
 	book, err := lm.db.GetBook(bookID)
 	if err != nil {
@@ -133,7 +234,7 @@ func (lm *LibraryManager) ReturnBookWithDetails(bookID, memberID int64) (returne
 	}
 
 	// Perform the return
-	returnedBy, err := lm.db.ReturnBook(bookID)
+	returnedBy, err := lm.db.returnItem(bookID, missingAccessories, actingID)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -145,7 +246,7 @@ func (lm *LibraryManager) ReturnBookWithDetails(bookID, memberID int64) (returne
 	}
 
 	if !bookAfter.Available && len(reservations) > 0 {
-		// Book was assigned to next person in queue
+		// Book is on hold for the next person in the queue
 		return returnedBy, bookAfter.BorrowerID, nil
 	}
 
@@ -156,7 +257,6 @@ func (lm *LibraryManager) ReturnBookWithDetails(bookID, memberID int64) (returne
 // ------------------ Legacy no-ops ------------------
 
 func (lm *LibraryManager) SaveData(string) error { return nil }
-func (lm *LibraryManager) LoadData(string) error { return nil }
 
 // ------------------ Utilities ------------------
 
@@ -185,23 +285,36 @@ func (lm *LibraryManager) UpdateBookContentFromFile(id int64, path string) error
 // ReadBook allows a member to read a book with pagination and proper authorization
 // Only allows reading if the book is already checked out to the member.
 func (lm *LibraryManager) ReadBook(bookID, memberID int64) error {
+	validation, err := lm.authorizeRead(bookID, memberID)
+	if err != nil {
+		return err
+	}
+
+	// Start the reading interface with efficient pagination
+	return lm.startReadingInterface(bookID, memberID, validation.BookTitle, validation.BookAuthor,
+		validation.MemberName, validation.BookContentLength)
+}
+
+// authorizeRead checks that memberID may read bookID right now, taking a
+// digital loan for licensed e-content, and returns the validated details.
+func (lm *LibraryManager) authorizeRead(bookID, memberID int64) (*ReadBookValidation, error) {
 	// Single optimized query for all validation
 	validation, err := lm.db.ValidateReadBookAccess(bookID, memberID)
 	if err != nil {
-		return fmt.Errorf("database error: %w", err)
+		return nil, fmt.Errorf("database error: %w", err)
 	}
 
 	// Check validation results with improved error messages
 	if !validation.BookExists {
-		return fmt.Errorf("book not found")
+		return nil, fmt.Errorf("book not found")
 	}
 
 	if !validation.MemberExists {
-		return fmt.Errorf("member not found")
+		return nil, fmt.Errorf("member not found")
 	}
 
 	if !validation.HasContent {
-		return fmt.Errorf("book has no content to read")
+		return nil, fmt.Errorf("book has no content to read")
 	}
 
 	// Additional validation: check for whitespace-only content using Go's more robust trimming
@@ -210,57 +323,93 @@ func (lm *LibraryManager) ReadBook(bookID, memberID int64) error {
 		// Get a small sample of content to check if it's all whitespace
 		sampleContent, err := lm.db.GetBookContentChunk(bookID, 0, 1000) // Check first 1000 chars
 		if err != nil {
-			return fmt.Errorf("failed to validate content: %w", err)
+			return nil, fmt.Errorf("failed to validate content: %w", err)
 		}
 		if strings.TrimSpace(sampleContent) == "" {
-			return fmt.Errorf("book has no content to read")
+			return nil, fmt.Errorf("book has no content to read")
+		}
+	}
+
+	// Licensed e-content is read on a digital loan, leaving the physical
+	// copy to circulate normally
+	holdsCopy := !validation.BookAvailable && validation.BookBorrowerID == memberID
+	if validation.DigitalLicenses > 0 && !holdsCopy {
+		if _, err := lm.db.AcquireDigitalLoan(bookID, memberID); err != nil {
+			return nil, err
 		}
+		return validation, nil
 	}
 
 	// Check if member can read the book (must already have it checked out)
 	if !validation.CanRead {
 		if validation.BookAvailable {
-			return fmt.Errorf("book is available but not checked out to you. Please check out the book first to read it")
+			return nil, fmt.Errorf("book is available but not checked out to you. Please check out the book first to read it")
 		} else {
 			// Book is checked out by someone else - don't expose borrower information
-			return fmt.Errorf("book is currently checked out by another member")
+			return nil, fmt.Errorf("book is currently checked out by another member")
 		}
 	}
 
-	// Start the reading interface with efficient pagination
-	return lm.startReadingInterface(bookID, validation.BookTitle, validation.BookAuthor,
-		validation.MemberName, validation.BookContentLength)
+	return validation, nil
 }
 
 // startReadingInterface provides a paginated reading experience with lazy loading
-func (lm *LibraryManager) startReadingInterface(bookID int64, title, author, memberName string, totalLength int) error {
-	const pageSize = 1500
-
+func (lm *LibraryManager) startReadingInterface(bookID, memberID int64, title, author, memberName string, totalLength int) error {
 	// Calculate total pages
-	totalPages := (totalLength + pageSize - 1) / pageSize
+	totalPages := (totalLength + PageSize - 1) / PageSize
 	if totalPages == 0 {
 		return fmt.Errorf("book has no content to display")
 	}
 
 	currentPage := 0
-	scanner := bufio.NewScanner(os.Stdin)
+	scanner := lm.input
+	if scanner == nil {
+		scanner = bufio.NewScanner(os.Stdin)
+	}
+
+	// Resume where the member left off, unless the book has since shrunk
+	savedPage, err := lm.db.GetReadingProgress(memberID, bookID)
+	if err != nil {
+		return fmt.Errorf("failed to load reading progress: %w", err)
+	}
+	if savedPage > 1 && savedPage <= totalPages {
+		currentPage = savedPage - 1
+	}
+	bookmarks, err := lm.db.GetBookmarks(memberID, bookID)
+	if err != nil {
+		return fmt.Errorf("failed to load bookmarks: %w", err)
+	}
 
 	// Clear screen and show initial page
 	fmt.Print("\033[2J\033[H") // Clear screen and move cursor to top
+	if currentPage > 0 {
+		fmt.Printf("📖 Resuming where you left off, on page %d.\n", currentPage+1)
+	}
 
 	for {
 		// Lazy load current page content
-		offset := currentPage * pageSize
-		pageContent, err := lm.db.GetBookContentChunk(bookID, offset, pageSize)
+		pageContent, err := lm.db.GetBookPage(bookID, currentPage+1)
 		if err != nil {
 			return fmt.Errorf("failed to load page content: %w", err)
 		}
+		if currentPage+1 != savedPage {
+			// Losing the place is no reason to stop reading
+			if err := lm.db.SaveReadingProgress(memberID, bookID, currentPage+1); err == nil {
+				savedPage = currentPage + 1
+			}
+		}
 
 		// Display header
 		fmt.Printf("═══════════════════════════════════════════════════════════════════════════════\n")
 		fmt.Printf("📖 %s by %s\n", title, author)
-		fmt.Printf("Reader: %s | Page %d of %d\n", memberName, currentPage+1, totalPages)
-		fmt.Printf("═══════════════════════════════════════════════════════════════════════════════\n\n")
+		fmt.Printf("Reader: %s | Page %d of %d", memberName, currentPage+1, totalPages)
+		if mark := bookmarkOn(bookmarks, currentPage+1); mark != nil {
+			fmt.Print(" | 🔖 Bookmarked")
+			if mark.Label != "" {
+				fmt.Printf(": %s", mark.Label)
+			}
+		}
+		fmt.Printf("\n═══════════════════════════════════════════════════════════════════════════════\n\n")
 
 		// Display current page content
 		fmt.Println(pageContent)
@@ -268,10 +417,11 @@ func (lm *LibraryManager) startReadingInterface(bookID int64, title, author, mem
 		// Display navigation footer (only show navigation for multi-page books)
 		fmt.Printf("\n═══════════════════════════════════════════════════════════════════════════════\n")
 		if totalPages == 1 {
-			fmt.Printf("📖 End of book. Press [q] to quit.")
+			fmt.Printf("📖 End of book. [/] search | [q]uit\n")
 		} else {
-			fmt.Printf("📖 Navigation: [n]ext | [p]revious | [g]oto page | [q]uit")
+			fmt.Printf("📖 Navigation: [n]ext | [p]revious | [g]oto page | [/] search | [q]uit\n")
 		}
+		fmt.Printf("📝 Marks: [a]dd note | [h]ighlight | [b]ookmark | [l]ist bookmarks | [u]nbookmark")
 		fmt.Printf("\n═══════════════════════════════════════════════════════════════════════════════\n")
 		fmt.Print("Command: ")
 
@@ -282,6 +432,12 @@ func (lm *LibraryManager) startReadingInterface(bookID int64, title, author, mem
 		input := strings.ToLower(strings.TrimSpace(scanner.Text()))
 		fmt.Print("\033[2J\033[H") // Clear screen
 
+		if strings.HasPrefix(input, "/") {
+			currentPage = lm.searchInReader(scanner, bookID, currentPage, totalPages, input[1:])
+			fmt.Print("\033[2J\033[H")
+			continue
+		}
+
 		switch input {
 		case "n", "next":
 			if totalPages == 1 {
@@ -337,6 +493,61 @@ func (lm *LibraryManager) startReadingInterface(bookID int64, title, author, mem
 				}
 				fmt.Print("\033[2J\033[H")
 			}
+		case "a", "note", "h", "highlight":
+			kind, prompt := AnnotationNote, "Note on this page: "
+			if input == "h" || input == "highlight" {
+				kind, prompt = AnnotationHighlight, "Passage to highlight (copy it from the page): "
+			}
+			fmt.Print(prompt)
+			if scanner.Scan() {
+				text := strings.TrimSpace(scanner.Text())
+				switch {
+				case text == "":
+				case kind == AnnotationHighlight && !strings.Contains(strings.ToLower(pageContent), strings.ToLower(text)):
+					fmt.Println("That passage isn't on this page.")
+				default:
+					if _, err := lm.db.AddAnnotation(memberID, bookID, currentPage+1, kind, text); err != nil {
+						fmt.Printf("Could not save %s: %v\n", kind, err)
+					} else {
+						fmt.Printf("📝 %s saved on page %d.\n", strings.ToUpper(kind[:1])+kind[1:], currentPage+1)
+					}
+				}
+				fmt.Println("Press Enter to continue...")
+				scanner.Scan()
+			}
+			fmt.Print("\033[2J\033[H")
+		case "b", "bookmark":
+			fmt.Printf("Label for the bookmark on page %d (optional): ", currentPage+1)
+			if scanner.Scan() {
+				if _, err := lm.db.AddBookmark(memberID, bookID, currentPage+1, scanner.Text()); err != nil {
+					fmt.Printf("Could not save bookmark: %v\n", err)
+				} else {
+					fmt.Printf("🔖 Bookmarked page %d.\n", currentPage+1)
+				}
+				if marks, err := lm.db.GetBookmarks(memberID, bookID); err == nil {
+					bookmarks = marks
+				}
+				fmt.Println("Press Enter to continue...")
+				scanner.Scan()
+			}
+			fmt.Print("\033[2J\033[H")
+		case "u", "unbookmark":
+			if removed, err := lm.db.DeleteBookmark(memberID, bookID, currentPage+1); err != nil {
+				fmt.Printf("Could not remove bookmark: %v\n", err)
+			} else if !removed {
+				fmt.Printf("Page %d isn't bookmarked.\n", currentPage+1)
+			} else {
+				fmt.Printf("🔖 Removed the bookmark on page %d.\n", currentPage+1)
+			}
+			if marks, err := lm.db.GetBookmarks(memberID, bookID); err == nil {
+				bookmarks = marks
+			}
+			fmt.Println("Press Enter to continue...")
+			scanner.Scan()
+			fmt.Print("\033[2J\033[H")
+		case "l", "bookmarks", "list bookmarks":
+			currentPage = listBookmarksInReader(scanner, bookmarks, currentPage, totalPages)
+			fmt.Print("\033[2J\033[H")
 		case "q", "quit", "exit":
 			fmt.Printf("📖 Finished reading '%s'.\n", title)
 			return nil
@@ -346,9 +557,9 @@ func (lm *LibraryManager) startReadingInterface(bookID int64, title, author, mem
 		default:
 			fmt.Printf("Unknown command: %s\n", input)
 			if totalPages == 1 {
-				fmt.Println("Use: [q]uit")
+				fmt.Println("Use: [a]dd note, [h]ighlight, [b]ookmark, [l]ist bookmarks, [u]nbookmark, /word to search, or [q]uit")
 			} else {
-				fmt.Println("Use: [n]ext, [p]revious, [g]oto, or [q]uit")
+				fmt.Println("Use: [n]ext, [p]revious, [g]oto, /word to search, [a]dd note, [h]ighlight, [b]ookmark, [l]ist bookmarks, [u]nbookmark, or [q]uit")
 			}
 			fmt.Println("Press Enter to continue...")
 			scanner.Scan()
@@ -358,3 +569,118 @@ func (lm *LibraryManager) startReadingInterface(bookID int64, title, author, mem
 
 	return nil
 }
+
+// bookmarkOn returns the bookmark on page (1-based), or nil.
+func bookmarkOn(bookmarks []*Bookmark, page int) *Bookmark {
+	for _, b := range bookmarks {
+		if b.Page == page {
+			return b
+		}
+	}
+	return nil
+}
+
+// listBookmarksInReader lists the bookmarks in the book being read and
+// returns the 0-based page to show next, a chosen bookmark or currentPage.
+func listBookmarksInReader(scanner *bufio.Scanner, bookmarks []*Bookmark, currentPage, totalPages int) int {
+	if len(bookmarks) == 0 {
+		fmt.Println("🔖 No bookmarks in this book yet; [b] bookmarks the page you are on.")
+		fmt.Println("Press Enter to continue...")
+		scanner.Scan()
+		return currentPage
+	}
+	fmt.Printf("🔖 Bookmarks (%d):\n\n", len(bookmarks))
+	for i, b := range bookmarks {
+		fmt.Printf("  %2d. Page %-5d %s\n", i+1, b.Page, b.Label)
+	}
+	fmt.Print("\nGo to bookmark number (Enter to stay): ")
+	if !scanner.Scan() {
+		return currentPage
+	}
+	answer := strings.TrimSpace(scanner.Text())
+	if answer == "" {
+		return currentPage
+	}
+	n, err := strconv.Atoi(answer)
+	if err != nil || n < 1 || n > len(bookmarks) {
+		fmt.Println("Invalid bookmark number!")
+		fmt.Println("Press Enter to continue...")
+		scanner.Scan()
+		return currentPage
+	}
+	// The book may have been shortened since
+	return min(bookmarks[n-1].Page, totalPages) - 1
+}
+
+// readerSearchListed caps the matching pages listed by a search in the
+// reader.
+const readerSearchListed = 15
+
+// searchInReader runs a / search in the reading interface: it lists the
+// pages where term occurs, asking for the term if it is blank, and returns
+// the 0-based page to show next, a chosen match or currentPage.
+func (lm *LibraryManager) searchInReader(scanner *bufio.Scanner, bookID int64, currentPage, totalPages int, term string) int {
+	if term = strings.TrimSpace(term); term == "" {
+		fmt.Print("Search this book for: ")
+		if !scanner.Scan() {
+			return currentPage
+		}
+		if term = strings.TrimSpace(scanner.Text()); term == "" {
+			return currentPage
+		}
+	}
+
+	matches, err := lm.db.FindInBook(bookID, term)
+	switch {
+	case err != nil:
+		fmt.Printf("Search failed: %v\n", err)
+	case len(matches) == 0:
+		fmt.Printf("🔍 '%s' isn't in this book.\n", term)
+	}
+	if err != nil || len(matches) == 0 {
+		fmt.Println("Press Enter to continue...")
+		scanner.Scan()
+		return currentPage
+	}
+
+	total := 0
+	for _, m := range matches {
+		total += m.Count
+	}
+	fmt.Printf("🔍 '%s' occurs %d time(s) on %d page(s):\n\n", term, total, len(matches))
+	for i, m := range matches {
+		if i == readerSearchListed {
+			fmt.Printf("  ... and %d more page(s)\n", len(matches)-i)
+			break
+		}
+		fmt.Printf("  Page %-5d %s\n", m.Page, strings.Join(strings.Fields(m.Excerpt), " "))
+	}
+
+	// Enter goes to the next match after this page, wrapping round
+	next := matches[0].Page
+	for _, m := range matches {
+		if m.Page > currentPage+1 {
+			next = m.Page
+			break
+		}
+	}
+	fmt.Printf("\nGo to page (Enter for page %d, 0 to stay): ", next)
+	if !scanner.Scan() {
+		return currentPage
+	}
+	answer := strings.TrimSpace(scanner.Text())
+	if answer == "" {
+		return next - 1
+	}
+	pageNum, err := strconv.Atoi(answer)
+	if err != nil || pageNum < 0 || pageNum > totalPages {
+		fmt.Println("Invalid page number!")
+		fmt.Println("Press Enter to continue...")
+		scanner.Scan()
+		return currentPage
+	}
+	if pageNum == 0 {
+		return currentPage
+	}
+	return pageNum - 1
+}
@@ -34,3 +34,132 @@ func TestAddBookFromFile(t *testing.T) {
 		t.Fatalf("content empty")
 	}
 }
+
+func TestCheckInBookRoutesHolds(t *testing.T) {
+	mgr := newManager(t)
+	bookID, _ := mgr.AddBook("Desk Book", "Author")
+	alice, _ := mgr.AddMember("Alice", "password")
+	bob, _ := mgr.AddMember("Bob", "password")
+
+	if _, err := mgr.CheckInBook(bookID); err == nil {
+		t.Fatalf("checking in an available book should fail")
+	}
+
+	mgr.CheckoutBook(bookID, alice)
+	mgr.ReserveBook(bookID, bob)
+
+	res, err := mgr.CheckInBook(bookID)
+	if err != nil {
+		t.Fatalf("check in: %v", err)
+	}
+	if res.ReturnedBy != alice || res.HoldFor != bob || res.HoldForName != "Bob" {
+		t.Fatalf("unexpected check-in result: %+v", res)
+	}
+
+	if _, err := mgr.CheckInBook(bookID); err == nil {
+		t.Fatalf("a hold waiting on the shelf should not check in")
+	}
+	if _, err := mgr.PickUpHold(bookID, bob); err != nil {
+		t.Fatalf("pickup: %v", err)
+	}
+	res, err = mgr.CheckInBook(bookID)
+	if err != nil {
+		t.Fatalf("second check in: %v", err)
+	}
+	if res.ReturnedBy != bob || res.HoldFor != 0 {
+		t.Fatalf("book should return to the shelf: %+v", res)
+	}
+}
+
+func TestAuthenticateAdmin(t *testing.T) {
+	mgr := newManager(t)
+	staff, _ := mgr.AddMember("Librarian", "password")
+
+	if err := mgr.AuthenticateAdmin(staff, "password"); err == nil {
+		t.Fatalf("regular member should not pass admin authentication")
+	}
+	if err := mgr.SetMemberAdmin(staff, true); err != nil {
+		t.Fatalf("grant admin: %v", err)
+	}
+	if err := mgr.AuthenticateAdmin(staff, "password"); err != nil {
+		t.Fatalf("admin authentication should succeed: %v", err)
+	}
+	if err := mgr.AuthenticateAdmin(staff, "wrong"); err == nil {
+		t.Fatalf("wrong password should fail")
+	}
+	if n, _ := mgr.CountAdmins(); n != 1 {
+		t.Fatalf("want 1 admin, got %d", n)
+	}
+}
+
+func TestMemberNotes(t *testing.T) {
+	mgr := newManager(t)
+	staff, _ := mgr.AddMember("Librarian", "password")
+	alice, _ := mgr.AddMember("Alice", "password")
+
+	if _, err := mgr.AddMemberNote(alice, staff, "Agreed to pay for water damage"); err == nil {
+		t.Fatalf("non-staff should not be able to add notes")
+	}
+	mgr.SetMemberAdmin(staff, true)
+
+	if _, err := mgr.AddMemberNote(alice, staff, "Agreed to pay for water damage"); err != nil {
+		t.Fatalf("add note: %v", err)
+	}
+	if _, err := mgr.AddMemberNote(alice, staff, "   "); err == nil {
+		t.Fatalf("empty note should be rejected")
+	}
+	if _, err := mgr.AddMemberNote(9999, staff, "ghost"); err == nil {
+		t.Fatalf("note on unknown member should be rejected")
+	}
+
+	notes, err := mgr.GetMemberNotes(alice)
+	if err != nil {
+		t.Fatalf("get notes: %v", err)
+	}
+	if len(notes) != 1 || notes[0].AuthorName != "Librarian" || notes[0].Note != "Agreed to pay for water damage" {
+		t.Fatalf("unexpected notes: %+v", notes)
+	}
+}
+
+func TestMemberAlertsAtCheckout(t *testing.T) {
+	mgr := newManager(t)
+	staff, _ := mgr.AddMember("Librarian", "password")
+	mgr.SetMemberAdmin(staff, true)
+	alice, _ := mgr.AddMember("Alice", "password")
+	book1, _ := mgr.AddBook("Book One", "Author")
+	book2, _ := mgr.AddBook("Book Two", "Author")
+
+	if _, err := mgr.AddMemberAlert(alice, staff, "Ask about new phone number", false); err != nil {
+		t.Fatalf("add advisory alert: %v", err)
+	}
+	// Advisory alerts do not block
+	if err := mgr.CheckoutBook(book1, alice); err != nil {
+		t.Fatalf("advisory alert should not block checkout: %v", err)
+	}
+
+	blockID, err := mgr.AddMemberAlert(alice, staff, "Verify new address", true)
+	if err != nil {
+		t.Fatalf("add blocking alert: %v", err)
+	}
+	if err := mgr.CheckoutBook(book2, alice); err == nil {
+		t.Fatalf("blocking alert should prevent checkout")
+	}
+	if err := mgr.ReserveBook(book2, alice); err == nil {
+		t.Fatalf("blocking alert should prevent immediate checkout via reserve")
+	}
+
+	alerts, _ := mgr.GetActiveAlerts(alice)
+	if len(alerts) != 2 || !alerts[0].Blocking {
+		t.Fatalf("expected blocking alert first, got %+v", alerts)
+	}
+
+	if err := mgr.ClearAlert(blockID, alice); err == nil {
+		t.Fatalf("members cannot clear their own alerts")
+	}
+	if err := mgr.ClearAlert(blockID, staff); err != nil {
+		t.Fatalf("clear alert: %v", err)
+	}
+	if err := mgr.CheckoutBook(book2, alice); err != nil {
+		t.Fatalf("checkout after clearing alert: %v", err)
+	}
+}
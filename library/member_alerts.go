@@ -0,0 +1,118 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MemberAlert is a flag on a member account that staff must see before a
+// checkout. Blocking alerts stop checkouts until cleared; advisory ones only
+// need acknowledging.
+type MemberAlert struct {
+	ID          int64
+	MemberID    int64
+	AuthorName  string
+	Message     string
+	Blocking    bool
+	CreatedTime time.Time
+}
+
+// AddMemberAlert flags memberID's account.
+func (d *Database) AddMemberAlert(memberID, authorID int64, message string, blocking bool) (int64, error) {
+	if strings.TrimSpace(message) == "" {
+		return 0, fmt.Errorf("alert message cannot be empty")
+	}
+	if _, err := d.GetMember(memberID); err != nil {
+		return 0, fmt.Errorf("member with ID %d not found", memberID)
+	}
+	res, err := d.db.Exec(`INSERT INTO member_alerts(member_id, author_id, message, blocking) VALUES(?,?,?,?)`,
+		memberID, authorID, message, blocking)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add alert: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// GetActiveAlerts returns memberID's uncleared alerts, blocking ones first.
+func (d *Database) GetActiveAlerts(memberID int64) ([]*MemberAlert, error) {
+	rows, err := d.db.Query(`SELECT a.id, a.member_id, COALESCE(s.name, ''), a.message, a.blocking, a.created_time
+                             FROM member_alerts a
+                             LEFT JOIN members s ON a.author_id = s.id
+                             WHERE a.member_id = ? AND a.cleared_time IS NULL
+                             ORDER BY a.blocking DESC, a.created_time, a.id`, memberID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []*MemberAlert
+	for rows.Next() {
+		var a MemberAlert
+		if err := rows.Scan(&a.ID, &a.MemberID, &a.AuthorName, &a.Message, &a.Blocking, &a.CreatedTime); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, &a)
+	}
+	return alerts, rows.Err()
+}
+
+// ClearAlert resolves an alert on behalf of staff member clearedBy.
+func (d *Database) ClearAlert(alertID, clearedBy int64) error {
+	res, err := d.db.Exec(`UPDATE member_alerts SET cleared_time=CURRENT_TIMESTAMP, cleared_by=?
+                           WHERE id=? AND cleared_time IS NULL`, clearedBy, alertID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("no active alert with ID %d", alertID)
+	}
+	return nil
+}
+
+// checkBlockingAlerts fails if memberID has an uncleared blocking alert,
+// reading through q, a database or transaction.
+func (d *Database) checkBlockingAlerts(q interface {
+	QueryRow(string, ...any) *sql.Row
+}, memberID int64) error {
+	var message string
+	err := q.QueryRow(`SELECT message FROM member_alerts
+                          WHERE member_id=? AND blocking=1 AND cleared_time IS NULL
+                          ORDER BY created_time LIMIT 1`, memberID).Scan(&message)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("account is blocked until staff clear the alert: %s", message)
+}
+
+// ------------------ Manager helpers ------------------
+
+// AddMemberAlert flags a member account. authorID must be a staff member.
+func (lm *LibraryManager) AddMemberAlert(memberID, authorID int64, message string, blocking bool) (int64, error) {
+	author, err := lm.db.GetMember(authorID)
+	if err != nil || !author.IsAdmin {
+		return 0, fmt.Errorf("staff privileges required")
+	}
+	return lm.db.AddMemberAlert(memberID, authorID, message, blocking)
+}
+
+func (lm *LibraryManager) GetActiveAlerts(memberID int64) ([]*MemberAlert, error) {
+	return lm.db.GetActiveAlerts(memberID)
+}
+
+// ClearAlert resolves an alert. clearedBy must be a staff member.
+func (lm *LibraryManager) ClearAlert(alertID, clearedBy int64) error {
+	staff, err := lm.db.GetMember(clearedBy)
+	if err != nil || !staff.IsAdmin {
+		return fmt.Errorf("staff privileges required")
+	}
+	return lm.db.ClearAlert(alertID, clearedBy)
+}
@@ -0,0 +1,73 @@
+package library
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MemberNote is a staff-only remark on a member record, e.g. a damaged-item
+// agreement. Notes are deliberately not part of Member so they can never leak
+// through member-facing output.
+type MemberNote struct {
+	ID          int64
+	MemberID    int64
+	AuthorID    int64
+	AuthorName  string
+	Note        string
+	CreatedTime time.Time
+}
+
+// AddMemberNote records a note about memberID written by staff member authorID.
+func (d *Database) AddMemberNote(memberID, authorID int64, note string) (int64, error) {
+	if strings.TrimSpace(note) == "" {
+		return 0, fmt.Errorf("note cannot be empty")
+	}
+	if _, err := d.GetMember(memberID); err != nil {
+		return 0, fmt.Errorf("member with ID %d not found", memberID)
+	}
+	res, err := d.db.Exec(`INSERT INTO member_notes(member_id, author_id, note) VALUES(?,?,?)`, memberID, authorID, note)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add note: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// GetMemberNotes returns all notes on memberID, oldest first.
+func (d *Database) GetMemberNotes(memberID int64) ([]*MemberNote, error) {
+	rows, err := d.db.Query(`SELECT n.id, n.member_id, n.author_id, COALESCE(a.name, ''), n.note, n.created_time
+                             FROM member_notes n
+                             LEFT JOIN members a ON n.author_id = a.id
+                             WHERE n.member_id = ?
+                             ORDER BY n.created_time, n.id`, memberID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []*MemberNote
+	for rows.Next() {
+		var n MemberNote
+		if err := rows.Scan(&n.ID, &n.MemberID, &n.AuthorID, &n.AuthorName, &n.Note, &n.CreatedTime); err != nil {
+			return nil, err
+		}
+		notes = append(notes, &n)
+	}
+	return notes, rows.Err()
+}
+
+// ------------------ Manager helpers ------------------
+
+// AddMemberNote attaches a staff note to a member. authorID must be a staff
+// member; callers are expected to have authenticated them already.
+func (lm *LibraryManager) AddMemberNote(memberID, authorID int64, note string) (int64, error) {
+	author, err := lm.db.GetMember(authorID)
+	if err != nil || !author.IsAdmin {
+		return 0, fmt.Errorf("staff privileges required")
+	}
+	return lm.db.AddMemberNote(memberID, authorID, note)
+}
+
+func (lm *LibraryManager) GetMemberNotes(memberID int64) ([]*MemberNote, error) {
+	return lm.db.GetMemberNotes(memberID)
+}
@@ -0,0 +1,188 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// DeactivateMember closes a departed member's account without erasing it.
+// Their waiting reservations are cancelled, their digital loans returned
+// and their API tokens revoked, and they can no longer sign in, borrow or
+// reserve; loan history and charges
+// stay with the account. It is refused while they have items on loan
+// (including holds waiting for pickup), and for the last active staff
+// account. It returns the number of reservations cancelled.
+func (d *Database) DeactivateMember(memberID, actorID int64) (int, error) {
+	return inTxResult(d, func(tx *sql.Tx) (int, error) {
+		active, isAdmin, err := memberStatus(tx, memberID)
+		if err != nil {
+			return 0, err
+		}
+		if !active {
+			return 0, fmt.Errorf("member %d is already deactivated", memberID)
+		}
+		if err := checkNothingOnLoan(tx, memberID); err != nil {
+			return 0, err
+		}
+		if isAdmin {
+			var others int
+			if err := tx.QueryRow(`SELECT COUNT(*) FROM members WHERE is_admin=1 AND active=1 AND id<>?`, memberID).Scan(&others); err != nil {
+				return 0, err
+			}
+			if others == 0 {
+				return 0, fmt.Errorf("member %d is the last active staff account", memberID)
+			}
+		}
+
+		var reserved []int64
+		rows, err := tx.Query(`SELECT book_id FROM reservations WHERE member_id=? AND fulfilled_time IS NULL`, memberID)
+		if err != nil {
+			return 0, err
+		}
+		for rows.Next() {
+			var bookID int64
+			if err := rows.Scan(&bookID); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			reserved = append(reserved, bookID)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		queueMoved, err := d.watchQueues(tx, reserved...)
+		if err != nil {
+			return 0, err
+		}
+		res, err := tx.Exec(`DELETE FROM reservations WHERE member_id=? AND fulfilled_time IS NULL`, memberID)
+		if err != nil {
+			return 0, err
+		}
+		cancelled, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if err := queueMoved(); err != nil {
+			return 0, err
+		}
+		res, err = tx.Exec(`UPDATE digital_loans SET return_time=? WHERE member_id=? AND return_time IS NULL AND expiry_time > ?`,
+			d.sqlNow(), memberID, d.sqlNow())
+		if err != nil {
+			return 0, err
+		}
+		returned, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`UPDATE api_tokens SET revoked_time=CURRENT_TIMESTAMP WHERE member_id=? AND revoked_time IS NULL`, memberID); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`UPDATE members SET active=0 WHERE id=?`, memberID); err != nil {
+			return 0, err
+		}
+		detail := fmt.Sprintf("member %d deactivated, %d reservation(s) cancelled", memberID, cancelled)
+		if returned > 0 {
+			detail += fmt.Sprintf(", %d digital loan(s) returned", returned)
+		}
+		if err := recordAuditOf(tx, actorID, AuditDeactivateMember, memberID, 0, detail); err != nil {
+			return 0, err
+		}
+		return int(cancelled), nil
+	})
+}
+
+// ReactivateMember reopens a deactivated account. Cancelled reservations
+// are not restored.
+func (d *Database) ReactivateMember(memberID, actorID int64) error {
+	return d.inTx(func(tx *sql.Tx) error {
+		active, _, err := memberStatus(tx, memberID)
+		if err != nil {
+			return err
+		}
+		if active {
+			return fmt.Errorf("member %d is already active", memberID)
+		}
+		if _, err := tx.Exec(`UPDATE members SET active=1 WHERE id=?`, memberID); err != nil {
+			return err
+		}
+		return recordAuditOf(tx, actorID, AuditReactivateMember, memberID, 0, fmt.Sprintf("member %d reactivated", memberID))
+	})
+}
+
+// DeleteMember removes a member who never borrowed anything, such as an
+// account opened by mistake, along with their holds, inbox, profile and
+// other records of their own. A member with loan history (digital loans
+// included), charges, or notes
+// and alerts written as staff is refused: deactivate them to keep that
+// history, or erase them with ForgetMember.
+func (d *Database) DeleteMember(memberID, actorID int64) error {
+	return d.inTx(func(tx *sql.Tx) error {
+		if _, _, err := memberStatus(tx, memberID); err != nil {
+			return err
+		}
+		if err := checkNothingOnLoan(tx, memberID); err != nil {
+			return err
+		}
+		var history int
+		err := tx.QueryRow(`SELECT (SELECT COUNT(*) FROM checkouts WHERE member_id=?1 OR picked_up_by=?1 OR returned_by=?1)
+		                         + (SELECT COUNT(*) FROM digital_loans WHERE member_id=?1)
+		                         + (SELECT COUNT(*) FROM fines WHERE member_id=?1)
+		                         + (SELECT COUNT(*) FROM member_notes WHERE author_id=?1)
+		                         + (SELECT COUNT(*) FROM member_alerts WHERE author_id=?1 OR cleared_by=?1)`, memberID).Scan(&history)
+		if err != nil {
+			return err
+		}
+		if history > 0 {
+			return fmt.Errorf("member %d has loan history or charges; deactivate or erase them instead", memberID)
+		}
+
+		for _, r := range memberOwnedRows {
+			if _, err := tx.Exec(r.query, memberID); err != nil {
+				return fmt.Errorf("delete %s: %w", r.key, err)
+			}
+		}
+		if _, err := tx.Exec(`DELETE FROM members WHERE id=?`, memberID); err != nil {
+			return err
+		}
+		return recordAuditOf(tx, actorID, AuditDeleteMember, memberID, 0, fmt.Sprintf("member %d deleted", memberID))
+	})
+}
+
+// memberStatus reports whether memberID is active and has staff rights. The
+// forgotten-member placeholder is treated as missing.
+func memberStatus(tx *sql.Tx, memberID int64) (active, isAdmin bool, err error) {
+	var placeholder bool
+	err = tx.QueryRow(`SELECT active, is_admin, placeholder FROM members WHERE id=?`, memberID).Scan(&active, &isAdmin, &placeholder)
+	if err == sql.ErrNoRows || placeholder {
+		return false, false, fmt.Errorf("member with ID %d not found", memberID)
+	}
+	return active, isAdmin, err
+}
+
+// checkNothingOnLoan fails while memberID has items out, including holds
+// waiting for pickup and disputed loans.
+func checkNothingOnLoan(tx *sql.Tx, memberID int64) error {
+	var onLoan int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM checkouts WHERE member_id=? AND return_time IS NULL`, memberID).Scan(&onLoan); err != nil {
+		return err
+	}
+	if onLoan > 0 {
+		return fmt.Errorf("member still has %d item(s) on loan; check them in first", onLoan)
+	}
+	return nil
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) DeactivateMember(memberID, actorID int64) (int, error) {
+	return lm.db.DeactivateMember(memberID, actorID)
+}
+
+func (lm *LibraryManager) ReactivateMember(memberID, actorID int64) error {
+	return lm.db.ReactivateMember(memberID, actorID)
+}
+
+func (lm *LibraryManager) DeleteMember(memberID, actorID int64) error {
+	return lm.db.DeleteMember(memberID, actorID)
+}
@@ -0,0 +1,118 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeactivateMember(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	staff, _ := db.AddMember("Staff", "password1")
+	db.SetMemberAdmin(staff, true)
+	alice, _ := db.AddMember("Alice", "password1")
+	bob, _ := db.AddMember("Bob", "password1")
+	b1, _ := db.AddBook("Borrowed", "Author", "")
+	b2, _ := db.AddBook("Wanted", "Author", "")
+	ebook, _ := db.AddBook("E-Book", "Author", "content")
+	db.SetDigitalLicenses(ebook, 1)
+
+	db.CheckoutBook(b1, alice)
+	db.CheckoutBook(b2, bob)
+	lm.ReserveBook(b2, alice)
+	db.AcquireDigitalLoan(ebook, alice)
+	db.IssueToken(alice, "")
+
+	if _, err := db.DeactivateMember(alice, staff); err == nil || !strings.Contains(err.Error(), "on loan") {
+		t.Fatalf("expected open loans to block deactivation, got %v", err)
+	}
+	if _, err := lm.ReturnBook(b1, alice); err != nil {
+		t.Fatalf("return: %v", err)
+	}
+
+	cancelled, err := db.DeactivateMember(alice, staff)
+	if err != nil || cancelled != 1 {
+		t.Fatalf("deactivate: %d %v", cancelled, err)
+	}
+	if m, _ := db.GetMember(alice); m.Active {
+		t.Fatalf("Alice should be inactive")
+	}
+	if err := db.AuthenticateMember(alice, "password1"); err == nil {
+		t.Fatalf("a deactivated member should not sign in")
+	}
+	if err := db.CheckoutBook(b1, alice); err == nil {
+		t.Fatalf("a deactivated member should not borrow")
+	}
+	if err := db.ReserveBook(b2, alice); err == nil {
+		t.Fatalf("a deactivated member should not reserve")
+	}
+	// Alice's digital loan was returned, freeing its license
+	if loans, _ := db.GetDigitalLoans(alice); len(loans) != 0 {
+		t.Fatalf("%d digital loan(s) still out", len(loans))
+	}
+	if _, err := db.AcquireDigitalLoan(ebook, alice); err == nil {
+		t.Fatalf("a deactivated member should not borrow digitally")
+	}
+	if _, err := db.AcquireDigitalLoan(ebook, bob); err != nil {
+		t.Fatalf("the license should be free again: %v", err)
+	}
+	var live int
+	db.db.QueryRow(`SELECT COUNT(*) FROM api_tokens WHERE member_id=? AND revoked_time IS NULL`, alice).Scan(&live)
+	if live != 0 {
+		t.Fatalf("%d token(s) still live", live)
+	}
+	if _, err := db.DeactivateMember(alice, staff); err == nil {
+		t.Fatalf("expected an error deactivating twice")
+	}
+	if _, err := db.DeactivateMember(staff, staff); err == nil || !strings.Contains(err.Error(), "last active staff") {
+		t.Fatalf("expected the last staff account to be kept, got %v", err)
+	}
+
+	if err := db.ReactivateMember(alice, staff); err != nil {
+		t.Fatalf("reactivate: %v", err)
+	}
+	if err := db.AuthenticateMember(alice, "password1"); err != nil {
+		t.Fatalf("sign in after reactivation: %v", err)
+	}
+}
+
+func TestDeleteMember(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	staff, _ := db.AddMember("Staff", "password1")
+	alice, _ := db.AddMember("Alice", "password1")
+	mistake, _ := db.AddMember("Typo", "password1")
+	reader, _ := db.AddMember("Reader", "password1")
+	bookID, _ := db.AddBook("Book", "Author", "")
+	other, _ := db.AddBook("Other", "Author", "")
+	ebook, _ := db.AddBook("E-Book", "Author", "content")
+	db.SetDigitalLicenses(ebook, 1)
+	db.AcquireDigitalLoan(ebook, reader)
+
+	db.CheckoutBook(bookID, alice)
+	db.CheckoutBook(other, staff)
+	lm.ReserveBook(other, mistake)
+	db.AddMemberNote(mistake, staff, "Opened twice")
+
+	if err := db.DeleteMember(alice, staff); err == nil || !strings.Contains(err.Error(), "on loan") {
+		t.Fatalf("expected open loans to block deletion, got %v", err)
+	}
+	lm.ReturnBook(bookID, alice)
+	if err := db.DeleteMember(alice, staff); err == nil || !strings.Contains(err.Error(), "history") {
+		t.Fatalf("expected loan history to block deletion, got %v", err)
+	}
+
+	if err := db.DeleteMember(reader, staff); err == nil || !strings.Contains(err.Error(), "history") {
+		t.Fatalf("expected a digital loan to block deletion, got %v", err)
+	}
+
+	if err := db.DeleteMember(mistake, staff); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := db.GetMember(mistake); err == nil {
+		t.Fatalf("the account should be gone")
+	}
+	if err := db.DeleteMember(mistake, staff); err == nil {
+		t.Fatalf("expected an error deleting a missing member")
+	}
+}
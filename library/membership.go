@@ -0,0 +1,159 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DefaultMembershipDays is the term granted at registration and renewal.
+const DefaultMembershipDays = 365
+
+// NoticeMembershipExpiring is sent once per term ahead of expiry.
+const NoticeMembershipExpiring = "membership_expiring"
+
+// checkMembershipActive fails if memberID's membership has lapsed or the
+// account is deactivated.
+func (d *Database) checkMembershipActive(tx *sql.Tx, memberID int64) error {
+	var active, expired bool
+	err := tx.QueryRow(`SELECT active, expiry_time IS NOT NULL AND expiry_time <= ? FROM members WHERE id=?`, d.sqlNow(), memberID).
+		Scan(&active, &expired)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("member not found")
+	}
+	if err != nil {
+		return err
+	}
+	if !active {
+		return fmt.Errorf("member account is deactivated")
+	}
+	if expired {
+		return fmt.Errorf("membership has expired; please renew it at the desk")
+	}
+	return nil
+}
+
+// RenewMembership extends memberID's membership by days, counted from the
+// current expiry date or from today if it has already lapsed.
+func (d *Database) RenewMembership(memberID int64, days int) (time.Time, error) {
+	if days <= 0 {
+		return time.Time{}, fmt.Errorf("membership term must be at least one day")
+	}
+	term := fmt.Sprintf("+%d days", days)
+	now := d.sqlNow()
+	res, err := d.db.Exec(`UPDATE members
+                           SET expiry_time = datetime(MAX(COALESCE(expiry_time, ?), ?), ?),
+                               expiry_notified = 0
+                           WHERE id=?`, now, now, term, memberID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if n == 0 {
+		return time.Time{}, fmt.Errorf("member with ID %d not found", memberID)
+	}
+
+	m, err := d.GetMember(memberID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return *m.ExpiryTime, nil
+}
+
+// GetExpiringMembers lists members whose membership lapses within the given
+// window, soonest first. Already-expired members are included.
+func (d *Database) GetExpiringMembers(within time.Duration) ([]*Member, error) {
+	rows, err := d.db.Query(`SELECT id, name, expiry_time FROM members
+                             WHERE active = 1 AND expiry_time IS NOT NULL AND expiry_time <= datetime(?, ?)
+                             ORDER BY expiry_time`, d.sqlNow(), fmt.Sprintf("+%d seconds", int64(within.Seconds())))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []*Member
+	for rows.Next() {
+		var m Member
+		var expiry time.Time
+		if err := rows.Scan(&m.ID, &m.Name, &expiry); err != nil {
+			return nil, err
+		}
+		m.ExpiryTime = &expiry
+		members = append(members, &m)
+	}
+	return members, rows.Err()
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) RenewMembership(memberID int64, days int) (time.Time, error) {
+	return lm.db.RenewMembership(memberID, days)
+}
+
+func (lm *LibraryManager) GetExpiringMembers(within time.Duration) ([]*Member, error) {
+	return lm.db.GetExpiringMembers(within)
+}
+
+// SendExpiryNotices notifies members whose membership expires within window,
+// once per term, so staff can follow up before cards lapse.
+func (lm *LibraryManager) SendExpiryNotices(n Notifier, window time.Duration) (int, error) {
+	rows, err := lm.db.db.Query(`SELECT id, name, expiry_time FROM members
+                                 WHERE expiry_notified = 0 AND active = 1 AND expiry_time IS NOT NULL
+                                   AND expiry_time > ? AND expiry_time <= datetime(?, ?)`,
+		lm.db.sqlNow(), lm.db.sqlNow(), fmt.Sprintf("+%d seconds", int64(window.Seconds())))
+	if err != nil {
+		return 0, err
+	}
+	type expiring struct {
+		id     int64
+		name   string
+		expiry time.Time
+	}
+	var due []expiring
+	for rows.Next() {
+		var e expiring
+		if err := rows.Scan(&e.id, &e.name, &e.expiry); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, e := range due {
+		notice := Notice{
+			MemberID:   e.id,
+			MemberName: e.name,
+			Kind:       NoticeMembershipExpiring,
+			Subject:    "Your library membership is expiring",
+			Body:       fmt.Sprintf("Your membership expires on %s. Renew at the desk to keep borrowing.", lm.db.localTime(e.expiry).Format("2006-01-02")),
+		}
+		if err := n.Notify(notice); err != nil {
+			return sent, fmt.Errorf("notify member %d: %w", e.id, err)
+		}
+		if _, err := lm.db.db.Exec(`UPDATE members SET expiry_notified = 1 WHERE id=?`, e.id); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// ExpiryNoticeJob wraps SendExpiryNotices as a Job for the JobRunner.
+func (lm *LibraryManager) ExpiryNoticeJob(n Notifier, window, interval time.Duration) *Job {
+	return &Job{
+		Name:     "membership-expiry",
+		Interval: interval,
+		Run: func() error {
+			_, err := lm.SendExpiryNotices(n, window)
+			return err
+		},
+	}
+}
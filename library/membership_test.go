@@ -0,0 +1,63 @@
+package library
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiredMemberCannotCheckOut(t *testing.T) {
+	mgr := newManager(t)
+	bookID, _ := mgr.AddBook("Book", "Author")
+	alice, _ := mgr.AddMember("Alice", "password")
+
+	m, _ := mgr.GetMember(alice)
+	if m.ExpiryTime == nil || m.Expired(time.Now()) {
+		t.Fatalf("new member should have an unexpired membership")
+	}
+
+	mgr.db.db.Exec(`UPDATE members SET expiry_time = datetime('now', '-1 day') WHERE id=?`, alice)
+	if err := mgr.CheckoutBook(bookID, alice); err == nil {
+		t.Fatalf("expired member should not be able to check out")
+	}
+	if err := mgr.ReserveBook(bookID, alice); err == nil {
+		t.Fatalf("expired member should not get an immediate checkout via reserve")
+	}
+
+	expiry, err := mgr.RenewMembership(alice, 30)
+	if err != nil {
+		t.Fatalf("renew: %v", err)
+	}
+	if d := time.Until(expiry); d < 29*24*time.Hour || d > 31*24*time.Hour {
+		t.Fatalf("renewal should run 30 days from today, got %v", expiry)
+	}
+	if err := mgr.CheckoutBook(bookID, alice); err != nil {
+		t.Fatalf("checkout after renewal: %v", err)
+	}
+}
+
+func TestExpiryReportAndNotices(t *testing.T) {
+	mgr := newManager(t)
+	alice, _ := mgr.AddMember("Alice", "password")
+	mgr.AddMember("Bob", "password")
+	mgr.db.db.Exec(`UPDATE members SET expiry_time = datetime('now', '+10 days') WHERE id=?`, alice)
+
+	expiring, err := mgr.GetExpiringMembers(30 * 24 * time.Hour)
+	if err != nil || len(expiring) != 1 || expiring[0].ID != alice {
+		t.Fatalf("expected only Alice in the expiry report, got %v (err=%v)", expiring, err)
+	}
+
+	n := &recordingNotifier{}
+	if sent, err := mgr.SendExpiryNotices(n, 30*24*time.Hour); err != nil || sent != 1 {
+		t.Fatalf("expiry notices sent=%d err=%v", sent, err)
+	}
+	if sent, _ := mgr.SendExpiryNotices(n, 30*24*time.Hour); sent != 0 {
+		t.Fatalf("expiry notice should be sent once per term")
+	}
+
+	// Renewing starts a new term and re-arms the notice
+	mgr.RenewMembership(alice, 1)
+	mgr.db.db.Exec(`UPDATE members SET expiry_time = datetime('now', '+5 days') WHERE id=?`, alice)
+	if sent, _ := mgr.SendExpiryNotices(n, 30*24*time.Hour); sent != 1 {
+		t.Fatalf("renewed member should be notified again before the next expiry")
+	}
+}
@@ -0,0 +1,225 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// bookColumns selects a whole book record from books aliased as b, in the
+// order scanBook reads them.
+const bookColumns = `b.id, b.title, b.author, COALESCE((SELECT content FROM book_contents WHERE book_id = b.id), ''), b.available, COALESCE(b.borrower_id,0), b.non_circulating, b.item_type, b.updated_time,
+                     b.isbn, b.publisher, b.publication_year, b.language, b.page_count`
+
+// bookListColumns is bookColumns with an empty content, for listings.
+const bookListColumns = `b.id, b.title, b.author, '', b.available, COALESCE(b.borrower_id,0), b.non_circulating, b.item_type, b.updated_time,
+                         b.isbn, b.publisher, b.publication_year, b.language, b.page_count`
+
+func scanBook(row interface{ Scan(...any) error }) (*Book, error) {
+	var b Book
+	if err := row.Scan(bookFields(&b)...); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// bookFields are the scan destinations for bookColumns, so queries can
+// select more after them.
+func bookFields(b *Book) []any {
+	return []any{&b.ID, &b.Title, &b.Author, &b.Content, &b.Available, &b.BorrowerID, &b.NonCirculating, &b.ItemType, &b.UpdatedTime,
+		&b.ISBN, &b.Publisher, &b.PublicationYear, &b.Language, &b.PageCount}
+}
+
+func scanBooks(rows *sql.Rows) ([]*Book, error) {
+	defer rows.Close()
+	var books []*Book
+	for rows.Next() {
+		b, err := scanBook(rows)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, b)
+	}
+	return books, rows.Err()
+}
+
+// NormalizeISBN strips hyphens and spaces from an ISBN-10 or ISBN-13 and
+// checks its check digit.
+func NormalizeISBN(isbn string) (string, error) {
+	n := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(isbn))
+	switch len(n) {
+	case 10:
+		sum := 0
+		for i, c := range n {
+			var v int
+			switch {
+			case c >= '0' && c <= '9':
+				v = int(c - '0')
+			case c == 'X' && i == 9:
+				v = 10
+			default:
+				return "", fmt.Errorf("invalid ISBN %q", isbn)
+			}
+			sum += (10 - i) * v
+		}
+		if sum%11 != 0 {
+			return "", fmt.Errorf("invalid ISBN %q: check digit does not match", isbn)
+		}
+	case 13:
+		sum := 0
+		for i, c := range n {
+			if c < '0' || c > '9' {
+				return "", fmt.Errorf("invalid ISBN %q", isbn)
+			}
+			w := 1
+			if i%2 == 1 {
+				w = 3
+			}
+			sum += w * int(c-'0')
+		}
+		if sum%10 != 0 {
+			return "", fmt.Errorf("invalid ISBN %q: check digit does not match", isbn)
+		}
+	default:
+		return "", fmt.Errorf("invalid ISBN %q: expected 10 or 13 digits", isbn)
+	}
+	return n, nil
+}
+
+// normalize validates m and returns it with the ISBN normalized and text
+// fields trimmed.
+func (m BookMetadata) normalize() (BookMetadata, error) {
+	m.ISBN = strings.TrimSpace(m.ISBN)
+	if m.ISBN != "" {
+		isbn, err := NormalizeISBN(m.ISBN)
+		if err != nil {
+			return m, err
+		}
+		m.ISBN = isbn
+	}
+	m.Publisher = strings.TrimSpace(m.Publisher)
+	m.Language = strings.TrimSpace(m.Language)
+	if m.PublicationYear < 0 || m.PublicationYear > time.Now().Year()+1 {
+		return m, fmt.Errorf("invalid publication year %d", m.PublicationYear)
+	}
+	if m.PageCount < 0 {
+		return m, fmt.Errorf("page count cannot be negative")
+	}
+	return m, nil
+}
+
+// AddBookWithMetadata adds a book along with its publication details.
+func (d *Database) AddBookWithMetadata(title, author, content string, meta BookMetadata) (int64, error) {
+	meta, err := meta.normalize()
+	if err != nil {
+		return 0, err
+	}
+	id, err := d.AddBook(title, author, content)
+	if err != nil {
+		return 0, err
+	}
+	return id, d.SetBookMetadata(id, meta)
+}
+
+// SetBookMetadata replaces a book's publication details.
+func (d *Database) SetBookMetadata(bookID int64, meta BookMetadata) error {
+	meta, err := meta.normalize()
+	if err != nil {
+		return err
+	}
+	res, err := d.db.Exec(`UPDATE books SET isbn=?, publisher=?, publication_year=?, language=?, page_count=? WHERE id=?`,
+		meta.ISBN, meta.Publisher, meta.PublicationYear, meta.Language, meta.PageCount, bookID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("book not found")
+	}
+	return nil
+}
+
+// UpdateBookMetadata corrects a book's title and author and replaces its
+// publication details. The search index follows: the triggers reindex
+// books whose text is kept inline, and books whose text is in the content
+// store are reindexed here.
+func (d *Database) UpdateBookMetadata(bookID int64, title, author string, meta BookMetadata) error {
+	title, author = strings.TrimSpace(title), strings.TrimSpace(author)
+	if title == "" || author == "" {
+		return fmt.Errorf("title and author are required")
+	}
+	meta, err := meta.normalize()
+	if err != nil {
+		return err
+	}
+	defer d.invalidateBook(bookID)
+
+	var ref sql.NullString
+	err = d.db.QueryRow(`SELECT content_ref FROM books WHERE id=?`, bookID).Scan(&ref)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("book not found")
+	}
+	if err != nil {
+		return err
+	}
+	var content string
+	if ref.Valid {
+		if content, err = d.resolveContent("", ref); err != nil {
+			return err
+		}
+	}
+
+	return d.inTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`UPDATE books SET title=?, author=?, isbn=?, publisher=?, publication_year=?, language=?, page_count=?
+		                   WHERE id=?`,
+			title, author, meta.ISBN, meta.Publisher, meta.PublicationYear, meta.Language, meta.PageCount, bookID)
+		if err != nil || !ref.Valid {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM books_fts WHERE rowid=?`, bookID); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO books_fts(rowid, title, author, content) VALUES(?,?,?,?)`, bookID, title, author, content)
+		return err
+	})
+}
+
+// findBooksByISBN returns the copies of the edition with the ISBN q, if q
+// is one.
+func (d *Database) findBooksByISBN(q, columns string) ([]*Book, error) {
+	isbn, err := NormalizeISBN(strings.TrimSpace(q))
+	if err != nil {
+		return nil, nil
+	}
+	rows, err := d.db.Query(`SELECT `+columns+` FROM books b WHERE b.isbn=? ORDER BY b.id`, isbn)
+	if err != nil {
+		return nil, err
+	}
+	return scanBooks(rows)
+}
+
+// ------------------ Manager helpers ------------------
+
+// AddBookWithMetadata adds a book, with its text read from path if path
+// is not empty, and its publication details.
+func (lm *LibraryManager) AddBookWithMetadata(title, author, path string, meta BookMetadata) (int64, error) {
+	content := ""
+	if path != "" {
+		data, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return 0, err
+		}
+		content = string(data)
+	}
+	return lm.db.AddBookWithMetadata(title, author, content, meta)
+}
+
+func (lm *LibraryManager) SetBookMetadata(bookID int64, meta BookMetadata) error {
+	return lm.db.SetBookMetadata(bookID, meta)
+}
+
+func (lm *LibraryManager) UpdateBookMetadata(bookID int64, title, author string, meta BookMetadata) error {
+	return lm.db.UpdateBookMetadata(bookID, title, author, meta)
+}
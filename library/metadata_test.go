@@ -0,0 +1,105 @@
+package library
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeISBN(t *testing.T) {
+	valid := map[string]string{
+		"978-0-441-17271-9": "9780441172719",
+		"0-441-17271-7":     "0441172717",
+		"0 8044 2957 x":     "080442957X",
+	}
+	for in, want := range valid {
+		if got, err := NormalizeISBN(in); err != nil || got != want {
+			t.Errorf("NormalizeISBN(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"978-0-441-17271-8", "12345", "X441172717", "97804411727AB"} {
+		if _, err := NormalizeISBN(in); err == nil {
+			t.Errorf("NormalizeISBN(%q): expected error", in)
+		}
+	}
+}
+
+func TestBookMetadata(t *testing.T) {
+	db := tempDB(t)
+	meta := BookMetadata{ISBN: "978-0-441-17271-9", Publisher: " Ace ", PublicationYear: 1965, Language: "English", PageCount: 604}
+	dune, err := db.AddBookWithMetadata("Dune", "Frank Herbert", "Arrakis", meta)
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	other, _ := db.AddBook("Dune Messiah", "Frank Herbert", "")
+	if _, err := db.AddBookWithMetadata("Bad", "Nobody", "", BookMetadata{ISBN: "123"}); err == nil {
+		t.Fatalf("expected invalid ISBN to be refused")
+	}
+
+	b, err := db.GetBook(dune)
+	if err != nil || b.ISBN != "9780441172719" || b.Publisher != "Ace" || b.PublicationYear != 1965 || b.PageCount != 604 {
+		t.Fatalf("unexpected book: %+v %v", b, err)
+	}
+
+	// An ISBN, however it is typed, finds its edition
+	books, err := db.SearchBooks("978 0441 172719")
+	if err != nil || len(books) != 1 || books[0].ID != dune {
+		t.Fatalf("ISBN search: %+v %v", books, err)
+	}
+	if books, _ := db.SearchBooks("Herbert"); len(books) != 2 {
+		t.Fatalf("text search should still work, got %d books", len(books))
+	}
+
+	if err := db.SetBookMetadata(other, BookMetadata{ISBN: "0-441-17271-7"}); err != nil {
+		t.Fatalf("set metadata: %v", err)
+	}
+	if books, _ := db.SearchBooks("0441172717"); len(books) != 1 || books[0].ID != other {
+		t.Fatalf("ISBN-10 search: %+v", books)
+	}
+
+	report, err := db.ImportBooksCSV(strings.NewReader("title,author,isbn,publication_year\nEmma,Jane Austen,9780141439587,1815\nOops,X,9780141439588,\n"))
+	if err != nil || len(report.Added) != 1 || len(report.Rejected) != 1 {
+		t.Fatalf("import: %v %s", err, report)
+	}
+	if emma, _ := db.GetBook(report.Added[0]); emma.PublicationYear != 1815 {
+		t.Fatalf("imported metadata missing: %+v", emma)
+	}
+}
+
+func TestUpdateBookMetadata(t *testing.T) {
+	db := tempDB(t)
+	inlineID, _ := db.AddBook("Dnue", "Frank Herbret", "desert planet spice")
+	store, _ := NewDirStore(filepath.Join(t.TempDir(), "content"))
+	db.SetContentStore(store, 10)
+	externalID, _ := db.AddBook("Emam", "Jane Austin", "a long text kept in the store")
+
+	fixed := BookMetadata{ISBN: "978-0441172719", PublicationYear: 1965}
+	if err := db.UpdateBookMetadata(inlineID, "Dune", "Frank Herbert", fixed); err != nil {
+		t.Fatalf("update inline: %v", err)
+	}
+	if err := db.UpdateBookMetadata(externalID, " Emma ", "Jane Austen", BookMetadata{}); err != nil {
+		t.Fatalf("update external: %v", err)
+	}
+
+	book, _ := db.GetBook(inlineID)
+	if book.Title != "Dune" || book.Author != "Frank Herbert" || book.ISBN != "9780441172719" || book.PublicationYear != 1965 {
+		t.Fatalf("unexpected book: %+v", book)
+	}
+	for q, want := range map[string]int64{"Herbert": inlineID, "Austen": externalID, "Emma": externalID, "store": externalID} {
+		if books, err := db.SearchBooks(q); err != nil || len(books) != 1 || books[0].ID != want {
+			t.Fatalf("search %q: %+v %v", q, books, err)
+		}
+	}
+	for _, q := range []string{"Herbret", "Austin"} {
+		if books, _ := db.SearchBooks(q); len(books) != 0 {
+			t.Fatalf("search %q still finds the old spelling", q)
+		}
+	}
+
+	if err := db.UpdateBookMetadata(inlineID, "", "Author", BookMetadata{}); err == nil {
+		t.Fatalf("expected an error for an empty title")
+	}
+	if err := db.UpdateBookMetadata(999, "Title", "Author", BookMetadata{}); err == nil {
+		t.Fatalf("expected an error for a missing book")
+	}
+}
@@ -1,20 +1,92 @@
 package library
 
+import "time"
+
 // Book represents a book in the library.
 type Book struct {
 	ID         int64  `json:"id"`
 	Title      string `json:"title"`
 	Author     string `json:"author"`
-	Content    string `json:"content"`
+	Content    string `json:"content"` // empty when the text is in the content store
 	Available  bool   `json:"available"`
 	BorrowerID int64  `json:"borrower_id,omitempty"`
+
+	NonCirculating bool      `json:"non_circulating"` // reference item, in-library use only
+	ItemType       string    `json:"item_type"`       // "book", "laptop", ...
+	UpdatedTime    time.Time `json:"updated_time"`    // last change to the record, kept by triggers
+
+	BookMetadata
+}
+
+// BookMetadata is a book's publication details. Every field is optional.
+type BookMetadata struct {
+	ISBN            string `json:"isbn,omitempty"` // ISBN-10 or ISBN-13, normalized without hyphens
+	Publisher       string `json:"publisher,omitempty"`
+	PublicationYear int    `json:"publication_year,omitempty"`
+	Language        string `json:"language,omitempty"`
+	PageCount       int    `json:"page_count,omitempty"` // printed pages, not reader pages
+}
+
+// SearchResult is a book found by a search, with how well and where it
+// matched. ISBN lookups and searches that only filter have no score or
+// excerpts.
+type SearchResult struct {
+	Book           *Book   `json:"book"`
+	Score          float64 `json:"score"`                     // relevance, higher is better
+	TitleHighlight string  `json:"title_highlight,omitempty"` // the title with matched terms in [brackets]
+	Snippet        string  `json:"snippet,omitempty"`         // an excerpt of the text, matched terms in [brackets]
 }
 
 // Member represents a library member with secure password handling.
 type Member struct {
-	ID           int64  `json:"id"`
-	Name         string `json:"name"`
-	PasswordHash string `json:"-"` // Excluded from JSON serialization for security
+	ID           int64      `json:"id"`
+	Name         string     `json:"name"`
+	PasswordHash string     `json:"-"` // Excluded from JSON serialization for security
+	IsAdmin      bool       `json:"is_admin"`
+	ExpiryTime   *time.Time `json:"expiry_time,omitempty"` // nil for legacy members without a term
+	Tier         string     `json:"tier"`
+	Active       bool       `json:"active"` // false once deactivated; see DeactivateMember
+}
+
+// Expired reports whether the membership has lapsed as of now.
+func (m *Member) Expired(now time.Time) bool {
+	return m.ExpiryTime != nil && !now.Before(*m.ExpiryTime)
+}
+
+// Loan status values stored in checkouts.status.
+const (
+	LoanActive         = "active"
+	LoanAwaitingPickup = "awaiting_pickup"
+	LoanClaimsReturned = "claims_returned"
+	LoanLost           = "lost"
+	LoanReturned       = "returned"
+	LoanHoldExpired    = "hold_expired" // closed because the hold was never collected
+)
+
+// Loan is a single checkout record.
+type Loan struct {
+	CheckoutID   int64      `json:"checkout_id"`
+	BookID       int64      `json:"book_id"`
+	BookTitle    string     `json:"book_title"`
+	MemberID     int64      `json:"member_id"`
+	MemberName   string     `json:"member_name"`
+	CheckoutTime time.Time  `json:"checkout_time"`
+	DueTime      time.Time  `json:"due_time"` // zero while a hold awaits pickup
+	ReturnTime   *time.Time `json:"return_time,omitempty"`
+	Status       string     `json:"status"`
+	ClaimTime    *time.Time `json:"claim_time,omitempty"`
+	// HoldExpiryTime is when a hold awaiting pickup lapses and passes on.
+	HoldExpiryTime *time.Time `json:"hold_expiry_time,omitempty"`
+
+	FineCentsPerDay int64  `json:"fine_cents_per_day"` // from the item type, else the borrower's membership tier
+	DepositCents    int64  `json:"deposit_cents,omitempty"`
+	DepositStatus   string `json:"deposit_status,omitempty"` // held, refunded or forfeited; empty without a deposit
+
+	// Who collected the hold and who handed the item back, when recorded:
+	// the borrower or one of their delegates. 0 for a code pickup or a desk
+	// check-in.
+	PickedUpBy int64 `json:"picked_up_by,omitempty"`
+	ReturnedBy int64 `json:"returned_by,omitempty"`
 }
 
 // LibraryData represents the complete library state for persistence
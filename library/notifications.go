@@ -0,0 +1,136 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Notice kinds stored in the notifications inbox.
+const (
+	NoticeHoldReady    = "hold_ready"
+	NoticeHoldExpired  = "hold_expired"
+	NoticeOverdue      = "overdue"
+	NoticeAnnouncement = "announcement"
+)
+
+// Notification is a message in a member's in-app inbox.
+type Notification struct {
+	ID          int64     `json:"id"`
+	MemberID    int64     `json:"member_id"`
+	Kind        string    `json:"kind"`
+	Subject     string    `json:"subject"`
+	Body        string    `json:"body"`
+	CreatedTime time.Time `json:"created_time"`
+}
+
+// insertNotification queues a message in memberID's inbox within tx.
+func insertNotification(tx *sql.Tx, memberID int64, kind, subject, body string) error {
+	_, err := tx.Exec(`INSERT INTO notifications(member_id, kind, subject, body) VALUES(?,?,?,?)`,
+		memberID, kind, subject, body)
+	return err
+}
+
+// AddNotification stores a message in memberID's inbox.
+func (d *Database) AddNotification(memberID int64, kind, subject, body string) error {
+	var exists int
+	err := d.db.QueryRow(`SELECT 1 FROM members WHERE id=?`, memberID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("member not found")
+	}
+	if err != nil {
+		return err
+	}
+	_, err = d.db.Exec(`INSERT INTO notifications(member_id, kind, subject, body) VALUES(?,?,?,?)`,
+		memberID, kind, subject, body)
+	return err
+}
+
+// Announce delivers the same message to every active member's inbox and
+// returns the number of members reached.
+func (d *Database) Announce(subject, body string) (int64, error) {
+	if subject == "" {
+		return 0, fmt.Errorf("announcement subject cannot be empty")
+	}
+	res, err := d.db.Exec(`INSERT INTO notifications(member_id, kind, subject, body)
+                           SELECT id, ?, ?, ? FROM members WHERE active = 1`, NoticeAnnouncement, subject, body)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// CountUnreadNotifications returns how many unread messages memberID has.
+func (d *Database) CountUnreadNotifications(memberID int64) (int, error) {
+	var n int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE member_id=? AND read_time IS NULL`, memberID).Scan(&n)
+	return n, err
+}
+
+// ReadNotifications returns memberID's unread messages, oldest first, and
+// marks them read in the same transaction.
+func (d *Database) ReadNotifications(memberID int64) ([]*Notification, error) {
+	return inTxResult(d, func(tx *sql.Tx) ([]*Notification, error) {
+		rows, err := tx.Query(`SELECT id, member_id, kind, subject, body, created_time
+	                           FROM notifications
+	                           WHERE member_id=? AND read_time IS NULL
+	                           ORDER BY created_time, id`, memberID)
+		if err != nil {
+			return nil, err
+		}
+
+		var notes []*Notification
+		for rows.Next() {
+			var n Notification
+			if err := rows.Scan(&n.ID, &n.MemberID, &n.Kind, &n.Subject, &n.Body, &n.CreatedTime); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			notes = append(notes, &n)
+		}
+		if err := rows.Close(); err != nil {
+			return nil, err
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		if len(notes) > 0 {
+			if _, err := tx.Exec(`UPDATE notifications SET read_time=CURRENT_TIMESTAMP WHERE member_id=? AND read_time IS NULL AND id<=?`,
+				memberID, notes[len(notes)-1].ID); err != nil {
+				return nil, err
+			}
+		}
+		return notes, nil
+	})
+}
+
+// InboxNotifier delivers notices to the in-app notifications table, which
+// works even where no email transport is configured.
+type InboxNotifier struct {
+	db *Database
+}
+
+// NewInboxNotifier returns a Notifier writing to the manager's inbox.
+func (lm *LibraryManager) NewInboxNotifier() *InboxNotifier {
+	return &InboxNotifier{db: lm.db}
+}
+
+// Notify implements Notifier.
+func (in *InboxNotifier) Notify(n Notice) error {
+	return in.db.AddNotification(n.MemberID, n.Kind, n.Subject, n.Body)
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) ReadNotifications(memberID int64) ([]*Notification, error) {
+	return lm.db.ReadNotifications(memberID)
+}
+
+func (lm *LibraryManager) CountUnreadNotifications(memberID int64) (int, error) {
+	return lm.db.CountUnreadNotifications(memberID)
+}
+
+func (lm *LibraryManager) Announce(subject, body string) (int64, error) {
+	return lm.db.Announce(subject, body)
+}
@@ -0,0 +1,72 @@
+package library
+
+import (
+	"testing"
+)
+
+func TestHoldReadyNotification(t *testing.T) {
+	db := tempDB(t)
+	bookID, _ := db.AddBook("Popular Book", "Author", "content")
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+
+	db.CheckoutBook(bookID, alice)
+	db.ReserveBook(bookID, bob)
+	if _, err := db.ReturnBook(bookID); err != nil {
+		t.Fatalf("return: %v", err)
+	}
+
+	if n, _ := db.CountUnreadNotifications(bob); n != 1 {
+		t.Fatalf("Bob should have 1 unread notification, got %d", n)
+	}
+	notes, err := db.ReadNotifications(bob)
+	if err != nil {
+		t.Fatalf("read notifications: %v", err)
+	}
+	if len(notes) != 1 || notes[0].Kind != NoticeHoldReady {
+		t.Fatalf("expected a hold-ready notification, got %+v", notes)
+	}
+
+	// Viewing marks messages read
+	notes, _ = db.ReadNotifications(bob)
+	if len(notes) != 0 {
+		t.Fatalf("notifications should be marked read after viewing")
+	}
+	if n, _ := db.CountUnreadNotifications(alice); n != 0 {
+		t.Fatalf("Alice should have no notifications, got %d", n)
+	}
+}
+
+func TestAnnounceAndOverdueInbox(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	bookID, _ := db.AddBook("Book", "Author", "content")
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+
+	reached, err := lm.Announce("Closed Monday", "The library is closed for the holiday.")
+	if err != nil || reached != 2 {
+		t.Fatalf("announce reached %d members, err=%v", reached, err)
+	}
+
+	db.CheckoutBook(bookID, alice)
+	db.db.Exec(`UPDATE checkouts SET due_time = datetime('now', '-1 day')`)
+	if sent, err := lm.SendOverdueNotices(lm.NewInboxNotifier()); err != nil || sent != 1 {
+		t.Fatalf("overdue notices sent=%d err=%v", sent, err)
+	}
+
+	notes, _ := lm.ReadNotifications(alice)
+	if len(notes) != 2 || notes[0].Kind != NoticeAnnouncement || notes[1].Kind != NoticeOverdue {
+		t.Fatalf("unexpected inbox for Alice: %+v", notes)
+	}
+	if n, _ := lm.CountUnreadNotifications(bob); n != 1 {
+		t.Fatalf("Bob should only have the announcement, got %d", n)
+	}
+}
+
+func TestAddNotificationUnknownMember(t *testing.T) {
+	db := tempDB(t)
+	if err := db.AddNotification(999, NoticeAnnouncement, "Hi", ""); err == nil {
+		t.Fatalf("expected error for unknown member")
+	}
+}
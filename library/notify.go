@@ -0,0 +1,43 @@
+package library
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Notice is a single message addressed to a library member.
+type Notice struct {
+	MemberID   int64
+	MemberName string
+	Kind       string // e.g. "due_soon"
+	Subject    string
+	Body       string
+}
+
+// Notifier delivers notices to members. Implementations must be safe for
+// concurrent use because jobs may run in the background.
+type Notifier interface {
+	Notify(n Notice) error
+}
+
+// WriterNotifier writes each notice as a single timestamped line to W.
+type WriterNotifier struct {
+	mu sync.Mutex
+	W  io.Writer
+}
+
+// NewWriterNotifier returns a Notifier that logs notices to w.
+func NewWriterNotifier(w io.Writer) *WriterNotifier {
+	return &WriterNotifier{W: w}
+}
+
+// Notify implements Notifier.
+func (wn *WriterNotifier) Notify(n Notice) error {
+	wn.mu.Lock()
+	defer wn.mu.Unlock()
+	_, err := fmt.Fprintf(wn.W, "%s [%s] to %s (ID: %d): %s - %s\n",
+		time.Now().Format(time.RFC3339), n.Kind, n.MemberName, n.MemberID, n.Subject, n.Body)
+	return err
+}
@@ -0,0 +1,170 @@
+package library
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// PageSize is the number of bytes of content per page, both in the terminal
+// reader and through the pages API. Page n covers the PageSize bytes from
+// (n-1)*PageSize, moved forward at either end to a break between words or,
+// failing that, between characters; see pageText.
+const PageSize = 1500
+
+// ErrPageOutOfRange is returned for page numbers past either end of a book.
+var ErrPageOutOfRange = errors.New("page out of range")
+
+// chapterPattern matches chapter headings on a line of their own, e.g.
+// "CHAPTER IV" or "Chapter 12: The Return".
+var chapterPattern = regexp.MustCompile(`(?mi)^[ \t]*chapter[ \t]+[0-9ivxlcdm]+\b[^\n]*$`)
+
+// Page is one page of a book's content as served to external readers.
+type Page struct {
+	BookID     int64  `json:"book_id"`
+	Title      string `json:"title"`
+	Author     string `json:"author"`
+	Number     int    `json:"page"` // 1-based
+	TotalPages int    `json:"total_pages"`
+	Chapter    string `json:"chapter,omitempty"` // heading of the chapter the page ends in
+	Text       string `json:"text"`
+}
+
+// GetPage returns page n (1-based) of bookID for memberID, applying the same
+// loan and authorization rules as ReadBook.
+func (lm *LibraryManager) GetPage(bookID, memberID int64, n int) (*Page, error) {
+	validation, err := lm.authorizeRead(bookID, memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := (validation.BookContentLength + PageSize - 1) / PageSize
+	if n < 1 || n > totalPages {
+		return nil, ErrPageOutOfRange
+	}
+
+	text, err := lm.db.GetBookPage(bookID, n)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := lm.db.getBookMeta(bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{
+		BookID:     bookID,
+		Title:      validation.BookTitle,
+		Author:     validation.BookAuthor,
+		Number:     n,
+		TotalPages: totalPages,
+		Text:       text,
+	}
+	// The chapter heading may lie on an earlier page. Headings start lines,
+	// so none is carried onto the page past its nominal end
+	for _, c := range meta.chapters {
+		if c.offset >= n*PageSize {
+			break
+		}
+		page.Chapter = c.heading
+	}
+	return page, nil
+}
+
+// GetBookPage returns the text of page n (1-based) of bookID, or "" past
+// its end. It reads only the page and the few bytes either side that say
+// where its words end.
+func (d *Database) GetBookPage(bookID int64, n int) (string, error) {
+	if n < 1 {
+		return "", ErrPageOutOfRange
+	}
+	key := chunkKey{bookID: bookID, page: n}
+	if d.chunkCache != nil {
+		if text, ok := d.chunkCache.get(key); ok {
+			return text, nil
+		}
+	}
+	start := (n - 1) * PageSize
+	lead := min(start, 1)
+	window, err := d.loadContentBytes(bookID, start-lead, lead+PageSize+pageCarry+utf8.UTFMax)
+	if err != nil {
+		return "", err
+	}
+	text := pageText(window, lead, PageSize)
+	if d.chunkCache != nil {
+		d.chunkCache.add(key, text)
+	}
+	return text, nil
+}
+
+// PageMatch is a page of a book that a search within it found.
+type PageMatch struct {
+	Page    int    // 1-based
+	Count   int    // matches on the page
+	Excerpt string // words around the first, in [brackets]
+}
+
+// findExcerptWords is how many words of lead-in each PageMatch excerpt has
+// either side of its match.
+const findExcerptWords = 4
+
+// FindInBook returns the pages of bookID where term occurs, in order. Like
+// a search it matches whole words, ignoring case and punctuation, and a
+// trailing * matches words beginning with the term.
+func (d *Database) FindInBook(bookID int64, term string) ([]PageMatch, error) {
+	term = strings.TrimSpace(term)
+	prefix := strings.HasSuffix(term, "*")
+	want := searchTerm{text: strings.TrimSuffix(term, "*"), prefix: prefix}
+	if len(splitWords(want.text)) == 0 {
+		return nil, fmt.Errorf("nothing to search for")
+	}
+
+	var content string
+	var ref sql.NullString
+	if err := d.db.QueryRow(`SELECT `+contentColumns+` FROM books b WHERE b.id=?`, bookID).Scan(&content, &ref); err != nil {
+		return nil, err
+	}
+	content, err := d.resolveContent(content, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	words := splitWords(content)
+	spans := matchSpans(words, []searchTerm{want}, "")
+	var matches []PageMatch
+	for _, s := range spans {
+		page := words[s[0]].start/PageSize + 1
+		if n := len(matches); n > 0 && matches[n-1].Page == page {
+			matches[n-1].Count++
+			continue
+		}
+		from, to := max(0, s[0]-findExcerptWords), min(len(words), s[1]+findExcerptWords)
+		excerpt := markSpans(content, words, spans, from, to)
+		if from > 0 {
+			excerpt = "…" + excerpt
+		}
+		if to < len(words) {
+			excerpt += "…"
+		}
+		matches = append(matches, PageMatch{Page: page, Count: 1, Excerpt: excerpt})
+	}
+	return matches, nil
+}
+
+// GetBookUpdatedTime returns when bookID's record last changed, without
+// loading its content. It returns sql.ErrNoRows for unknown books.
+func (d *Database) GetBookUpdatedTime(bookID int64) (time.Time, error) {
+	var t time.Time
+	err := d.db.QueryRow(`SELECT updated_time FROM books WHERE id=?`, bookID).Scan(&t)
+	return t, err
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) GetBookUpdatedTime(bookID int64) (time.Time, error) {
+	return lm.db.GetBookUpdatedTime(bookID)
+}
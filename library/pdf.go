@@ -0,0 +1,204 @@
+package library
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// A small PDF writer for printable handouts: US Letter pages of wrapped text
+// in the standard Helvetica faces, which every PDF reader has built in, so
+// no fonts are embedded.
+
+type pdfFont int
+
+const (
+	pdfRegular pdfFont = iota
+	pdfBold
+	pdfItalic
+)
+
+var pdfFontNames = []string{"Helvetica", "Helvetica-Bold", "Helvetica-Oblique"}
+
+const (
+	pdfPageWidth  = 612.0
+	pdfPageHeight = 792.0
+	pdfMargin     = 54.0
+	pdfLeading    = 1.3
+)
+
+type pdfDoc struct {
+	pages  []*bytes.Buffer
+	y      float64 // baseline of the next line on the current page
+	footer string
+}
+
+func newPDFDoc() *pdfDoc {
+	d := &pdfDoc{}
+	d.newPage()
+	return d
+}
+
+func (d *pdfDoc) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pdfPageHeight - pdfMargin
+}
+
+// space leaves pt points of vertical space.
+func (d *pdfDoc) space(pt float64) { d.y -= pt }
+
+// keepTogether starts a new page unless pt points still fit on this one.
+func (d *pdfDoc) keepTogether(pt float64) {
+	if d.y-pt < pdfMargin+20 && d.y < pdfPageHeight-pdfMargin {
+		d.newPage()
+	}
+}
+
+// setFooter sets a line printed at the bottom of every page, followed by the
+// page number.
+func (d *pdfDoc) setFooter(text string) { d.footer = text }
+
+// paragraph writes text wrapped to the page width, indented by indent points,
+// breaking onto new pages as needed.
+func (d *pdfDoc) paragraph(font pdfFont, size, indent float64, text string) {
+	width := pdfPageWidth - 2*pdfMargin - indent
+	for _, line := range wrapPDFText(text, font, size, width) {
+		if d.y-size < pdfMargin+20 {
+			d.newPage()
+		}
+		d.y -= size
+		d.text(font, size, pdfMargin+indent, d.y, line)
+		d.y -= size * (pdfLeading - 1)
+	}
+}
+
+func (d *pdfDoc) text(font pdfFont, size, x, y float64, s string) {
+	fmt.Fprintf(d.pages[len(d.pages)-1], "BT /F%d %.1f Tf %.2f %.2f Td (%s) Tj ET\n",
+		font+1, size, x, y, pdfString(s))
+}
+
+// wrapPDFText splits text into lines no wider than width points. A single
+// word wider than the line is left to overflow rather than broken.
+func wrapPDFText(text string, font pdfFont, size, width float64) []string {
+	var lines []string
+	for _, para := range strings.Split(text, "\n") {
+		words := strings.Fields(para)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		line := words[0]
+		for _, w := range words[1:] {
+			if pdfTextWidth(line+" "+w, font, size) > width {
+				lines = append(lines, line)
+				line = w
+			} else {
+				line += " " + w
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// pdfTextWidth estimates the printed width of s from rough Helvetica glyph
+// classes; close enough for wrapping.
+func pdfTextWidth(s string, font pdfFont, size float64) float64 {
+	var em float64
+	for _, r := range s {
+		switch {
+		case strings.ContainsRune(" .,;:'!|ijltfI()[]", r):
+			em += 0.28
+		case r == 'm' || r == 'w' || r == 'M' || r == 'W':
+			em += 0.85
+		case r >= 'A' && r <= 'Z':
+			em += 0.68
+		default:
+			em += 0.56
+		}
+	}
+	if font == pdfBold {
+		em *= 1.06
+	}
+	return em * size
+}
+
+// winAnsiExtras maps the punctuation people paste in from word processors
+// onto its WinAnsiEncoding byte.
+var winAnsiExtras = map[rune]byte{
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95,
+	'–': 0x96, '—': 0x97, '…': 0x85, '€': 0x80, '™': 0x99,
+}
+
+// pdfString encodes s as the body of a PDF literal string in WinAnsi,
+// replacing characters the standard fonts can't show with '?'.
+func pdfString(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			sb.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&sb, "\\%03o", r)
+		case winAnsiExtras[r] != 0:
+			fmt.Fprintf(&sb, "\\%03o", winAnsiExtras[r])
+		case r == utf8.RuneError || r < 0x20:
+			sb.WriteByte(' ')
+		default:
+			sb.WriteByte('?')
+		}
+	}
+	return sb.String()
+}
+
+// WriteTo writes the finished document.
+func (d *pdfDoc) WriteTo(w io.Writer) (int64, error) {
+	var out bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-2 are the catalog and page tree, 3-5 the fonts, then a page
+	// and its content stream for each page.
+	nPages := len(d.pages)
+	kids := make([]string, nPages)
+	for i := range kids {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), nPages))
+	for _, name := range pdfFontNames {
+		obj(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+	}
+	for i, page := range d.pages {
+		content := page.String()
+		footer := fmt.Sprintf("Page %d of %d", i+1, nPages)
+		if d.footer != "" {
+			footer = d.footer + " · " + footer
+		}
+		content += fmt.Sprintf("BT /F1 8.0 Tf %.2f %.2f Td (%s) Tj ET\n", pdfMargin, pdfMargin-20, pdfString(footer))
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R /F3 5 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 7+2*i))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	n, err := w.Write(out.Bytes())
+	return int64(n), err
+}
@@ -0,0 +1,54 @@
+package library
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestLockerPickupCode(t *testing.T) {
+	db := tempDB(t)
+	db.SetLockerPickup(true)
+	bookID, _ := db.AddBook("Popular Book", "Author", "content")
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+
+	db.CheckoutBook(bookID, alice)
+	db.ReserveBook(bookID, bob)
+	if _, err := db.ReturnBook(bookID); err != nil {
+		t.Fatalf("return: %v", err)
+	}
+
+	loans, _ := db.GetOpenLoans(bob)
+	if len(loans) != 1 || loans[0].Status != LoanAwaitingPickup {
+		t.Fatalf("Bob's hold should await pickup, got %+v", loans)
+	}
+
+	notes, _ := db.ReadNotifications(bob)
+	if len(notes) != 1 {
+		t.Fatalf("expected a hold-ready notification, got %+v", notes)
+	}
+	code := regexp.MustCompile(`\d{6}`).FindString(notes[0].Body)
+	if code == "" {
+		t.Fatalf("notification should carry the pickup code: %q", notes[0].Body)
+	}
+
+	if _, err := db.VerifyPickup("not-a-code"); err == nil {
+		t.Fatalf("unknown code should be rejected")
+	}
+	loan, err := db.VerifyPickup(code)
+	if err != nil {
+		t.Fatalf("verify pickup: %v", err)
+	}
+	if loan.MemberID != bob || loan.Status != LoanActive {
+		t.Fatalf("unexpected loan after pickup: %+v", loan)
+	}
+	if loan.DueTime.Sub(loan.CheckoutTime) != defaultLoanDays*24*time.Hour {
+		t.Fatalf("loan period should restart at pickup, got %v", loan.DueTime.Sub(loan.CheckoutTime))
+	}
+
+	// Codes are single use
+	if _, err := db.VerifyPickup(code); err == nil {
+		t.Fatalf("pickup code should not be reusable")
+	}
+}
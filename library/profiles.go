@@ -0,0 +1,120 @@
+package library
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Age groups accepted in member profiles.
+var AgeGroups = []string{"0-12", "13-17", "18-64", "65+"}
+
+var zipPattern = regexp.MustCompile(`^\d{5}(-\d{4})?$`)
+
+// MemberProfile holds optional, privacy-flagged address and demographic data.
+// It is write-only from the application's point of view: individual profiles
+// are never read back out, only aggregated by ServiceAreaReport.
+type MemberProfile struct {
+	Address  string
+	Zip      string
+	AgeGroup string
+}
+
+// AreaStats is one row of the service-area report.
+type AreaStats struct {
+	Zip       string `json:"zip"`
+	Members   int    `json:"members"`
+	Checkouts int    `json:"checkouts"`
+}
+
+// SetMemberProfile stores or replaces memberID's profile. Empty fields are
+// allowed; every field is optional.
+func (d *Database) SetMemberProfile(memberID int64, p MemberProfile) error {
+	p.Zip = strings.TrimSpace(p.Zip)
+	if p.Zip != "" && !zipPattern.MatchString(p.Zip) {
+		return fmt.Errorf("invalid ZIP code %q", p.Zip)
+	}
+	if p.AgeGroup != "" {
+		valid := false
+		for _, g := range AgeGroups {
+			if p.AgeGroup == g {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("invalid age group %q (use one of %s)", p.AgeGroup, strings.Join(AgeGroups, ", "))
+		}
+	}
+	if _, err := d.GetMember(memberID); err != nil {
+		return fmt.Errorf("member with ID %d not found", memberID)
+	}
+
+	// Only the 5-digit ZIP is kept; ZIP+4 is precise enough to identify a street
+	_, err := d.db.Exec(`INSERT INTO member_profiles(member_id, address, zip, age_group) VALUES(?,?,?,?)
+                         ON CONFLICT(member_id) DO UPDATE SET
+                             address=excluded.address, zip=excluded.zip, age_group=excluded.age_group,
+                             updated_time=CURRENT_TIMESTAMP`,
+		memberID, strings.TrimSpace(p.Address), p.Zip[:min(len(p.Zip), 5)], p.AgeGroup)
+	return err
+}
+
+// HasMemberProfile reports whether memberID has profile data on file without
+// revealing it.
+func (d *Database) HasMemberProfile(memberID int64) (bool, error) {
+	var n int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM member_profiles WHERE member_id=?`, memberID).Scan(&n)
+	return n > 0, err
+}
+
+// ServiceAreaReport aggregates members, and checkouts from since up to until,
+// by 5-digit ZIP. ZIPs with fewer than minCell members are folded into an
+// "other" row so small groups can't be singled out.
+func (d *Database) ServiceAreaReport(since, until time.Time, minCell int) ([]*AreaStats, error) {
+	rows, err := d.db.Query(`SELECT CASE WHEN p.zip = '' OR p.zip IS NULL THEN 'unknown' ELSE p.zip END AS area,
+                                    COUNT(DISTINCT m.id),
+                                    COUNT(c.id)
+                             FROM members m
+                             LEFT JOIN member_profiles p ON p.member_id = m.id
+                             LEFT JOIN checkouts c ON c.member_id = m.id AND c.checkout_time >= ? AND c.checkout_time <= ?
+                             GROUP BY area
+                             ORDER BY area`, sqlTime(&since), sqlTime(&until))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var report []*AreaStats
+	other := &AreaStats{Zip: "other"}
+	for rows.Next() {
+		var a AreaStats
+		if err := rows.Scan(&a.Zip, &a.Members, &a.Checkouts); err != nil {
+			return nil, err
+		}
+		if a.Zip != "unknown" && a.Members < minCell {
+			other.Members += a.Members
+			other.Checkouts += a.Checkouts
+			continue
+		}
+		report = append(report, &a)
+	}
+	if other.Members > 0 {
+		report = append(report, other)
+	}
+	return report, rows.Err()
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) SetMemberProfile(memberID int64, p MemberProfile) error {
+	return lm.db.SetMemberProfile(memberID, p)
+}
+
+func (lm *LibraryManager) HasMemberProfile(memberID int64) (bool, error) {
+	return lm.db.HasMemberProfile(memberID)
+}
+
+func (lm *LibraryManager) ServiceAreaReport(since, until time.Time, minCell int) ([]*AreaStats, error) {
+	return lm.db.ServiceAreaReport(since, until, minCell)
+}
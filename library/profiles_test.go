@@ -0,0 +1,69 @@
+package library
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestServiceAreaReport(t *testing.T) {
+	db := tempDB(t)
+	bookID, _ := db.AddBook("Book", "Author", "content")
+
+	// Three members in 12345, one in 99999, one without a profile
+	var members []int64
+	for i := 0; i < 5; i++ {
+		id, _ := db.AddMember(fmt.Sprintf("Member %d", i), "password")
+		members = append(members, id)
+	}
+	for _, id := range members[:3] {
+		if err := db.SetMemberProfile(id, MemberProfile{Address: "1 Main St", Zip: "12345-6789", AgeGroup: "18-64"}); err != nil {
+			t.Fatalf("set profile: %v", err)
+		}
+	}
+	db.SetMemberProfile(members[3], MemberProfile{Zip: "99999"})
+
+	db.CheckoutBook(bookID, members[0])
+	db.ReturnBook(bookID)
+	db.CheckoutBook(bookID, members[1])
+
+	report, err := db.ServiceAreaReport(time.Now().Add(-time.Hour), time.Now().Add(time.Minute), 2)
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+
+	got := map[string]AreaStats{}
+	for _, a := range report {
+		got[a.Zip] = *a
+	}
+	if got["12345"].Members != 3 || got["12345"].Checkouts != 2 {
+		t.Fatalf("12345 row = %+v", got["12345"])
+	}
+	if _, ok := got["99999"]; ok {
+		t.Fatalf("ZIPs below the minimum cell size must not be reported individually")
+	}
+	if got["other"].Members != 1 || got["unknown"].Members != 1 {
+		t.Fatalf("unexpected other/unknown rows: %+v", report)
+	}
+}
+
+func TestSetMemberProfileValidation(t *testing.T) {
+	db := tempDB(t)
+	alice, _ := db.AddMember("Alice", "password")
+
+	if err := db.SetMemberProfile(alice, MemberProfile{Zip: "ABCDE"}); err == nil {
+		t.Fatalf("invalid ZIP should be rejected")
+	}
+	if err := db.SetMemberProfile(alice, MemberProfile{AgeGroup: "teen"}); err == nil {
+		t.Fatalf("invalid age group should be rejected")
+	}
+	if has, _ := db.HasMemberProfile(alice); has {
+		t.Fatalf("no profile should be stored after failed updates")
+	}
+	if err := db.SetMemberProfile(alice, MemberProfile{Zip: "12345"}); err != nil {
+		t.Fatalf("set profile: %v", err)
+	}
+	if has, _ := db.HasMemberProfile(alice); !has {
+		t.Fatalf("profile should be on file")
+	}
+}
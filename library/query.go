@@ -0,0 +1,155 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxQueryRows caps how many rows an ad-hoc query returns.
+const MaxQueryRows = 10000
+
+// QueryResult is the outcome of an ad-hoc read-only query. Values are nil,
+// int64, float64 or string.
+type QueryResult struct {
+	Columns   []string
+	Rows      [][]any
+	Truncated bool // more than MaxQueryRows rows matched
+}
+
+// readOnlyVerbs are the statements Query accepts.
+var readOnlyVerbs = []string{"SELECT", "WITH", "VALUES", "EXPLAIN"}
+
+// Query runs one read-only SQL statement for ad-hoc reporting. The statement
+// must start with SELECT, WITH, VALUES or EXPLAIN, and it runs on a separate
+// connection opened read-only, so nothing it does can change the library,
+// whatever SQL it smuggles in.
+func (d *Database) Query(query string) (*QueryResult, error) {
+	if err := checkReadOnlyStatement(query); err != nil {
+		return nil, err
+	}
+	if d.path == "" || strings.HasPrefix(d.path, ":memory:") {
+		return nil, fmt.Errorf("query needs a database file")
+	}
+
+	dsn := fmt.Sprintf("file:%s?mode=ro&_query_only=1&_busy_timeout=5000", d.path)
+	ro, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open read-only connection: %w", err)
+	}
+	defer ro.Close()
+
+	rows, err := ro.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	res := &QueryResult{Columns: cols}
+	for rows.Next() {
+		if len(res.Rows) == MaxQueryRows {
+			res.Truncated = true
+			break
+		}
+		vals := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		for i, v := range vals {
+			switch v := v.(type) {
+			case []byte:
+				vals[i] = string(v)
+			case bool:
+				if v {
+					vals[i] = int64(1)
+				} else {
+					vals[i] = int64(0)
+				}
+			case time.Time:
+				vals[i] = v.UTC().Format(time.RFC3339)
+			}
+		}
+		res.Rows = append(res.Rows, vals)
+	}
+	return res, rows.Err()
+}
+
+// checkReadOnlyStatement rejects anything but a single statement starting
+// with one of readOnlyVerbs. The driver runs every statement in a query
+// string, so a second one hidden after a semicolon must not get through.
+func checkReadOnlyStatement(query string) error {
+	body := maskSQL(query)
+	fields := strings.Fields(body)
+	if len(fields) == 0 {
+		return fmt.Errorf("empty query")
+	}
+	verb := strings.ToUpper(strings.TrimLeft(fields[0], "("))
+	allowed := false
+	for _, v := range readOnlyVerbs {
+		if verb == v {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("only read-only statements (%s) are allowed", strings.Join(readOnlyVerbs, ", "))
+	}
+	if i := strings.IndexByte(body, ';'); i >= 0 && strings.TrimSpace(body[i+1:]) != "" {
+		return fmt.Errorf("only one statement may be run at a time")
+	}
+	return nil
+}
+
+// maskSQL blanks out comments and the contents of quoted strings and
+// identifiers, leaving the statement's structure (keywords, semicolons)
+// intact. An unterminated quote swallows the rest of the text.
+func maskSQL(query string) string {
+	var sb strings.Builder
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+			sb.WriteByte(' ')
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 3
+			}
+			sb.WriteByte(' ')
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			// Doubled quotes inside a literal just close and reopen it
+			j := i + 1
+			for j < len(query) && query[j] != closing {
+				j++
+			}
+			i = j
+			sb.WriteString(" x ")
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// ------------------ Manager helpers ------------------
+
+// Query runs a read-only ad-hoc SQL statement; see Database.Query.
+func (lm *LibraryManager) Query(query string) (*QueryResult, error) { return lm.db.Query(query) }
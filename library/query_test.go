@@ -0,0 +1,53 @@
+package library
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestQueryReadOnly(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "library.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	db.AddBook("Dune", "Herbert", "")
+	db.AddBook("Emma", "Austen", "")
+
+	res, err := db.Query("SELECT title, author FROM books ORDER BY title -- sorted")
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(res.Columns) != 2 || len(res.Rows) != 2 || res.Rows[0][0] != "Dune" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	rejected := []string{
+		"DELETE FROM books",
+		"PRAGMA query_only=OFF",
+		"SELECT 1; DELETE FROM books",
+		"SELECT 1; PRAGMA query_only=OFF; DELETE FROM books",
+		"/* SELECT */ UPDATE books SET title='x'",
+		"",
+	}
+	for _, q := range rejected {
+		if _, err := db.Query(q); err == nil {
+			t.Errorf("expected %q to be rejected", q)
+		}
+	}
+
+	// Semicolons inside literals and a trailing one are fine
+	if _, err := db.Query("SELECT ';' || title FROM books WHERE author != 'x;y';"); err != nil {
+		t.Fatalf("literal semicolons: %v", err)
+	}
+
+	// Even a statement that slips past the check can't write
+	if _, err := db.Query("WITH x AS (SELECT 1) DELETE FROM books"); err == nil {
+		t.Fatalf("expected the read-only connection to refuse a write")
+	}
+	var n int
+	db.db.QueryRow(`SELECT COUNT(*) FROM books`).Scan(&n)
+	if n != 2 {
+		t.Fatalf("books changed: %d", n)
+	}
+}
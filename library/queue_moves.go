@@ -0,0 +1,240 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NoticeQueueMoved tells a member they have moved up reservation queues.
+const NoticeQueueMoved = "queue_moved"
+
+// QueueMove is a member moving up a book's reservation queue because a
+// reservation ahead of theirs was cancelled or fulfilled. Positions are
+// 1-based; 1 is next in line.
+type QueueMove struct {
+	BookID   int64
+	MemberID int64
+	From, To int
+}
+
+// queuePositions returns the 1-based place of each member waiting for
+// bookID, read within tx.
+func queuePositions(tx *sql.Tx, bookID int64) (map[int64]int, error) {
+	rows, err := tx.Query(`SELECT member_id FROM reservations WHERE book_id=? AND fulfilled_time IS NULL
+	                       ORDER BY reservation_time, id`, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	positions := make(map[int64]int)
+	for rows.Next() {
+		var memberID int64
+		if err := rows.Scan(&memberID); err != nil {
+			return nil, err
+		}
+		positions[memberID] = len(positions) + 1
+	}
+	return positions, rows.Err()
+}
+
+// watchQueues notes the reservation queues of bookIDs within tx before a
+// change to them. The returned function, called after the change, raises
+// an EventQueueMoved for the members who moved up, to be published once tx
+// commits.
+func (d *Database) watchQueues(tx *sql.Tx, bookIDs ...int64) (func() error, error) {
+	before := make(map[int64]map[int64]int, len(bookIDs))
+	for _, bookID := range bookIDs {
+		positions, err := queuePositions(tx, bookID)
+		if err != nil {
+			return nil, err
+		}
+		before[bookID] = positions
+	}
+	return func() error {
+		var moves []QueueMove
+		for _, bookID := range bookIDs {
+			after, err := queuePositions(tx, bookID)
+			if err != nil {
+				return err
+			}
+			for memberID, to := range after {
+				if from, ok := before[bookID][memberID]; ok && to < from {
+					moves = append(moves, QueueMove{BookID: bookID, MemberID: memberID, From: from, To: to})
+				}
+			}
+		}
+		if len(moves) > 0 {
+			sort.Slice(moves, func(i, j int) bool {
+				if moves[i].BookID != moves[j].BookID {
+					return moves[i].BookID < moves[j].BookID
+				}
+				return moves[i].To < moves[j].To
+			})
+			d.events.queue(tx, Event{Kind: EventQueueMoved, Time: d.now(), Moves: moves})
+		}
+		return nil
+	}, nil
+}
+
+// QueueNotifier tells members when they move up a reservation queue. It
+// collects EventQueueMoved events as they happen, and each Flush sends a
+// member one notice covering every queue they moved up in since the last,
+// so a queue that moves several times in a short while isn't a stream of
+// messages.
+type QueueNotifier struct {
+	db    *Database
+	email Notifier // nil unless members can be emailed
+
+	mu      sync.Mutex
+	pending map[int64]map[int64]bool // books by member
+}
+
+// NotifyQueueMoves subscribes a QueueNotifier to queue moves. Notices go to
+// the in-app inbox, and through email as well to members who asked for it
+// in their email preferences; email may be nil.
+func (lm *LibraryManager) NotifyQueueMoves(email Notifier) *QueueNotifier {
+	qn := &QueueNotifier{db: lm.db, email: email, pending: make(map[int64]map[int64]bool)}
+	lm.db.OnQueueMoved(qn.Handle)
+	return qn
+}
+
+// Handle is an EventHandler for EventQueueMoved.
+func (qn *QueueNotifier) Handle(e Event) {
+	qn.mu.Lock()
+	defer qn.mu.Unlock()
+	for _, m := range e.Moves {
+		if qn.pending[m.MemberID] == nil {
+			qn.pending[m.MemberID] = make(map[int64]bool)
+		}
+		qn.pending[m.MemberID][m.BookID] = true
+	}
+}
+
+// queueSpot is where a member now stands in a book's queue.
+type queueSpot struct {
+	title    string
+	position int
+}
+
+// Flush sends the notices for the moves collected since the last flush and
+// returns the number of members told. Each queue is described as it stands
+// now; one the member has since left, because their turn came or they
+// cancelled, is left out.
+func (qn *QueueNotifier) Flush() (int, error) {
+	qn.mu.Lock()
+	pending := qn.pending
+	qn.pending = make(map[int64]map[int64]bool)
+	qn.mu.Unlock()
+
+	memberIDs := make([]int64, 0, len(pending))
+	for memberID := range pending {
+		memberIDs = append(memberIDs, memberID)
+	}
+	sort.Slice(memberIDs, func(i, j int) bool { return memberIDs[i] < memberIDs[j] })
+
+	told := 0
+	for _, memberID := range memberIDs {
+		spots, err := qn.db.queueSpots(memberID, pending[memberID])
+		if err != nil {
+			qn.requeue(memberID, pending[memberID])
+			return told, err
+		}
+		if len(spots) == 0 {
+			continue
+		}
+		subject, body := queueNotice(spots)
+		if err := qn.db.AddNotification(memberID, NoticeQueueMoved, subject, body); err != nil {
+			qn.requeue(memberID, pending[memberID])
+			return told, fmt.Errorf("notify member %d: %w", memberID, err)
+		}
+		told++
+		if qn.email == nil {
+			continue
+		}
+		var name string
+		var wants bool
+		err = qn.db.db.QueryRow(`SELECT name, email IS NOT NULL AND email_queue_moved = 1 FROM members WHERE id=?`, memberID).
+			Scan(&name, &wants)
+		if err != nil {
+			return told, err
+		}
+		if wants {
+			notice := Notice{MemberID: memberID, MemberName: name, Kind: NoticeQueueMoved, Subject: subject, Body: body}
+			if err := qn.email.Notify(notice); err != nil {
+				return told, fmt.Errorf("email member %d: %w", memberID, err)
+			}
+		}
+	}
+	return told, nil
+}
+
+// requeue puts back moves a failed Flush couldn't send, for the next.
+func (qn *QueueNotifier) requeue(memberID int64, books map[int64]bool) {
+	qn.mu.Lock()
+	defer qn.mu.Unlock()
+	for bookID := range books {
+		if qn.pending[memberID] == nil {
+			qn.pending[memberID] = make(map[int64]bool)
+		}
+		qn.pending[memberID][bookID] = true
+	}
+}
+
+// queueSpots returns where memberID now stands in the queues of books they
+// are still waiting for, in title order.
+func (d *Database) queueSpots(memberID int64, books map[int64]bool) ([]queueSpot, error) {
+	var spots []queueSpot
+	for bookID := range books {
+		var spot queueSpot
+		err := d.db.QueryRow(`SELECT b.title, (SELECT COUNT(*) FROM reservations o
+		                                       WHERE o.book_id = r.book_id AND o.fulfilled_time IS NULL
+		                                         AND (o.reservation_time < r.reservation_time
+		                                              OR (o.reservation_time = r.reservation_time AND o.id <= r.id)))
+		                      FROM reservations r JOIN books b ON b.id = r.book_id
+		                      WHERE r.book_id=? AND r.member_id=? AND r.fulfilled_time IS NULL`, bookID, memberID).
+			Scan(&spot.title, &spot.position)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		spots = append(spots, spot)
+	}
+	sort.Slice(spots, func(i, j int) bool { return spots[i].title < spots[j].title })
+	return spots, nil
+}
+
+// queueNotice writes the subject and body of a notice about spots.
+func queueNotice(spots []queueSpot) (string, string) {
+	subject := "You've moved up the queue for '" + spots[0].title + "'"
+	if len(spots) > 1 {
+		subject = fmt.Sprintf("You've moved up %d reservation queues", len(spots))
+	}
+	lines := make([]string, len(spots))
+	for i, s := range spots {
+		if s.position == 1 {
+			lines[i] = fmt.Sprintf("'%s': you are next in line.", s.title)
+		} else {
+			lines[i] = fmt.Sprintf("'%s': %s ahead of you.", s.title, plural(s.position-1, "member"))
+		}
+	}
+	return subject, strings.Join(lines, "\n")
+}
+
+// QueueNoticeJob wraps Flush as a Job for the JobRunner; interval is how
+// long moves are collected before members are told.
+func (qn *QueueNotifier) QueueNoticeJob(interval time.Duration) *Job {
+	return &Job{
+		Name:     "queue-notices",
+		Interval: interval,
+		Run: func() error {
+			_, err := qn.Flush()
+			return err
+		},
+	}
+}
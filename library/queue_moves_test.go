@@ -0,0 +1,89 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQueueMoveNotices(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	var moves []QueueMove
+	lm.OnQueueMoved(func(e Event) { moves = append(moves, e.Moves...) })
+	email := &recordingNotifier{}
+	qn := lm.NotifyQueueMoves(email)
+
+	dune, _ := db.AddBook("Dune", "Herbert", "content")
+	emma, _ := db.AddBook("Emma", "Austen", "content")
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+	carol, _ := db.AddMember("Carol", "password")
+	dave, _ := db.AddMember("Dave", "password")
+	db.CheckoutBook(dune, alice)
+	db.CheckoutBook(emma, alice)
+	for _, m := range []int64{bob, carol, dave} {
+		if err := db.ReserveBook(dune, m); err != nil {
+			t.Fatalf("reserve: %v", err)
+		}
+	}
+	db.ReserveBook(emma, bob)
+	db.ReserveBook(emma, dave)
+	if err := db.SetEmailPreferences(dave, EmailPreferences{Email: "dave@example.org", QueueMoved: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bob leaves both queues: Carol and Dave move up Dune's, Dave Emma's
+	if err := db.CancelReservation(dune, bob); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if err := db.CancelReservation(emma, bob); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if len(moves) != 3 || moves[0] != (QueueMove{BookID: dune, MemberID: carol, From: 2, To: 1}) {
+		t.Fatalf("moves = %+v", moves)
+	}
+
+	told, err := qn.Flush()
+	if err != nil || told != 2 {
+		t.Fatalf("flush told %d, %v", told, err)
+	}
+	notes, _ := db.ReadNotifications(dave)
+	if len(notes) != 1 || notes[0].Kind != NoticeQueueMoved || notes[0].Subject != "You've moved up 2 reservation queues" ||
+		notes[0].Body != "'Dune': 1 member ahead of you.\n'Emma': you are next in line." {
+		t.Fatalf("Dave's notices = %+v", notes)
+	}
+	notes, _ = db.ReadNotifications(carol)
+	if len(notes) != 1 || notes[0].Body != "'Dune': you are next in line." {
+		t.Fatalf("Carol's notices = %+v", notes)
+	}
+	if len(email.notices) != 1 || email.notices[0].MemberID != dave {
+		t.Fatalf("emails = %+v", email.notices)
+	}
+
+	// Carol's turn comes, moving Dave up; once told, nothing is sent again
+	moves = nil
+	if _, err := db.ReturnBook(dune); err != nil {
+		t.Fatalf("return: %v", err)
+	}
+	if len(moves) != 1 || moves[0].MemberID != dave || moves[0].To != 1 {
+		t.Fatalf("moves = %+v", moves)
+	}
+	qn.Flush()
+	if told, _ := qn.Flush(); told != 0 {
+		t.Errorf("second flush told %d members", told)
+	}
+	notes, _ = db.ReadNotifications(dave)
+	if len(notes) != 1 || !strings.Contains(notes[0].Body, "'Dune': you are next in line") {
+		t.Fatalf("Dave's notices = %+v", notes)
+	}
+
+	// A queue the member has left by the flush isn't mentioned
+	db.CancelReservation(emma, dave)
+	db.ReserveBook(emma, carol)
+	db.ReserveBook(emma, dave)
+	db.CancelReservation(emma, carol)
+	db.CancelReservation(emma, dave)
+	if told, _ := qn.Flush(); told != 0 {
+		t.Errorf("flush told %d members about queues they left", told)
+	}
+}
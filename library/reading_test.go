@@ -1,10 +1,14 @@
 package library
 
 import (
+	"database/sql"
+	"errors"
 	"io"
+	"math"
 	"os"
 	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 // Mock reader to simulate user input during testing
@@ -175,6 +179,90 @@ func TestGetBookContentChunk(t *testing.T) {
 	}
 }
 
+// FuzzContentChunk checks the offset math behind GetBookContentChunk: any
+// range GetBookContentChunk accepts is safe, chunks of well-formed text are well-formed,
+// and reading page after page gives back the whole text.
+func FuzzContentChunk(f *testing.F) {
+	f.Add("0123456789ABCDEFGHIJ", 3, 4)
+	f.Add("Café naïve 日本語の本 🐉 dragon", 5, 3)
+	f.Add("\xe6\x97\xa5\xff\xfe", 1, 1)
+	f.Add("", 0, 0)
+	f.Fuzz(func(t *testing.T, content string, offset, length int) {
+		offset, length = offset&0xffff, length&0xffff
+		chunk := contentChunk(content, offset, length)
+		if utf8.ValidString(content) && !utf8.ValidString(chunk) {
+			t.Fatalf("chunk %q of %q is not valid UTF-8", chunk, content)
+		}
+		if !strings.Contains(content, chunk) {
+			t.Fatalf("chunk %q is not part of %q", chunk, content)
+		}
+
+		size := 1 + length&0x3f
+		var pages strings.Builder
+		for off := 0; off < len(content); off += size {
+			pages.WriteString(contentChunk(content, off, size))
+		}
+		if pages.String() != content {
+			t.Fatalf("pages of %d bytes give %q, want %q", size, pages.String(), content)
+		}
+	})
+}
+
+// Chunks cut in SQL are the chunks contentChunk cuts from the whole text.
+func TestContentChunkInSQL(t *testing.T) {
+	db := tempDB(t)
+	content := "Café naïve 日本語の本 🐉 dragon \xff\xfe end"
+	bookID, _ := db.AddBook("Mixed", "Author", content)
+	for offset := 0; offset <= len(content)+1; offset++ {
+		for length := 0; length <= 2*utf8.UTFMax; length++ {
+			got, err := db.loadBookContentChunk(bookID, offset, length)
+			if want := contentChunk(content, offset, length); err != nil || got != want {
+				t.Fatalf("%d bytes from %d: %q, %v; want %q", length, offset, got, err, want)
+			}
+		}
+	}
+	if got, err := db.loadBookContentChunk(bookID, 1, math.MaxInt); err != nil || got != content[1:] {
+		t.Fatalf("rest of the text: %q, %v", got, err)
+	}
+
+	emptyID, _ := db.AddBook("Empty", "Author", "")
+	if got, err := db.loadBookContentChunk(emptyID, 0, PageSize); err != nil || got != "" {
+		t.Fatalf("book without text: %q, %v", got, err)
+	}
+	if _, err := db.loadBookContentChunk(emptyID+1, 0, PageSize); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("missing book: %v", err)
+	}
+}
+
+// BenchmarkContentChunk reads a page from the middle of a text of several
+// megabytes, cut in SQL, against reading the whole text and cutting it in
+// Go.
+func BenchmarkContentChunk(b *testing.B) {
+	db := tempDB(b)
+	content := strings.Repeat("Call me Ishmael. Некоторые годы тому назад — 日本語の本. ", 50000)
+	bookID, _ := db.AddBook("Long", "Author", content)
+	offset := len(content) / 2
+
+	b.Run("substr", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := db.loadBookContentChunk(bookID, offset, PageSize); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("whole text", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var text string
+			if err := db.db.QueryRow(`SELECT content FROM book_contents WHERE book_id=?`, bookID).Scan(&text); err != nil {
+				b.Fatal(err)
+			}
+			contentChunk(text, offset, PageSize)
+		}
+	})
+}
+
 func TestReadBookValidation(t *testing.T) {
 	db := tempDB(t)
 	lm := &LibraryManager{db: db}
@@ -472,3 +560,35 @@ func TestReadBookBoundaryConditions(t *testing.T) {
 		t.Errorf("Second chunk = %q, want 'Y'", chunk2)
 	}
 }
+
+func TestFindInBook(t *testing.T) {
+	db := tempDB(t)
+
+	filler := strings.Repeat("Sand and more sand. ", 100) // 2000 bytes
+	content := "The Spice must flow. " + filler + "A worm, spice-laden, rose. Spicy food." + filler
+	bookID, _ := db.AddBook("Dune", "Herbert", content)
+
+	matches, err := db.FindInBook(bookID, "SPICE")
+	if err != nil {
+		t.Fatalf("FindInBook: %v", err)
+	}
+	if len(matches) != 2 || matches[0].Page != 1 || matches[1].Page != 2 || matches[1].Count != 1 {
+		t.Fatalf("expected one match on pages 1 and 2, got %+v", matches)
+	}
+	if !strings.HasPrefix(matches[0].Excerpt, "The [Spice] must flow") {
+		t.Errorf("excerpt = %q", matches[0].Excerpt)
+	}
+
+	if matches, _ := db.FindInBook(bookID, "spic*"); len(matches) != 2 || matches[1].Count != 2 {
+		t.Errorf("expected a prefix to match spicy too, got %+v", matches)
+	}
+	if matches, _ := db.FindInBook(bookID, "worm spice"); len(matches) != 1 || matches[0].Page != 2 {
+		t.Errorf("expected the phrase on page 2, got %+v", matches)
+	}
+	if matches, _ := db.FindInBook(bookID, "sandworm"); len(matches) != 0 {
+		t.Errorf("expected no matches, got %+v", matches)
+	}
+	if _, err := db.FindInBook(bookID, " * "); err == nil {
+		t.Errorf("expected an empty term to be refused")
+	}
+}
@@ -0,0 +1,184 @@
+package library
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReminderDueSoon is the reminders.kind value for pre-due-date courtesy notices.
+const ReminderDueSoon = "due_soon"
+
+// DueLoan describes an open checkout together with its due date.
+type DueLoan struct {
+	CheckoutID int64
+	BookID     int64
+	BookTitle  string
+	MemberID   int64
+	MemberName string
+	DueTime    time.Time
+}
+
+// GetDueSoonCheckouts lists open loans falling due within lead from now that
+// have not yet received a reminder of the given kind.
+func (d *Database) GetDueSoonCheckouts(lead time.Duration, kind string) ([]*DueLoan, error) {
+	query := `SELECT c.id, b.id, b.title, m.id, m.name, c.due_time
+              FROM checkouts c
+              JOIN books b ON c.book_id = b.id
+              JOIN members m ON c.member_id = m.id
+              WHERE c.return_time IS NULL
+                AND c.status = 'active'
+                AND c.due_time IS NOT NULL
+                AND c.due_time > ?
+                AND c.due_time <= datetime(?, ?)
+                AND NOT EXISTS (SELECT 1 FROM reminders r WHERE r.checkout_id = c.id AND r.kind = ?)
+              ORDER BY c.due_time`
+
+	now := d.sqlNow()
+	rows, err := d.db.Query(query, now, now, fmt.Sprintf("+%d seconds", int64(lead.Seconds())), kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var loans []*DueLoan
+	for rows.Next() {
+		var l DueLoan
+		if err := rows.Scan(&l.CheckoutID, &l.BookID, &l.BookTitle, &l.MemberID, &l.MemberName, &l.DueTime); err != nil {
+			return nil, err
+		}
+		loans = append(loans, &l)
+	}
+	return loans, rows.Err()
+}
+
+// ClaimReminder records that a reminder of kind is being sent for checkoutID.
+// It returns false if one was already recorded, so each loan is reminded once.
+func (d *Database) ClaimReminder(checkoutID int64, kind string) (bool, error) {
+	res, err := d.db.Exec(`INSERT OR IGNORE INTO reminders(checkout_id, kind) VALUES(?,?)`, checkoutID, kind)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// ReleaseReminder forgets a claimed reminder so it is retried on the next run.
+func (d *Database) ReleaseReminder(checkoutID int64, kind string) error {
+	_, err := d.db.Exec(`DELETE FROM reminders WHERE checkout_id=? AND kind=?`, checkoutID, kind)
+	return err
+}
+
+// GetOverdueCheckouts lists open loans past their due date that have not yet
+// received a notice of the given kind.
+func (d *Database) GetOverdueCheckouts(kind string) ([]*DueLoan, error) {
+	query := `SELECT c.id, b.id, b.title, m.id, m.name, c.due_time
+              FROM checkouts c
+              JOIN books b ON c.book_id = b.id
+              JOIN members m ON c.member_id = m.id
+              WHERE c.return_time IS NULL
+                AND c.status = 'active'
+                AND c.due_time IS NOT NULL
+                AND c.due_time <= ?
+                AND NOT EXISTS (SELECT 1 FROM reminders r WHERE r.checkout_id = c.id AND r.kind = ?)
+              ORDER BY c.due_time`
+
+	rows, err := d.db.Query(query, d.sqlNow(), kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var loans []*DueLoan
+	for rows.Next() {
+		var l DueLoan
+		if err := rows.Scan(&l.CheckoutID, &l.BookID, &l.BookTitle, &l.MemberID, &l.MemberName, &l.DueTime); err != nil {
+			return nil, err
+		}
+		loans = append(loans, &l)
+	}
+	return loans, rows.Err()
+}
+
+// SendDueReminders notifies every member whose loan falls due within lead.
+// It returns the number of reminders delivered.
+func (lm *LibraryManager) SendDueReminders(n Notifier, lead time.Duration) (int, error) {
+	loans, err := lm.db.GetDueSoonCheckouts(lead, ReminderDueSoon)
+	if err != nil {
+		return 0, fmt.Errorf("find due loans: %w", err)
+	}
+	return lm.sendLoanNotices(n, loans, ReminderDueSoon, func(l *DueLoan) (string, string) {
+		return fmt.Sprintf("'%s' is due soon", l.BookTitle),
+			fmt.Sprintf("Please return or renew '%s' by %s.", l.BookTitle, lm.db.localTime(l.DueTime).Format("2006-01-02 15:04 MST"))
+	})
+}
+
+// SendOverdueNotices notifies every member holding an overdue loan, once per loan.
+func (lm *LibraryManager) SendOverdueNotices(n Notifier) (int, error) {
+	loans, err := lm.db.GetOverdueCheckouts(NoticeOverdue)
+	if err != nil {
+		return 0, fmt.Errorf("find overdue loans: %w", err)
+	}
+	return lm.sendLoanNotices(n, loans, NoticeOverdue, func(l *DueLoan) (string, string) {
+		return fmt.Sprintf("'%s' is overdue", l.BookTitle),
+			fmt.Sprintf("'%s' was due on %s. Please return it as soon as possible.", l.BookTitle, lm.db.localTime(l.DueTime).Format("2006-01-02 15:04 MST"))
+	})
+}
+
+// sendLoanNotices delivers one notice of kind per loan, recording each in the
+// reminders table so repeated runs never notify twice for the same loan.
+func (lm *LibraryManager) sendLoanNotices(n Notifier, loans []*DueLoan, kind string, render func(*DueLoan) (string, string)) (int, error) {
+	sent := 0
+	for _, l := range loans {
+		claimed, err := lm.db.ClaimReminder(l.CheckoutID, kind)
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+
+		subject, body := render(l)
+		notice := Notice{MemberID: l.MemberID, MemberName: l.MemberName, Kind: kind, Subject: subject, Body: body}
+		if err := n.Notify(notice); err != nil {
+			// Let the next run try again instead of silently dropping it.
+			if relErr := lm.db.ReleaseReminder(l.CheckoutID, kind); relErr != nil {
+				return sent, relErr
+			}
+			return sent, fmt.Errorf("notify member %d: %w", l.MemberID, err)
+		}
+		sent++
+		if kind == NoticeOverdue {
+			if err := lm.db.publishLoanEvent(EventOverdue, l.CheckoutID); err != nil {
+				return sent, err
+			}
+		}
+	}
+	return sent, nil
+}
+
+// DueReminderJob wraps SendDueReminders as a Job for the JobRunner.
+func (lm *LibraryManager) DueReminderJob(n Notifier, lead, interval time.Duration) *Job {
+	return &Job{
+		Name:     "due-reminders",
+		Interval: interval,
+		Run: func() error {
+			_, err := lm.SendDueReminders(n, lead)
+			return err
+		},
+	}
+}
+
+// OverdueNoticeJob wraps SendOverdueNotices as a Job for the JobRunner.
+func (lm *LibraryManager) OverdueNoticeJob(n Notifier, interval time.Duration) *Job {
+	return &Job{
+		Name:     "overdue-notices",
+		Interval: interval,
+		Run: func() error {
+			_, err := lm.SendOverdueNotices(n)
+			return err
+		},
+	}
+}
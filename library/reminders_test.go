@@ -0,0 +1,143 @@
+package library
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingNotifier struct {
+	mu      sync.Mutex
+	notices []Notice
+	fail    bool
+}
+
+func (r *recordingNotifier) Notify(n Notice) error {
+	if r.fail {
+		return fmt.Errorf("delivery failed")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notices = append(r.notices, n)
+	return nil
+}
+
+func TestCheckoutSetsDueDate(t *testing.T) {
+	db := tempDB(t)
+	bookID, _ := db.AddBook("Book", "Author", "content")
+	memberID, _ := db.AddMember("Alice", "password")
+
+	if err := db.CheckoutBook(bookID, memberID); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+
+	var due time.Time
+	if err := db.db.QueryRow(`SELECT due_time FROM checkouts WHERE book_id=?`, bookID).Scan(&due); err != nil {
+		t.Fatalf("read due_time: %v", err)
+	}
+	want := time.Now().UTC().Add(defaultLoanDays * 24 * time.Hour)
+	if diff := want.Sub(due); diff < -time.Minute || diff > time.Minute {
+		t.Fatalf("due_time = %v, want about %v", due, want)
+	}
+}
+
+func TestSendDueRemindersDedup(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+
+	soon, _ := db.AddBook("Due Soon", "Author", "content")
+	later, _ := db.AddBook("Due Later", "Author", "content")
+	alice, _ := db.AddMember("Alice", "password")
+	db.CheckoutBook(soon, alice)
+	db.CheckoutBook(later, alice)
+
+	// Pull the first loan forward so it falls inside the reminder window
+	if _, err := db.db.Exec(`UPDATE checkouts SET due_time = datetime('now', '+1 day') WHERE book_id=?`, soon); err != nil {
+		t.Fatalf("adjust due date: %v", err)
+	}
+
+	n := &recordingNotifier{}
+	sent, err := lm.SendDueReminders(n, 48*time.Hour)
+	if err != nil {
+		t.Fatalf("send reminders: %v", err)
+	}
+	if sent != 1 || len(n.notices) != 1 {
+		t.Fatalf("want 1 reminder, got sent=%d notices=%d", sent, len(n.notices))
+	}
+	if n.notices[0].MemberID != alice || n.notices[0].Kind != ReminderDueSoon {
+		t.Fatalf("unexpected notice: %+v", n.notices[0])
+	}
+
+	// A second run must not remind again for the same loan
+	sent, err = lm.SendDueReminders(n, 48*time.Hour)
+	if err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if sent != 0 {
+		t.Fatalf("member reminded twice for the same loan")
+	}
+}
+
+func TestSendDueRemindersRetriesAfterFailure(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+
+	bookID, _ := db.AddBook("Book", "Author", "content")
+	alice, _ := db.AddMember("Alice", "password")
+	db.CheckoutBook(bookID, alice)
+	db.db.Exec(`UPDATE checkouts SET due_time = datetime('now', '+1 day')`)
+
+	if _, err := lm.SendDueReminders(&recordingNotifier{fail: true}, 48*time.Hour); err == nil {
+		t.Fatalf("expected delivery error")
+	}
+
+	n := &recordingNotifier{}
+	if sent, err := lm.SendDueReminders(n, 48*time.Hour); err != nil || sent != 1 {
+		t.Fatalf("failed reminder should be retried, sent=%d err=%v", sent, err)
+	}
+}
+
+func TestRemindersUseTimezone(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	db.SetTimezone(time.FixedZone("XST", -10*3600))
+
+	bookID, _ := db.AddBook("Book", "Author", "content")
+	alice, _ := db.AddMember("Alice", "password")
+	db.CheckoutBook(bookID, alice)
+	// Just after midnight UTC is still the previous evening in XST
+	if _, err := db.db.Exec(`UPDATE checkouts SET due_time = datetime('now', '+1 day', 'start of day', '+30 minutes')`); err != nil {
+		t.Fatalf("adjust due date: %v", err)
+	}
+
+	n := &recordingNotifier{}
+	if sent, err := lm.SendDueReminders(n, 48*time.Hour); err != nil || sent != 1 {
+		t.Fatalf("send reminders: %d %v", sent, err)
+	}
+	due := time.Now().UTC().AddDate(0, 0, 1)
+	want := time.Date(due.Year(), due.Month(), due.Day(), 0, 30, 0, 0, time.UTC).Add(-10 * time.Hour).Format("2006-01-02 15:04")
+	if body := n.notices[0].Body; !strings.Contains(body, want+" XST") {
+		t.Fatalf("due date not in the library's zone: %q, want %s XST", body, want)
+	}
+}
+
+func TestJobRunnerRunDue(t *testing.T) {
+	runs := 0
+	r := NewJobRunner(nil)
+	if err := r.Add(&Job{Name: "count", Interval: time.Hour, Run: func() error { runs++; return nil }}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	now := time.Now()
+	r.RunDue(now)
+	r.RunDue(now.Add(time.Minute))
+	if runs != 1 {
+		t.Fatalf("job ran %d times within its interval", runs)
+	}
+	r.RunDue(now.Add(2 * time.Hour))
+	if runs != 2 {
+		t.Fatalf("job should run again after its interval, runs=%d", runs)
+	}
+}
@@ -0,0 +1,117 @@
+package library
+
+import (
+	"database/sql"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// RetryPolicy controls how write transactions are retried when SQLite
+// reports the database as busy or locked by another connection.
+type RetryPolicy struct {
+	Attempts  int           // total tries, including the first
+	BaseDelay time.Duration // backoff before the second try; doubles each time
+	MaxDelay  time.Duration // cap on a single backoff
+}
+
+// DefaultRetryPolicy rides out short bursts of contention between desks.
+var DefaultRetryPolicy = RetryPolicy{Attempts: 5, BaseDelay: 20 * time.Millisecond, MaxDelay: 500 * time.Millisecond}
+
+// SetRetryPolicy replaces the busy retry policy for write transactions.
+// Attempts below one disables retrying.
+func (d *Database) SetRetryPolicy(p RetryPolicy) { d.retry = p }
+
+// SetMaxOpenConns limits the number of open connections to the database.
+func (d *Database) SetMaxOpenConns(n int) { d.db.SetMaxOpenConns(n) }
+
+// SetMaxIdleConns limits the number of idle connections kept for reuse.
+func (d *Database) SetMaxIdleConns(n int) { d.db.SetMaxIdleConns(n) }
+
+// isBusy reports whether err is SQLite's busy or locked error.
+func isBusy(err error) bool {
+	var serr sqlite3.Error
+	if errors.As(err, &serr) {
+		return serr.Code == sqlite3.ErrBusy || serr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
+
+// backoff returns the jittered delay before retry number attempt (1-based).
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	// Full jitter keeps competing desks from retrying in lockstep
+	return time.Duration(rand.Int64N(int64(delay) + 1))
+}
+
+// inTxResult runs fn in a write transaction and commits it, rolling back if
+// fn fails. The whole transaction is retried according to d's RetryPolicy
+// while SQLite reports the database busy, so fn must not have side effects
+// outside tx. Waiting for other writers and for retries stops when d's
+// context is done.
+func inTxResult[T any](d *Database, fn func(tx *sql.Tx) (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		res, err := runTx(d, fn)
+		if err == nil || !isBusy(err) || attempt >= d.retry.Attempts {
+			return res, err
+		}
+		select {
+		case <-d.db.ctx.Done():
+			return res, d.db.ctx.Err()
+		case <-time.After(d.retry.backoff(attempt)):
+		}
+	}
+}
+
+// inTx is inTxResult for transactions without a result.
+func (d *Database) inTx(fn func(tx *sql.Tx) error) error {
+	_, err := inTxResult(d, func(tx *sql.Tx) (struct{}, error) {
+		return struct{}{}, fn(tx)
+	})
+	return err
+}
+
+func runTx[T any](d *Database, fn func(tx *sql.Tx) (T, error)) (T, error) {
+	var zero T
+	select {
+	case d.writeLock <- struct{}{}:
+	case <-d.db.ctx.Done():
+		return zero, d.db.ctx.Err()
+	}
+	unlock := sync.OnceFunc(func() { <-d.writeLock })
+	defer unlock()
+	tx, err := d.db.Begin()
+	if err != nil {
+		return zero, err
+	}
+	defer tx.Rollback()
+	// Events raised by fn are published only if it commits
+	defer d.events.take(tx)
+
+	res, err := fn(tx)
+	if err != nil {
+		return zero, err
+	}
+	if err := tx.Commit(); err != nil {
+		return zero, err
+	}
+	// Subscribers may write in turn, so the lock is released first
+	events := d.events.take(tx)
+	unlock()
+	d.events.publish(events...)
+	return res, nil
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) SetRetryPolicy(p RetryPolicy) { lm.db.SetRetryPolicy(p) }
+
+func (lm *LibraryManager) SetMaxOpenConns(n int) { lm.db.SetMaxOpenConns(n) }
+
+func (lm *LibraryManager) SetMaxIdleConns(n int) { lm.db.SetMaxIdleConns(n) }
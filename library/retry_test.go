@@ -0,0 +1,78 @@
+package library
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestIsBusy(t *testing.T) {
+	busy := sqlite3.Error{Code: sqlite3.ErrBusy}
+	if !isBusy(busy) || !isBusy(fmt.Errorf("checkout: %w", busy)) {
+		t.Fatalf("SQLITE_BUSY should be retryable")
+	}
+	if !isBusy(sqlite3.Error{Code: sqlite3.ErrLocked}) {
+		t.Fatalf("SQLITE_LOCKED should be retryable")
+	}
+	if isBusy(sqlite3.Error{Code: sqlite3.ErrConstraint}) || isBusy(errors.New("book not found")) {
+		t.Fatalf("other errors must not be retried")
+	}
+}
+
+func TestInTxRetriesBusy(t *testing.T) {
+	db := tempDB(t)
+	db.SetRetryPolicy(RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond})
+
+	calls := 0
+	err := db.inTx(func(tx *sql.Tx) error {
+		calls++
+		if calls < 3 {
+			return sqlite3.Error{Code: sqlite3.ErrBusy}
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("expected success on the third attempt, calls=%d err=%v", calls, err)
+	}
+
+	calls = 0
+	err = db.inTx(func(tx *sql.Tx) error {
+		calls++
+		return sqlite3.Error{Code: sqlite3.ErrBusy}
+	})
+	if !isBusy(err) || calls != 3 {
+		t.Fatalf("expected busy error after 3 attempts, calls=%d err=%v", calls, err)
+	}
+
+	calls = 0
+	err = db.inTx(func(tx *sql.Tx) error {
+		calls++
+		return fmt.Errorf("book not found")
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("non-busy errors should not be retried, calls=%d", calls)
+	}
+}
+
+func TestInTxRollsBackOnError(t *testing.T) {
+	db := tempDB(t)
+	bookID, _ := db.AddBook("Book", "Author", "content")
+
+	err := db.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`UPDATE books SET title='Changed' WHERE id=?`, bookID); err != nil {
+			return err
+		}
+		return fmt.Errorf("abort")
+	})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	b, _ := db.GetBook(bookID)
+	if b.Title != "Book" {
+		t.Fatalf("update should have been rolled back, title=%q", b.Title)
+	}
+}
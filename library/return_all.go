@@ -0,0 +1,107 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ReturnAllResult reports a member's bag emptied at once: the books returned
+// and the items left for the desk's accessory checklist.
+type ReturnAllResult struct {
+	Returned []*CheckInResult
+	AtDesk   []int64 // items with accessories, still on loan
+}
+
+// ReturnAll returns every item memberID has out, in one transaction, and
+// routes each to the next member waiting for it. Holds not yet collected
+// stay waiting. actingID is the member handing the items in, or 0 at the
+// desk. Items with accessories are left on loan for the desk's checklist
+// (CheckInItem).
+func (d *Database) ReturnAll(memberID, actingID int64) (*ReturnAllResult, error) {
+	res, err := inTxResult(d, func(tx *sql.Tx) (*ReturnAllResult, error) {
+		rows, err := tx.Query(`SELECT c.book_id, b.title, c.deposit_cents, COALESCE(c.deposit_status, ''),
+		                              EXISTS(SELECT 1 FROM item_accessories a WHERE a.book_id = c.book_id)
+		                       FROM checkouts c JOIN books b ON b.id = c.book_id
+		                       WHERE c.member_id=? AND c.return_time IS NULL AND c.status<>?
+		                       ORDER BY c.checkout_time, c.id`, memberID, LoanAwaitingPickup)
+		if err != nil {
+			return nil, err
+		}
+		type loan struct {
+			result         *CheckInResult
+			hasAccessories bool
+		}
+		var loans []loan
+		for rows.Next() {
+			r := &CheckInResult{ReturnedBy: memberID}
+			var l loan
+			if err := rows.Scan(&r.BookID, &r.Title, &r.DepositCents, &r.DepositStatus, &l.hasAccessories); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			l.result = r
+			loans = append(loans, l)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		out := &ReturnAllResult{}
+		for _, l := range loans {
+			r := l.result
+			if l.hasAccessories {
+				out.AtDesk = append(out.AtDesk, r.BookID)
+				continue
+			}
+			if _, r.HoldFor, err = d.returnItemTx(tx, r.BookID, nil, actingID); err != nil {
+				return nil, fmt.Errorf("return book %d: %w", r.BookID, err)
+			}
+			if r.DepositStatus == DepositHeld {
+				r.DepositStatus = DepositRefunded
+			}
+			out.Returned = append(out.Returned, r)
+		}
+		return out, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	name := func(id int64) string {
+		if m, err := d.GetMember(id); err == nil {
+			return m.Name
+		}
+		return ""
+	}
+	for _, r := range res.Returned {
+		r.ReturnedByName = name(r.ReturnedBy)
+		if r.HoldFor > 0 {
+			r.HoldForName = name(r.HoldFor)
+		}
+	}
+	return res, nil
+}
+
+// ------------------ Manager helpers ------------------
+
+// ReturnAll returns everything memberID has out on behalf of actingID, who
+// must be the member or one of their delegates.
+func (lm *LibraryManager) ReturnAll(memberID, actingID int64) (*ReturnAllResult, error) {
+	if actingID != memberID {
+		ok, err := lm.db.IsDelegate(memberID, actingID)
+		if err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		if !ok {
+			return nil, fmt.Errorf("you can only return books that you or the members you collect for have checked out")
+		}
+	}
+	return lm.db.ReturnAll(memberID, actingID)
+}
+
+// CheckInAll is the staff-side ReturnAll at the desk. Callers must have
+// authenticated a staff member.
+func (lm *LibraryManager) CheckInAll(memberID int64) (*ReturnAllResult, error) {
+	return lm.db.ReturnAll(memberID, 0)
+}
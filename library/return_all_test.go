@@ -0,0 +1,45 @@
+package library
+
+import "testing"
+
+func TestReturnAll(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	alice, _ := db.AddMember("Alice", "password1")
+	bob, _ := db.AddMember("Bob", "password1")
+	carol, _ := db.AddMember("Carol", "password1")
+	b1, _ := db.AddBook("First", "Author", "")
+	b2, _ := db.AddBook("Second", "Author", "")
+	laptop, _ := db.AddItem("Chromebook", "Acme", "laptop", []string{"charger"})
+
+	db.CheckoutBook(b1, alice)
+	db.CheckoutBook(b2, alice)
+	db.CheckoutBook(laptop, alice)
+	db.ReserveBook(b2, bob)
+
+	if _, err := lm.ReturnAll(alice, carol); err == nil {
+		t.Fatalf("expected an error returning for someone else")
+	}
+
+	res, err := lm.ReturnAll(alice, alice)
+	if err != nil {
+		t.Fatalf("return all: %v", err)
+	}
+	if len(res.Returned) != 2 || len(res.AtDesk) != 1 || res.AtDesk[0] != laptop {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if r := res.Returned[1]; r.BookID != b2 || r.HoldFor != bob || r.HoldForName != "Bob" || r.ReturnedByName != "Alice" {
+		t.Fatalf("second book not routed to Bob: %+v", r)
+	}
+	if res.Returned[0].HoldFor != 0 {
+		t.Fatalf("first book should be reshelved")
+	}
+	if loans, _ := db.GetOpenLoans(alice); len(loans) != 1 || loans[0].BookID != laptop {
+		t.Fatalf("only the laptop should still be out: %+v", loans)
+	}
+
+	// Bob's hold waiting for pickup is not his to return
+	if res, err := lm.CheckInAll(bob); err != nil || len(res.Returned) != 0 {
+		t.Fatalf("check in all for Bob: %+v %v", res, err)
+	}
+}
@@ -0,0 +1,497 @@
+package library
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Search queries use a small language over FTS5 full-text search:
+//
+//	dragon castle        both words, anywhere (AND is implied)
+//	dragon OR wyvern     either word
+//	dragon NOT castle    the first without the second
+//	"winter is coming"   the exact phrase
+//	drag*                words starting with "drag"
+//	"winter is" NEAR/5 coming
+//	                     terms within 5 words of each other (NEAR alone: 10)
+//	(dragon OR wyvern) castle
+//	                     parentheses group
+//	dragon -castle       the first word, leaving out matches of the second
+//
+// Operators are upper case; "and", "or", "not" and "near" in lower case are
+// ordinary words. ParseSearchQuery checks a query and translates it, quoting
+// every term, so punctuation in a search never reaches FTS5 as syntax.
+//
+// Book searches (ParseBookQuery) can also name fields:
+//
+//	title:ring           the word in the title only (also author: and
+//	                     content:, before a word, phrase or group)
+//	available:yes        only books on the shelf (available:no, only
+//	                     those out); it filters the whole search
+
+// defaultNearDistance is FTS5's own default for NEAR without a distance.
+const defaultNearDistance = 10
+
+// SearchSyntaxError describes a malformed search query.
+type SearchSyntaxError struct {
+	Query  string
+	Column int // 1-based position of the problem, in characters
+	Msg    string
+}
+
+func (e *SearchSyntaxError) Error() string {
+	return fmt.Sprintf("search syntax error at column %d: %s", e.Column, e.Msg)
+}
+
+type searchTokenKind int
+
+const (
+	tokEnd searchTokenKind = iota
+	tokWord
+	tokPhrase
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+	tokNear
+	tokMinus     // - before a term to leave out
+	tokField     // title: and the like, before the term it restricts
+	tokAvailable // available:yes or available:no
+)
+
+// bookSearchFields are the names a book search accepts before a colon: the
+// columns of books_fts, and the availability filter.
+var bookSearchFields = map[string]searchTokenKind{
+	"title":     tokField,
+	"author":    tokField,
+	"content":   tokField,
+	"available": tokAvailable,
+}
+
+type searchToken struct {
+	kind   searchTokenKind
+	text   string // word or phrase text; the field name; the operator as typed
+	prefix bool   // word ended in *
+	near   int    // NEAR distance
+	col    int
+}
+
+// ParseSearchQuery validates q and returns the equivalent FTS5 MATCH
+// expression, or a *SearchSyntaxError.
+func ParseSearchQuery(q string) (string, error) {
+	toks, err := lexSearch(q, nil)
+	if err != nil {
+		return "", err
+	}
+	match, _, err := parseSearch(q, toks)
+	return match, err
+}
+
+// BookQuery is a parsed book search.
+type BookQuery struct {
+	Match     string // FTS5 MATCH expression; empty if the query only filters
+	Available *bool  // nil unless available: was given
+
+	terms []searchTerm // what a match shows, for excerpts
+}
+
+// searchTerm is a word or phrase a match must contain, as opposed to one
+// it must not (after NOT or -). Excerpts mark where it occurs.
+type searchTerm struct {
+	text   string
+	prefix bool   // the last word may be the start of a longer one
+	field  string // the only column it matches in; "" for any
+}
+
+// ParseBookQuery validates a book search, which may name fields as well,
+// and returns the MATCH expression and filters. A malformed query returns
+// a *SearchSyntaxError.
+func ParseBookQuery(q string) (*BookQuery, error) {
+	toks, err := lexSearch(q, bookSearchFields)
+	if err != nil {
+		return nil, err
+	}
+	bq := &BookQuery{}
+	if toks, err = bq.takeFilters(q, toks); err != nil {
+		return nil, err
+	}
+	if len(toks) > 1 {
+		if bq.Match, bq.terms, err = parseSearch(q, toks); err != nil {
+			return nil, err
+		}
+	}
+	return bq, nil
+}
+
+// takeFilters records the available: filter and returns toks without it.
+// It filters the whole search, so it must stand beside the other terms,
+// outside parentheses and away from operators.
+func (bq *BookQuery) takeFilters(q string, toks []searchToken) ([]searchToken, error) {
+	var kept []searchToken
+	depth := 0
+	for i, t := range toks {
+		switch t.kind {
+		case tokLParen:
+			depth++
+		case tokRParen:
+			depth--
+		}
+		if t.kind != tokAvailable {
+			kept = append(kept, t)
+			continue
+		}
+		errorAt := func(msg string) error { return &SearchSyntaxError{Query: q, Column: t.col, Msg: msg} }
+		if depth > 0 {
+			return nil, errorAt("available: filters the whole search, so it can't go in parentheses")
+		}
+		if isSearchOperator(toks[i+1].kind) || i > 0 && (isSearchOperator(toks[i-1].kind) || toks[i-1].kind == tokMinus || toks[i-1].kind == tokField) {
+			return nil, errorAt("available: filters the whole search; put it beside the other words, as in dragon available:yes")
+		}
+		if bq.Available != nil {
+			return nil, errorAt("available: is given more than once")
+		}
+		var available bool
+		switch strings.ToLower(t.text) {
+		case "yes", "true":
+			available = true
+		case "no", "false":
+		default:
+			return nil, errorAt("available: takes yes or no")
+		}
+		bq.Available = &available
+	}
+	return kept, nil
+}
+
+func isSearchOperator(k searchTokenKind) bool {
+	return k == tokAnd || k == tokOr || k == tokNot || k == tokNear
+}
+
+// parseSearch parses the tokens of q into a MATCH expression, and returns
+// the terms a match contains.
+func parseSearch(q string, toks []searchToken) (string, []searchTerm, error) {
+	p := &searchParser{query: q, toks: toks}
+	expr, err := p.parseOr()
+	if err != nil {
+		return "", nil, err
+	}
+	switch t := p.peek(); t.kind {
+	case tokEnd:
+		return expr, p.terms, nil
+	case tokRParen:
+		return "", nil, p.errorAt(t, "unmatched )")
+	default:
+		return "", nil, p.errorAt(t, fmt.Sprintf("unexpected %s", t.text))
+	}
+}
+
+// lexSearch splits q into tokens. A word before a colon is a field only if
+// fields names it; otherwise the colon is part of the word.
+func lexSearch(q string, fields map[string]searchTokenKind) ([]searchToken, error) {
+	var toks []searchToken
+	runes := []rune(q)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		col := i + 1
+		switch {
+		case isSearchSpace(r):
+			i++
+		case r == '(':
+			toks = append(toks, searchToken{kind: tokLParen, text: "(", col: col})
+			i++
+		case r == ')':
+			toks = append(toks, searchToken{kind: tokRParen, text: ")", col: col})
+			i++
+		case r == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != '"' {
+				j++
+			}
+			if j == len(runes) {
+				return nil, &SearchSyntaxError{Query: q, Column: col, Msg: `phrase is missing its closing "`}
+			}
+			text := strings.Join(strings.FieldsFunc(string(runes[i+1:j]), isSearchSpace), " ")
+			if text == "" {
+				return nil, &SearchSyntaxError{Query: q, Column: col, Msg: "empty phrase"}
+			}
+			toks = append(toks, searchToken{kind: tokPhrase, text: text, col: col})
+			i = j + 1
+		case r == '-' && i+1 < len(runes) && !isSearchSpace(runes[i+1]):
+			toks = append(toks, searchToken{kind: tokMinus, text: "-", col: col})
+			i++
+		default:
+			j := i
+			for j < len(runes) && !isSearchSpace(runes[j]) && !strings.ContainsRune(`()"`, runes[j]) {
+				j++
+			}
+			word := string(runes[i:j])
+			if k := strings.IndexRune(word, ':'); k > 0 {
+				switch name := strings.ToLower(word[:k]); fields[name] {
+				case tokField:
+					toks = append(toks, searchToken{kind: tokField, text: name, col: col})
+					i += utf8.RuneCountInString(word[:k]) + 1
+					continue
+				case tokAvailable:
+					toks = append(toks, searchToken{kind: tokAvailable, text: word[k+1:], col: col})
+					i = j
+					continue
+				}
+			}
+			tok := searchToken{kind: tokWord, text: word, col: col}
+			switch {
+			case word == "AND":
+				tok.kind = tokAnd
+			case word == "OR":
+				tok.kind = tokOr
+			case word == "NOT":
+				tok.kind = tokNot
+			case word == "NEAR":
+				tok.kind, tok.near = tokNear, defaultNearDistance
+			case strings.HasPrefix(word, "NEAR/"):
+				n, err := strconv.Atoi(word[len("NEAR/"):])
+				if err != nil || n < 0 {
+					return nil, &SearchSyntaxError{Query: q, Column: col, Msg: "NEAR distance must be a whole number, as in NEAR/5"}
+				}
+				tok.kind, tok.near = tokNear, n
+			case strings.HasSuffix(word, "*"):
+				tok.text = strings.TrimRight(word, "*")
+				tok.prefix = true
+				if tok.text == "" {
+					return nil, &SearchSyntaxError{Query: q, Column: col, Msg: "* must follow the start of a word, as in drag*"}
+				}
+			}
+			toks = append(toks, tok)
+			i = j
+		}
+	}
+	return append(toks, searchToken{kind: tokEnd, col: utf8.RuneCountInString(q) + 1}), nil
+}
+
+// isSearchSpace reports whether r separates words in a search. Control
+// characters do, as well as spaces: FTS5 would take a NUL for the end of
+// the query.
+func isSearchSpace(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsControl(r)
+}
+
+type searchParser struct {
+	query   string
+	toks    []searchToken
+	pos     int
+	field   string // the field restricting the term being parsed
+	negated int    // > 0 while parsing a term to leave out
+	terms   []searchTerm
+}
+
+func (p *searchParser) peek() searchToken { return p.toks[p.pos] }
+
+func (p *searchParser) next() searchToken {
+	t := p.toks[p.pos]
+	if t.kind != tokEnd {
+		p.pos++
+	}
+	return t
+}
+
+func (p *searchParser) errorAt(t searchToken, msg string) error {
+	return &SearchSyntaxError{Query: p.query, Column: t.col, Msg: msg}
+}
+
+func startsTerm(k searchTokenKind) bool {
+	return k == tokWord || k == tokPhrase || k == tokLParen || k == tokMinus || k == tokField
+}
+
+// parseOr handles a OR b; it binds loosest.
+func (p *searchParser) parseOr() (string, error) {
+	terms, err := p.parseList(tokOr, p.parseAnd)
+	if err != nil || len(terms) == 1 {
+		return strings.Join(terms, ""), err
+	}
+	return "(" + strings.Join(terms, " OR ") + ")", nil
+}
+
+// parseAnd handles a AND b, and a b with the AND left out. Terms marked
+// with - are left out of the matches of the others.
+func (p *searchParser) parseAnd() (string, error) {
+	var terms, excluded []string
+	var firstMinus searchToken
+	for {
+		if len(terms)+len(excluded) > 0 {
+			if p.peek().kind == tokAnd {
+				op := p.next()
+				if !startsTerm(p.peek().kind) {
+					return "", p.errorAt(op, "AND needs a term after it")
+				}
+			} else if !startsTerm(p.peek().kind) {
+				break
+			}
+		}
+		if p.peek().kind == tokMinus {
+			minus := p.next()
+			if len(excluded) == 0 {
+				firstMinus = minus
+			}
+			p.negated++
+			t, err := p.parsePrimary()
+			p.negated--
+			if err != nil {
+				return "", err
+			}
+			if k := p.peek().kind; k == tokNot || k == tokNear {
+				return "", p.errorAt(minus, "- leaves out one word, phrase or group; put longer terms in parentheses, as in -(a NEAR b)")
+			}
+			excluded = append(excluded, t)
+			continue
+		}
+		t, err := p.parseNot()
+		if err != nil {
+			return "", err
+		}
+		terms = append(terms, t)
+	}
+	if len(terms) == 0 {
+		return "", p.errorAt(firstMinus, "- needs another term to leave matches out of, as in dragon -castle")
+	}
+	expr := terms[0]
+	if len(terms) > 1 {
+		expr = "(" + strings.Join(terms, " AND ") + ")"
+	}
+	switch len(excluded) {
+	case 0:
+		return expr, nil
+	case 1:
+		return "(" + expr + " NOT " + excluded[0] + ")", nil
+	default:
+		return "(" + expr + " NOT (" + strings.Join(excluded, " OR ") + "))", nil
+	}
+}
+
+// parseNot handles a NOT b, which keeps matches of a without b.
+func (p *searchParser) parseNot() (string, error) {
+	terms, err := p.parseList(tokNot, p.parseNear)
+	if err != nil || len(terms) == 1 {
+		return strings.Join(terms, ""), err
+	}
+	return "(" + strings.Join(terms, " NOT ") + ")", nil
+}
+
+// parseList parses operands of sub separated by the binary operator op.
+func (p *searchParser) parseList(op searchTokenKind, sub func() (string, error)) ([]string, error) {
+	first, err := sub()
+	if err != nil {
+		return nil, err
+	}
+	terms := []string{first}
+	for p.peek().kind == op {
+		t := p.next()
+		if !startsTerm(p.peek().kind) {
+			return nil, p.errorAt(t, t.text+" needs a term after it")
+		}
+		if op == tokNot {
+			p.negated++
+		}
+		term, err := sub()
+		if op == tokNot {
+			p.negated--
+		}
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+// parseNear handles a NEAR/n b NEAR/n c, whose operands must be words or
+// phrases.
+func (p *searchParser) parseNear() (string, error) {
+	start := p.peek()
+	first, err := p.parsePrimary()
+	if err != nil || p.peek().kind != tokNear {
+		return first, err
+	}
+	if start.kind == tokLParen {
+		return "", p.errorAt(start, "NEAR works on words and phrases, not groups in parentheses")
+	}
+	if start.kind == tokField {
+		return "", p.errorAt(start, "NEAR works on words and phrases; put the field before a group, as in title:(a NEAR b)")
+	}
+	terms := []string{first}
+	dist := p.peek().near
+	for p.peek().kind == tokNear {
+		op := p.next()
+		if op.near != dist {
+			return "", p.errorAt(op, "use one NEAR distance for a run of terms")
+		}
+		if k := p.peek().kind; k != tokWord && k != tokPhrase {
+			return "", p.errorAt(op, "NEAR needs a word or phrase on both sides")
+		}
+		term, err := p.parsePrimary()
+		if err != nil {
+			return "", err
+		}
+		terms = append(terms, term)
+	}
+	return fmt.Sprintf("NEAR(%s, %d)", strings.Join(terms, " "), dist), nil
+}
+
+func (p *searchParser) parsePrimary() (string, error) {
+	t := p.next()
+	if (t.kind == tokWord || t.kind == tokPhrase) && p.negated == 0 {
+		p.terms = append(p.terms, searchTerm{text: t.text, prefix: t.prefix, field: p.field})
+	}
+	switch t.kind {
+	case tokWord:
+		if t.prefix {
+			return ftsQuote(t.text) + " *", nil
+		}
+		return ftsQuote(t.text), nil
+	case tokPhrase:
+		return ftsQuote(t.text), nil
+	case tokLParen:
+		if p.peek().kind == tokRParen {
+			return "", p.errorAt(t, "empty parentheses")
+		}
+		expr, err := p.parseOr()
+		if err != nil {
+			return "", err
+		}
+		if p.peek().kind != tokRParen {
+			return "", p.errorAt(t, "( is missing its closing )")
+		}
+		p.next()
+		return expr, nil
+	case tokField:
+		if p.field != "" {
+			return "", p.errorAt(t, "a field can't go inside another field's term")
+		}
+		if k := p.peek().kind; k != tokWord && k != tokPhrase && k != tokLParen {
+			return "", p.errorAt(t, t.text+": needs a word, phrase or group after it")
+		}
+		p.field = t.text
+		term, err := p.parsePrimary()
+		p.field = ""
+		if err != nil {
+			return "", err
+		}
+		return t.text + ":" + term, nil
+	case tokMinus:
+		return "", p.errorAt(t, "- leaves a term out of the words beside it, as in dragon -castle")
+	case tokRParen:
+		return "", p.errorAt(t, "unmatched )")
+	case tokEnd:
+		return "", p.errorAt(t, "expected a word or phrase to search for")
+	default:
+		return "", p.errorAt(t, t.text+" needs a term before it")
+	}
+}
+
+// ftsQuote makes s an FTS5 string, so it is matched as text whatever it
+// contains.
+func ftsQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
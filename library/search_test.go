@@ -0,0 +1,177 @@
+package library
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	valid := map[string]string{
+		`dragon`:                       `"dragon"`,
+		`dragon castle`:                `("dragon" AND "castle")`,
+		`dragon OR wyvern castle`:      `("dragon" OR ("wyvern" AND "castle"))`,
+		`dragon NOT castle`:            `("dragon" NOT "castle")`,
+		`"winter is" NEAR/5 coming`:    `NEAR("winter is" "coming", 5)`,
+		`a NEAR b NEAR c`:              `NEAR("a" "b" "c", 10)`,
+		`(dragon OR wyvern) AND drag*`: `(("dragon" OR "wyvern") AND "drag" *)`,
+		`o'brien and "say hi"`:         `("o'brien" AND "and" AND "say hi")`,
+		`title:foo`:                    `"title:foo"`,
+	}
+	for q, want := range valid {
+		got, err := ParseSearchQuery(q)
+		if err != nil || got != want {
+			t.Errorf("ParseSearchQuery(%q) = %q, %v; want %q", q, got, err, want)
+		}
+	}
+
+	invalid := map[string]int{ // query -> column of the error
+		`"winter is`:          1,
+		`dragon OR`:           8,
+		`NOT dragon`:          1,
+		`(dragon`:             1,
+		`dragon)`:             7,
+		`a NEAR/x b`:          3,
+		`(a OR b) NEAR c`:     1,
+		`a NEAR/2 b NEAR/3 c`: 12,
+		`a NEAR (b)`:          3,
+		`""`:                  1,
+		`*`:                   1,
+	}
+	for q, col := range invalid {
+		_, err := ParseSearchQuery(q)
+		var se *SearchSyntaxError
+		if !errors.As(err, &se) || se.Column != col {
+			t.Errorf("ParseSearchQuery(%q): got %v, want error at column %d", q, err, col)
+		}
+	}
+}
+
+func TestSearchBooksPhraseAndNear(t *testing.T) {
+	db := tempDB(t)
+	got, _ := db.AddBook("Got", "Martin", "Winter is coming, said the lord of the north.")
+	db.AddBook("Almanac", "Farmer", "The winter is long. Spring is coming soon enough for the crops and all the fields.")
+
+	books, err := db.SearchBooks(`"winter is coming"`)
+	if err != nil || len(books) != 1 || books[0].ID != got {
+		t.Fatalf("phrase search: %v %v", books, err)
+	}
+	books, err = db.SearchBooks(`"winter is" NEAR/5 coming`)
+	if err != nil || len(books) != 2 {
+		t.Fatalf("near search: %d books, %v", len(books), err)
+	}
+	books, err = db.SearchBooks(`winter NEAR/1 coming`)
+	if err != nil || len(books) != 1 || books[0].ID != got {
+		t.Fatalf("tight near search: %v %v", books, err)
+	}
+	if _, err := db.SearchBooks(`winter NEAR`); err == nil {
+		t.Fatalf("expected syntax error")
+	}
+}
+
+func TestParseBookQuery(t *testing.T) {
+	yes, no := true, false
+	valid := map[string]BookQuery{
+		`title:"ring" author:tolkien -content:elves`: {Match: `((title:"ring" AND author:"tolkien") NOT content:"elves")`},
+		`Title:(ring OR hobbit) drag*`:               {Match: `(title:("ring" OR "hobbit") AND "drag" *)`},
+		`dragon -castle -"high keep"`:                {Match: `("dragon" NOT ("castle" OR "high keep"))`},
+		`x-ray re:think`:                             {Match: `("x-ray" AND "re:think")`},
+		`content:(winter NEAR/2 coming)`:             {Match: `content:NEAR("winter" "coming", 2)`},
+		`dune available:yes`:                         {Match: `"dune"`, Available: &yes},
+		`available:no`:                               {Available: &no},
+	}
+	for q, want := range valid {
+		got, err := ParseBookQuery(q)
+		if err != nil || got.Match != want.Match || (got.Available == nil) != (want.Available == nil) ||
+			got.Available != nil && *got.Available != *want.Available {
+			t.Errorf("ParseBookQuery(%q) = %+v, %v; want %+v", q, got, err, want)
+		}
+	}
+
+	invalid := map[string]int{ // query -> column of the error
+		`-castle`:                    1,
+		`dragon NOT -castle`:         12,
+		`dragon -castle NEAR keep`:   8,
+		`title:`:                     1,
+		`title:(author:x)`:           8,
+		`title:a NEAR b`:             1,
+		`(dune available:yes)`:       7,
+		`dune OR available:yes`:      9,
+		`available:maybe`:            1,
+		`available:yes available:no`: 15,
+	}
+	for q, col := range invalid {
+		_, err := ParseBookQuery(q)
+		var se *SearchSyntaxError
+		if !errors.As(err, &se) || se.Column != col {
+			t.Errorf("ParseBookQuery(%q): got %v, want error at column %d", q, err, col)
+		}
+	}
+}
+
+func TestSearchBooksFieldsAndAvailability(t *testing.T) {
+	db := tempDB(t)
+	member, _ := db.AddMember("Reader", "password1")
+	ring, _ := db.AddBook("The Ring", "Tolkien", "Elves and dwarves set out.")
+	world, _ := db.AddBook("Ringworld", "Niven", "No elves, only a ring around a star.")
+	hobbit, _ := db.AddBook("The Hobbit", "Tolkien", "A ring is found in the dark.")
+	db.CheckoutBook(hobbit, member)
+
+	ids := func(q string) []int64 {
+		t.Helper()
+		books, err := db.SearchBooks(q)
+		if err != nil {
+			t.Fatalf("SearchBooks(%q): %v", q, err)
+		}
+		var got []int64
+		for _, b := range books {
+			got = append(got, b.ID)
+		}
+		sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+		return got
+	}
+	for q, want := range map[string][]int64{
+		`ring`:                          {ring, world, hobbit},
+		`title:ring`:                    {ring},
+		`author:tolkien -content:elves`: {hobbit},
+		`ring available:yes`:            {ring, world},
+		`tolkien available:no`:          {hobbit},
+		`available:no`:                  {hobbit},
+	} {
+		if got := ids(q); !reflect.DeepEqual(got, want) {
+			t.Errorf("SearchBooks(%q) = %v, want %v", q, got, want)
+		}
+	}
+}
+
+// FuzzSearchBookResults checks that any search either runs or is refused
+// with a syntax error pointing into the query: what the parser accepts,
+// FTS5 must accept too.
+func FuzzSearchBookResults(f *testing.F) {
+	for _, q := range []string{`dragon`, `"winter is" NEAR/5 coming`, `(dragon OR wyvern) AND drag*`,
+		`title:(dune -arrakis) available:yes`, `o'brien`, `-castle dragon`, `NEAR/x`, `café "naïve"`, `a:b:c`, "\xff"} {
+		f.Add(q)
+	}
+	db, err := NewDatabase(":memory:")
+	if err != nil {
+		f.Fatal(err)
+	}
+	defer db.Close()
+	db.AddBook("Dune", "Frank Herbert", "A beginning is a very delicate time. Café naïve dragon castle.")
+
+	f.Fuzz(func(t *testing.T, q string) {
+		_, err := db.SearchBookResults(q)
+		var se *SearchSyntaxError
+		switch {
+		case err == nil:
+		case errors.As(err, &se):
+			if se.Column < 1 || se.Column > utf8.RuneCountInString(q)+1 {
+				t.Fatalf("search %q: error at column %d of %d", q, se.Column, utf8.RuneCountInString(q))
+			}
+		default:
+			t.Fatalf("search %q: %v", q, err)
+		}
+	})
+}
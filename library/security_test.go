@@ -0,0 +1,60 @@
+package library
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestForcePasswordReset(t *testing.T) {
+	db := tempDB(t)
+	staff, _ := db.AddMember("Staff", "password1")
+	db.SetMemberAdmin(staff, true)
+	alice, _ := db.AddMember("Alice", "password1")
+	bob, _ := db.AddMember("Bob", "password1")
+
+	// Alice signed in after the incident, Bob only long before it
+	if err := db.AuthenticateMember(alice, "password1"); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	db.db.Exec(`UPDATE members SET last_login_time=datetime('now', '-30 days') WHERE id=?`, bob)
+	aliceToken, _ := db.IssueToken(alice, "")
+	bobToken, _ := db.IssueToken(bob, "")
+
+	since := time.Now().Add(-24 * time.Hour)
+	n, err := db.ForcePasswordReset(staff, &since)
+	if err != nil || n != 1 {
+		t.Fatalf("force reset: n=%d err=%v", n, err)
+	}
+	if err := db.AuthenticateMember(alice, "password1"); !errors.Is(err, ErrPasswordChangeRequired) {
+		t.Fatalf("expected Alice to need a new password, got %v", err)
+	}
+	if err := db.AuthenticateMember(alice, "wrong"); err == nil || errors.Is(err, ErrPasswordChangeRequired) {
+		t.Fatalf("a wrong password must still fail plainly, got %v", err)
+	}
+	if _, err := db.AuthenticateToken(aliceToken); err == nil {
+		t.Fatalf("Alice's token should be revoked")
+	}
+	if err := db.AuthenticateMember(bob, "password1"); err != nil {
+		t.Fatalf("Bob was outside the filter: %v", err)
+	}
+	if _, err := db.AuthenticateToken(bobToken); err != nil {
+		t.Fatalf("Bob's token should survive: %v", err)
+	}
+
+	if err := db.ResetMemberPassword(alice, "password2"); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if err := db.AuthenticateMember(alice, "password2"); err != nil {
+		t.Fatalf("a new password clears the flag: %v", err)
+	}
+
+	if n, _ := db.ForcePasswordReset(staff, nil); n != 3 {
+		t.Fatalf("--all should flag every account, got %d", n)
+	}
+	entries, _ := db.GetAuditLog(10)
+	if len(entries) != 3 || entries[0].Action != AuditForcePasswordReset || entries[0].ActorID != staff ||
+		entries[1].Action != AuditPasswordReset || entries[1].MemberID != alice {
+		t.Fatalf("unexpected audit log: %+v", entries)
+	}
+}
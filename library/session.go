@@ -0,0 +1,158 @@
+package library
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrSessionExpired is returned by a Session that has timed out or been
+// logged out of.
+var ErrSessionExpired = errors.New("session expired, please log in again")
+
+// Session is a member signed in once for a run of operations, so they don't
+// re-enter their password for each one. Its methods act as the member. A
+// session expires after sitting idle for its timeout; each successful use
+// pushes the expiry back.
+type Session struct {
+	lm       *LibraryManager
+	memberID int64
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	expires time.Time
+}
+
+// Login verifies a member's password and opens a session for them that
+// expires after timeout without use. Like AuthenticateMember it returns
+// ErrPasswordChangeRequired, and no session, while the member's password
+// has been expired by staff.
+func (lm *LibraryManager) Login(memberID int64, password string, timeout time.Duration) (*Session, error) {
+	if err := lm.AuthenticateMember(memberID, password); err != nil {
+		return nil, err
+	}
+	return &Session{lm: lm, memberID: memberID, timeout: timeout, expires: time.Now().Add(timeout)}, nil
+}
+
+// MemberID is the member the session acts as.
+func (s *Session) MemberID() int64 { return s.memberID }
+
+// Active reports whether the session can still be used, without extending it.
+func (s *Session) Active() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Now().Before(s.expires)
+}
+
+// Logout ends the session.
+func (s *Session) Logout() {
+	s.mu.Lock()
+	s.expires = time.Time{}
+	s.mu.Unlock()
+}
+
+// Touch checks the session is still live and extends it, returning
+// ErrSessionExpired if not. A session also ends once its member is
+// deactivated, deleted or forgotten, or staff require a new password from
+// them. The methods below call it; callers doing other work as the
+// session's member call it themselves.
+func (s *Session) Touch() error {
+	ok, err := s.lm.db.sessionAllowed(s.memberID)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if !ok {
+		s.expires = time.Time{}
+	}
+	if !now.Before(s.expires) {
+		return ErrSessionExpired
+	}
+	s.expires = now.Add(s.timeout)
+	return nil
+}
+
+func (s *Session) CheckoutBook(bookID int64) error {
+	if err := s.Touch(); err != nil {
+		return err
+	}
+	return s.lm.CheckoutBook(bookID, s.memberID)
+}
+
+func (s *Session) CheckoutBooks(bookIDs []int64) ([]*Loan, error) {
+	if err := s.Touch(); err != nil {
+		return nil, err
+	}
+	return s.lm.CheckoutBooks(bookIDs, s.memberID)
+}
+
+// ReturnBook returns a book the member has (or, as a delegate, one of the
+// members they act for has) and reports who had it and who it passed to
+// from the hold queue; see LibraryManager.ReturnBookWithDetails.
+func (s *Session) ReturnBook(bookID int64) (returnedBy, assignedTo int64, err error) {
+	if err := s.Touch(); err != nil {
+		return 0, 0, err
+	}
+	return s.lm.ReturnBookWithDetails(bookID, s.memberID)
+}
+
+// ReturnAll returns everything memberID has out: the session's own member,
+// or one they collect for as a delegate.
+func (s *Session) ReturnAll(memberID int64) (*ReturnAllResult, error) {
+	if err := s.Touch(); err != nil {
+		return nil, err
+	}
+	return s.lm.ReturnAll(memberID, s.memberID)
+}
+
+func (s *Session) ReserveBook(bookID int64) error {
+	if err := s.Touch(); err != nil {
+		return err
+	}
+	return s.lm.ReserveBook(bookID, s.memberID)
+}
+
+func (s *Session) CancelReservation(bookID int64) error {
+	if err := s.Touch(); err != nil {
+		return err
+	}
+	return s.lm.CancelReservation(bookID, s.memberID)
+}
+
+// ReadBook opens the interactive reader on a book the member has on loan.
+func (s *Session) ReadBook(bookID int64) error {
+	if err := s.Touch(); err != nil {
+		return err
+	}
+	return s.lm.ReadBook(bookID, s.memberID)
+}
+
+func (s *Session) ReturnDigitalLoan(bookID int64) error {
+	if err := s.Touch(); err != nil {
+		return err
+	}
+	return s.lm.ReturnDigitalLoan(bookID, s.memberID)
+}
+
+func (s *Session) GetOpenLoans() ([]*Loan, error) {
+	if err := s.Touch(); err != nil {
+		return nil, err
+	}
+	return s.lm.GetOpenLoans(s.memberID)
+}
+
+func (s *Session) GetHistory() ([]*Loan, error) {
+	if err := s.Touch(); err != nil {
+		return nil, err
+	}
+	return s.lm.GetMemberHistory(s.memberID)
+}
+
+func (s *Session) GetMemberFines() ([]*Fine, error) {
+	if err := s.Touch(); err != nil {
+		return nil, err
+	}
+	return s.lm.GetMemberFines(s.memberID)
+}
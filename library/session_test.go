@@ -0,0 +1,91 @@
+package library
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSession(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	bookID, _ := db.AddBook("Dune", "Frank Herbert", "")
+	alice, _ := db.AddMember("Alice", "password")
+
+	if _, err := lm.Login(alice, "wrong", time.Minute); err == nil {
+		t.Fatalf("expected wrong password to be refused")
+	}
+	s, err := lm.Login(alice, "password", time.Minute)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if err := s.CheckoutBook(bookID); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	loans, err := s.GetOpenLoans()
+	if err != nil || len(loans) != 1 || loans[0].MemberID != alice {
+		t.Fatalf("loans: %v %+v", err, loans)
+	}
+
+	// Idle past the timeout
+	s.expires = time.Now().Add(-time.Second)
+	if _, _, err := s.ReturnBook(bookID); !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("expected expired session, got %v", err)
+	}
+
+	s, _ = lm.Login(alice, "password", time.Minute)
+	if returnedBy, _, err := s.ReturnBook(bookID); err != nil || returnedBy != alice {
+		t.Fatalf("return: %d %v", returnedBy, err)
+	}
+	s.Logout()
+	if s.Active() || !errors.Is(s.ReserveBook(bookID), ErrSessionExpired) {
+		t.Fatalf("expected logged-out session to refuse work")
+	}
+
+	db.ForcePasswordReset(0, nil)
+	if _, err := lm.Login(alice, "password", time.Minute); !errors.Is(err, ErrPasswordChangeRequired) {
+		t.Fatalf("expected password change to be required, got %v", err)
+	}
+}
+
+// A session ends with its member's account, or when staff require a new
+// password, however long it has left to run.
+func TestSessionFollowsAccount(t *testing.T) {
+	db := tempDB(t)
+	lm := &LibraryManager{db: db}
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+	carol, _ := db.AddMember("Carol", "password")
+	dave, _ := db.AddMember("Dave", "password")
+
+	sessions := map[int64]*Session{}
+	for _, id := range []int64{alice, bob, carol, dave} {
+		s, err := lm.Login(id, "password", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions[id] = s
+	}
+
+	db.DeactivateMember(alice, 0)
+	db.DeleteMember(bob, 0)
+	db.ForgetMember(carol, 0)
+	for _, id := range []int64{alice, bob, carol} {
+		if err := sessions[id].Touch(); !errors.Is(err, ErrSessionExpired) {
+			t.Fatalf("member %d: expected the session to end, got %v", id, err)
+		}
+	}
+	if err := sessions[dave].Touch(); err != nil {
+		t.Fatalf("untouched account: %v", err)
+	}
+
+	db.ForcePasswordReset(0, nil)
+	if _, err := sessions[dave].GetOpenLoans(); !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("expected a forced reset to end the session, got %v", err)
+	}
+	// Setting the new password doesn't bring the old session back
+	db.ChangeMemberPassword(dave, "password", "password2")
+	if sessions[dave].Active() {
+		t.Fatalf("expected the session to stay ended")
+	}
+}
@@ -0,0 +1,204 @@
+package library
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sort"
+)
+
+// signatureSize is the number of hash functions in a book's MinHash
+// signature; the similarity estimate is good to about 1/sqrt(signatureSize).
+const signatureSize = 128
+
+// duplicateSimilarity is the similarity above which two books are most
+// likely the same text, such as two editions of one title.
+const duplicateSimilarity = 0.8
+
+// SimilarBook is a book whose text resembles another's.
+type SimilarBook struct {
+	Book *Book `json:"book"`
+	// Similarity estimates the share of vocabulary the two books have in
+	// common (Jaccard similarity, common words left out), from 0 to 1.
+	Similarity float64 `json:"similarity"`
+	// LikelyDuplicate is set when the texts are nearly the same.
+	LikelyDuplicate bool `json:"likely_duplicate"`
+}
+
+// signatureSeeds derive the signature's hash functions from one word hash.
+var signatureSeeds = func() [signatureSize]uint64 {
+	var seeds [signatureSize]uint64
+	for i := range seeds {
+		seeds[i] = mix64(uint64(i))
+	}
+	return seeds
+}()
+
+// mix64 is the splitmix64 finalizer, scrambling x into a well spread hash.
+func mix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// bookSignature computes bookID's MinHash signature over the distinct words
+// of its text, reading it a page at a time. ok is false if the book has no
+// words to sign.
+func (d *Database) bookSignature(bookID int64) (sig [signatureSize]uint32, ok bool, err error) {
+	for i := range sig {
+		sig[i] = ^uint32(0)
+	}
+	_, err = d.scanBookWords(bookID, func(w bookWord) {
+		if stopWords[w.word] {
+			return
+		}
+		h := fnv.New64a()
+		h.Write([]byte(w.word))
+		x := h.Sum64()
+		for i, seed := range signatureSeeds {
+			if v := uint32(mix64(x^seed) >> 32); v < sig[i] {
+				sig[i] = v
+			}
+		}
+		ok = true
+	})
+	return sig, ok, err
+}
+
+// updateSignature recomputes and stores bookID's signature after its text
+// has changed.
+func (d *Database) updateSignature(bookID int64) error {
+	sig, ok, err := d.bookSignature(bookID)
+	if err != nil {
+		return fmt.Errorf("compute book signature: %w", err)
+	}
+	if !ok {
+		_, err := d.db.Exec(`DELETE FROM book_signatures WHERE book_id=?`, bookID)
+		return err
+	}
+	blob := make([]byte, 4*signatureSize)
+	for i, v := range sig {
+		binary.LittleEndian.PutUint32(blob[4*i:], v)
+	}
+	_, err = d.db.Exec(`INSERT INTO book_signatures(book_id, minhash) VALUES(?,?)
+	                    ON CONFLICT(book_id) DO UPDATE SET minhash=excluded.minhash`, bookID, blob)
+	return err
+}
+
+// backfillSignatures signs the books with text but no signature: those
+// written before signatures existed or brought in by a legacy import.
+func (d *Database) backfillSignatures() error {
+	rows, err := d.db.Query(`SELECT b.id FROM books b
+	                         LEFT JOIN book_signatures s ON s.book_id = b.id
+	                         WHERE s.book_id IS NULL
+	                           AND (b.content_ref IS NOT NULL OR EXISTS (SELECT 1 FROM book_contents c WHERE c.book_id = b.id AND c.content != ''))`)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := d.updateSignature(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SimilarBooks returns up to limit books whose text most resembles bookID's,
+// most similar first.
+func (d *Database) SimilarBooks(bookID int64, limit int) ([]*SimilarBook, error) {
+	if _, err := d.GetBook(bookID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("book not found")
+		}
+		return nil, err
+	}
+	if err := d.backfillSignatures(); err != nil {
+		return nil, err
+	}
+
+	var target []byte
+	err := d.db.QueryRow(`SELECT minhash FROM book_signatures WHERE book_id=?`, bookID).Scan(&target)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("book has no text to compare")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.Query(`SELECT book_id, minhash FROM book_signatures WHERE book_id != ?`, bookID)
+	if err != nil {
+		return nil, err
+	}
+	type match struct {
+		bookID     int64
+		similarity float64
+	}
+	var matches []match
+	for rows.Next() {
+		var id int64
+		var sig []byte
+		if err := rows.Scan(&id, &sig); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		same := 0
+		for i := 0; i+4 <= len(sig) && i+4 <= len(target); i += 4 {
+			if binary.LittleEndian.Uint32(sig[i:]) == binary.LittleEndian.Uint32(target[i:]) {
+				same++
+			}
+		}
+		if same > 0 {
+			matches = append(matches, match{id, float64(same) / signatureSize})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].similarity != matches[j].similarity {
+			return matches[i].similarity > matches[j].similarity
+		}
+		return matches[i].bookID < matches[j].bookID
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	similar := make([]*SimilarBook, 0, len(matches))
+	for _, m := range matches {
+		book, err := d.GetBook(m.bookID)
+		if err != nil {
+			return nil, err
+		}
+		similar = append(similar, &SimilarBook{
+			Book:            book,
+			Similarity:      m.similarity,
+			LikelyDuplicate: m.similarity >= duplicateSimilarity,
+		})
+	}
+	return similar, nil
+}
+
+// ------------------ Manager helpers ------------------
+
+// SimilarBooks returns up to limit books whose text most resembles bookID's.
+func (lm *LibraryManager) SimilarBooks(bookID int64, limit int) ([]*SimilarBook, error) {
+	return lm.db.SimilarBooks(bookID, limit)
+}
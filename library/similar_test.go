@@ -0,0 +1,81 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	seaText = `The captain sailed the stormy sea with her crew of sailors, charting
+islands and harbours, fighting storms and whales across the ocean. The mast
+creaked, the anchor dropped near the reef, and the lighthouse keeper waved
+from the rocky shore while gulls circled over the tide.`
+	spaceText = `The astronaut piloted the rocket past distant planets and moons,
+studying stars, comets and galaxies from the orbiting station. Telescopes
+tracked asteroids, nebulae glowed, and mission control radioed gravity
+readings while the capsule drifted through the silent vacuum.`
+	pirateText = `Pirates sailed the sea hunting treasure, fighting rival crews
+and storms. Their captain buried gold on islands near the reef, drew maps of
+harbours, and raised the black flag over the ocean tide.`
+)
+
+func TestSimilarBooks(t *testing.T) {
+	db := tempDB(t)
+	sea, _ := db.AddBook("Sea", "A", seaText)
+	// Another edition: the same text with a word changed
+	edition, _ := db.AddBook("Sea (2nd ed.)", "A", strings.Replace(seaText, "rocky", "stony", 1))
+	space, _ := db.AddBook("Space", "B", spaceText)
+	pirates, _ := db.AddBook("Pirates", "C", pirateText)
+	db.AddBook("Blank", "D", "")
+
+	similar, err := db.SimilarBooks(sea, 10)
+	if err != nil {
+		t.Fatalf("similar: %v", err)
+	}
+	if len(similar) < 2 || similar[0].Book.ID != edition || similar[1].Book.ID != pirates {
+		t.Fatalf("unexpected ranking: %+v", similar)
+	}
+	if !similar[0].LikelyDuplicate || similar[1].LikelyDuplicate {
+		t.Fatalf("duplicate edition not flagged: %+v %+v", similar[0], similar[1])
+	}
+	for _, s := range similar {
+		if s.Book.ID == space && s.Similarity >= similar[1].Similarity {
+			t.Fatalf("unrelated book ranked with read-alikes: %+v", s)
+		}
+	}
+
+	if got, _ := db.SimilarBooks(sea, 1); len(got) != 1 {
+		t.Fatalf("limit not applied: %d results", len(got))
+	}
+	if _, err := db.SimilarBooks(999, 5); err == nil {
+		t.Fatalf("expected an error for a missing book")
+	}
+}
+
+func TestSimilarBooksFollowsContentChanges(t *testing.T) {
+	db := tempDB(t)
+	sea, _ := db.AddBook("Sea", "A", seaText)
+	other, _ := db.AddBook("Other", "B", spaceText)
+	blank, _ := db.AddBook("Blank", "C", "")
+
+	if _, err := db.SimilarBooks(blank, 5); err == nil {
+		t.Fatalf("expected an error for a book without text")
+	}
+
+	if err := db.UpdateBookContent(other, seaText); err != nil {
+		t.Fatalf("update content: %v", err)
+	}
+	similar, err := db.SimilarBooks(sea, 5)
+	if err != nil || len(similar) != 1 || similar[0].Book.ID != other || similar[0].Similarity != 1 {
+		t.Fatalf("signature not refreshed: %v %+v", err, similar)
+	}
+
+	// Books from before signatures existed are signed on first use
+	if _, err := db.db.Exec(`DELETE FROM book_signatures`); err != nil {
+		t.Fatal(err)
+	}
+	similar, err = db.SimilarBooks(sea, 5)
+	if err != nil || len(similar) != 1 || similar[0].Book.ID != other {
+		t.Fatalf("signatures not backfilled: %v %+v", err, similar)
+	}
+}
@@ -0,0 +1,204 @@
+package library
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// suggestCandidates is how many corrections are tried for each word.
+	suggestCandidates = 3
+	// suggestMaxWords caps the misspelled words corrected in one query, so
+	// the combinations tried stay few.
+	suggestMaxWords = 4
+	// suggestMaxTries caps the corrected queries run to find ones that
+	// match something.
+	suggestMaxTries = 20
+)
+
+// spellCandidate is a dictionary term close to a misspelled word.
+type spellCandidate struct {
+	term     string
+	distance int
+	docs     int
+}
+
+// misspelling is a word of a query, at runes [start, end), that is not in
+// the search index, with the terms it may have been meant as.
+type misspelling struct {
+	start, end int
+	candidates []spellCandidate
+}
+
+// SuggestQueries returns up to limit corrections of q, a search that found
+// nothing, that do find books. Words that appear nowhere in the catalog are
+// replaced by the closest title and author terms; the best guesses come
+// first. Operators, phrases and prefix searches are left as typed.
+func (d *Database) SuggestQueries(q string, limit int) ([]string, error) {
+	toks, err := lexSearch(q, bookSearchFields)
+	if err != nil {
+		return nil, nil
+	}
+
+	var words []*misspelling
+	for _, t := range toks {
+		if t.kind != tokWord || t.prefix || strings.IndexFunc(t.text, func(r rune) bool { return !unicode.IsLetter(r) }) >= 0 {
+			continue
+		}
+		word := strings.ToLower(t.text)
+		var known int
+		if err := d.db.QueryRow(`SELECT COUNT(*) FROM books_fts_vocab WHERE term=?`, word).Scan(&known); err != nil {
+			return nil, err
+		}
+		if known > 0 {
+			continue
+		}
+		candidates, err := d.spellCandidates(word)
+		if err != nil {
+			return nil, err
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		start := t.col - 1
+		words = append(words, &misspelling{start, start + len([]rune(t.text)), candidates})
+		if len(words) == suggestMaxWords {
+			break
+		}
+	}
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	// Every combination of candidates, closest overall first
+	type combo struct {
+		picks    []int
+		distance int
+		docs     int
+	}
+	combos := []combo{{}}
+	for _, w := range words {
+		var next []combo
+		for _, c := range combos {
+			for i, cand := range w.candidates {
+				picks := append(append([]int(nil), c.picks...), i)
+				next = append(next, combo{picks, c.distance + cand.distance, c.docs + cand.docs})
+			}
+		}
+		combos = next
+	}
+	sort.SliceStable(combos, func(i, j int) bool {
+		if combos[i].distance != combos[j].distance {
+			return combos[i].distance < combos[j].distance
+		}
+		return combos[i].docs > combos[j].docs
+	})
+
+	runes := []rune(q)
+	var suggestions []string
+	for i, c := range combos {
+		if i == suggestMaxTries || len(suggestions) == limit {
+			break
+		}
+		var sb strings.Builder
+		pos := 0
+		for k, w := range words {
+			sb.WriteString(string(runes[pos:w.start]))
+			sb.WriteString(w.candidates[c.picks[k]].term)
+			pos = w.end
+		}
+		sb.WriteString(string(runes[pos:]))
+		suggestion := sb.String()
+
+		books, err := d.SearchBookSummaries(suggestion)
+		if err != nil {
+			return nil, err
+		}
+		if len(books) > 0 {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	return suggestions, nil
+}
+
+// spellCandidates returns the title and author terms within a small edit
+// distance of word, closest and then most common first.
+func (d *Database) spellCandidates(word string) ([]spellCandidate, error) {
+	n := len([]rune(word))
+	maxDistance := 2
+	if n <= 4 {
+		maxDistance = 1
+	}
+	rows, err := d.db.Query(`SELECT term, SUM(doc) FROM books_fts_vocab
+	                         WHERE col IN ('title', 'author') AND length(term) BETWEEN ? AND ?
+	                         GROUP BY term`, n-maxDistance, n+maxDistance)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []spellCandidate
+	for rows.Next() {
+		var c spellCandidate
+		if err := rows.Scan(&c.term, &c.docs); err != nil {
+			return nil, err
+		}
+		if c.distance = editDistance(word, c.term); c.distance <= maxDistance {
+			candidates = append(candidates, c)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		if a.docs != b.docs {
+			return a.docs > b.docs
+		}
+		return a.term < b.term
+	})
+	if len(candidates) > suggestCandidates {
+		candidates = candidates[:suggestCandidates]
+	}
+	return candidates, nil
+}
+
+// editDistance counts the single-letter insertions, deletions, substitutions
+// and swaps of neighbouring letters that turn a into b.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	// Three rows of the table: two back, one back and the current one
+	prev2 := make([]int, len(t)+1)
+	prev := make([]int, len(t)+1)
+	cur := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		cur[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(t)]
+}
+
+// ------------------ Manager helpers ------------------
+
+// SuggestQueries returns up to limit corrected versions of q, a search that
+// found nothing, that do find books.
+func (lm *LibraryManager) SuggestQueries(q string, limit int) ([]string, error) {
+	return lm.db.SuggestQueries(q, limit)
+}
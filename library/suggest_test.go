@@ -0,0 +1,70 @@
+package library
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSuggestQueries(t *testing.T) {
+	db := tempDB(t)
+	db.AddBook("The Three Musketeers", "Alexandre Dumas", "All for one and one for all.")
+	db.AddBook("Twenty Years After", "Alexandre Dumas", "The musketeers return.")
+	db.AddBook("Treasure Island", "Robert Louis Stevenson", "Fifteen men on the dead man's chest.")
+	db.AddBook("Great Expectations", "Charles Dickens", "Pip meets a convict.")
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"musketers", []string{"musketeers"}},
+		{"threee musketers", []string{"three musketeers"}},
+		{"dumas OR stevensen", []string{"dumas OR stevenson"}},
+		{`"three musketeers" dikens`, nil}, // dickens is in another book
+		{`tresure NOT island`, nil},        // the correction excludes itself
+		{"trasure*", nil},                  // prefix searches are left alone
+		{"expecttions dickens", []string{"expectations dickens"}},
+		{"xylophone", nil},
+	}
+	for _, c := range cases {
+		got, err := db.SuggestQueries(c.query, 3)
+		if err != nil {
+			t.Fatalf("%q: %v", c.query, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %q, want %q", c.query, got, c.want)
+		}
+	}
+}
+
+func TestSuggestQueriesPrefersCommonTerms(t *testing.T) {
+	db := tempDB(t)
+	db.AddBook("Rings of Power", "Tolkien", "")
+	db.AddBook("Kings of War", "Smith", "")
+	db.AddBook("Kings of Peace", "Smith", "")
+
+	got, err := db.SuggestQueries("jings", 3)
+	if err != nil {
+		t.Fatalf("suggest: %v", err)
+	}
+	if want := []string{"kings", "rings"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"musketers", "musketeers", 1},
+		{"teh", "the", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+		{"café", "cafe", 1},
+	}
+	for _, c := range cases {
+		if got := editDistance(c.a, c.b); got != c.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package library
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AccountSummary is what a member is greeted with when they sign in.
+type AccountSummary struct {
+	DueSoon     int           // active loans due within Window
+	Window      time.Duration // how far ahead DueSoon looks
+	Overdue     int
+	HoldsReady  int   // holds waiting in the pickup locker
+	BalanceOwed int64 // outstanding charges, in cents
+}
+
+// GetAccountSummary gathers a member's due, overdue and waiting items and
+// their balance in one query.
+func (d *Database) GetAccountSummary(memberID int64, window time.Duration, now time.Time) (*AccountSummary, error) {
+	horizon := now.Add(window)
+	s := AccountSummary{Window: window}
+	err := d.db.QueryRow(`SELECT
+	        COALESCE(SUM(c.status=? AND c.due_time > ? AND c.due_time <= ?), 0),
+	        COALESCE(SUM(c.status=? AND c.due_time <= ?), 0),
+	        COALESCE(SUM(c.status=?), 0),
+	        (SELECT COALESCE(SUM(amount_cents), 0) FROM fines WHERE member_id=? AND reversed_time IS NULL)
+	      FROM checkouts c
+	      WHERE c.member_id=? AND c.return_time IS NULL`,
+		LoanActive, sqlTime(&now), sqlTime(&horizon),
+		LoanActive, sqlTime(&now),
+		LoanAwaitingPickup,
+		memberID, memberID).
+		Scan(&s.DueSoon, &s.Overdue, &s.HoldsReady, &s.BalanceOwed)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// String renders the summary as one line, e.g. "2 books due in 3 days, 1 hold
+// ready for pickup, $1.50 in fines". It is empty when there is nothing to
+// report.
+func (s *AccountSummary) String() string {
+	var parts []string
+	if s.Overdue > 0 {
+		parts = append(parts, fmt.Sprintf("%s overdue", plural(s.Overdue, "book")))
+	}
+	if s.DueSoon > 0 {
+		days := int(s.Window / (24 * time.Hour))
+		parts = append(parts, fmt.Sprintf("%s due in %s", plural(s.DueSoon, "book"), plural(days, "day")))
+	}
+	if s.HoldsReady > 0 {
+		parts = append(parts, fmt.Sprintf("%s ready for pickup", plural(s.HoldsReady, "hold")))
+	}
+	if s.BalanceOwed > 0 {
+		parts = append(parts, fmt.Sprintf("%s in fines", FormatCents(s.BalanceOwed)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// plural formats a count with its noun, e.g. "1 book", "2 books".
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// ------------------ Manager helpers ------------------
+
+// GetAccountSummary summarizes a member's account as of now.
+func (lm *LibraryManager) GetAccountSummary(memberID int64, window time.Duration) (*AccountSummary, error) {
+	return lm.db.GetAccountSummary(memberID, window, lm.db.now())
+}
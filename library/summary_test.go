@@ -0,0 +1,36 @@
+package library
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccountSummary(t *testing.T) {
+	db := tempDB(t)
+	b1, _ := db.AddBook("Soon", "Author", "")
+	b2, _ := db.AddBook("Later", "Author", "")
+	b3, _ := db.AddBook("Late", "Author", "")
+	alice, _ := db.AddMember("Alice", "password")
+
+	db.CheckoutBook(b1, alice)
+	db.CheckoutBook(b2, alice)
+	db.CheckoutBook(b3, alice)
+	db.db.Exec(`UPDATE checkouts SET due_time=datetime('now', '+2 days') WHERE book_id=?`, b1)
+	db.db.Exec(`UPDATE checkouts SET due_time=datetime('now', '-1 day') WHERE book_id=?`, b3)
+	db.db.Exec(`INSERT INTO fines(member_id, kind, amount_cents) VALUES (?, 'overdue', 150)`, alice)
+
+	s, err := db.GetAccountSummary(alice, 3*24*time.Hour, time.Now())
+	if err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+	want := "1 book overdue, 1 book due in 3 days, $1.50 in fines"
+	if got := s.String(); got != want {
+		t.Fatalf("summary = %q, want %q", got, want)
+	}
+
+	bob, _ := db.AddMember("Bob", "password")
+	s, _ = db.GetAccountSummary(bob, 3*24*time.Hour, time.Now())
+	if s.String() != "" {
+		t.Fatalf("expected nothing to report for Bob, got %q", s)
+	}
+}
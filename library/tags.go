@@ -0,0 +1,152 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// maxTagLength bounds a tag name, which is meant to be a word or two such
+// as "science fiction".
+const maxTagLength = 40
+
+// Tag is a subject or genre, with the number of books carrying it.
+type Tag struct {
+	Name  string `json:"name"`
+	Books int    `json:"books"`
+}
+
+// normalizeTag lower-cases name and collapses its spaces, so the same
+// subject typed differently is one tag.
+func normalizeTag(name string) (string, error) {
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+	if name == "" {
+		return "", fmt.Errorf("tag cannot be empty")
+	}
+	if len(name) > maxTagLength {
+		return "", fmt.Errorf("tag is longer than %d characters", maxTagLength)
+	}
+	return name, nil
+}
+
+// TagBook files bookID under tag, creating the tag if it is new. Tagging a
+// book twice with the same tag is not an error.
+func (d *Database) TagBook(bookID int64, tag string) error {
+	name, err := normalizeTag(tag)
+	if err != nil {
+		return err
+	}
+	return d.inTx(func(tx *sql.Tx) error {
+		var exists int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM books WHERE id=?`, bookID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists == 0 {
+			return fmt.Errorf("book not found")
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO tags(name) VALUES(?)`, name); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`INSERT OR IGNORE INTO book_tags(book_id, tag_id) SELECT ?, id FROM tags WHERE name=?`, bookID, name)
+		return err
+	})
+}
+
+// UntagBook takes tag off bookID. A tag left on no books is dropped.
+func (d *Database) UntagBook(bookID int64, tag string) error {
+	name, err := normalizeTag(tag)
+	if err != nil {
+		return err
+	}
+	return d.inTx(func(tx *sql.Tx) error {
+		res, err := tx.Exec(`DELETE FROM book_tags WHERE book_id=? AND tag_id=(SELECT id FROM tags WHERE name=?)`, bookID, name)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("book %d is not tagged %q", bookID, name)
+		}
+		_, err = tx.Exec(`DELETE FROM tags WHERE name=? AND NOT EXISTS (SELECT 1 FROM book_tags WHERE tag_id=tags.id)`, name)
+		return err
+	})
+}
+
+// GetBooksByTag lists the books filed under tag, in catalog order, without
+// their content.
+func (d *Database) GetBooksByTag(tag string) ([]*Book, error) {
+	name, err := normalizeTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.db.Query(`SELECT `+bookListColumns+`
+                             FROM books b
+                             JOIN book_tags bt ON bt.book_id = b.id
+                             JOIN tags t ON t.id = bt.tag_id
+                             WHERE t.name=?
+                             ORDER BY b.id`, name)
+	if err != nil {
+		return nil, err
+	}
+	return scanBooks(rows)
+}
+
+// GetBookTags returns bookID's tags in alphabetical order.
+func (d *Database) GetBookTags(bookID int64) ([]string, error) {
+	rows, err := d.db.Query(`SELECT t.name FROM tags t JOIN book_tags bt ON bt.tag_id = t.id
+                             WHERE bt.book_id=? ORDER BY t.name`, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tags []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tags = append(tags, name)
+	}
+	return tags, rows.Err()
+}
+
+// GetTags lists every tag in use with its book count, alphabetically.
+func (d *Database) GetTags() ([]*Tag, error) {
+	rows, err := d.db.Query(`SELECT t.name, COUNT(bt.book_id)
+                             FROM tags t JOIN book_tags bt ON bt.tag_id = t.id
+                             GROUP BY t.id ORDER BY t.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tags []*Tag
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.Name, &t.Books); err != nil {
+			return nil, err
+		}
+		tags = append(tags, &t)
+	}
+	return tags, rows.Err()
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) TagBook(bookID int64, tag string) error {
+	return lm.db.TagBook(bookID, tag)
+}
+
+func (lm *LibraryManager) UntagBook(bookID int64, tag string) error {
+	return lm.db.UntagBook(bookID, tag)
+}
+
+func (lm *LibraryManager) GetBooksByTag(tag string) ([]*Book, error) {
+	return lm.db.GetBooksByTag(tag)
+}
+
+func (lm *LibraryManager) GetBookTags(bookID int64) ([]string, error) {
+	return lm.db.GetBookTags(bookID)
+}
+
+func (lm *LibraryManager) GetTags() ([]*Tag, error) {
+	return lm.db.GetTags()
+}
@@ -0,0 +1,46 @@
+package library
+
+import "testing"
+
+func TestTags(t *testing.T) {
+	db := tempDB(t)
+	dune, _ := db.AddBook("Dune", "Herbert", "content")
+	emma, _ := db.AddBook("Emma", "Austen", "content")
+
+	if err := db.TagBook(dune, "  Science   Fiction "); err != nil {
+		t.Fatalf("tag: %v", err)
+	}
+	db.TagBook(dune, "classics")
+	db.TagBook(emma, "Classics")
+	// Tagging twice is harmless
+	if err := db.TagBook(emma, "classics"); err != nil {
+		t.Fatalf("tag again: %v", err)
+	}
+	if err := db.TagBook(999, "classics"); err == nil {
+		t.Fatalf("tagged a missing book")
+	}
+	if err := db.TagBook(dune, " "); err == nil {
+		t.Fatalf("accepted an empty tag")
+	}
+
+	books, err := db.GetBooksByTag("CLASSICS")
+	if err != nil || len(books) != 2 || books[0].ID != dune || books[1].ID != emma {
+		t.Fatalf("classics: %+v %v", books, err)
+	}
+	tags, _ := db.GetBookTags(dune)
+	if len(tags) != 2 || tags[0] != "classics" || tags[1] != "science fiction" {
+		t.Fatalf("dune tags = %q", tags)
+	}
+
+	if err := db.UntagBook(dune, "science fiction"); err != nil {
+		t.Fatalf("untag: %v", err)
+	}
+	if err := db.UntagBook(dune, "science fiction"); err == nil {
+		t.Fatalf("untagged a tag the book doesn't have")
+	}
+	// The emptied tag is gone from the list
+	all, _ := db.GetTags()
+	if len(all) != 1 || *all[0] != (Tag{Name: "classics", Books: 2}) {
+		t.Fatalf("tags = %+v", all)
+	}
+}
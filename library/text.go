@@ -0,0 +1,76 @@
+package library
+
+import "unicode/utf8"
+
+// Truncate shortens s to at most width characters, ending it with "..."
+// when there is room, so it fits a column of that width. It counts and
+// cuts whole characters, never bytes of one.
+func Truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	if width <= 3 {
+		return string(runes[:max(width, 0)])
+	}
+	return string(runes[:width-3]) + "..."
+}
+
+// pageCarry is how far past its PageSize bytes a page runs to finish the
+// word it would otherwise cut in two; a longer word is cut anyway.
+const pageCarry = 40
+
+// pageText cuts a page from window, text whose byte lead is the page's
+// nominal start and which runs pageCarry+utf8.UTFMax bytes past its
+// nominal end, or to the end of the content. Both ends move forward with
+// pageBreak, so consecutive pages meet after the space between two words
+// and together make up the whole content. lead is 0 for the first page
+// and otherwise 1: the byte before the page says whether it starts
+// mid-word.
+func pageText(window string, lead, length int) string {
+	if lead >= len(window) {
+		return ""
+	}
+	start := lead
+	if lead > 0 {
+		start = pageBreak(window, lead)
+	}
+	end := max(pageBreak(window, min(lead+length, len(window))), start)
+	return window[start:end]
+}
+
+// pageBreak returns i if white space comes before it, or otherwise the
+// start of the next word, so pages don't split words and each begins with
+// one. A word that runs more than pageCarry bytes past i, or to the end of
+// s, is split at the first character boundary from i instead. Words are
+// told apart by ASCII white space alone, which no byte of a multi-byte
+// character can be mistaken for.
+func pageBreak(s string, i int) int {
+	if i <= 0 || i >= len(s) {
+		return i
+	}
+	for j := i; j < len(s) && j-i <= pageCarry; j++ {
+		if isSpaceByte(s[j-1]) {
+			return j
+		}
+	}
+	return runeStartAt(s, i)
+}
+
+func isSpaceByte(b byte) bool {
+	switch b {
+	case ' ', '\t', '\n', '\r', '\v', '\f':
+		return true
+	}
+	return false
+}
+
+// runeStartAt returns i, or the start of the next character if i falls
+// inside one. In invalid UTF-8 it moves past at most utf8.UTFMax-1 bytes,
+// and never from the start of s.
+func runeStartAt(s string, i int) int {
+	for n := 1; n < utf8.UTFMax && i > 0 && i < len(s) && !utf8.RuneStart(s[i]); n++ {
+		i++
+	}
+	return i
+}
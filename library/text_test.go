@@ -0,0 +1,122 @@
+package library
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	for _, tc := range []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"Dune", 10, "Dune"},
+		{"The Left Hand of Darkness", 10, "The Lef..."},
+		{"日本語の本を読む", 6, "日本語..."},
+		{"Café naïve", 7, "Café..."},
+		{"🐉🐉🐉🐉", 3, "🐉🐉🐉"},
+		{"🐉🐉🐉🐉", 0, ""},
+		{"Dune", -1, ""},
+	} {
+		if got := Truncate(tc.s, tc.width); got != tc.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tc.s, tc.width, got, tc.want)
+		}
+	}
+}
+
+// contentPage cuts page n from the whole of content the way GetBookPage
+// cuts it from what it reads.
+func contentPage(content string, n, size int) string {
+	start := (n - 1) * size
+	lead := min(start, 1)
+	from := min(start-lead, len(content))
+	return pageText(content[from:min(from+lead+size+pageCarry+utf8.UTFMax, len(content))], lead, size)
+}
+
+// FuzzPageText checks that pages of any text make up the text, split no
+// character of well-formed text, and split no word shorter than pageCarry.
+func FuzzPageText(f *testing.F) {
+	f.Add("Call me Ishmael. Some years ago, never mind how long precisely.", 7)
+	f.Add("Некоторые годы тому назад — 日本語の本 🐉 dragon", 9)
+	f.Add(strings.Repeat("日本語", 30), 5)
+	f.Add("\xe6\x97\xa5\xff\xfe a", 1)
+	f.Add("", 3)
+	f.Fuzz(func(t *testing.T, content string, size int) {
+		size = pageCarry + 1 + size&0xff
+		var pages []string
+		for n := 1; (n-1)*size < len(content); n++ {
+			pages = append(pages, contentPage(content, n, size))
+		}
+		if got := strings.Join(pages, ""); got != content {
+			t.Fatalf("pages of %d bytes give %q, want %q", size, got, content)
+		}
+		if !utf8.ValidString(content) {
+			return
+		}
+		b := 0
+		for i, p := range pages {
+			if !utf8.ValidString(p) {
+				t.Fatalf("page %d, %q, is not valid UTF-8", i+1, p)
+			}
+			b += len(p)
+			if b == 0 || b == len(content) || isSpaceByte(content[b-1]) || isSpaceByte(content[b]) {
+				continue
+			}
+			// A word split between pages is one too long to carry over,
+			// or the one the text ends with
+			end := strings.IndexFunc(content[b:], isSpaceRune)
+			if end >= 0 && b+end+1 < len(content) && end < pageCarry-utf8.UTFMax {
+				start := strings.LastIndexFunc(content[:b], isSpaceRune) + 1
+				t.Fatalf("pages %d and %d split %q", i+1, i+2, content[start:b+end])
+			}
+		}
+	})
+}
+
+func isSpaceRune(r rune) bool { return r < utf8.RuneSelf && isSpaceByte(byte(r)) }
+
+func TestGetBookPage(t *testing.T) {
+	db := tempDB(t)
+	// Words of two and three bytes a character, with one straddling the
+	// end of each page
+	word := "Некоторые 日本語の本 "
+	content := strings.Repeat(word, 3*PageSize/len(word))
+	bookID, _ := db.AddBook("Unicode", "Author", content)
+
+	var pages []string
+	for n := 1; (n-1)*PageSize < len(content); n++ {
+		page, err := db.GetBookPage(bookID, n)
+		if err != nil {
+			t.Fatalf("page %d: %v", n, err)
+		}
+		if want := contentPage(content, n, PageSize); page != want {
+			t.Fatalf("page %d = %q..., want %q...", n, Truncate(page, 20), Truncate(want, 20))
+		}
+		if !utf8.ValidString(page) {
+			t.Fatalf("page %d is not valid UTF-8", n)
+		}
+		if n > 1 && !strings.HasPrefix(page, "Некоторые") && !strings.HasPrefix(page, "日本語の本") {
+			t.Fatalf("page %d starts mid-word: %q", n, Truncate(page, 20))
+		}
+		pages = append(pages, page)
+	}
+	if strings.Join(pages, "") != content {
+		t.Fatalf("pages don't make up the text")
+	}
+	if page, err := db.GetBookPage(bookID, len(pages)+1); err != nil || page != "" {
+		t.Fatalf("page past the end: %q, %v", page, err)
+	}
+	if _, err := db.GetBookPage(bookID, 0); err != ErrPageOutOfRange {
+		t.Fatalf("page 0: %v", err)
+	}
+
+	// Cached pages are dropped with the text they came from
+	db.EnableCache(10)
+	first, _ := db.GetBookPage(bookID, 1)
+	db.UpdateBookContent(bookID, "A new text.")
+	if page, _ := db.GetBookPage(bookID, 1); page != "A new text." || page == first {
+		t.Fatalf("page after update = %q", page)
+	}
+}
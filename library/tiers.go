@@ -0,0 +1,140 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Built-in membership tiers.
+const (
+	TierAdult  = "adult"
+	TierChild  = "child"
+	TierSenior = "senior"
+	TierStaff  = "staff"
+)
+
+// TierPolicy holds the circulation rules for a membership tier.
+type TierPolicy struct {
+	Name            string `json:"name"`
+	LoanLimit       int    `json:"loan_limit"`
+	LoanDays        int    `json:"loan_days"`
+	FineCentsPerDay int64  `json:"fine_cents_per_day"`
+}
+
+// GetTierPolicy returns the policy for tier.
+func (d *Database) GetTierPolicy(tier string) (*TierPolicy, error) {
+	var p TierPolicy
+	err := d.db.QueryRow(`SELECT name, loan_limit, loan_days, fine_cents_per_day FROM membership_tiers WHERE name=?`, tier).
+		Scan(&p.Name, &p.LoanLimit, &p.LoanDays, &p.FineCentsPerDay)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("unknown membership tier %q", tier)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetTierPolicies lists every membership tier.
+func (d *Database) GetTierPolicies() ([]*TierPolicy, error) {
+	rows, err := d.db.Query(`SELECT name, loan_limit, loan_days, fine_cents_per_day FROM membership_tiers ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []*TierPolicy
+	for rows.Next() {
+		var p TierPolicy
+		if err := rows.Scan(&p.Name, &p.LoanLimit, &p.LoanDays, &p.FineCentsPerDay); err != nil {
+			return nil, err
+		}
+		policies = append(policies, &p)
+	}
+	return policies, rows.Err()
+}
+
+// SetTierPolicy changes the loan limit, loan period and fine rate of an
+// existing tier. Open loans keep the due dates they were issued with.
+func (d *Database) SetTierPolicy(p TierPolicy) error {
+	if p.LoanLimit < 1 || p.LoanDays < 1 || p.FineCentsPerDay < 0 {
+		return fmt.Errorf("invalid policy for %q: loan limit and loan days must be positive and the fine not negative", p.Name)
+	}
+	res, err := d.db.Exec(`UPDATE membership_tiers SET loan_limit=?, loan_days=?, fine_cents_per_day=? WHERE name=?`,
+		p.LoanLimit, p.LoanDays, p.FineCentsPerDay, p.Name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("unknown membership tier %q", p.Name)
+	}
+	return nil
+}
+
+// SetMemberTier moves memberID to another membership tier. Open loans keep
+// the due dates they were issued with.
+func (d *Database) SetMemberTier(memberID int64, tier string) error {
+	if _, err := d.GetTierPolicy(tier); err != nil {
+		return err
+	}
+	res, err := d.db.Exec(`UPDATE members SET tier=? WHERE id=?`, tier, memberID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("member with ID %d not found", memberID)
+	}
+	return nil
+}
+
+// checkLoanLimit fails if memberID already holds as many open loans as their
+// tier allows.
+func checkLoanLimit(tx *sql.Tx, memberID int64) error {
+	return checkLoanLimitFor(tx, memberID, 1)
+}
+
+// checkLoanLimitFor fails if memberID can't take n more loans.
+func checkLoanLimitFor(tx *sql.Tx, memberID int64, n int) error {
+	var open, limit int
+	err := tx.QueryRow(`SELECT
+                            (SELECT COUNT(*) FROM checkouts WHERE member_id = m.id AND return_time IS NULL),
+                            COALESCE(t.loan_limit, -1)
+                        FROM members m LEFT JOIN membership_tiers t ON m.tier = t.name
+                        WHERE m.id = ?`, memberID).Scan(&open, &limit)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("member not found")
+	}
+	if err != nil {
+		return err
+	}
+	if limit >= 0 && open+n > limit {
+		if n > 1 {
+			return fmt.Errorf("%d books would pass the loan limit of %d (%d already out)", n, limit, open)
+		}
+		return fmt.Errorf("loan limit reached (%d books)", limit)
+	}
+	return nil
+}
+
+// ------------------ Manager helpers ------------------
+
+// AddMemberWithTier creates a member in the given membership tier.
+func (lm *LibraryManager) AddMemberWithTier(name, password, tier string) (int64, error) {
+	return lm.db.AddMemberWithTier(name, password, tier)
+}
+
+func (lm *LibraryManager) GetTierPolicies() ([]*TierPolicy, error) { return lm.db.GetTierPolicies() }
+
+func (lm *LibraryManager) GetTierPolicy(tier string) (*TierPolicy, error) {
+	return lm.db.GetTierPolicy(tier)
+}
+
+func (lm *LibraryManager) SetTierPolicy(p TierPolicy) error { return lm.db.SetTierPolicy(p) }
+
+func (lm *LibraryManager) SetMemberTier(memberID int64, tier string) error {
+	return lm.db.SetMemberTier(memberID, tier)
+}
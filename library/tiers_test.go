@@ -0,0 +1,79 @@
+package library
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTierPoliciesApplyToCheckouts(t *testing.T) {
+	db := tempDB(t)
+	child, err := db.AddMemberWithTier("Kid", "password", TierChild)
+	if err != nil {
+		t.Fatalf("add child member: %v", err)
+	}
+	if _, err := db.AddMemberWithTier("Nobody", "password", "platinum"); err == nil {
+		t.Fatalf("unknown tier should be rejected")
+	}
+
+	policy, _ := db.GetTierPolicy(TierChild)
+	var books []int64
+	for i := 0; i <= policy.LoanLimit; i++ {
+		id, _ := db.AddBook(fmt.Sprintf("Book %d", i), "Author", "content")
+		books = append(books, id)
+	}
+
+	for _, id := range books[:policy.LoanLimit] {
+		if err := db.CheckoutBook(id, child); err != nil {
+			t.Fatalf("checkout within limit: %v", err)
+		}
+	}
+	if err := db.CheckoutBook(books[policy.LoanLimit], child); err == nil {
+		t.Fatalf("checkout beyond the tier loan limit should fail")
+	}
+
+	loans, _ := db.GetOpenLoans(child)
+	want := time.Now().UTC().Add(time.Duration(policy.LoanDays) * 24 * time.Hour)
+	if diff := want.Sub(loans[0].DueTime); diff < -time.Minute || diff > time.Minute {
+		t.Fatalf("due date should follow the child loan period, got %v want %v", loans[0].DueTime, want)
+	}
+	if loans[0].FineCentsPerDay != policy.FineCentsPerDay {
+		t.Fatalf("fine rate = %d, want %d", loans[0].FineCentsPerDay, policy.FineCentsPerDay)
+	}
+}
+
+func TestSetMemberTier(t *testing.T) {
+	db := tempDB(t)
+	alice, _ := db.AddMember("Alice", "password")
+
+	m, _ := db.GetMember(alice)
+	if m.Tier != TierAdult {
+		t.Fatalf("default tier = %q, want adult", m.Tier)
+	}
+	if err := db.SetMemberTier(alice, TierSenior); err != nil {
+		t.Fatalf("set tier: %v", err)
+	}
+	if m, _ = db.GetMember(alice); m.Tier != TierSenior {
+		t.Fatalf("tier = %q, want senior", m.Tier)
+	}
+	if err := db.SetMemberTier(alice, "gold"); err == nil {
+		t.Fatalf("unknown tier should be rejected")
+	}
+}
+
+func TestSetTierPolicy(t *testing.T) {
+	db := tempDB(t)
+	want := TierPolicy{Name: TierAdult, LoanLimit: 3, LoanDays: 7, FineCentsPerDay: 0}
+	if err := db.SetTierPolicy(want); err != nil {
+		t.Fatalf("set policy: %v", err)
+	}
+	if p, _ := db.GetTierPolicy(TierAdult); *p != want {
+		t.Fatalf("policy = %+v, want %+v", p, want)
+	}
+	if err := db.SetTierPolicy(TierPolicy{Name: "gold", LoanLimit: 1, LoanDays: 1}); err == nil {
+		t.Fatalf("unknown tier should be rejected")
+	}
+	if err := db.SetTierPolicy(TierPolicy{Name: TierAdult, LoanLimit: 0, LoanDays: 7}); err == nil {
+		t.Fatalf("zero loan limit should be rejected")
+	}
+}
@@ -0,0 +1,40 @@
+package library
+
+import "time"
+
+// Times are stored as SQLite's own UTC text, "YYYY-MM-DD HH:MM:SS": it is
+// what CURRENT_TIMESTAMP and datetime('now') produce, and queries compare
+// stored times against those as strings, so times written from Go must take
+// the same form. The driver reads them back as UTC. Exports write RFC 3339,
+// and anything shown to people is converted to the configured zone first.
+
+// sqlTimeLayout is the layout of times stored in the database.
+const sqlTimeLayout = "2006-01-02 15:04:05"
+
+// sqlTime formats t the way SQLite's datetime() stores it, or NULL.
+func sqlTime(t *time.Time) interface{} {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(sqlTimeLayout)
+}
+
+// SetTimezone sets the zone dates and times are written in for members,
+// such as due dates in notices; nil means the machine's local zone.
+func (d *Database) SetTimezone(loc *time.Location) { d.location = loc }
+
+// zone returns the zone set with SetTimezone.
+func (d *Database) zone() *time.Location {
+	if d.location == nil {
+		return time.Local
+	}
+	return d.location
+}
+
+// localTime returns t in the zone set with SetTimezone.
+func (d *Database) localTime(t time.Time) time.Time { return t.In(d.zone()) }
+
+// ------------------ Manager helpers ------------------
+
+// SetTimezone sets the zone dates and times are written in for members.
+func (lm *LibraryManager) SetTimezone(loc *time.Location) { lm.db.SetTimezone(loc) }
@@ -0,0 +1,78 @@
+package library
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+)
+
+// tokenBytes is the amount of randomness in an API token's secret.
+const tokenBytes = 32
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueToken creates an API token for memberID. A non-empty scope (the name
+// of the library that issued it) is prefixed to the token as "scope." so
+// servers hosting several libraries can tell where a token belongs. Only a
+// hash of the token is stored; the token itself cannot be recovered.
+func (d *Database) IssueToken(memberID int64, scope string) (string, error) {
+	if _, err := d.GetMember(memberID); err != nil {
+		return "", fmt.Errorf("member with ID %d not found", memberID)
+	}
+	secret := make([]byte, tokenBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(secret)
+	if scope != "" {
+		token = scope + "." + token
+	}
+	if _, err := d.db.Exec(`INSERT INTO api_tokens(member_id, token_hash) VALUES(?,?)`, memberID, hashToken(token)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// AuthenticateToken returns the member an unrevoked API token was issued to.
+func (d *Database) AuthenticateToken(token string) (int64, error) {
+	var memberID int64
+	err := d.db.QueryRow(`SELECT member_id FROM api_tokens WHERE token_hash=? AND revoked_time IS NULL`, hashToken(token)).
+		Scan(&memberID)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("authentication failed: invalid or revoked token")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("database error during authentication: %w", err)
+	}
+	return memberID, nil
+}
+
+// RevokeTokens revokes every API token issued to memberID and returns how
+// many were still active.
+func (d *Database) RevokeTokens(memberID int64) (int, error) {
+	res, err := d.db.Exec(`UPDATE api_tokens SET revoked_time=CURRENT_TIMESTAMP WHERE member_id=? AND revoked_time IS NULL`, memberID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) IssueToken(memberID int64, scope string) (string, error) {
+	return lm.db.IssueToken(memberID, scope)
+}
+
+func (lm *LibraryManager) AuthenticateToken(token string) (int64, error) {
+	return lm.db.AuthenticateToken(token)
+}
+
+func (lm *LibraryManager) RevokeTokens(memberID int64) (int, error) {
+	return lm.db.RevokeTokens(memberID)
+}
@@ -0,0 +1,41 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAPITokens(t *testing.T) {
+	db := tempDB(t)
+	alice, _ := db.AddMember("Alice", "password")
+
+	token, err := db.IssueToken(alice, "branch")
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+	if !strings.HasPrefix(token, "branch.") {
+		t.Fatalf("token should carry its scope, got %q", token)
+	}
+	if id, err := db.AuthenticateToken(token); err != nil || id != alice {
+		t.Fatalf("authenticate token: id=%d err=%v", id, err)
+	}
+	if _, err := db.AuthenticateToken(token + "x"); err == nil {
+		t.Fatalf("tampered token should be rejected")
+	}
+
+	var stored string
+	db.db.QueryRow(`SELECT token_hash FROM api_tokens`).Scan(&stored)
+	if strings.Contains(token, stored) {
+		t.Fatalf("token should only be stored hashed")
+	}
+
+	if n, err := db.RevokeTokens(alice); err != nil || n != 1 {
+		t.Fatalf("revoke: n=%d err=%v", n, err)
+	}
+	if _, err := db.AuthenticateToken(token); err == nil {
+		t.Fatalf("revoked token should be rejected")
+	}
+	if _, err := db.IssueToken(999, ""); err == nil {
+		t.Fatalf("expected error for unknown member")
+	}
+}
@@ -0,0 +1,187 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Catalog checks run by ValidateCatalog. Each names a kind of record the
+// application never writes itself, but which older versions, imports or
+// edits made outside the application can leave behind.
+const (
+	// CheckUnavailableNoBorrower: a book marked out with no borrower, so
+	// nobody can borrow or return it.
+	CheckUnavailableNoBorrower = "unavailable_without_borrower"
+	// CheckMissingBorrower: a book lent to a member who doesn't exist.
+	CheckMissingBorrower = "borrower_missing"
+	// CheckReservationAfterCheckout: a reservation marked fulfilled after
+	// someone else's current loan of the book began, so the member lost
+	// their place in the queue without getting the book.
+	CheckReservationAfterCheckout = "reservation_fulfilled_during_loan"
+	// CheckMissingSearchIndex: a book with no search index entry, which no
+	// search can find.
+	CheckMissingSearchIndex = "search_index_missing"
+	// CheckWhitespaceContent: a book whose text is nothing but whitespace,
+	// which looks readable but shows blank pages.
+	CheckWhitespaceContent = "whitespace_content"
+)
+
+// CatalogIssue is an anomaly ValidateCatalog found.
+type CatalogIssue struct {
+	Check  string `json:"check"`
+	BookID int64  `json:"book_id"`
+	Detail string `json:"detail"`
+	Fixed  bool   `json:"fixed"`
+
+	refID int64 // the reservation or member it is about, or 0
+}
+
+// catalogCheck finds one kind of anomaly. find selects a book ID, a
+// related ID (or 0) and the book's title for each; fix repairs one.
+type catalogCheck struct {
+	name   string
+	find   string
+	detail func(title string, refID int64) string
+	fix    func(d *Database, issue *CatalogIssue) error
+}
+
+var catalogChecks = []catalogCheck{
+	{
+		name: CheckUnavailableNoBorrower,
+		find: `SELECT id, 0, title FROM books WHERE available=0 AND borrower_id IS NULL`,
+		detail: func(title string, _ int64) string {
+			return fmt.Sprintf("'%s' is marked out but has no borrower", title)
+		},
+		fix: (*Database).repairBorrower,
+	},
+	{
+		name: CheckMissingBorrower,
+		find: `SELECT b.id, b.borrower_id, b.title FROM books b
+		       WHERE b.borrower_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM members m WHERE m.id = b.borrower_id)`,
+		detail: func(title string, memberID int64) string {
+			return fmt.Sprintf("'%s' is lent to member %d, who doesn't exist", title, memberID)
+		},
+		fix: (*Database).repairBorrower,
+	},
+	{
+		name: CheckReservationAfterCheckout,
+		find: `SELECT r.book_id, r.id, b.title FROM reservations r
+		       JOIN books b ON b.id = r.book_id
+		       JOIN checkouts c ON c.book_id = r.book_id AND c.return_time IS NULL AND c.member_id != r.member_id
+		       WHERE r.fulfilled_time IS NOT NULL AND datetime(r.fulfilled_time) > datetime(c.checkout_time)`,
+		detail: func(title string, reservationID int64) string {
+			return fmt.Sprintf("reservation %d for '%s' was fulfilled while the book was on loan to someone else", reservationID, title)
+		},
+		fix: func(d *Database, issue *CatalogIssue) error {
+			// Back into the queue, in its original place
+			_, err := d.db.Exec(`UPDATE reservations SET fulfilled_time=NULL WHERE id=?`, issue.refID)
+			return err
+		},
+	},
+	{
+		name: CheckMissingSearchIndex,
+		find: `SELECT id, 0, title FROM books WHERE id NOT IN (SELECT rowid FROM books_fts)`,
+		detail: func(title string, _ int64) string {
+			return fmt.Sprintf("'%s' is missing from the search index", title)
+		},
+		fix: (*Database).reindexBook,
+	},
+	{
+		// Texts in the content store would each have to be fetched, so
+		// only inline texts are checked
+		name: CheckWhitespaceContent,
+		find: `SELECT b.id, 0, b.title FROM books b JOIN book_contents c ON c.book_id = b.id
+		       WHERE b.content_ref IS NULL AND c.content != '' AND trim(c.content, char(32, 9, 10, 11, 12, 13)) = ''`,
+		detail: func(title string, _ int64) string {
+			return fmt.Sprintf("'%s' has a text of nothing but whitespace", title)
+		},
+		fix: func(d *Database, issue *CatalogIssue) error {
+			defer d.invalidateBook(issue.BookID)
+			_, err := d.db.Exec(`UPDATE book_contents SET content='' WHERE book_id=?`, issue.BookID)
+			return err
+		},
+	},
+}
+
+// ValidateCatalog looks for inconsistent records in the catalog (see the
+// Check constants) and, with fix set, repairs each one it finds.
+func (d *Database) ValidateCatalog(fix bool) ([]*CatalogIssue, error) {
+	var issues []*CatalogIssue
+	for _, check := range catalogChecks {
+		found, err := d.findCatalogIssues(check)
+		if err != nil {
+			return issues, fmt.Errorf("check %s: %w", check.name, err)
+		}
+		for _, issue := range found {
+			if fix {
+				if err := check.fix(d, issue); err != nil {
+					return append(issues, found...), fmt.Errorf("fix %s on book %d: %w", check.name, issue.BookID, err)
+				}
+				issue.Fixed = true
+			}
+		}
+		issues = append(issues, found...)
+	}
+	return issues, nil
+}
+
+func (d *Database) findCatalogIssues(check catalogCheck) ([]*CatalogIssue, error) {
+	rows, err := d.db.Query(check.find)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var issues []*CatalogIssue
+	for rows.Next() {
+		issue := &CatalogIssue{Check: check.name}
+		var title string
+		if err := rows.Scan(&issue.BookID, &issue.refID, &title); err != nil {
+			return nil, err
+		}
+		issue.Detail = check.detail(title, issue.refID)
+		issues = append(issues, issue)
+	}
+	return issues, rows.Err()
+}
+
+// repairBorrower points a book marked out at the member of its open loan,
+// or puts it back on the shelf if it has none.
+func (d *Database) repairBorrower(issue *CatalogIssue) error {
+	return d.inTx(func(tx *sql.Tx) error {
+		var memberID int64
+		err := tx.QueryRow(`SELECT c.member_id FROM checkouts c JOIN members m ON m.id = c.member_id
+		                    WHERE c.book_id=? AND c.return_time IS NULL
+		                    ORDER BY c.checkout_time DESC LIMIT 1`, issue.BookID).Scan(&memberID)
+		if err == sql.ErrNoRows {
+			_, err = tx.Exec(`UPDATE books SET available=1, borrower_id=NULL WHERE id=?`, issue.BookID)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE books SET available=0, borrower_id=? WHERE id=?`, memberID, issue.BookID)
+		return err
+	})
+}
+
+// reindexBook adds a book missing from the search index back to it.
+func (d *Database) reindexBook(issue *CatalogIssue) error {
+	var content string
+	var ref sql.NullString
+	if err := d.db.QueryRow(`SELECT `+contentColumns+` FROM books b WHERE b.id=?`, issue.BookID).Scan(&content, &ref); err != nil {
+		return err
+	}
+	content, err := d.resolveContent(content, ref)
+	if err != nil {
+		return err
+	}
+	_, err = d.db.Exec(`INSERT INTO books_fts(rowid, title, author, content)
+	                    SELECT id, title, author, ? FROM books WHERE id=?`, content, issue.BookID)
+	return err
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) ValidateCatalog(fix bool) ([]*CatalogIssue, error) {
+	return lm.db.ValidateCatalog(fix)
+}
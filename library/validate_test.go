@@ -0,0 +1,71 @@
+package library
+
+import (
+	"context"
+	"testing"
+)
+
+func TestValidateCatalog(t *testing.T) {
+	db := tempDB(t)
+	lost, _ := db.AddBook("Lost Flag", "Author", "text")
+	ghost, _ := db.AddBook("Ghost Loan", "Author", "text")
+	queued, _ := db.AddBook("Queued", "Author", "text")
+	unindexed, _ := db.AddBook("Unindexed", "Author", "findable words")
+	blank, _ := db.AddBook("Blank", "Author", "  \n\t ")
+	alice, _ := db.AddMember("Alice", "password")
+	bob, _ := db.AddMember("Bob", "password")
+
+	if issues, err := db.ValidateCatalog(false); err != nil || len(issues) != 1 || issues[0].Check != CheckWhitespaceContent {
+		t.Fatalf("expected only the blank text flagged, got %v %+v", err, issues)
+	}
+
+	// Break the records the way outside edits can
+	db.db.Exec(`UPDATE books SET available=0 WHERE id=?`, lost)
+	conn, _ := db.db.Conn(context.Background())
+	conn.ExecContext(context.Background(), `PRAGMA foreign_keys=OFF`)
+	conn.ExecContext(context.Background(), `UPDATE books SET available=0, borrower_id=99 WHERE id=?`, ghost)
+	conn.ExecContext(context.Background(), `PRAGMA foreign_keys=ON`)
+	conn.Close()
+	db.CheckoutBook(queued, alice)
+	db.ReserveBook(queued, bob)
+	db.db.Exec(`UPDATE reservations SET fulfilled_time=datetime('now', '+1 minute') WHERE book_id=?`, queued)
+	db.db.Exec(`DELETE FROM books_fts WHERE rowid=?`, unindexed)
+
+	issues, err := db.ValidateCatalog(false)
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	found := map[string]int64{}
+	for _, issue := range issues {
+		if issue.Fixed {
+			t.Errorf("nothing should be fixed without asking: %+v", issue)
+		}
+		found[issue.Check] = issue.BookID
+	}
+	want := map[string]int64{CheckUnavailableNoBorrower: lost, CheckMissingBorrower: ghost,
+		CheckReservationAfterCheckout: queued, CheckMissingSearchIndex: unindexed, CheckWhitespaceContent: blank}
+	if len(issues) != len(want) {
+		t.Fatalf("expected %d issues, got %+v", len(want), issues)
+	}
+	for check, bookID := range want {
+		if found[check] != bookID {
+			t.Errorf("%s: expected book %d, got %d", check, bookID, found[check])
+		}
+	}
+
+	if issues, err := db.ValidateCatalog(true); err != nil || len(issues) != len(want) || !issues[0].Fixed {
+		t.Fatalf("fix: %v %+v", err, issues)
+	}
+	if issues, _ := db.ValidateCatalog(false); len(issues) != 0 {
+		t.Fatalf("expected a clean catalog after fixing, got %+v", issues)
+	}
+	if book, _ := db.GetBook(lost); !book.Available {
+		t.Errorf("a book with no loan should be back on the shelf")
+	}
+	if reservations, _ := db.GetReservations(queued); len(reservations) != 1 {
+		t.Errorf("Bob's reservation should be back in the queue, got %+v", reservations)
+	}
+	if results, _ := db.SearchBooks("findable"); len(results) != 1 {
+		t.Errorf("expected the reindexed book found, got %+v", results)
+	}
+}
@@ -0,0 +1,83 @@
+package library
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Build details, stamped at link time by release builds:
+//
+//	go build -ldflags "-X library-management/library.Version=1.4.0
+//	    -X library-management/library.Commit=$(git rev-parse --short HEAD)
+//	    -X library-management/library.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Without them, the commit and date come from the version control details
+// the go command records, when it recorded any.
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// VersionInfo describes the running build and the database it has open,
+// so that deployments can be told apart.
+type VersionInfo struct {
+	Version       string `json:"version"`
+	Commit        string `json:"commit,omitempty"`
+	BuildDate     string `json:"build_date,omitempty"`
+	GoVersion     string `json:"go_version"`
+	SQLiteVersion string `json:"sqlite_version"`
+	FTS5          bool   `json:"fts5"`           // compiled into SQLite
+	SchemaVersion int    `json:"schema_version"` // of the open database
+	LatestSchema  int    `json:"latest_schema"`  // the version this build migrates to
+}
+
+// buildInfo returns the stamped build details, filled in from the go
+// command's version control details where not stamped.
+func buildInfo() (commit, date string) {
+	commit, date = Commit, BuildDate
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return commit, date
+	}
+	modified := false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if commit == "" {
+				commit = s.Value
+				if len(commit) > 12 {
+					commit = commit[:12]
+				}
+			}
+		case "vcs.time":
+			if date == "" {
+				date = s.Value
+			}
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if modified && Commit == "" && commit != "" {
+		commit += "-dirty"
+	}
+	return commit, date
+}
+
+// VersionInfo reports the build, the SQLite it was built with and the
+// schema version of the database.
+func (d *Database) VersionInfo() (*VersionInfo, error) {
+	v := &VersionInfo{Version: Version, GoVersion: runtime.Version(), LatestSchema: schemaVersion}
+	v.Commit, v.BuildDate = buildInfo()
+	err := d.db.QueryRow(`SELECT sqlite_version(), sqlite_compileoption_used('ENABLE_FTS5'),
+	                             (SELECT version FROM schema_version LIMIT 1)`).
+		Scan(&v.SQLiteVersion, &v.FTS5, &v.SchemaVersion)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) VersionInfo() (*VersionInfo, error) { return lm.db.VersionInfo() }
@@ -0,0 +1,24 @@
+package library
+
+import "testing"
+
+func TestVersionInfo(t *testing.T) {
+	db := tempDB(t)
+	v, err := db.VersionInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != "dev" || v.SQLiteVersion == "" || !v.FTS5 || v.GoVersion == "" {
+		t.Fatalf("unstamped build: %+v", v)
+	}
+	if v.SchemaVersion != len(migrations) || v.LatestSchema != len(migrations) {
+		t.Fatalf("schema %d of %d, want %d", v.SchemaVersion, v.LatestSchema, len(migrations))
+	}
+
+	// Stamped details win over what the go command recorded
+	defer func(version, commit, date string) { Version, Commit, BuildDate = version, commit, date }(Version, Commit, BuildDate)
+	Version, Commit, BuildDate = "1.4.0", "abc1234", "2030-01-07T09:00:00Z"
+	if v, _ := db.VersionInfo(); v.Version != "1.4.0" || v.Commit != "abc1234" || v.BuildDate != "2030-01-07T09:00:00Z" {
+		t.Fatalf("stamped build: %+v", v)
+	}
+}
@@ -0,0 +1,229 @@
+package library
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Archive layout: each chain starts from a byte copy of the database file
+// and continues with the WAL segments written on top of it, in order.
+//
+//	wal/<chain start>/base.db
+//	wal/<chain start>/<seq>-<archived at>.wal
+//
+// Times are UTC in archiveTimeLayout, so names sort chronologically.
+const (
+	walArchivePrefix  = "wal/"
+	archiveTimeLayout = "20060102T150405.000000000Z"
+	walHeaderSize     = 32
+)
+
+type walArchive struct {
+	store ObjectStore
+	chain string // start time of the current chain
+	seq   int
+	salt1 uint32 // salt-1 of the last archived WAL; SQLite bumps it on every reset
+}
+
+// EnableWALArchive checks the database is in WAL mode, as NewDatabase opens
+// it, and starts shipping every committed change to store, so the database
+// can later be restored to any archive point with RestoreToTime. The
+// archiver, rather than SQLite, decides when to checkpoint; to make that
+// safe the connection pool is limited to a single connection.
+func (d *Database) EnableWALArchive(store ObjectStore) error {
+	d.db.SetMaxOpenConns(1)
+	conn, err := d.db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var mode string
+	if err := conn.QueryRowContext(context.Background(), `PRAGMA journal_mode=WAL`).Scan(&mode); err != nil {
+		return fmt.Errorf("enable WAL: %w", err)
+	}
+	if mode != "wal" {
+		return fmt.Errorf("enable WAL: database is in %s mode", mode)
+	}
+	d.walArchive = &walArchive{store: store}
+	return d.startArchiveChain(conn, time.Now())
+}
+
+// startArchiveChain checkpoints everything into the database file and
+// archives a copy of it as the base of a new chain. conn must be the pool's
+// only connection, so nothing can write meanwhile.
+func (d *Database) startArchiveChain(conn *sql.Conn, now time.Time) error {
+	if err := checkpointTruncate(conn); err != nil {
+		return err
+	}
+	f, err := os.Open(d.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	chain := now.UTC().Format(archiveTimeLayout)
+	if err := d.walArchive.store.Put(walArchivePrefix+chain+"/base.db", f); err != nil {
+		return fmt.Errorf("archive base: %w", err)
+	}
+	d.walArchive.chain, d.walArchive.seq, d.walArchive.salt1 = chain, 0, 0
+	return nil
+}
+
+// ArchiveWAL ships the changes committed since the last call and then
+// truncates the WAL. If the WAL was reset behind the archiver's back, the
+// chain is broken and a new one is started from a fresh base copy.
+func (d *Database) ArchiveWAL(now time.Time) error {
+	a := d.walArchive
+	if a == nil {
+		return nil
+	}
+	conn, err := d.db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// Per connection, so re-applied in case the pool replaced it
+	if _, err := conn.ExecContext(context.Background(), `PRAGMA wal_autocheckpoint=0`); err != nil {
+		return err
+	}
+
+	wal, err := os.ReadFile(d.path + "-wal")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(wal) < walHeaderSize {
+		return nil
+	}
+	salt1 := binary.BigEndian.Uint32(wal[16:20])
+	if a.seq > 0 && salt1 != a.salt1 && salt1 != a.salt1+1 {
+		return d.startArchiveChain(conn, now)
+	}
+
+	a.seq++
+	name := fmt.Sprintf("%s%s/%08d-%s.wal", walArchivePrefix, a.chain, a.seq, now.UTC().Format(archiveTimeLayout))
+	if err := a.store.Put(name, bytes.NewReader(wal)); err != nil {
+		a.seq--
+		return fmt.Errorf("archive WAL: %w", err)
+	}
+	a.salt1 = salt1
+	return checkpointTruncate(conn)
+}
+
+func checkpointTruncate(conn *sql.Conn) error {
+	var busy, log, checkpointed int
+	err := conn.QueryRowContext(context.Background(), `PRAGMA wal_checkpoint(TRUNCATE)`).Scan(&busy, &log, &checkpointed)
+	if err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	return nil
+}
+
+// RestoreToTime rebuilds the database as it stood at the last archive
+// point at or before target and writes it to out, which must not exist.
+func RestoreToTime(store ObjectStore, target time.Time, out string) (time.Time, error) {
+	if _, err := os.Stat(out); err == nil {
+		return time.Time{}, fmt.Errorf("%s already exists", out)
+	}
+	names, err := store.List(walArchivePrefix)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// The latest chain that started by target
+	stamp := target.UTC().Format(archiveTimeLayout)
+	chain := ""
+	for _, name := range names {
+		if c, ok := strings.CutSuffix(strings.TrimPrefix(name, walArchivePrefix), "/base.db"); ok && c <= stamp {
+			chain = c
+		}
+	}
+	if chain == "" {
+		return time.Time{}, fmt.Errorf("no archive from before %s", target.Format("2006-01-02 15:04:05"))
+	}
+	restoredTo, _ := time.Parse(archiveTimeLayout, chain)
+
+	if err := copyObject(store, walArchivePrefix+chain+"/base.db", out); err != nil {
+		return time.Time{}, err
+	}
+	for _, name := range names {
+		seg, ok := strings.CutPrefix(name, walArchivePrefix+chain+"/")
+		if !ok || !strings.HasSuffix(seg, ".wal") {
+			continue
+		}
+		_, archived, _ := strings.Cut(strings.TrimSuffix(seg, ".wal"), "-")
+		if archived > stamp {
+			break
+		}
+		if err := copyObject(store, name, out+"-wal"); err != nil {
+			return time.Time{}, err
+		}
+		if err := applyWAL(out); err != nil {
+			return time.Time{}, fmt.Errorf("apply %s: %w", path.Base(name), err)
+		}
+		restoredTo, _ = time.Parse(archiveTimeLayout, archived)
+	}
+	return restoredTo, nil
+}
+
+// applyWAL opens the database at file so SQLite recovers the WAL next to
+// it, then folds the WAL into the database file.
+func applyWAL(file string) error {
+	db, err := sql.Open("sqlite3", "file:"+file)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	var busy, log, checkpointed int
+	if err := db.QueryRow(`PRAGMA wal_checkpoint(TRUNCATE)`).Scan(&busy, &log, &checkpointed); err != nil {
+		return err
+	}
+	if busy != 0 {
+		return fmt.Errorf("checkpoint incomplete")
+	}
+	return nil
+}
+
+func copyObject(store ObjectStore, name, dst string) error {
+	r, err := store.Get(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ------------------ Manager helpers ------------------
+
+func (lm *LibraryManager) EnableWALArchive(store ObjectStore) error {
+	return lm.db.EnableWALArchive(store)
+}
+
+func (lm *LibraryManager) ArchiveWAL(now time.Time) error { return lm.db.ArchiveWAL(now) }
+
+// WALArchiveJob ships the WAL every interval, which bounds how much work a
+// point-in-time restore can lose. It does nothing unless archiving is on.
+func (lm *LibraryManager) WALArchiveJob(interval time.Duration) *Job {
+	return &Job{
+		Name:     "wal-archive",
+		Interval: interval,
+		Run:      func() error { return lm.ArchiveWAL(time.Now()) },
+	}
+}
@@ -0,0 +1,79 @@
+package library
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPointInTimeRestore(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "library.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	store, _ := NewDirStore(filepath.Join(t.TempDir(), "archive"))
+	if err := db.EnableWALArchive(store); err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+
+	start := time.Now()
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	db.AddBook("First", "Author", "one")
+	if err := db.ArchiveWAL(at(1)); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	db.AddBook("Second", "Author", "two")
+	db.ArchiveWAL(at(2))
+	// The accidental bulk delete
+	db.db.Exec(`DELETE FROM books`)
+	db.ArchiveWAL(at(3))
+
+	countBooks := func(file string) int {
+		t.Helper()
+		rdb, err := sql.Open("sqlite3", file)
+		if err != nil {
+			t.Fatalf("open restored: %v", err)
+		}
+		defer rdb.Close()
+		var n int
+		if err := rdb.QueryRow(`SELECT COUNT(*) FROM books`).Scan(&n); err != nil {
+			t.Fatalf("query restored: %v", err)
+		}
+		return n
+	}
+
+	for _, c := range []struct {
+		target time.Time
+		books  int
+	}{
+		{at(1).Add(30 * time.Second), 1},
+		{at(2).Add(59 * time.Second), 2},
+		{at(3), 0},
+	} {
+		out := filepath.Join(t.TempDir(), "restored.db")
+		restoredTo, err := RestoreToTime(store, c.target, out)
+		if err != nil {
+			t.Fatalf("restore to %v: %v", c.target, err)
+		}
+		if restoredTo.After(c.target) {
+			t.Fatalf("restored to %v, after target %v", restoredTo, c.target)
+		}
+		if n := countBooks(out); n != c.books {
+			t.Fatalf("restore to %v: %d books, want %d", c.target, n, c.books)
+		}
+	}
+
+	if _, err := RestoreToTime(store, start.Add(-time.Hour), filepath.Join(t.TempDir(), "x.db")); err == nil {
+		t.Fatalf("expected error restoring to before the archive began")
+	}
+}
+
+func TestDirStoreRejectsEscapingNames(t *testing.T) {
+	store, _ := NewDirStore(t.TempDir())
+	if _, err := store.Get("../secret"); err == nil {
+		t.Fatalf("expected names outside the store to be rejected")
+	}
+}
@@ -0,0 +1,121 @@
+package libraryclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// MaxBatchItems is the most items the server takes in one batch. The batch
+// methods split longer lists into several requests.
+const MaxBatchItems = 1000
+
+// Batch item outcomes.
+const (
+	BatchCreated   = "created"
+	BatchUpdated   = "updated"
+	BatchApplied   = "applied"
+	BatchDuplicate = "duplicate"
+	BatchFailed    = "failed"
+)
+
+// BatchResult is the outcome of one item of a batch. Index is the item's
+// place in the list passed to the batch method. Replayed means the item's
+// key had been applied before and nothing was done again.
+type BatchResult struct {
+	Index    int    `json:"index"`
+	Key      string `json:"key,omitempty"`
+	Status   string `json:"status"`
+	ID       int64  `json:"id,omitempty"`
+	Replayed bool   `json:"replayed,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// NewBook is a book to add with AddBooks. A book whose title and author are
+// already in the catalog isn't added again.
+type NewBook struct {
+	Key             string `json:"key,omitempty"`
+	Title           string `json:"title"`
+	Author          string `json:"author"`
+	Content         string `json:"content,omitempty"`
+	ISBN            string `json:"isbn,omitempty"`
+	Publisher       string `json:"publisher,omitempty"`
+	PublicationYear int    `json:"publication_year,omitempty"`
+	Language        string `json:"language,omitempty"`
+	PageCount       int    `json:"page_count,omitempty"`
+}
+
+// MemberUpdate is a member to add or update with UpsertMembers: the member
+// with ID if given, otherwise the one with Name, who is added if there is
+// none. Empty fields are left as they are.
+type MemberUpdate struct {
+	Key        string `json:"key,omitempty"`
+	ID         int64  `json:"id,omitempty"`
+	Name       string `json:"name"`
+	Tier       string `json:"tier,omitempty"`
+	ExpiryDate string `json:"expiry_date,omitempty"` // YYYY-MM-DD
+}
+
+// Circulation event types.
+const (
+	Checkout = "checkout"
+	Return   = "return"
+)
+
+// CirculationEvent is a checkout or return to replay with ApplyCirculation.
+type CirculationEvent struct {
+	Key      string `json:"key,omitempty"`
+	Type     string `json:"type"`
+	BookID   int64  `json:"book_id"`
+	MemberID int64  `json:"member_id,omitempty"`
+}
+
+// AddBooks adds books to the catalog. The signed-in member must be staff.
+func (c *Client) AddBooks(ctx context.Context, books []NewBook) ([]BatchResult, error) {
+	return sendBatch(ctx, c, "/batch/books", books, func(b NewBook) string { return b.Key })
+}
+
+// UpsertMembers adds or updates members. The signed-in member must be staff.
+func (c *Client) UpsertMembers(ctx context.Context, members []MemberUpdate) ([]BatchResult, error) {
+	return sendBatch(ctx, c, "/batch/members", members, func(m MemberUpdate) string { return m.Key })
+}
+
+// ApplyCirculation replays checkouts and returns, in order. The signed-in
+// member must be staff.
+func (c *Client) ApplyCirculation(ctx context.Context, events []CirculationEvent) ([]BatchResult, error) {
+	return sendBatch(ctx, c, "/batch/circulation", events, func(e CirculationEvent) string { return e.Key })
+}
+
+// sendBatch posts items to path in batches the server accepts, and returns
+// every item's result, indexed into items. A batch is only retried when all
+// of its items have keys, since only then is sending it twice harmless. An
+// error stops before the remaining batches; the results so far are
+// returned with it.
+func sendBatch[T any](ctx context.Context, c *Client, path string, items []T, key func(T) string) ([]BatchResult, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("the batch is empty")
+	}
+	var results []BatchResult
+	for start := 0; start < len(items); start += MaxBatchItems {
+		chunk := items[start:min(start+MaxBatchItems, len(items))]
+		keyed := true
+		for _, item := range chunk {
+			if key(item) == "" {
+				keyed = false
+				break
+			}
+		}
+		var out struct {
+			Results []BatchResult `json:"results"`
+		}
+		req := request{method: http.MethodPost, path: path, body: map[string][]T{"items": chunk}, auth: true, retry: keyed}
+		if err := c.do(ctx, req, &out); err != nil {
+			return results, err
+		}
+		for _, r := range out.Results {
+			r.Index += start
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,347 @@
+// Package libraryclient is a Go client for the library's HTTP API, the one
+// served by `serve` (see package api).
+//
+// It is what the CLI's remote mode uses, and is meant for integrators too:
+// it depends only on the standard library, so programs that talk to a
+// library over the network don't need the SQLite engine to build.
+//
+//	c, err := libraryclient.New("https://library.example.org", libraryclient.WithBasicAuth(42, password))
+//	...
+//	page, err := c.GetPage(ctx, bookID, 1)
+//
+// Credentials are either an API token (WithToken) or a member ID and
+// password (WithBasicAuth). With the latter, Login swaps them for a token
+// so the password isn't sent with every request, and a token the server
+// no longer accepts is replaced once before a request is given up on.
+//
+// Requests that are safe to repeat (reads, and batches whose every item has
+// an idempotency key) are retried after network errors and the server
+// being briefly unavailable, backing off between attempts.
+package libraryclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default retry policy.
+const (
+	DefaultRetries = 3
+	DefaultBackoff = 200 * time.Millisecond
+)
+
+// Client calls one library's API. It is safe for concurrent use.
+type Client struct {
+	baseURL *url.URL
+	hc      *http.Client
+	retries int
+	backoff time.Duration
+
+	memberID int64
+	password string
+
+	mu    sync.Mutex
+	token string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sends requests through hc instead of http.DefaultClient,
+// e.g. to set a timeout or a proxy.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.hc = hc }
+}
+
+// WithToken authenticates with an API token from POST /tokens.
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithBasicAuth authenticates as memberID with their password.
+func WithBasicAuth(memberID int64, password string) Option {
+	return func(c *Client) { c.memberID, c.password = memberID, password }
+}
+
+// WithRetries sets how many times a request that is safe to repeat is
+// retried, and the wait before the first retry, which doubles each time.
+// Zero retries turns retrying off.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) { c.retries, c.backoff = n, backoff }
+}
+
+// New creates a Client for the library at endpoint, such as
+// "http://localhost:8080" or, for a library hosted among several,
+// "http://localhost:8080/north".
+func New(endpoint string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid library endpoint %q: expected http(s)://host[:port][/library]", endpoint)
+	}
+	c := &Client{baseURL: u, hc: http.DefaultClient, retries: DefaultRetries, backoff: DefaultBackoff}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// APIError is an error response from the server.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("library API: %s (%d)", e.Message, e.StatusCode)
+}
+
+// IsNotFound reports whether err is the server saying the resource doesn't
+// exist.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Token returns the API token the client is using, if any: the one it was
+// given or the one Login obtained.
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+// Login exchanges the client's member ID and password for an API token,
+// which it uses from then on, and returns it.
+func (c *Client) Login(ctx context.Context) (string, error) {
+	if c.memberID == 0 {
+		return "", fmt.Errorf("login needs a member ID and password")
+	}
+	var out struct {
+		Token string `json:"token"`
+	}
+	req := request{method: http.MethodPost, path: "/tokens", basic: true}
+	if err := c.do(ctx, req, &out); err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	c.token = out.Token
+	c.mu.Unlock()
+	return out.Token, nil
+}
+
+// request describes one API call.
+type request struct {
+	method string
+	path   string
+	body   any
+	auth   bool // send credentials
+	basic  bool // send the member ID and password even if there is a token
+	retry  bool // safe to repeat
+}
+
+// do sends req, retrying as it allows, and decodes a successful response
+// into out unless out is nil.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return err
+		}
+	}
+	relogged := false
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, req, body)
+		wait := c.backoff << attempt
+		if err == nil {
+			if resp.StatusCode < 300 {
+				defer resp.Body.Close()
+				if out == nil {
+					return nil
+				}
+				if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+					return fmt.Errorf("library API: decode response: %w", err)
+				}
+				return nil
+			}
+			err = readAPIError(resp)
+			resp.Body.Close()
+
+			// A revoked or lapsed token: get a new one, once
+			if resp.StatusCode == http.StatusUnauthorized && req.auth && !req.basic && !relogged &&
+				c.memberID != 0 && c.Token() != "" {
+				relogged = true
+				if _, lerr := c.Login(ctx); lerr != nil {
+					return lerr
+				}
+				attempt--
+				continue
+			}
+			if !retryable(resp.StatusCode) {
+				return err
+			}
+			if s, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil && s >= 0 {
+				wait = time.Duration(s) * time.Second
+			}
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !req.retry || attempt >= c.retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// send makes one attempt at req.
+func (c *Client) send(ctx context.Context, req request, body []byte) (*http.Response, error) {
+	u := *c.baseURL
+	u.Path += req.path
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	hr, err := http.NewRequestWithContext(ctx, req.method, u.String(), r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		hr.Header.Set("Content-Type", "application/json")
+	}
+	hr.Header.Set("Accept", "application/json")
+	if req.auth || req.basic {
+		token := c.Token()
+		switch {
+		case token != "" && !req.basic:
+			hr.Header.Set("Authorization", "Bearer "+token)
+		case c.memberID != 0:
+			hr.SetBasicAuth(strconv.FormatInt(c.memberID, 10), c.password)
+		default:
+			return nil, fmt.Errorf("library API: %s %s needs credentials", req.method, req.path)
+		}
+	}
+	return c.hc.Do(hr)
+}
+
+// retryable reports whether a response status may go away on its own.
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// readAPIError turns an error response into an *APIError.
+func readAPIError(resp *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(data, &body) != nil || body.Error == "" {
+		body.Error = strings.TrimSpace(string(data))
+		if body.Error == "" {
+			body.Error = http.StatusText(resp.StatusCode)
+		}
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: body.Error}
+}
+
+// Book is a book's public catalog record.
+type Book struct {
+	ID             int64     `json:"id"`
+	Title          string    `json:"title"`
+	Author         string    `json:"author"`
+	ItemType       string    `json:"item_type"`
+	Available      bool      `json:"available"`
+	NonCirculating bool      `json:"non_circulating"`
+	UpdatedTime    time.Time `json:"updated_time"`
+}
+
+// Page is one page of a book's text.
+type Page struct {
+	BookID     int64  `json:"book_id"`
+	Title      string `json:"title"`
+	Author     string `json:"author"`
+	Number     int    `json:"page"` // 1-based
+	TotalPages int    `json:"total_pages"`
+	Chapter    string `json:"chapter,omitempty"` // heading of the chapter the page ends in
+	Text       string `json:"text"`
+}
+
+// Capability is an optional part of the library and whether it is on.
+type Capability struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// VersionInfo is the build serving a library and its database's schema
+// version.
+type VersionInfo struct {
+	Version       string `json:"version"`
+	Commit        string `json:"commit,omitempty"`
+	BuildDate     string `json:"build_date,omitempty"`
+	GoVersion     string `json:"go_version"`
+	SQLiteVersion string `json:"sqlite_version"`
+	FTS5          bool   `json:"fts5"`
+	SchemaVersion int    `json:"schema_version"`
+	LatestSchema  int    `json:"latest_schema"`
+}
+
+// ListBooks returns the whole public catalog.
+func (c *Client) ListBooks(ctx context.Context) ([]*Book, error) {
+	var books []*Book
+	err := c.do(ctx, request{method: http.MethodGet, path: "/books", retry: true}, &books)
+	return books, err
+}
+
+// GetBook returns the catalog record of one book.
+func (c *Client) GetBook(ctx context.Context, bookID int64) (*Book, error) {
+	var b Book
+	err := c.do(ctx, request{method: http.MethodGet, path: fmt.Sprintf("/books/%d", bookID), retry: true}, &b)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// GetPage returns page n (1-based) of a book the signed-in member may read.
+func (c *Client) GetPage(ctx context.Context, bookID int64, n int) (*Page, error) {
+	var p Page
+	req := request{method: http.MethodGet, path: fmt.Sprintf("/books/%d/pages/%d", bookID, n), auth: true, retry: true}
+	if err := c.do(ctx, req, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Version reports the build serving the library.
+func (c *Client) Version(ctx context.Context) (*VersionInfo, error) {
+	var v VersionInfo
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/version", retry: true}, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Capabilities lists the library's optional subsystems.
+func (c *Client) Capabilities(ctx context.Context) ([]Capability, error) {
+	var caps []Capability
+	err := c.do(ctx, request{method: http.MethodGet, path: "/capabilities", retry: true}, &caps)
+	return caps, err
+}
@@ -0,0 +1,121 @@
+package libraryclient
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"library-management/librarytest"
+)
+
+func TestClientAuth(t *testing.T) {
+	ctx := context.Background()
+	srv := librarytest.NewServer(t)
+	mgr := srv.Library
+	bookID, _ := mgr.AddBook("Dune", "Herbert")
+	mgr.UpdateBookContent(bookID, "CHAPTER I\nA beginning is a very delicate time.")
+	alice, _ := mgr.AddMember("Alice", "password1")
+	mgr.CheckoutBook(bookID, alice)
+
+	if _, err := New("localhost:8080"); err == nil {
+		t.Fatalf("expected an endpoint without a scheme to be refused")
+	}
+
+	anon, _ := New(srv.URL)
+	if _, err := anon.GetPage(ctx, bookID, 1); err == nil {
+		t.Fatalf("expected a page to need credentials")
+	}
+	if _, err := anon.GetBook(ctx, 999); !IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	c, _ := New(srv.URL+"/", WithBasicAuth(alice, "password1"))
+	token, err := c.Login(ctx)
+	if err != nil || token == "" || c.Token() != token {
+		t.Fatalf("login: %q, %v", token, err)
+	}
+	page, err := c.GetPage(ctx, bookID, 1)
+	if err != nil || page.Chapter != "CHAPTER I" || page.TotalPages != 1 {
+		t.Fatalf("page = %+v, %v", page, err)
+	}
+
+	// Revoked tokens are replaced once from the password
+	mgr.RevokeTokens(alice)
+	if _, err := c.GetPage(ctx, bookID, 1); err != nil {
+		t.Fatalf("page after revocation: %v", err)
+	}
+	if c.Token() == token {
+		t.Fatalf("expected a new token")
+	}
+
+	// A token alone can't be renewed
+	mgr.RevokeTokens(alice)
+	tokenOnly, _ := New(srv.URL, WithToken(c.Token()))
+	if _, err := tokenOnly.GetPage(ctx, bookID, 1); err == nil {
+		t.Fatalf("expected a revoked token to be refused")
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	ctx := context.Background()
+	srv := librarytest.NewServer(t)
+	mgr := srv.Library
+	staff, _ := mgr.AddMember("Staff", "password1")
+	mgr.SetMemberAdmin(staff, true)
+
+	// The first two attempts at every request find the server unavailable
+	unavailable := &librarytest.Flaky{Handler: srv.Config.Handler, Failures: 2}
+	flaky := httptest.NewServer(unavailable)
+	defer flaky.Close()
+
+	c, _ := New(flaky.URL, WithBasicAuth(staff, "password1"), WithRetries(2, time.Millisecond))
+	if caps, err := c.Capabilities(ctx); err != nil || len(caps) == 0 {
+		t.Fatalf("capabilities: %v, %v", caps, err)
+	}
+	if v, err := c.Version(ctx); err != nil || v.SchemaVersion == 0 {
+		t.Fatalf("version: %+v, %v", v, err)
+	}
+
+	// Keyed batches are retried; one with an unkeyed item is not
+	results, err := c.AddBooks(ctx, []NewBook{{Key: "b1", Title: "Dune", Author: "Herbert"}, {Key: "b2", Title: "Emma", Author: "Austen"}})
+	if err != nil || len(results) != 2 || results[1].Status != BatchCreated || results[1].Index != 1 {
+		t.Fatalf("keyed batch: %+v, %v", results, err)
+	}
+	unavailable.Reset()
+	if _, err := c.AddBooks(ctx, []NewBook{{Title: "Persuasion", Author: "Austen"}}); err == nil {
+		t.Fatalf("expected an unkeyed batch not to be retried")
+	}
+
+	c, _ = New(flaky.URL, WithRetries(0, 0))
+	unavailable.Reset()
+	if _, err := c.ListBooks(ctx); err == nil {
+		t.Fatalf("expected no retries")
+	}
+	if _, err := c.AddBooks(ctx, nil); err == nil {
+		t.Fatalf("expected an empty batch to be refused")
+	}
+}
+
+func TestClientBatchesLongLists(t *testing.T) {
+	ctx := context.Background()
+	srv := librarytest.NewServer(t)
+	mgr := srv.Library
+	staff, _ := mgr.AddMember("Staff", "password1")
+	mgr.SetMemberAdmin(staff, true)
+
+	members := make([]MemberUpdate, MaxBatchItems+5)
+	for i := range members {
+		members[i] = MemberUpdate{Name: fmt.Sprintf("Member %d", i)}
+	}
+	c, _ := New(srv.URL, WithBasicAuth(staff, "password1"))
+	results, err := c.UpsertMembers(ctx, members)
+	if err != nil || len(results) != len(members) || results[MaxBatchItems+4].Index != MaxBatchItems+4 ||
+		results[MaxBatchItems+4].Status != BatchCreated {
+		t.Fatalf("%d results, %v", len(results), err)
+	}
+	if n, _ := mgr.CountMembers(); n != len(members)+1 {
+		t.Fatalf("%d members", n)
+	}
+}
@@ -0,0 +1,153 @@
+// Package librarycore is the embeddable face of the library engine.
+//
+// Other Go programs that want the catalog, circulation and member accounts
+// without the interactive CLI open an Engine and program against the
+// interfaces below. They cover what the CLI and the HTTP API build on and
+// are kept stable across minor releases: methods are only ever added to the
+// library engine, never removed from or changed in these interfaces, and a
+// breaking change means a new major version of the module.
+//
+// The library package itself remains available for everything else
+// (notifications, jobs, backups, reporting), but its surface follows the
+// application and may change between releases.
+package librarycore
+
+import (
+	"context"
+	"time"
+
+	"library-management/library"
+)
+
+// Records shared with the library package. They are aliases, so values pass
+// freely between an Engine and code that uses the library package directly.
+type (
+	Book          = library.Book
+	SearchResult  = library.SearchResult
+	Member        = library.Member
+	Loan          = library.Loan
+	Page          = library.Page
+	CheckInResult = library.CheckInResult
+	Snapshot      = library.Snapshot
+	Report        = library.MaintenanceReport
+	ObjectStore   = library.ObjectStore
+	Event         = library.Event
+	EventHandler  = library.EventHandler
+)
+
+// ErrPageOutOfRange is returned by Catalog.GetPage for a page past the end of
+// the book.
+var ErrPageOutOfRange = library.ErrPageOutOfRange
+
+// Catalog holds the books and their texts.
+type Catalog interface {
+	// AddBook adds a book without content and returns its ID.
+	AddBook(title, author string) (int64, error)
+	// UpdateBookContent replaces a book's text and reindexes it for search.
+	UpdateBookContent(id int64, content string) error
+	GetBook(id int64) (*Book, error)
+	GetAllBooks() ([]*Book, error)
+	// GetAllBookSummaries is GetAllBooks without the texts.
+	GetAllBookSummaries() ([]*Book, error)
+	// SearchBooks runs a full-text query over titles, authors and content.
+	SearchBooks(q string) ([]*Book, error)
+	// SearchBookSummaries is SearchBooks without the texts.
+	SearchBookSummaries(q string) ([]*Book, error)
+	// SearchBookResults is SearchBookSummaries with each book's relevance
+	// score and excerpts showing where it matched.
+	SearchBookResults(q string) ([]*SearchResult, error)
+	// GetPage returns page n (1-based) of a book memberID may read.
+	GetPage(bookID, memberID int64, n int) (*Page, error)
+	// GetBookUpdatedTime reports when a book's record last changed.
+	GetBookUpdatedTime(bookID int64) (time.Time, error)
+}
+
+// Circulation lends books out and takes them back.
+type Circulation interface {
+	CheckoutBook(bookID, memberID int64) error
+	// ReturnBook returns a book on memberID's behalf and reports the member
+	// it was passed to from the hold queue, or 0.
+	ReturnBook(bookID, memberID int64) (int64, error)
+	// CheckInBook is the staff-side return, whoever holds the book.
+	CheckInBook(bookID int64) (*CheckInResult, error)
+	ReserveBook(bookID, memberID int64) error
+	CancelReservation(bookID, memberID int64) error
+	// GetReservations lists a book's hold queue in order.
+	GetReservations(bookID int64) ([]*Member, error)
+	GetOpenLoans(memberID int64) ([]*Loan, error)
+	// GetMemberHistory lists all of a member's checkouts, newest first.
+	GetMemberHistory(memberID int64) ([]*Loan, error)
+	// GetBookHistory lists all checkouts of a book, newest first.
+	GetBookHistory(bookID int64) ([]*Loan, error)
+}
+
+// Auth manages member accounts and their credentials.
+type Auth interface {
+	AddMember(name, password string) (int64, error)
+	GetMember(id int64) (*Member, error)
+	AuthenticateMember(memberID int64, password string) error
+	AuthenticateAdmin(memberID int64, password string) error
+	// IssueToken returns a bearer token for memberID; only its hash is kept.
+	IssueToken(memberID int64, scope string) (string, error)
+	// AuthenticateToken resolves a token to the member it was issued to.
+	AuthenticateToken(token string) (int64, error)
+	// RevokeTokens invalidates every token of a member and reports how many.
+	RevokeTokens(memberID int64) (int, error)
+}
+
+// Events lets integrations follow circulation as it happens. Handlers run
+// after each change is committed, on the goroutine that made it.
+type Events interface {
+	OnCheckout(h EventHandler)
+	OnReturn(h EventHandler)
+	// OnHoldReady is called when a returned book goes on hold for the next
+	// member waiting for it.
+	OnHoldReady(h EventHandler)
+	// OnOverdue is called once per loan, when the overdue notices job first
+	// finds it overdue.
+	OnOverdue(h EventHandler)
+}
+
+// Store looks after the database underneath the engine.
+type Store interface {
+	// Maintain analyzes, compacts and checkpoints the database.
+	Maintain() (*Report, error)
+	// CreateSnapshot writes a checksummed copy of the database into dir.
+	CreateSnapshot(dir string, now time.Time) (*Snapshot, error)
+	// SetContentStore keeps book texts longer than inlineMax bytes in store.
+	SetContentStore(store ObjectStore, inlineMax int)
+	Close() error
+}
+
+// Engine is a whole library: every interface over one database.
+type Engine interface {
+	Catalog
+	Circulation
+	Auth
+	Events
+	Store
+}
+
+var _ Engine = (*library.LibraryManager)(nil)
+
+// Open opens (or creates) the library database at path.
+func Open(path string) (Engine, error) {
+	mgr, err := library.NewLibraryManager(path)
+	if err != nil {
+		return nil, err
+	}
+	return mgr, nil
+}
+
+// WithContext returns a view of eng whose calls run under ctx: queries stop
+// and transactions roll back once ctx is cancelled or its deadline passes,
+// and the call returns ctx's error. An Engine that can't be bound to a
+// context is returned as it is.
+func WithContext(ctx context.Context, eng Engine) Engine {
+	if c, ok := eng.(interface {
+		WithContext(context.Context) *library.LibraryManager
+	}); ok {
+		return c.WithContext(ctx)
+	}
+	return eng
+}
@@ -0,0 +1,66 @@
+package librarycore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEngineRoundTrip(t *testing.T) {
+	eng, err := Open(filepath.Join(t.TempDir(), "core.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer eng.Close()
+
+	var catalog Catalog = eng
+	var circ Circulation = eng
+	var auth Auth = eng
+
+	bookID, err := catalog.AddBook("Embedded", "Author")
+	if err != nil {
+		t.Fatalf("add book: %v", err)
+	}
+	if err := catalog.UpdateBookContent(bookID, "Text to read."); err != nil {
+		t.Fatalf("content: %v", err)
+	}
+	memberID, _ := auth.AddMember("Alice", "password1")
+	if err := auth.AuthenticateMember(memberID, "password1"); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if err := circ.CheckoutBook(bookID, memberID); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	page, err := catalog.GetPage(bookID, memberID, 1)
+	if err != nil || page.Text != "Text to read." {
+		t.Fatalf("page %+v, err %v", page, err)
+	}
+	if _, err := catalog.GetPage(bookID, memberID, 2); err != ErrPageOutOfRange {
+		t.Fatalf("expected ErrPageOutOfRange, got %v", err)
+	}
+	if _, err := circ.ReturnBook(bookID, memberID); err != nil {
+		t.Fatalf("return: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := WithContext(ctx, eng).CheckoutBook(bookID, memberID); !errors.Is(err, context.Canceled) {
+		t.Fatalf("checkout under a cancelled context: %v", err)
+	}
+}
+
+func TestOpenFailure(t *testing.T) {
+	// A regular file standing where the database's directory should be
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	os.WriteFile(blocker, nil, 0o600)
+	eng, err := Open(filepath.Join(blocker, "core.db"))
+	if err == nil {
+		eng.Close()
+		t.Fatalf("expected an error for an unreachable path")
+	}
+	if eng != nil {
+		t.Fatalf("expected a nil Engine on error")
+	}
+}
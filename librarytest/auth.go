@@ -0,0 +1,150 @@
+package librarytest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"library-management/library"
+	"library-management/librarycore"
+)
+
+// Auth is an in-memory librarycore.Auth: member accounts and API tokens
+// kept in maps, with passwords compared as given rather than hashed. Its
+// errors read like the library's own.
+type Auth struct {
+	mu      sync.Mutex
+	members map[int64]*authMember
+	names   map[string]int64
+	tokens  map[string]int64
+	issued  int
+}
+
+type authMember struct {
+	member   library.Member
+	password string
+}
+
+var _ librarycore.Auth = (*Auth)(nil)
+
+// NewAuth returns an Auth with no members.
+func NewAuth() *Auth {
+	return &Auth{members: map[int64]*authMember{}, names: map[string]int64{}, tokens: map[string]int64{}}
+}
+
+// AddMember adds an active member; IDs are handed out from 1.
+func (a *Auth) AddMember(name, password string) (int64, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, fmt.Errorf("member name cannot be empty")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.names[name]; ok {
+		return 0, fmt.Errorf("member with name '%s' already exists", name)
+	}
+	id := int64(len(a.members) + 1)
+	a.members[id] = &authMember{member: library.Member{ID: id, Name: name, Active: true}, password: password}
+	a.names[name] = id
+	return id, nil
+}
+
+// SetAdmin grants or revokes staff rights for memberID.
+func (a *Auth) SetAdmin(memberID int64, isAdmin bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	m, ok := a.members[memberID]
+	if !ok {
+		return fmt.Errorf("member with ID %d not found", memberID)
+	}
+	m.member.IsAdmin = isAdmin
+	return nil
+}
+
+// SetActive activates or deactivates memberID's account.
+func (a *Auth) SetActive(memberID int64, active bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	m, ok := a.members[memberID]
+	if !ok {
+		return fmt.Errorf("member with ID %d not found", memberID)
+	}
+	m.member.Active = active
+	return nil
+}
+
+// GetMember returns a copy of memberID's record.
+func (a *Auth) GetMember(id int64) (*library.Member, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	m, ok := a.members[id]
+	if !ok {
+		return nil, fmt.Errorf("member with ID %d not found", id)
+	}
+	member := m.member
+	return &member, nil
+}
+
+func (a *Auth) AuthenticateMember(memberID int64, password string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	m, ok := a.members[memberID]
+	if !ok || m.password != password {
+		return fmt.Errorf("authentication failed: invalid member ID or password")
+	}
+	if !m.member.Active {
+		return fmt.Errorf("member account %d is deactivated", memberID)
+	}
+	return nil
+}
+
+func (a *Auth) AuthenticateAdmin(memberID int64, password string) error {
+	if err := a.AuthenticateMember(memberID, password); err != nil {
+		return err
+	}
+	if m, _ := a.GetMember(memberID); !m.IsAdmin {
+		return fmt.Errorf("staff privileges required")
+	}
+	return nil
+}
+
+// IssueToken returns a token for memberID, prefixed with "scope." when a
+// scope is given. Tokens are numbered rather than random, so they are
+// predictable: never use them outside tests.
+func (a *Auth) IssueToken(memberID int64, scope string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.members[memberID]; !ok {
+		return "", fmt.Errorf("member with ID %d not found", memberID)
+	}
+	a.issued++
+	token := fmt.Sprintf("test-token-%d", a.issued)
+	if scope != "" {
+		token = scope + "." + token
+	}
+	a.tokens[token] = memberID
+	return token, nil
+}
+
+func (a *Auth) AuthenticateToken(token string) (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	memberID, ok := a.tokens[token]
+	if !ok {
+		return 0, fmt.Errorf("authentication failed: invalid or revoked token")
+	}
+	return memberID, nil
+}
+
+func (a *Auth) RevokeTokens(memberID int64) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n := 0
+	for token, id := range a.tokens {
+		if id == memberID {
+			delete(a.tokens, token)
+			n++
+		}
+	}
+	return n, nil
+}
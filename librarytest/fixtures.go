@@ -0,0 +1,179 @@
+package librarytest
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"library-management/library"
+)
+
+// FixturePassword is every fixture member's password.
+const FixturePassword = "password"
+
+// Fixtures names the records LoadFixtures creates and holds the clock it
+// runs on, so tests can refer to them and move time on.
+type Fixtures struct {
+	Clock *library.FixedClock
+
+	// Members; Staff is an administrator on the staff tier and Carol is
+	// a child
+	Staff, Alice, Bob, Carol int64
+
+	// Books; Neuromancer has no text
+	Dune, Emma, Hobbit, Persuasion, Neuromancer int64
+}
+
+// NewFixtureLibrary opens a library in a temporary directory and loads the
+// fixtures into it. The library is closed and removed when the test ends.
+func NewFixtureLibrary(t testing.TB) (*library.LibraryManager, *Fixtures) {
+	t.Helper()
+	lm, fx, err := LoadFixtures(filepath.Join(t.TempDir(), "library.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lm.Close() })
+	return lm, fx
+}
+
+// LoadFixtures opens the library at path, which must have no books yet,
+// and fills it with a small known catalog and a fortnight of circulation,
+// on a clock from NewClock, so that every ID and timestamp it leaves
+// behind is the same on every run:
+//
+//   - day 0: Alice borrows Dune
+//   - day 1: Bob borrows Emma; Carol, then Alice, reserve it
+//   - day 3: Bob borrows The Hobbit, and brings it back on day 5
+//   - day 10: Bob returns Emma, which is held for Carol
+//   - day 15: the clock stops, with Dune a day overdue
+//
+// Books' updated times, which SQLite stamps itself, are set to the last of
+// these. Passwords are hashed at bcrypt's lowest cost, so signing in is
+// quick. The caller closes the library.
+func LoadFixtures(path string) (*library.LibraryManager, *Fixtures, error) {
+	lm, err := library.NewLibraryManager(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load fixtures: %w", err)
+	}
+	fx, err := loadFixtures(lm, path)
+	if err != nil {
+		lm.Close()
+		return nil, nil, fmt.Errorf("load fixtures: %w", err)
+	}
+	return lm, fx, nil
+}
+
+func loadFixtures(lm *library.LibraryManager, path string) (*Fixtures, error) {
+	if n, err := lm.CountBooks(); err != nil || n > 0 {
+		if err == nil {
+			err = fmt.Errorf("library already has %d books", n)
+		}
+		return nil, err
+	}
+	if err := lm.SetBcryptCost(bcrypt.MinCost); err != nil {
+		return nil, err
+	}
+	fx := &Fixtures{Clock: NewClock()}
+	lm.SetClock(fx.Clock)
+
+	var err error
+	member := func(name, tier string) int64 {
+		if err != nil {
+			return 0
+		}
+		var id int64
+		id, err = lm.AddMemberWithTier(name, FixturePassword, tier)
+		return id
+	}
+	fx.Staff = member("Desk Staff", library.TierStaff)
+	fx.Alice = member("Alice Archer", library.TierAdult)
+	fx.Bob = member("Bob Baker", library.TierSenior)
+	fx.Carol = member("Carol Cooper", library.TierChild)
+	if err == nil {
+		err = lm.SetMemberAdmin(fx.Staff, true)
+	}
+
+	book := func(title, author, content string, meta library.BookMetadata, tags ...string) int64 {
+		if err != nil {
+			return 0
+		}
+		var id int64
+		if id, err = lm.AddBook(title, author); err != nil {
+			return 0
+		}
+		if content != "" {
+			if err = lm.UpdateBookContent(id, content); err != nil {
+				return 0
+			}
+		}
+		if err = lm.SetBookMetadata(id, meta); err != nil {
+			return 0
+		}
+		for _, tag := range tags {
+			if err = lm.TagBook(id, tag); err != nil {
+				return 0
+			}
+		}
+		return id
+	}
+	fx.Dune = book("Dune", "Frank Herbert",
+		"A beginning is the time for taking the most delicate care that the balances are correct.",
+		library.BookMetadata{ISBN: "9780441013593", Publisher: "Ace", PublicationYear: 1965, Language: "en", PageCount: 412},
+		"science fiction")
+	fx.Emma = book("Emma", "Jane Austen",
+		"Emma Woodhouse, handsome, clever, and rich, with a comfortable home and happy disposition.",
+		library.BookMetadata{ISBN: "9780141439587", Publisher: "Penguin", PublicationYear: 1815, Language: "en", PageCount: 474},
+		"classics")
+	fx.Hobbit = book("The Hobbit", "J. R. R. Tolkien",
+		"In a hole in the ground there lived a hobbit.",
+		library.BookMetadata{ISBN: "9780547928227", Publisher: "Houghton Mifflin", PublicationYear: 1937, Language: "en", PageCount: 300})
+	fx.Persuasion = book("Persuasion", "Jane Austen",
+		"Sir Walter Elliot, of Kellynch Hall, in Somersetshire, was a man who, for his own amusement, never took up any book but the Baronetage.",
+		library.BookMetadata{PublicationYear: 1817, Language: "en"},
+		"classics")
+	fx.Neuromancer = book("Neuromancer", "William Gibson", "",
+		library.BookMetadata{ISBN: "9780441569595", Publisher: "Ace", PublicationYear: 1984, Language: "en", PageCount: 271},
+		"science fiction")
+	if err != nil {
+		return nil, err
+	}
+
+	day := func(n int) { fx.Clock.Set(Epoch.AddDate(0, 0, n)) }
+	steps := []struct {
+		day int
+		do  func() error
+	}{
+		{0, func() error { return lm.CheckoutBook(fx.Dune, fx.Alice) }},
+		{1, func() error { return lm.CheckoutBook(fx.Emma, fx.Bob) }},
+		{1, func() error { return lm.ReserveBook(fx.Emma, fx.Carol) }},
+		{1, func() error { return lm.ReserveBook(fx.Emma, fx.Alice) }},
+		{3, func() error { return lm.CheckoutBook(fx.Hobbit, fx.Bob) }},
+		{5, func() error { _, err := lm.ReturnBook(fx.Hobbit, fx.Bob); return err }},
+		{10, func() error { _, err := lm.ReturnBook(fx.Emma, fx.Bob); return err }},
+	}
+	for i, s := range steps {
+		// Steps on the same day an hour apart, so none share a timestamp
+		day(s.day)
+		fx.Clock.Advance(time.Duration(i) * time.Hour)
+		if err := s.do(); err != nil {
+			return nil, fmt.Errorf("day %d: %w", s.day, err)
+		}
+	}
+	day(15)
+
+	// The library has no call for setting updated times, so they are set
+	// over a connection of our own
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	if _, err := db.Exec(`UPDATE books SET updated_time = ?`, fx.Clock.Now().UTC().Format(time.DateTime)); err != nil {
+		return nil, err
+	}
+	return fx, nil
+}
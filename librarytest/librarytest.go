@@ -0,0 +1,79 @@
+// Package librarytest provides test doubles for the library's integration
+// points, so programs built on the library (and the library's own tests)
+// can exercise notifications, authentication, time and the HTTP API
+// without real mail servers, accounts, clocks or network services.
+//
+// The doubles are safe for concurrent use, since the jobs and servers
+// they stand in for call them from other goroutines. LoadFixtures and
+// CheckGolden go with them, for tests that compare output with golden
+// files.
+package librarytest
+
+import (
+	"sync"
+	"time"
+
+	"library-management/library"
+)
+
+// Epoch is the time a Clock from NewClock starts at: a fixed, ordinary
+// weekday morning, so due dates and expiries come out the same every run.
+var Epoch = time.Date(2030, time.January, 7, 9, 0, 0, 0, time.UTC)
+
+// NewClock returns a library.Clock stopped at Epoch. Move it with Set or
+// Advance.
+func NewClock() *library.FixedClock { return library.NewFixedClock(Epoch) }
+
+// Notifier is a library.Notifier that keeps the notices it is given
+// instead of delivering them.
+type Notifier struct {
+	mu      sync.Mutex
+	notices []library.Notice
+	err     error
+}
+
+var _ library.Notifier = (*Notifier)(nil)
+
+// Notify implements library.Notifier. While a failure is set (see Fail) it
+// returns that error and keeps nothing.
+func (n *Notifier) Notify(notice library.Notice) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.err != nil {
+		return n.err
+	}
+	n.notices = append(n.notices, notice)
+	return nil
+}
+
+// Fail makes later deliveries fail with err; nil makes them succeed again.
+func (n *Notifier) Fail(err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.err = err
+}
+
+// Notices returns the notices delivered so far, oldest first.
+func (n *Notifier) Notices() []library.Notice {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]library.Notice(nil), n.notices...)
+}
+
+// NoticesFor returns the notices delivered to memberID, oldest first.
+func (n *Notifier) NoticesFor(memberID int64) []library.Notice {
+	var out []library.Notice
+	for _, notice := range n.Notices() {
+		if notice.MemberID == memberID {
+			out = append(out, notice)
+		}
+	}
+	return out
+}
+
+// Reset forgets the notices delivered so far.
+func (n *Notifier) Reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notices = nil
+}
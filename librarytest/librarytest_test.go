@@ -0,0 +1,90 @@
+package librarytest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNotifierWithClock(t *testing.T) {
+	srv := NewServer(t)
+	lm := srv.Library
+	clock := NewClock()
+	lm.SetClock(clock)
+	alice, _ := lm.AddMember("Alice", "password1")
+	lm.AddMember("Bob", "password1")
+
+	var n Notifier
+	n.Fail(fmt.Errorf("mail server down"))
+	if _, err := lm.SendExpiryNotices(&n, 7*24*time.Hour); err != nil {
+		t.Fatalf("nothing due yet: %v", err)
+	}
+	clock.Advance(360 * 24 * time.Hour)
+	if sent, err := lm.SendExpiryNotices(&n, 7*24*time.Hour); err == nil || sent != 0 || len(n.Notices()) != 0 {
+		t.Fatalf("failing delivery: sent %d, %v, %+v", sent, err, n.Notices())
+	}
+
+	n.Fail(nil)
+	if sent, err := lm.SendExpiryNotices(&n, 7*24*time.Hour); err != nil || sent != 2 {
+		t.Fatalf("sent %d, %v", sent, err)
+	}
+	got := n.NoticesFor(alice)
+	if len(got) != 1 || got[0].MemberName != "Alice" || got[0].Body != "Your membership expires on 2031-01-07. Renew at the desk to keep borrowing." {
+		t.Fatalf("notices for Alice = %+v", got)
+	}
+	n.Reset()
+	if len(n.Notices()) != 0 {
+		t.Fatalf("notices after reset = %+v", n.Notices())
+	}
+}
+
+func TestAuth(t *testing.T) {
+	a := NewAuth()
+	alice, _ := a.AddMember("Alice", "password1")
+	if _, err := a.AddMember("Alice", "other"); err == nil {
+		t.Fatalf("expected a duplicate name to be refused")
+	}
+	if err := a.AuthenticateMember(alice, "wrong"); err == nil {
+		t.Fatalf("expected a wrong password to be refused")
+	}
+	if err := a.AuthenticateAdmin(alice, "password1"); err == nil {
+		t.Fatalf("expected a member without staff rights to be refused")
+	}
+	a.SetAdmin(alice, true)
+	if err := a.AuthenticateAdmin(alice, "password1"); err != nil {
+		t.Fatalf("admin: %v", err)
+	}
+
+	token, _ := a.IssueToken(alice, "north")
+	if id, err := a.AuthenticateToken(token); err != nil || id != alice || token[:6] != "north." {
+		t.Fatalf("token %q: %d, %v", token, id, err)
+	}
+	if n, _ := a.RevokeTokens(alice); n != 1 {
+		t.Fatalf("revoked %d tokens", n)
+	}
+	if _, err := a.AuthenticateToken(token); err == nil {
+		t.Fatalf("expected a revoked token to be refused")
+	}
+
+	a.SetActive(alice, false)
+	if err := a.AuthenticateMember(alice, "password1"); err == nil {
+		t.Fatalf("expected a deactivated member to be refused")
+	}
+}
+
+func TestFlaky(t *testing.T) {
+	srv := NewServer(t)
+	f := &Flaky{Handler: srv.Config.Handler, Failures: 1, Status: http.StatusBadGateway}
+	for i, want := range []int{http.StatusBadGateway, http.StatusOK, http.StatusBadGateway} {
+		rec := httptest.NewRecorder()
+		f.ServeHTTP(rec, httptest.NewRequest("GET", "/books", nil))
+		if rec.Code != want {
+			t.Fatalf("request %d: status %d, want %d", i+1, rec.Code, want)
+		}
+	}
+	if f.Calls() != 3 {
+		t.Fatalf("%d calls", f.Calls())
+	}
+}
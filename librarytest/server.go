@@ -0,0 +1,78 @@
+package librarytest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"library-management/api"
+	"library-management/library"
+)
+
+// Server is the library's HTTP API running on a loopback address over a
+// fresh database, for testing clients against.
+type Server struct {
+	*httptest.Server
+	// Library is the library behind the API, for setting up books and
+	// members and checking what requests did.
+	Library *library.LibraryManager
+}
+
+// NewServer starts an API server over an empty library in a temporary
+// directory. Both are closed and removed when the test ends.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	mgr, err := library.NewLibraryManager(filepath.Join(t.TempDir(), "library.db"))
+	if err != nil {
+		t.Fatalf("new library: %v", err)
+	}
+	t.Cleanup(func() { mgr.Close() })
+	srv := httptest.NewServer(api.NewServer(mgr))
+	t.Cleanup(srv.Close)
+	return &Server{Server: srv, Library: mgr}
+}
+
+// Flaky wraps Handler so that, of every Failures+1 requests, the first
+// Failures are answered with Status (503 Service Unavailable if zero) and
+// only the last reaches Handler. It stands in for an overloaded server or
+// proxy when testing retries.
+type Flaky struct {
+	Handler  http.Handler
+	Failures int
+	Status   int
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *Flaky) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.calls++
+	fail := f.calls%(f.Failures+1) != 0
+	f.mu.Unlock()
+	if !fail {
+		f.Handler.ServeHTTP(w, r)
+		return
+	}
+	status := f.Status
+	if status == 0 {
+		status = http.StatusServiceUnavailable
+	}
+	w.WriteHeader(status)
+}
+
+// Calls reports how many requests have arrived.
+func (f *Flaky) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// Reset starts the count again, so the next request fails.
+func (f *Flaky) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = 0
+}
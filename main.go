@@ -2,394 +2,6303 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/mail"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
+	"text/tabwriter"
+	"time"
 
+	"library-management/api"
 	"library-management/library"
+	"library-management/libraryclient"
+	"library-management/selfupdate"
+	"library-management/telemetry"
 
 	"golang.org/x/term"
 )
 
-const dbFile = "library.db"
+const (
+	jobLogFile = "jobs.log"
+
+	defaultHTTPAddr = ":8080"
+	// serverCacheSize is how many page chunks (and books' metadata) the
+	// server keeps in memory.
+	serverCacheSize = 1024
+
+	// defaultReminderLead is how far ahead of the due date courtesy reminders
+	// go out; override with LIBRARY_REMINDER_LEAD (e.g. "72h").
+	defaultReminderLead = 48 * time.Hour
+	reminderInterval    = time.Hour
+	overdueInterval     = time.Hour
+
+	// Members signing in are told about loans due within this window.
+	dueSoonWindow = 3 * 24 * time.Hour
+
+	// Members are told this far ahead that their card is about to expire.
+	expiryNoticeWindow   = 30 * 24 * time.Hour
+	expiryNoticeInterval = 24 * time.Hour
+
+	// Expired digital loans are returned and their readers told this often.
+	digitalReturnInterval = time.Hour
+
+	// Holds not collected in their pickup window are passed on this often.
+	holdExpiryInterval = time.Hour
+
+	// Members who moved up reservation queues are told this often, in one
+	// notice for everything that moved meanwhile.
+	queueNoticeInterval = 15 * time.Minute
+
+	// Database maintenance runs once a night between these local hours.
+	maintenanceIdleStart = 2
+	maintenanceIdleEnd   = 5
+	maintenanceInterval  = time.Hour
+
+	// The nightly backup job checks its stored schedule this often.
+	backupInterval = 15 * time.Minute
+
+	// With LIBRARY_WAL_ARCHIVE set, committed changes are shipped this
+	// often; it is the granularity of `restore --to`.
+	walArchiveInterval = time.Minute
+
+	// serviceAreaMinCell suppresses ZIPs with too few members to stay anonymous.
+	serviceAreaMinCell = 5
+
+	// The availability board redraws this often.
+	boardRefresh = 5 * time.Second
+
+	// A `login` session ends after this long without a member command.
+	sessionTimeout = 15 * time.Minute
+
+	// `librarycli foo` runs librarycli-foo from PATH, git-style.
+	pluginPrefix = "librarycli-"
+)
+
+// readSecret reads a line from the terminal without echoing it.
+var readSecret = func() ([]byte, error) { return term.ReadPassword(int(syscall.Stdin)) }
 
 // readPassword securely reads a password with masking
 func readPassword(prompt string) (string, error) {
 	fmt.Print(prompt)
-	bytePassword, err := term.ReadPassword(int(syscall.Stdin))
+	bytePassword, err := readSecret()
+	if err != nil {
+		return "", err
+	}
+	fmt.Println() // Add newline after password input
+	return strings.TrimSpace(string(bytePassword)), nil
+}
+
+// authenticateStaff prompts for a staff member ID and password and verifies
+// the account has admin rights. It returns the authenticated staff ID.
+func authenticateStaff(sc *bufio.Scanner, mgr *library.LibraryManager) (int64, error) {
+	prompt("Staff member ID: ")
+	if !sc.Scan() {
+		return 0, fmt.Errorf("no staff member ID entered")
+	}
+	staffIDStr := strings.TrimSpace(sc.Text())
+	staffID, err := strconv.ParseInt(staffIDStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid member ID: %s", staffIDStr)
+	}
+
+	password, err := readPassword("Enter your password: ")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read password: %w", err)
+	}
+
+	if err := mgr.AuthenticateAdmin(staffID, password); err != nil {
+		if !errors.Is(err, library.ErrPasswordChangeRequired) {
+			countError(telemetry.ErrAuth)
+			return 0, err
+		}
+		// The password checked out, but AuthenticateAdmin stops before
+		// checking staff rights, so check them here: a member without them
+		// mustn't be walked through a password change at the staff prompt
+		if m, err := mgr.GetMember(staffID); err != nil || !m.IsAdmin {
+			return 0, fmt.Errorf("staff privileges required")
+		}
+		if _, err := promptPasswordChange(mgr, staffID, password); err != nil {
+			return 0, err
+		}
+	}
+	return staffID, nil
+}
+
+// greeted records the members shown their account summary this session.
+var greeted = map[int64]bool{}
+
+// displayZone is the zone times are shown and typed in, set by
+// LIBRARY_TIMEZONE; the database keeps them in UTC.
+var displayZone = time.Local
+
+// loadDisplayZone reads LIBRARY_TIMEZONE, an IANA zone name such as
+// "Europe/Paris", into displayZone.
+func loadDisplayZone() error {
+	if v := os.Getenv("LIBRARY_TIMEZONE"); v != "" {
+		loc, err := time.LoadLocation(v)
+		if err != nil {
+			return fmt.Errorf("invalid LIBRARY_TIMEZONE %q", v)
+		}
+		displayZone = loc
+	}
+	return nil
+}
+
+// displayTime returns t in displayZone, ready to format for the screen.
+func displayTime(t time.Time) time.Time { return t.In(displayZone) }
+
+// session is the member signed in with `login`, if any. While it lasts,
+// member commands act as them without asking for an ID or password.
+var session *library.Session
+
+// authenticateUser prompts for and verifies user credentials, unless
+// memberID is the member logged in at the prompt.
+func authenticateUser(sc *bufio.Scanner, mgr *library.LibraryManager, memberID int64) error {
+	if session != nil && session.MemberID() == memberID && session.Touch() == nil {
+		return nil
+	}
+	_, err := signIn(sc, mgr, memberID)
+	return err
+}
+
+// signIn asks for memberID's password and opens a session for them. The
+// first time a member signs in during a run they also get a one-line
+// summary of what needs their attention.
+func signIn(sc *bufio.Scanner, mgr *library.LibraryManager, memberID int64) (*library.Session, error) {
+	password, err := readPassword("Enter your password: ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read password: %w", err)
+	}
+
+	sess, err := mgr.Login(memberID, password, sessionTimeout)
+	if errors.Is(err, library.ErrPasswordChangeRequired) {
+		if password, err = promptPasswordChange(mgr, memberID, password); err != nil {
+			return nil, err
+		}
+		sess, err = mgr.Login(memberID, password, sessionTimeout)
+	}
+	if err != nil {
+		countError(telemetry.ErrAuth)
+		return nil, err
+	}
+
+	if !greeted[memberID] {
+		greeted[memberID] = true
+		if s, err := mgr.GetAccountSummary(memberID, dueSoonWindow); err == nil && s.String() != "" {
+			fmt.Printf("📌 %s\n", s)
+		}
+	}
+	return sess, nil
+}
+
+// memberSession returns the logged-in member's session, or asks who the
+// member is and signs them in for just this command.
+func memberSession(sc *bufio.Scanner, mgr *library.LibraryManager) (*library.Session, bool) {
+	if session != nil {
+		if session.Touch() == nil {
+			return session, true
+		}
+		fmt.Println("Your session has expired; logged out.")
+		session = nil
+	}
+	memberID, ok := promptMemberID(sc, "Member ID: ")
+	if !ok {
+		return nil, false
+	}
+	sess, err := signIn(sc, mgr, memberID)
+	if err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return nil, false
+	}
+	return sess, true
+}
+
+// promptPasswordChange makes a member whose password staff have expired
+// choose a new one, other than current, before carrying on, and returns it.
+func promptPasswordChange(mgr *library.LibraryManager, memberID int64, current string) (string, error) {
+	fmt.Println("Your password has expired and must be changed before you continue.")
+	newPassword, err := readPassword("New password: ")
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	confirm, err := readPassword("Confirm new password: ")
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	if newPassword != confirm {
+		return "", fmt.Errorf("passwords do not match")
+	}
+	if err := mgr.ChangeMemberPassword(memberID, current, newPassword); err != nil {
+		return "", err
+	}
+	fmt.Println("✓ Password changed")
+	return newPassword, nil
+}
+
+func handleLogin(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	memberID, ok := promptMemberID(sc, "Member ID: ")
+	if !ok {
+		return
+	}
+	sess, err := signIn(sc, mgr, memberID)
+	if err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+	if session != nil {
+		session.Logout()
+	}
+	session = sess
+	name := fmt.Sprintf("member %d", memberID)
+	if m, err := mgr.GetMember(memberID); err == nil {
+		name = m.Name
+	}
+	fmt.Printf("✓ Logged in as %s. The session ends after %d minutes idle, or with logout.\n", name, int(sessionTimeout.Minutes()))
+}
+
+func handleLogout() {
+	if session == nil {
+		fmt.Println("Not logged in.")
+		return
+	}
+	session.Logout()
+	session = nil
+	fmt.Println("✓ Logged out")
+}
+
+// Who must sign in to run a command.
+const (
+	authNone   = ""
+	authMember = "member" // the member's ID and password, or a login session
+	authStaff  = "staff"  // a staff member's ID and password
+)
+
+// commandInfo describes a shell command for the command list and `help`.
+type commandInfo struct {
+	Name     string
+	Args     string // usage after the name, e.g. "<id> [--word \"ring\"]"
+	Category string
+	Auth     string
+	Summary  string
+	Examples []string
+	// Capability, when set, hides the command while that subsystem is off.
+	Capability string
+}
+
+func (c commandInfo) usage() string {
+	return strings.TrimSpace(c.Name + " " + c.Args)
+}
+
+// commands lists every shell command, grouped by category in the order the
+// command list shows them.
+var commands = []commandInfo{
+	{Name: "add book", Category: "Books", Summary: "Add a book to the catalog, with its text file and optional publication details."},
+	{Name: "add copy", Args: "[<id>]", Category: "Books", Summary: "Add another copy of a book, with the same text, details and tags.", Examples: []string{"add copy 12"}},
+	{Name: "list books", Args: "[--tag <tag>]", Category: "Books", Summary: "List the catalog with each book's availability and reservation queue, or only the books with a tag.",
+		Examples: []string{"list books", "list books --tag science fiction"}},
+	{Name: "search book", Args: "[--tag <tag>]", Category: "Books", Summary: "Search titles, authors and text; the query syntax is in the README.",
+		Examples: []string{"search book", "search book --tag classics"}},
+	{Name: "similar", Args: "<id>", Category: "Books", Summary: "List the books whose text most resembles a book's.", Examples: []string{"similar 12"}},
+	{Name: "update content", Category: "Books", Summary: "Replace a book's text from a file."},
+	{Name: "set metadata", Category: "Books", Auth: authStaff, Summary: "Change a book's ISBN, publisher, year, language and page count."},
+	{Name: "edit book", Args: "[<id>]", Category: "Books", Auth: authStaff, Summary: "Correct a book's title and author, and optionally its publication details.", Examples: []string{"edit book 12"}},
+
+	{Name: "tags", Args: "[<book id>]", Category: "Subjects", Summary: "List the tags in use with their book counts, or a book's tags.", Examples: []string{"tags", "tags 12"}},
+	{Name: "tag book", Args: "<id> <tag>", Category: "Subjects", Auth: authStaff, Summary: "File a book under a subject or genre.", Examples: []string{`tag book 12 "science fiction"`}},
+	{Name: "untag book", Args: "<id> <tag>", Category: "Subjects", Auth: authStaff, Summary: "Take a tag off a book.", Examples: []string{"untag book 12 classics"}},
+
+	{Name: "add item", Category: "Equipment", Auth: authStaff, Summary: "Add a non-book item such as a laptop or a museum pass."},
+	{Name: "item types", Category: "Equipment", Summary: "List the item types with their loan periods, fines and deposits."},
+
+	{Name: "add member", Category: "Members", Summary: "Register a member with a password and membership tier."},
+	{Name: "list members", Category: "Members", Summary: "List members with their tier, staff rights and card expiry."},
+	{Name: "reset password", Category: "Members", Auth: authStaff, Summary: "Set a new password for a member."},
+	{Name: "change password", Category: "Members", Auth: authMember, Summary: "Change your own password; needs the current one."},
+	{Name: "rename member", Args: "[<id>]", Category: "Members", Auth: authStaff, Summary: "Change a member's name.", Examples: []string{"rename member 4"}},
+	{Name: "revoke tokens", Category: "Members", Auth: authStaff, Summary: "Sign a member out of every API client."},
+	{Name: "grant admin", Category: "Members", Auth: authStaff, Summary: "Give a member staff rights; the first staff account needs no sign-in."},
+	{Name: "set tier", Category: "Members", Auth: authStaff, Summary: "Move a member to another membership tier."},
+	{Name: "renew membership", Category: "Members", Auth: authStaff, Summary: "Extend a member's card."},
+	{Name: "expiring members", Category: "Members", Auth: authStaff, Summary: "List the cards that expire soon."},
+	{Name: "deactivate member", Args: "<id>", Category: "Members", Auth: authStaff, Summary: "Close a departed member's account, cancelling their holds.", Examples: []string{"deactivate member 4"}},
+	{Name: "reactivate member", Args: "<id>", Category: "Members", Auth: authStaff, Summary: "Reopen a deactivated account."},
+	{Name: "delete member", Args: "<id>", Category: "Members", Auth: authStaff, Summary: "Remove an account that never borrowed anything."},
+
+	{Name: "show member", Args: "<id>", Category: "Member records", Auth: authStaff, Summary: "Show a member's record, notes and alerts.", Examples: []string{"show member 4"}},
+	{Name: "note add member", Args: `<id> "text"`, Category: "Member records", Auth: authStaff, Summary: "Add a note to a member's record.",
+		Examples: []string{`note add member 4 "Prefers large print"`}},
+	{Name: "edit profile", Category: "Member records", Auth: authStaff, Summary: "Change a member's name, age group and ZIP code."},
+	{Name: "forget member", Args: "<id>", Category: "Member records", Auth: authStaff, Summary: "Erase a member's personal data and print a certificate.", Examples: []string{"forget member 4"}},
+
+	{Name: "service area report", Args: "[--as-of YYYY-MM-DD]", Category: "Reports", Auth: authStaff, Summary: "Count members and circulation by ZIP code and age group.",
+		Examples: []string{"service area report", "service area report --as-of 2026-06-30"}},
+	{Name: "usage stats", Args: "[--as-of YYYY-MM-DD]", Category: "Reports", Auth: authStaff, Summary: "Summarize checkouts, returns and the most borrowed books.",
+		Examples: []string{"usage stats", "usage stats --as-of 2026-06-30"}},
+	{Name: "analyze book", Args: `<id> [--word "ring"]`, Category: "Reports", Auth: authStaff, Summary: "Count a book's words, or list the pages a word appears on.",
+		Examples: []string{"analyze book 3", `analyze book 3 --word "ring"`}},
+	{Name: "query", Args: `[--csv|--json] "SELECT ..."`, Category: "Reports", Auth: authStaff, Summary: "Run a read-only SQL query.",
+		Examples: []string{`query "SELECT title FROM books"`, `query --csv "SELECT * FROM checkouts"`}},
+
+	{Name: "alert add member", Args: "<id>", Category: "Account alerts", Auth: authStaff, Summary: "Flag a member's account with an alert shown at the desk.", Examples: []string{"alert add member 4"}},
+	{Name: "alert clear", Category: "Account alerts", Auth: authStaff, Summary: "Clear an account alert by its number."},
+
+	{Name: "login", Category: "Session", Summary: "Sign in once for checkout, return, reserve, read and loans."},
+	{Name: "logout", Category: "Session", Summary: "End the signed-in session."},
+	{Name: "set output", Args: "json|csv|table", Category: "Session", Summary: "Print list books, list members, list reservations and search book as JSON or CSV, or as tables again.",
+		Examples: []string{"set output json", "set output table"}},
+	{Name: "recent", Category: "Session", Summary: "List the books and members used lately, which !1, !2... reuse at ID questions."},
+
+	{Name: "checkout", Category: "Circulation", Auth: authMember, Summary: "Borrow a book, or collect one on hold, by ID or title."},
+	{Name: "cart", Category: "Circulation", Auth: authMember, Summary: "Check out several books at once, with one receipt."},
+	{Name: "return", Category: "Circulation", Auth: authMember, Summary: "Return a borrowed book."},
+	{Name: "return all", Args: "[--member <id>]", Category: "Circulation", Auth: authMember, Summary: "Return everything a member has out, as the member, a delegate or staff.",
+		Examples: []string{"return all", "return all --member 4"}},
+	{Name: "reserve", Category: "Circulation", Auth: authMember, Summary: "Join the queue for a book on loan, by ID or title."},
+	{Name: "reserve bulk", Args: "--book <id> --members <ids>", Category: "Circulation", Auth: authStaff, Summary: "Reserve a book for several members at once.",
+		Examples: []string{"reserve bulk --book 7 --members 2,5,9"}},
+	{Name: "list reservations", Category: "Circulation", Summary: "Show the reservation queue of a book, or of every book."},
+	{Name: "cancel reservation", Category: "Circulation", Auth: authMember, Summary: "Leave a book's reservation queue."},
+	{Name: "verify pickup", Args: "<code>", Category: "Circulation", Summary: "Open a pickup locker with its one-time code.", Examples: []string{"verify pickup 482913"}},
+	{Name: "pickup hold", Category: "Circulation", Auth: authMember, Summary: "Collect a hold, yours or one you are a delegate for."},
+	{Name: "holds", Category: "Circulation", Auth: authStaff, Summary: "List the hold shelf with each pickup deadline."},
+	{Name: "fulfill hold", Args: "[<book id> <member id>]", Category: "Circulation", Auth: authStaff, Summary: "Check out a hold to the member collecting it at the desk.",
+		Examples: []string{"fulfill hold 12 4"}},
+
+	{Name: "loans", Category: "Loans", Auth: authMember, Summary: "List your open loans with due dates."},
+	{Name: "fines", Category: "Loans", Auth: authMember, Summary: "List the charges on your account.", Capability: library.CapFines},
+	{Name: "history", Category: "Loans", Auth: authMember, Summary: "List everything you have borrowed."},
+	{Name: "history member", Args: "<id>", Category: "Loans", Auth: authStaff, Summary: "List everything a member has borrowed.", Examples: []string{"history member 4"}},
+	{Name: "history book", Args: "<id>", Category: "Loans", Auth: authStaff, Summary: "List who has borrowed a book.", Examples: []string{"history book 12"}},
+
+	{Name: "delegate add", Category: "Family", Auth: authMember, Summary: "Let another member pick up your holds."},
+	{Name: "delegate remove", Category: "Family", Auth: authMember, Summary: "Stop another member picking up your holds."},
+	{Name: "delegates", Category: "Family", Auth: authMember, Summary: "List who may pick up your holds."},
+
+	{Name: "board", Category: "Desk", Summary: "Show the live status board; press Enter to leave."},
+	{Name: "check in", Category: "Desk", Auth: authStaff, Summary: "Check returned books in at the desk."},
+	{Name: "claims returned", Category: "Desk", Auth: authStaff, Summary: "Record that a member says they returned a book."},
+	{Name: "resolve claim", Category: "Desk", Auth: authStaff, Summary: "Settle a claims-returned loan once the copy is found or not."},
+	{Name: "shelf search", Category: "Desk", Auth: authStaff, Summary: "List the copies claimed returned, to look for on the shelves."},
+	{Name: "mark lost", Category: "Desk", Auth: authStaff, Summary: "Mark a loan lost and charge for the copy."},
+	{Name: "set price", Category: "Desk", Auth: authStaff, Summary: "Set a book's replacement price."},
+	{Name: "set reference", Category: "Desk", Auth: authStaff, Summary: "Make a book reference only, or lendable again."},
+	{Name: "set licenses", Category: "Desk", Auth: authStaff, Summary: "Set how many members can read a digital book at once."},
+	{Name: "in-library use", Args: "<bookID>", Category: "Desk", Summary: "Count a book used in the library without a loan.", Examples: []string{"in-library use 12"}},
+
+	{Name: "read book", Category: "Reading", Auth: authMember, Summary: "Read a book page by page, borrowing it if needed."},
+	{Name: "return digital", Category: "Reading", Auth: authMember, Summary: "End a digital loan early."},
+	{Name: "search notes", Args: "<query>", Category: "Reading", Auth: authMember, Summary: "Search your notes and highlights.", Examples: []string{"search notes dragon"}},
+
+	{Name: "collections", Category: "Reading lists", Summary: "List the reading lists."},
+	{Name: "collection show", Args: "<name>", Category: "Reading lists", Summary: "Show a reading list.", Examples: []string{"collection show Summer Reading"}},
+	{Name: "collection export-pdf", Args: "<name>", Category: "Reading lists", Summary: "Save a reading list as a printable PDF handout."},
+	{Name: "collection create", Args: "<name>", Category: "Reading lists", Auth: authStaff, Summary: "Start a reading list."},
+	{Name: "collection add", Args: "<name>", Category: "Reading lists", Auth: authStaff, Summary: "Add a book to a reading list, with a blurb."},
+	{Name: "collection remove", Args: "<name>", Category: "Reading lists", Auth: authStaff, Summary: "Take a book off a reading list."},
+
+	{Name: "notifications", Category: "Messages", Auth: authMember, Summary: "Read your notices."},
+	{Name: "announce", Category: "Messages", Summary: "Send a notice to every member."},
+	{Name: "email settings", Category: "Messages", Auth: authMember, Summary: "Set the address reminders are emailed to and which ones you get.", Capability: library.CapEmail},
+	{Name: "notify", Category: "Messages", Auth: authStaff, Summary: "Email the reminders that are due now.", Capability: library.CapEmail},
+
+	{Name: "export books", Args: "[--editable] <file.csv|file.json>", Category: "Migration", Auth: authStaff, Summary: "Export the catalog, or with --editable a CSV to correct in a spreadsheet and apply.",
+		Examples: []string{"export books catalog.csv", "export books --editable cleanup.csv"}},
+	{Name: "export members", Args: "<file.csv|file.json>", Category: "Migration", Auth: authStaff, Summary: "Export the members.", Examples: []string{"export members members.json"}},
+	{Name: "export circulation", Category: "Migration", Auth: authStaff, Summary: "Export open loans, holds and charges for a move to another installation."},
+	{Name: "apply books", Args: "<file.csv>", Category: "Migration", Auth: authStaff, Summary: "Preview and apply the changes made to an editable export of the catalog.", Examples: []string{"apply books cleanup.csv"}},
+	{Name: "import books", Args: "<file.csv>", Category: "Migration", Auth: authStaff, Summary: "Add the books listed in a CSV file.", Examples: []string{"import books new-titles.csv"}},
+	{Name: "import members", Args: "<file.csv>", Category: "Migration", Auth: authStaff, Summary: "Add the members listed in a CSV file."},
+	{Name: "import circulation", Category: "Migration", Auth: authStaff, Summary: "Import the circulation export of another installation."},
+	{Name: "import legacy", Category: "Migration", Auth: authStaff, Summary: "Import the data of the old JSON version."},
+
+	{Name: "security force-reset", Args: "--all | --since YYYY-MM-DD", Category: "Security", Auth: authStaff, Summary: "Make members choose a new password at their next sign-in.",
+		Examples: []string{"security force-reset --all", "security force-reset --since 2026-01-01"}},
+	{Name: "audit verify", Category: "Security", Auth: authStaff, Summary: "Check the audit log has not been tampered with."},
+	{Name: "view audit", Args: "[--member <id>] [--book <id>] [--since YYYY-MM-DD] [--until YYYY-MM-DD]", Category: "Security", Auth: authStaff,
+		Summary:  "Show who did what: books added, checkouts, returns, password resets and account changes.",
+		Examples: []string{"view audit --member 42", "view audit --book 12 --since 2026-01-01 --until 2026-01-31"}},
+
+	{Name: "capabilities", Category: "System", Summary: "List which optional subsystems are switched on."},
+	{Name: "telemetry show", Category: "System", Summary: "Show whether anonymous usage counts are shared, and exactly what the next report holds."},
+	{Name: "version", Category: "System", Summary: "Show the program's version and build, and the database's schema version."},
+	{Name: "run jobs", Category: "System", Summary: "Run every scheduled job now and report how each went."},
+	{Name: "db maintain", Category: "System", Auth: authStaff, Summary: "Analyze, compact and checkpoint the database."},
+	{Name: "validate catalog", Args: "[--fix]", Category: "System", Auth: authStaff, Summary: "Look for inconsistent catalog records, and optionally repair them.",
+		Examples: []string{"validate catalog", "validate catalog --fix"}},
+	{Name: "offload content", Category: "System", Auth: authStaff, Summary: "Move long book texts to the external content store."},
+	{Name: "backup schedule", Category: "System", Auth: authStaff, Summary: "Set up nightly backups."},
+	{Name: "backup verify", Category: "System", Auth: authStaff, Summary: "Check a backup snapshot can be restored."},
+	{Name: "help", Args: "[<command>]", Category: "System", Summary: "List the commands, or show how to use one.", Examples: []string{"help", "help checkout", "help collection"}},
+	{Name: "exit", Category: "System", Summary: "Leave the program."},
+}
+
+// printCommandList prints the commands by category, leaving out those of
+// subsystems that are switched off.
+func printCommandList(mgr *library.LibraryManager) {
+	var categories []string
+	byCategory := make(map[string][]commandInfo)
+	for _, c := range commands {
+		if c.Capability != "" && !capabilityEnabled(mgr, c.Capability) {
+			continue
+		}
+		if _, ok := byCategory[c.Category]; !ok {
+			categories = append(categories, c.Category)
+		}
+		byCategory[c.Category] = append(byCategory[c.Category], c)
+	}
+
+	fmt.Println("Available commands:")
+	for _, category := range categories {
+		cmds := byCategory[category]
+		// A category of staff commands says so once
+		allStaff := true
+		for _, c := range cmds {
+			allStaff = allStaff && c.Auth == authStaff
+		}
+		names := make([]string, len(cmds))
+		for i, c := range cmds {
+			names[i] = c.usage()
+			if c.Auth == authStaff && !allStaff {
+				names[i] += " (staff)"
+			}
+		}
+		label := category
+		if allStaff {
+			label += " (staff)"
+		}
+		fmt.Printf("  %s: %s\n", label, strings.Join(names, ", "))
+	}
+	fmt.Println("Type help <command> for how to use one, or ? at any question for what it expects.")
+}
+
+// handleHelp lists the commands, or explains those named by topic: an
+// exact command, or every command starting with it.
+func handleHelp(mgr *library.LibraryManager, topic string) {
+	topic = strings.ToLower(strings.Join(strings.Fields(topic), " "))
+	if topic == "" {
+		printCommandList(mgr)
+		return
+	}
+
+	var matches []commandInfo
+	for _, c := range commands {
+		if c.Name == topic {
+			matches = []commandInfo{c}
+			break
+		}
+		if strings.HasPrefix(c.Name, topic) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		fmt.Printf("No command %q. Type help to list them.\n", topic)
+		return
+	}
+	for i, c := range matches {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(c.usage())
+		fmt.Printf("  %s\n", c.Summary)
+		switch c.Auth {
+		case authMember:
+			fmt.Println("  Sign-in: the member's ID and password, or a login session")
+		case authStaff:
+			fmt.Println("  Sign-in: a staff member's ID and password")
+		}
+		if c.Capability != "" && !capabilityEnabled(mgr, c.Capability) {
+			fmt.Printf("  Not available: %s is switched off in this library (see capabilities)\n", c.Capability)
+		}
+		if len(c.Examples) > 0 {
+			fmt.Println("  Examples:")
+			for _, ex := range c.Examples {
+				fmt.Printf("    %s\n", ex)
+			}
+		}
+	}
+}
+
+// lastPrompt is the question the shell asked last, for `?` to explain.
+var lastPrompt string
+
+// commandPrompt is the shell's prompt for the next command.
+const commandPrompt = "\n> "
+
+// prompt asks a question and remembers it, so that answering `?` can say
+// what is expected.
+func prompt(format string, args ...any) {
+	lastPrompt = fmt.Sprintf(format, args...)
+	fmt.Fprint(promptOutput(), lastPrompt)
+}
+
+// outputFormats are the formats listings can be printed in: the aligned
+// tables people read, or JSON or CSV for jq and scripts.
+var outputFormats = []string{"table", "json", "csv"}
+
+// outputFormat is how listings print, set by --output or `set output`.
+var outputFormat = "table"
+
+// machineOutput reports whether listings print as JSON or CSV.
+func machineOutput() bool {
+	return outputFormat != "table"
+}
+
+// promptOutput is where questions go: standard error while listings print
+// as JSON or CSV, so that standard output carries only the data.
+func promptOutput() io.Writer {
+	if machineOutput() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// setOutputFormat makes format how listings print.
+func setOutputFormat(format string) error {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if !slices.Contains(outputFormats, format) {
+		return fmt.Errorf("unknown output format %q (use %s)", format, strings.Join(outputFormats, ", "))
+	}
+	outputFormat = format
+	return nil
+}
+
+// parseOutputFlag takes a leading `--output <format>` or `--output=<format>`
+// off args, which are the program's arguments after its name, and returns
+// the rest and the format, or "" if none was given.
+func parseOutputFlag(args []string) ([]string, string, error) {
+	if len(args) == 0 {
+		return args, "", nil
+	}
+	format, ok := strings.CutPrefix(args[0], "--output=")
+	switch {
+	case ok:
+		args = args[1:]
+	case args[0] == "--output":
+		if len(args) < 2 {
+			return args, "", fmt.Errorf("--output needs a format (%s)", strings.Join(outputFormats, ", "))
+		}
+		format, args = args[1], args[2:]
+	default:
+		return args, "", nil
+	}
+	if !slices.Contains(outputFormats, format) {
+		return args, "", fmt.Errorf("unknown output format %q (use %s)", format, strings.Join(outputFormats, ", "))
+	}
+	return args, format, nil
+}
+
+// writeRecords prints a listing in the chosen JSON or CSV format, one
+// record per row with values typed as they are for `query`.
+func writeRecords(columns []string, rows [][]any) {
+	if err := writeQueryResult(os.Stdout, &library.QueryResult{Columns: columns, Rows: rows}, outputFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+}
+
+// handleSetOutput runs `set output json|csv|table`.
+func handleSetOutput(args string) {
+	if strings.TrimSpace(args) == "" {
+		fmt.Printf("Listings print as %s. Usage: set output %s\n", outputFormat, strings.Join(outputFormats, "|"))
+		return
+	}
+	if err := setOutputFormat(args); err != nil {
+		printError(err)
+		return
+	}
+	fmt.Fprintf(promptOutput(), "✓ Listings now print as %s\n", outputFormat)
+}
+
+// scanInput splits input into lines like bufio.ScanLines, except that a
+// lone "?" is answered with a hint for the question being asked, which is
+// then asked again, instead of being taken as the answer.
+func scanInput(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := bufio.ScanLines(data, atEOF)
+	if token != nil && strings.TrimSpace(string(token)) == "?" {
+		if lastPrompt == commandPrompt {
+			fmt.Println("Type a command; help lists them and help <command> explains one.")
+		} else {
+			fmt.Println(promptHint(lastPrompt))
+		}
+		fmt.Fprint(promptOutput(), lastPrompt)
+		return advance, nil, nil
+	}
+	return advance, token, err
+}
+
+// promptHints describe the answers to common questions, by a word or two
+// of the question.
+var promptHints = []struct{ match, hint string }{
+	{"staff member id", "Your own member ID; the account needs staff rights."},
+	{"book id or title", "A book's number, #n for result n of the last search, !n for a recently used book, or words from its title to pick from the matches."},
+	{"book id", "A book's number, from the ID column of list books or search book, #n for result n of the last search, or !n for the nth most recently used book."},
+	{"member id", "A member's number, from the ID column of list members, or !n for the nth most recently used member."},
+	{"yyyy-mm-dd", "A date written year-month-day, e.g. 2026-03-01."},
+	{"days", "A whole number of days."},
+	{"file", "A file path, relative to the directory the program runs in."},
+	{"email", "An address such as name@example.org."},
+	{"query", "Words to search for. Combine them with OR and NOT, quote \"exact phrases\", and end a word with * to match its start."},
+	{"zip", "A 5-digit ZIP code."},
+}
+
+// promptHint explains what the question label expects: what its words
+// usually mean, the choices listed in parentheses and the default in
+// brackets.
+func promptHint(label string) string {
+	q := strings.ToLower(strings.TrimSpace(label))
+	var parts []string
+	for _, h := range promptHints {
+		if strings.Contains(q, h.match) {
+			parts = append(parts, h.hint)
+			break
+		}
+	}
+	if open := strings.LastIndex(q, "("); open >= 0 {
+		if end := strings.Index(q[open:], ")"); end > 0 {
+			inner := q[open+1 : open+end]
+			switch {
+			case inner == "y/n":
+				parts = append(parts, "Answer y or n.")
+			case strings.Contains(inner, "/") && !strings.Contains(inner, " "):
+				parts = append(parts, "One of: "+strings.ReplaceAll(inner, "/", ", ")+".")
+			}
+		}
+	}
+	if open := strings.LastIndex(label, "["); open >= 0 {
+		if end := strings.Index(label[open:], "]"); end > 0 {
+			parts = append(parts, fmt.Sprintf("Press Enter for %s.", label[open+1:open+end]))
+		}
+	}
+	if len(parts) == 0 {
+		return "Type your answer and press Enter."
+	}
+	return strings.Join(parts, " ")
+}
+
+func main() {
+	// A leading --output picks how listings print, over LIBRARY_OUTPUT
+	args, output, err := parseOutputFlag(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	os.Args = append(os.Args[:1], args...)
+
+	// `init` sets up a new library and writes the config file the other
+	// commands read.
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		if err := runInit(); err != nil {
+			fmt.Fprintf(os.Stderr, "Setup failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := loadConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if err := loadDisplayZone(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	cfg, err := configFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if output == "" {
+		output = cfg.Output
+	}
+	outputFormat, listPageSize = output, cfg.PageSize
+
+	if len(os.Args) > 1 && os.Args[1] == "remote" {
+		if err := runRemote(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "restore" {
+		if err := runRestore(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Restore failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "self-update" {
+		if err := runSelfUpdate(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Update failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// `serve` with LIBRARY_TENANTS_DIR set hosts every library in that
+	// directory instead of the single local database.
+	if dir := os.Getenv("LIBRARY_TENANTS_DIR"); dir != "" && len(os.Args) > 1 && os.Args[1] == "serve" {
+		if err := serveTenants(dir); err != nil {
+			fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// LIBRARY_DB points the prompt at another database file, e.g. one of
+	// the libraries in LIBRARY_TENANTS_DIR.
+	path := cfg.DBPath
+	manager, err := library.NewLibraryManager(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer manager.Close()
+
+	if err := configureManager(manager); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "version" {
+		handleVersion(manager)
+		return
+	}
+
+	// Any other first argument names an external command; it runs instead
+	// of the prompt and its exit status becomes ours.
+	if len(os.Args) > 1 && os.Args[1] != "serve" {
+		code, err := runPlugin(manager, path, os.Args[1], os.Args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+		manager.Close()
+		os.Exit(code)
+	}
+
+	jobs, stopJobs, err := startJobs(manager)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting scheduled jobs: %v\n", err)
+		os.Exit(1)
+	}
+	defer close(stopJobs)
+
+	// `serve [addr]` runs the HTTP API instead of the interactive prompt
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		addr := defaultHTTPAddr
+		if len(os.Args) > 2 {
+			addr = os.Args[2]
+		}
+		manager.EnableCache(serverCacheSize)
+		manager.SetCapability(library.CapServer, true, addr)
+		fmt.Printf("Serving the library API on %s\n", addr)
+		if err := http.ListenAndServe(addr, api.NewServer(manager)); err != nil {
+			fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := openTelemetry(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	defer closeTelemetry()
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Split(scanInput)
+	runPrompt(scanner, manager, jobs)
+}
+
+// runPrompt runs the interactive shell, reading commands from scanner until
+// exit or the end of input. The book reader reads from scanner too, so
+// answers are taken in order; passwords come from the terminal.
+func runPrompt(scanner *bufio.Scanner, manager *library.LibraryManager, jobs *library.JobRunner) {
+	manager.SetInput(scanner)
+
+	// Reading JSON or CSV from standard output, a script has no use for
+	// the welcome
+	if !machineOutput() {
+		fmt.Println("Welcome to the Library Management System with Secure Authentication!")
+		printCommandList(manager)
+		fmt.Println()
+		fmt.Println("Tips:")
+		fmt.Println("  • For 'list reservations': Enter a Book ID for specific book, or press Enter to see all books")
+	}
+
+	for {
+		prompt(commandPrompt)
+		if !scanner.Scan() {
+			break
+		}
+		cmd := strings.TrimSpace(scanner.Text())
+		if name := commandName(cmd); name != "" {
+			countFeature(name)
+		}
+
+		switch cmd {
+		case "add book":
+			handleAddBook(scanner, manager)
+		case "add item":
+			handleAddItem(scanner, manager)
+		case "item types":
+			handleItemTypes(manager)
+		case "add member":
+			handleAddMember(scanner, manager)
+		case "list members":
+			handleListMembers(scanner, manager)
+		case "checkout":
+			handleCheckout(scanner, manager)
+		case "cart":
+			handleCart(scanner, manager)
+		case "return":
+			handleReturn(scanner, manager)
+		case "reserve":
+			handleReserve(scanner, manager)
+		case "list reservations":
+			handleListReservations(scanner, manager)
+		case "cancel reservation":
+			handleCancelReservation(scanner, manager)
+		case "update content":
+			handleUpdateContent(scanner, manager)
+		case "read book":
+			handleReadBook(scanner, manager)
+		case "return digital":
+			handleReturnDigital(scanner, manager)
+		case "change password":
+			handleChangePassword(scanner, manager)
+		case "reset password":
+			handleResetPassword(scanner, manager)
+		case "check in":
+			handleCheckIn(scanner, manager)
+		case "loans":
+			handleLoans(scanner, manager)
+		case "history":
+			handleHistory(scanner, manager)
+		case "fines":
+			handleFines(scanner, manager)
+		case "mark lost":
+			handleMarkLost(scanner, manager)
+		case "set metadata":
+			handleSetMetadata(scanner, manager)
+		case "set price":
+			handleSetPrice(scanner, manager)
+		case "set reference":
+			handleSetReference(scanner, manager)
+		case "set licenses":
+			handleSetLicenses(scanner, manager)
+		case "claims returned":
+			handleClaimsReturned(scanner, manager)
+		case "resolve claim":
+			handleResolveClaim(scanner, manager)
+		case "shelf search":
+			handleShelfSearch(scanner, manager)
+		case "edit profile":
+			handleEditProfile(scanner, manager)
+		case "set tier":
+			handleSetTier(scanner, manager)
+		case "renew membership":
+			handleRenewMembership(scanner, manager)
+		case "expiring members":
+			handleExpiringMembers(scanner, manager)
+		case "revoke tokens":
+			handleRevokeTokens(scanner, manager)
+		case "grant admin":
+			handleGrantAdmin(scanner, manager)
+		case "notifications":
+			handleNotifications(scanner, manager)
+		case "email settings":
+			handleEmailSettings(scanner, manager)
+		case "notify":
+			handleNotify(scanner, manager)
+		case "capabilities":
+			handleCapabilities(manager)
+		case "version":
+			handleVersion(manager)
+		case "announce":
+			handleAnnounce(scanner, manager)
+		case "export circulation":
+			handleExportCirculation(scanner, manager)
+		case "import circulation":
+			handleImportCirculation(scanner, manager)
+		case "import legacy":
+			handleImportLegacy(scanner, manager)
+		case "backup schedule":
+			handleBackupSchedule(scanner, manager)
+		case "backup verify":
+			handleBackupVerify(scanner, manager)
+		case "offload content":
+			handleOffloadContent(scanner, manager)
+		case "delegate add":
+			handleDelegate(scanner, manager, true)
+		case "delegate remove":
+			handleDelegate(scanner, manager, false)
+		case "delegates":
+			handleListDelegates(scanner, manager)
+		case "pickup hold":
+			handlePickUpHold(scanner, manager)
+		case "audit verify":
+			handleAuditVerify(scanner, manager)
+		case "board":
+			handleBoard(scanner, manager)
+		case "holds":
+			handleHolds(scanner, manager)
+		case "db maintain":
+			handleDBMaintain(scanner, manager)
+		case "run jobs":
+			handleRunJobs(jobs)
+		case "login":
+			handleLogin(scanner, manager)
+		case "logout":
+			handleLogout()
+		case "recent":
+			handleRecent(manager)
+		case "exit":
+			fmt.Println("Goodbye!")
+			return
+		default:
+			switch {
+			case strings.HasPrefix(cmd, "note add member"):
+				handleAddMemberNote(scanner, manager, strings.TrimPrefix(cmd, "note add member"))
+			case strings.HasPrefix(cmd, "alert add member"):
+				handleAddMemberAlert(scanner, manager, strings.TrimPrefix(cmd, "alert add member"))
+			case cmd == "alert clear":
+				handleClearAlert(scanner, manager)
+			case strings.HasPrefix(cmd, "in-library use"):
+				handleInLibraryUse(strings.TrimPrefix(cmd, "in-library use"), manager)
+			case strings.HasPrefix(cmd, "fulfill hold"):
+				handleFulfillHold(scanner, manager, strings.TrimPrefix(cmd, "fulfill hold"))
+			case strings.HasPrefix(cmd, "verify pickup"):
+				handleVerifyPickup(strings.TrimPrefix(cmd, "verify pickup"), manager)
+			case strings.HasPrefix(cmd, "reserve bulk"):
+				handleReserveBulk(scanner, manager, strings.TrimPrefix(cmd, "reserve bulk"))
+			case strings.HasPrefix(cmd, "deactivate member"):
+				handleMemberStatus(scanner, manager, "deactivate", strings.TrimPrefix(cmd, "deactivate member"))
+			case strings.HasPrefix(cmd, "reactivate member"):
+				handleMemberStatus(scanner, manager, "reactivate", strings.TrimPrefix(cmd, "reactivate member"))
+			case strings.HasPrefix(cmd, "delete member"):
+				handleMemberStatus(scanner, manager, "delete", strings.TrimPrefix(cmd, "delete member"))
+			case strings.HasPrefix(cmd, "rename member"):
+				handleRenameMember(scanner, manager, strings.TrimPrefix(cmd, "rename member"))
+			case strings.HasPrefix(cmd, "return all"):
+				handleReturnAll(scanner, manager, strings.TrimPrefix(cmd, "return all"))
+			case strings.HasPrefix(cmd, "edit book"):
+				handleEditBook(scanner, manager, strings.TrimPrefix(cmd, "edit book"))
+			case strings.HasPrefix(cmd, "forget member"):
+				handleForgetMember(scanner, manager, strings.TrimPrefix(cmd, "forget member"))
+			case cmd == "similar" || strings.HasPrefix(cmd, "similar "):
+				handleSimilar(scanner, manager, strings.TrimPrefix(cmd, "similar"))
+			case strings.HasPrefix(cmd, "history member") || strings.HasPrefix(cmd, "history book"):
+				handleScopedHistory(scanner, manager, strings.TrimPrefix(cmd, "history "))
+			case cmd == "usage stats" || strings.HasPrefix(cmd, "usage stats "):
+				handleUsageStats(scanner, manager, strings.TrimPrefix(cmd, "usage stats"))
+			case cmd == "service area report" || strings.HasPrefix(cmd, "service area report "):
+				handleServiceAreaReport(scanner, manager, strings.TrimPrefix(cmd, "service area report"))
+			case strings.HasPrefix(cmd, "analyze book"):
+				handleAnalyzeBook(scanner, manager, strings.TrimPrefix(cmd, "analyze book"))
+			case strings.HasPrefix(cmd, "show member"):
+				handleShowMember(scanner, manager, strings.TrimPrefix(cmd, "show member"))
+			case cmd == "view audit" || strings.HasPrefix(cmd, "view audit "):
+				handleViewAudit(scanner, manager, strings.TrimPrefix(cmd, "view audit"))
+			case strings.HasPrefix(cmd, "security force-reset"):
+				handleForceReset(scanner, manager, strings.TrimPrefix(cmd, "security force-reset"))
+			case strings.HasPrefix(cmd, "export books") || strings.HasPrefix(cmd, "export members"):
+				handleExport(scanner, manager, strings.TrimPrefix(cmd, "export "))
+			case strings.HasPrefix(cmd, "validate catalog"):
+				handleValidateCatalog(scanner, manager, strings.TrimPrefix(cmd, "validate catalog"))
+			case strings.HasPrefix(cmd, "add copy"):
+				handleAddCopy(scanner, manager, strings.TrimPrefix(cmd, "add copy"))
+			case strings.HasPrefix(cmd, "apply books"):
+				handleApplyBooks(scanner, manager, strings.TrimPrefix(cmd, "apply books"))
+			case cmd == "search notes" || strings.HasPrefix(cmd, "search notes "):
+				handleSearchNotes(scanner, manager, strings.TrimPrefix(cmd, "search notes"))
+			case strings.HasPrefix(cmd, "import books") || strings.HasPrefix(cmd, "import members"):
+				handleImportCSV(scanner, manager, strings.TrimPrefix(cmd, "import "))
+			case cmd == "collections":
+				handleListCollections(manager)
+			case cmd == "list books" || strings.HasPrefix(cmd, "list books "):
+				handleListBooks(scanner, manager, strings.TrimPrefix(cmd, "list books"))
+			case cmd == "search book" || strings.HasPrefix(cmd, "search book "):
+				handleSearchBooks(scanner, manager, strings.TrimPrefix(cmd, "search book"))
+			case strings.HasPrefix(cmd, "tag book"):
+				handleTagBook(scanner, manager, true, strings.TrimPrefix(cmd, "tag book"))
+			case strings.HasPrefix(cmd, "untag book"):
+				handleTagBook(scanner, manager, false, strings.TrimPrefix(cmd, "untag book"))
+			case cmd == "help" || strings.HasPrefix(cmd, "help "):
+				handleHelp(manager, strings.TrimPrefix(cmd, "help"))
+			case cmd == "tags" || strings.HasPrefix(cmd, "tags "):
+				handleTags(manager, strings.TrimPrefix(cmd, "tags"))
+			case strings.HasPrefix(cmd, "collection "):
+				handleCollection(scanner, manager, strings.TrimPrefix(cmd, "collection "))
+			case cmd == "set output" || strings.HasPrefix(cmd, "set output "):
+				handleSetOutput(strings.TrimPrefix(cmd, "set output"))
+			case cmd == "query" || strings.HasPrefix(cmd, "query "):
+				handleQuery(scanner, manager, strings.TrimPrefix(cmd, "query"))
+			case cmd == "telemetry" || strings.HasPrefix(cmd, "telemetry "):
+				handleTelemetry(strings.TrimPrefix(cmd, "telemetry"))
+			default:
+				countError(telemetry.ErrUnknownCommand)
+				fmt.Println("Unknown command. Type help to list the commands.")
+			}
+		}
+	}
+}
+
+// handleVerifyPickup redeems a locker pickup code; the code itself is the
+// member's proof of identity.
+func handleVerifyPickup(code string, mgr *library.LibraryManager) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		fmt.Println("Usage: verify pickup <code>")
+		return
+	}
+	loan, err := mgr.VerifyPickup(code)
+	if err != nil {
+		fmt.Printf("Pickup failed: %v\n", err)
+		return
+	}
+	fmt.Printf("✓ '%s' picked up by %s (ID: %d). Due %s.\n",
+		loan.BookTitle, loan.MemberName, loan.MemberID, displayTime(loan.DueTime).Format("2006-01-02"))
+}
+
+// splitArgs separates an inline command argument string into the first word
+// and the remainder, with surrounding quotes removed from the remainder.
+func splitArgs(args string) (first, rest string) {
+	args = strings.TrimSpace(args)
+	first, rest, _ = strings.Cut(args, " ")
+	rest = strings.TrimSpace(rest)
+	if len(rest) >= 2 && rest[0] == '"' && rest[len(rest)-1] == '"' {
+		rest = rest[1 : len(rest)-1]
+	}
+	return first, rest
+}
+
+// configureManager applies the LIBRARY_* environment settings to mgr.
+func configureManager(mgr *library.LibraryManager) error {
+	// Notices give dates in the same zone as the prompt
+	mgr.SetTimezone(displayZone)
+
+	cfg, err := configFromEnv()
+	if err != nil {
+		return err
+	}
+	mgr.SetDefaultLoanDays(cfg.LoanDays)
+	mgr.SetDefaultFineRate(cfg.FineCents)
+	if err := mgr.SetBcryptCost(cfg.BcryptCost); err != nil {
+		return fmt.Errorf("invalid LIBRARY_BCRYPT_COST: %w", err)
+	}
+
+	// Email is available once LIBRARY_SMTP_ADDR names a mail server
+	mailer, err := smtpNotifier(mgr)
+	if err != nil {
+		return err
+	}
+	if mailer != nil {
+		mgr.SetCapability(library.CapEmail, true, "via "+os.Getenv("LIBRARY_SMTP_ADDR"))
+	}
+
+	// Set LIBRARY_LOCKER_PICKUP=1 to hold fulfilled reservations in the
+	// pickup lockers behind a one-time code.
+	if v := os.Getenv("LIBRARY_LOCKER_PICKUP"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid LIBRARY_LOCKER_PICKUP %q", v)
+		}
+		mgr.SetLockerPickup(enabled)
+	}
+
+	// LIBRARY_DIGITAL_LOAN_DAYS sets how long a digital loan lasts.
+	if v := os.Getenv("LIBRARY_DIGITAL_LOAN_DAYS"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days <= 0 {
+			return fmt.Errorf("invalid LIBRARY_DIGITAL_LOAN_DAYS %q", v)
+		}
+		mgr.SetDigitalLoanDays(days)
+	}
+
+	// LIBRARY_HOLD_POLICY says what happens to a returned book that members
+	// have reserved: shelf, immediate or notify.
+	if v := os.Getenv("LIBRARY_HOLD_POLICY"); v != "" {
+		if err := mgr.SetHoldPolicy(v); err != nil {
+			return fmt.Errorf("invalid LIBRARY_HOLD_POLICY %q", v)
+		}
+	}
+
+	// LIBRARY_HOLD_PICKUP_DAYS sets how long a returned book waits on hold
+	// for the next member in its queue.
+	if v := os.Getenv("LIBRARY_HOLD_PICKUP_DAYS"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days <= 0 {
+			return fmt.Errorf("invalid LIBRARY_HOLD_PICKUP_DAYS %q", v)
+		}
+		mgr.SetHoldPickupDays(days)
+	}
+
+	// LIBRARY_EVENT_LOG appends a line for every checkout, return, hold
+	// and overdue loan to a file, or to standard output when it is "-".
+	if v := os.Getenv("LIBRARY_EVENT_LOG"); v != "" {
+		w := io.Writer(os.Stdout)
+		if v != "-" {
+			f, err := os.OpenFile(v, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+			if err != nil {
+				return fmt.Errorf("open LIBRARY_EVENT_LOG: %w", err)
+			}
+			w = f
+		}
+		mgr.LogEvents(w)
+	}
+
+	// LIBRARY_DB_MAX_OPEN_CONNS and LIBRARY_DB_MAX_IDLE_CONNS size the
+	// connection pool; LIBRARY_DB_BUSY_RETRIES sets how many times a write
+	// transaction is attempted while another desk holds the database lock.
+	for _, pool := range []struct {
+		env string
+		set func(int)
+	}{
+		{"LIBRARY_DB_MAX_OPEN_CONNS", mgr.SetMaxOpenConns},
+		{"LIBRARY_DB_MAX_IDLE_CONNS", mgr.SetMaxIdleConns},
+	} {
+		if v := os.Getenv(pool.env); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid %s %q", pool.env, v)
+			}
+			pool.set(n)
+		}
+	}
+	if v := os.Getenv("LIBRARY_DB_BUSY_RETRIES"); v != "" {
+		attempts, err := strconv.Atoi(v)
+		if err != nil || attempts <= 0 {
+			return fmt.Errorf("invalid LIBRARY_DB_BUSY_RETRIES %q", v)
+		}
+		policy := library.DefaultRetryPolicy
+		policy.Attempts = attempts
+		mgr.SetRetryPolicy(policy)
+	}
+
+	// LIBRARY_CONTENT_STORE keeps long book texts in a directory (which
+	// server replicas can share) instead of the database;
+	// LIBRARY_CONTENT_INLINE_MAX is the longest text, in bytes, kept inline.
+	if dir := os.Getenv("LIBRARY_CONTENT_STORE"); dir != "" {
+		store, err := library.NewDirStore(dir)
+		if err != nil {
+			return err
+		}
+		inlineMax := library.DefaultInlineContentMax
+		if v := os.Getenv("LIBRARY_CONTENT_INLINE_MAX"); v != "" {
+			if inlineMax, err = strconv.Atoi(v); err != nil || inlineMax < 0 {
+				return fmt.Errorf("invalid LIBRARY_CONTENT_INLINE_MAX %q", v)
+			}
+		}
+		mgr.SetContentStore(store, inlineMax)
+	}
+
+	// LIBRARY_WAL_ARCHIVE ships every change to a directory for
+	// point-in-time recovery with `restore --to`.
+	if dir := os.Getenv("LIBRARY_WAL_ARCHIVE"); dir != "" {
+		store, err := library.NewDirStore(dir)
+		if err != nil {
+			return err
+		}
+		if err := mgr.EnableWALArchive(store); err != nil {
+			return fmt.Errorf("enable WAL archive: %w", err)
+		}
+	}
+	return nil
+}
+
+// serveTenants hosts every library database (*.db) in dir under
+// /{library}/, where the library's name is the file name without ".db".
+// Each library keeps its own scheduled jobs and cache.
+func serveTenants(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.db"))
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no libraries (*.db) found in %s", dir)
+	}
+
+	libraries := make(map[string]*library.LibraryManager, len(paths))
+	for _, p := range paths {
+		name := strings.TrimSuffix(filepath.Base(p), ".db")
+		if !api.ValidTenantName(name) {
+			return fmt.Errorf("invalid library name %q: use lowercase letters, digits and dashes", name)
+		}
+		mgr, err := library.NewLibraryManager(p)
+		if err != nil {
+			return fmt.Errorf("open library %s: %w", name, err)
+		}
+		defer mgr.Close()
+		if err := configureManager(mgr); err != nil {
+			return err
+		}
+		_, stopJobs, err := startJobs(mgr)
+		if err != nil {
+			return fmt.Errorf("start jobs for %s: %w", name, err)
+		}
+		defer close(stopJobs)
+		mgr.EnableCache(serverCacheSize)
+		mgr.SetCapability(library.CapServer, true, "library "+name)
+		libraries[name] = mgr
+	}
+
+	tenants, err := api.NewTenants(libraries)
+	if err != nil {
+		return err
+	}
+	addr := defaultHTTPAddr
+	if len(os.Args) > 2 {
+		addr = os.Args[2]
+	}
+	fmt.Printf("Serving %d libraries on %s\n", len(libraries), addr)
+	return http.ListenAndServe(addr, tenants)
+}
+
+// runRestore implements `restore --to <timestamp> [--from dir] [--out file]`,
+// rebuilding the database from the WAL archive as it stood at the given
+// local time. The live database is left alone; staff swap the restored file
+// in once they have checked it.
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	to := fs.String("to", "", `point in time, e.g. "2026-03-01 13:59"`)
+	from := fs.String("from", os.Getenv("LIBRARY_WAL_ARCHIVE"), "WAL archive directory (default $LIBRARY_WAL_ARCHIVE)")
+	out := fs.String("out", "library-restored.db", "file to write the restored database to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *to == "" || *from == "" {
+		fs.Usage()
+		return fmt.Errorf("--to and an archive directory are required")
+	}
+
+	// A time names the whole second or minute it covers: "13:59" restores
+	// everything committed up to 13:59:59.
+	var target time.Time
+	parsed := false
+	for _, f := range []struct {
+		layout    string
+		precision time.Duration
+	}{
+		{time.RFC3339, time.Second},
+		{"2006-01-02 15:04:05", time.Second},
+		{"2006-01-02 15:04", time.Minute},
+	} {
+		if t, err := time.ParseInLocation(f.layout, *to, displayZone); err == nil {
+			target, parsed = t.Add(f.precision-time.Nanosecond), true
+			break
+		}
+	}
+	if !parsed {
+		return fmt.Errorf("invalid time %q", *to)
+	}
+
+	store, err := library.NewDirStore(*from)
+	if err != nil {
+		return err
+	}
+	restoredTo, err := library.RestoreToTime(store, target, *out)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✓ Restored the database as of %s to %s\n", displayTime(restoredTo).Format("2006-01-02 15:04:05"), *out)
+	fmt.Println("Stop the server and swap it in for the live database to roll back.")
+	return nil
+}
+
+// runSelfUpdate implements `self-update [--channel stable|beta] [--check]
+// [--force]`, replacing this program with the newest release on its
+// channel. The release endpoint and key are the ones the build was stamped
+// with; LIBRARY_UPDATE_URL overrides the endpoint, and LIBRARY_UPDATE_KEY
+// the key only in development builds, so no setting can make a release
+// trust signatures other than its own.
+func runSelfUpdate(args []string) error {
+	channel := os.Getenv("LIBRARY_UPDATE_CHANNEL")
+	if channel == "" {
+		channel = selfupdate.Stable
+	}
+	endpoint := os.Getenv("LIBRARY_UPDATE_URL")
+	if endpoint == "" {
+		endpoint = selfupdate.DefaultEndpoint
+	}
+	key := selfupdate.ReleaseKey
+	if v := os.Getenv("LIBRARY_UPDATE_KEY"); v != "" && !selfupdate.ValidVersion(library.Version) {
+		key = v
+	}
+
+	fs := flag.NewFlagSet("self-update", flag.ContinueOnError)
+	fs.StringVar(&channel, "channel", channel, "release channel, stable or beta (default $LIBRARY_UPDATE_CHANNEL)")
+	check := fs.Bool("check", false, "only report whether a newer release is out")
+	force := fs.Bool("force", false, "install the channel's release even if it isn't newer")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	updater, err := selfupdate.NewUpdater(endpoint, channel, key)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	release, err := updater.Latest(ctx)
+	if err != nil {
+		return err
+	}
+
+	current := library.Version
+	newer := selfupdate.Newer(release.Version, current)
+	switch {
+	case *check && newer:
+		fmt.Printf("Version %s is out on the %s channel (this is %s). Run `self-update` to install it.\n", release.Version, channel, current)
+		return nil
+	case !newer && !*force:
+		if !selfupdate.ValidVersion(current) {
+			fmt.Printf("This is a %s build; use --force to replace it with %s.\n", current, release.Version)
+		} else {
+			fmt.Printf("✓ Up to date: %s is the latest on the %s channel.\n", current, channel)
+		}
+		return nil
+	case *check:
+		fmt.Printf("%s is the latest on the %s channel (this is %s).\n", release.Version, channel, current)
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	fmt.Printf("Downloading %s from the %s channel...\n", release.Version, channel)
+	if err := updater.Install(ctx, release, exe); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Updated %s to %s.\n", current, release.Version)
+	return nil
+}
+
+// remoteUsage lists the commands of `remote`.
+const remoteUsage = `Usage: remote <command>, with LIBRARY_ENDPOINT set to the library's API
+  remote books                    list the catalog
+  remote book <id>                show one book
+  remote read <id> [<page>]       print a page of a book you have on loan
+  remote capabilities             list the library's optional subsystems
+  remote version                  show the build serving the library
+  remote login                    print an API token to put in LIBRARY_TOKEN
+  remote sync books|members|circulation <file.json>
+                                  apply a JSON array of batch items (staff)`
+
+// runRemote implements `remote`, which works on a library over its HTTP API
+// (LIBRARY_ENDPOINT) instead of a local database. Commands that need an
+// account use LIBRARY_TOKEN, or ask for a member ID and password.
+func runRemote(args []string) error {
+	endpoint := os.Getenv("LIBRARY_ENDPOINT")
+	if len(args) == 0 || endpoint == "" {
+		return fmt.Errorf("%s", remoteUsage)
+	}
+	var opts []libraryclient.Option
+	if token := os.Getenv("LIBRARY_TOKEN"); token != "" {
+		opts = append(opts, libraryclient.WithToken(token))
+	}
+	c, err := libraryclient.New(endpoint, opts...)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	// signIn returns a client for a member: c itself with a token, or else
+	// one for the member ID and password asked for
+	signIn := func() (*libraryclient.Client, error) {
+		if c.Token() != "" {
+			return c, nil
+		}
+		sc := bufio.NewScanner(os.Stdin)
+		prompt("Member ID: ")
+		if !sc.Scan() {
+			return nil, fmt.Errorf("no member ID entered")
+		}
+		memberID, err := strconv.ParseInt(strings.TrimSpace(sc.Text()), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid member ID: %s", strings.TrimSpace(sc.Text()))
+		}
+		password, err := readPassword("Password: ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read password: %w", err)
+		}
+		member, err := libraryclient.New(endpoint, libraryclient.WithBasicAuth(memberID, password))
+		if err != nil {
+			return nil, err
+		}
+		_, err = member.Login(ctx)
+		return member, err
+	}
+
+	switch cmd, rest := args[0], args[1:]; {
+	case cmd == "books" && len(rest) == 0:
+		books, err := c.ListBooks(ctx)
+		if err != nil {
+			return err
+		}
+		if machineOutput() {
+			rows := make([][]any, len(books))
+			for i, b := range books {
+				rows[i] = []any{b.ID, b.Title, b.Author, b.ItemType, b.Available, b.NonCirculating}
+			}
+			writeRecords([]string{"id", "title", "author", "item_type", "available", "reference_only"}, rows)
+			return nil
+		}
+		for _, b := range books {
+			fmt.Println(remoteBookLine(b))
+		}
+	case cmd == "book" && len(rest) == 1:
+		id, err := strconv.ParseInt(rest[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid book ID: %s", rest[0])
+		}
+		b, err := c.GetBook(ctx, id)
+		if err != nil {
+			return err
+		}
+		fmt.Println(remoteBookLine(b))
+	case cmd == "read" && (len(rest) == 1 || len(rest) == 2):
+		id, err := strconv.ParseInt(rest[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid book ID: %s", rest[0])
+		}
+		n := 1
+		if len(rest) == 2 {
+			if n, err = strconv.Atoi(rest[1]); err != nil || n < 1 {
+				return fmt.Errorf("invalid page number: %s", rest[1])
+			}
+		}
+		member, err := signIn()
+		if err != nil {
+			return err
+		}
+		page, err := member.GetPage(ctx, id, n)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("📖 %s by %s | Page %d of %d\n", page.Title, page.Author, page.Number, page.TotalPages)
+		if page.Chapter != "" {
+			fmt.Println(page.Chapter)
+		}
+		fmt.Println()
+		fmt.Println(page.Text)
+	case cmd == "capabilities" && len(rest) == 0:
+		caps, err := c.Capabilities(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-18s %-8s %s\n", "Capability", "Status", "Detail")
+		fmt.Println(strings.Repeat("-", 60))
+		for _, cap := range caps {
+			status := "off"
+			if cap.Enabled {
+				status = "on"
+			}
+			fmt.Printf("%-18s %-8s %s\n", cap.Name, status, cap.Detail)
+		}
+	case cmd == "version" && len(rest) == 0:
+		v, err := c.Version(ctx)
+		if err != nil {
+			return err
+		}
+		printVersion(library.VersionInfo(*v))
+	case cmd == "login" && len(rest) == 0:
+		member, err := signIn()
+		if err != nil {
+			return err
+		}
+		fmt.Println(member.Token())
+	case cmd == "sync" && len(rest) == 2:
+		return remoteSync(ctx, signIn, rest[0], rest[1])
+	default:
+		return fmt.Errorf("%s", remoteUsage)
+	}
+	return nil
+}
+
+// remoteBookLine is one book as `remote books` lists it.
+func remoteBookLine(b *libraryclient.Book) string {
+	status := "available"
+	switch {
+	case b.NonCirculating:
+		status = "in-library use only"
+	case !b.Available:
+		status = "on loan"
+	}
+	return fmt.Sprintf("%-6d %s by %s (%s)", b.ID, b.Title, b.Author, status)
+}
+
+// remoteSync runs `remote sync <kind> <file.json>`, sending the JSON array
+// of items in file to the batch endpoint for kind and printing each item's
+// outcome.
+func remoteSync(ctx context.Context, signIn func() (*libraryclient.Client, error), kind, file string) error {
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return err
+	}
+	var results []libraryclient.BatchResult
+	send := func(items any, apply func(c *libraryclient.Client) ([]libraryclient.BatchResult, error)) error {
+		if err := json.Unmarshal(data, items); err != nil {
+			return fmt.Errorf("%s: expected a JSON array of %s: %w", file, kind, err)
+		}
+		c, err := signIn()
+		if err != nil {
+			return err
+		}
+		results, err = apply(c)
+		return err
+	}
+	switch kind {
+	case "books":
+		var items []libraryclient.NewBook
+		err = send(&items, func(c *libraryclient.Client) ([]libraryclient.BatchResult, error) {
+			return c.AddBooks(ctx, items)
+		})
+	case "members":
+		var items []libraryclient.MemberUpdate
+		err = send(&items, func(c *libraryclient.Client) ([]libraryclient.BatchResult, error) {
+			return c.UpsertMembers(ctx, items)
+		})
+	case "circulation":
+		var items []libraryclient.CirculationEvent
+		err = send(&items, func(c *libraryclient.Client) ([]libraryclient.BatchResult, error) {
+			return c.ApplyCirculation(ctx, items)
+		})
+	default:
+		return fmt.Errorf("%s", remoteUsage)
+	}
+
+	if machineOutput() {
+		rows := make([][]any, len(results))
+		for i, r := range results {
+			rows[i] = []any{r.Index, r.Key, r.Status, r.ID, r.Replayed, r.Error}
+		}
+		writeRecords([]string{"index", "key", "status", "id", "replayed", "error"}, rows)
+		return err
+	}
+	counts := make(map[string]int)
+	replayed := 0
+	for _, r := range results {
+		counts[r.Status]++
+		if r.Replayed {
+			replayed++
+		}
+		if r.Status == libraryclient.BatchFailed {
+			label := fmt.Sprintf("item %d", r.Index+1)
+			if r.Key != "" {
+				label += " (" + r.Key + ")"
+			}
+			fmt.Printf("  %s: %s\n", label, r.Error)
+		}
+	}
+	var summary []string
+	for _, status := range []string{libraryclient.BatchCreated, libraryclient.BatchUpdated, libraryclient.BatchApplied,
+		libraryclient.BatchDuplicate, libraryclient.BatchFailed} {
+		if counts[status] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+	if replayed > 0 {
+		summary = append(summary, fmt.Sprintf("%d already applied earlier", replayed))
+	}
+	if len(summary) > 0 {
+		fmt.Printf("✓ Synced %s: %s\n", kind, strings.Join(summary, ", "))
+	}
+	return err
+}
+
+// configFile is where `init` saves the library's settings; LIBRARY_CONFIG
+// names another file.
+const configFile = "library.conf"
+
+// userConfigFile, under the home directory, holds settings for every
+// library the user opens; the library's own config file comes first.
+var userConfigFile = filepath.Join(".library", "config.toml")
+
+// sampleCatalog lists the sample books `init` can load.
+const sampleCatalog = "texts/catalog.csv"
+
+// configKeys are the settings a config file may hold, in the order they are
+// written. Each is the LIBRARY_* environment variable of the same name,
+// which overrides the file.
+var configKeys = []string{
+	"LIBRARY_DB",
+	"LIBRARY_OUTPUT",
+	"LIBRARY_PAGE_SIZE",
+	"LIBRARY_TIMEZONE",
+	"LIBRARY_LOAN_DAYS",
+	"LIBRARY_FINE_RATE",
+	"LIBRARY_BCRYPT_COST",
+	"LIBRARY_HOLD_POLICY",
+	"LIBRARY_HOLD_PICKUP_DAYS",
+	"LIBRARY_DIGITAL_LOAN_DAYS",
+	"LIBRARY_LOCKER_PICKUP",
+	"LIBRARY_REMINDER_LEAD",
+	"LIBRARY_SMTP_ADDR",
+	"LIBRARY_SMTP_FROM",
+	"LIBRARY_SMTP_USER",
+	"LIBRARY_SMTP_PASSWORD",
+	"LIBRARY_EVENT_LOG",
+	"LIBRARY_DB_MAX_OPEN_CONNS",
+	"LIBRARY_DB_MAX_IDLE_CONNS",
+	"LIBRARY_DB_BUSY_RETRIES",
+	"LIBRARY_CONTENT_STORE",
+	"LIBRARY_CONTENT_INLINE_MAX",
+	"LIBRARY_WAL_ARCHIVE",
+	"LIBRARY_TENANTS_DIR",
+	"LIBRARY_UPDATE_URL",
+	"LIBRARY_UPDATE_CHANNEL",
+	"LIBRARY_UPDATE_KEY",
+	"LIBRARY_TELEMETRY",
+	"LIBRARY_TELEMETRY_URL",
+}
+
+func configPath() string {
+	if v := os.Getenv("LIBRARY_CONFIG"); v != "" {
+		return v
+	}
+	return configFile
+}
+
+// readConfig parses a config file of KEY=value lines, or TOML's key = value
+// with the key in lower case and without LIBRARY_ (db = "library.db").
+// Values may be quoted. Blank lines and lines starting with # are skipped;
+// unknown keys are errors, so a misspelt setting isn't silently ignored.
+func readConfig(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	settings := make(map[string]string)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		key := name
+		if key == strings.ToLower(key) {
+			key = "LIBRARY_" + strings.ToUpper(key)
+		}
+		if !ok || !slices.Contains(configKeys, key) {
+			return nil, fmt.Errorf("%s:%d: unknown setting %q", path, i+1, name)
+		}
+		if value, err = configValue(value); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s: %w", path, i+1, key, err)
+		}
+		settings[key] = value
+	}
+	return settings, nil
+}
+
+// configValue reads a setting's value: a quoted string, or a bare word or
+// number with an optional # comment after it.
+func configValue(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, `"`):
+		end := strings.LastIndex(s, `"`)
+		if end == 0 || !strings.HasPrefix(strings.TrimSpace(s[end+1:])+"#", "#") {
+			return "", fmt.Errorf("unterminated string")
+		}
+		return strconv.Unquote(s[:end+1])
+	case strings.HasPrefix(s, "'"):
+		end := strings.LastIndex(s, "'")
+		if end == 0 || !strings.HasPrefix(strings.TrimSpace(s[end+1:])+"#", "#") {
+			return "", fmt.Errorf("unterminated string")
+		}
+		return s[1:end], nil
+	}
+	if hash := strings.Index(s, " #"); hash >= 0 {
+		s = s[:hash]
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// configPaths are the config files loadConfig reads, first one first:
+// LIBRARY_CONFIG alone if it is set, otherwise the library's config file
+// and then the user's.
+func configPaths() []string {
+	if v := os.Getenv("LIBRARY_CONFIG"); v != "" {
+		return []string{v}
+	}
+	paths := []string{configFile}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, userConfigFile))
+	}
+	return paths
+}
+
+// loadConfig applies the config files' settings that aren't already set in
+// the environment or an earlier file. A missing default file is not an
+// error.
+func loadConfig() error {
+	for _, path := range configPaths() {
+		settings, err := readConfig(path)
+		if errors.Is(err, os.ErrNotExist) && os.Getenv("LIBRARY_CONFIG") == "" {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("read config: %w", err)
+		}
+		for key, value := range settings {
+			if _, set := os.LookupEnv(key); !set {
+				os.Setenv(key, value)
+			}
+		}
+	}
+	return nil
+}
+
+// Config is the settings the program reads from the LIBRARY_* environment
+// (and so from the config files) beyond those handled where they're used.
+type Config struct {
+	DBPath     string // LIBRARY_DB
+	Output     string // LIBRARY_OUTPUT: how listings print; see outputFormats
+	PageSize   int    // LIBRARY_PAGE_SIZE: rows per page of list books and list members
+	LoanDays   int    // LIBRARY_LOAN_DAYS: loan period for members without a tier; 0 for the library's default
+	FineCents  int64  // LIBRARY_FINE_RATE: daily overdue fine for the same; -1 for the library's default
+	BcryptCost int    // LIBRARY_BCRYPT_COST: cost of new password hashes; 0 for the library's default
+	Telemetry  bool   // LIBRARY_TELEMETRY: count commands and errors for the maintainers; off unless set
+}
+
+// defaultConfig is the settings with nothing set.
+var defaultConfig = Config{DBPath: "library.db", Output: "table", PageSize: 20, FineCents: -1}
+
+// configFromEnv reads the Config settings from the environment, over
+// defaultConfig.
+func configFromEnv() (Config, error) {
+	cfg := defaultConfig
+	if v := os.Getenv("LIBRARY_DB"); v != "" {
+		cfg.DBPath = v
+	}
+	if v := os.Getenv("LIBRARY_OUTPUT"); v != "" {
+		if !slices.Contains(outputFormats, v) {
+			return cfg, fmt.Errorf("invalid LIBRARY_OUTPUT %q (use %s)", v, strings.Join(outputFormats, ", "))
+		}
+		cfg.Output = v
+	}
+	for _, setting := range []struct {
+		env string
+		n   *int
+	}{
+		{"LIBRARY_PAGE_SIZE", &cfg.PageSize},
+		{"LIBRARY_LOAN_DAYS", &cfg.LoanDays},
+		{"LIBRARY_BCRYPT_COST", &cfg.BcryptCost},
+	} {
+		if v := os.Getenv(setting.env); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return cfg, fmt.Errorf("invalid %s %q", setting.env, v)
+			}
+			*setting.n = n
+		}
+	}
+	if v := os.Getenv("LIBRARY_FINE_RATE"); v != "" {
+		cents, err := library.ParseCents(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid LIBRARY_FINE_RATE %q", v)
+		}
+		cfg.FineCents = cents
+	}
+	if v := os.Getenv("LIBRARY_TELEMETRY"); v != "" {
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid LIBRARY_TELEMETRY %q", v)
+		}
+		cfg.Telemetry = on
+	}
+	return cfg, nil
+}
+
+// writeConfig saves settings to path, readable only by its owner since it
+// may hold the mail server password.
+func writeConfig(path string, settings map[string]string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Library settings, written by `init` on %s.\n", time.Now().In(displayZone).Format("2006-01-02 15:04"))
+	b.WriteString("# Environment variables of the same name override these.\n")
+	for _, key := range configKeys {
+		if v := settings[key]; v != "" {
+			fmt.Fprintf(&b, "%s=%s\n", key, v)
+		}
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o600)
+}
+
+// initPrompter asks the questions of the setup wizard.
+type initPrompter struct {
+	sc *bufio.Scanner
+}
+
+// ask prints label with def in brackets and returns the answer, or def
+// when it is left blank. check, if set, rejects an answer and asks again.
+func (p initPrompter) ask(label, def string, check func(string) error) (string, error) {
+	for {
+		if def != "" {
+			prompt("%s [%s]: ", label, def)
+		} else {
+			prompt("%s: ", label)
+		}
+		if !p.sc.Scan() {
+			return "", fmt.Errorf("setup cancelled")
+		}
+		answer := strings.TrimSpace(p.sc.Text())
+		if answer == "" {
+			answer = def
+		}
+		if check == nil {
+			return answer, nil
+		}
+		if err := check(answer); err != nil {
+			fmt.Printf("  %v\n", err)
+			continue
+		}
+		return answer, nil
+	}
+}
+
+// confirm asks a yes/no question.
+func (p initPrompter) confirm(label string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	answer, err := p.ask(fmt.Sprintf("%s (%s)", label, hint), "", func(s string) error {
+		switch strings.ToLower(s) {
+		case "", "y", "yes", "n", "no":
+			return nil
+		}
+		return fmt.Errorf("please answer y or n")
+	})
+	if err != nil {
+		return false, err
+	}
+	if answer == "" {
+		return def, nil
+	}
+	return strings.HasPrefix(strings.ToLower(answer), "y"), nil
+}
+
+// positiveInt accepts a whole number above zero, or blank.
+func positiveInt(s string) error {
+	if s == "" {
+		return nil
+	}
+	if n, err := strconv.Atoi(s); err != nil || n <= 0 {
+		return fmt.Errorf("enter a whole number above zero")
+	}
+	return nil
+}
+
+// runInit walks a new librarian through setting up a library: the
+// database, the first staff account, loan policies, reminders and
+// optionally the sample books. It ends by saving the settings to the config
+// file, once they have been checked the same way start-up checks them.
+func runInit() error {
+	p := initPrompter{sc: bufio.NewScanner(os.Stdin)}
+	p.sc.Split(scanInput)
+	path := configPath()
+	settings, err := readConfig(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		settings = make(map[string]string)
+	case err != nil:
+		return err
+	default:
+		fmt.Printf("%s already exists; its settings are offered as defaults.\n", path)
+	}
+
+	fmt.Println("Library setup. Press Enter to accept the value in brackets.")
+	fmt.Println("\n1. Database")
+	dbDefault := settings["LIBRARY_DB"]
+	if dbDefault == "" {
+		dbDefault = defaultConfig.DBPath
+	}
+	if settings["LIBRARY_DB"], err = p.ask("Database file", dbDefault, nil); err != nil {
+		return err
+	}
+	settings["LIBRARY_TIMEZONE"], err = p.ask("Time zone for dates, e.g. Europe/Paris (blank for this machine's)", settings["LIBRARY_TIMEZONE"],
+		func(s string) error {
+			if _, err := time.LoadLocation(s); err != nil {
+				return fmt.Errorf("unknown time zone %q", s)
+			}
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+	mgr, err := library.NewLibraryManager(settings["LIBRARY_DB"])
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer mgr.Close()
+	fmt.Printf("✓ Database ready at %s\n", settings["LIBRARY_DB"])
+
+	fmt.Println("\n2. Administrator")
+	if err := initAdmin(p, mgr); err != nil {
+		return err
+	}
+
+	fmt.Println("\n3. Loan policies")
+	if err := initLoanPolicies(p, mgr, settings); err != nil {
+		return err
+	}
+
+	fmt.Println("\n4. Notifications")
+	if err := initNotifications(p, settings); err != nil {
+		return err
+	}
+
+	fmt.Println("\n5. Sample data")
+	if err := initSampleData(p, mgr); err != nil {
+		return err
+	}
+
+	// Check the settings exactly as start-up will read them
+	for _, key := range configKeys {
+		os.Unsetenv(key)
+		if v := settings[key]; v != "" {
+			os.Setenv(key, v)
+		}
+	}
+	if err := loadDisplayZone(); err != nil {
+		return fmt.Errorf("settings not saved: %w", err)
+	}
+	if err := configureManager(mgr); err != nil {
+		return fmt.Errorf("settings not saved: %w", err)
+	}
+	if _, err := reminderLead(); err != nil {
+		return fmt.Errorf("settings not saved: %w", err)
+	}
+	if err := writeConfig(path, settings); err != nil {
+		return err
+	}
+	fmt.Printf("\n✓ Settings saved to %s. Run the program from this directory to open the library.\n", path)
+	return nil
+}
+
+// initAdmin creates the first staff account, unless the library has one.
+func initAdmin(p initPrompter, mgr *library.LibraryManager) error {
+	admins, err := mgr.CountAdmins()
+	if err != nil {
+		return err
+	}
+	if admins > 0 {
+		fmt.Printf("The library already has %d staff account(s); skipping.\n", admins)
+		return nil
+	}
+	name, err := p.ask("Administrator's name", "", func(s string) error {
+		if s == "" {
+			return fmt.Errorf("a name is required")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var password string
+	for {
+		password, err = readPassword(fmt.Sprintf("Password for %s: ", name))
+		if err != nil {
+			return fmt.Errorf("read password: %w", err)
+		}
+		again, err := readPassword("Repeat the password: ")
+		if err != nil {
+			return fmt.Errorf("read password: %w", err)
+		}
+		if strings.TrimSpace(password) == "" {
+			fmt.Println("  The password cannot be empty.")
+		} else if password != again {
+			fmt.Println("  The passwords don't match.")
+		} else {
+			break
+		}
+	}
+	id, err := mgr.AddMemberWithTier(name, password, library.TierStaff)
+	if err != nil {
+		return err
+	}
+	if err := mgr.SetMemberAdmin(id, true); err != nil {
+		return err
+	}
+	fmt.Printf("✓ %s is the administrator; sign in as staff member ID %d\n", name, id)
+	return nil
+}
+
+// initLoanPolicies lets the librarian adjust each membership tier and the
+// hold and digital loan periods.
+func initLoanPolicies(p initPrompter, mgr *library.LibraryManager, settings map[string]string) error {
+	review, err := p.confirm("Review the loan limit, loan period and fine for each membership tier?", false)
+	if err != nil {
+		return err
+	}
+	if review {
+		tiers, err := mgr.GetTierPolicies()
+		if err != nil {
+			return err
+		}
+		for _, t := range tiers {
+			fmt.Printf("  %s members\n", t.Name)
+			limit, err := p.ask("    Books on loan at once", strconv.Itoa(t.LoanLimit), positiveInt)
+			if err != nil {
+				return err
+			}
+			days, err := p.ask("    Loan period in days", strconv.Itoa(t.LoanDays), positiveInt)
+			if err != nil {
+				return err
+			}
+			fine, err := p.ask("    Overdue fine per day in cents (0 for none)", strconv.FormatInt(t.FineCentsPerDay, 10), func(s string) error {
+				if n, err := strconv.ParseInt(s, 10, 64); err != nil || n < 0 {
+					return fmt.Errorf("enter a whole number of cents")
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			policy := library.TierPolicy{Name: t.Name}
+			policy.LoanLimit, _ = strconv.Atoi(limit)
+			policy.LoanDays, _ = strconv.Atoi(days)
+			policy.FineCentsPerDay, _ = strconv.ParseInt(fine, 10, 64)
+			if err := mgr.SetTierPolicy(policy); err != nil {
+				return err
+			}
+		}
+		fmt.Println("✓ Loan policies saved")
+	}
+
+	if settings["LIBRARY_HOLD_POLICY"], err = p.ask("When a reserved book comes back: shelf (hold it for pickup), immediate (check it out to the next reader) or notify (tell them it is in)",
+		settings["LIBRARY_HOLD_POLICY"], func(s string) error {
+			if s != "" && !slices.Contains(library.HoldPolicies, s) {
+				return fmt.Errorf("enter shelf, immediate or notify")
+			}
+			return nil
+		}); err != nil {
+		return err
+	}
+	if settings["LIBRARY_HOLD_POLICY"] == library.HoldPolicyShelf || settings["LIBRARY_HOLD_POLICY"] == "" {
+		if settings["LIBRARY_HOLD_PICKUP_DAYS"], err = p.ask("Days a returned book is held for the next reader (blank for the default)",
+			settings["LIBRARY_HOLD_PICKUP_DAYS"], positiveInt); err != nil {
+			return err
+		}
+	}
+	settings["LIBRARY_DIGITAL_LOAN_DAYS"], err = p.ask("Length of a digital loan in days (blank for the default)",
+		settings["LIBRARY_DIGITAL_LOAN_DAYS"], positiveInt)
+	return err
+}
+
+// initNotifications asks how early reminders go out and whether they are
+// emailed.
+func initNotifications(p initPrompter, settings map[string]string) error {
+	lead := settings["LIBRARY_REMINDER_LEAD"]
+	if lead == "" {
+		lead = "48h"
+	}
+	lead, err := p.ask("Send due date reminders how long ahead (e.g. 48h)", lead, func(s string) error {
+		if d, err := time.ParseDuration(s); err != nil || d <= 0 {
+			return fmt.Errorf("enter a duration such as 48h or 72h")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	settings["LIBRARY_REMINDER_LEAD"] = lead
+
+	email, err := p.confirm("Email reminders to members through an SMTP server?", settings["LIBRARY_SMTP_ADDR"] != "")
+	if err != nil {
+		return err
+	}
+	if !email {
+		for _, key := range []string{"LIBRARY_SMTP_ADDR", "LIBRARY_SMTP_FROM", "LIBRARY_SMTP_USER", "LIBRARY_SMTP_PASSWORD"} {
+			delete(settings, key)
+		}
+		return nil
+	}
+	if settings["LIBRARY_SMTP_ADDR"], err = p.ask("  Mail server (host:port)", settings["LIBRARY_SMTP_ADDR"], func(s string) error {
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			return fmt.Errorf("enter the server as host:port, e.g. mail.example.org:587")
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if settings["LIBRARY_SMTP_FROM"], err = p.ask("  Send from address", settings["LIBRARY_SMTP_FROM"], func(s string) error {
+		if _, err := mail.ParseAddress(s); err != nil {
+			return fmt.Errorf("enter an address such as desk@library.example")
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if settings["LIBRARY_SMTP_USER"], err = p.ask("  User name (blank if the server needs none)", settings["LIBRARY_SMTP_USER"], nil); err != nil {
+		return err
+	}
+	if settings["LIBRARY_SMTP_USER"] != "" {
+		password, err := readPassword("  Password (Enter to keep the saved one): ")
+		if err != nil {
+			return fmt.Errorf("read password: %w", err)
+		}
+		if password != "" {
+			settings["LIBRARY_SMTP_PASSWORD"] = password
+		}
+	} else {
+		delete(settings, "LIBRARY_SMTP_PASSWORD")
+	}
+	return nil
+}
+
+// initSampleData offers to load the sample books into an empty catalog.
+func initSampleData(p initPrompter, mgr *library.LibraryManager) error {
+	n, err := mgr.CountBooks()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		fmt.Printf("The catalog already has %d book(s); skipping.\n", n)
+		return nil
+	}
+	if _, err := os.Stat(sampleCatalog); err != nil {
+		fmt.Printf("No sample books found (%s); skipping.\n", sampleCatalog)
+		return nil
+	}
+	load, err := p.confirm("Load the sample books?", false)
+	if err != nil || !load {
+		return err
+	}
+	f, err := os.Open(sampleCatalog)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	report, err := mgr.ImportBooksCSV(f)
+	if err != nil {
+		return fmt.Errorf("load sample books: %w", err)
+	}
+	fmt.Printf("✓ Sample books: %s\n", report)
+	for _, issue := range report.Rejected {
+		fmt.Printf("  line %d: %s\n", issue.Line, issue.Reason)
+	}
+	return nil
+}
+
+// runPlugin runs the external command pluginPrefix+name with args, wired to
+// our terminal. It learns which library to work on from the environment:
+// LIBRARY_DB is the absolute path of the (migrated) database, and
+// LIBRARY_ENDPOINT, when set, is passed through for commands that talk to a
+// remote server instead. If LIBRARY_TOKEN is set it must be a valid API
+// token; the command then gets LIBRARY_MEMBER_ID, and LIBRARY_ADMIN=1 for
+// staff, so it can act on the member's behalf without asking for a password.
+// It returns the exit status to finish with.
+func runPlugin(mgr *library.LibraryManager, dbPath, name string, args []string) (int, error) {
+	bin, err := exec.LookPath(pluginPrefix + name)
+	if err != nil {
+		return 1, fmt.Errorf("unknown command %q (no %s%s on PATH)", name, pluginPrefix, name)
+	}
+	absDB, err := filepath.Abs(dbPath)
+	if err != nil {
+		return 1, err
+	}
+
+	// Identity comes only from a verified token, never from the caller's
+	// environment
+	var env []string
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "LIBRARY_MEMBER_ID=") || strings.HasPrefix(kv, "LIBRARY_ADMIN=") {
+			continue
+		}
+		env = append(env, kv)
+	}
+	env = append(env, "LIBRARY_DB="+absDB)
+	if token := os.Getenv("LIBRARY_TOKEN"); token != "" {
+		memberID, err := mgr.AuthenticateToken(token)
+		if err != nil {
+			return 1, fmt.Errorf("LIBRARY_TOKEN: %w", err)
+		}
+		member, err := mgr.GetMember(memberID)
+		if err != nil {
+			return 1, err
+		}
+		env = append(env, fmt.Sprintf("LIBRARY_MEMBER_ID=%d", memberID))
+		if member.IsAdmin {
+			env = append(env, "LIBRARY_ADMIN=1")
+		}
+	}
+
+	cmd := exec.Command(bin, args...)
+	cmd.Env = env
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		return 1, err
+	}
+	return 0, nil
+}
+
+// reminderLead is how far ahead of the due date reminders go out, from
+// LIBRARY_REMINDER_LEAD.
+func reminderLead() (time.Duration, error) {
+	if v := os.Getenv("LIBRARY_REMINDER_LEAD"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return 0, fmt.Errorf("invalid LIBRARY_REMINDER_LEAD %q", v)
+		}
+		return d, nil
+	}
+	return defaultReminderLead, nil
+}
+
+// smtpNotifier emails reminders through the server in LIBRARY_SMTP_ADDR
+// (host:port) from LIBRARY_SMTP_FROM, signing in with LIBRARY_SMTP_USER and
+// LIBRARY_SMTP_PASSWORD when the server needs it. It returns nil when email
+// is not configured.
+func smtpNotifier(mgr *library.LibraryManager) (*library.SMTPNotifier, error) {
+	addr := os.Getenv("LIBRARY_SMTP_ADDR")
+	if addr == "" {
+		return nil, nil
+	}
+	return mgr.NewSMTPNotifier(library.SMTPConfig{
+		Addr:     addr,
+		From:     os.Getenv("LIBRARY_SMTP_FROM"),
+		Username: os.Getenv("LIBRARY_SMTP_USER"),
+		Password: os.Getenv("LIBRARY_SMTP_PASSWORD"),
+	})
+}
+
+// startJobs registers the scheduled jobs and runs them in the background.
+// Notices go to members' in-app inboxes, and by email when it is
+// configured; job failures are appended to jobLogFile so they don't
+// interrupt the prompt.
+func startJobs(mgr *library.LibraryManager) (*library.JobRunner, chan struct{}, error) {
+	lead, err := reminderLead()
+	if err != nil {
+		return nil, nil, err
+	}
+	mailer, err := smtpNotifier(mgr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logFile, err := os.OpenFile(jobLogFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+	notifier := mgr.NewInboxNotifier()
+
+	runner := library.NewJobRunner(func(res library.JobResult) {
+		if res.Err != nil {
+			fmt.Fprintf(logFile, "%s job %s failed: %v\n", res.Started.Format(time.RFC3339), res.Name, res.Err)
+		}
+	})
+	if err := runner.Add(mgr.DueReminderJob(notifier, lead, reminderInterval)); err != nil {
+		return nil, nil, err
+	}
+	if err := runner.Add(mgr.OverdueNoticeJob(notifier, overdueInterval)); err != nil {
+		return nil, nil, err
+	}
+	if err := runner.Add(mgr.ExpiryNoticeJob(notifier, expiryNoticeWindow, expiryNoticeInterval)); err != nil {
+		return nil, nil, err
+	}
+	if err := runner.Add(mgr.DigitalReturnJob(notifier, digitalReturnInterval)); err != nil {
+		return nil, nil, err
+	}
+	if err := runner.Add(mgr.HoldExpiryJob(holdExpiryInterval)); err != nil {
+		return nil, nil, err
+	}
+	var queueEmail library.Notifier
+	if mailer != nil {
+		if err := runner.Add(mgr.EmailReminderJob(mailer, lead, reminderInterval)); err != nil {
+			return nil, nil, err
+		}
+		queueEmail = mailer
+	}
+	if err := runner.Add(mgr.NotifyQueueMoves(queueEmail).QueueNoticeJob(queueNoticeInterval)); err != nil {
+		return nil, nil, err
+	}
+	if err := runner.Add(mgr.MaintenanceJob(maintenanceIdleStart, maintenanceIdleEnd, maintenanceInterval)); err != nil {
+		return nil, nil, err
+	}
+	if err := runner.Add(mgr.BackupJob(backupInterval)); err != nil {
+		return nil, nil, err
+	}
+	if err := runner.Add(mgr.WALArchiveJob(walArchiveInterval)); err != nil {
+		return nil, nil, err
+	}
+
+	stop := make(chan struct{})
+	runner.Start(time.Minute, stop)
+	return runner, stop, nil
+}
+
+// handleExportCirculation writes open loans, holds and outstanding charges to
+// a JSON file for a library that is moving to another installation.
+func handleExportCirculation(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	prompt("Export to file (default circulation.json): ")
+	if !sc.Scan() {
+		return
+	}
+	path := strings.TrimSpace(sc.Text())
+	if path == "" {
+		path = "circulation.json"
+	}
+
+	state, err := mgr.ExportCirculation()
+	if err != nil {
+		printError(err)
+		return
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		printError(err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		fmt.Printf("Error writing %s: %v\n", path, err)
+		return
+	}
+	fmt.Printf("✓ Exported %d loans, %d holds and %d charges to %s\n",
+		len(state.Checkouts), len(state.Holds), len(state.Fines), path)
+}
+
+// handleExport writes the catalog or the member list to a file for
+// spreadsheets and other systems; a .json file gets JSON, anything else CSV.
+// export books --editable writes the CSV that apply books reads back.
+func handleExport(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	what, path := splitArgs(args)
+	editable := false
+	if flag, rest := splitArgs(path); what == "books" && flag == "--editable" {
+		editable, path = true, rest
+	}
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	if path == "" {
+		prompt("Export to file (default %s.csv): ", what)
+		if !sc.Scan() {
+			return
+		}
+		path = strings.TrimSpace(sc.Text())
+		if path == "" {
+			path = what + ".csv"
+		}
+	}
+	format := library.ExportCSV
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if editable {
+			fmt.Println("Error: an editable export is a CSV file")
+			return
+		}
+		format = library.ExportJSON
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch {
+	case editable:
+		err = mgr.ExportEditableBooks(&buf)
+	case what == "members":
+		err = mgr.ExportMembers(&buf, format)
+	default:
+		err = mgr.ExportBooks(&buf, format)
+	}
+	if err != nil {
+		printError(err)
+		return
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		fmt.Printf("Error writing %s: %v\n", path, err)
+		return
+	}
+	fmt.Printf("✓ Exported %s to %s\n", what, path)
+	if editable {
+		fmt.Printf("  Correct titles, authors, tags (separated by ;) and publication details, then run apply books %s\n", path)
+	}
+}
+
+// handleApplyBooks compares an edited export books --editable file with the
+// catalog, shows what would change and applies it once confirmed.
+func handleApplyBooks(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	path := strings.Trim(strings.TrimSpace(args), `"`)
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	if path == "" {
+		var ok bool
+		if path, ok = promptPath(sc, "Edited CSV file: ", "", true); !ok {
+			return
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		printError(err)
+		return
+	}
+	diff, err := mgr.DiffEditableBooks(f)
+	f.Close()
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	for _, e := range diff.Edits {
+		fmt.Printf("Book %d '%s' (line %d):\n", e.BookID, e.Title, e.Line)
+		for _, c := range e.Changes {
+			if c.Field == "tags" {
+				fmt.Printf("    tags: %s\n", c.New)
+				continue
+			}
+			fmt.Printf("    %s: %q → %q\n", c.Field, c.Old, c.New)
+		}
+	}
+	for _, issue := range diff.Rejected {
+		fmt.Printf("  line %d: rejected, %s\n", issue.Line, issue.Reason)
+	}
+	fmt.Printf("%d book(s) to change, %d unchanged, %d rejected\n", len(diff.Edits), diff.Unchanged, len(diff.Rejected))
+	if len(diff.Edits) == 0 {
+		return
+	}
+
+	prompt("Apply these changes? (y/n): ")
+	if !sc.Scan() || !strings.EqualFold(strings.TrimSpace(sc.Text()), "y") {
+		fmt.Println("Nothing was changed.")
+		return
+	}
+	skipped, err := mgr.ApplyBookEdits(diff.Edits)
+	for _, issue := range skipped {
+		fmt.Printf("  line %d: skipped, %s\n", issue.Line, issue.Reason)
+	}
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("✓ Updated %d book(s)\n", len(diff.Edits)-len(skipped))
+}
+
+// handleImportCSV adds the books or members listed in a CSV file, reporting
+// the rows it skipped.
+func handleImportCSV(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	what, path := splitArgs(args)
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	if path == "" {
+		var ok bool
+		if path, ok = promptPath(sc, "CSV file: ", "", true); !ok {
+			return
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		printError(err)
+		return
+	}
+	defer f.Close()
+
+	var report *library.ImportReport
+	if what == "members" {
+		report, err = mgr.ImportMembersCSV(f)
+	} else {
+		report, err = mgr.ImportBooksCSV(f)
+	}
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("✓ Imported %s: %s\n", what, report)
+	for _, issue := range report.Duplicates {
+		fmt.Printf("  line %d: skipped, %s\n", issue.Line, issue.Reason)
+	}
+	for _, issue := range report.Rejected {
+		fmt.Printf("  line %d: rejected, %s\n", issue.Line, issue.Reason)
+	}
+	if what == "members" && len(report.Added) > 0 {
+		fmt.Println("  New members have no password yet; set one with reset password.")
+	}
+}
+
+// handleImportCirculation loads a circulation export into this library once
+// its books and members are in place.
+func handleImportCirculation(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	path, ok := promptPath(sc, "Import from file: ", "", true)
+	if !ok {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", path, err)
+		return
+	}
+	var state library.CirculationState
+	if err := json.Unmarshal(data, &state); err != nil {
+		fmt.Printf("Invalid circulation export: %v\n", err)
+		return
+	}
+
+	if err := mgr.ImportCirculation(&state); err != nil {
+		fmt.Printf("Import failed, nothing was changed: %v\n", err)
+		return
+	}
+	fmt.Printf("✓ Imported %d loans, %d holds and %d charges\n",
+		len(state.Checkouts), len(state.Holds), len(state.Fines))
+}
+
+// handleImportLegacy migrates the JSON data file of the old file-based
+// version. Like grant admin, it needs no staff login while the database has
+// no staff yet, since a freshly migrated library starts without any.
+func handleImportLegacy(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	admins, err := mgr.CountAdmins()
+	if err != nil {
+		printError(err)
+		return
+	}
+	if admins > 0 {
+		if _, err := authenticateStaff(sc, mgr); err != nil {
+			fmt.Printf("Authentication failed: %v\n", err)
+			return
+		}
+	}
+
+	path, ok := promptPath(sc, "Legacy data file (default library.json): ", "library.json", true)
+	if !ok {
+		return
+	}
+
+	report, err := mgr.LoadData(path)
+	if err != nil {
+		fmt.Printf("Import failed, nothing was changed: %v\n", err)
+		return
+	}
+	fmt.Printf("✓ Imported %d books, %d members and %d checkouts\n", report.Books, report.Members, report.Checkouts)
+	fmt.Println("Imported members have no password yet; use 'reset password' before they sign in.")
+}
+
+// handleBackupSchedule shows and changes the nightly snapshot schedule.
+func handleBackupSchedule(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	current, err := mgr.GetBackupSchedule()
+	if err != nil {
+		printError(err)
+		return
+	}
+	if current == nil {
+		fmt.Println("Nightly backups are off.")
+	} else {
+		fmt.Printf("Nightly backups to %s after %02d:00, keeping %d daily and %d weekly snapshots.\n",
+			current.Dir, current.Hour, current.KeepDaily, current.KeepWeekly)
+	}
+
+	prompt("Backup directory (blank to turn backups off): ")
+	if !sc.Scan() {
+		return
+	}
+	dir := strings.TrimSpace(sc.Text())
+	if dir == "" {
+		if err := mgr.SetBackupSchedule(nil); err != nil {
+			printError(err)
+			return
+		}
+		fmt.Println("✓ Nightly backups turned off")
+		return
+	}
+
+	schedule := &library.BackupSchedule{Dir: dir, Hour: 2, KeepDaily: 7, KeepWeekly: 4}
+	for _, field := range []struct {
+		prompt string
+		value  *int
+	}{
+		{"Hour of day to back up", &schedule.Hour},
+		{"Daily snapshots to keep", &schedule.KeepDaily},
+		{"Weekly snapshots to keep", &schedule.KeepWeekly},
+	} {
+		prompt("%s (default %d): ", field.prompt, *field.value)
+		if !sc.Scan() {
+			return
+		}
+		if v := strings.TrimSpace(sc.Text()); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				fmt.Printf("Invalid number: %s\n", v)
+				return
+			}
+			*field.value = n
+		}
+	}
+
+	if err := mgr.SetBackupSchedule(schedule); err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("✓ Nightly backups to %s after %02d:00, keeping %d daily and %d weekly snapshots\n",
+		schedule.Dir, schedule.Hour, schedule.KeepDaily, schedule.KeepWeekly)
+}
+
+// handleBackupVerify restores a snapshot into a scratch database and checks
+// it, defaulting to the newest snapshot in the scheduled backup directory.
+func handleBackupVerify(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	prompt("Snapshot file (blank for the latest): ")
+	if !sc.Scan() {
+		return
+	}
+	path := strings.TrimSpace(sc.Text())
+	if path == "" {
+		schedule, err := mgr.GetBackupSchedule()
+		if err != nil {
+			printError(err)
+			return
+		}
+		if schedule == nil {
+			fmt.Println("Nightly backups are off; enter a snapshot file to verify.")
+			return
+		}
+		snaps, err := library.ListSnapshots(schedule.Dir)
+		if err != nil || len(snaps) == 0 {
+			fmt.Printf("No snapshots found in %s\n", schedule.Dir)
+			return
+		}
+		path = snaps[0].Path
+	}
+
+	v, err := library.VerifyBackup(path)
+	if err != nil {
+		fmt.Printf("✗ %s failed verification: %v\n", path, err)
+		return
+	}
+	fmt.Printf("✓ %s verified: checksum matches, integrity checks passed (%d books, %d members)\n",
+		path, v.Books, v.Members)
+}
+
+// handleOffloadContent moves long texts already in the database into the
+// configured content store.
+func handleOffloadContent(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	n, err := mgr.OffloadContent()
+	if err != nil {
+		fmt.Printf("Error after moving %d texts: %v\n", n, err)
+		return
+	}
+	fmt.Printf("✓ Moved %d book texts to the content store. Run 'db maintain' to shrink the database.\n", n)
+}
+
+// handleDBMaintain runs database maintenance on demand, outside the nightly
+// idle window.
+func handleDBMaintain(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	report, err := mgr.Maintain()
+	if err != nil {
+		fmt.Printf("Maintenance failed: %v\n", err)
+		return
+	}
+	fmt.Printf("✓ Maintenance completed in %v\n", report.Duration.Round(time.Millisecond))
+	fmt.Printf("  Pages: %d → %d\n", report.PagesBefore, report.PagesAfter)
+	if report.CheckpointedFrames >= 0 {
+		fmt.Printf("  WAL frames checkpointed: %d\n", report.CheckpointedFrames)
+	}
+}
+
+// handleValidateCatalog reports inconsistent catalog records; with --fix it
+// offers to repair them.
+func handleValidateCatalog(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	fix := strings.TrimSpace(args) == "--fix"
+	if !fix && strings.TrimSpace(args) != "" {
+		fmt.Println("Usage: validate catalog [--fix]")
+		return
+	}
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	issues, err := mgr.ValidateCatalog(false)
+	if err != nil {
+		fmt.Printf("Validation failed: %v\n", err)
+		return
+	}
+	if len(issues) == 0 {
+		fmt.Println("✓ No problems found in the catalog")
+		return
+	}
+	fmt.Printf("Found %d problem(s):\n", len(issues))
+	for _, issue := range issues {
+		fmt.Printf("  [%s] book %d: %s\n", issue.Check, issue.BookID, issue.Detail)
+	}
+	if !fix {
+		fmt.Println("Run validate catalog --fix to repair them.")
+		return
+	}
+
+	prompt("Repair them? (y/n): ")
+	if !sc.Scan() || !strings.EqualFold(strings.TrimSpace(sc.Text()), "y") {
+		fmt.Println("Nothing was changed.")
+		return
+	}
+	issues, err = mgr.ValidateCatalog(true)
+	fixed := 0
+	for _, issue := range issues {
+		if issue.Fixed {
+			fixed++
+		}
+	}
+	if err != nil {
+		fmt.Printf("Repaired %d problem(s), then failed: %v\n", fixed, err)
+		return
+	}
+	fmt.Printf("✓ Repaired %d problem(s)\n", fixed)
+}
+
+// handleQuery runs `query [--csv|--json] "SELECT ..."` for staff who need an
+// ad-hoc report. Results print in the output format (see set output) unless
+// a format is given.
+func handleQuery(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	args = strings.TrimSpace(args)
+	format := outputFormat
+	for _, f := range []string{"--csv", "--json"} {
+		if strings.HasPrefix(args, f+" ") {
+			format = strings.TrimPrefix(f, "--")
+			args = strings.TrimSpace(strings.TrimPrefix(args, f))
+		}
+	}
+	if len(args) >= 2 && args[0] == '"' && args[len(args)-1] == '"' {
+		args = args[1 : len(args)-1]
+	}
+	if args == "" {
+		fmt.Println(`Usage: query [--csv|--json] "SELECT ..."`)
+		return
+	}
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	res, err := mgr.Query(args)
+	if err != nil {
+		fmt.Printf("Query failed: %v\n", err)
+		return
+	}
+	if err := writeQueryResult(os.Stdout, res, format); err != nil {
+		printError(err)
+		return
+	}
+	if res.Truncated {
+		fmt.Fprintf(os.Stderr, "(only the first %d rows are shown)\n", library.MaxQueryRows)
+	}
+}
+
+// writeQueryResult prints res as an aligned table, CSV with a header row, or
+// a JSON array of objects keyed by column name.
+func writeQueryResult(w io.Writer, res *library.QueryResult, format string) error {
+	cell := func(v any) string {
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprint(v)
+	}
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(res.Columns)
+		for _, row := range res.Rows {
+			rec := make([]string, len(row))
+			for i, v := range row {
+				rec[i] = cell(v)
+			}
+			cw.Write(rec)
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		out := make([]map[string]any, len(res.Rows))
+		for r, row := range res.Rows {
+			out[r] = make(map[string]any, len(row))
+			for i, v := range row {
+				out[r][res.Columns[i]] = v
+			}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(res.Columns, "\t"))
+		for _, row := range res.Rows {
+			cells := make([]string, len(row))
+			for i, v := range row {
+				cells[i] = cell(v)
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "(%d rows)\n", len(res.Rows))
+		return nil
+	}
+}
+
+// handleBoard shows the availability board full screen for a wall display at
+// the desk, redrawing it every boardRefresh until Enter is pressed.
+func handleBoard(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	done := make(chan struct{})
+	go func() {
+		sc.Scan()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(boardRefresh)
+	defer ticker.Stop()
+	for {
+		fmt.Print("\033[2J\033[H")
+		if s, err := mgr.GetBoardStats(displayTime(mgr.Now())); err != nil {
+			printError(err)
+		} else {
+			fmt.Println("═══════════════════════════════════════════")
+			fmt.Println("            📚  LIBRARY STATUS")
+			fmt.Println("═══════════════════════════════════════════")
+			fmt.Printf("  Available on the shelf   %6d of %d\n", s.AvailableBooks, s.TotalBooks)
+			fmt.Printf("  Checked out today        %6d\n", s.CheckoutsToday)
+			fmt.Printf("  Holds ready for pickup   %6d\n", s.HoldsReady)
+			fmt.Printf("  Overdue                  %6d\n", s.Overdue)
+			fmt.Println("═══════════════════════════════════════════")
+			fmt.Printf("  Updated %s · press Enter to exit\n", displayTime(s.UpdatedTime).Format("15:04:05"))
+		}
+		select {
+		case <-done:
+			fmt.Print("\033[2J\033[H")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func handleRunJobs(jobs *library.JobRunner) {
+	for _, res := range jobs.RunAll() {
+		if res.Err != nil {
+			fmt.Printf("Job %s failed: %v\n", res.Name, res.Err)
+		} else {
+			fmt.Printf("Job %s completed in %v\n", res.Name, res.Duration.Round(time.Millisecond))
+		}
+	}
+}
+
+func handleAddBook(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	prompt("Title: ")
+	if !sc.Scan() {
+		return
+	}
+	title := strings.TrimSpace(sc.Text())
+
+	// Another copy of a title the library has is better added as a copy
+	// than typed in again as a new record
+	if dups, err := mgr.FindTitleDuplicates(title); err == nil && len(dups) > 0 {
+		original, ok := promptCopyOf(sc, dups)
+		if !ok {
+			return
+		}
+		if original != 0 {
+			addCopy(mgr, original)
+			return
+		}
+	}
+
+	prompt("Author: ")
+	if !sc.Scan() {
+		return
+	}
+	author := strings.TrimSpace(sc.Text())
+
+	prompt("Path to text file (optional): ")
+	if !sc.Scan() {
+		return
+	}
+	path := strings.TrimSpace(sc.Text())
+	if path != "" {
+		if _, errStat := os.Stat(filepath.Clean(path)); errStat != nil {
+			fmt.Printf("File error: %v. Adding book without content.\n", errStat)
+			path = ""
+		}
+	}
+
+	meta, ok := promptBookMetadata(sc)
+	if !ok {
+		return
+	}
+	id, err := mgr.AddBookWithMetadata(title, author, path, meta)
+
+	if err != nil {
+		fmt.Printf("Error adding book: %v\n", err)
+	} else {
+		if path == "" {
+			fmt.Printf("Added book ID %d (no content). Use 'update content' later.\n", id)
+		} else {
+			fmt.Printf("Added book ID %d with content.\n", id)
+		}
+	}
+}
+
+// promptCopyOf warns that books named like a new one exist and asks
+// whether to add a copy of one instead. It returns the book to copy, or 0
+// to go on adding a new record.
+func promptCopyOf(sc *bufio.Scanner, dups []*library.Book) (int64, bool) {
+	if len(dups) == 1 {
+		prompt("A book named '%s' by %s already exists (ID %d) — add another copy instead? (y/n): ", dups[0].Title, dups[0].Author, dups[0].ID)
+		if !sc.Scan() {
+			return 0, false
+		}
+		if strings.EqualFold(strings.TrimSpace(sc.Text()), "y") {
+			return dups[0].ID, true
+		}
+		return 0, true
+	}
+
+	fmt.Println("Books with a title like this already exist:")
+	for _, b := range dups {
+		fmt.Printf("  %d: '%s' by %s\n", b.ID, b.Title, b.Author)
+	}
+	for {
+		prompt("ID of the book to add another copy of (Enter to add a new book): ")
+		if !sc.Scan() {
+			return 0, false
+		}
+		v := strings.TrimSpace(sc.Text())
+		if v == "" {
+			return 0, true
+		}
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err == nil && slices.ContainsFunc(dups, func(b *library.Book) bool { return b.ID == id }) {
+			return id, true
+		}
+		fmt.Println("Enter one of the IDs listed, or press Enter.")
+	}
+}
+
+// handleAddCopy adds another copy of a book in the catalog.
+func handleAddCopy(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	bookIDStr, _ := splitArgs(args)
+	bookID, ok := argOrPromptID(sc, bookIDStr, "Book ID to copy: ", "book")
+	if !ok {
+		return
+	}
+	addCopy(mgr, bookID)
+}
+
+func addCopy(mgr *library.LibraryManager, bookID int64) {
+	id, err := mgr.AddCopy(bookID)
+	if err != nil {
+		fmt.Printf("Error adding copy: %v\n", err)
+		return
+	}
+	fmt.Printf("Added book ID %d, a copy of book %d.\n", id, bookID)
+}
+
+// promptBookMetadata asks for a book's optional publication details; Enter
+// skips each one.
+func promptBookMetadata(sc *bufio.Scanner) (library.BookMetadata, bool) {
+	var meta library.BookMetadata
+	for _, f := range []struct {
+		label string
+		s     *string
+		n     *int
+	}{
+		{label: "ISBN", s: &meta.ISBN},
+		{label: "Publisher", s: &meta.Publisher},
+		{label: "Publication year", n: &meta.PublicationYear},
+		{label: "Language", s: &meta.Language},
+		{label: "Page count", n: &meta.PageCount},
+	} {
+		prompt("%s (optional): ", f.label)
+		if !sc.Scan() {
+			return meta, false
+		}
+		v := strings.TrimSpace(sc.Text())
+		if f.s != nil {
+			*f.s = v
+		} else if v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				fmt.Printf("Invalid %s: %s\n", strings.ToLower(f.label), v)
+				return meta, false
+			}
+			*f.n = n
+		}
+	}
+	return meta, true
+}
+
+// handleSetMetadata replaces a book's publication details.
+func handleSetMetadata(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	bookID, ok := promptBookID(sc, "Book ID: ")
+	if !ok {
+		return
+	}
+	book, err := mgr.GetBook(bookID)
+	if err != nil {
+		fmt.Printf("Error: Book with ID %d not found\n", bookID)
+		return
+	}
+	fmt.Printf("Publication details for '%s' (blank fields are cleared):\n", book.Title)
+
+	meta, ok := promptBookMetadata(sc)
+	if !ok {
+		return
+	}
+	if err := mgr.SetBookMetadata(bookID, meta); err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("✓ Publication details of '%s' updated\n", book.Title)
+}
+
+// handleEditBook corrects a book's title and author (`edit book [<id>]`).
+// Enter keeps the current value; publication details are only asked for on
+// request.
+func handleEditBook(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	bookIDStr, _ := splitArgs(args)
+
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	bookID, ok := argOrPromptID(sc, bookIDStr, "Book ID: ", "book")
+	if !ok {
+		return
+	}
+	book, err := mgr.GetBook(bookID)
+	if err != nil {
+		fmt.Printf("Error: Book with ID %d not found\n", bookID)
+		return
+	}
+
+	title, author := book.Title, book.Author
+	for _, f := range []struct {
+		label string
+		value *string
+	}{{"Title", &title}, {"Author", &author}} {
+		prompt("%s [%s]: ", f.label, *f.value)
+		if !sc.Scan() {
+			return
+		}
+		if v := strings.TrimSpace(sc.Text()); v != "" {
+			*f.value = v
+		}
+	}
+
+	meta := book.BookMetadata
+	prompt("Change the publication details too? (y/n): ")
+	if !sc.Scan() {
+		return
+	}
+	if strings.EqualFold(strings.TrimSpace(sc.Text()), "y") {
+		fmt.Println("Publication details (blank fields are cleared):")
+		if meta, ok = promptBookMetadata(sc); !ok {
+			return
+		}
+	}
+
+	if err := mgr.UpdateBookMetadata(bookID, title, author, meta); err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("✓ Book %d is now '%s' by %s\n", bookID, title, author)
+}
+
+// bookEdition summarizes a book's ISBN and year for listings.
+func bookEdition(b *library.Book) string {
+	parts := []string{}
+	if b.ISBN != "" {
+		parts = append(parts, b.ISBN)
+	}
+	if b.PublicationYear != 0 {
+		parts = append(parts, strconv.Itoa(b.PublicationYear))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func handleAddMember(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	prompt("Name: ")
+	if !sc.Scan() {
+		return
+	}
+	name := strings.TrimSpace(sc.Text())
+
+	password, err := readPassword(fmt.Sprintf("Enter password for %s: ", name))
+	if err != nil {
+		fmt.Printf("Error reading password: %v\n", err)
+		return
+	}
+
+	if strings.TrimSpace(password) == "" {
+		fmt.Println("Error: Password cannot be empty")
+		return
+	}
+
+	prompt("Membership type (adult/child/senior/staff) [adult]: ")
+	if !sc.Scan() {
+		return
+	}
+	tier := strings.ToLower(strings.TrimSpace(sc.Text()))
+	if tier == "" {
+		tier = library.TierAdult
+	}
+
+	id, err := mgr.AddMemberWithTier(name, password, tier)
+	if err != nil {
+		printError(err)
+	} else {
+		fmt.Printf("Added %s member '%s' with ID %d\n", tier, name, id)
+	}
+}
+
+func handleResetPassword(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	staffID, err := authenticateStaff(sc, mgr)
+	if err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	memberID, ok := promptMemberID(sc, "Member ID: ")
+	if !ok {
+		return
+	}
+
+	// Verify member exists and get their name
+	member, err := mgr.GetMember(memberID)
+	if err != nil {
+		fmt.Printf("Error: Member with ID %d not found\n", memberID)
+		return
+	}
+
+	newPassword, err := readPassword(fmt.Sprintf("Enter new password for %s (ID: %d): ", member.Name, memberID))
+	if err != nil {
+		fmt.Printf("Error reading password: %v\n", err)
+		return
+	}
+
+	if strings.TrimSpace(newPassword) == "" {
+		fmt.Println("Error: Password cannot be empty")
+		return
+	}
+
+	if err := mgr.ResetMemberPasswordAs(memberID, newPassword, staffID); err != nil {
+		fmt.Printf("Error resetting password: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Password successfully reset for %s (ID: %d)\n", member.Name, memberID)
+}
+
+// handleChangePassword lets members change their own password. Unlike
+// reset password it needs the current one, even when they are logged in.
+func handleChangePassword(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	var memberID int64
+	if session != nil && session.Touch() == nil {
+		memberID = session.MemberID()
+	} else {
+		var ok bool
+		if memberID, ok = promptMemberID(sc, "Member ID: "); !ok {
+			return
+		}
+	}
+
+	current, err := readPassword("Current password: ")
+	if err != nil {
+		fmt.Printf("Error reading password: %v\n", err)
+		return
+	}
+	newPassword, err := readPassword("New password: ")
+	if err != nil {
+		fmt.Printf("Error reading password: %v\n", err)
+		return
+	}
+	confirm, err := readPassword("Confirm new password: ")
+	if err != nil {
+		fmt.Printf("Error reading password: %v\n", err)
+		return
+	}
+	if newPassword != confirm {
+		fmt.Println("Error: passwords do not match")
+		return
+	}
+
+	if err := mgr.ChangeMemberPassword(memberID, current, newPassword); err != nil {
+		fmt.Printf("Error changing password: %v\n", err)
+		return
+	}
+	fmt.Println("✓ Password changed")
+}
+
+// handleRenameMember changes a member's name (`rename member [<id>]`).
+func handleRenameMember(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	memberIDStr, _ := splitArgs(args)
+
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	memberID, ok := argOrPromptID(sc, memberIDStr, "Member ID: ", "member")
+	if !ok {
+		return
+	}
+	member, err := mgr.GetMember(memberID)
+	if err != nil {
+		fmt.Printf("Error: Member with ID %d not found\n", memberID)
+		return
+	}
+
+	prompt("New name for %s: ", member.Name)
+	if !sc.Scan() {
+		return
+	}
+	name := strings.TrimSpace(sc.Text())
+	if err := mgr.UpdateMemberName(memberID, name); err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("✓ %s is now %s\n", member.Name, name)
+}
+
+// parseTagFilter reads the optional `--tag <tag>` after list books and
+// search book.
+func parseTagFilter(args string) (string, error) {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		return "", nil
+	}
+	flag, tag := splitArgs(args)
+	if flag != "--tag" || tag == "" {
+		return "", fmt.Errorf("unexpected arguments: %s", args)
+	}
+	return tag, nil
+}
+
+// listPageSize is how many rows list books and list members show at a
+// time, from LIBRARY_PAGE_SIZE.
+var listPageSize = defaultConfig.PageSize
+
+// pageThrough shows total rows a page at a time: show prints limit rows
+// from offset, and between pages the reader presses Enter for more or q to
+// stop.
+func pageThrough(sc *bufio.Scanner, total int, show func(offset, limit int) error) {
+	for offset := 0; offset < total; offset += listPageSize {
+		if offset > 0 {
+			prompt("-- %d of %d shown; Enter for more, q to stop: ", offset, total)
+			if !sc.Scan() || strings.EqualFold(strings.TrimSpace(sc.Text()), "q") {
+				return
+			}
+		}
+		if err := show(offset, listPageSize); err != nil {
+			printError(err)
+			return
+		}
+	}
+}
+
+func handleListBooks(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	tag, err := parseTagFilter(args)
+	if err != nil {
+		printError(err)
+		fmt.Println("Usage: list books [--tag <tag>]")
+		return
+	}
+
+	// The whole catalog is fetched a page at a time; a tag's books at once
+	var total int
+	var page func(offset, limit int) ([]*library.Book, error)
+	if tag != "" {
+		tagged, err := mgr.GetBooksByTag(tag)
+		if err != nil {
+			printError(err)
+			return
+		}
+		total = len(tagged)
+		page = func(offset, limit int) ([]*library.Book, error) {
+			return tagged[offset:min(offset+limit, len(tagged))], nil
+		}
+	} else {
+		if total, err = mgr.CountBooks(); err != nil {
+			printError(err)
+			return
+		}
+		page = mgr.GetBooksPage
+	}
+	if total == 0 && machineOutput() {
+		writeBookRecords(mgr, nil, nil)
+		return
+	}
+	if total == 0 {
+		if tag != "" {
+			fmt.Printf("No books tagged '%s'.\n", tag)
+			return
+		}
+		fmt.Println("No books in library.")
+		return
+	}
+
+	queues, err := mgr.GetReservationCounts()
+	if err != nil {
+		printError(err)
+		return
+	}
+	if machineOutput() {
+		books, err := page(0, total)
+		if err != nil {
+			printError(err)
+			return
+		}
+		writeBookRecords(mgr, books, queues)
+		return
+	}
+
+	fmt.Printf("%-5s %-30s %-25s %-19s %-10s %-20s %s\n", "ID", "Title", "Author", "ISBN, Year", "Available", "Borrower", "Reservation Queue")
+	fmt.Println(strings.Repeat("-", 140))
+
+	pageThrough(sc, total, func(offset, limit int) error {
+		books, err := page(offset, limit)
+		if err != nil {
+			return err
+		}
+		for _, b := range books {
+			// Get borrower information
+			var borrowerInfo string
+			if b.Available {
+				borrowerInfo = "None"
+			} else {
+				if member, err := mgr.GetMember(b.BorrowerID); err == nil {
+					borrowerInfo = fmt.Sprintf("%s (ID: %d)", member.Name, member.ID)
+				} else {
+					borrowerInfo = fmt.Sprintf("ID: %d", b.BorrowerID)
+				}
+			}
+
+			// Get reservation queue
+			queueInfo := "None"
+			if q, ok := queues[b.ID]; ok {
+				queueInfo = formatQueue(q)
+			}
+
+			// Print book information
+			availStr := "Yes"
+			if b.NonCirculating {
+				availStr = "Ref only"
+			} else if !b.Available {
+				availStr = "No"
+			}
+
+			fmt.Printf("%-5d %-30s %-25s %-19s %-10s %-20s %s\n",
+				b.ID,
+				truncateString(b.Title, 30),
+				truncateString(b.Author, 25),
+				bookEdition(b),
+				availStr,
+				truncateString(borrowerInfo, 20),
+				queueInfo)
+		}
+		return nil
+	})
+}
+
+// bookRecordColumns are the fields of a book in JSON or CSV listings.
+var bookRecordColumns = []string{"id", "title", "author", "isbn", "publication_year", "available",
+	"reference_only", "borrower_id", "borrower", "reservations", "next_member_id"}
+
+// writeBookRecords prints books as JSON or CSV records with their borrower
+// and reservation queue. A missing borrower or queue is null.
+func writeBookRecords(mgr *library.LibraryManager, books []*library.Book, queues map[int64]*library.ReservationCount) {
+	rows := make([][]any, 0, len(books))
+	for _, b := range books {
+		var borrowerID, borrower, nextMember any
+		if !b.Available && b.BorrowerID > 0 {
+			borrowerID = b.BorrowerID
+			if member, err := mgr.GetMember(b.BorrowerID); err == nil {
+				borrower = member.Name
+			}
+		}
+		waiting := 0
+		if q, ok := queues[b.ID]; ok {
+			waiting, nextMember = q.QueueLength, q.FirstMemberID
+		}
+		var isbn, year any
+		if b.ISBN != "" {
+			isbn = b.ISBN
+		}
+		if b.PublicationYear != 0 {
+			year = b.PublicationYear
+		}
+		rows = append(rows, []any{b.ID, b.Title, b.Author, isbn, year, b.Available,
+			b.NonCirculating, borrowerID, borrower, waiting, nextMember})
+	}
+	writeRecords(bookRecordColumns, rows)
+}
+
+func handleListMembers(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	total, err := mgr.CountMembers()
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	if machineOutput() {
+		members, err := mgr.GetMembersPage(0, total)
+		if err != nil {
+			printError(err)
+			return
+		}
+		rows := make([][]any, 0, len(members))
+		for _, m := range members {
+			var expires any
+			if m.ExpiryTime != nil {
+				expires = displayTime(*m.ExpiryTime).Format("2006-01-02")
+			}
+			rows = append(rows, []any{m.ID, m.Name, m.Tier, m.PasswordHash != "", m.IsAdmin, m.Active, expires, m.Expired(mgr.Now())})
+		}
+		writeRecords([]string{"id", "name", "tier", "password_set", "staff", "active", "expires", "expired"}, rows)
+		return
+	}
+	if total == 0 {
+		fmt.Println("No members registered.")
+		return
+	}
+
+	fmt.Printf("%-5s %-30s %-8s %-15s %-6s %-12s\n", "ID", "Name", "Tier", "Password Set", "Staff", "Expires")
+	fmt.Println(strings.Repeat("-", 85))
+
+	pageThrough(sc, total, func(offset, limit int) error {
+		members, err := mgr.GetMembersPage(offset, limit)
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			passwordStatus := "No"
+			if member.PasswordHash != "" {
+				passwordStatus = "Yes"
+			}
+			staffStatus := "No"
+			if member.IsAdmin {
+				staffStatus = "Yes"
+			}
+			expires := "Never"
+			if member.ExpiryTime != nil {
+				expires = displayTime(*member.ExpiryTime).Format("2006-01-02")
+				if member.Expired(mgr.Now()) {
+					expires += " (expired)"
+				}
+			}
+			if !member.Active {
+				expires = "Deactivated"
+			}
+			fmt.Printf("%-5d %-30s %-8s %-15s %-6s %-12s\n", member.ID, member.Name, member.Tier, passwordStatus, staffStatus, expires)
+		}
+		return nil
+	})
+}
+
+func handleSearchBooks(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	tag, err := parseTagFilter(args)
+	if err != nil {
+		printError(err)
+		fmt.Println("Usage: search book [--tag <tag>]")
+		return
+	}
+	prompt("Query: ")
+	if !sc.Scan() {
+		return
+	}
+	runBookSearch(sc, mgr, strings.TrimSpace(sc.Text()), tag)
+}
+
+// suggestionLimit is how many corrected queries a fruitless search offers.
+const suggestionLimit = 3
+
+// runBookSearch searches for query and prints the matches, best first, each
+// with an excerpt of its text around the matched words. It keeps only books
+// tagged tag if it is set. When nothing matches it offers corrected queries,
+// and runs the one picked by number.
+func runBookSearch(sc *bufio.Scanner, mgr *library.LibraryManager, query, tag string) {
+	results, err := mgr.SearchBookResults(query)
+	if showSearchSyntaxError(err, len("Query: ")) {
+		fmt.Println("  Narrow words to a field with title:, author: or content:, and add available:yes or available:no.")
+		return
+	}
+	if err != nil {
+		printError(err)
+		return
+	}
+	if tag != "" && len(results) > 0 {
+		if results, err = filterByTag(mgr, results, tag); err != nil {
+			printError(err)
+			return
+		}
+		if len(results) == 0 && !machineOutput() {
+			fmt.Printf("No books tagged '%s' match '%s'.\n", tag, query)
+			return
+		}
+	}
+	if machineOutput() {
+		writeSearchRecords(mgr, results)
+		return
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("No books found matching '%s'.\n", query)
+		suggestions, err := mgr.SuggestQueries(query, suggestionLimit)
+		if err != nil || len(suggestions) == 0 {
+			return
+		}
+		fmt.Println("Did you mean:")
+		for i, s := range suggestions {
+			fmt.Printf("  %d. %s\n", i+1, s)
+		}
+		n, ok := askValue(sc, "Search again with (number, or Enter to skip): ", func(s string) (int, error) {
+			if s == "" {
+				return 0, nil
+			}
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 || n > len(suggestions) {
+				return 0, fmt.Errorf("pick a number from 1 to %d", len(suggestions))
+			}
+			return n, nil
+		})
+		if ok && n > 0 {
+			runBookSearch(sc, mgr, suggestions[n-1], tag)
+		}
+		return
+	}
+
+	fmt.Printf("Found %d book(s) matching '%s':\n", len(results), query)
+	fmt.Printf("%-4s %-5s %-30s %-25s %-19s %-10s %-25s\n", "#", "ID", "Title", "Author", "ISBN, Year", "Available", "Borrower")
+	fmt.Println(strings.Repeat("-", 125))
+
+	listedBooks = listedBooks[:0]
+	highlighted := false
+	for i, r := range results {
+		book := r.Book
+		borrowerName := ""
+		if !book.Available && book.BorrowerID > 0 {
+			if member, err := mgr.GetMember(book.BorrowerID); err == nil {
+				borrowerName = member.Name
+			}
+		}
+		title := book.Title
+		if r.TitleHighlight != "" {
+			title = r.TitleHighlight
+			highlighted = true
+		}
+		listedBooks = append(listedBooks, book.ID)
+		fmt.Printf("%-4s %-5d %-30s %-25s %-19s %-10t %-25s\n", fmt.Sprintf("#%d", i+1), book.ID, title, book.Author, bookEdition(book), book.Available, borrowerName)
+		if snippet := strings.Join(strings.Fields(r.Snippet), " "); snippet != "" {
+			fmt.Printf("%-10s %s\n", "", snippet)
+		}
+	}
+	if highlighted {
+		fmt.Println("Matched words are shown in [brackets].")
+	}
+	fmt.Println("At a Book ID question, #n picks result n.")
+}
+
+// writeSearchRecords prints search results as JSON or CSV records, best
+// first, and remembers them for #n like the table does.
+func writeSearchRecords(mgr *library.LibraryManager, results []*library.SearchResult) {
+	listedBooks = listedBooks[:0]
+	rows := make([][]any, 0, len(results))
+	for i, r := range results {
+		book := r.Book
+		listedBooks = append(listedBooks, book.ID)
+		var borrower, isbn, year any
+		if !book.Available && book.BorrowerID > 0 {
+			if member, err := mgr.GetMember(book.BorrowerID); err == nil {
+				borrower = member.Name
+			}
+		}
+		if book.ISBN != "" {
+			isbn = book.ISBN
+		}
+		if book.PublicationYear != 0 {
+			year = book.PublicationYear
+		}
+		rows = append(rows, []any{i + 1, book.ID, book.Title, book.Author, isbn, year, book.Available, borrower,
+			r.Score, strings.Join(strings.Fields(r.Snippet), " ")})
+	}
+	writeRecords([]string{"rank", "id", "title", "author", "isbn", "publication_year", "available", "borrower", "score", "excerpt"}, rows)
+}
+
+// filterByTag keeps the results whose books are tagged tag, in their
+// original order.
+func filterByTag(mgr *library.LibraryManager, results []*library.SearchResult, tag string) ([]*library.SearchResult, error) {
+	tagged, err := mgr.GetBooksByTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[int64]bool, len(tagged))
+	for _, b := range tagged {
+		ids[b.ID] = true
+	}
+	var kept []*library.SearchResult
+	for _, r := range results {
+		if ids[r.Book.ID] {
+			kept = append(kept, r)
+		}
+	}
+	return kept, nil
+}
+
+// handleTags lists the tags in use, or with a book ID, that book's tags.
+func handleTags(mgr *library.LibraryManager, args string) {
+	args = strings.TrimSpace(args)
+	if args != "" {
+		bookID, err := strconv.ParseInt(args, 10, 64)
+		if err != nil {
+			fmt.Println("Usage: tags [<book id>]")
+			return
+		}
+		book, err := mgr.GetBook(bookID)
+		if err != nil {
+			printError(err)
+			return
+		}
+		tags, err := mgr.GetBookTags(bookID)
+		if err != nil {
+			printError(err)
+			return
+		}
+		if len(tags) == 0 {
+			fmt.Printf("'%s' has no tags.\n", book.Title)
+			return
+		}
+		fmt.Printf("'%s': %s\n", book.Title, strings.Join(tags, ", "))
+		return
+	}
+
+	tags, err := mgr.GetTags()
+	if err != nil {
+		printError(err)
+		return
+	}
+	if len(tags) == 0 {
+		fmt.Println("No books have been tagged yet.")
+		return
+	}
+	fmt.Printf("%-40s %s\n", "Tag", "Books")
+	fmt.Println(strings.Repeat("-", 48))
+	for _, t := range tags {
+		fmt.Printf("%-40s %d\n", t.Name, t.Books)
+	}
+}
+
+// handleTagBook files a book under a tag, or with add false takes the tag
+// off. args is "<book id> <tag>".
+func handleTagBook(sc *bufio.Scanner, mgr *library.LibraryManager, add bool, args string) {
+	id, tag := splitArgs(args)
+	bookID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || tag == "" {
+		fmt.Println("Usage: tag book|untag book <id> <tag>")
+		return
+	}
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+	if add {
+		if err := mgr.TagBook(bookID, tag); err != nil {
+			printError(err)
+			return
+		}
+		fmt.Printf("✓ Book %d tagged '%s'\n", bookID, tag)
+		return
+	}
+	if err := mgr.UntagBook(bookID, tag); err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("✓ Tag '%s' removed from book %d\n", tag, bookID)
+}
+
+// showSearchSyntaxError explains a malformed search query, with a caret
+// under the problem in the query as typed indent columns in. It reports
+// whether err was one.
+func showSearchSyntaxError(err error, indent int) bool {
+	var syntaxErr *library.SearchSyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return false
+	}
+	fmt.Printf("%s^\n", strings.Repeat(" ", indent+syntaxErr.Column-1))
+	fmt.Printf("✗ %s\n", syntaxErr.Msg)
+	fmt.Println(`  Words match anywhere; use "exact phrase", OR, NOT, -word, prefix*, a NEAR/5 b and (groups).`)
+	return true
+}
+
+// handleSearchNotes searches the member's own notes and highlights in every
+// book.
+// similarLimit is how many books similar prints.
+const similarLimit = 5
+
+// handleSimilar lists the books whose text most resembles a book's, to
+// spot duplicate editions and suggest read-alikes.
+func handleSimilar(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	bookIDStr, _ := splitArgs(args)
+	bookID, ok := argOrPromptID(sc, bookIDStr, "Book ID: ", "book")
+	if !ok {
+		return
+	}
+	book, err := mgr.GetBook(bookID)
+	if err != nil {
+		fmt.Printf("Error: Book with ID %d not found\n", bookID)
+		return
+	}
+
+	similar, err := mgr.SimilarBooks(bookID, similarLimit)
+	if err != nil {
+		printError(err)
+		return
+	}
+	if len(similar) == 0 {
+		fmt.Printf("No books resemble '%s'.\n", book.Title)
+		return
+	}
+	fmt.Printf("Books similar to '%s':\n", book.Title)
+	fmt.Printf("%-5s %-30s %-25s %-10s\n", "ID", "Title", "Author", "Similarity")
+	fmt.Println(strings.Repeat("-", 90))
+	for _, s := range similar {
+		note := ""
+		if s.LikelyDuplicate {
+			note = "  (likely the same text)"
+		}
+		fmt.Printf("%-5d %-30s %-25s %9.0f%%%s\n", s.Book.ID, s.Book.Title, s.Book.Author, s.Similarity*100, note)
+	}
+}
+
+// analyzeTopWords and concordanceLimit bound what analyze book prints.
+const (
+	analyzeTopWords  = 20
+	concordanceLimit = 50
+)
+
+// handleAnalyzeBook prints word statistics for a book, or with --word every
+// place the word occurs: analyze book 3 --word "ring".
+func handleAnalyzeBook(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	bookIDStr, rest := splitArgs(args)
+	var word string
+	if rest != "" {
+		flag, value := splitArgs(rest)
+		if flag != "--word" || value == "" {
+			fmt.Println("Usage: analyze book <id> [--word \"ring\"]")
+			return
+		}
+		word = value
+	}
+
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	bookID, ok := argOrPromptID(sc, bookIDStr, "Book ID: ", "book")
+	if !ok {
+		return
+	}
+
+	if word != "" {
+		lines, err := mgr.Concordance(bookID, word)
+		if err != nil {
+			printError(err)
+			return
+		}
+		if len(lines) == 0 {
+			fmt.Printf("'%s' does not occur in book %d.\n", word, bookID)
+			return
+		}
+		fmt.Printf("'%s' occurs %d time(s):\n", word, len(lines))
+		for i, l := range lines {
+			if i == concordanceLimit {
+				fmt.Printf("  … and %d more\n", len(lines)-concordanceLimit)
+				break
+			}
+			fmt.Printf("  p.%-5d %s\n", l.Page, l.Context)
+		}
+		return
+	}
+
+	a, err := mgr.AnalyzeBook(bookID, analyzeTopWords)
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("'%s': %d page(s), %d word(s), %d unique\n", a.Title, a.Pages, a.TotalWords, a.UniqueWords)
+	if len(a.Top) == 0 {
+		return
+	}
+	fmt.Println("Most frequent words (common words left out):")
+	for i, w := range a.Top {
+		fmt.Printf("  %2d. %-20s %d\n", i+1, w.Word, w.Count)
+	}
+}
+
+func handleSearchNotes(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	query := strings.TrimSpace(args)
+	indent := len("> search notes ")
+	if query == "" {
+		prompt("Query: ")
+		if !sc.Scan() {
+			return
+		}
+		query = strings.TrimSpace(sc.Text())
+		indent = len("Query: ")
+	}
+	sess, ok := memberSession(sc, mgr)
+	if !ok {
+		return
+	}
+
+	matches, err := mgr.SearchAnnotations(sess.MemberID(), query)
+	if showSearchSyntaxError(err, indent) {
+		return
+	}
+	if err != nil {
+		printError(err)
+		return
+	}
+	if len(matches) == 0 {
+		fmt.Printf("No notes or highlights match '%s'.\n", query)
+		return
+	}
+	fmt.Printf("Found %d match(es):\n", len(matches))
+	for _, a := range matches {
+		fmt.Printf("  %s, page %d (%s): %s\n", a.BookTitle, a.Page, a.Kind, a.Snippet)
+	}
+}
+
+func handleCheckout(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	bookID, ok := pickBook(sc, mgr, "Book ID or title: ")
+	if !ok {
+		return
+	}
+
+	sess, ok := memberSession(sc, mgr)
+	if !ok {
+		return
+	}
+	memberID := sess.MemberID()
+
+	if !acknowledgeAlerts(sc, mgr, memberID) {
+		fmt.Println("Checkout cancelled.")
+		return
+	}
+
+	if err := sess.CheckoutBook(bookID); err != nil {
+		fmt.Printf("Error checking out book: %v\n", err)
+		return
+	}
+
+	// Get member and book info for confirmation
+	member, _ := mgr.GetMember(memberID)
+	book, _ := mgr.GetBook(bookID)
+	fmt.Printf("Book '%s' checked out to %s\n", book.Title, member.Name)
+	if it, err := mgr.GetItemType(book.ItemType); err == nil && it.DepositCents > 0 {
+		fmt.Printf("Collect a deposit of %s\n", library.FormatCents(it.DepositCents))
+	}
+	if accessories, err := mgr.GetAccessories(bookID); err == nil && len(accessories) > 0 {
+		fmt.Printf("Includes: %s\n", strings.Join(accessories, ", "))
+	}
+}
+
+// handleCart collects several books and checks them out together: one
+// sign-in, one transaction and one receipt. The loan limit applies to the
+// whole cart.
+func handleCart(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	fmt.Println("Add books one per line by ID or title; undo drops the last one, Enter finishes.")
+	var cart []*library.Book
+	for {
+		prompt("Add to cart (%d so far): ", len(cart))
+		if !sc.Scan() {
+			return
+		}
+		answer := strings.TrimSpace(sc.Text())
+		switch {
+		case answer == "":
+		case strings.EqualFold(answer, "cancel"):
+			fmt.Println("Cancelled.")
+			return
+		case strings.EqualFold(answer, "undo"):
+			if len(cart) > 0 {
+				fmt.Printf("  Removed '%s'\n", cart[len(cart)-1].Title)
+				cart = cart[:len(cart)-1]
+			}
+			continue
+		default:
+			if err := checkBookAnswer(answer); err != nil {
+				printError(err)
+				continue
+			}
+			bookID, ok := resolveBook(sc, mgr, answer)
+			if !ok {
+				continue
+			}
+			if slices.ContainsFunc(cart, func(b *library.Book) bool { return b.ID == bookID }) {
+				fmt.Println("  Already in the cart.")
+				continue
+			}
+			book, err := mgr.GetBook(bookID)
+			if err != nil {
+				fmt.Printf("Error: Book with ID %d not found\n", bookID)
+				continue
+			}
+			cart = append(cart, book)
+			fmt.Printf("  Added '%s'\n", book.Title)
+			continue
+		}
+		break
+	}
+	if len(cart) == 0 {
+		fmt.Println("The cart is empty.")
+		return
+	}
+
+	sess, ok := memberSession(sc, mgr)
+	if !ok {
+		return
+	}
+	memberID := sess.MemberID()
+	if !acknowledgeAlerts(sc, mgr, memberID) {
+		fmt.Println("Checkout cancelled.")
+		return
+	}
+
+	ids := make([]int64, len(cart))
+	for i, b := range cart {
+		ids[i] = b.ID
+	}
+	loans, err := sess.CheckoutBooks(ids)
+	if err != nil {
+		fmt.Printf("Error: nothing was checked out: %v\n", err)
+		return
+	}
+
+	member, _ := mgr.GetMember(memberID)
+	fmt.Printf("Receipt for %s (ID: %d), %s\n", member.Name, memberID, displayTime(mgr.Now()).Format("2006-01-02 15:04"))
+	var deposits int64
+	for i, l := range loans {
+		fmt.Printf("  %2d. %-40s due %s\n", i+1, truncateString(l.BookTitle, 40), displayTime(l.DueTime).Format("2006-01-02"))
+		if accessories, err := mgr.GetAccessories(l.BookID); err == nil && len(accessories) > 0 {
+			fmt.Printf("      Includes: %s\n", strings.Join(accessories, ", "))
+		}
+		deposits += l.DepositCents
+	}
+	fmt.Printf("%d item(s) checked out\n", len(loans))
+	if deposits > 0 {
+		fmt.Printf("Collect deposits of %s\n", library.FormatCents(deposits))
+	}
+}
+
+func handleReturn(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	bookID, ok := promptBookID(sc, "Book ID: ")
+	if !ok {
+		return
+	}
+
+	sess, ok := memberSession(sc, mgr)
+	if !ok {
+		return
+	}
+	memberID := sess.MemberID()
+
+	returnedBy, assignedTo, err := sess.ReturnBook(bookID)
+	if err != nil {
+		fmt.Printf("Error returning book: %v\n", err)
+		return
+	}
+
+	// Get book info
+	book, _ := mgr.GetBook(bookID)
+	returnedMember, _ := mgr.GetMember(returnedBy)
+
+	if returnedBy != memberID {
+		if delegate, err := mgr.GetMember(memberID); err == nil {
+			fmt.Printf("Book '%s' returned for %s by %s\n", book.Title, returnedMember.Name, delegate.Name)
+		}
+	} else {
+		fmt.Printf("Book '%s' returned by %s\n", book.Title, returnedMember.Name)
+	}
+
+	if assignedTo > 0 {
+		assignedMember, _ := mgr.GetMember(assignedTo)
+		fmt.Printf("Book is now on hold for %s (next in reservation queue)\n", assignedMember.Name)
+	} else {
+		fmt.Println("Book is now available for checkout")
+	}
+}
+
+// maxPromptAttempts is how many answers an ID or file question takes
+// before the command gives up.
+const maxPromptAttempts = 3
+
+// askValue asks label until parse accepts the answer, at most
+// maxPromptAttempts times. Answering "cancel" abandons the question. It
+// reports whether a value was read.
+func askValue[T any](sc *bufio.Scanner, label string, parse func(string) (T, error)) (T, bool) {
+	var zero T
+	for attempt := 1; ; attempt++ {
+		prompt("%s", label)
+		if !sc.Scan() {
+			return zero, false
+		}
+		answer := strings.TrimSpace(sc.Text())
+		if strings.EqualFold(answer, "cancel") {
+			fmt.Println("Cancelled.")
+			return zero, false
+		}
+		v, err := parse(answer)
+		if err == nil {
+			return v, true
+		}
+		countError(telemetry.ErrInvalidInput)
+		if attempt == maxPromptAttempts {
+			fmt.Printf("Error: %v\n", err)
+			return zero, false
+		}
+		fmt.Printf("Error: %v. Try again, or type cancel.\n", err)
+	}
+}
+
+// listedBooks are the book IDs of the last search results, in the order
+// shown, so a Book ID question can take "#2" for the second.
+var listedBooks []int64
+
+// maxRecent is how many recently used IDs of each kind "!n" can reach.
+const maxRecent = 9
+
+// recentIDs are the IDs given at ID questions this session, by kind and
+// most recent first.
+var recentIDs = map[string][]int64{}
+
+// rememberID puts id first among the recently used IDs of a what.
+func rememberID(what string, id int64) {
+	ids := slices.DeleteFunc(slices.Clone(recentIDs[what]), func(x int64) bool { return x == id })
+	ids = append([]int64{id}, ids...)
+	recentIDs[what] = ids[:min(len(ids), maxRecent)]
+}
+
+// parseID reads the ID of a what, e.g. "member". "!n" reuses the nth most
+// recently used one, and a book can also be given as "#n", the nth of the
+// last search results.
+func parseID(s, what string) (int64, error) {
+	if n, ok := strings.CutPrefix(s, "!"); ok {
+		i, err := strconv.Atoi(n)
+		if err != nil || i < 1 || i > len(recentIDs[what]) {
+			return 0, fmt.Errorf("no recent %s %s", what, s)
+		}
+		return recentIDs[what][i-1], nil
+	}
+	if n, ok := strings.CutPrefix(s, "#"); ok && what == "book" {
+		i, err := strconv.Atoi(n)
+		if err != nil || i < 1 || i > len(listedBooks) {
+			return 0, fmt.Errorf("no search result %s", s)
+		}
+		return listedBooks[i-1], nil
+	}
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid %s ID: %q", what, s)
+	}
+	return id, nil
+}
+
+// promptID asks for the ID of a what.
+func promptID(sc *bufio.Scanner, label, what string) (int64, bool) {
+	id, ok := askValue(sc, label, func(s string) (int64, error) { return parseID(s, what) })
+	if ok {
+		rememberID(what, id)
+	}
+	return id, ok
+}
+
+// promptMemberID asks for a member ID after label, e.g. "Member ID: ".
+func promptMemberID(sc *bufio.Scanner, label string) (int64, bool) {
+	return promptID(sc, label, "member")
+}
+
+// promptBookID asks for a book ID, or "#n" for a search result.
+func promptBookID(sc *bufio.Scanner, label string) (int64, bool) {
+	return promptID(sc, label, "book")
+}
+
+// pickLimit is how many matches pickBook lists.
+const pickLimit = 10
+
+// pickBook asks for a book by ID, by "#n", or by a few words of its title.
+// Words are searched for; a single match is taken, and otherwise the
+// matches are numbered and the user picks one.
+func pickBook(sc *bufio.Scanner, mgr *library.LibraryManager, label string) (int64, bool) {
+	answer, ok := askValue(sc, label, func(s string) (string, error) {
+		if s == "" {
+			return "", fmt.Errorf("a book ID or title is required")
+		}
+		return s, checkBookAnswer(s)
+	})
+	if !ok {
+		return 0, false
+	}
+	return resolveBook(sc, mgr, answer)
+}
+
+// checkBookAnswer rejects an answer meant as a book ID that isn't one;
+// anything else is taken as words of a title.
+func checkBookAnswer(s string) error {
+	if _, err := parseID(s, "book"); err != nil && (strings.ContainsAny(s[:1], "#!") || strings.Trim(s, "0123456789") == "") {
+		return err
+	}
+	return nil
+}
+
+// resolveBook turns an answer checked by checkBookAnswer into a book ID,
+// letting the user pick among the matches when it is words of a title.
+func resolveBook(sc *bufio.Scanner, mgr *library.LibraryManager, answer string) (int64, bool) {
+	if id, err := parseID(answer, "book"); err == nil {
+		rememberID("book", id)
+		return id, true
+	}
+
+	books, err := mgr.SearchBookSummaries(answer)
+	if err != nil {
+		printError(err)
+		return 0, false
+	}
+	switch len(books) {
+	case 0:
+		fmt.Printf("No books found matching '%s'.\n", answer)
+		return 0, false
+	case 1:
+		fmt.Printf("Found '%s' by %s (ID %d).\n", books[0].Title, books[0].Author, books[0].ID)
+		rememberID("book", books[0].ID)
+		return books[0].ID, true
+	}
+
+	shown := books[:min(len(books), pickLimit)]
+	for i, b := range shown {
+		status := "available"
+		if !b.Available {
+			status = "on loan"
+		}
+		fmt.Printf("  %2d. %s by %s (ID %d, %s)\n", i+1, truncateString(b.Title, 40), b.Author, b.ID, status)
+	}
+	if len(books) > len(shown) {
+		fmt.Printf("Showing %d of %d matches; type more of the title to narrow them down.\n", len(shown), len(books))
+	}
+	n, ok := askValue(sc, "Which book (number): ", func(s string) (int, error) {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > len(shown) {
+			return 0, fmt.Errorf("pick a number from 1 to %d", len(shown))
+		}
+		return n, nil
+	})
+	if !ok {
+		return 0, false
+	}
+	rememberID("book", shown[n-1].ID)
+	return shown[n-1].ID, true
+}
+
+// argOrPromptID reads the ID of a what from a command's argument, or asks
+// for it with label when the argument is empty.
+func argOrPromptID(sc *bufio.Scanner, arg, label, what string) (int64, bool) {
+	if arg == "" {
+		return promptID(sc, label, what)
+	}
+	id, err := parseID(arg, what)
+	if err != nil {
+		printError(err)
+		return 0, false
+	}
+	rememberID(what, id)
+	return id, true
+}
+
+// handleRecent lists the books and members "!n" can reuse at ID questions.
+func handleRecent(mgr *library.LibraryManager) {
+	if len(recentIDs["book"]) == 0 && len(recentIDs["member"]) == 0 {
+		fmt.Println("No books or members used yet this session.")
+		return
+	}
+	if ids := recentIDs["book"]; len(ids) > 0 {
+		fmt.Println("Books:")
+		for i, id := range ids {
+			title := "(not found)"
+			if b, err := mgr.GetBook(id); err == nil {
+				title = b.Title
+			}
+			fmt.Printf("  !%d  %-5d %s\n", i+1, id, title)
+		}
+	}
+	if ids := recentIDs["member"]; len(ids) > 0 {
+		fmt.Println("Members:")
+		for i, id := range ids {
+			name := "(not found)"
+			if m, err := mgr.GetMember(id); err == nil {
+				name = m.Name
+			}
+			fmt.Printf("  !%d  %-5d %s\n", i+1, id, name)
+		}
+	}
+}
+
+// promptPath asks for a file path; def is used when the answer is blank.
+// With mustExist, the file has to be there already.
+func promptPath(sc *bufio.Scanner, label, def string, mustExist bool) (string, bool) {
+	return askValue(sc, label, func(s string) (string, error) {
+		if s == "" {
+			s = def
+		}
+		if s == "" {
+			return "", fmt.Errorf("a file is required")
+		}
+		if mustExist {
+			if _, err := os.Stat(s); err != nil {
+				return "", fmt.Errorf("cannot read %s: %w", s, errors.Unwrap(err))
+			}
+		}
+		return s, nil
+	})
+}
+
+// handleDelegate lets a member authorize (or stop authorizing) someone, such
+// as a family member, to collect their holds and return their books.
+func handleDelegate(sc *bufio.Scanner, mgr *library.LibraryManager, add bool) {
+	ownerID, ok := promptMemberID(sc, "Your member ID: ")
+	if !ok {
+		return
+	}
+	if err := authenticateUser(sc, mgr, ownerID); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+	delegateID, ok := promptMemberID(sc, "Delegate's member ID: ")
+	if !ok {
+		return
+	}
+
+	if !add {
+		if err := mgr.RemoveDelegate(ownerID, delegateID); err != nil {
+			printError(err)
+			return
+		}
+		fmt.Printf("✓ Member %d can no longer act for you\n", delegateID)
+		return
+	}
+	if err := mgr.AddDelegate(ownerID, delegateID); err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("✓ Member %d can now collect your holds and return your books\n", delegateID)
+}
+
+func handleListDelegates(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	ownerID, ok := promptMemberID(sc, "Member ID: ")
+	if !ok {
+		return
+	}
+	if err := authenticateUser(sc, mgr, ownerID); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+	delegates, err := mgr.GetDelegates(ownerID)
+	if err != nil {
+		printError(err)
+		return
+	}
+	if len(delegates) == 0 {
+		fmt.Println("No one is authorized to act for you.")
+		return
+	}
+	fmt.Println("Authorized to collect your holds and return your books:")
+	for _, m := range delegates {
+		fmt.Printf("  %s (ID: %d)\n", m.Name, m.ID)
+	}
+}
+
+// handlePickUpHold checks out a hold waiting for pickup to its owner, with
+// the owner or one of their delegates signing for it.
+func handlePickUpHold(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	bookID, ok := promptBookID(sc, "Book ID: ")
+	if !ok {
+		return
+	}
+	memberID, ok := promptMemberID(sc, "Member ID (yours, or the holder's delegate): ")
+	if !ok {
+		return
+	}
+	if err := authenticateUser(sc, mgr, memberID); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	loan, err := mgr.PickUpHold(bookID, memberID)
+	if err != nil {
+		printError(err)
+		return
+	}
+	if loan.MemberID != memberID {
+		fmt.Printf("✓ '%s' collected for %s, due %s\n", loan.BookTitle, loan.MemberName, displayTime(loan.DueTime).Format("2006-01-02"))
+	} else {
+		fmt.Printf("✓ '%s' checked out to you, due %s\n", loan.BookTitle, displayTime(loan.DueTime).Format("2006-01-02"))
+	}
+}
+
+// handleReserveBulk runs `reserve bulk --book 12 --members 3,4,5`, putting a
+// list of members (e.g. a class) in a book's queue in the order given.
+func handleReserveBulk(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	fs := flag.NewFlagSet("reserve bulk", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	bookID := fs.Int64("book", 0, "book ID")
+	membersArg := fs.String("members", "", "comma-separated member IDs, in queue order")
+	if err := fs.Parse(strings.Fields(args)); err != nil || *bookID <= 0 || *membersArg == "" {
+		fmt.Println("Usage: reserve bulk --book <id> --members <id>,<id>,...")
+		return
+	}
+	var memberIDs []int64
+	for _, f := range strings.Split(*membersArg, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(f), 10, 64)
+		if err != nil {
+			fmt.Printf("Invalid member ID: %s\n", f)
+			return
+		}
+		memberIDs = append(memberIDs, id)
+	}
+
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	book, err := mgr.GetBook(*bookID)
+	if err != nil {
+		fmt.Printf("Error: Book with ID %d not found\n", *bookID)
+		return
+	}
+	if err := mgr.ReserveBookFor(*bookID, memberIDs); err != nil {
+		fmt.Printf("Error: %v (no reservations were made)\n", err)
+		return
+	}
+	fmt.Printf("✓ Reserved '%s' for %d member(s)\n", book.Title, len(memberIDs))
+	if book.Available {
+		fmt.Printf("  Member %d checked out the shelf copy; the rest are queued in order\n", memberIDs[0])
+	}
+}
+
+func handleReserve(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	bookID, ok := pickBook(sc, mgr, "Book ID or title: ")
+	if !ok {
+		return
+	}
+
+	sess, ok := memberSession(sc, mgr)
+	if !ok {
+		return
+	}
+	memberID := sess.MemberID()
+
+	if book, err := mgr.GetBook(bookID); err == nil && book.Available && !acknowledgeAlerts(sc, mgr, memberID) {
+		fmt.Println("Checkout cancelled.")
+		return
+	}
+
+	if err := sess.ReserveBook(bookID); err != nil {
+		fmt.Printf("Error reserving book: %v\n", err)
+		return
+	}
+
+	// Get member and book info for confirmation
+	member, _ := mgr.GetMember(memberID)
+	book, _ := mgr.GetBook(bookID)
+
+	if book.Available {
+		fmt.Printf("Book '%s' immediately checked out to %s\n", book.Title, member.Name)
+	} else {
+		fmt.Printf("Book '%s' reserved for %s\n", book.Title, member.Name)
+
+		// Show current position in queue
+		reservations, err := mgr.GetReservations(bookID)
+		if err == nil {
+			for i, reservedMember := range reservations {
+				if reservedMember.ID == memberID {
+					fmt.Printf("Position in queue: %d\n", i+1)
+					break
+				}
+			}
+		}
+	}
+}
+
+func handleListReservations(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	prompt("Book ID (or press Enter for all books): ")
+	if !sc.Scan() {
+		return
+	}
+	bookIDStr := strings.TrimSpace(sc.Text())
+
+	// If no Book ID provided, show reservations for all books
+	if bookIDStr == "" {
+		handleListAllReservations(mgr)
+		return
+	}
+
+	bookID, err := strconv.ParseInt(bookIDStr, 10, 64)
+	if err != nil {
+		fmt.Printf("Invalid book ID: %s\n", bookIDStr)
+		return
+	}
+
+	book, err := mgr.GetBook(bookID)
+	if err != nil {
+		fmt.Printf("Error: Book with ID %d not found\n", bookID)
+		return
+	}
+
+	reservations, err := mgr.GetReservations(bookID)
+	if err != nil {
+		fmt.Printf("Error retrieving reservations: %v\n", err)
+		return
+	}
+
+	if machineOutput() {
+		rows := make([][]any, len(reservations))
+		for i, member := range reservations {
+			rows[i] = []any{i + 1, member.ID, member.Name}
+		}
+		writeRecords([]string{"position", "member_id", "name"}, rows)
+		return
+	}
+
+	fmt.Printf("Reservations for '%s' by %s:\n", book.Title, book.Author)
+
+	if len(reservations) == 0 {
+		fmt.Println("No reservations for this book.")
+		return
+	}
+
+	fmt.Printf("%-10s %-5s %-30s\n", "Position", "ID", "Name")
+	fmt.Println(strings.Repeat("-", 50))
+
+	for i, member := range reservations {
+		fmt.Printf("%-10d %-5d %-30s\n", i+1, member.ID, member.Name)
+	}
+}
+
+func handleListAllReservations(mgr *library.LibraryManager) {
+	books, err := mgr.GetAllBookSummaries()
+	if err != nil {
+		fmt.Printf("Error retrieving books: %v\n", err)
+		return
+	}
+
+	if len(books) == 0 && !machineOutput() {
+		fmt.Println("No books in the library.")
+		return
+	}
+
+	queues, err := mgr.GetReservationCounts()
+	if err != nil {
+		fmt.Printf("Error retrieving reservations: %v\n", err)
+		return
+	}
+	if machineOutput() {
+		writeBookRecords(mgr, books, queues)
+		return
+	}
+
+	fmt.Println("Reservation Status for All Books:")
+	fmt.Printf("%-5s %-30s %-25s %-12s %-30s %s\n", "ID", "Title", "Author", "Status", "Current Borrower", "Reservations")
+	fmt.Println(strings.Repeat("-", 130))
+
+	for _, book := range books {
+		// Get current borrower info
+		var statusInfo, borrowerInfo string
+		if book.Available {
+			statusInfo = "Available"
+			borrowerInfo = "None"
+		} else {
+			statusInfo = "Checked Out"
+			if member, err := mgr.GetMember(book.BorrowerID); err == nil {
+				borrowerInfo = fmt.Sprintf("%s (ID: %d)", member.Name, member.ID)
+			} else {
+				borrowerInfo = fmt.Sprintf("ID: %d", book.BorrowerID)
+			}
+		}
+
+		// Get reservations for this book
+		reservationInfo := "None"
+		if q, ok := queues[book.ID]; ok {
+			reservationInfo = formatQueue(q)
+		}
+
+		fmt.Printf("%-5d %-30s %-25s %-12s %-30s %s\n",
+			book.ID,
+			truncateString(book.Title, 30),
+			truncateString(book.Author, 25),
+			statusInfo,
+			truncateString(borrowerInfo, 30),
+			reservationInfo)
+	}
+
+	if len(queues) == 0 {
+		fmt.Println("\nNo active reservations in the system.")
+	} else {
+		fmt.Printf("\nTotal books: %d | Books with reservations: %d\n", len(books), len(queues))
+	}
+}
+
+// formatQueue summarises a reservation queue for book listings; use
+// 'list reservations' with a book ID for the full queue.
+func formatQueue(q *library.ReservationCount) string {
+	return fmt.Sprintf("%d waiting, next: %s (ID: %d)", q.QueueLength, q.FirstMemberName, q.FirstMemberID)
+}
+
+func handleCancelReservation(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	bookID, ok := promptBookID(sc, "Book ID: ")
+	if !ok {
+		return
+	}
+
+	sess, ok := memberSession(sc, mgr)
+	if !ok {
+		return
+	}
+	memberID := sess.MemberID()
+
+	if err := sess.CancelReservation(bookID); err != nil {
+		fmt.Printf("Error cancelling reservation: %v\n", err)
+		return
+	}
+
+	// Get member and book info for confirmation
+	member, _ := mgr.GetMember(memberID)
+	book, _ := mgr.GetBook(bookID)
+	fmt.Printf("Reservation for '%s' cancelled for %s\n", book.Title, member.Name)
+}
+
+func handleUpdateContent(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	bookID, ok := promptBookID(sc, "Book ID: ")
+	if !ok {
+		return
+	}
+
+	path, ok := promptPath(sc, "Path to text file: ", "", true)
+	if !ok {
+		return
+	}
+
+	if err := mgr.UpdateBookContentFromFile(bookID, path); err != nil {
+		fmt.Printf("Error updating book content: %v\n", err)
+		return
+	}
+
+	book, _ := mgr.GetBook(bookID)
+	fmt.Printf("Content updated for book '%s'\n", book.Title)
+}
+
+func handleReadBook(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	bookID, ok := promptBookID(sc, "Book ID: ")
+	if !ok {
+		return
+	}
+
+	sess, ok := memberSession(sc, mgr)
+	if !ok {
+		return
+	}
+
+	if err := sess.ReadBook(bookID); err != nil {
+		fmt.Printf("Error reading book: %v\n", err)
+		return
+	}
+}
+
+// handleReturnDigital ends a digital loan before it expires so the license
+// goes to the next reader.
+func handleReturnDigital(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	bookID, ok := promptBookID(sc, "Book ID: ")
+	if !ok {
+		return
+	}
+
+	sess, ok := memberSession(sc, mgr)
+	if !ok {
+		return
+	}
+
+	if err := sess.ReturnDigitalLoan(bookID); err != nil {
+		fmt.Printf("Error returning digital loan: %v\n", err)
+		return
+	}
+	fmt.Println("✓ Digital loan returned")
+}
+
+// handleEditProfile records a member's optional address and demographic
+// data. Existing values are never displayed; the data is only ever reported
+// in aggregate.
+func handleEditProfile(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	memberID, ok := promptMemberID(sc, "Member ID: ")
+	if !ok {
+		return
+	}
+
+	fmt.Println("All fields are optional and private; they are only used in aggregate reports.")
+	var p library.MemberProfile
+	prompt("Address: ")
+	if !sc.Scan() {
+		return
+	}
+	p.Address = strings.TrimSpace(sc.Text())
+	prompt("ZIP code: ")
+	if !sc.Scan() {
+		return
+	}
+	p.Zip = strings.TrimSpace(sc.Text())
+	prompt("Age group (%s): ", strings.Join(library.AgeGroups, ", "))
+	if !sc.Scan() {
+		return
+	}
+	p.AgeGroup = strings.TrimSpace(sc.Text())
+
+	if err := mgr.SetMemberProfile(memberID, p); err != nil {
+		fmt.Printf("Error saving profile: %v\n", err)
+		return
+	}
+	fmt.Printf("Profile saved for member %d\n", memberID)
+}
+
+// parseAsOf reads a report's optional `--as-of YYYY-MM-DD`, returning the
+// end of that day, or the library's current time without one, and how to
+// describe the period ending then.
+func parseAsOf(mgr *library.LibraryManager, args string) (time.Time, string, error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return mgr.Now(), "to now", nil
+	}
+	if len(fields) != 2 || fields[0] != "--as-of" {
+		return time.Time{}, "", fmt.Errorf("unexpected arguments: %s", strings.TrimSpace(args))
+	}
+	day, err := time.ParseInLocation("2006-01-02", fields[1], displayZone)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid date: %s", fields[1])
+	}
+	return day.AddDate(0, 0, 1).Add(-time.Second), "to the end of " + fields[1], nil
+}
+
+func handleServiceAreaReport(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	asOf, label, err := parseAsOf(mgr, args)
+	if err != nil {
+		printError(err)
+		fmt.Println("Usage: service area report [--as-of YYYY-MM-DD]")
+		return
+	}
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	prompt("Count circulation over the last N days (default 365): ")
+	if !sc.Scan() {
+		return
+	}
+	days := 365
+	if daysStr := strings.TrimSpace(sc.Text()); daysStr != "" {
+		var err error
+		if days, err = strconv.Atoi(daysStr); err != nil || days <= 0 {
+			fmt.Printf("Invalid number of days: %s\n", daysStr)
+			return
+		}
+	}
+
+	report, err := mgr.ServiceAreaReport(asOf.AddDate(0, 0, -days), asOf, serviceAreaMinCell)
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	fmt.Printf("Service area report (%d days %s; ZIPs with fewer than %d members grouped as 'other')\n", days, label, serviceAreaMinCell)
+	fmt.Printf("%-10s %-10s %s\n", "ZIP", "Members", "Checkouts")
+	fmt.Println(strings.Repeat("-", 35))
+	for _, a := range report {
+		fmt.Printf("%-10s %-10d %d\n", a.Zip, a.Members, a.Checkouts)
+	}
+}
+
+func handleSetTier(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	memberID, ok := promptMemberID(sc, "Member ID: ")
+	if !ok {
+		return
+	}
+
+	policies, err := mgr.GetTierPolicies()
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("%-8s %-11s %-10s %s\n", "Tier", "Loan Limit", "Loan Days", "Fine/Day")
+	for _, p := range policies {
+		fmt.Printf("%-8s %-11d %-10d %s\n", p.Name, p.LoanLimit, p.LoanDays, library.FormatCents(p.FineCentsPerDay))
+	}
+
+	prompt("New tier: ")
+	if !sc.Scan() {
+		return
+	}
+	tier := strings.ToLower(strings.TrimSpace(sc.Text()))
+
+	if err := mgr.SetMemberTier(memberID, tier); err != nil {
+		printError(err)
+		return
+	}
+	member, _ := mgr.GetMember(memberID)
+	fmt.Printf("%s (ID: %d) is now a %s member\n", member.Name, memberID, tier)
+}
+
+func handleRenewMembership(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	memberID, ok := promptMemberID(sc, "Member ID: ")
+	if !ok {
+		return
+	}
+
+	prompt("Term in days (default %d): ", library.DefaultMembershipDays)
+	if !sc.Scan() {
+		return
+	}
+	days := library.DefaultMembershipDays
+	if termStr := strings.TrimSpace(sc.Text()); termStr != "" {
+		var err error
+		if days, err = strconv.Atoi(termStr); err != nil {
+			fmt.Printf("Invalid term: %s\n", termStr)
+			return
+		}
+	}
+
+	expiry, err := mgr.RenewMembership(memberID, days)
+	if err != nil {
+		fmt.Printf("Error renewing membership: %v\n", err)
+		return
+	}
+	member, _ := mgr.GetMember(memberID)
+	fmt.Printf("Membership for %s renewed until %s\n", member.Name, displayTime(expiry).Format("2006-01-02"))
+}
+
+func handleExpiringMembers(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	prompt("Days ahead (default 30): ")
+	if !sc.Scan() {
+		return
+	}
+	days := 30
+	if daysStr := strings.TrimSpace(sc.Text()); daysStr != "" {
+		var err error
+		if days, err = strconv.Atoi(daysStr); err != nil || days < 0 {
+			fmt.Printf("Invalid number of days: %s\n", daysStr)
+			return
+		}
+	}
+
+	members, err := mgr.GetExpiringMembers(time.Duration(days) * 24 * time.Hour)
+	if err != nil {
+		printError(err)
+		return
+	}
+	if len(members) == 0 {
+		fmt.Printf("No memberships expire in the next %d days.\n", days)
+		return
+	}
+
+	now := mgr.Now()
+	fmt.Printf("%-5s %-30s %-12s\n", "ID", "Name", "Expires")
+	fmt.Println(strings.Repeat("-", 50))
+	for _, m := range members {
+		status := ""
+		if m.Expired(now) {
+			status = " (expired)"
+		}
+		fmt.Printf("%-5d %-30s %s%s\n", m.ID, truncateString(m.Name, 30), displayTime(*m.ExpiryTime).Format("2006-01-02"), status)
+	}
+}
+
+func handleGrantAdmin(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	admins, err := mgr.CountAdmins()
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	// The very first staff account can be created without authentication;
+	// after that only existing staff may grant rights.
+	if admins > 0 {
+		if _, err := authenticateStaff(sc, mgr); err != nil {
+			fmt.Printf("Authentication failed: %v\n", err)
+			return
+		}
+	} else {
+		fmt.Println("No staff accounts exist yet; the member below will become the first administrator.")
+	}
+
+	memberID, ok := promptMemberID(sc, "Member ID to grant staff rights: ")
+	if !ok {
+		return
+	}
+
+	if err := mgr.SetMemberAdmin(memberID, true); err != nil {
+		printError(err)
+		return
+	}
+	member, _ := mgr.GetMember(memberID)
+	fmt.Printf("%s (ID: %d) now has staff rights\n", member.Name, memberID)
+}
+
+// handleRevokeTokens signs a member out of every API client, e.g. after a
+// lost phone.
+func handleRevokeTokens(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	memberID, ok := promptMemberID(sc, "Member ID: ")
+	if !ok {
+		return
+	}
+
+	n, err := mgr.RevokeTokens(memberID)
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("✓ Revoked %d API token(s) for member %d\n", n, memberID)
+}
+
+// handleForceReset runs `security force-reset --all` or
+// `security force-reset --since YYYY-MM-DD` after a security incident.
+func handleForceReset(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	var since *time.Time
+	switch fields := strings.Fields(args); {
+	case len(fields) == 1 && fields[0] == "--all":
+	case len(fields) == 2 && fields[0] == "--since":
+		t, err := time.ParseInLocation("2006-01-02", fields[1], displayZone)
+		if err != nil {
+			fmt.Printf("Invalid date: %s\n", fields[1])
+			return
+		}
+		since = &t
+	default:
+		fmt.Println("Usage: security force-reset --all | --since YYYY-MM-DD")
+		return
+	}
+
+	staffID, err := authenticateStaff(sc, mgr)
+	if err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	scope := "EVERY account"
+	if since != nil {
+		scope = "every account signed in since " + displayTime(*since).Format("2006-01-02")
+	}
+	prompt("This expires the password of %s and revokes its API tokens. Continue? (y/n): ", scope)
+	if !sc.Scan() || strings.ToLower(strings.TrimSpace(sc.Text())) != "y" {
+		fmt.Println("Cancelled.")
+		return
+	}
+
+	n, err := mgr.ForcePasswordReset(staffID, since)
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("✓ %d account(s) must choose a new password at their next sign-in\n", n)
+}
+
+func handleAuditVerify(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	n, err := mgr.VerifyAuditLog()
+	if err != nil {
+		fmt.Printf("✗ Audit log check failed after %d good entries: %v\n", n, err)
+		return
+	}
+	fmt.Printf("✓ Audit log intact: %d entries, hash chain unbroken\n", n)
+}
+
+// parseAuditFilter reads the options of `view audit`. Both dates are whole
+// days in the display zone, --until included.
+func parseAuditFilter(args string) (library.AuditFilter, error) {
+	var f library.AuditFilter
+	fields := strings.Fields(args)
+	if len(fields)%2 != 0 {
+		return f, fmt.Errorf("every option needs a value")
+	}
+	for i := 0; i < len(fields); i += 2 {
+		flag, value := fields[i], fields[i+1]
+		switch flag {
+		case "--member", "--book":
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || id <= 0 {
+				return f, fmt.Errorf("invalid %s ID: %s", strings.TrimPrefix(flag, "--"), value)
+			}
+			if flag == "--member" {
+				f.MemberID = id
+			} else {
+				f.BookID = id
+			}
+		case "--since", "--until":
+			day, err := time.ParseInLocation("2006-01-02", value, displayZone)
+			if err != nil {
+				return f, fmt.Errorf("invalid date: %s", value)
+			}
+			if flag == "--since" {
+				f.Since = day
+			} else {
+				f.Until = day.AddDate(0, 0, 1)
+			}
+		default:
+			return f, fmt.Errorf("unknown option: %s", flag)
+		}
+	}
+	if !f.Since.IsZero() && !f.Until.IsZero() && !f.Since.Before(f.Until) {
+		return f, fmt.Errorf("--since must not be after --until")
+	}
+	return f, nil
+}
+
+// handleViewAudit runs `view audit`, listing the audit log newest first.
+func handleViewAudit(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	f, err := parseAuditFilter(args)
+	if err != nil {
+		printError(err)
+		fmt.Println("Usage: view audit [--member <id>] [--book <id>] [--since YYYY-MM-DD] [--until YYYY-MM-DD]")
+		return
+	}
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	entries, err := mgr.SearchAuditLog(f)
+	if err != nil {
+		fmt.Printf("Error reading the audit log: %v\n", err)
+		return
+	}
+	if machineOutput() {
+		rows := make([][]any, len(entries))
+		for i, e := range entries {
+			rows[i] = []any{e.ID, displayTime(e.CreatedTime).Format(time.RFC3339), e.ActorID, e.Action, e.MemberID, e.BookID, e.Detail}
+		}
+		writeRecords([]string{"id", "time", "actor_id", "action", "member_id", "book_id", "detail"}, rows)
+		return
+	}
+	if len(entries) == 0 {
+		fmt.Println("No audit entries match.")
+		return
+	}
+
+	fmt.Printf("%-6s %-16s %-8s %-20s %s\n", "ID", "When", "By", "Action", "Detail")
+	pageThrough(sc, len(entries), func(offset, limit int) error {
+		for _, e := range entries[offset:min(offset+limit, len(entries))] {
+			actor := "-"
+			if e.ActorID != 0 {
+				actor = strconv.FormatInt(e.ActorID, 10)
+			}
+			fmt.Printf("%-6d %-16s %-8s %-20s %s\n", e.ID, displayTime(e.CreatedTime).Format("2006-01-02 15:04"), actor, e.Action, e.Detail)
+		}
+		return nil
+	})
+}
+
+// handleCheckIn runs the return desk queue: a staff member authenticates once
+// and then scans book IDs until a blank line or "done".
+func handleCheckIn(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	fmt.Println("Check-in mode: enter one book ID per line. Blank line or 'done' to finish.")
+	checkedIn, holds, failed := 0, 0, 0
+	for {
+		prompt("Book ID: ")
+		if !sc.Scan() {
+			break
+		}
+		input := strings.TrimSpace(sc.Text())
+		if input == "" || strings.EqualFold(input, "done") {
+			break
+		}
+
+		bookID, err := strconv.ParseInt(input, 10, 64)
+		if err != nil {
+			fmt.Printf("  ✗ Invalid book ID: %s\n", input)
+			failed++
+			continue
+		}
+
+		missing, ok := verifyAccessories(sc, mgr, bookID)
+		if !ok {
+			failed++
+			continue
+		}
+
+		res, err := mgr.CheckInItem(bookID, missing)
+		if err != nil {
+			fmt.Printf("  ✗ Book %d: %v\n", bookID, err)
+			failed++
+			continue
+		}
+		checkedIn++
+
+		if printCheckIn(res) {
+			holds++
+		}
+	}
+
+	fmt.Printf("Checked in %d book(s): %d to hold shelf, %d errors\n", checkedIn, holds, failed)
+}
+
+// printCheckIn shows where a checked-in book goes next and what to do about
+// its deposit, and reports whether it goes to the hold shelf.
+func printCheckIn(res *library.CheckInResult) bool {
+	fmt.Printf("  ✓ '%s' checked in (borrowed by %s, ID: %d)\n", res.Title, res.ReturnedByName, res.ReturnedBy)
+	if res.HoldFor > 0 {
+		fmt.Printf("    → HOLD: route to hold shelf for %s (ID: %d)\n", res.HoldForName, res.HoldFor)
+	} else {
+		fmt.Println("    → Reshelve")
+	}
+	if len(res.MissingAccessories) > 0 {
+		fmt.Printf("    ⚠ Missing: %s\n", strings.Join(res.MissingAccessories, ", "))
+	}
+	switch res.DepositStatus {
+	case library.DepositRefunded:
+		fmt.Printf("    → Refund deposit of %s\n", library.FormatCents(res.DepositCents))
+	case library.DepositForfeited:
+		fmt.Printf("    → Deposit of %s forfeited\n", library.FormatCents(res.DepositCents))
+	}
+	return res.HoldFor > 0
+}
+
+// handleReturnAll returns everything a member has out (`return all
+// [--member <id>]`), for the patron emptying their bag at the desk. Whoever
+// signs in acts: the member, one of their delegates, or staff, who also go
+// through the accessory checklist of any equipment.
+func handleReturnAll(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	fields := strings.Fields(args)
+	var memberID int64
+	switch {
+	case len(fields) == 0:
+	case len(fields) == 2 && fields[0] == "--member":
+		id, err := parseID(fields[1], "member")
+		if err != nil {
+			printError(err)
+			return
+		}
+		memberID = id
+		rememberID("member", id)
+	default:
+		fmt.Println("Usage: return all [--member <id>]")
+		return
+	}
+
+	sess := session
+	if sess != nil && sess.Touch() != nil {
+		fmt.Println("Your session has expired; logged out.")
+		session, sess = nil, nil
+	}
+	if sess == nil {
+		signerID, ok := promptMemberID(sc, "Your member ID (member, delegate or staff): ")
+		if !ok {
+			return
+		}
+		var err error
+		if sess, err = signIn(sc, mgr, signerID); err != nil {
+			fmt.Printf("Authentication failed: %v\n", err)
+			return
+		}
+	}
+	if memberID == 0 {
+		memberID = sess.MemberID()
+	}
+
+	atDesk := false
+	if memberID != sess.MemberID() {
+		if signer, err := mgr.GetMember(sess.MemberID()); err == nil && signer.IsAdmin {
+			atDesk = true
+		}
+	}
+	var res *library.ReturnAllResult
+	var err error
+	if atDesk {
+		res, err = mgr.CheckInAll(memberID)
+	} else {
+		res, err = sess.ReturnAll(memberID)
+	}
+	if err != nil {
+		printError(err)
+		return
+	}
+	if len(res.Returned) == 0 && len(res.AtDesk) == 0 {
+		fmt.Println("Nothing to return.")
+		return
+	}
+
+	holds := 0
+	for _, r := range res.Returned {
+		if printCheckIn(r) {
+			holds++
+		}
+	}
+	returned := len(res.Returned)
+	for _, bookID := range res.AtDesk {
+		if !atDesk {
+			fmt.Printf("  • Item %d has accessories; bring it to the desk to be checked in\n", bookID)
+			continue
+		}
+		missing, ok := verifyAccessories(sc, mgr, bookID)
+		if !ok {
+			continue
+		}
+		r, err := mgr.CheckInItem(bookID, missing)
+		if err != nil {
+			fmt.Printf("  ✗ Item %d: %v\n", bookID, err)
+			continue
+		}
+		returned++
+		if printCheckIn(r) {
+			holds++
+		}
+	}
+	fmt.Printf("Returned %d item(s): %d to hold shelf\n", returned, holds)
+}
+
+// verifyAccessories walks staff through bookID's accessory checklist and
+// returns the accessories that did not come back. ok is false if the
+// checklist could not be completed.
+func verifyAccessories(sc *bufio.Scanner, mgr *library.LibraryManager, bookID int64) (missing []string, ok bool) {
+	accessories, err := mgr.GetAccessories(bookID)
+	if err != nil {
+		fmt.Printf("  ✗ Book %d: %v\n", bookID, err)
+		return nil, false
+	}
+	for _, a := range accessories {
+		prompt("    Returned with %s? (y/n): ", a)
+		if !sc.Scan() {
+			return nil, false
+		}
+		if !strings.EqualFold(strings.TrimSpace(sc.Text()), "y") {
+			missing = append(missing, a)
+		}
+	}
+	return missing, true
+}
+
+// handleHolds passes on the holds whose pickup window has closed, then
+// lists the books waiting on the hold shelf and until when.
+func handleHolds(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	expired, err := mgr.ExpireHolds()
+	if err != nil {
+		fmt.Printf("Error expiring holds: %v\n", err)
+		return
+	}
+	if expired > 0 {
+		fmt.Printf("%d hold(s) were not collected in time and have been passed on.\n", expired)
+	}
+
+	holds, err := mgr.GetHolds()
+	if err != nil {
+		fmt.Printf("Error retrieving holds: %v\n", err)
+		return
+	}
+	if len(holds) == 0 {
+		fmt.Println("No holds are waiting for pickup.")
+		return
+	}
+	fmt.Printf("%-5s %-30s %-25s %s\n", "ID", "Title", "Held for", "Pick up by")
+	fmt.Println(strings.Repeat("-", 80))
+	for _, h := range holds {
+		until := ""
+		if h.HoldExpiryTime != nil {
+			until = displayTime(*h.HoldExpiryTime).Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%-5d %-30s %-25s %s\n", h.BookID, truncateString(h.BookTitle, 30),
+			truncateString(fmt.Sprintf("%s (ID: %d)", h.MemberName, h.MemberID), 25), until)
+	}
+}
+
+// handleFulfillHold hands a book on the hold shelf to the member it is held
+// for, starting their loan (`fulfill hold <book id> <member id>`).
+func handleFulfillHold(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	bookIDStr, memberIDStr := splitArgs(args)
+
+	staffID, err := authenticateStaff(sc, mgr)
+	if err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	bookID, ok := argOrPromptID(sc, bookIDStr, "Book ID: ", "book")
+	if !ok {
+		return
+	}
+	memberID, ok := argOrPromptID(sc, memberIDStr, "Member ID: ", "member")
+	if !ok {
+		return
+	}
+
+	loan, err := mgr.FulfillHold(bookID, memberID, staffID)
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("✓ '%s' checked out to %s, due %s\n", loan.BookTitle, loan.MemberName, displayTime(loan.DueTime).Format("2006-01-02"))
+}
+
+// handleHistory shows the signed-in member's checkouts, past and present.
+func handleHistory(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	sess, ok := memberSession(sc, mgr)
+	if !ok {
+		return
+	}
+	history, err := sess.GetHistory()
+	if err != nil {
+		fmt.Printf("Error retrieving history: %v\n", err)
+		return
+	}
+	if len(history) == 0 {
+		fmt.Println("You have not borrowed anything yet.")
+		return
+	}
+	printHistory(history, false)
+}
+
+// handleScopedHistory shows who borrowed what and when for one member or
+// one book: "member <id>" or "book <id>".
+func handleScopedHistory(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	scope, idStr := splitArgs(args)
+
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	if idStr == "" {
+		prompt("%s ID: ", strings.ToUpper(scope[:1])+scope[1:])
+		if !sc.Scan() {
+			return
+		}
+		idStr = strings.TrimSpace(sc.Text())
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		fmt.Printf("Invalid %s ID: %s\n", scope, idStr)
+		return
+	}
+
+	var history []*library.Loan
+	if scope == "member" {
+		member, err := mgr.GetMember(id)
+		if err != nil {
+			fmt.Printf("Error: Member with ID %d not found\n", id)
+			return
+		}
+		if history, err = mgr.GetMemberHistory(id); err != nil {
+			fmt.Printf("Error retrieving history: %v\n", err)
+			return
+		}
+		if len(history) == 0 {
+			fmt.Printf("%s has not borrowed anything yet.\n", member.Name)
+			return
+		}
+		fmt.Printf("Checkout history of %s:\n", member.Name)
+		printHistory(history, false)
+		return
+	}
+
+	book, err := mgr.GetBook(id)
+	if err != nil {
+		fmt.Printf("Error: Book with ID %d not found\n", id)
+		return
+	}
+	if history, err = mgr.GetBookHistory(id); err != nil {
+		fmt.Printf("Error retrieving history: %v\n", err)
+		return
+	}
+	if len(history) == 0 {
+		fmt.Printf("'%s' has never been checked out.\n", book.Title)
+		return
+	}
+	fmt.Printf("Checkout history of '%s':\n", book.Title)
+	printHistory(history, true)
+}
+
+// printHistory lists checkouts, naming the borrower when byMember is set
+// and the book otherwise.
+func printHistory(history []*library.Loan, byMember bool) {
+	heading := "Title"
+	if byMember {
+		heading = "Member"
+	}
+	fmt.Printf("%-5s %-30s %-17s %-17s\n", "ID", heading, "Checked out", "Returned/status")
+	fmt.Println(strings.Repeat("-", 72))
+	for _, l := range history {
+		id, name := l.BookID, l.BookTitle
+		if byMember {
+			id, name = l.MemberID, l.MemberName
+		}
+		returned := l.Status
+		if l.Status == library.LoanHoldExpired {
+			returned = "hold expired"
+		} else if l.ReturnTime != nil {
+			returned = displayTime(*l.ReturnTime).Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%-5d %-30s %-17s %-17s\n", id, truncateString(name, 30),
+			displayTime(l.CheckoutTime).Format("2006-01-02 15:04"), returned)
+	}
+}
+
+func handleLoans(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	sess, ok := memberSession(sc, mgr)
+	if !ok {
+		return
+	}
+	memberID := sess.MemberID()
+
+	loans, err := sess.GetOpenLoans()
+	if err != nil {
+		fmt.Printf("Error retrieving loans: %v\n", err)
+		return
+	}
+	digital, err := mgr.GetDigitalLoans(memberID)
+	if err != nil {
+		fmt.Printf("Error retrieving digital loans: %v\n", err)
+		return
+	}
+	if len(loans) == 0 && len(digital) == 0 {
+		fmt.Println("You have no books checked out.")
+		return
+	}
+
+	if len(loans) > 0 {
+		now := mgr.Now()
+		fmt.Printf("%-5s %-30s %-17s %-16s %s\n", "ID", "Title", "Due", "Status", "Fine")
+		fmt.Println(strings.Repeat("-", 80))
+		for _, l := range loans {
+			// A hold is due to be picked up rather than returned
+			due := l.DueTime
+			if l.HoldExpiryTime != nil {
+				due = *l.HoldExpiryTime
+			}
+			fmt.Printf("%-5d %-30s %-17s %-16s %s\n",
+				l.BookID,
+				truncateString(l.BookTitle, 30),
+				displayTime(due).Format("2006-01-02 15:04"),
+				l.Status,
+				library.FormatCents(l.AccruedFineCents(now)))
+		}
+	}
+
+	if len(digital) > 0 {
+		fmt.Println("\nDigital loans:")
+		fmt.Printf("%-5s %-30s %s\n", "ID", "Title", "Expires")
+		fmt.Println(strings.Repeat("-", 55))
+		for _, l := range digital {
+			fmt.Printf("%-5d %-30s %s\n", l.BookID, truncateString(l.BookTitle, 30), displayTime(l.ExpiryTime).Format("2006-01-02 15:04"))
+		}
+	}
+}
+
+func handleFines(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	sess, ok := memberSession(sc, mgr)
+	if !ok {
+		return
+	}
+	memberID := sess.MemberID()
+
+	fines, err := sess.GetMemberFines()
+	if err != nil {
+		fmt.Printf("Error retrieving fines: %v\n", err)
+		return
+	}
+	if len(fines) == 0 {
+		if !capabilityEnabled(mgr, library.CapFines) {
+			fmt.Println("This library does not charge overdue fines.")
+			return
+		}
+		fmt.Println("No charges on your account.")
+		return
+	}
+
+	fmt.Printf("%-12s %-10s %-50s %s\n", "Date", "Amount", "Description", "Status")
+	fmt.Println(strings.Repeat("-", 85))
+	for _, f := range fines {
+		status := "Open"
+		if f.ReversedTime != nil {
+			status = "Reversed"
+		}
+		fmt.Printf("%-12s %-10s %-50s %s\n", displayTime(f.CreatedTime).Format("2006-01-02"),
+			library.FormatCents(f.AmountCents), truncateString(f.Description, 50), status)
+	}
+
+	balance, err := mgr.GetFineBalance(memberID)
+	if err == nil {
+		fmt.Printf("\nBalance owed: %s\n", library.FormatCents(balance))
+	}
+}
+
+func handleMarkLost(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	bookID, ok := promptBookID(sc, "Book ID: ")
+	if !ok {
+		return
+	}
+
+	if err := mgr.MarkLost(bookID); err != nil {
+		printError(err)
+		return
+	}
+	cost, _ := mgr.GetReplacementCost(bookID)
+	fmt.Printf("Book %d marked as lost; replacement charge of %s added to the borrower's account\n",
+		bookID, library.FormatCents(cost))
+}
+
+func handleSetPrice(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	bookID, ok := promptBookID(sc, "Book ID: ")
+	if !ok {
+		return
+	}
+
+	prompt("Replacement price (e.g. 24.99, blank for default): ")
+	if !sc.Scan() {
+		return
+	}
+	priceStr := strings.TrimSpace(sc.Text())
+	cents := int64(-1)
+	if priceStr != "" {
+		var err error
+		if cents, err = library.ParseCents(priceStr); err != nil {
+			printError(err)
+			return
+		}
+	}
+
+	if err := mgr.SetReplacementCost(bookID, cents); err != nil {
+		fmt.Printf("Error setting price: %v\n", err)
+		return
+	}
+	cost, _ := mgr.GetReplacementCost(bookID)
+	fmt.Printf("Replacement price for book %d is now %s\n", bookID, library.FormatCents(cost))
+}
+
+func handleAddItem(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	prompt("Name: ")
+	if !sc.Scan() {
+		return
+	}
+	title := strings.TrimSpace(sc.Text())
+	if title == "" {
+		fmt.Println("Name cannot be empty")
+		return
+	}
+	prompt("Make/model: ")
+	if !sc.Scan() {
+		return
+	}
+	maker := strings.TrimSpace(sc.Text())
+
+	var names []string
+	if types, err := mgr.GetItemTypes(); err == nil {
+		for _, it := range types {
+			names = append(names, it.Name)
+		}
+	}
+	prompt("Item type (%s): ", strings.Join(names, ", "))
+	if !sc.Scan() {
+		return
+	}
+	itemType := strings.ToLower(strings.TrimSpace(sc.Text()))
+
+	prompt("Accessories, comma separated (blank for none): ")
+	if !sc.Scan() {
+		return
+	}
+	var accessories []string
+	if list := strings.TrimSpace(sc.Text()); list != "" {
+		accessories = strings.Split(list, ",")
+	}
+
+	id, err := mgr.AddItem(title, maker, itemType, accessories)
+	if err != nil {
+		fmt.Printf("Error adding item: %v\n", err)
+		return
+	}
+	fmt.Printf("Item added with ID %d\n", id)
+}
+
+func handleItemTypes(mgr *library.LibraryManager) {
+	types, err := mgr.GetItemTypes()
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	fmt.Printf("%-12s %-10s %-12s %s\n", "Type", "Loan Days", "Fine/Day", "Deposit")
+	fmt.Println(strings.Repeat("-", 48))
+	for _, it := range types {
+		loanDays, fine := "by tier", "by tier"
+		if it.LoanDays > 0 {
+			loanDays = strconv.Itoa(it.LoanDays)
+		}
+		if it.FineCentsPerDay > 0 {
+			fine = library.FormatCents(it.FineCentsPerDay)
+		}
+		fmt.Printf("%-12s %-10s %-12s %s\n", it.Name, loanDays, fine, library.FormatCents(it.DepositCents))
+	}
+}
+
+func handleSetReference(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	bookID, ok := promptBookID(sc, "Book ID: ")
+	if !ok {
+		return
+	}
+
+	prompt("In-library use only? (y/n): ")
+	if !sc.Scan() {
+		return
+	}
+	reference := strings.EqualFold(strings.TrimSpace(sc.Text()), "y")
+
+	if err := mgr.SetNonCirculating(bookID, reference); err != nil {
+		printError(err)
+		return
+	}
+	if reference {
+		fmt.Printf("Book %d is now a reference item (in-library use only)\n", bookID)
+	} else {
+		fmt.Printf("Book %d now circulates normally\n", bookID)
+	}
+}
+
+func handleSetLicenses(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
+
+	bookID, ok := promptBookID(sc, "Book ID: ")
+	if !ok {
+		return
+	}
+
+	prompt("Simultaneous digital reads (0 for print only): ")
+	if !sc.Scan() {
+		return
+	}
+	licensesStr := strings.TrimSpace(sc.Text())
+	licenses, err := strconv.Atoi(licensesStr)
 	if err != nil {
-		return "", err
+		fmt.Printf("Invalid number: %s\n", licensesStr)
+		return
 	}
-	fmt.Println() // Add newline after password input
-	return strings.TrimSpace(string(bytePassword)), nil
+
+	if err := mgr.SetDigitalLicenses(bookID, licenses); err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("Book %d now has %d digital license(s)\n", bookID, licenses)
 }
 
-// authenticateUser prompts for and verifies user credentials
-func authenticateUser(sc *bufio.Scanner, mgr *library.LibraryManager, memberID int64) error {
-	password, err := readPassword("Enter your password: ")
+// handleInLibraryUse records a consultation of a book that was used in the
+// building without being checked out, e.g. when reshelving reference items.
+func handleInLibraryUse(args string, mgr *library.LibraryManager) {
+	bookIDStr := strings.TrimSpace(args)
+	if bookIDStr == "" {
+		fmt.Println("Usage: in-library use <bookID>")
+		return
+	}
+	bookID, err := strconv.ParseInt(bookIDStr, 10, 64)
 	if err != nil {
-		return fmt.Errorf("failed to read password: %w", err)
+		fmt.Printf("Invalid book ID: %s\n", bookIDStr)
+		return
+	}
+	if err := mgr.RecordInLibraryUse(bookID); err != nil {
+		printError(err)
+		return
 	}
+	fmt.Printf("✓ Recorded in-library use of book %d\n", bookID)
+}
 
-	if err := mgr.AuthenticateMember(memberID, password); err != nil {
-		return err
+func handleUsageStats(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	asOf, label, err := parseAsOf(mgr, args)
+	if err != nil {
+		printError(err)
+		fmt.Println("Usage: usage stats [--as-of YYYY-MM-DD]")
+		return
+	}
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
 	}
 
-	return nil
-}
+	prompt("Report on the last N days (default 30): ")
+	if !sc.Scan() {
+		return
+	}
+	days := 30
+	if daysStr := strings.TrimSpace(sc.Text()); daysStr != "" {
+		var err error
+		if days, err = strconv.Atoi(daysStr); err != nil || days <= 0 {
+			fmt.Printf("Invalid number of days: %s\n", daysStr)
+			return
+		}
+	}
 
-func main() {
-	manager, err := library.NewLibraryManager(dbFile)
+	stats, err := mgr.GetUsageStats(asOf.AddDate(0, 0, -days), asOf, 10)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
-		os.Exit(1)
+		printError(err)
+		return
 	}
-	defer manager.Close()
 
-	scanner := bufio.NewScanner(os.Stdin)
+	fmt.Printf("Usage over the %d days %s\n", days, label)
+	fmt.Printf("  Checkouts:       %d\n", stats.Checkouts)
+	fmt.Printf("  In-library uses: %d\n", stats.InLibraryUses)
+	if len(stats.TopInLibrary) == 0 {
+		return
+	}
+	fmt.Println("\nMost consulted in the library:")
+	fmt.Printf("%-5s %-40s %s\n", "ID", "Title", "Uses")
+	fmt.Println(strings.Repeat("-", 55))
+	for _, u := range stats.TopInLibrary {
+		fmt.Printf("%-5d %-40s %d\n", u.BookID, truncateString(u.Title, 40), u.Uses)
+	}
+}
 
-	fmt.Println("Welcome to the Library Management System with Secure Authentication!")
-	fmt.Println("Available commands:")
-	fmt.Println("  Books: add book, list books, search book, update content")
-	fmt.Println("  Members: add member, list members, reset password")
-	fmt.Println("  Circulation: checkout, return, reserve, list reservations, cancel reservation")
-	fmt.Println("  Reading: read book")
-	fmt.Println("  System: exit")
-	fmt.Println()
-	fmt.Println("Tips:")
-	fmt.Println("  • For 'list reservations': Enter a Book ID for specific book, or press Enter to see all books")
+func handleClaimsReturned(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
+	}
 
-	for {
-		fmt.Print("\n> ")
-		if !scanner.Scan() {
-			break
-		}
-		cmd := strings.TrimSpace(scanner.Text())
+	bookID, ok := promptBookID(sc, "Book ID: ")
+	if !ok {
+		return
+	}
 
-		switch cmd {
-		case "add book":
-			handleAddBook(scanner, manager)
-		case "add member":
-			handleAddMember(scanner, manager)
-		case "list books":
-			handleListBooks(manager)
-		case "list members":
-			handleListMembers(manager)
-		case "search book":
-			handleSearchBooks(scanner, manager)
-		case "checkout":
-			handleCheckout(scanner, manager)
-		case "return":
-			handleReturn(scanner, manager)
-		case "reserve":
-			handleReserve(scanner, manager)
-		case "list reservations":
-			handleListReservations(scanner, manager)
-		case "cancel reservation":
-			handleCancelReservation(scanner, manager)
-		case "update content":
-			handleUpdateContent(scanner, manager)
-		case "read book":
-			handleReadBook(scanner, manager)
-		case "reset password":
-			handleResetPassword(scanner, manager)
-		case "exit":
-			fmt.Println("Goodbye!")
-			return
-		default:
-			fmt.Println("Unknown command. Type one of the available commands listed above.")
-		}
+	if err := mgr.MarkClaimsReturned(bookID); err != nil {
+		printError(err)
+		return
 	}
+	book, _ := mgr.GetBook(bookID)
+	fmt.Printf("Loan of '%s' marked claims returned. Fines suspended; copy flagged for shelf search.\n", book.Title)
 }
 
-func handleAddBook(sc *bufio.Scanner, mgr *library.LibraryManager) {
-	fmt.Print("Title: ")
-	if !sc.Scan() {
+func handleResolveClaim(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
 		return
 	}
-	title := strings.TrimSpace(sc.Text())
 
-	fmt.Print("Author: ")
-	if !sc.Scan() {
+	bookID, ok := promptBookID(sc, "Book ID: ")
+	if !ok {
 		return
 	}
-	author := strings.TrimSpace(sc.Text())
 
-	fmt.Print("Path to text file (optional): ")
+	prompt("Was the copy found? (found/lost): ")
 	if !sc.Scan() {
 		return
 	}
-	path := strings.TrimSpace(sc.Text())
+	var found bool
+	switch strings.ToLower(strings.TrimSpace(sc.Text())) {
+	case "found", "f", "returned":
+		found = true
+	case "lost", "l":
+		found = false
+	default:
+		fmt.Println("Please answer 'found' or 'lost'.")
+		return
+	}
 
-	var (
-		id  int64
-		err error
-	)
+	res, err := mgr.ResolveClaim(bookID, found)
+	if err != nil {
+		fmt.Printf("Error resolving claim: %v\n", err)
+		return
+	}
+	if !found {
+		cost, _ := mgr.GetReplacementCost(bookID)
+		fmt.Printf("Book %d marked as lost; replacement charge of %s added to the borrower's account\n",
+			bookID, library.FormatCents(cost))
+		return
+	}
+	fmt.Printf("'%s' found and checked in\n", res.Title)
+	if res.HoldFor > 0 {
+		fmt.Printf("→ HOLD: route to hold shelf for %s (ID: %d)\n", res.HoldForName, res.HoldFor)
+	}
+}
 
-	if path == "" {
-		// No content yet
-		id, err = mgr.AddBook(title, author)
-	} else {
-		if _, errStat := os.Stat(filepath.Clean(path)); errStat != nil {
-			fmt.Printf("File error: %v. Adding book without content.\n", errStat)
-			id, err = mgr.AddBook(title, author)
-		} else {
-			id, err = mgr.AddBookFromFile(title, author, path)
-		}
+func handleShelfSearch(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return
 	}
 
+	loans, err := mgr.GetShelfSearchList()
 	if err != nil {
-		fmt.Printf("Error adding book: %v\n", err)
-	} else {
-		if path == "" {
-			fmt.Printf("Added book ID %d (no content). Use 'update content' later.\n", id)
-		} else {
-			fmt.Printf("Added book ID %d with content.\n", id)
+		printError(err)
+		return
+	}
+	if len(loans) == 0 {
+		fmt.Println("No copies flagged for shelf search.")
+		return
+	}
+
+	fmt.Printf("%-5s %-30s %-25s %s\n", "ID", "Title", "Patron", "Claimed")
+	fmt.Println(strings.Repeat("-", 80))
+	for _, l := range loans {
+		claimed := ""
+		if l.ClaimTime != nil {
+			claimed = displayTime(*l.ClaimTime).Format("2006-01-02")
 		}
+		fmt.Printf("%-5d %-30s %-25s %s\n", l.BookID, truncateString(l.BookTitle, 30),
+			truncateString(fmt.Sprintf("%s (ID: %d)", l.MemberName, l.MemberID), 25), claimed)
 	}
 }
 
-func handleAddMember(sc *bufio.Scanner, mgr *library.LibraryManager) {
-	fmt.Print("Name: ")
-	if !sc.Scan() {
+// handleAddMemberNote implements `note add member <id> "text"`; missing
+// arguments are prompted for.
+func handleAddMemberNote(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	memberIDStr, note := splitArgs(args)
+
+	staffID, err := authenticateStaff(sc, mgr)
+	if err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
 		return
 	}
-	name := strings.TrimSpace(sc.Text())
 
-	password, err := readPassword(fmt.Sprintf("Enter password for %s: ", name))
-	if err != nil {
-		fmt.Printf("Error reading password: %v\n", err)
+	memberID, ok := argOrPromptID(sc, memberIDStr, "Member ID: ", "member")
+	if !ok {
 		return
 	}
 
-	if strings.TrimSpace(password) == "" {
-		fmt.Println("Error: Password cannot be empty")
+	if note == "" {
+		prompt("Note: ")
+		if !sc.Scan() {
+			return
+		}
+		note = strings.TrimSpace(sc.Text())
+	}
+
+	if _, err := mgr.AddMemberNote(memberID, staffID, note); err != nil {
+		fmt.Printf("Error adding note: %v\n", err)
 		return
 	}
+	fmt.Printf("Note added to member %d\n", memberID)
+}
+
+func alertLabel(a *library.MemberAlert) string {
+	if a.Blocking {
+		return "[BLOCKING]"
+	}
+	return "[ADVISORY]"
+}
 
-	id, err := mgr.AddMember(name, password)
+// acknowledgeAlerts shows any alerts on the member's account and has staff
+// acknowledge each one. Blocking alerts must be cleared for the checkout to
+// proceed. It returns false if the checkout should be abandoned.
+func acknowledgeAlerts(sc *bufio.Scanner, mgr *library.LibraryManager, memberID int64) bool {
+	alerts, err := mgr.GetActiveAlerts(memberID)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-	} else {
-		fmt.Printf("Added member '%s' with ID %d\n", name, id)
+		fmt.Printf("Error checking account alerts: %v\n", err)
+		return false
+	}
+	if len(alerts) == 0 {
+		return true
+	}
+
+	fmt.Println("⚠️  This account has alerts that staff must acknowledge:")
+	for _, a := range alerts {
+		fmt.Printf("  #%d %s %s (by %s, %s)\n", a.ID, alertLabel(a), a.Message, a.AuthorName, displayTime(a.CreatedTime).Format("2006-01-02"))
+	}
+
+	staffID, err := authenticateStaff(sc, mgr)
+	if err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
+		return false
+	}
+
+	for _, a := range alerts {
+		if a.Blocking {
+			prompt("Resolve and clear blocking alert #%d? (y/n): ", a.ID)
+		} else {
+			prompt("Acknowledge advisory alert #%d? (y/n): ", a.ID)
+		}
+		if !sc.Scan() || strings.ToLower(strings.TrimSpace(sc.Text())) != "y" {
+			return false
+		}
+		if a.Blocking {
+			if err := mgr.ClearAlert(a.ID, staffID); err != nil {
+				fmt.Printf("Error clearing alert: %v\n", err)
+				return false
+			}
+		}
 	}
+	return true
 }
 
-func handleResetPassword(sc *bufio.Scanner, mgr *library.LibraryManager) {
-	fmt.Print("Member ID: ")
-	if !sc.Scan() {
+func handleAddMemberAlert(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	memberIDStr, message := splitArgs(args)
+
+	staffID, err := authenticateStaff(sc, mgr)
+	if err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
 		return
 	}
-	memberIDStr := strings.TrimSpace(sc.Text())
 
-	memberID, err := strconv.ParseInt(memberIDStr, 10, 64)
-	if err != nil {
-		fmt.Printf("Invalid member ID: %s\n", memberIDStr)
+	memberID, ok := argOrPromptID(sc, memberIDStr, "Member ID: ", "member")
+	if !ok {
 		return
 	}
 
-	// Verify member exists and get their name
-	member, err := mgr.GetMember(memberID)
-	if err != nil {
-		fmt.Printf("Error: Member with ID %d not found\n", memberID)
+	if message == "" {
+		prompt("Alert message: ")
+		if !sc.Scan() {
+			return
+		}
+		message = strings.TrimSpace(sc.Text())
+	}
+
+	prompt("Block checkouts until cleared? (y/n): ")
+	if !sc.Scan() {
 		return
 	}
+	blocking := strings.ToLower(strings.TrimSpace(sc.Text())) == "y"
 
-	newPassword, err := readPassword(fmt.Sprintf("Enter new password for %s (ID: %d): ", member.Name, memberID))
+	id, err := mgr.AddMemberAlert(memberID, staffID, message, blocking)
 	if err != nil {
-		fmt.Printf("Error reading password: %v\n", err)
+		fmt.Printf("Error adding alert: %v\n", err)
 		return
 	}
+	fmt.Printf("Alert #%d added to member %d\n", id, memberID)
+}
 
-	if strings.TrimSpace(newPassword) == "" {
-		fmt.Println("Error: Password cannot be empty")
+func handleClearAlert(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	staffID, err := authenticateStaff(sc, mgr)
+	if err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
 		return
 	}
 
-	if err := mgr.ResetMemberPassword(memberID, newPassword); err != nil {
-		fmt.Printf("Error resetting password: %v\n", err)
+	alertID, ok := promptID(sc, "Alert ID: ", "alert")
+	if !ok {
 		return
 	}
 
-	fmt.Printf("Password successfully reset for %s (ID: %d)\n", member.Name, memberID)
+	if err := mgr.ClearAlert(alertID, staffID); err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("Alert #%d cleared\n", alertID)
 }
 
-func handleListBooks(mgr *library.LibraryManager) {
-	books, err := mgr.GetAllBooks()
+// handleMemberStatus deactivates, reactivates or deletes a member
+// (`<action> member <id>`), after staff sign in and confirm.
+func handleMemberStatus(sc *bufio.Scanner, mgr *library.LibraryManager, action, args string) {
+	memberIDStr, _ := splitArgs(args)
+
+	staffID, err := authenticateStaff(sc, mgr)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		fmt.Printf("Authentication failed: %v\n", err)
 		return
 	}
-	if len(books) == 0 {
-		fmt.Println("No books in library.")
+
+	memberID, ok := argOrPromptID(sc, memberIDStr, "Member ID: ", "member")
+	if !ok {
+		return
+	}
+	member, err := mgr.GetMember(memberID)
+	if err != nil {
+		fmt.Printf("Error: Member with ID %d not found\n", memberID)
 		return
 	}
 
-	fmt.Printf("%-5s %-30s %-25s %-10s %-20s %s\n", "ID", "Title", "Author", "Available", "Borrower", "Reservation Queue")
-	fmt.Println(strings.Repeat("-", 120))
-
-	for _, b := range books {
-		// Get borrower information
-		var borrowerInfo string
-		if b.Available {
-			borrowerInfo = "None"
-		} else {
-			if member, err := mgr.GetMember(b.BorrowerID); err == nil {
-				borrowerInfo = fmt.Sprintf("%s (ID: %d)", member.Name, member.ID)
-			} else {
-				borrowerInfo = fmt.Sprintf("ID: %d", b.BorrowerID)
-			}
+	if action != "reactivate" {
+		prompt("%s %s (ID: %d)? (y/n): ", strings.ToUpper(action[:1])+action[1:], member.Name, memberID)
+		if !sc.Scan() || !strings.EqualFold(strings.TrimSpace(sc.Text()), "y") {
+			fmt.Println("Cancelled.")
+			return
 		}
+	}
 
-		// Get reservation queue
-		reservations, err := mgr.GetReservations(b.ID)
-		var queueInfo string
-		if err != nil || len(reservations) == 0 {
-			queueInfo = "None"
-		} else {
-			var queueMembers []string
-			for i, member := range reservations {
-				queueMembers = append(queueMembers, fmt.Sprintf("%d. %s (ID: %d)", i+1, member.Name, member.ID))
-			}
-			queueInfo = strings.Join(queueMembers, ", ")
+	switch action {
+	case "deactivate":
+		cancelled, err := mgr.DeactivateMember(memberID, staffID)
+		if err != nil {
+			printError(err)
+			return
 		}
-
-		// Print book information
-		availStr := "Yes"
-		if !b.Available {
-			availStr = "No"
+		fmt.Printf("✓ %s deactivated; %d reservation(s) cancelled\n", member.Name, cancelled)
+	case "reactivate":
+		if err := mgr.ReactivateMember(memberID, staffID); err != nil {
+			printError(err)
+			return
 		}
-
-		fmt.Printf("%-5d %-30s %-25s %-10s %-20s %s\n",
-			b.ID,
-			truncateString(b.Title, 30),
-			truncateString(b.Author, 25),
-			availStr,
-			truncateString(borrowerInfo, 20),
-			queueInfo)
+		fmt.Printf("✓ %s reactivated\n", member.Name)
+	case "delete":
+		if err := mgr.DeleteMember(memberID, staffID); err != nil {
+			printError(err)
+			return
+		}
+		fmt.Printf("✓ %s deleted\n", member.Name)
 	}
 }
 
-func handleListMembers(mgr *library.LibraryManager) {
-	members, err := mgr.GetAllMembers()
+// handleShowMember prints the staff view of a member record, including notes.
+// handleForgetMember erases a member on request (`forget member <id>`) and
+// prints the erasure certificate. See library.ForgetMember for the policy.
+func handleForgetMember(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	memberIDStr, _ := splitArgs(args)
+
+	staffID, err := authenticateStaff(sc, mgr)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		fmt.Printf("Authentication failed: %v\n", err)
 		return
 	}
 
-	if len(members) == 0 {
-		fmt.Println("No members registered.")
+	memberID, ok := argOrPromptID(sc, memberIDStr, "Member ID: ", "member")
+	if !ok {
+		return
+	}
+	member, err := mgr.GetMember(memberID)
+	if err != nil {
+		fmt.Printf("Error: Member with ID %d not found\n", memberID)
 		return
 	}
 
-	fmt.Printf("%-5s %-30s %-15s\n", "ID", "Name", "Password Set")
-	fmt.Println(strings.Repeat("-", 55))
+	prompt("Permanently erase %s (ID: %d)? This cannot be undone. Type the member's name to confirm: ", member.Name, memberID)
+	if !sc.Scan() || strings.TrimSpace(sc.Text()) != member.Name {
+		fmt.Println("Cancelled.")
+		return
+	}
 
-	for _, member := range members {
-		passwordStatus := "No"
-		if member.PasswordHash != "" {
-			passwordStatus = "Yes"
+	cert, err := mgr.ForgetMember(memberID, staffID)
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("✓ Member %d erased. Erasure certificate #%d, %s\n", cert.MemberID, cert.ID, displayTime(cert.CreatedTime).Format("2006-01-02 15:04:05 MST"))
+	for _, part := range []struct {
+		label  string
+		counts map[string]int
+	}{{"Deleted", cert.Deleted}, {"Anonymized", cert.Anonymized}} {
+		var items []string
+		for _, k := range sortedCountKeys(part.counts) {
+			if part.counts[k] > 0 {
+				items = append(items, fmt.Sprintf("%s %d", k, part.counts[k]))
+			}
+		}
+		if len(items) > 0 {
+			fmt.Printf("  %s: %s\n", part.label, strings.Join(items, ", "))
 		}
-		fmt.Printf("%-5d %-30s %-15s\n", member.ID, member.Name, passwordStatus)
 	}
 }
 
-func handleSearchBooks(sc *bufio.Scanner, mgr *library.LibraryManager) {
-	fmt.Print("Query: ")
-	if !sc.Scan() {
-		return
+// handleCollection runs the "collection <subcommand> <name>" commands.
+// Curating a list is staff work; anyone can view or print one.
+func handleCollection(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	sub, name := splitArgs(args)
+	name = strings.TrimSpace(name)
+	if name == "" {
+		prompt("Collection name: ")
+		if !sc.Scan() {
+			return
+		}
+		name = strings.TrimSpace(sc.Text())
+	}
+
+	switch sub {
+	case "show":
+		c, err := mgr.GetCollection(name)
+		if err != nil {
+			printError(err)
+			return
+		}
+		fmt.Printf("\n📋 %s\n", c.Name)
+		if c.Description != "" {
+			fmt.Printf("   %s\n", c.Description)
+		}
+		for i, it := range c.Items {
+			fmt.Printf("%3d. %s by %s (ID: %d)\n", i+1, it.Title, it.Author, it.BookID)
+			if it.Blurb != "" {
+				fmt.Printf("     %s\n", it.Blurb)
+			}
+		}
+		if len(c.Items) == 0 {
+			fmt.Println("   (empty)")
+		}
+	case "export-pdf":
+		def := strings.ReplaceAll(strings.ToLower(name), " ", "-") + ".pdf"
+		prompt("Save to file (default %s): ", def)
+		if !sc.Scan() {
+			return
+		}
+		path := strings.TrimSpace(sc.Text())
+		if path == "" {
+			path = def
+		}
+		var buf bytes.Buffer
+		if err := mgr.WriteCollectionPDF(name, &buf); err != nil {
+			printError(err)
+			return
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			fmt.Printf("Error writing %s: %v\n", path, err)
+			return
+		}
+		fmt.Printf("✓ Handout for '%s' saved to %s\n", name, path)
+	case "create", "add", "remove":
+		if _, err := authenticateStaff(sc, mgr); err != nil {
+			fmt.Printf("Authentication failed: %v\n", err)
+			return
+		}
+		if sub == "create" {
+			prompt("Description (optional): ")
+			if !sc.Scan() {
+				return
+			}
+			if _, err := mgr.CreateCollection(name, sc.Text()); err != nil {
+				printError(err)
+				return
+			}
+			fmt.Printf("✓ Collection '%s' created\n", name)
+			return
+		}
+		bookID, ok := promptBookID(sc, "Book ID: ")
+		if !ok {
+			return
+		}
+		if sub == "remove" {
+			if err := mgr.RemoveFromCollection(name, bookID); err != nil {
+				printError(err)
+				return
+			}
+			fmt.Printf("✓ Book %d removed from '%s'\n", bookID, name)
+			return
+		}
+		prompt("Blurb (optional): ")
+		if !sc.Scan() {
+			return
+		}
+		if err := mgr.AddToCollection(name, bookID, sc.Text()); err != nil {
+			printError(err)
+			return
+		}
+		fmt.Printf("✓ Book %d added to '%s'\n", bookID, name)
+	default:
+		fmt.Println("Usage: collection show|export-pdf|create|add|remove <name>")
 	}
-	query := strings.TrimSpace(sc.Text())
+}
 
-	books, err := mgr.SearchBooks(query)
+func handleListCollections(mgr *library.LibraryManager) {
+	cs, err := mgr.GetCollections()
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		printError(err)
 		return
 	}
-
-	if len(books) == 0 {
-		fmt.Printf("No books found matching '%s'.\n", query)
+	if len(cs) == 0 {
+		fmt.Println("No collections yet.")
 		return
 	}
-
-	fmt.Printf("Found %d book(s) matching '%s':\n", len(books), query)
-	fmt.Printf("%-5s %-30s %-25s %-10s %-25s\n", "ID", "Title", "Author", "Available", "Borrower")
-	fmt.Println(strings.Repeat("-", 100))
-
-	for _, book := range books {
-		borrowerName := ""
-		if !book.Available && book.BorrowerID > 0 {
-			if member, err := mgr.GetMember(book.BorrowerID); err == nil {
-				borrowerName = member.Name
-			}
+	for _, c := range cs {
+		if c.Description != "" {
+			fmt.Printf("  %s — %s\n", c.Name, c.Description)
+		} else {
+			fmt.Printf("  %s\n", c.Name)
 		}
-		fmt.Printf("%-5d %-30s %-25s %-10t %-25s\n", book.ID, book.Title, book.Author, book.Available, borrowerName)
 	}
 }
 
-func handleCheckout(sc *bufio.Scanner, mgr *library.LibraryManager) {
-	fmt.Print("Book ID: ")
-	if !sc.Scan() {
-		return
+// sortedCountKeys returns the keys of counts in order.
+func sortedCountKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
 	}
-	bookIDStr := strings.TrimSpace(sc.Text())
-	bookID, err := strconv.ParseInt(bookIDStr, 10, 64)
-	if err != nil {
-		fmt.Printf("Invalid book ID: %s\n", bookIDStr)
+	sort.Strings(keys)
+	return keys
+}
+
+func handleShowMember(sc *bufio.Scanner, mgr *library.LibraryManager, args string) {
+	memberIDStr, _ := splitArgs(args)
+
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
 		return
 	}
 
-	fmt.Print("Member ID: ")
-	if !sc.Scan() {
+	memberID, ok := argOrPromptID(sc, memberIDStr, "Member ID: ", "member")
+	if !ok {
 		return
 	}
-	memberIDStr := strings.TrimSpace(sc.Text())
-	memberID, err := strconv.ParseInt(memberIDStr, 10, 64)
+
+	member, err := mgr.GetMember(memberID)
 	if err != nil {
-		fmt.Printf("Invalid member ID: %s\n", memberIDStr)
+		fmt.Printf("Error: Member with ID %d not found\n", memberID)
 		return
 	}
 
-	// Authenticate the member
-	if err := authenticateUser(sc, mgr, memberID); err != nil {
-		fmt.Printf("Authentication failed: %v\n", err)
-		return
+	fmt.Printf("Member:   %s (ID: %d)\n", member.Name, member.ID)
+	fmt.Printf("Tier:     %s\n", member.Tier)
+	fmt.Printf("Staff:    %t\n", member.IsAdmin)
+	if !member.Active {
+		fmt.Println("Status:   DEACTIVATED")
 	}
-
-	if err := mgr.CheckoutBook(bookID, memberID); err != nil {
-		fmt.Printf("Error checking out book: %v\n", err)
-		return
+	if member.ExpiryTime != nil {
+		status := ""
+		if member.Expired(mgr.Now()) {
+			status = " (EXPIRED)"
+		}
+		fmt.Printf("Expires:  %s%s\n", displayTime(*member.ExpiryTime).Format("2006-01-02"), status)
+	}
+	if loans, err := mgr.GetOpenLoans(memberID); err == nil {
+		fmt.Printf("Loans:    %d open\n", len(loans))
+	}
+	if balance, err := mgr.GetFineBalance(memberID); err == nil {
+		fmt.Printf("Balance:  %s\n", library.FormatCents(balance))
+	}
+	if has, err := mgr.HasMemberProfile(memberID); err == nil {
+		profile := "not on file"
+		if has {
+			profile = "on file (private)"
+		}
+		fmt.Printf("Profile:  %s\n", profile)
 	}
 
-	// Get member and book info for confirmation
-	member, _ := mgr.GetMember(memberID)
-	book, _ := mgr.GetBook(bookID)
-	fmt.Printf("Book '%s' checked out to %s\n", book.Title, member.Name)
-}
-
-func handleReturn(sc *bufio.Scanner, mgr *library.LibraryManager) {
-	fmt.Print("Book ID: ")
-	if !sc.Scan() {
-		return
+	if alerts, err := mgr.GetActiveAlerts(memberID); err == nil && len(alerts) > 0 {
+		fmt.Println("\nActive alerts:")
+		for _, a := range alerts {
+			fmt.Printf("  #%d %s %s\n", a.ID, alertLabel(a), a.Message)
+		}
 	}
-	bookIDStr := strings.TrimSpace(sc.Text())
-	bookID, err := strconv.ParseInt(bookIDStr, 10, 64)
+
+	notes, err := mgr.GetMemberNotes(memberID)
 	if err != nil {
-		fmt.Printf("Invalid book ID: %s\n", bookIDStr)
+		fmt.Printf("Error retrieving notes: %v\n", err)
 		return
 	}
-
-	fmt.Print("Member ID: ")
-	if !sc.Scan() {
+	fmt.Println("\nStaff notes:")
+	if len(notes) == 0 {
+		fmt.Println("  None")
 		return
 	}
-	memberIDStr := strings.TrimSpace(sc.Text())
-	memberID, err := strconv.ParseInt(memberIDStr, 10, 64)
-	if err != nil {
-		fmt.Printf("Invalid member ID: %s\n", memberIDStr)
+	for _, n := range notes {
+		fmt.Printf("  [%s] %s: %s\n", displayTime(n.CreatedTime).Format("2006-01-02 15:04"), n.AuthorName, n.Note)
+	}
+}
+
+func handleNotifications(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	memberID, ok := promptMemberID(sc, "Member ID: ")
+	if !ok {
 		return
 	}
 
@@ -399,304 +6308,326 @@ func handleReturn(sc *bufio.Scanner, mgr *library.LibraryManager) {
 		return
 	}
 
-	returnedBy, assignedTo, err := mgr.ReturnBookWithDetails(bookID, memberID)
+	notes, err := mgr.ReadNotifications(memberID)
 	if err != nil {
-		fmt.Printf("Error returning book: %v\n", err)
+		fmt.Printf("Error retrieving notifications: %v\n", err)
+		return
+	}
+	if len(notes) == 0 {
+		fmt.Println("No unread notifications.")
 		return
 	}
 
-	// Get book info
-	book, _ := mgr.GetBook(bookID)
-	returnedMember, _ := mgr.GetMember(returnedBy)
-
-	fmt.Printf("Book '%s' returned by %s\n", book.Title, returnedMember.Name)
-
-	if assignedTo > 0 {
-		assignedMember, _ := mgr.GetMember(assignedTo)
-		fmt.Printf("Book automatically assigned to %s (next in reservation queue)\n", assignedMember.Name)
-	} else {
-		fmt.Println("Book is now available for checkout")
+	fmt.Printf("You have %d unread notification(s):\n", len(notes))
+	for _, n := range notes {
+		fmt.Println(strings.Repeat("-", 60))
+		fmt.Printf("[%s] %s\n", displayTime(n.CreatedTime).Format("2006-01-02 15:04"), n.Subject)
+		if n.Body != "" {
+			fmt.Println(n.Body)
+		}
 	}
 }
 
-func handleReserve(sc *bufio.Scanner, mgr *library.LibraryManager) {
-	fmt.Print("Book ID: ")
-	if !sc.Scan() {
-		return
-	}
-	bookIDStr := strings.TrimSpace(sc.Text())
-	bookID, err := strconv.ParseInt(bookIDStr, 10, 64)
-	if err != nil {
-		fmt.Printf("Invalid book ID: %s\n", bookIDStr)
+// handleEmailSettings lets a member set the address reminders are emailed
+// to and choose which reminders they get.
+func handleEmailSettings(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if !capabilityEnabled(mgr, library.CapEmail) {
+		fmt.Println("This library does not send email.")
 		return
 	}
-
-	fmt.Print("Member ID: ")
-	if !sc.Scan() {
+	sess, ok := memberSession(sc, mgr)
+	if !ok {
 		return
 	}
-	memberIDStr := strings.TrimSpace(sc.Text())
-	memberID, err := strconv.ParseInt(memberIDStr, 10, 64)
+	prefs, err := mgr.GetEmailPreferences(sess.MemberID())
 	if err != nil {
-		fmt.Printf("Invalid member ID: %s\n", memberIDStr)
+		printError(err)
 		return
 	}
 
-	// Authenticate the member
-	if err := authenticateUser(sc, mgr, memberID); err != nil {
-		fmt.Printf("Authentication failed: %v\n", err)
-		return
+	current := prefs.Email
+	if current == "" {
+		current = "none"
 	}
-
-	err = mgr.ReserveBook(bookID, memberID)
-	if err != nil {
-		fmt.Printf("Error reserving book: %v\n", err)
+	prompt("Email address (currently %s; Enter to keep, - to remove): ", current)
+	if !sc.Scan() {
 		return
 	}
+	switch v := strings.TrimSpace(sc.Text()); v {
+	case "":
+	case "-":
+		prefs.Email = ""
+	default:
+		prefs.Email = v
+	}
 
-	// Get member and book info for confirmation
-	member, _ := mgr.GetMember(memberID)
-	book, _ := mgr.GetBook(bookID)
-
-	if book.Available {
-		fmt.Printf("Book '%s' immediately checked out to %s\n", book.Title, member.Name)
-	} else {
-		fmt.Printf("Book '%s' reserved for %s\n", book.Title, member.Name)
-
-		// Show current position in queue
-		reservations, err := mgr.GetReservations(bookID)
-		if err == nil {
-			for i, reservedMember := range reservations {
-				if reservedMember.ID == memberID {
-					fmt.Printf("Position in queue: %d\n", i+1)
-					break
-				}
+	if prefs.Email != "" {
+		for _, p := range []struct {
+			label string
+			on    *bool
+		}{
+			{"books due soon", &prefs.DueSoon},
+			{"overdue books", &prefs.Overdue},
+			{"holds ready for pickup", &prefs.HoldReady},
+			{"moving up a reservation queue", &prefs.QueueMoved},
+		} {
+			def := "n"
+			if *p.on {
+				def = "y"
+			}
+			prompt("Email me about %s? (y/n, Enter for %s): ", p.label, def)
+			if !sc.Scan() {
+				return
+			}
+			switch strings.ToLower(strings.TrimSpace(sc.Text())) {
+			case "y":
+				*p.on = true
+			case "n":
+				*p.on = false
 			}
 		}
 	}
-}
 
-func handleListReservations(sc *bufio.Scanner, mgr *library.LibraryManager) {
-	fmt.Print("Book ID (or press Enter for all books): ")
-	if !sc.Scan() {
+	if err := mgr.SetEmailPreferences(sess.MemberID(), *prefs); err != nil {
+		printError(err)
 		return
 	}
-	bookIDStr := strings.TrimSpace(sc.Text())
-
-	// If no Book ID provided, show reservations for all books
-	if bookIDStr == "" {
-		handleListAllReservations(mgr)
+	if prefs.Email == "" {
+		fmt.Println("✓ Reminders will not be emailed; they still appear under notifications")
 		return
 	}
+	fmt.Printf("✓ Reminders you chose will be emailed to %s\n", prefs.Email)
+}
 
-	bookID, err := strconv.ParseInt(bookIDStr, 10, 64)
-	if err != nil {
-		fmt.Printf("Invalid book ID: %s\n", bookIDStr)
-		return
-	}
+// capabilityEnabled reports whether the named subsystem is available. If
+// that can't be told, the command is offered and left to fail by itself.
+func capabilityEnabled(mgr *library.LibraryManager, name string) bool {
+	c, err := mgr.Capability(name)
+	return err != nil || c.Enabled
+}
 
-	book, err := mgr.GetBook(bookID)
+// handleCapabilities lists which optional subsystems this library has.
+func handleCapabilities(mgr *library.LibraryManager) {
+	caps, err := mgr.Capabilities()
 	if err != nil {
-		fmt.Printf("Error: Book with ID %d not found\n", bookID)
+		printError(err)
 		return
 	}
-
-	reservations, err := mgr.GetReservations(bookID)
-	if err != nil {
-		fmt.Printf("Error retrieving reservations: %v\n", err)
-		return
+	fmt.Printf("%-18s %-8s %s\n", "Capability", "Status", "Detail")
+	fmt.Println(strings.Repeat("-", 60))
+	for _, c := range caps {
+		status := "off"
+		if c.Enabled {
+			status = "on"
+		}
+		fmt.Printf("%-18s %-8s %s\n", c.Name, status, c.Detail)
 	}
+}
 
-	fmt.Printf("Reservations for '%s' by %s:\n", book.Title, book.Author)
+// usage counts commands and errors for telemetry once the library has
+// opted in with LIBRARY_TELEMETRY; until then it is nil and nothing is
+// counted.
+var usage *telemetry.Recorder
 
-	if len(reservations) == 0 {
-		fmt.Println("No reservations for this book.")
-		return
-	}
+// telemetryFile is where counts wait to be sent, under the user's home.
+var telemetryFile = filepath.Join(".library", "telemetry.json")
 
-	fmt.Printf("%-10s %-5s %-30s\n", "Position", "ID", "Name")
-	fmt.Println(strings.Repeat("-", 50))
+// telemetrySendTimeout bounds sending a report on the way out.
+const telemetrySendTimeout = 5 * time.Second
 
-	for i, member := range reservations {
-		fmt.Printf("%-10d %-5d %-30s\n", i+1, member.ID, member.Name)
+// openTelemetry starts counting if cfg opts in. Reports go to
+// LIBRARY_TELEMETRY_URL, or else the endpoint the build was stamped with.
+func openTelemetry(cfg Config) error {
+	if !cfg.Telemetry {
+		return nil
 	}
-}
-
-func handleListAllReservations(mgr *library.LibraryManager) {
-	books, err := mgr.GetAllBooks()
+	home, err := os.UserHomeDir()
 	if err != nil {
-		fmt.Printf("Error retrieving books: %v\n", err)
-		return
+		return fmt.Errorf("telemetry: %w", err)
 	}
+	endpoint := os.Getenv("LIBRARY_TELEMETRY_URL")
+	if endpoint == "" {
+		endpoint = telemetry.DefaultEndpoint
+	}
+	usage, err = telemetry.Open(filepath.Join(home, telemetryFile), endpoint, library.Version)
+	return err
+}
 
-	if len(books) == 0 {
-		fmt.Println("No books in the library.")
+// closeTelemetry sends the counts if a report is due and otherwise keeps
+// them for the next run; either way, failing is no reason to complain on
+// the way out.
+func closeTelemetry() {
+	if usage == nil {
 		return
 	}
-
-	fmt.Println("Reservation Status for All Books:")
-	fmt.Printf("%-5s %-30s %-25s %-12s %-30s %s\n", "ID", "Title", "Author", "Status", "Current Borrower", "Reservations")
-	fmt.Println(strings.Repeat("-", 130))
-
-	hasAnyReservations := false
-
-	for _, book := range books {
-		// Get current borrower info
-		var statusInfo, borrowerInfo string
-		if book.Available {
-			statusInfo = "Available"
-			borrowerInfo = "None"
-		} else {
-			statusInfo = "Checked Out"
-			if member, err := mgr.GetMember(book.BorrowerID); err == nil {
-				borrowerInfo = fmt.Sprintf("%s (ID: %d)", member.Name, member.ID)
-			} else {
-				borrowerInfo = fmt.Sprintf("ID: %d", book.BorrowerID)
-			}
+	if usage.Due(time.Now()) {
+		ctx, cancel := context.WithTimeout(context.Background(), telemetrySendTimeout)
+		defer cancel()
+		if usage.Send(ctx) == nil {
+			return
 		}
+	}
+	usage.Save()
+}
 
-		// Get reservations for this book
-		reservations, err := mgr.GetReservations(book.ID)
-		var reservationInfo string
-		if err != nil || len(reservations) == 0 {
-			reservationInfo = "None"
-		} else {
-			hasAnyReservations = true
-			var queueList []string
-			for i, member := range reservations {
-				queueList = append(queueList, fmt.Sprintf("%d.%s(ID:%d)", i+1, member.Name, member.ID))
-			}
-			reservationInfo = strings.Join(queueList, ", ")
-		}
+func countFeature(name string) {
+	if usage != nil {
+		usage.Feature(name)
+	}
+}
 
-		fmt.Printf("%-5d %-30s %-25s %-12s %-30s %s\n",
-			book.ID,
-			truncateString(book.Title, 30),
-			truncateString(book.Author, 25),
-			statusInfo,
-			truncateString(borrowerInfo, 30),
-			reservationInfo)
+func countError(category string) {
+	if usage != nil {
+		usage.Error(category)
 	}
+}
 
-	if !hasAnyReservations {
-		fmt.Println("\nNo active reservations in the system.")
-	} else {
-		fmt.Printf("\nTotal books: %d | Books with reservations: ", len(books))
-		reservedCount := 0
-		for _, book := range books {
-			if reservations, err := mgr.GetReservations(book.ID); err == nil && len(reservations) > 0 {
-				reservedCount++
-			}
+// printError reports a command's error, counting its category.
+func printError(err error) {
+	countError(telemetry.Classify(err))
+	fmt.Printf("Error: %v\n", err)
+}
+
+// commandName returns the name in commands of the command cmd runs, or ""
+// if it isn't one. Only the name is ever counted, never what follows it.
+func commandName(cmd string) string {
+	name := ""
+	for _, c := range commands {
+		if (cmd == c.Name || strings.HasPrefix(cmd, c.Name+" ")) && len(c.Name) > len(name) {
+			name = c.Name
 		}
-		fmt.Printf("%d\n", reservedCount)
 	}
+	return name
 }
 
-func handleCancelReservation(sc *bufio.Scanner, mgr *library.LibraryManager) {
-	fmt.Print("Book ID: ")
-	if !sc.Scan() {
+// handleTelemetry runs `telemetry show`: whether counts are shared, where
+// to, and the next report exactly as it would be sent.
+func handleTelemetry(args string) {
+	if a := strings.TrimSpace(args); a != "" && a != "show" {
+		fmt.Println("Usage: telemetry show")
 		return
 	}
-	bookIDStr := strings.TrimSpace(sc.Text())
-	bookID, err := strconv.ParseInt(bookIDStr, 10, 64)
-	if err != nil {
-		fmt.Printf("Invalid book ID: %s\n", bookIDStr)
+	if usage == nil {
+		fmt.Println("Telemetry is off: nothing is counted or sent.")
+		fmt.Println("Set LIBRARY_TELEMETRY=1 to share anonymous counts of the commands used and the kinds of error met.")
 		return
 	}
 
-	fmt.Print("Member ID: ")
-	if !sc.Scan() {
-		return
+	out := promptOutput()
+	switch {
+	case usage.Endpoint() == "":
+		fmt.Fprintln(out, "Telemetry is on, but no endpoint is set (LIBRARY_TELEMETRY_URL), so counts are kept and never sent.")
+	case usage.LastSent().IsZero():
+		fmt.Fprintf(out, "Telemetry is on. The first report goes to %s on the way out.\n", usage.Endpoint())
+	default:
+		next := usage.LastSent().Add(telemetry.SendInterval)
+		fmt.Fprintf(out, "Telemetry is on. The next report goes to %s on the way out after %s.\n",
+			usage.Endpoint(), displayTime(next).Format("2006-01-02 15:04"))
 	}
-	memberIDStr := strings.TrimSpace(sc.Text())
-	memberID, err := strconv.ParseInt(memberIDStr, 10, 64)
+	fmt.Fprintln(out, "It is exactly:")
+	data, err := json.MarshalIndent(usage.Report(), "", "  ")
 	if err != nil {
-		fmt.Printf("Invalid member ID: %s\n", memberIDStr)
+		printError(err)
 		return
 	}
+	fmt.Println(string(data))
+}
 
-	// Authenticate the member
-	if err := authenticateUser(sc, mgr, memberID); err != nil {
-		fmt.Printf("Authentication failed: %v\n", err)
+// handleVersion prints the program's build and the open database's schema
+// version.
+func handleVersion(mgr *library.LibraryManager) {
+	v, err := mgr.VersionInfo()
+	if err != nil {
+		printError(err)
 		return
 	}
+	printVersion(*v)
+}
 
-	if err := mgr.CancelReservation(bookID, memberID); err != nil {
-		fmt.Printf("Error cancelling reservation: %v\n", err)
+// printVersion prints a build's details, as a record for JSON or CSV.
+func printVersion(v library.VersionInfo) {
+	if machineOutput() {
+		writeRecords([]string{"version", "commit", "build_date", "go_version", "sqlite_version", "fts5", "schema_version", "latest_schema"},
+			[][]any{{v.Version, v.Commit, v.BuildDate, v.GoVersion, v.SQLiteVersion, v.FTS5, v.SchemaVersion, v.LatestSchema}})
 		return
 	}
-
-	// Get member and book info for confirmation
-	member, _ := mgr.GetMember(memberID)
-	book, _ := mgr.GetBook(bookID)
-	fmt.Printf("Reservation for '%s' cancelled for %s\n", book.Title, member.Name)
+	orUnknown := func(s string) string {
+		if s == "" {
+			return "unknown"
+		}
+		return s
+	}
+	fts := "without FTS5"
+	if v.FTS5 {
+		fts = "with FTS5"
+	}
+	schema := "up to date"
+	switch {
+	case v.SchemaVersion < v.LatestSchema:
+		schema = fmt.Sprintf("this build migrates to %d", v.LatestSchema)
+	case v.SchemaVersion > v.LatestSchema:
+		schema = fmt.Sprintf("newer than this build's %d", v.LatestSchema)
+	}
+	fmt.Printf("Library Management System %s\n", v.Version)
+	fmt.Printf("  Commit:          %s\n", orUnknown(v.Commit))
+	fmt.Printf("  Built:           %s\n", orUnknown(v.BuildDate))
+	fmt.Printf("  Go:              %s\n", v.GoVersion)
+	fmt.Printf("  SQLite:          %s, %s\n", v.SQLiteVersion, fts)
+	fmt.Printf("  Database schema: %d (%s)\n", v.SchemaVersion, schema)
 }
 
-func handleUpdateContent(sc *bufio.Scanner, mgr *library.LibraryManager) {
-	fmt.Print("Book ID: ")
-	if !sc.Scan() {
+// handleNotify emails any reminders that are due now instead of waiting
+// for the background job.
+func handleNotify(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	if _, err := authenticateStaff(sc, mgr); err != nil {
+		fmt.Printf("Authentication failed: %v\n", err)
 		return
 	}
-	bookIDStr := strings.TrimSpace(sc.Text())
-	bookID, err := strconv.ParseInt(bookIDStr, 10, 64)
+	mailer, err := smtpNotifier(mgr)
 	if err != nil {
-		fmt.Printf("Invalid book ID: %s\n", bookIDStr)
+		printError(err)
 		return
 	}
-
-	fmt.Print("Path to text file: ")
-	if !sc.Scan() {
+	if mailer == nil {
+		fmt.Println("Email is not configured; set LIBRARY_SMTP_ADDR and LIBRARY_SMTP_FROM.")
 		return
 	}
-	path := strings.TrimSpace(sc.Text())
-
-	if err := mgr.UpdateBookContentFromFile(bookID, path); err != nil {
-		fmt.Printf("Error updating book content: %v\n", err)
+	lead, err := reminderLead()
+	if err != nil {
+		printError(err)
 		return
 	}
 
-	book, _ := mgr.GetBook(bookID)
-	fmt.Printf("Content updated for book '%s'\n", book.Title)
-}
-
-func handleReadBook(sc *bufio.Scanner, mgr *library.LibraryManager) {
-	fmt.Print("Book ID: ")
-	if !sc.Scan() {
-		return
-	}
-	bookIDStr := strings.TrimSpace(sc.Text())
-	bookID, err := strconv.ParseInt(bookIDStr, 10, 64)
+	sent, err := mgr.SendEmailReminders(mailer, lead)
+	fmt.Printf("✓ Sent %d reminder email(s)\n", sent)
 	if err != nil {
-		fmt.Printf("Invalid book ID: %s\n", bookIDStr)
-		return
+		printError(err)
 	}
+}
 
-	fmt.Print("Member ID: ")
+func handleAnnounce(sc *bufio.Scanner, mgr *library.LibraryManager) {
+	prompt("Subject: ")
 	if !sc.Scan() {
 		return
 	}
-	memberIDStr := strings.TrimSpace(sc.Text())
-	memberID, err := strconv.ParseInt(memberIDStr, 10, 64)
-	if err != nil {
-		fmt.Printf("Invalid member ID: %s\n", memberIDStr)
-		return
-	}
+	subject := strings.TrimSpace(sc.Text())
 
-	// Authenticate the member
-	if err := authenticateUser(sc, mgr, memberID); err != nil {
-		fmt.Printf("Authentication failed: %v\n", err)
+	prompt("Message: ")
+	if !sc.Scan() {
 		return
 	}
+	body := strings.TrimSpace(sc.Text())
 
-	if err := mgr.ReadBook(bookID, memberID); err != nil {
-		fmt.Printf("Error reading book: %v\n", err)
+	reached, err := mgr.Announce(subject, body)
+	if err != nil {
+		fmt.Printf("Error sending announcement: %v\n", err)
 		return
 	}
+	fmt.Printf("Announcement delivered to %d member(s)\n", reached)
 }
 
+// truncateString shortens s to at most maxLength characters, ending it
+// with "..." when there is room, so it fits a table column.
 func truncateString(s string, maxLength int) string {
-	if len(s) <= maxLength {
-		return s
-	}
-	return s[:maxLength-3] + "..."
+	return library.Truncate(s, maxLength)
 }
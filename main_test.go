@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf8"
+
+	"library-management/librarytest"
+	"library-management/telemetry"
+)
+
+func TestSessionCirculation(t *testing.T) {
+	text := filepath.Join(t.TempDir(), "dune.txt")
+	if err := os.WriteFile(text, []byte("A beginning is the time for taking the most delicate care."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := startCLI(t)
+	s.expect("Welcome to the Library Management System")
+
+	s.run("add member",
+		"Name: ", "Alice",
+		"Enter password for Alice: ", "password1",
+		"Membership type", "")
+	s.expect("Added adult member 'Alice' with ID 1")
+
+	s.run("add book",
+		"Title: ", "Dune",
+		"Author: ", "Frank Herbert",
+		"Path to text file (optional): ", text,
+		"ISBN (optional): ", "",
+		"Publisher (optional): ", "",
+		"Publication year (optional): ", "1965",
+		"Language (optional): ", "",
+		"Page count (optional): ", "")
+	s.expect("Added book ID 1 with content.")
+
+	s.run("login", "Member ID: ", "1", "Enter your password: ", "wrong")
+	s.expect("Authentication failed")
+	s.run("login", "Member ID: ", "1", "Enter your password: ", "password1")
+	s.expect("✓ Logged in as Alice")
+
+	// Logged in, circulation commands don't ask for the password again
+	s.run("checkout", "Book ID or title: ", "dune")
+	s.expect("Found 'Dune' by Frank Herbert (ID 1).")
+	s.expect("Book 'Dune' checked out to Alice")
+
+	s.run("read book", "Book ID: ", "1")
+	s.expect("Reader: Alice | Page 1 of 1")
+	s.expect("A beginning is the time")
+	s.expect("Command: ")
+	s.send("q")
+
+	s.run("return", "Book ID: ", "1")
+	s.expect("Book 'Dune' returned by Alice")
+	s.expect("Book is now available for checkout")
+
+	s.run("logout")
+	s.expect("✓ Logged out")
+	s.run("exit")
+	s.expect("Goodbye!")
+
+	if b, err := s.mgr.GetBook(1); err != nil || !b.Available || b.PublicationYear != 1965 {
+		t.Fatalf("book after the session = %+v, %v", b, err)
+	}
+}
+
+func TestSessionPrompts(t *testing.T) {
+	s := startCLI(t)
+
+	s.run("no such command")
+	s.expect("Unknown command. Type help to list the commands.")
+
+	// A lone ? explains the question and asks it again
+	s.run("return", "Book ID: ", "?")
+	s.expect("Book ID: ")
+	s.send("abc")
+	s.expect("Try again, or type cancel.")
+	s.expect("Book ID: ")
+	s.send("cancel")
+	s.expect("Cancelled.")
+
+	s.run("logout")
+	s.expect("Not logged in.")
+
+	s.run("version")
+	s.expect("Library Management System dev")
+	s.expect("with FTS5")
+	s.expect("(up to date)")
+}
+
+// After staff force a password reset, a logged-in member is logged out and
+// must choose a new password, other than the one they had, to sign in.
+func TestSessionForcedPasswordChange(t *testing.T) {
+	s := startCLI(t)
+	alice, err := s.mgr.AddMember("Alice", "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.run("login", "Member ID: ", fmt.Sprint(alice), "Enter your password: ", "password1")
+	s.expect("✓ Logged in as Alice")
+
+	if _, err := s.mgr.ForcePasswordReset(0, nil); err != nil {
+		t.Fatal(err)
+	}
+	s.run("loans", "Member ID: ", fmt.Sprint(alice), "Enter your password: ", "password1",
+		"New password: ", "password1", "Confirm new password: ", "password1")
+	s.expect("must differ from the current one")
+
+	s.run("login", "Member ID: ", fmt.Sprint(alice), "Enter your password: ", "password1",
+		"New password: ", "password2", "Confirm new password: ", "password2")
+	s.expect("✓ Password changed")
+	s.expect("✓ Logged in as Alice")
+}
+
+// With telemetry on, the prompt counts commands by name and errors by kind,
+// and `telemetry show` prints the report as it would be sent.
+func TestSessionTelemetry(t *testing.T) {
+	s := startCLI(t)
+	s.run("telemetry show")
+	s.expect("Telemetry is off: nothing is counted or sent.")
+	s.run("exit")
+	s.expect("Goodbye!")
+
+	path := filepath.Join(t.TempDir(), "telemetry.json")
+	rec, err := telemetry.Open(path, "", "1.5.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage = rec
+	t.Cleanup(func() { usage = nil })
+
+	s = startCLI(t)
+	s.run("list books --tag poetry")
+	s.expect("No books tagged 'poetry'.")
+	s.run("frobnicate the catalog")
+	s.expect("Unknown command.")
+	s.run("return", "Book ID: ", "abc")
+	s.expect("Try again, or type cancel.")
+	s.send("cancel")
+	s.expect("Cancelled.")
+	s.run("telemetry show")
+	s.expect("no endpoint is set")
+	s.expect(`"list books": 1`)
+	s.expect(`"return": 1`)
+	s.expect(`"invalid_input": 1`)
+	s.expect(`"unknown_command": 1`)
+
+	// Only command names are counted, never what follows them
+	want := map[string]int{"list books": 1, "return": 1, "telemetry show": 1}
+	if got := rec.Report().Features; !maps.Equal(got, want) {
+		t.Fatalf("features = %v, want %v", got, want)
+	}
+}
+
+// Listings of the fixture library in each output format match the golden
+// files in testdata/golden; after changing a listing on purpose, rerun with
+// -update and review the diff.
+func TestGoldenListings(t *testing.T) {
+	mgr, fx := librarytest.NewFixtureLibrary(t)
+	s := startCLIOver(t, mgr)
+
+	s.run("login", "Member ID: ", fmt.Sprint(fx.Alice), "Enter your password: ", librarytest.FixturePassword)
+	s.expect("✓ Logged in as Alice Archer")
+	s.run("loans")
+	librarytest.CheckGolden(t, "loans.txt", []byte(s.output()))
+	s.run("list reservations", "Book ID (or press Enter for all books): ", "")
+	librarytest.CheckGolden(t, "reservations.txt", []byte(s.output()))
+
+	for _, format := range []string{"table", "json", "csv"} {
+		s.run("set output " + format)
+		s.output()
+		s.run("list books")
+		librarytest.CheckGolden(t, "books."+format, []byte(s.output()))
+		s.run("list members")
+		librarytest.CheckGolden(t, "members."+format, []byte(s.output()))
+	}
+}
+
+// FuzzTruncateString checks that truncated text stays well-formed and fits
+// its table column exactly, whatever the characters in it.
+func FuzzTruncateString(f *testing.F) {
+	f.Add("The Left Hand of Darkness", 20)
+	f.Add("Les Misérables, tome premier", 12)
+	f.Add("日本語の本のタイトル", 5)
+	f.Add("🐉🐉🐉🐉", 2)
+	f.Fuzz(func(t *testing.T, s string, width int) {
+		width &= 0x7f
+		got := truncateString(s, width)
+		if utf8.RuneCountInString(s) <= width && got != s {
+			t.Fatalf("truncateString(%q, %d) = %q; it fitted already", s, width, got)
+		}
+		if !utf8.ValidString(s) {
+			return
+		}
+		if !utf8.ValidString(got) {
+			t.Fatalf("truncateString(%q, %d) = %q, not valid UTF-8", s, width, got)
+		}
+		if cell := fmt.Sprintf("%-*s", width, got); utf8.RuneCountInString(cell) != width {
+			t.Fatalf("truncateString(%q, %d) = %q fills %d characters", s, width, got, utf8.RuneCountInString(cell))
+		}
+	})
+}
@@ -0,0 +1,286 @@
+// Package selfupdate replaces the running program with a newer release, so
+// that a library without IT staff can keep the tool current itself.
+//
+// A release endpoint serves, for each channel, the channel's latest release
+// as <endpoint>/<channel>/manifest.json:
+//
+//	{"channel": "stable", "version": "1.5.0",
+//	 "binaries": {"linux-amd64": {"url": "library-cli-linux-amd64", "sha256": "9f86d0..."}}}
+//
+// with an Ed25519 signature of the file, base64-encoded, beside it in
+// manifest.json.sig. A manifest is only believed when its signature checks
+// out against the release key and it names the channel it was fetched
+// for, and a binary only installed when its SHA-256 matches the
+// manifest's, so neither the endpoint nor the network in between can slip
+// in a program of its own, or another channel's release.
+//
+// Binary URLs may be relative to the manifest. The new binary is written
+// next to the old and renamed over it, so the program on disk is always
+// one release or the other, never half of each.
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Release channels: stable releases, or those and the betas before them.
+const (
+	Stable = "stable"
+	Beta   = "beta"
+)
+
+// Channels lists the release channels.
+var Channels = []string{Stable, Beta}
+
+// Release builds stamp the endpoint they update from and the key their
+// releases are signed with (base64), e.g.
+//
+//	-ldflags "-X library-management/selfupdate.DefaultEndpoint=https://... -X library-management/selfupdate.ReleaseKey=..."
+var (
+	DefaultEndpoint = ""
+	ReleaseKey      = ""
+)
+
+// Size limits on what an endpoint may send.
+const (
+	maxManifestSize = 1 << 20
+	maxBinarySize   = 512 << 20
+)
+
+// ErrNoKey is returned by NewUpdater when there is no key to check
+// releases against.
+var ErrNoKey = errors.New("no release key: this build cannot check releases")
+
+// Manifest describes a channel's latest release.
+type Manifest struct {
+	Channel  string            `json:"channel"`
+	Version  string            `json:"version"`
+	Binaries map[string]Binary `json:"binaries"` // by GOOS-GOARCH
+}
+
+// Binary is a release's program for one platform.
+type Binary struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"` // hex
+}
+
+// Updater checks a release channel and installs what it finds.
+type Updater struct {
+	endpoint *url.URL
+	channel  string
+	key      ed25519.PublicKey
+	client   *http.Client
+	platform string
+}
+
+// Option configures an Updater.
+type Option func(*Updater)
+
+// WithHTTPClient makes requests with hc instead of http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(u *Updater) { u.client = hc }
+}
+
+// WithPlatform fetches the binary for platform ("linux-amd64") instead of
+// the running one's.
+func WithPlatform(platform string) Option {
+	return func(u *Updater) { u.platform = platform }
+}
+
+// NewUpdater returns an Updater for channel of the endpoint, checking
+// releases against key, a base64 Ed25519 public key.
+func NewUpdater(endpoint, channel, key string, opts ...Option) (*Updater, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("no release endpoint configured")
+	}
+	base, err := url.Parse(strings.TrimSuffix(endpoint, "/") + "/")
+	if err != nil || (base.Scheme != "https" && base.Scheme != "http") || base.Host == "" {
+		return nil, fmt.Errorf("invalid release endpoint %q", endpoint)
+	}
+	if !validChannel(channel) {
+		return nil, fmt.Errorf("unknown release channel %q (use %s)", channel, strings.Join(Channels, " or "))
+	}
+	if key == "" {
+		return nil, ErrNoKey
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid release key")
+	}
+	u := &Updater{
+		endpoint: base,
+		channel:  channel,
+		key:      ed25519.PublicKey(raw),
+		client:   http.DefaultClient,
+		platform: runtime.GOOS + "-" + runtime.GOARCH,
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u, nil
+}
+
+func validChannel(channel string) bool {
+	for _, c := range Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// Latest fetches the channel's manifest and checks its signature, and that
+// it was signed as the channel's.
+func (u *Updater) Latest(ctx context.Context) (*Manifest, error) {
+	manifestURL := u.endpoint.JoinPath(u.channel, "manifest.json")
+	data, err := u.fetch(ctx, manifestURL.String(), maxManifestSize)
+	if err != nil {
+		return nil, fmt.Errorf("fetch manifest: %w", err)
+	}
+	sigData, err := u.fetch(ctx, manifestURL.String()+".sig", maxManifestSize)
+	if err != nil {
+		return nil, fmt.Errorf("fetch manifest signature: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigData)))
+	if err != nil || !ed25519.Verify(u.key, data, sig) {
+		return nil, fmt.Errorf("manifest signature does not match the release key")
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	if m.Channel != u.channel {
+		return nil, fmt.Errorf("manifest is signed for the %q channel, not %q", m.Channel, u.channel)
+	}
+	if _, ok := parseVersion(m.Version); !ok {
+		return nil, fmt.Errorf("manifest has an invalid version %q", m.Version)
+	}
+	// Relative URLs are relative to the manifest
+	for platform, b := range m.Binaries {
+		ref, err := url.Parse(b.URL)
+		if err != nil {
+			return nil, fmt.Errorf("manifest has an invalid URL for %s: %w", platform, err)
+		}
+		b.URL = manifestURL.ResolveReference(ref).String()
+		m.Binaries[platform] = b
+	}
+	return &m, nil
+}
+
+// Install downloads m's binary for the platform, checks it against the
+// manifest's checksum and puts it in place of the program at path, keeping
+// the program's file mode.
+func (u *Updater) Install(ctx context.Context, m *Manifest, path string) error {
+	b, ok := m.Binaries[u.platform]
+	if !ok {
+		return fmt.Errorf("release %s has no build for %s", m.Version, u.platform)
+	}
+	want, err := hex.DecodeString(b.SHA256)
+	if err != nil || len(want) != sha256.Size {
+		return fmt.Errorf("release %s has an invalid checksum for %s", m.Version, u.platform)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("download release: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download release: %s", resp.Status)
+	}
+	return replaceFile(path, io.LimitReader(resp.Body, maxBinarySize), want, info.Mode().Perm())
+}
+
+// replaceFile writes r beside path and, if its SHA-256 is want, renames it
+// over path. Otherwise path is left as it was.
+func replaceFile(path string, r io.Reader, want []byte, mode os.FileMode) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".update-*")
+	if err != nil {
+		return fmt.Errorf("write new release: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), r); err != nil {
+		return fmt.Errorf("download release: %w", err)
+	}
+	if got := h.Sum(nil); !bytes.Equal(got, want) {
+		return fmt.Errorf("download does not match the release checksum (got %x)", got)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("write new release: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write new release: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("write new release: %w", err)
+	}
+
+	// Windows won't rename over a running program, but will rename it
+	// out of the way
+	if runtime.GOOS == "windows" {
+		old := path + ".old"
+		os.Remove(old)
+		if err := os.Rename(path, old); err != nil {
+			return fmt.Errorf("install new release: %w", err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("install new release: %w", err)
+	}
+	return nil
+}
+
+// fetch GETs url and returns at most limit bytes of its body.
+func (u *Updater) fetch(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("larger than %d bytes", limit)
+	}
+	return data, nil
+}
@@ -0,0 +1,201 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// releaseServer serves, as the stable channel's, a manifest for binary
+// signed with a fresh key as channel's release, and returns the server and
+// the key's public half.
+func releaseServer(t *testing.T, channel, version string, binary []byte, tamper func(*Manifest)) (*httptest.Server, string) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(binary)
+	m := Manifest{Channel: channel, Version: version, Binaries: map[string]Binary{
+		"linux-amd64": {URL: "../files/library-cli", SHA256: hex.EncodeToString(sum[:])},
+	}}
+	manifest, _ := json.Marshal(m)
+	sig := ed25519.Sign(priv, manifest)
+	if tamper != nil {
+		tamper(&m)
+		manifest, _ = json.Marshal(m)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /releases/stable/manifest.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(manifest)
+	})
+	mux.HandleFunc("GET /releases/stable/manifest.json.sig", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(base64.StdEncoding.EncodeToString(sig) + "\n"))
+	})
+	mux.HandleFunc("GET /releases/files/library-cli", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(binary)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, base64.StdEncoding.EncodeToString(pub)
+}
+
+// program writes a stand-in for the running program and returns its path.
+func program(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "library-cli")
+	if err := os.WriteFile(path, []byte("old release"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestInstall(t *testing.T) {
+	ctx := context.Background()
+	srv, key := releaseServer(t, Stable, "1.5.0", []byte("new release"), nil)
+	path := program(t)
+
+	u, err := NewUpdater(srv.URL+"/releases", Stable, key, WithPlatform("linux-amd64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := u.Latest(ctx)
+	if err != nil {
+		t.Fatalf("latest: %v", err)
+	}
+	if m.Version != "1.5.0" || m.Binaries["linux-amd64"].URL != srv.URL+"/releases/files/library-cli" {
+		t.Fatalf("manifest = %+v", m)
+	}
+	if err := u.Install(ctx, m, path); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	info, _ := os.Stat(path)
+	if string(data) != "new release" || info.Mode().Perm() != 0o755 {
+		t.Fatalf("installed %q with mode %v", data, info.Mode())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Fatalf("expected no files left beside the program, got %d", len(entries))
+	}
+
+	// No build for the platform
+	other, _ := NewUpdater(srv.URL+"/releases", Stable, key, WithPlatform("plan9-386"))
+	if err := other.Install(ctx, m, path); err == nil || !strings.Contains(err.Error(), "no build for plan9-386") {
+		t.Fatalf("expected no build, got %v", err)
+	}
+	// Nothing published on the beta channel
+	beta, _ := NewUpdater(srv.URL+"/releases", Beta, key)
+	if _, err := beta.Latest(ctx); err == nil {
+		t.Fatalf("expected a missing manifest to fail")
+	}
+}
+
+func TestInstallRefusesBadChecksum(t *testing.T) {
+	ctx := context.Background()
+	srv, key := releaseServer(t, Stable, "1.5.0", []byte("new release"), nil)
+	path := program(t)
+	u, _ := NewUpdater(srv.URL+"/releases", Stable, key, WithPlatform("linux-amd64"))
+	m, err := u.Latest(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256([]byte("something else"))
+	m.Binaries["linux-amd64"] = Binary{URL: m.Binaries["linux-amd64"].URL, SHA256: hex.EncodeToString(sum[:])}
+	if err := u.Install(ctx, m, path); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if string(data) != "old release" || len(entries) != 1 {
+		t.Fatalf("expected the old program alone untouched, got %q and %d files", data, len(entries))
+	}
+}
+
+func TestLatestRefusesBadSignature(t *testing.T) {
+	ctx := context.Background()
+
+	// A manifest changed after signing
+	srv, key := releaseServer(t, Stable, "1.5.0", []byte("new release"), func(m *Manifest) {
+		m.Binaries["linux-amd64"] = Binary{URL: "https://elsewhere.example/evil", SHA256: m.Binaries["linux-amd64"].SHA256}
+	})
+	u, _ := NewUpdater(srv.URL+"/releases", Stable, key)
+	if _, err := u.Latest(ctx); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Fatalf("expected a bad signature, got %v", err)
+	}
+
+	// A manifest signed with another key
+	srv, _ = releaseServer(t, Stable, "1.5.0", []byte("new release"), nil)
+	u, _ = NewUpdater(srv.URL+"/releases", Stable, key)
+	if _, err := u.Latest(ctx); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Fatalf("expected a bad signature, got %v", err)
+	}
+}
+
+// A beta manifest, rightly signed, is refused when served as the stable
+// channel's.
+func TestLatestRefusesOtherChannel(t *testing.T) {
+	ctx := context.Background()
+	srv, key := releaseServer(t, Beta, "1.6.0-beta.1", []byte("beta release"), nil)
+	u, _ := NewUpdater(srv.URL+"/releases", Stable, key)
+	if _, err := u.Latest(ctx); err == nil || !strings.Contains(err.Error(), `"beta" channel`) {
+		t.Fatalf("expected the beta manifest to be refused, got %v", err)
+	}
+}
+
+func TestNewUpdater(t *testing.T) {
+	_, key := releaseServer(t, Stable, "1.5.0", nil, nil)
+	for _, tc := range []struct {
+		endpoint, channel, key string
+	}{
+		{"", Stable, key},
+		{"releases.example", Stable, key},
+		{"https://releases.example", "nightly", key},
+		{"https://releases.example", Stable, ""},
+		{"https://releases.example", Stable, "not a key"},
+	} {
+		if _, err := NewUpdater(tc.endpoint, tc.channel, tc.key); err == nil {
+			t.Errorf("NewUpdater(%q, %q, %q): expected an error", tc.endpoint, tc.channel, tc.key)
+		}
+	}
+	if _, err := NewUpdater("https://releases.example/", Beta, key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewer(t *testing.T) {
+	for _, tc := range []struct {
+		release, current string
+		want             bool
+	}{
+		{"1.5.0", "1.4.9", true},
+		{"v1.10.0", "1.9.3", true},
+		{"2.0.0", "1.99.99", true},
+		{"1.5.0", "1.5.0", false},
+		{"1.4.0", "1.5.0", false},
+		{"1.5.0", "1.5.0-beta.2", true},
+		{"1.5.0-beta.2", "1.5.0", false},
+		{"1.5.0-beta.10", "1.5.0-beta.2", true},
+		{"1.5.0-rc.1", "1.5.0-beta.2", true},
+		{"1.5.0-beta.1", "1.5.0-beta", true},
+		{"1.5.0-beta", "1.5.0-1", true},
+		{"1.5.0+linux", "1.5.0", false},
+		{"1.5.0", "dev", false},
+		{"nightly", "1.4.0", false},
+		{"1.05.0", "1.4.0", false},
+	} {
+		if got := Newer(tc.release, tc.current); got != tc.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tc.release, tc.current, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package selfupdate
+
+import (
+	"strconv"
+	"strings"
+)
+
+// version is a semantic version: 1.5.0, or 1.5.0-beta.2 before it.
+type version struct {
+	major, minor, patch int
+	pre                 []string
+}
+
+// parseVersion reads a semantic version, with or without a leading v;
+// build metadata after a + is ignored.
+func parseVersion(s string) (version, bool) {
+	s = strings.TrimPrefix(s, "v")
+	s, _, _ = strings.Cut(s, "+")
+	s, pre, hasPre := strings.Cut(s, "-")
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return version{}, false
+	}
+	var nums [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || (len(p) > 1 && p[0] == '0') {
+			return version{}, false
+		}
+		nums[i] = n
+	}
+	v := version{major: nums[0], minor: nums[1], patch: nums[2]}
+	if hasPre {
+		v.pre = strings.Split(pre, ".")
+		for _, id := range v.pre {
+			if id == "" {
+				return version{}, false
+			}
+		}
+	}
+	return v, true
+}
+
+// compare returns -1, 0 or 1 as v is older than, the same as or newer
+// than w, by semantic versioning's precedence rules.
+func (v version) compare(w version) int {
+	for _, d := range []int{v.major - w.major, v.minor - w.minor, v.patch - w.patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	// A pre-release comes before its release
+	switch {
+	case len(v.pre) == 0 && len(w.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(w.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.pre) && i < len(w.pre); i++ {
+		a, aErr := strconv.Atoi(v.pre[i])
+		b, bErr := strconv.Atoi(w.pre[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if a != b {
+				return sign(a - b)
+			}
+		case aErr == nil: // numbers come before words
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(v.pre[i], w.pre[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return sign(len(v.pre) - len(w.pre))
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// Newer reports whether release is newer than current. A current version
+// that isn't a semantic version, such as a development build's "dev", is
+// never updated.
+func Newer(release, current string) bool {
+	r, ok := parseVersion(release)
+	if !ok {
+		return false
+	}
+	c, ok := parseVersion(current)
+	return ok && r.compare(c) > 0
+}
+
+// ValidVersion reports whether s is a semantic version that releases can
+// be compared with.
+func ValidVersion(s string) bool {
+	_, ok := parseVersion(s)
+	return ok
+}
@@ -0,0 +1,249 @@
+// Package telemetry counts, for libraries that opt in, which commands are
+// used and what kinds of error people run into, and now and then sends the
+// counts to the maintainers so they know where work is most needed.
+//
+// A report holds the program's version and platform, and counts keyed by
+// command name and by error category; nothing else. It has no member,
+// book or library names, no IDs, no text that was typed and no times, so
+// it cannot tell one library from another. Counts wait in a local file
+// until they are sent, at most once a day, and a send that fails keeps
+// them for next time.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Release builds stamp the endpoint reports go to, e.g.
+//
+//	-ldflags "-X library-management/telemetry.DefaultEndpoint=https://..."
+var DefaultEndpoint = ""
+
+// SendInterval is how long a Recorder waits between reports.
+const SendInterval = 24 * time.Hour
+
+// Error categories. Only the category of an error is counted, never its
+// message.
+const (
+	ErrAuth           = "auth"            // a wrong password or missing rights
+	ErrUnknownCommand = "unknown_command" // something typed at the prompt that isn't a command
+	ErrNotFound       = "not_found"       // a book, member or file that isn't there
+	ErrInvalidInput   = "invalid_input"   // an answer or argument that doesn't parse
+	ErrDatabase       = "database"        // SQLite refused or failed
+	ErrNetwork        = "network"         // a server, mail server or endpoint out of reach
+	ErrOther          = "other"
+)
+
+// Report is what a Recorder sends.
+type Report struct {
+	Version  string         `json:"version"`
+	Platform string         `json:"platform"` // GOOS-GOARCH
+	Features map[string]int `json:"features"` // uses by command name
+	Errors   map[string]int `json:"errors"`   // occurrences by category
+}
+
+// empty reports whether r has nothing worth sending.
+func (r *Report) empty() bool { return len(r.Features) == 0 && len(r.Errors) == 0 }
+
+// state is what a Recorder keeps on disk between runs.
+type state struct {
+	Features map[string]int `json:"features,omitempty"`
+	Errors   map[string]int `json:"errors,omitempty"`
+	LastSent time.Time      `json:"last_sent"` // kept here, never sent
+}
+
+// Recorder counts feature use and errors, keeps the counts in a file and
+// sends them to an endpoint. It is safe for concurrent use.
+type Recorder struct {
+	path     string
+	endpoint string
+	version  string
+	client   *http.Client
+
+	mu    sync.Mutex
+	state state
+}
+
+// Open returns a Recorder for the program at version, keeping its counts in
+// path and sending them to endpoint; with no endpoint, counts are kept but
+// never sent. Counts left in path by an earlier run are carried on.
+func Open(path, endpoint, version string) (*Recorder, error) {
+	r := &Recorder{path: path, endpoint: endpoint, version: version, client: &http.Client{Timeout: 10 * time.Second}}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("read telemetry: %w", err)
+	default:
+		// A damaged file loses its counts rather than stopping the program
+		json.Unmarshal(data, &r.state)
+	}
+	if r.state.Features == nil {
+		r.state.Features = make(map[string]int)
+	}
+	if r.state.Errors == nil {
+		r.state.Errors = make(map[string]int)
+	}
+	return r, nil
+}
+
+// Endpoint returns where reports are sent, or "" if nowhere.
+func (r *Recorder) Endpoint() string { return r.endpoint }
+
+// LastSent returns when a report was last sent, or the zero time.
+func (r *Recorder) LastSent() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state.LastSent
+}
+
+// Feature counts a use of the command name.
+func (r *Recorder) Feature(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state.Features[name]++
+}
+
+// Error counts an error of category, one of the Err constants.
+func (r *Recorder) Error(category string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state.Errors[category]++
+}
+
+// Report returns the report that would be sent now.
+func (r *Recorder) Report() *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Report{
+		Version:  r.version,
+		Platform: runtime.GOOS + "-" + runtime.GOARCH,
+		Features: maps.Clone(r.state.Features),
+		Errors:   maps.Clone(r.state.Errors),
+	}
+}
+
+// Save writes the counts to the Recorder's file, readable only by its
+// owner.
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.state, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o700); err != nil {
+		return fmt.Errorf("save telemetry: %w", err)
+	}
+	if err := os.WriteFile(r.path, data, 0o600); err != nil {
+		return fmt.Errorf("save telemetry: %w", err)
+	}
+	return nil
+}
+
+// Due reports whether there is an endpoint, something to send and
+// SendInterval has passed since the last report at now.
+func (r *Recorder) Due(now time.Time) bool {
+	if r.endpoint == "" {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.state.Features) == 0 && len(r.state.Errors) == 0 {
+		return false
+	}
+	return now.Sub(r.state.LastSent) >= SendInterval
+}
+
+// Send posts the report to the endpoint as JSON and, once it is accepted,
+// clears the counts it held and saves. Counts made meanwhile are kept.
+func (r *Recorder) Send(ctx context.Context) error {
+	if r.endpoint == "" {
+		return fmt.Errorf("no telemetry endpoint configured")
+	}
+	report := r.Report()
+	if report.empty() {
+		return nil
+	}
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send telemetry: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("send telemetry: %s", resp.Status)
+	}
+
+	r.mu.Lock()
+	for name, n := range report.Features {
+		subtract(r.state.Features, name, n)
+	}
+	for category, n := range report.Errors {
+		subtract(r.state.Errors, category, n)
+	}
+	r.state.LastSent = time.Now()
+	r.mu.Unlock()
+	return r.Save()
+}
+
+func subtract(counts map[string]int, key string, n int) {
+	if counts[key] -= n; counts[key] <= 0 {
+		delete(counts, key)
+	}
+}
+
+// Classify returns the category of err, going by the errors it wraps and
+// failing that by its wording.
+func Classify(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, os.ErrNotExist):
+		return ErrNotFound
+	case errors.Is(err, os.ErrPermission):
+		return ErrAuth
+	case errors.As(err, &netErr), errors.Is(err, context.DeadlineExceeded):
+		return ErrNetwork
+	}
+	msg := strings.ToLower(err.Error())
+	for _, c := range []struct {
+		category string
+		words    []string
+	}{
+		{ErrAuth, []string{"password", "authenticat", "privilege", "not authorized", "permission"}},
+		{ErrNotFound, []string{"not found", "no such"}},
+		{ErrInvalidInput, []string{"invalid", "must be", "cannot be empty", "required"}},
+		{ErrDatabase, []string{"database", "sqlite", "constraint"}},
+	} {
+		for _, w := range c.words {
+			if strings.Contains(msg, w) {
+				return c.category
+			}
+		}
+	}
+	return ErrOther
+}
@@ -0,0 +1,144 @@
+package telemetry
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecorderKeepsCounts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".library", "telemetry.json")
+	r, err := Open(path, "", "1.5.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Feature("checkout")
+	r.Feature("checkout")
+	r.Error(ErrAuth)
+	if err := r.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("saved file: %v, %v", info, err)
+	}
+
+	r, err = Open(path, "", "1.5.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Feature("checkout")
+	report := r.Report()
+	if report.Version != "1.5.0" || report.Features["checkout"] != 3 || report.Errors[ErrAuth] != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	// Nothing is sent without an endpoint
+	if r.Due(time.Now()) {
+		t.Fatalf("expected no report due without an endpoint")
+	}
+	if err := r.Send(context.Background()); err == nil {
+		t.Fatalf("expected sending without an endpoint to fail")
+	}
+
+	// A damaged file starts the counts afresh
+	os.WriteFile(path, []byte("{not json"), 0o600)
+	if r, err = Open(path, "", "1.5.0"); err != nil || len(r.Report().Features) != 0 {
+		t.Fatalf("damaged file: %v, %v", r.Report(), err)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var mu sync.Mutex
+	var received []Report
+	fail := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		var report Report
+		if err := json.Unmarshal(body, &report); err != nil || req.Method != http.MethodPost {
+			t.Errorf("%s %q: %v", req.Method, body, err)
+		}
+		received = append(received, report)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "telemetry.json")
+	r, _ := Open(path, srv.URL, "1.5.0")
+	if r.Due(time.Now()) {
+		t.Fatalf("expected nothing due with nothing counted")
+	}
+	r.Feature("read book")
+	r.Error(ErrNotFound)
+	if !r.Due(time.Now()) {
+		t.Fatalf("expected a first report to be due")
+	}
+
+	// What is sent is what Report shows
+	want := r.Report()
+	if err := r.Send(ctx); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if len(received) != 1 || !maps.Equal(received[0].Features, want.Features) ||
+		!maps.Equal(received[0].Errors, want.Errors) || received[0].Platform != want.Platform {
+		t.Fatalf("received %+v, want %+v", received, want)
+	}
+	if got := r.Report(); len(got.Features) != 0 || len(got.Errors) != 0 {
+		t.Fatalf("counts after sending = %+v", got)
+	}
+
+	// The next report waits a day
+	r.Feature("read book")
+	if r.Due(time.Now()) || !r.Due(time.Now().Add(SendInterval)) {
+		t.Fatalf("expected the next report due in %v", SendInterval)
+	}
+
+	// A failed send keeps the counts, on disk too
+	mu.Lock()
+	fail = true
+	mu.Unlock()
+	if err := r.Send(ctx); err == nil {
+		t.Fatalf("expected the send to fail")
+	}
+	r.Save()
+	r, _ = Open(path, srv.URL, "1.5.0")
+	if r.Report().Features["read book"] != 1 || r.LastSent().IsZero() {
+		t.Fatalf("after failed send: %+v, last sent %v", r.Report(), r.LastSent())
+	}
+}
+
+func TestClassify(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("load member: %w", sql.ErrNoRows), ErrNotFound},
+		{&os.PathError{Op: "open", Path: "books.csv", Err: os.ErrNotExist}, ErrNotFound},
+		{errors.New("book not found"), ErrNotFound},
+		{errors.New("invalid credentials: wrong password"), ErrAuth},
+		{errors.New("staff privileges required"), ErrAuth},
+		{errors.New("invalid ISBN \"12\""), ErrInvalidInput},
+		{errors.New("database is locked"), ErrDatabase},
+		{fmt.Errorf("send: %w", context.DeadlineExceeded), ErrNetwork},
+		{errors.New("book is currently checked out by another member"), ErrOther},
+	} {
+		if got := Classify(tc.err); got != tc.want {
+			t.Errorf("Classify(%q) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}